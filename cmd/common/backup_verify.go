@@ -0,0 +1,85 @@
+package common
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	backupVerifyShortDescription = "Verifies the backup objects against the signed integrity manifest"
+	backupVerifyLongDescription  = "Checks the signature of the backup integrity manifest, then streams every backup object " +
+		"and recomputes its size and sha256. Reports the missing, truncated, tampered and unexpected objects " +
+		"without decrypting or restoring anything. Use LATEST to verify the latest backup.\n\n" +
+		"With --restore-cmd the backup is restored instead: the backup stream is piped to the restore command " +
+		"running in the scratch directory, then the validation command is run. The result is recorded " +
+		"in the verification sentinel of the backup and shown by backup-list --detail."
+
+	restoreCmdFlag         = "restore-cmd"
+	restoreCmdDescription  = "Shell command restoring the backup from stdin into $" + internal.VerifyScratchDirEnv
+	validateCmdFlag        = "validate-cmd"
+	validateCmdDescription = "Shell command validating the restored backup"
+	scratchDirFlag         = "scratch-dir"
+	scratchDirDescription  = "Directory to create the temporary restore directory in"
+	verifyUntilFlag        = "until"
+	verifyUntilDescription = "Fetch the logs until this time in RFC3339 instead of the end of the backup"
+)
+
+var (
+	restoreCommand  string
+	validateCommand string
+	scratchDir      string
+	verifyUntil     string
+
+	// BackupVerifyLogsFetcher fetches the logs of the backup for the restore command,
+	// it is set by the databases which keep the logs apart from the backups
+	BackupVerifyLogsFetcher internal.RestoreCheckLogsFetcher
+)
+
+// BackupVerifyCmd represents the backup-verify command
+var BackupVerifyCmd = &cobra.Command{
+	Use:   "backup-verify backup_name",
+	Short: backupVerifyShortDescription,
+	Long:  backupVerifyLongDescription,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		folder, err := internal.ConfigureFolder()
+		tracelog.ErrorLogger.FatalOnError(err)
+		if restoreCommand == "" {
+			err = internal.HandleBackupVerify(folder, args[0])
+			tracelog.ErrorLogger.FatalOnError(err)
+			return
+		}
+
+		internal.ConfigureLimiters()
+		ctx, cancel := context.WithCancel(context.Background())
+		signalHandler := utility.NewSignalHandler(ctx, cancel, []os.Signal{syscall.SIGINT, syscall.SIGTERM})
+		defer func() { _ = signalHandler.Close() }()
+
+		config := internal.RestoreCheckConfig{
+			RestoreCommand:  restoreCommand,
+			ValidateCommand: validateCommand,
+			ScratchDir:      scratchDir,
+			LogsFetcher:     BackupVerifyLogsFetcher,
+		}
+		if verifyUntil != "" {
+			config.Until, err = time.Parse(time.RFC3339, verifyUntil)
+			tracelog.ErrorLogger.FatalOnError(err)
+		}
+		err = internal.HandleBackupRestoreCheck(ctx, folder, args[0], config)
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+func init() {
+	BackupVerifyCmd.Flags().StringVar(&restoreCommand, restoreCmdFlag, "", restoreCmdDescription)
+	BackupVerifyCmd.Flags().StringVar(&validateCommand, validateCmdFlag, "", validateCmdDescription)
+	BackupVerifyCmd.Flags().StringVar(&scratchDir, scratchDirFlag, "", scratchDirDescription)
+	BackupVerifyCmd.Flags().StringVar(&verifyUntil, verifyUntilFlag, "", verifyUntilDescription)
+}
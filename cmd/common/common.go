@@ -62,6 +62,9 @@ func Init(cmd *cobra.Command, dbName string) {
 	// Add storage tools
 	cmd.AddCommand(st.StorageToolsCmd)
 
+	// Add backup integrity verification
+	cmd.AddCommand(BackupVerifyCmd)
+
 	// profiler
 	persistentPreRun := cmd.PersistentPreRun
 	persistentPostRun := cmd.PersistentPostRun
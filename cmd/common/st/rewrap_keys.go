@@ -0,0 +1,32 @@
+package st
+
+import (
+	"github.com/apecloud/dataprotection-wal-g/internal/multistorage"
+	"github.com/apecloud/dataprotection-wal-g/internal/storagetools"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+)
+
+const rewrapKeysShortDescription = "Re-wrap the envelope encryption data keys with the new master key"
+
+// rewrapKeysCmd represents the rewrapKeys command
+var rewrapKeysCmd = &cobra.Command{
+	Use:   "rewrap-keys new_master_key_config",
+	Short: rewrapKeysShortDescription,
+	Long: "Decrypt every data key of the envelope encryption with the configured master key " +
+		"and encrypt it with the master key from the new config file. The backups and WAL are not re-uploaded.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		newConfigPath := args[0]
+
+		err := multistorage.ExecuteOnStorage(targetStorage, func(folder storage.Folder) error {
+			return storagetools.HandleRewrapKeys(folder, newConfigPath)
+		})
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+func init() {
+	StorageToolsCmd.AddCommand(rewrapKeysCmd)
+}
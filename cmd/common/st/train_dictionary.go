@@ -0,0 +1,57 @@
+package st
+
+import (
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/zstd"
+	"github.com/apecloud/dataprotection-wal-g/internal/multistorage"
+	"github.com/apecloud/dataprotection-wal-g/internal/storagetools"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	trainDictionaryShortDescription = "Train the zstd dictionary on the storage objects"
+
+	maxSamplesFlag     = "samples"
+	sampleSizeFlag     = "sample-size"
+	dictionarySizeFlag = "size"
+)
+
+// trainDictionaryCmd represents the trainDictionary command
+var trainDictionaryCmd = &cobra.Command{
+	Use:   "train-dict [samples_folder]",
+	Short: trainDictionaryShortDescription,
+	Long: "Train the zstd dictionary on the objects of the folder (WAL archive by default) " +
+		"and upload it to the storage root. Set WALG_ZSTD_DICTIONARY to the printed ID to compress with it.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		samplesPath := utility.WalPath
+		if len(args) > 0 {
+			samplesPath = args[0]
+		}
+
+		err := multistorage.ExecuteOnStorage(targetStorage, func(folder storage.Folder) error {
+			uploader, err := internal.ConfigureUploaderToFolder(folder)
+			if err != nil {
+				return err
+			}
+			return storagetools.HandleTrainDictionary(samplesPath, uploader, maxSamples, sampleSize, dictionarySize)
+		})
+		tracelog.ErrorLogger.FatalOnError(err)
+	},
+}
+
+var maxSamples int
+var sampleSize int
+var dictionarySize int
+
+func init() {
+	StorageToolsCmd.AddCommand(trainDictionaryCmd)
+	trainDictionaryCmd.Flags().IntVar(&maxSamples, maxSamplesFlag, 100, "Number of the objects to train on")
+	trainDictionaryCmd.Flags().IntVar(&sampleSize, sampleSizeFlag, 128<<10,
+		"Number of the decompressed bytes to take from the beginning of every object")
+	trainDictionaryCmd.Flags().IntVar(&dictionarySize, dictionarySizeFlag, zstd.DefaultDictionarySize,
+		"Size of the dictionary in bytes")
+}
@@ -16,6 +16,7 @@ const (
 	targetUserDataDescription    = "Fetch storage backup which has the specified user data"
 	restorePointDescription      = "Fetch storage backup w/ restore point specified by name"
 	restorePointTSDescription    = "Fetch storage backup w/ restore point time less or equal to the provided timestamp"
+	targetTimeDescription        = "Recover to the latest restore point created before or at this time in RFC3339"
 	restoreConfigPathDescription = "Path to the cluster restore configuration"
 	fetchContentIdsDescription   = "If set, WAL-G will fetch only the specified segments"
 	fetchModeDescription         = "Backup fetch mode. default: do the backup unpacking " +
@@ -25,6 +26,7 @@ const (
 
 var fetchTargetUserData string
 var restorePointTS string
+var targetTime string
 var restorePoint string
 var restoreConfigPath string
 var fetchContentIds *[]int
@@ -32,7 +34,7 @@ var fetchModeStr string
 var inPlaceRestore bool
 
 var backupFetchCmd = &cobra.Command{
-	Use:   "backup-fetch [backup_name | --target-user-data <data> | --restore-point <name>]",
+	Use:   "backup-fetch [backup_name | --target-user-data <data> | --restore-point <name> | --target-time <time>]",
 	Short: backupFetchShortDescription, // TODO : improve description
 	Args:  cobra.RangeArgs(0, 1),
 	Run: func(cmd *cobra.Command, args []string) {
@@ -50,8 +52,14 @@ var backupFetchCmd = &cobra.Command{
 		folder, err := internal.ConfigureFolder()
 		tracelog.ErrorLogger.FatalOnError(err)
 
+		if restorePointTS != "" && targetTime != "" {
+			tracelog.ErrorLogger.Fatalf("can't use both --restore-point-ts and --target-time")
+		}
+		if targetTime != "" {
+			restorePointTS = targetTime
+		}
 		if restorePoint != "" && restorePointTS != "" {
-			tracelog.ErrorLogger.Fatalf("can't use both --restore-point and --restore-point-ts")
+			tracelog.ErrorLogger.Fatalf("can't use both --restore-point and --restore-point-ts or --target-time")
 		}
 
 		if restorePointTS != "" {
@@ -105,6 +113,7 @@ func init() {
 		"", targetUserDataDescription)
 	backupFetchCmd.Flags().StringVar(&restorePointTS, "restore-point-ts", "", restorePointTSDescription)
 	backupFetchCmd.Flags().StringVar(&restorePoint, "restore-point", "", restorePointDescription)
+	backupFetchCmd.Flags().StringVar(&targetTime, "target-time", "", targetTimeDescription)
 	backupFetchCmd.Flags().StringVar(&restoreConfigPath, "restore-config",
 		"", restoreConfigPathDescription)
 	backupFetchCmd.Flags().BoolVar(&inPlaceRestore, "in-place", false, inPlaceFlagDescription)
@@ -1,6 +1,8 @@
 package gp
 
 import (
+	"time"
+
 	"github.com/apecloud/dataprotection-wal-g/internal/databases/greenplum"
 
 	"github.com/spf13/cobra"
@@ -9,15 +11,32 @@ import (
 
 const (
 	createRestorePointDescription = "Creates cluster-wide restore point with the specified name"
+	everyDescription              = "Keep running and create the restore points named <name>_<time> with this interval, e.g. 30m"
+
+	defaultScheduledRestorePointPrefix = "restore_point"
 )
 
 var (
 	// createRestorePointCmd represents the createRestorePoint command
 	createRestorePointCmd = &cobra.Command{
-		Use:   "create-restore-point name",
+		Use:   "create-restore-point {name | [name_prefix] --every <interval>}",
 		Short: createRestorePointDescription, // TODO : improve description
-		Args:  cobra.ExactArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if restorePointInterval != 0 {
+				return cobra.MaximumNArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 		Run: func(cmd *cobra.Command, args []string) {
+			if restorePointInterval != 0 {
+				prefix := defaultScheduledRestorePointPrefix
+				if len(args) > 0 {
+					prefix = args[0]
+				}
+				greenplum.HandleScheduledRestorePoints(prefix, restorePointInterval)
+				return
+			}
+
 			name := args[0]
 
 			restorePointCreator, err := greenplum.NewRestorePointCreator(name)
@@ -26,8 +45,10 @@ var (
 			restorePointCreator.Create()
 		},
 	}
+	restorePointInterval time.Duration
 )
 
 func init() {
+	createRestorePointCmd.Flags().DurationVar(&restorePointInterval, "every", 0, everyDescription)
 	cmd.AddCommand(createRestorePointCmd)
 }
@@ -0,0 +1,39 @@
+package gp
+
+import (
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/greenplum"
+	"github.com/spf13/cobra"
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	segmentAgentShortDescription = "Runs the agent serving the segment commands of the coordinator"
+	segmentAgentLongDescription  = "Runs the agent on the segment host, so the coordinator dispatches " +
+		"the segment backup-push and backup-fetch commands over HTTP instead of SSH " +
+		"(WALG_GP_SEG_TRANSPORT=agent). The agent listens on WALG_GP_SEG_AGENT_SOCKET if it is set, " +
+		"on WALG_GP_SEG_AGENT_PORT otherwise, which requires WALG_GP_SEG_AGENT_TOKEN."
+	listenFlag = "listen"
+)
+
+var (
+	segmentAgentCmd = &cobra.Command{
+		Use:   "seg-agent",
+		Short: segmentAgentShortDescription,
+		Long:  segmentAgentLongDescription,
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if listenAddress == "" {
+				listenAddress = greenplum.SegmentAgentListenAddress()
+			}
+			err := greenplum.HandleSegmentAgent(listenAddress)
+			tracelog.ErrorLogger.FatalOnError(err)
+		},
+	}
+	listenAddress string
+)
+
+func init() {
+	segmentAgentCmd.Flags().StringVar(&listenAddress, listenFlag, "",
+		"The address to listen on, host:port or unix:/path/to/socket")
+	cmd.AddCommand(segmentAgentCmd)
+}
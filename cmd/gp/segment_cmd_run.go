@@ -1,6 +1,9 @@
 package gp
 
 import (
+	"encoding/json"
+	"strings"
+
 	"github.com/apecloud/dataprotection-wal-g/internal"
 	"github.com/apecloud/dataprotection-wal-g/internal/databases/greenplum"
 	"github.com/spf13/cobra"
@@ -18,7 +21,11 @@ var (
 		Args:  cobra.ExactArgs(2),
 		Run: func(cmd *cobra.Command, args []string) {
 			cmdName := args[0]
-			cmdArgs := args[1]
+			cmdArgs := strings.Fields(args[1])
+			if jsonArgs {
+				err := json.Unmarshal([]byte(args[1]), &cmdArgs)
+				tracelog.ErrorLogger.FatalfOnError("Failed to unmarshal the command args: %v", err)
+			}
 
 			greenplum.SetSegmentStoragePrefix(contentID)
 
@@ -29,10 +36,15 @@ var (
 	}
 )
 
-var contentID int
+var (
+	contentID int
+	jsonArgs  bool
+)
 
 func init() {
 	segCmdRunCmd.PersistentFlags().IntVar(&contentID, "content-id", 0, "segment content ID")
+	segCmdRunCmd.PersistentFlags().BoolVar(&jsonArgs, greenplum.SegCmdRunJSONArgsFlag, false,
+		"args are a JSON array passed to the command as is")
 	_ = segCmdRunCmd.MarkFlagRequired("content-id")
 	// Since this is a utility command, it should not be exposed to the end user.
 	segCmdRunCmd.Hidden = true
@@ -8,6 +8,7 @@ import (
 	"github.com/apecloud/dataprotection-wal-g/cmd/common"
 
 	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/mongo"
 	"github.com/spf13/cobra"
 	"github.com/wal-g/tracelog"
 )
@@ -42,6 +43,7 @@ func Execute() {
 
 func init() {
 	common.Init(cmd, internal.MONGO)
+	common.BackupVerifyLogsFetcher = mongo.FetchRestoreCheckOplog
 	internal.AddTurboFlag(cmd)
 	internal.RequiredSettings[internal.MongoDBUriSetting] = true
 }
@@ -1,6 +1,8 @@
 package mysql
 
 import (
+	"time"
+
 	"github.com/apecloud/dataprotection-wal-g/internal"
 	"github.com/apecloud/dataprotection-wal-g/internal/databases/mysql"
 	"github.com/spf13/cobra"
@@ -15,6 +17,8 @@ const (
 	ImpermanentFlagShortHand   = "i"
 	ImpermanentFlag            = "impermanent"
 	backupMarkShortDescription = "mark permanent/impermanent target backup"
+	LockForDescription         = "Extends the storage object lock of the backup objects by the duration from now, e.g. 720h"
+	LockForFlag                = "lock-for"
 )
 
 var (
@@ -28,10 +32,14 @@ var (
 			uploader, err := internal.ConfigureUploader()
 			tracelog.ErrorLogger.FatalOnError(err)
 			mysql.MarkBackup(uploader, name, !toImpermanent)
+			if lockFor > 0 {
+				internal.HandleBackupLockExtend(uploader, name, lockFor)
+			}
 		},
 	}
 	toImpermanent = false
 	name          = ""
+	lockFor       time.Duration
 )
 
 func init() {
@@ -41,5 +49,6 @@ func init() {
 		false,
 		ImpermanentDescription)
 	backupMarkCmd.Flags().StringVarP(&name, backupNameFlag, backupShorthand, "", backupMarkShortDescription)
+	backupMarkCmd.Flags().DurationVar(&lockFor, LockForFlag, 0, LockForDescription)
 	cmd.AddCommand(backupMarkCmd)
 }
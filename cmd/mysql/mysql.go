@@ -8,6 +8,7 @@ import (
 	"github.com/apecloud/dataprotection-wal-g/cmd/common"
 
 	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/mysql"
 	"github.com/spf13/cobra"
 	"github.com/wal-g/tracelog"
 )
@@ -44,5 +45,6 @@ func Execute() {
 
 func init() {
 	common.Init(cmd, internal.MYSQL)
+	common.BackupVerifyLogsFetcher = mysql.FetchRestoreCheckBinlogs
 	internal.AddTurboFlag(cmd)
 }
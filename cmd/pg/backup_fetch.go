@@ -2,6 +2,7 @@ package pg
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/apecloud/dataprotection-wal-g/internal"
 	"github.com/apecloud/dataprotection-wal-g/internal/databases/postgres"
@@ -21,7 +22,19 @@ For information about pattern syntax view: https://golang.org/pkg/path/filepath/
 	skipRedundantTarsDescription  = "Skip tars with no useful data (requires reverse delta unpack)"
 	targetUserDataDescription     = "Fetch storage backup which has the specified user data"
 	restoreOnlyDescription        = `[Experimental] Downloads only databases specified by passed names from default tablespace.
+A single table is passed as database/schema.table, the public schema is used if omitted.
 Sets reverse delta unpack & skip redundant tars options automatically. Always downloads system databases.`
+	targetTimeDescription     = "Recover to this time in RFC3339, picks the newest backup finished before it"
+	targetLSNDescription      = "Recover to this LSN, picks the newest backup finished before it"
+	targetXidDescription      = "Recover to this transaction ID, requires the name of the backup finished before it"
+	targetTimelineDescription = "Timeline to recover along: a number, latest or current"
+	targetActionDescription   = "recovery_target_action to write: pause, promote or shutdown"
+	restoreCommandDescription = "restore_command to write, defaults to wal-fetch of this binary"
+	verifyOnlyDescription     = `Reads the backup and its delta chain without writing to disk and checks the tars,
+the page checksums and the file sizes. The destination_directory must be omitted.`
+	logicalFetchDescription  = "Restores the logical backup with pg_restore, the destination_directory must be omitted"
+	databaseFetchDescription = `The database to restore the logical backup into, defaults to the dumped one.
+LATEST picks the newest logical backup of this database.`
 )
 
 var fileMask string
@@ -30,22 +43,72 @@ var reverseDeltaUnpack bool
 var skipRedundantTars bool
 var fetchTargetUserData string
 var onlyDatabases []string
+var targetTime string
+var targetLSN string
+var targetXid string
+var targetTimeline string
+var targetAction string
+var restoreCommand string
+var verifyOnly bool
+var fetchLogical bool
+var fetchLogicalDatabase string
 
 var backupFetchCmd = &cobra.Command{
-	Use:   "backup-fetch destination_directory [backup_name | --target-user-data <data>]",
+	Use: "backup-fetch {destination_directory | --verify-only} [backup_name | --target-user-data <data> | " +
+		"--target-time <time> | --target-lsn <lsn> | --target-xid <xid>] | --logical backup_name [--database <name>]",
 	Short: backupFetchShortDescription, // TODO : improve description
-	Args:  cobra.RangeArgs(1, 2),
+	Args: func(cmd *cobra.Command, args []string) error {
+		if fetchLogical {
+			return cobra.ExactArgs(1)(cmd, args)
+		}
+		if verifyOnly {
+			return cobra.MaximumNArgs(1)(cmd, args)
+		}
+		return cobra.RangeArgs(1, 2)(cmd, args)
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		internal.ConfigureLimiters()
+		folder, err := internal.ConfigureFolder()
+		tracelog.ErrorLogger.FatalOnError(err)
 
-		if fetchTargetUserData == "" {
-			fetchTargetUserData = viper.GetString(internal.FetchTargetUserDataSetting)
+		if fetchLogical {
+			postgres.HandleLogicalBackupFetch(folder, args[0], fetchLogicalDatabase)
+			return
+		}
+		if verifyOnly {
+			// there is no destination directory
+			args = append([]string{""}, args...)
 		}
-		targetBackupSelector, err := createTargetFetchBackupSelector(cmd, args, fetchTargetUserData)
-		tracelog.ErrorLogger.FatalOnError(err)
 
-		folder, err := internal.ConfigureFolder()
-		tracelog.ErrorLogger.FatalOnError(err)
+		var targetBackupSelector internal.BackupSelector
+		var recoveryTarget *postgres.RecoveryTarget
+		var pitrPlan *postgres.PitrPlan
+		if targetTime != "" || targetLSN != "" || targetXid != "" {
+			if fetchTargetUserData != "" {
+				tracelog.ErrorLogger.Fatal("The recovery target can't be combined with --target-user-data")
+			}
+			recoveryTarget, err = postgres.NewRecoveryTarget(targetTime, targetLSN, targetXid, targetTimeline, targetAction)
+			tracelog.ErrorLogger.FatalOnError(err)
+			backupName := ""
+			if len(args) >= 2 {
+				backupName = args[1]
+			}
+			pitrPlan, err = postgres.PlanPointInTimeRestore(folder, recoveryTarget, backupName)
+			tracelog.ErrorLogger.FatalOnError(err)
+			targetBackupSelector, err = internal.NewBackupNameSelector(pitrPlan.Backup.BackupName, true)
+			tracelog.ErrorLogger.FatalOnError(err)
+		} else {
+			if fetchTargetUserData == "" {
+				fetchTargetUserData = viper.GetString(internal.FetchTargetUserDataSetting)
+			}
+			targetBackupSelector, err = createTargetFetchBackupSelector(cmd, args, fetchTargetUserData)
+			tracelog.ErrorLogger.FatalOnError(err)
+		}
+
+		if verifyOnly {
+			internal.HandleBackupFetch(folder, targetBackupSelector, postgres.GetPgFetcherVerifyOnly(fileMask, os.Stdout))
+			return
+		}
 
 		var pgFetcher func(folder storage.Folder, backup internal.Backup)
 
@@ -71,9 +134,27 @@ var backupFetchCmd = &cobra.Command{
 		}
 
 		internal.HandleBackupFetch(folder, targetBackupSelector, pgFetcher)
+
+		if pitrPlan != nil {
+			if restoreCommand == "" {
+				restoreCommand, err = defaultRestoreCommand()
+				tracelog.ErrorLogger.FatalOnError(err)
+			}
+			err = postgres.WriteRecoveryConfig(args[0], pitrPlan, recoveryTarget, restoreCommand)
+			tracelog.ErrorLogger.FatalOnError(err)
+		}
 	},
 }
 
+// defaultRestoreCommand fetches the WAL with this binary and config
+func defaultRestoreCommand() (string, error) {
+	binaryPath, err := os.Executable()
+	if err != nil {
+		binaryPath = "wal-g"
+	}
+	return postgres.DefaultRestoreCommand(binaryPath, internal.CfgFile)
+}
+
 // create the BackupSelector to select the backup to fetch
 func createTargetFetchBackupSelector(cmd *cobra.Command,
 	args []string, targetUserData string) (internal.BackupSelector, error) {
@@ -101,6 +182,16 @@ func init() {
 		"", targetUserDataDescription)
 	backupFetchCmd.Flags().StringSliceVar(&onlyDatabases, "restore-only",
 		nil, restoreOnlyDescription)
+	backupFetchCmd.Flags().StringVar(&targetTime, "target-time", "", targetTimeDescription)
+	backupFetchCmd.Flags().StringVar(&targetLSN, "target-lsn", "", targetLSNDescription)
+	backupFetchCmd.Flags().StringVar(&targetXid, "target-xid", "", targetXidDescription)
+	backupFetchCmd.Flags().StringVar(&targetTimeline, "target-timeline",
+		postgres.TargetTimelineLatest, targetTimelineDescription)
+	backupFetchCmd.Flags().StringVar(&targetAction, "target-action", "", targetActionDescription)
+	backupFetchCmd.Flags().StringVar(&restoreCommand, "restore-command", "", restoreCommandDescription)
+	backupFetchCmd.Flags().BoolVar(&verifyOnly, "verify-only", false, verifyOnlyDescription)
+	backupFetchCmd.Flags().BoolVar(&fetchLogical, logicalFlag, false, logicalFetchDescription)
+	backupFetchCmd.Flags().StringVar(&fetchLogicalDatabase, databaseFlag, "", databaseFetchDescription)
 
 	Cmd.AddCommand(backupFetchCmd)
 }
@@ -27,7 +27,7 @@ var (
 			if detail {
 				postgres.HandleDetailedBackupList(folder.GetSubFolder(utility.BaseBackupPath), pretty, json)
 			} else {
-				internal.DefaultHandleBackupList(folder.GetSubFolder(utility.BaseBackupPath), pretty, json)
+				postgres.HandleBackupList(folder, pretty, json)
 			}
 		},
 	}
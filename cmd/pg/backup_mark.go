@@ -1,6 +1,8 @@
 package pg
 
 import (
+	"time"
+
 	"github.com/apecloud/dataprotection-wal-g/internal"
 	"github.com/apecloud/dataprotection-wal-g/internal/databases/postgres"
 	"github.com/spf13/cobra"
@@ -13,6 +15,8 @@ const (
 	Permanent backups are prevented from being removed when running delete.`
 	ImpermanentDescription = "Marks a backup impermanent"
 	ImpermanentFlag        = "impermanent"
+	LockForDescription     = "Extends the storage object lock of the backup objects by the duration from now, e.g. 720h"
+	LockForFlag            = "lock-for"
 )
 
 var (
@@ -26,12 +30,17 @@ var (
 			uploader, err := internal.ConfigureUploader()
 			tracelog.ErrorLogger.FatalOnError(err)
 			internal.HandleBackupMark(uploader, args[0], !toImpermanent, postgres.NewGenericMetaInteractor())
+			if lockFor > 0 {
+				internal.HandleBackupLockExtend(uploader, args[0], lockFor)
+			}
 		},
 	}
 	toImpermanent = false
+	lockFor       time.Duration
 )
 
 func init() {
 	backupMarkCmd.Flags().BoolVarP(&toImpermanent, ImpermanentFlag, "i", false, ImpermanentDescription)
+	backupMarkCmd.Flags().DurationVar(&lockFor, LockForFlag, 0, LockForDescription)
 	Cmd.AddCommand(backupMarkCmd)
 }
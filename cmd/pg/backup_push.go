@@ -25,6 +25,8 @@ const (
 	deltaFromNameFlag         = "delta-from-name"
 	addUserDataFlag           = "add-user-data"
 	withoutFilesMetadataFlag  = "without-files-metadata"
+	logicalFlag               = "logical"
+	databaseFlag              = "database"
 
 	permanentShorthand             = "p"
 	fullBackupShorthand            = "f"
@@ -38,12 +40,17 @@ const (
 var (
 	// backupPushCmd represents the backupPush command
 	backupPushCmd = &cobra.Command{
-		Use:   "backup-push db_directory",
+		Use:   "backup-push {db_directory | --logical --database <name>}",
 		Short: backupPushShortDescription, // TODO : improve description
 		Args:  cobra.MaximumNArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			internal.ConfigureLimiters()
 
+			if logicalBackup {
+				pushLogicalBackup(args)
+				return
+			}
+
 			var dataDirectory string
 
 			if len(args) > 0 {
@@ -110,8 +117,25 @@ var (
 	deltaFromUserData     = ""
 	userDataRaw           = ""
 	withoutFilesMetadata  = false
+	logicalBackup         = false
+	logicalDatabase       = ""
 )
 
+func pushLogicalBackup(args []string) {
+	if len(args) > 0 {
+		tracelog.ErrorLogger.Fatalf("The data directory can't be used with --%s", logicalFlag)
+	}
+	if userDataRaw == "" {
+		userDataRaw = viper.GetString(internal.SentinelUserDataSetting)
+	}
+	userData, err := internal.UnmarshalSentinelUserData(userDataRaw)
+	tracelog.ErrorLogger.FatalfOnError("Failed to unmarshal the provided UserData: %s", err)
+
+	uploader, err := internal.ConfigureUploader()
+	tracelog.ErrorLogger.FatalOnError(err)
+	postgres.HandleLogicalBackupPush(uploader, logicalDatabase, permanent, userData)
+}
+
 func chooseTarBallComposer() postgres.TarBallComposerType {
 	tarBallComposerType := postgres.RegularComposer
 
@@ -159,4 +183,8 @@ func init() {
 		"", "Write the provided user data to the backup sentinel and metadata files.")
 	backupPushCmd.Flags().BoolVar(&withoutFilesMetadata, withoutFilesMetadataFlag,
 		false, "Do not track files metadata, significantly reducing memory usage")
+	backupPushCmd.Flags().BoolVar(&logicalBackup, logicalFlag,
+		false, "Make a logical backup of a single database with pg_dump")
+	backupPushCmd.Flags().StringVar(&logicalDatabase, databaseFlag,
+		"", "The database to dump for the logical backup")
 }
@@ -4,12 +4,13 @@ import (
 	"github.com/apecloud/dataprotection-wal-g/internal"
 	"github.com/apecloud/dataprotection-wal-g/internal/asm"
 	"github.com/apecloud/dataprotection-wal-g/internal/databases/postgres"
+	"github.com/apecloud/dataprotection-wal-g/internal/multistorage"
 	"github.com/apecloud/dataprotection-wal-g/utility"
 	"github.com/spf13/cobra"
 	"github.com/wal-g/tracelog"
 )
 
-const DaemonShortDescription = "Uploads a WAL file to storage"
+const DaemonShortDescription = "Uploads and fetches WAL files on requests through the socket"
 
 // daemonCmd represents the daemon archive command
 var daemonCmd = &cobra.Command{
@@ -17,6 +18,9 @@ var daemonCmd = &cobra.Command{
 	Short: DaemonShortDescription, // TODO : improve description
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		err := internal.ConfigureAndRunDefaultWebServer()
+		tracelog.ErrorLogger.FatalOnError(err)
+
 		baseUploader, err := internal.ConfigureUploader()
 		tracelog.ErrorLogger.FatalOnError(err)
 
@@ -39,7 +43,19 @@ var daemonCmd = &cobra.Command{
 			uploader.PGArchiveStatusManager = asm.NewNopASM()
 		}
 		uploader.ChangeDirectory(utility.WalPath)
-		postgres.HandleDaemon(uploader, args[0])
+
+		folder, err := internal.ConfigureFolder()
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		failover, err := internal.InitFailoverStorages()
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		folderReader, err := multistorage.NewStorageFolderReader(folder, failover)
+		tracelog.ErrorLogger.FatalOnError(err)
+
+		partialWal, err := postgres.ConfigurePartialWal()
+		tracelog.ErrorLogger.FatalOnError(err)
+		postgres.HandleDaemon(uploader, folderReader, args[0], partialWal)
 	},
 }
 
@@ -0,0 +1,73 @@
+package pg
+
+import (
+	"os"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/postgres"
+	"github.com/spf13/cobra"
+)
+
+const (
+	DaemonClientShortDescription         = "Sends requests to the running daemon"
+	DaemonClientCheckShortDescription    = "Checks that the daemon accepts requests"
+	DaemonClientWalPushShortDescription  = "Archives WAL files through the daemon"
+	DaemonClientWalFetchShortDescription = "Fetches a WAL file through the daemon"
+	DaemonClientStatusShortDescription   = "Prints the daemon status in JSON"
+
+	daemonSocketFlag        = "socket"
+	daemonSocketShorthand   = "s"
+	daemonSocketDescription = "Path to the daemon socket"
+)
+
+var daemonSocketPath string
+
+// daemonClientCmd represents the daemon-client command
+var daemonClientCmd = &cobra.Command{
+	Use:   "daemon-client",
+	Short: DaemonClientShortDescription,
+}
+
+var daemonClientCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: DaemonClientCheckShortDescription,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		postgres.HandleDaemonClientCheck(daemonSocketPath)
+	},
+}
+
+var daemonClientWalPushCmd = &cobra.Command{
+	Use:   "wal-push wal_filepath...",
+	Short: DaemonClientWalPushShortDescription,
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		postgres.HandleDaemonClientWALPush(daemonSocketPath, args)
+	},
+}
+
+var daemonClientWalFetchCmd = &cobra.Command{
+	Use:   "wal-fetch wal_name destination_filename",
+	Short: DaemonClientWalFetchShortDescription,
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		postgres.HandleDaemonClientWALFetch(daemonSocketPath, args[0], args[1])
+	},
+}
+
+var daemonClientStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: DaemonClientStatusShortDescription,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		postgres.HandleDaemonClientStatus(daemonSocketPath, os.Stdout)
+	},
+}
+
+func init() {
+	daemonClientCmd.PersistentFlags().StringVarP(&daemonSocketPath, daemonSocketFlag, daemonSocketShorthand,
+		"", daemonSocketDescription)
+	_ = daemonClientCmd.MarkPersistentFlagRequired(daemonSocketFlag)
+
+	daemonClientCmd.AddCommand(daemonClientCheckCmd, daemonClientWalPushCmd, daemonClientWalFetchCmd, daemonClientStatusCmd)
+	Cmd.AddCommand(daemonClientCmd)
+}
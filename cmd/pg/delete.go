@@ -3,6 +3,7 @@ package pg
 import (
 	"github.com/apecloud/dataprotection-wal-g/internal"
 	"github.com/apecloud/dataprotection-wal-g/internal/databases/postgres"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
 	"github.com/spf13/cobra"
 	"github.com/wal-g/tracelog"
 )
@@ -13,10 +14,15 @@ const DeleteGarbageExamples = `  garbage           Deletes outdated WAL archives
   garbage ARCHIVES  Deletes only outdated WAL archives from storage
   garbage BACKUPS   Deletes only leftover backups files from storage`
 const DeleteGarbageUse = "garbage [ARCHIVES|BACKUPS]"
+const DeleteLogicalDescription = "Applies the deletion to the logical backups instead of the physical ones"
+const DeleteDatabaseDescription = "The database the logical backups are deleted of, " +
+	"required with --logical except for delete target and delete garbage"
 
 var confirmed = false
 var useSentinelTime = false
 var deleteTargetUserData = ""
+var deleteLogical = false
+var deleteDatabase = ""
 
 // deleteCmd represents the delete command
 var deleteCmd = &cobra.Command{
@@ -64,6 +70,13 @@ func runDeleteBefore(cmd *cobra.Command, args []string) {
 	folder, err := internal.ConfigureFolder()
 	tracelog.ErrorLogger.FatalOnError(err)
 
+	if deleteLogical {
+		deleteHandler := newLogicalDeleteHandler(folder, requireDeleteDatabase())
+		deleteHandler.HandleDeleteBefore(args, confirmed)
+		return
+	}
+
+	checkNoDeleteDatabase()
 	permanentBackups, permanentWals := postgres.GetPermanentBackupsAndWals(folder)
 
 	deleteHandler, err := postgres.NewDeleteHandler(folder, permanentBackups, permanentWals, useSentinelTime)
@@ -76,6 +89,13 @@ func runDeleteRetain(cmd *cobra.Command, args []string) {
 	folder, err := internal.ConfigureFolder()
 	tracelog.ErrorLogger.FatalOnError(err)
 
+	if deleteLogical {
+		deleteHandler := newLogicalDeleteHandler(folder, requireDeleteDatabase())
+		deleteHandler.HandleDeleteRetain(args, confirmed)
+		return
+	}
+
+	checkNoDeleteDatabase()
 	permanentBackups, permanentWals := postgres.GetPermanentBackupsAndWals(folder)
 
 	deleteHandler, err := postgres.NewDeleteHandler(folder, permanentBackups, permanentWals, useSentinelTime)
@@ -88,6 +108,14 @@ func runDeleteEverything(cmd *cobra.Command, args []string) {
 	folder, err := internal.ConfigureFolder()
 	tracelog.ErrorLogger.FatalOnError(err)
 
+	if deleteLogical {
+		database := requireDeleteDatabase()
+		deleteHandler := newLogicalDeleteHandler(folder, database)
+		deleteHandler.HandleDeleteEverything(args, postgres.GetPermanentLogicalBackups(folder, database), confirmed)
+		return
+	}
+
+	checkNoDeleteDatabase()
 	permanentBackups, permanentWals := postgres.GetPermanentBackupsAndWals(folder)
 
 	deleteHandler, err := postgres.NewDeleteHandler(folder, permanentBackups, permanentWals, useSentinelTime)
@@ -100,8 +128,6 @@ func runDeleteTarget(cmd *cobra.Command, args []string) {
 	folder, err := internal.ConfigureFolder()
 	tracelog.ErrorLogger.FatalOnError(err)
 
-	permanentBackups, permanentWals := postgres.GetPermanentBackupsAndWals(folder)
-
 	findFullBackup := false
 	modifier := internal.ExtractDeleteTargetModifierFromArgs(args)
 	if modifier == internal.FindFullDeleteModifier {
@@ -110,11 +136,24 @@ func runDeleteTarget(cmd *cobra.Command, args []string) {
 		args = args[1:]
 	}
 
-	deleteHandler, err := postgres.NewDeleteHandler(folder, permanentBackups, permanentWals, useSentinelTime)
-	tracelog.ErrorLogger.FatalOnError(err)
+	if deleteLogical {
+		targetBackupSelector, err := internal.CreateTargetDeleteBackupSelector(cmd, args, deleteTargetUserData,
+			postgres.NewLogicalMetaFetcher())
+		tracelog.ErrorLogger.FatalOnError(err)
+		deleteHandler := newLogicalDeleteHandler(folder, deleteDatabase)
+		deleteHandler.HandleDeleteTarget(targetBackupSelector, confirmed, findFullBackup)
+		return
+	}
+	checkNoDeleteDatabase()
+
 	targetBackupSelector, err := internal.CreateTargetDeleteBackupSelector(cmd, args, deleteTargetUserData, postgres.NewGenericMetaFetcher())
 	tracelog.ErrorLogger.FatalOnError(err)
 
+	permanentBackups, permanentWals := postgres.GetPermanentBackupsAndWals(folder)
+
+	deleteHandler, err := postgres.NewDeleteHandler(folder, permanentBackups, permanentWals, useSentinelTime)
+	tracelog.ErrorLogger.FatalOnError(err)
+
 	deleteHandler.HandleDeleteTarget(targetBackupSelector, confirmed, findFullBackup)
 }
 
@@ -122,6 +161,16 @@ func runDeleteGarbage(cmd *cobra.Command, args []string) {
 	folder, err := internal.ConfigureFolder()
 	tracelog.ErrorLogger.FatalOnError(err)
 
+	if deleteLogical {
+		if len(args) == 1 && args[0] == postgres.DeleteGarbageArchivesModifier {
+			tracelog.ErrorLogger.Fatal("The logical backups have no WAL archives")
+		}
+		err = postgres.HandleLogicalDeleteGarbage(folder, confirmed)
+		tracelog.ErrorLogger.FatalOnError(err)
+		return
+	}
+
+	checkNoDeleteDatabase()
 	permanentBackups, permanentWals := postgres.GetPermanentBackupsAndWals(folder)
 
 	deleteHandler, err := postgres.NewDeleteHandler(folder, permanentBackups, permanentWals, false)
@@ -131,6 +180,28 @@ func runDeleteGarbage(cmd *cobra.Command, args []string) {
 	tracelog.ErrorLogger.FatalOnError(err)
 }
 
+func newLogicalDeleteHandler(folder storage.Folder, database string) *internal.DeleteHandler {
+	deleteHandler, err := postgres.NewLogicalDeleteHandler(folder, database,
+		postgres.GetPermanentLogicalBackups(folder, database))
+	tracelog.ErrorLogger.FatalOnError(err)
+	return deleteHandler
+}
+
+// requireDeleteDatabase returns the database the logical backups are deleted of,
+// the retention is applied to the backups of each database separately
+func requireDeleteDatabase() string {
+	if deleteDatabase == "" {
+		tracelog.ErrorLogger.Fatalf("--%s is required with --%s", databaseFlag, logicalFlag)
+	}
+	return deleteDatabase
+}
+
+func checkNoDeleteDatabase() {
+	if deleteDatabase != "" {
+		tracelog.ErrorLogger.Fatalf("--%s can be used with --%s only", databaseFlag, logicalFlag)
+	}
+}
+
 func DeleteGarbageArgsValidator(cmd *cobra.Command, args []string) error {
 	modifiers := []string{postgres.DeleteGarbageArchivesModifier, postgres.DeleteGarbageBackupsModifier}
 	return internal.DeleteArgsValidator(args, modifiers, 0, 1)
@@ -145,4 +216,6 @@ func init() {
 	deleteCmd.AddCommand(deleteRetainCmd, deleteBeforeCmd, deleteEverythingCmd, deleteTargetCmd, deleteGarbageCmd)
 	deleteCmd.PersistentFlags().BoolVar(&confirmed, internal.ConfirmFlag, false, "Confirms backup deletion")
 	deleteCmd.PersistentFlags().BoolVar(&useSentinelTime, UseSentinelTimeFlag, false, UseSentinelTimeDescription)
+	deleteCmd.PersistentFlags().BoolVar(&deleteLogical, logicalFlag, false, DeleteLogicalDescription)
+	deleteCmd.PersistentFlags().StringVar(&deleteDatabase, databaseFlag, "", DeleteDatabaseDescription)
 }
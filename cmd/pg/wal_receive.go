@@ -4,6 +4,7 @@ import (
 	"github.com/apecloud/dataprotection-wal-g/internal"
 	"github.com/apecloud/dataprotection-wal-g/internal/asm"
 	"github.com/apecloud/dataprotection-wal-g/internal/databases/postgres"
+	"github.com/apecloud/dataprotection-wal-g/internal/webserver"
 	"github.com/spf13/cobra"
 	"github.com/wal-g/tracelog"
 )
@@ -16,6 +17,9 @@ var walReceiveCmd = &cobra.Command{
 	Short: walReceiveShortDescription,
 	Args:  cobra.ExactArgs(0),
 	Run: func(cmd *cobra.Command, args []string) {
+		err := internal.ConfigureAndRunDefaultWebServer()
+		tracelog.ErrorLogger.FatalOnError(err)
+
 		baseUploader, err := internal.ConfigureUploader()
 		tracelog.ErrorLogger.FatalOnError(err)
 
@@ -29,7 +33,19 @@ var walReceiveCmd = &cobra.Command{
 			tracelog.ErrorLogger.PrintError(err)
 			uploader.ArchiveStatusManager = asm.NewNopASM()
 		}
-		postgres.HandleWALReceive(uploader)
+
+		synchronous, err := internal.GetBoolSettingDefault(internal.PgWalReceiveSynchronous, false)
+		tracelog.ErrorLogger.FatalOnError(err)
+		partialWal, err := postgres.ConfigurePartialWal()
+		tracelog.ErrorLogger.FatalOnError(err)
+		status := postgres.NewWalReceiveStatus(synchronous, partialWal)
+		exposeHTTP, err := internal.GetBoolSettingDefault(internal.PgWalReceiveExposeHTTP, false)
+		tracelog.ErrorLogger.FatalOnError(err)
+		if exposeHTTP {
+			status.EnableHTTPHandler(postgres.WalReceiveStatsPath, webserver.DefaultWebServer)
+		}
+
+		postgres.HandleWALReceive(uploader, status)
 	},
 }
 
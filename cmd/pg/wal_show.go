@@ -21,6 +21,12 @@ const (
 
 	disableBackupsLookupFlag        = "without-backups"
 	disableBackupsLookupDescription = "Disable backups lookup for each timeline."
+
+	timelineGraphFlag        = "timeline-graph"
+	timelineGraphDescription = "Show the timeline branching tree built from the .history files " +
+		"with the fork LSNs, WAL gaps and backups on each timeline. Output format: json (default) or dot."
+	timelineGraphJSONFormat = "json"
+	timelineGraphDotFormat  = "dot"
 )
 
 var (
@@ -33,6 +39,20 @@ var (
 		Run: func(cmd *cobra.Command, args []string) {
 			folder, err := internal.ConfigureFolder()
 			tracelog.ErrorLogger.FatalOnError(err)
+			if cmd.Flags().Changed(timelineGraphFlag) {
+				outputType := postgres.JSONOutput
+				switch timelineGraphFormat {
+				case timelineGraphJSONFormat:
+				case timelineGraphDotFormat:
+					outputType = postgres.DotOutput
+				default:
+					tracelog.ErrorLogger.Fatalf("Unknown timeline graph format %q, expected %s or %s\n",
+						timelineGraphFormat, timelineGraphJSONFormat, timelineGraphDotFormat)
+				}
+				outputWriter := postgres.NewTimelineGraphOutputWriter(outputType, os.Stdout)
+				postgres.HandleWalShowTimelineGraph(folder, !disableBackupsLookup, outputWriter)
+				return
+			}
 			outputType := postgres.TableOutput
 			if detailedJSONOutput {
 				outputType = postgres.JSONOutput
@@ -43,10 +63,13 @@ var (
 	}
 	detailedJSONOutput   bool
 	disableBackupsLookup bool
+	timelineGraphFormat  string
 )
 
 func init() {
 	Cmd.AddCommand(walShowCmd)
 	walShowCmd.Flags().BoolVar(&detailedJSONOutput, detailedOutputFlag, false, detailedOutputDescription)
 	walShowCmd.Flags().BoolVar(&disableBackupsLookup, disableBackupsLookupFlag, false, disableBackupsLookupDescription)
+	walShowCmd.Flags().StringVar(&timelineGraphFormat, timelineGraphFlag, timelineGraphJSONFormat, timelineGraphDescription)
+	walShowCmd.Flags().Lookup(timelineGraphFlag).NoOptDefVal = timelineGraphJSONFormat
 }
@@ -50,3 +50,11 @@ else
   echo "Error in WAL-G response."
   exit 1
 fi
+
+WAL=$(ls -l ${PGDATA}/pg_wal | head -n3 | tail -n1 | egrep -o "[0-9A-F]{24}")
+wal-g --config=${TMP_CONFIG} daemon-client --socket ${SOCKET} check
+wal-g --config=${TMP_CONFIG} daemon-client --socket ${SOCKET} wal-push ${PGDATA}/pg_wal/${WAL}
+wal-g --config=${TMP_CONFIG} daemon-client --socket ${SOCKET} wal-fetch ${WAL} /tmp/daemon-fetched-wal
+cmp /tmp/daemon-fetched-wal ${PGDATA}/pg_wal/${WAL}
+wal-g --config=${TMP_CONFIG} daemon-client --socket ${SOCKET} status | grep -q "\"last_archived_wal\":\"${WAL}\""
+echo "WAL-G daemon client is working"
@@ -0,0 +1,33 @@
+package internal
+
+import "sync"
+
+// AdaptiveCompressions collects the algorithms chosen by the adaptive compression for every tar or stream of the backup
+type AdaptiveCompressions struct {
+	mutex      sync.Mutex
+	algorithms map[string]string
+}
+
+func NewAdaptiveCompressions() *AdaptiveCompressions {
+	return &AdaptiveCompressions{algorithms: make(map[string]string)}
+}
+
+func (compressions *AdaptiveCompressions) Add(name string, algorithm string) {
+	compressions.mutex.Lock()
+	defer compressions.mutex.Unlock()
+	compressions.algorithms[name] = algorithm
+}
+
+// Get returns nil if no algorithm was chosen adaptively
+func (compressions *AdaptiveCompressions) Get() map[string]string {
+	compressions.mutex.Lock()
+	defer compressions.mutex.Unlock()
+	if len(compressions.algorithms) == 0 {
+		return nil
+	}
+	result := make(map[string]string, len(compressions.algorithms))
+	for name, algorithm := range compressions.algorithms {
+		result[name] = algorithm
+	}
+	return result
+}
@@ -107,6 +107,28 @@ func UploadDto(folder storage.Folder, dto interface{}, path string) error {
 	return folder.PutObject(path, r)
 }
 
+// UploadBackupDto serializes given object to JSON and puts it to path relative to the uploader folder.
+// Unlike UploadDto, the object gets the metadata and the lock of the uploader as the rest of the backup objects.
+func UploadBackupDto(uploader Uploader, dto interface{}, path string) error {
+	marshaller, err := NewDtoSerializer()
+	if err != nil {
+		return err
+	}
+	r, err := marshaller.Marshal(dto)
+	if err != nil {
+		return err
+	}
+	if regularUploader := getRegularUploader(uploader); regularUploader != nil {
+		content, recordObject := regularUploader.trackObject(path, r)
+		if err = regularUploader.putObject(path, content); err != nil {
+			return err
+		}
+		recordObject()
+		return nil
+	}
+	return uploader.Folder().PutObject(path, r)
+}
+
 func (backup *Backup) CheckExistence() (bool, error) {
 	exists, err := backup.SentinelExists()
 	if err != nil {
@@ -151,10 +173,17 @@ func GetBackupByName(backupName, subfolder string, folder storage.Folder) (Backu
 	return backup, nil
 }
 
-// TODO : unit tests
+// UploadSentinel uploads the data key manifest and the integrity manifest if they are enabled,
+// then the sentinel completing the backup
 func UploadSentinel(uploader Uploader, sentinelDto interface{}, backupName string) error {
+	if err := uploadDataKeyManifest(uploader, backupName); err != nil {
+		return errors.Wrap(err, "failed to upload the data key manifest")
+	}
+	if err := uploadBackupManifest(uploader, backupName); err != nil {
+		return errors.Wrap(err, "failed to upload the integrity manifest")
+	}
 	sentinelName := SentinelNameFromBackup(backupName)
-	return UploadDto(uploader.Folder(), sentinelDto, sentinelName)
+	return UploadBackupDto(uploader, sentinelDto, sentinelName)
 }
 
 type ErrWaiter interface {
@@ -13,10 +13,12 @@ type BackupFileDescription struct {
 	MTime         time.Time
 	CorruptBlocks *CorruptBlocksInfo `json:",omitempty"`
 	UpdatesCount  uint64
+	// Size is the size of the file in the data directory, the older backups do not store it
+	Size int64 `json:",omitempty"`
 }
 
 func NewBackupFileDescription(isIncremented, isSkipped bool, modTime time.Time) *BackupFileDescription {
-	return &BackupFileDescription{isIncremented, isSkipped, modTime, nil, 0}
+	return &BackupFileDescription{IsIncremented: isIncremented, IsSkipped: isSkipped, MTime: modTime}
 }
 
 type CorruptBlocksInfo struct {
@@ -0,0 +1,190 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/integrity"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+// isBackupManifestEnabled checks if the manifest signing key is configured
+func isBackupManifestEnabled() bool {
+	for _, setting := range []string{ManifestEd25519KeyPathSetting, ManifestHMACKeySetting} {
+		if value, ok := GetSetting(setting); ok && value != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ConfigureManifestSigner returns the key to sign the backup manifests or nil if it is not configured
+func ConfigureManifestSigner() (integrity.Signer, error) {
+	if keyPath, ok := GetSetting(ManifestEd25519KeyPathSetting); ok && keyPath != "" {
+		pemBytes, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the manifest signing key")
+		}
+		privateKey, err := integrity.ParseEd25519PrivateKey(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		return integrity.Ed25519Signer{PrivateKey: privateKey}, nil
+	}
+	if key, ok := GetSetting(ManifestHMACKeySetting); ok && key != "" {
+		return integrity.HMACSigner{Key: []byte(key)}, nil
+	}
+	return nil, nil
+}
+
+// ConfigureManifestVerifier returns the key to verify the backup manifests.
+// The ed25519 public key is enough, so the hosts which only verify the backups don't need the private key.
+func ConfigureManifestVerifier() (integrity.Verifier, error) {
+	if keyPath, ok := GetSetting(ManifestEd25519PubPathSetting); ok && keyPath != "" {
+		pemBytes, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the manifest public key")
+		}
+		publicKey, err := integrity.ParseEd25519PublicKey(pemBytes)
+		if err != nil {
+			return nil, err
+		}
+		return integrity.Ed25519Verifier{PublicKey: publicKey}, nil
+	}
+	signer, err := ConfigureManifestSigner()
+	if err != nil {
+		return nil, err
+	}
+	if verifier, ok := signer.(integrity.Verifier); ok {
+		return verifier, nil
+	}
+	return nil, fmt.Errorf("none of %s, %s or %s is set to verify the backup manifest",
+		ManifestEd25519PubPathSetting, ManifestEd25519KeyPathSetting, ManifestHMACKeySetting)
+}
+
+// isManifestObject checks if the uploaded object should be listed in the backup manifest.
+// The sentinels and the backup metadata are excluded since backup-mark rewrites them,
+// as well as the manifest itself and the sentinel of backup-verify written after it.
+func isManifestObject(objectPath string) bool {
+	objectKind, backupName := classifyObjectPath(objectPath)
+	return objectKind == ObjectKindBackup && backupName != "" && !isManifestExcluded(path.Base(objectPath))
+}
+
+func getIntegrityRecorder(uploader Uploader) *integrity.Recorder {
+	if regularUploader := getRegularUploader(uploader); regularUploader != nil {
+		return regularUploader.IntegrityRecorder
+	}
+	return nil
+}
+
+// uploadBackupManifest signs and uploads the manifest of the objects uploaded for the backup.
+// It does nothing unless the manifest signing key is configured.
+func uploadBackupManifest(uploader Uploader, backupName string) error {
+	recorder := getIntegrityRecorder(uploader)
+	if recorder == nil {
+		return nil
+	}
+	objects := recorder.Take(storage.AddDelimiterToPath(storage.JoinPath(uploader.Folder().GetPath(), backupName)))
+	if len(objects) == 0 {
+		// e.g. the Greenplum coordinator, the segments upload their own manifests
+		tracelog.DebugLogger.Printf("No objects were uploaded for %s, skipping the integrity manifest", backupName)
+		return nil
+	}
+
+	signer, err := ConfigureManifestSigner()
+	if err != nil {
+		return err
+	}
+	manifest := integrity.NewManifest(backupName, objects, utility.TimeNowCrossPlatformUTC())
+	if err = manifest.Sign(signer); err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Uploading the integrity manifest of %d objects", len(objects))
+	return UploadBackupDto(uploader, manifest, path.Join(backupName, integrity.ManifestFileName))
+}
+
+// BackupVerifyResult is the outcome of the backup verification against its manifest
+type BackupVerifyResult struct {
+	BackupName string
+	Checked    int
+	Problems   []integrity.Problem
+}
+
+// VerifyBackupIntegrity checks the manifest signature, then streams every object of the backup
+// and compares it with the manifest. Nothing is decrypted or restored.
+func VerifyBackupIntegrity(baseBackupFolder storage.Folder, backupName string,
+	verifier integrity.Verifier) (*BackupVerifyResult, error) {
+	backupFolder := baseBackupFolder.GetSubFolder(backupName)
+	var manifest integrity.Manifest
+	if err := FetchDto(backupFolder, &manifest, integrity.ManifestFileName); err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch the integrity manifest of %s", backupName)
+	}
+	if manifest.BackupName != backupName {
+		return nil, fmt.Errorf("the manifest belongs to %s, not %s", manifest.BackupName, backupName)
+	}
+	if err := manifest.Verify(verifier); err != nil {
+		return nil, errors.Wrapf(err, "the integrity manifest of %s is not authentic", backupName)
+	}
+
+	result := &BackupVerifyResult{BackupName: backupName}
+	ctx := context.Background()
+	expected := make(map[string]bool, len(manifest.Objects))
+	for _, entry := range manifest.Objects {
+		expected[entry.Path] = true
+		problem, err := integrity.VerifyObject(ctx, backupFolder, entry)
+		if err != nil {
+			return nil, err
+		}
+		result.Checked++
+		if problem != nil {
+			result.Problems = append(result.Problems, *problem)
+		}
+	}
+
+	err := storage.WalkFolder(ctx, backupFolder, func(object storage.Object) error {
+		name := object.GetName()
+		if !expected[name] && !isManifestExcluded(name) {
+			result.Problems = append(result.Problems, integrity.Problem{Path: name, Kind: integrity.ProblemUnexpected})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the objects of %s", backupName)
+	}
+	return result, nil
+}
+
+// isManifestExcluded checks if the object is written to the backup folder without being listed in the manifest
+func isManifestExcluded(name string) bool {
+	return name == integrity.ManifestFileName || name == VerificationSentinelName || path.Base(name) == utility.MetadataFileName
+}
+
+// HandleBackupVerify verifies the backup with the configured manifest key and reports the damaged objects
+func HandleBackupVerify(rootFolder storage.Folder, backupName string) error {
+	backup, err := GetBackupByName(backupName, utility.BaseBackupPath, rootFolder)
+	if err != nil {
+		return err
+	}
+	verifier, err := ConfigureManifestVerifier()
+	if err != nil {
+		return err
+	}
+	result, err := VerifyBackupIntegrity(backup.Folder, backup.Name, verifier)
+	if err != nil {
+		return err
+	}
+	for _, problem := range result.Problems {
+		tracelog.ErrorLogger.Println(problem.String())
+	}
+	if len(result.Problems) > 0 {
+		return fmt.Errorf("backup %s is damaged: %d problems found in %d objects",
+			result.BackupName, len(result.Problems), result.Checked)
+	}
+	tracelog.InfoLogger.Printf("Backup %s is intact: %d objects verified", result.BackupName, result.Checked)
+	return nil
+}
@@ -0,0 +1,81 @@
+package internal_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/integrity"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/testtools"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const manifestTestBackup = "stream_20240101T000000Z"
+
+func uploadManifestTestBackup(t *testing.T) storage.Folder {
+	viper.Set(internal.ManifestHMACKeySetting, "secret")
+	t.Cleanup(func() { viper.Set(internal.ManifestHMACKeySetting, "") })
+
+	rootFolder := testtools.MakeDefaultInMemoryStorageFolder()
+	baseBackupFolder := rootFolder.GetSubFolder(utility.BaseBackupPath)
+	uploader, err := internal.ConfigureUploaderToFolder(baseBackupFolder)
+	require.NoError(t, err)
+	require.NotNil(t, uploader.IntegrityRecorder)
+
+	for _, name := range []string{"part_0000.br", "part_0001.br", utility.MetadataFileName} {
+		content := strings.Repeat(name, 1000)
+		require.NoError(t, uploader.Upload(manifestTestBackup+"/"+name, strings.NewReader(content)))
+	}
+	require.NoError(t, internal.UploadSentinel(uploader, map[string]string{"a": "b"}, manifestTestBackup))
+	return baseBackupFolder
+}
+
+func verifyManifestTestBackup(t *testing.T, baseBackupFolder storage.Folder) []integrity.Problem {
+	result, err := internal.VerifyBackupIntegrity(baseBackupFolder, manifestTestBackup, integrity.HMACSigner{Key: []byte("secret")})
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Checked)
+	return result.Problems
+}
+
+func TestVerifyBackupIntegrity_Intact(t *testing.T) {
+	baseBackupFolder := uploadManifestTestBackup(t)
+	assert.Empty(t, verifyManifestTestBackup(t, baseBackupFolder))
+}
+
+func TestVerifyBackupIntegrity_ReportsDamagedObjects(t *testing.T) {
+	baseBackupFolder := uploadManifestTestBackup(t)
+	backupFolder := baseBackupFolder.GetSubFolder(manifestTestBackup)
+	require.NoError(t, backupFolder.DeleteObjects([]string{"part_0000.br"}))
+	require.NoError(t, backupFolder.PutObject("part_0001.br", strings.NewReader("truncated")))
+	require.NoError(t, backupFolder.PutObject("part_0002.br", &bytes.Buffer{}))
+	// the metadata is not covered by the manifest since backup-mark changes it
+	require.NoError(t, backupFolder.PutObject(utility.MetadataFileName, strings.NewReader("{}")))
+	require.NoError(t, backupFolder.PutObject(internal.VerificationSentinelName, strings.NewReader("{}")))
+
+	assert.Equal(t, []integrity.Problem{
+		{Path: "part_0000.br", Kind: integrity.ProblemMissing},
+		{Path: "part_0001.br", Kind: integrity.ProblemTruncated, Details: "9 of 12000 bytes"},
+		{Path: "part_0002.br", Kind: integrity.ProblemUnexpected},
+	}, verifyManifestTestBackup(t, baseBackupFolder))
+}
+
+func TestVerifyBackupIntegrity_ReportsTamperedObject(t *testing.T) {
+	baseBackupFolder := uploadManifestTestBackup(t)
+	tampered := strings.Repeat("PART_0000.br", 1000)
+	require.NoError(t, baseBackupFolder.PutObject(manifestTestBackup+"/part_0000.br", strings.NewReader(tampered)))
+
+	assert.Equal(t, []integrity.Problem{
+		{Path: "part_0000.br", Kind: integrity.ProblemTampered, Details: "sha256 mismatch"},
+	}, verifyManifestTestBackup(t, baseBackupFolder))
+}
+
+func TestVerifyBackupIntegrity_RejectsForgedManifest(t *testing.T) {
+	baseBackupFolder := uploadManifestTestBackup(t)
+	_, err := internal.VerifyBackupIntegrity(baseBackupFolder, manifestTestBackup, integrity.HMACSigner{Key: []byte("other")})
+	assert.ErrorIs(t, err, integrity.ErrInvalidSignature)
+}
@@ -1,6 +1,20 @@
 package internal
 
+import (
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/wal-g/tracelog"
+)
+
 func HandleBackupMark(uploader Uploader, backupName string, toPermanent bool, metaInteractor GenericMetaInteractor) {
 	markHandler := NewBackupMarkHandler(metaInteractor, uploader.Folder())
 	markHandler.MarkBackup(backupName, toPermanent)
 }
+
+// HandleBackupLockExtend extends the storage object lock of the backup by lockFor from now
+func HandleBackupLockExtend(uploader Uploader, backupName string, lockFor time.Duration) {
+	retainUntil := utility.TimeNowCrossPlatformUTC().Add(lockFor)
+	err := ExtendBackupLock(uploader.Folder().GetSubFolder(utility.BaseBackupPath), backupName, retainUntil)
+	tracelog.ErrorLogger.FatalfOnError("Failed to extend the backup lock: %v", err)
+}
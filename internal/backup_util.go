@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"path"
 	"sort"
@@ -206,42 +207,82 @@ func SplitPurgingBackups(backups []TimedBackup,
 	return purge, retain, nil
 }
 
-// DeleteGarbage purges given garbage keys
+// DeleteGarbage purges given garbage keys, the objects locked by the storage are skipped
 func DeleteGarbage(folder storage.Folder, garbage []string) error {
-	var keys []string
+	lockFilter := newDeleteLockFilter(folder)
+	keyCollector := newDataKeyCollector(folder)
 	for _, prefix := range garbage {
-		garbageObjects, err := storage.ListFolderRecursively(folder.GetSubFolder(prefix))
-		if err != nil {
+		keyCollector.addBackup(prefix)
+		if err := deleteFolderObjects(folder, prefix, lockFilter); err != nil {
 			return err
 		}
-		for _, obj := range garbageObjects {
-			keys = append(keys, path.Join(prefix, obj.GetName()))
-		}
 	}
-	tracelog.DebugLogger.Printf("Garbage keys will be deleted: %+v\n", keys)
-	return folder.DeleteObjects(keys)
+	lockFilter.report()
+	keyCollector.deleteUnusedKeys()
+	return nil
 }
 
-// DeleteBackups purges given backups files
+// DeleteBackups purges given backups files. The backups with the locked sentinel are kept as a whole,
+// the locked objects of the other backups are skipped.
 // TODO: extract BackupLayout abstraction and provide DataPath(), SentinelPath(), Exists() methods
 func DeleteBackups(folder storage.Folder, backups []string) error {
-	keys := make([]string, 0, len(backups)*2)
+	lockFilter := newDeleteLockFilter(folder)
+	keyCollector := newDataKeyCollector(folder)
 	for i := range backups {
 		backupName := backups[i]
-		keys = append(keys, SentinelNameFromBackup(backupName))
-
-		dataObjects, err := storage.ListFolderRecursively(folder.GetSubFolder(backupName))
-		if err != nil {
+		sentinelName := SentinelNameFromBackup(backupName)
+		if lockFilter.isLocked(sentinelName) {
+			tracelog.WarningLogger.Printf("Backup %s is locked by the storage, skipping it", backupName)
+			continue
+		}
+		keyCollector.addBackup(backupName)
+		tracelog.DebugLogger.Printf("Backup keys will be deleted: %+v\n", sentinelName)
+		if err := folder.DeleteObjects([]string{sentinelName}); err != nil {
 			return err
 		}
-		for _, obj := range dataObjects {
-			keys = append(keys, path.Join(backupName, obj.GetName()))
+		if err := deleteFolderObjects(folder, backupName, lockFilter); err != nil {
+			return err
 		}
 	}
+	lockFilter.report()
+	keyCollector.deleteUnusedKeys()
+	return nil
+}
 
-	tracelog.DebugLogger.Printf("Backup keys will be deleted: %+v\n", keys)
-	if err := folder.DeleteObjects(keys); err != nil {
+// newDeleteLockFilter returns nil unless delete should look for the locked objects
+func newDeleteLockFilter(folder storage.Folder) *lockedObjectsFilter {
+	if !isObjectLockCheckEnabled() {
+		return nil
+	}
+	return newLockedObjectsFilter(folder)
+}
+
+// deleteFolderObjects deletes all the objects under the prefix while walking it, so the huge
+// folders are never listed into memory at once. The locked objects are skipped.
+func deleteFolderObjects(folder storage.Folder, prefix string, lockFilter *lockedObjectsFilter) error {
+	keys := make([]string, 0, storage.DeleteObjectsBatchSize)
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		tracelog.DebugLogger.Printf("Keys will be deleted: %+v\n", keys)
+		err := folder.DeleteObjects(keys)
+		keys = keys[:0]
 		return err
 	}
-	return nil
+	err := storage.WalkFolder(context.Background(), folder.GetSubFolder(prefix), func(object storage.Object) error {
+		key := path.Join(prefix, object.GetName())
+		if lockFilter.isLocked(key) {
+			return nil
+		}
+		keys = append(keys, key)
+		if len(keys) >= storage.DeleteObjectsBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
 }
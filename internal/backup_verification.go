@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	VerificationSentinelName = "verification_sentinel.json"
+
+	VerificationResultSuccess = "success"
+	VerificationResultFailure = "failure"
+
+	// The environment of the restore and validation commands
+	VerifyScratchDirEnv = "WALG_VERIFY_SCRATCH_DIR"
+	VerifyLogsDirEnv    = "WALG_VERIFY_LOGS_DIR"
+	VerifyBackupNameEnv = "WALG_VERIFY_BACKUP_NAME"
+)
+
+// BackupVerification is the result of the last restore test of the backup.
+// It is stored in the verification sentinel next to the backup objects.
+type BackupVerification struct {
+	Result          string    `json:"result"`
+	Error           string    `json:"error,omitempty"`
+	StartTime       time.Time `json:"start_time"`
+	FinishTime      time.Time `json:"finish_time"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Hostname        string    `json:"hostname,omitempty"`
+	RestoreCommand  string    `json:"restore_command"`
+	ValidateCommand string    `json:"validate_command,omitempty"`
+}
+
+// String is the short form shown in backup-list --detail
+func (verification *BackupVerification) String() string {
+	if verification == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s %s (%s)", verification.Result, verification.FinishTime.Format(time.RFC3339),
+		time.Duration(verification.DurationSeconds*float64(time.Second)).Round(time.Second))
+}
+
+// FetchBackupVerification returns the result of the last restore test of the backup or nil if it was never tested
+func FetchBackupVerification(baseBackupFolder storage.Folder, backupName string) (*BackupVerification, error) {
+	var verification BackupVerification
+	err := FetchDto(baseBackupFolder.GetSubFolder(backupName), &verification, VerificationSentinelName)
+	var notFoundErr storage.ObjectNotFoundError
+	if errors.As(err, &notFoundErr) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch the verification sentinel of %s", backupName)
+	}
+	return &verification, nil
+}
+
+// RestoreCheckLogsFetcher downloads the logs (binlogs, oplog) needed to restore the backup into logsDir.
+// The logs are fetched until the end of the backup unless the until time is set.
+type RestoreCheckLogsFetcher func(ctx context.Context, rootFolder storage.Folder, backup Backup,
+	logsDir string, until time.Time) error
+
+// RestoreCheckConfig describes how the backup is restored and validated by backup-verify
+type RestoreCheckConfig struct {
+	RestoreCommand  string
+	ValidateCommand string
+	// ScratchDir is the parent of the temporary restore directory, the system temp dir is used if empty
+	ScratchDir  string
+	Until       time.Time
+	LogsFetcher RestoreCheckLogsFetcher
+}
+
+// HandleBackupRestoreCheck restores the backup with the user supplied command into the scratch directory,
+// runs the validation command and records the outcome in the verification sentinel of the backup.
+// The outcome is recorded even if the restore fails.
+func HandleBackupRestoreCheck(ctx context.Context, rootFolder storage.Folder, backupName string,
+	config RestoreCheckConfig) error {
+	backup, err := GetBackupByName(backupName, utility.BaseBackupPath, rootFolder)
+	if err != nil {
+		return err
+	}
+
+	verification := BackupVerification{
+		StartTime:       utility.TimeNowCrossPlatformUTC(),
+		RestoreCommand:  config.RestoreCommand,
+		ValidateCommand: config.ValidateCommand,
+	}
+	verification.Hostname, _ = os.Hostname()
+
+	checkErr := runRestoreCheck(ctx, rootFolder, backup, config)
+
+	verification.FinishTime = utility.TimeNowCrossPlatformUTC()
+	verification.DurationSeconds = verification.FinishTime.Sub(verification.StartTime).Seconds()
+	verification.Result = VerificationResultSuccess
+	if checkErr != nil {
+		verification.Result = VerificationResultFailure
+		verification.Error = checkErr.Error()
+	}
+	err = UploadDto(backup.Folder.GetSubFolder(backup.Name), verification, VerificationSentinelName)
+	if err != nil {
+		if checkErr != nil {
+			tracelog.ErrorLogger.Printf("Restore check of %s failed: %v", backup.Name, checkErr)
+		}
+		return errors.Wrapf(err, "failed to upload the verification sentinel of %s", backup.Name)
+	}
+	if checkErr != nil {
+		return errors.Wrapf(checkErr, "restore check of %s failed", backup.Name)
+	}
+	tracelog.InfoLogger.Printf("Backup %s was restored and validated in %s",
+		backup.Name, verification.FinishTime.Sub(verification.StartTime).Round(time.Second))
+	return nil
+}
+
+func runRestoreCheck(ctx context.Context, rootFolder storage.Folder, backup Backup, config RestoreCheckConfig) error {
+	scratchDir, err := os.MkdirTemp(config.ScratchDir, "walg-verify-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create the scratch directory")
+	}
+	defer func() {
+		if err := os.RemoveAll(scratchDir); err != nil {
+			tracelog.WarningLogger.Printf("Failed to remove the scratch directory %s: %v", scratchDir, err)
+		}
+	}()
+	dataDir := filepath.Join(scratchDir, "data")
+	logsDir := filepath.Join(scratchDir, "logs")
+	for _, dir := range []string{dataDir, logsDir} {
+		if err = os.Mkdir(dir, 0700); err != nil {
+			return err
+		}
+	}
+
+	if config.LogsFetcher != nil {
+		tracelog.InfoLogger.Printf("Fetching the logs of %s into %s", backup.Name, logsDir)
+		if err = config.LogsFetcher(ctx, rootFolder, backup, logsDir, config.Until); err != nil {
+			return errors.Wrap(err, "failed to fetch the logs")
+		}
+	}
+
+	env := append(os.Environ(),
+		fmt.Sprintf("%s=%s", VerifyScratchDirEnv, dataDir),
+		fmt.Sprintf("%s=%s", VerifyLogsDirEnv, logsDir),
+		fmt.Sprintf("%s=%s", VerifyBackupNameEnv, backup.Name))
+
+	restoreCmd := newShellCommand(ctx, config.RestoreCommand)
+	restoreCmd.Dir, restoreCmd.Env, restoreCmd.Stdout = dataDir, env, os.Stdout
+	tracelog.InfoLogger.Printf("Restoring %s into %s", backup.Name, dataDir)
+	if err = streamBackupToCommand(restoreCmd, backup); err != nil {
+		return errors.Wrap(err, "restore command failed")
+	}
+
+	if config.ValidateCommand == "" {
+		return nil
+	}
+	validateCmd := newShellCommand(ctx, config.ValidateCommand)
+	validateCmd.Dir, validateCmd.Env, validateCmd.Stdout = dataDir, env, os.Stdout
+	tracelog.InfoLogger.Printf("Validating the restored %s", backup.Name)
+	if err = validateCmd.Run(); err != nil {
+		return errors.Wrap(err, "validation command failed")
+	}
+	return nil
+}
+
+// streamBackupToCommand is StreamBackupToCommandStdin supporting the split and merged stream backups
+func streamBackupToCommand(cmd *exec.Cmd, backup Backup) error {
+	fetcher, err := GetBackupStreamFetcher(backup)
+	if err != nil {
+		return errors.Wrap(err, "failed to detect backup format")
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return err
+	}
+	fetchErr := fetcher(backup, stdin)
+	// make sure the command sees the end of the input even if the fetcher failed midway
+	_ = stdin.Close()
+	cmdErr := cmd.Wait()
+	if fetchErr != nil {
+		return errors.Wrap(fetchErr, "failed to download and decompress stream")
+	}
+	return cmdErr
+}
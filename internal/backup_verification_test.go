@@ -0,0 +1,80 @@
+package internal_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/testtools"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const restoreCheckTestContent = "restore check test backup"
+
+func pushRestoreCheckTestBackup(t *testing.T) (storage.Folder, string) {
+	rootFolder := testtools.MakeDefaultInMemoryStorageFolder()
+	uploader, err := internal.ConfigureUploaderToFolder(rootFolder.GetSubFolder(utility.BaseBackupPath))
+	require.NoError(t, err)
+	backupName, err := uploader.PushStream(strings.NewReader(restoreCheckTestContent))
+	require.NoError(t, err)
+	require.NoError(t, internal.UploadSentinel(uploader, map[string]string{"a": "b"}, backupName))
+	return rootFolder, backupName
+}
+
+func writeTestLogs(_ context.Context, _ storage.Folder, backup internal.Backup, logsDir string, _ time.Time) error {
+	return os.WriteFile(filepath.Join(logsDir, "log_0001"), []byte(backup.Name), 0600)
+}
+
+func TestHandleBackupRestoreCheck_Success(t *testing.T) {
+	rootFolder, backupName := pushRestoreCheckTestBackup(t)
+	baseBackupFolder := rootFolder.GetSubFolder(utility.BaseBackupPath)
+	verification, err := internal.FetchBackupVerification(baseBackupFolder, backupName)
+	require.NoError(t, err)
+	assert.Nil(t, verification)
+
+	config := internal.RestoreCheckConfig{
+		RestoreCommand: `cat > "$WALG_VERIFY_SCRATCH_DIR/restored"`,
+		ValidateCommand: `grep -q "` + restoreCheckTestContent + `" restored && ` +
+			`grep -q "$WALG_VERIFY_BACKUP_NAME" "$WALG_VERIFY_LOGS_DIR/log_0001"`,
+		ScratchDir:  t.TempDir(),
+		LogsFetcher: writeTestLogs,
+	}
+	require.NoError(t, internal.HandleBackupRestoreCheck(context.Background(), rootFolder, internal.LatestString, config))
+
+	verification, err = internal.FetchBackupVerification(baseBackupFolder, backupName)
+	require.NoError(t, err)
+	require.NotNil(t, verification)
+	assert.Equal(t, internal.VerificationResultSuccess, verification.Result)
+	assert.Empty(t, verification.Error)
+	assert.Equal(t, config.RestoreCommand, verification.RestoreCommand)
+	assert.False(t, verification.FinishTime.Before(verification.StartTime))
+
+	// the scratch directory is removed
+	entries, err := os.ReadDir(config.ScratchDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestHandleBackupRestoreCheck_RecordsFailure(t *testing.T) {
+	rootFolder, backupName := pushRestoreCheckTestBackup(t)
+	config := internal.RestoreCheckConfig{
+		RestoreCommand:  `cat > /dev/null`,
+		ValidateCommand: `exit 3`,
+		ScratchDir:      t.TempDir(),
+	}
+	err := internal.HandleBackupRestoreCheck(context.Background(), rootFolder, backupName, config)
+	assert.ErrorContains(t, err, "validation command failed")
+
+	verification, err := internal.FetchBackupVerification(rootFolder.GetSubFolder(utility.BaseBackupPath), backupName)
+	require.NoError(t, err)
+	require.NotNil(t, verification)
+	assert.Equal(t, internal.VerificationResultFailure, verification.Result)
+	assert.Contains(t, verification.Error, "exit status 3")
+}
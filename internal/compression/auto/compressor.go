@@ -0,0 +1,132 @@
+package auto
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/lz4"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/zstd"
+)
+
+const (
+	AlgorithmName = "auto"
+	FileExtension = "auto"
+
+	// AlgorithmNone is chosen for the data which is not compressible, e.g. already compressed or encrypted
+	AlgorithmNone = "none"
+
+	// SampleSize is the size of the beginning of the stream used to choose the algorithm
+	SampleSize = 1 << 20
+	// MinRatio is the compression ratio of zstd below which the data is stored uncompressed
+	MinRatio = 1.1
+	// Lz4MaxLoss is the share of the raw data the lz4 output may be larger than the zstd one to prefer the faster lz4
+	Lz4MaxLoss = 0.05
+)
+
+// Compressor chooses none, lz4 or zstd for every compressed stream by the ratio measured on its first SampleSize bytes.
+// The choice is made once per stream, i.e. per backup tar, WAL segment or stream backup, not for every file in a tar.
+// The chosen algorithm is written to the stream header, so the Decompressor doesn't need to know it in advance.
+type Compressor struct{}
+
+func (compressor Compressor) NewWriter(writer io.Writer) io.WriteCloser {
+	return &Writer{dst: writer}
+}
+
+func (compressor Compressor) FileExtension() string {
+	return FileExtension
+}
+
+// Writer buffers the sample, then writes the header and passes the data to the chosen compressor
+type Writer struct {
+	dst        io.Writer
+	sample     bytes.Buffer
+	compressor io.WriteCloser
+	algorithm  string
+}
+
+func (writer *Writer) Write(p []byte) (int, error) {
+	if writer.compressor != nil {
+		return writer.compressor.Write(p)
+	}
+	sampled := min(len(p), SampleSize-writer.sample.Len())
+	writer.sample.Write(p[:sampled])
+	if writer.sample.Len() < SampleSize {
+		return sampled, nil
+	}
+	// the sampled bytes are consumed even if the compressor fails to start
+	if err := writer.start(); err != nil {
+		return sampled, err
+	}
+	written, err := writer.compressor.Write(p[sampled:])
+	return sampled + written, err
+}
+
+func (writer *Writer) Close() error {
+	if writer.compressor == nil {
+		if err := writer.start(); err != nil {
+			return err
+		}
+	}
+	return writer.compressor.Close()
+}
+
+// Algorithm returns the chosen algorithm, it is empty until the sample is collected or the writer is closed
+func (writer *Writer) Algorithm() string {
+	return writer.algorithm
+}
+
+func (writer *Writer) start() error {
+	writer.algorithm = ChooseAlgorithm(writer.sample.Bytes())
+	_, err := writer.dst.Write(append([]byte(headerMagic), algorithmIDs[writer.algorithm]))
+	if err != nil {
+		return err
+	}
+	switch writer.algorithm {
+	case lz4.AlgorithmName:
+		writer.compressor = lz4.Compressor{}.NewWriter(writer.dst)
+	case zstd.AlgorithmName:
+		writer.compressor = zstd.Compressor{}.NewWriter(writer.dst)
+	default:
+		writer.compressor = nopWriteCloser{writer.dst}
+	}
+	_, err = writer.compressor.Write(writer.sample.Bytes())
+	writer.sample = bytes.Buffer{}
+	return err
+}
+
+// ChooseAlgorithm compresses the sample with lz4 and zstd and picks the best trade-off between speed and ratio
+func ChooseAlgorithm(sample []byte) string {
+	if len(sample) == 0 {
+		return AlgorithmNone
+	}
+	zstdSize := compressedSize(zstd.Compressor{Level: zstd.MinLevel}, sample)
+	if float64(len(sample)) < float64(zstdSize)*MinRatio {
+		return AlgorithmNone
+	}
+	lz4Size := compressedSize(lz4.Compressor{}, sample)
+	if float64(lz4Size-zstdSize) <= float64(len(sample))*Lz4MaxLoss {
+		return lz4.AlgorithmName
+	}
+	return zstd.AlgorithmName
+}
+
+type compressor interface {
+	NewWriter(writer io.Writer) io.WriteCloser
+}
+
+func compressedSize(compressor compressor, sample []byte) int {
+	var compressed bytes.Buffer
+	writer := compressor.NewWriter(&compressed)
+	// the writes to the bytes.Buffer never fail
+	_, _ = writer.Write(sample)
+	_ = writer.Close()
+	return compressed.Len()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
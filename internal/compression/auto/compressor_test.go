@@ -0,0 +1,89 @@
+package auto
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"testing"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/lz4"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressDecompress(t *testing.T) {
+	randomData := make([]byte, 2*SampleSize)
+	rand.New(rand.NewSource(0x1337c0de)).Read(randomData)
+	// the few symbols without repeated sequences are compressed well only by the entropy coding of zstd
+	lowEntropyData := make([]byte, SampleSize)
+	for i := range lowEntropyData {
+		lowEntropyData[i] = randomData[i] % 4
+	}
+
+	testcases := []struct {
+		name      string
+		input     []byte
+		algorithm string
+	}{
+		{
+			name:      "empty input",
+			input:     []byte{},
+			algorithm: AlgorithmNone,
+		},
+		{
+			name:      "random data",
+			input:     randomData,
+			algorithm: AlgorithmNone,
+		},
+		{
+			name:      "low entropy data",
+			input:     lowEntropyData,
+			algorithm: zstd.AlgorithmName,
+		},
+		{
+			name:      "repeated text",
+			input:     bytes.Repeat([]byte("How much wood could a woodchuck chuck if a woodchuck could chuck wood ?"), 50000),
+			algorithm: lz4.AlgorithmName,
+		},
+	}
+
+	for _, tc := range testcases {
+		var comp bytes.Buffer
+		wc := Compressor{}.NewWriter(&comp)
+		_, err := wc.Write(tc.input)
+		require.NoError(t, err, tc.name)
+		require.NoError(t, wc.Close(), tc.name)
+		assert.Equal(t, tc.algorithm, wc.(*Writer).Algorithm(), tc.name)
+
+		rdr, err := Decompressor{}.Decompress(&comp)
+		require.NoError(t, err, tc.name)
+		decomp, err := io.ReadAll(rdr)
+		require.NoError(t, err, tc.name)
+		require.NoError(t, rdr.Close(), tc.name)
+		assert.Equal(t, tc.input, decomp, tc.name)
+	}
+}
+
+func TestDecompress_InvalidHeader(t *testing.T) {
+	_, err := Decompressor{}.Decompress(bytes.NewReader([]byte("not compressed")))
+	assert.Error(t, err)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestWrite_CountsSampleOnFailedStart(t *testing.T) {
+	wc := Compressor{}.NewWriter(failingWriter{})
+	n, err := wc.Write(make([]byte, SampleSize/2))
+	assert.NoError(t, err)
+	assert.Equal(t, SampleSize/2, n)
+
+	n, err = wc.Write(make([]byte, SampleSize))
+	assert.Error(t, err)
+	assert.Equal(t, SampleSize/2, n)
+}
@@ -0,0 +1,45 @@
+package auto
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/lz4"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/zstd"
+)
+
+// headerMagic precedes the ID of the algorithm chosen for the stream
+const headerMagic = "WGA"
+
+var algorithmIDs = map[string]byte{
+	AlgorithmNone:      0,
+	lz4.AlgorithmName:  1,
+	zstd.AlgorithmName: 2,
+}
+
+type Decompressor struct{}
+
+func (decompressor Decompressor) Decompress(src io.Reader) (io.ReadCloser, error) {
+	header := make([]byte, len(headerMagic)+1)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, fmt.Errorf("failed to read the adaptive compression header: %w", err)
+	}
+	if string(header[:len(headerMagic)]) != headerMagic {
+		return nil, fmt.Errorf("invalid adaptive compression header %q", header)
+	}
+
+	switch header[len(headerMagic)] {
+	case algorithmIDs[AlgorithmNone]:
+		return io.NopCloser(src), nil
+	case algorithmIDs[lz4.AlgorithmName]:
+		return lz4.Decompressor{}.Decompress(src)
+	case algorithmIDs[zstd.AlgorithmName]:
+		return zstd.Decompressor{}.Decompress(src)
+	default:
+		return nil, fmt.Errorf("unknown adaptive compression algorithm ID %d", header[len(headerMagic)])
+	}
+}
+
+func (decompressor Decompressor) FileExtension() string {
+	return FileExtension
+}
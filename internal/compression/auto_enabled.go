@@ -0,0 +1,11 @@
+package compression
+
+import (
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/auto"
+)
+
+func init() {
+	Decompressors = append(Decompressors, auto.Decompressor{})
+	Compressors[auto.AlgorithmName] = auto.Compressor{}
+	CompressingAlgorithms = append(CompressingAlgorithms, auto.AlgorithmName)
+}
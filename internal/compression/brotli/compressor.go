@@ -4,6 +4,7 @@
 package brotli
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/google/brotli/go/cbrotli"
@@ -14,10 +15,31 @@ const (
 	FileExtension = "br"
 )
 
-type Compressor struct{}
+const (
+	MinLevel     = 0
+	MaxLevel     = 11
+	defaultLevel = 3
+)
+
+// Compressor uses the quality 3 unless Level is set
+type Compressor struct {
+	Level *int
+}
+
+// NewCompressor creates the compressor with the brotli quality from 0 to 11
+func NewCompressor(level int) (Compressor, error) {
+	if level < MinLevel || level > MaxLevel {
+		return Compressor{}, fmt.Errorf("brotli compression level %d is out of range [%d, %d]", level, MinLevel, MaxLevel)
+	}
+	return Compressor{Level: &level}, nil
+}
 
 func (compressor Compressor) NewWriter(writer io.Writer) io.WriteCloser {
-	return cbrotli.NewWriter(writer, cbrotli.WriterOptions{Quality: 3})
+	quality := defaultLevel
+	if compressor.Level != nil {
+		quality = *compressor.Level
+	}
+	return cbrotli.NewWriter(writer, cbrotli.WriterOptions{Quality: quality})
 }
 
 func (compressor Compressor) FileExtension() string {
@@ -10,5 +10,6 @@ import "github.com/apecloud/dataprotection-wal-g/internal/compression/brotli"
 func init() {
 	Decompressors = append(Decompressors, brotli.Decompressor{})
 	Compressors[brotli.AlgorithmName] = brotli.Compressor{}
+	LeveledCompressors[brotli.AlgorithmName] = func(level int) (Compressor, error) { return brotli.NewCompressor(level) }
 	CompressingAlgorithms = append(CompressingAlgorithms, brotli.AlgorithmName)
 }
@@ -1,6 +1,7 @@
 package compression
 
 import (
+	"fmt"
 	"io"
 )
 
@@ -9,11 +10,28 @@ type Compressor interface {
 	FileExtension() string
 }
 
+// AdaptiveWriter is the compressing writer which chooses the algorithm by the written data, e.g. the auto compressor.
+// Algorithm returns the chosen algorithm once the writer is closed.
+type AdaptiveWriter interface {
+	io.WriteCloser
+	Algorithm() string
+}
+
 type Decompressor interface {
 	Decompress(src io.Reader) (io.ReadCloser, error)
 	FileExtension() string
 }
 
+// NewCompressor creates the compressor of the algorithm with the compression level.
+// The range of the levels is specific to the algorithm.
+func NewCompressor(algorithm string, level int) (Compressor, error) {
+	newCompressor, ok := LeveledCompressors[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("compression level is not supported by '%s'", algorithm)
+	}
+	return newCompressor(level)
+}
+
 func GetDecompressorByCompressor(compressor Compressor) Decompressor {
 	return FindDecompressor(compressor.FileExtension())
 }
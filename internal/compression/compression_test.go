@@ -62,3 +62,19 @@ func TestBigDataCompression(t *testing.T) {
 		testCompressor(compressor, testData, t)
 	}
 }
+
+func TestLeveledCompression(t *testing.T) {
+	const SmallDataSize = 16 << 10
+	randomReader := io.LimitReader(NewBiasedRandomReader(), SmallDataSize)
+	var testData bytes.Buffer
+	io.Copy(&testData, randomReader)
+	for compressingAlgorithm := range LeveledCompressors {
+		for _, level := range []int{1, 9} {
+			compressor, err := NewCompressor(compressingAlgorithm, level)
+			assert.NoError(t, err)
+			testCompressor(compressor, testData, t)
+		}
+		_, err := NewCompressor(compressingAlgorithm, 100)
+		assert.Error(t, err)
+	}
+}
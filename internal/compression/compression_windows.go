@@ -15,6 +15,11 @@ var Compressors = map[string]Compressor{
 	lzma.AlgorithmName: lzma.Compressor{},
 }
 
+var LeveledCompressors = map[string]func(level int) (Compressor, error){
+	lz4.AlgorithmName:  func(level int) (Compressor, error) { return lz4.NewCompressor(level) },
+	lzma.AlgorithmName: func(level int) (Compressor, error) { return lzma.NewCompressor(level) },
+}
+
 var Decompressors = []Decompressor{
 	lz4.Decompressor{},
 	lzma.Decompressor{},
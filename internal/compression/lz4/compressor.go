@@ -1,6 +1,7 @@
 package lz4
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/pierrec/lz4/v4"
@@ -11,10 +12,37 @@ const (
 	FileExtension = "lz4"
 )
 
-type Compressor struct{}
+const (
+	MinLevel = 0
+	MaxLevel = 9
+)
+
+var levels = []lz4.CompressionLevel{
+	lz4.Fast, lz4.Level1, lz4.Level2, lz4.Level3, lz4.Level4, lz4.Level5, lz4.Level6, lz4.Level7, lz4.Level8, lz4.Level9,
+}
+
+// Compressor uses the fast compression unless Level is set
+type Compressor struct {
+	Level int
+}
+
+// NewCompressor creates the compressor with the level from 0 (fast) to 9 (high compression)
+func NewCompressor(level int) (Compressor, error) {
+	if level < MinLevel || level > MaxLevel {
+		return Compressor{}, fmt.Errorf("lz4 compression level %d is out of range [%d, %d]", level, MinLevel, MaxLevel)
+	}
+	return Compressor{Level: level}, nil
+}
 
 func (compressor Compressor) NewWriter(writer io.Writer) io.WriteCloser {
-	return lz4.NewWriter(writer)
+	lz4Writer := lz4.NewWriter(writer)
+	if compressor.Level != 0 {
+		err := lz4Writer.Apply(lz4.CompressionLevelOption(levels[compressor.Level]))
+		if err != nil {
+			panic(err)
+		}
+	}
+	return lz4Writer
 }
 
 func (compressor Compressor) FileExtension() string {
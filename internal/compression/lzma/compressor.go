@@ -1,6 +1,7 @@
 package lzma
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/ulikunitz/xz/lzma"
@@ -11,10 +12,35 @@ const (
 	FileExtension = "lzma"
 )
 
-type Compressor struct{}
+const (
+	MinLevel = 0
+	MaxLevel = 9
+)
+
+// dictionaryCaps are the dictionary sizes of the xz presets 0-9
+var dictionaryCaps = []int{
+	256 << 10, 1 << 20, 2 << 20, 4 << 20, 4 << 20, 8 << 20, 8 << 20, 16 << 20, 32 << 20, 64 << 20,
+}
+
+// Compressor uses the 8 MiB dictionary unless Level is set
+type Compressor struct {
+	Level *int
+}
+
+// NewCompressor creates the compressor with the dictionary size of the xz preset from 0 to 9
+func NewCompressor(level int) (Compressor, error) {
+	if level < MinLevel || level > MaxLevel {
+		return Compressor{}, fmt.Errorf("lzma compression level %d is out of range [%d, %d]", level, MinLevel, MaxLevel)
+	}
+	return Compressor{Level: &level}, nil
+}
 
 func (compressor Compressor) NewWriter(writer io.Writer) io.WriteCloser {
-	lzmaWriter, err := lzma.NewWriter(writer)
+	config := lzma.WriterConfig{}
+	if compressor.Level != nil {
+		config.DictCap = dictionaryCaps[*compressor.Level]
+	}
+	lzmaWriter, err := config.NewWriter(writer)
 	if err != nil {
 		panic(err)
 	}
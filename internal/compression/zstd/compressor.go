@@ -1,6 +1,7 @@
 package zstd
 
 import (
+	"fmt"
 	"io"
 
 	"github.com/klauspost/compress/zstd"
@@ -9,12 +10,38 @@ import (
 const (
 	AlgorithmName = "zstd"
 	FileExtension = "zst"
+
+	MinLevel = 1
+	MaxLevel = 22
+
+	// LongWindowLog is the window size used by the zstd cli in the --long mode
+	LongWindowLog = 27
+	MinWindowLog  = 10
+	MaxWindowLog  = 29
 )
 
-type Compressor struct{}
+// Compressor compresses with the default level and window unless Level and WindowLog are set.
+// The Dictionary, if set, is referenced by its ID in every compressed frame.
+type Compressor struct {
+	Level      int
+	WindowLog  int
+	Dictionary []byte
+}
 
 func (compressor Compressor) NewWriter(writer io.Writer) io.WriteCloser {
-	zw, err := zstd.NewWriter(writer, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	level := zstd.SpeedDefault
+	if compressor.Level != 0 {
+		level = zstd.EncoderLevelFromZstd(compressor.Level)
+	}
+	options := []zstd.EOption{zstd.WithEncoderLevel(level)}
+	if compressor.WindowLog != 0 {
+		options = append(options, zstd.WithWindowSize(1<<compressor.WindowLog))
+	}
+	if compressor.Dictionary != nil {
+		options = append(options, zstd.WithEncoderDict(compressor.Dictionary))
+	}
+
+	zw, err := zstd.NewWriter(writer, options...)
 	if err != nil {
 		panic(err)
 	}
@@ -25,3 +52,43 @@ func (compressor Compressor) NewWriter(writer io.Writer) io.WriteCloser {
 func (compressor Compressor) FileExtension() string {
 	return FileExtension
 }
+
+// NewCompressor creates the compressor with the zstd level from 1 to 22.
+// The encoder has only four speeds, so the levels are mapped to them in buckets by zstd.EncoderLevelFromZstd:
+// 1-2 to the fastest, 3-5 to the default, 6-9 to the better and 10-22 to the best compression.
+// The levels of the same bucket compress identically.
+func NewCompressor(level int) (Compressor, error) {
+	if level < MinLevel || level > MaxLevel {
+		return Compressor{}, fmt.Errorf("zstd compression level %d is out of range [%d, %d]", level, MinLevel, MaxLevel)
+	}
+	return Compressor{Level: level}, nil
+}
+
+// WithWindowLog enables the long distance matching with the window of 2^windowLog bytes
+func (compressor Compressor) WithWindowLog(windowLog int) (Compressor, error) {
+	if windowLog < MinWindowLog || windowLog > MaxWindowLog {
+		return compressor, fmt.Errorf("zstd window log %d is out of range [%d, %d]",
+			windowLog, MinWindowLog, MaxWindowLog)
+	}
+	compressor.WindowLog = windowLog
+	return compressor, nil
+}
+
+// WithDictionary makes the compressor use the trained dictionary.
+// The dictionary is registered so the data compressed with it can be decompressed in the same process.
+func (compressor Compressor) WithDictionary(dictionary []byte) (Compressor, error) {
+	if _, err := RegisterDictionary(dictionary); err != nil {
+		return compressor, err
+	}
+	compressor.Dictionary = dictionary
+	return compressor, nil
+}
+
+// DictionaryID returns the ID of the dictionary or 0 if the compressor doesn't use one
+func (compressor Compressor) DictionaryID() uint32 {
+	if compressor.Dictionary == nil {
+		return 0
+	}
+	id, _ := DictionaryID(compressor.Dictionary)
+	return id
+}
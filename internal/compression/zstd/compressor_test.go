@@ -2,9 +2,11 @@ package zstd
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"math/rand"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
@@ -71,3 +73,70 @@ func TestCompressDecompress(t *testing.T) {
 		}
 	}
 }
+
+func compressDecompress(t *testing.T, compressor Compressor, in []byte) []byte {
+	var comp bytes.Buffer
+	wc := compressor.NewWriter(&comp)
+	_, err := wc.Write(in)
+	require.NoError(t, err)
+	require.NoError(t, wc.Close())
+
+	rdr, err := Decompressor{}.Decompress(&comp)
+	require.NoError(t, err)
+	defer rdr.Close()
+	decomp, err := io.ReadAll(rdr)
+	require.NoError(t, err)
+	return decomp
+}
+
+func TestCompressDecompress_LevelAndWindow(t *testing.T) {
+	in := bytes.Repeat([]byte("How much wood could a woodchuck chuck if a woodchuck could chuck wood ?"), 1000)
+
+	compressor, err := NewCompressor(19)
+	require.NoError(t, err)
+	compressor, err = compressor.WithWindowLog(LongWindowLog)
+	require.NoError(t, err)
+	assert.Equal(t, in, compressDecompress(t, compressor, in))
+
+	_, err = NewCompressor(0)
+	assert.Error(t, err)
+	_, err = Compressor{}.WithWindowLog(MaxWindowLog + 1)
+	assert.Error(t, err)
+}
+
+func TestCompressDecompress_Dictionary(t *testing.T) {
+	samples := make([][]byte, 0, 100)
+	for i := 0; i < 100; i++ {
+		var sample strings.Builder
+		for j := 0; j < 200; j++ {
+			fmt.Fprintf(&sample, "record %d of segment %d: the same header of every segment; ", j, i)
+		}
+		samples = append(samples, []byte(sample.String()))
+	}
+	dictionary, err := TrainDictionary(samples, 4096)
+	require.NoError(t, err)
+	id, err := DictionaryID(dictionary)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, id, uint32(MinDictionaryID))
+
+	compressor, err := Compressor{}.WithDictionary(dictionary)
+	require.NoError(t, err)
+	assert.Equal(t, id, compressor.DictionaryID())
+
+	in := []byte("record 42: the same header of every segment; record 43")
+	assert.Equal(t, in, compressDecompress(t, compressor, in))
+
+	// the dictionary unknown to the process is fetched with the loader
+	dictionariesMutex.Lock()
+	delete(dictionaries, id)
+	dictionariesMutex.Unlock()
+	loaded := false
+	SetDictionaryLoader(func(loadID uint32) ([]byte, error) {
+		assert.Equal(t, id, loadID)
+		loaded = true
+		return dictionary, nil
+	})
+	defer SetDictionaryLoader(nil)
+	assert.Equal(t, in, compressDecompress(t, Compressor{Dictionary: dictionary}, in))
+	assert.True(t, loaded)
+}
@@ -1,6 +1,7 @@
 package zstd
 
 import (
+	"bufio"
 	"io"
 
 	"github.com/apecloud/dataprotection-wal-g/internal/compression/computils"
@@ -10,7 +11,11 @@ import (
 type Decompressor struct{}
 
 func (decompressor Decompressor) Decompress(src io.Reader) (io.ReadCloser, error) {
-	zstdReader, err := zstd.NewReader(computils.NewUntilEOFReader(src))
+	reader := bufio.NewReader(computils.NewUntilEOFReader(src))
+	if err := loadFrameDictionary(reader); err != nil {
+		return nil, err
+	}
+	zstdReader, err := zstd.NewReader(reader, zstd.WithDecoderDicts(registeredDictionaries()...))
 	if err != nil {
 		return nil, err
 	}
@@ -20,3 +25,15 @@ func (decompressor Decompressor) Decompress(src io.Reader) (io.ReadCloser, error
 func (decompressor Decompressor) FileExtension() string {
 	return FileExtension
 }
+
+// loadFrameDictionary makes sure the dictionary referenced by the first frame is registered
+func loadFrameDictionary(reader *bufio.Reader) error {
+	// a short or broken header is reported by the decoder itself
+	headerBytes, _ := reader.Peek(zstd.HeaderMaxSize)
+	var header zstd.Header
+	if header.Decode(headerBytes) != nil || header.DictionaryID == 0 {
+		return nil
+	}
+	_, err := GetDictionary(header.DictionaryID)
+	return err
+}
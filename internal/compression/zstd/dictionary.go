@@ -0,0 +1,129 @@
+package zstd
+
+import (
+	"fmt"
+	"hash/crc32"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// MinDictionaryID is the lowest ID of the trained dictionaries, the lower IDs are reserved by the zstd format
+	MinDictionaryID = 1 << 15
+	// DefaultDictionarySize is the size of the dictionary trained by the zstd cli
+	DefaultDictionarySize = 112 << 10
+	minDictionarySize     = 8
+)
+
+// DictionaryLoader fetches the dictionary with the ID, e.g. from the storage.
+// It is called when the decompressor meets the frame compressed with an unregistered dictionary.
+type DictionaryLoader func(id uint32) ([]byte, error)
+
+var (
+	dictionariesMutex sync.RWMutex
+	dictionaries      = make(map[uint32][]byte)
+	dictionaryLoader  DictionaryLoader
+)
+
+func SetDictionaryLoader(loader DictionaryLoader) {
+	dictionariesMutex.Lock()
+	defer dictionariesMutex.Unlock()
+	dictionaryLoader = loader
+}
+
+// DictionaryID reads the ID from the dictionary header
+func DictionaryID(dictionary []byte) (uint32, error) {
+	info, err := zstd.InspectDictionary(dictionary)
+	if err != nil {
+		return 0, fmt.Errorf("invalid zstd dictionary: %w", err)
+	}
+	return info.ID(), nil
+}
+
+// RegisterDictionary makes the dictionary available to the decompressor without calling the DictionaryLoader
+func RegisterDictionary(dictionary []byte) (uint32, error) {
+	id, err := DictionaryID(dictionary)
+	if err != nil {
+		return 0, err
+	}
+	dictionariesMutex.Lock()
+	defer dictionariesMutex.Unlock()
+	dictionaries[id] = dictionary
+	return id, nil
+}
+
+// GetDictionary returns the registered dictionary or loads it with the DictionaryLoader
+func GetDictionary(id uint32) ([]byte, error) {
+	dictionariesMutex.RLock()
+	dictionary, ok := dictionaries[id]
+	loader := dictionaryLoader
+	dictionariesMutex.RUnlock()
+	if ok {
+		return dictionary, nil
+	}
+	if loader == nil {
+		return nil, fmt.Errorf("zstd dictionary %d is not registered", id)
+	}
+
+	dictionary, err := loader(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load zstd dictionary %d: %w", id, err)
+	}
+	loadedID, err := RegisterDictionary(dictionary)
+	if err != nil {
+		return nil, err
+	}
+	if loadedID != id {
+		return nil, fmt.Errorf("loaded zstd dictionary has ID %d instead of %d", loadedID, id)
+	}
+	return dictionary, nil
+}
+
+func registeredDictionaries() [][]byte {
+	dictionariesMutex.RLock()
+	defer dictionariesMutex.RUnlock()
+	result := make([][]byte, 0, len(dictionaries))
+	for _, dictionary := range dictionaries {
+		result = append(result, dictionary)
+	}
+	return result
+}
+
+// TrainDictionary builds the dictionary of the given size from the samples of the data to compress,
+// e.g. the WAL segments or binlogs. The ID is derived from the dictionary content.
+func TrainDictionary(samples [][]byte, dictionarySize int) (dictionary []byte, err error) {
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no samples to train zstd dictionary")
+	}
+	if dictionarySize <= 0 {
+		dictionarySize = DefaultDictionarySize
+	}
+
+	// take the same share from the beginning of every sample
+	chunkSize := dictionarySize / len(samples)
+	history := make([]byte, 0, dictionarySize)
+	for _, sample := range samples {
+		if len(sample) > chunkSize {
+			sample = sample[:chunkSize]
+		}
+		history = append(history, sample...)
+	}
+	if len(history) < minDictionarySize {
+		return nil, fmt.Errorf("samples are too small to train zstd dictionary: %d bytes", len(history))
+	}
+
+	// BuildDict divides by zero when the samples have too few sequences
+	defer func() {
+		if r := recover(); r != nil {
+			dictionary, err = nil, fmt.Errorf("samples are not enough to train zstd dictionary: %v", r)
+		}
+	}()
+	id := MinDictionaryID + crc32.ChecksumIEEE(history)%(1<<31-MinDictionaryID)
+	return zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       id,
+		Contents: samples,
+		History:  history,
+		Offsets:  [3]int{1, 4, 8},
+	})
+}
@@ -7,5 +7,6 @@ import (
 func init() {
 	Decompressors = append(Decompressors, zstd.Decompressor{})
 	Compressors[zstd.AlgorithmName] = zstd.Compressor{}
+	LeveledCompressors[zstd.AlgorithmName] = func(level int) (Compressor, error) { return zstd.NewCompressor(level) }
 	CompressingAlgorithms = append(CompressingAlgorithms, zstd.AlgorithmName)
 }
@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/compression"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/zstd"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+)
+
+func init() {
+	// the commands without an uploader, e.g. wal-fetch, load the unknown dictionaries from the default storage
+	zstd.SetDictionaryLoader(func(id uint32) ([]byte, error) {
+		folder, err := ConfigureFolder()
+		if err != nil {
+			return nil, err
+		}
+		return DownloadCompressionDictionary(folder, id)
+	})
+}
+
+func compressionDictionaryName(id uint32) string {
+	return fmt.Sprintf("zstd_%d.dict", id)
+}
+
+// UploadCompressionDictionary stores the dictionary in the storage root, encrypted with the configured crypter.
+// The dictionary outlives the backup which trained it, so the master key is used instead of the backup data key.
+func UploadCompressionDictionary(uploader Uploader, dictionary []byte) (uint32, error) {
+	id, err := zstd.DictionaryID(dictionary)
+	if err != nil {
+		return 0, err
+	}
+	uploader = uploader.Clone()
+	uploader.ChangeDirectory(utility.CompressionDictionaryPath)
+	content := CompressAndEncrypt(bytes.NewReader(dictionary), nil, ConfigureCrypterForSpecificConfig(viper.GetViper()))
+	err = uploader.Upload(compressionDictionaryName(id), content)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to upload compression dictionary %d", id)
+	}
+	return id, nil
+}
+
+// DownloadCompressionDictionary reads the dictionary with the ID from the storage root
+func DownloadCompressionDictionary(folder storage.Folder, id uint32) ([]byte, error) {
+	dictionaryFolder := folder.GetSubFolder(utility.CompressionDictionaryPath)
+	reader, err := dictionaryFolder.ReadObject(compressionDictionaryName(id))
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(reader, "")
+
+	decryptReader, err := DecryptBytes(reader)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(decryptReader)
+}
+
+// LoadCompressionDictionary makes sure the dictionary referenced by the backup sentinel is available
+// before the backup files are decompressed. Zero ID means the backup is compressed without a dictionary.
+func LoadCompressionDictionary(folder storage.Folder, id uint32) error {
+	if id == 0 {
+		return nil
+	}
+	tracelog.DebugLogger.Printf("Loading compression dictionary %d", id)
+	dictionary, err := DownloadCompressionDictionary(folder, id)
+	if err != nil {
+		return errors.Wrapf(err, "failed to load compression dictionary %d", id)
+	}
+	_, err = zstd.RegisterDictionary(dictionary)
+	return err
+}
+
+// ConfigureCompressionDictionaryLoader makes the zstd decompressor fetch the unknown dictionaries from the folder.
+// It is called by the uploader configuration, so the dictionaries are read from the storage the uploader writes to.
+func ConfigureCompressionDictionaryLoader(folder storage.Folder) {
+	zstd.SetDictionaryLoader(func(id uint32) ([]byte, error) {
+		return DownloadCompressionDictionary(folder, id)
+	})
+}
+
+// CompressionDictionaryID returns the ID of the dictionary used by the compressor or 0 if there is none
+func CompressionDictionaryID(compressor compression.Compressor) uint32 {
+	if zstdCompressor, ok := compressor.(zstd.Compressor); ok {
+		return zstdCompressor.DictionaryID()
+	}
+	return 0
+}
+
+func configureCompressionLevel(compressor compression.Compressor, method string) (compression.Compressor, error) {
+	levelStr, ok := GetSetting(CompressionLevelSetting)
+	if !ok || levelStr == "" {
+		return compressor, nil
+	}
+	level, err := strconv.Atoi(levelStr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", CompressionLevelSetting)
+	}
+	return compression.NewCompressor(method, level)
+}
+
+func configureZstdWindowLog(compressor compression.Compressor) (compression.Compressor, error) {
+	if !viper.IsSet(ZstdWindowLogSetting) || viper.GetString(ZstdWindowLogSetting) == "" {
+		return compressor, nil
+	}
+	zstdCompressor, ok := compressor.(zstd.Compressor)
+	if !ok {
+		tracelog.WarningLogger.Printf("%s is ignored since the compression method is not %s",
+			ZstdWindowLogSetting, zstd.AlgorithmName)
+		return compressor, nil
+	}
+	return zstdCompressor.WithWindowLog(viper.GetInt(ZstdWindowLogSetting))
+}
+
+func configureZstdDictionary(compressor compression.Compressor, folder storage.Folder) (compression.Compressor, error) {
+	idStr, ok := GetSetting(ZstdDictionarySetting)
+	if !ok || idStr == "" {
+		return compressor, nil
+	}
+	zstdCompressor, ok := compressor.(zstd.Compressor)
+	if !ok {
+		tracelog.WarningLogger.Printf("%s is ignored since the compression method is not %s",
+			ZstdDictionarySetting, zstd.AlgorithmName)
+		return compressor, nil
+	}
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s", ZstdDictionarySetting)
+	}
+	dictionary, err := DownloadCompressionDictionary(folder, uint32(id))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to download compression dictionary %d", id)
+	}
+	return zstdCompressor.WithDictionary(dictionary)
+}
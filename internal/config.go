@@ -9,6 +9,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/apecloud/dataprotection-wal-g/internal/crypto"
 	"github.com/apecloud/dataprotection-wal-g/internal/limiters"
 
 	"github.com/pkg/errors"
@@ -37,9 +38,23 @@ const (
 	SentinelUserDataSetting        = "WALG_SENTINEL_USER_DATA"
 	PreventWalOverwriteSetting     = "WALG_PREVENT_WAL_OVERWRITE"
 	UploadWalMetadata              = "WALG_UPLOAD_WAL_METADATA"
+	UploadObjectMetadataSetting    = "WALG_UPLOAD_OBJECT_METADATA"
+	RetentionClassSetting          = "WALG_RETENTION_CLASS"
+	ObjectLockModeSetting          = "WALG_OBJECT_LOCK_MODE"
+	ObjectLockRetentionSetting     = "WALG_OBJECT_LOCK_RETENTION"
+	ObjectLockLegalHoldSetting     = "WALG_OBJECT_LOCK_LEGAL_HOLD"
+	DeleteCheckObjectLockSetting   = "WALG_DELETE_CHECK_OBJECT_LOCK"
+	EnvelopeEncryptionSetting      = "WALG_ENVELOPE_ENCRYPTION"
+	EnvelopeKeyRotationSetting     = "WALG_ENVELOPE_KEY_ROTATION_PERIOD"
+	ManifestEd25519KeyPathSetting  = "WALG_MANIFEST_ED25519_KEY_PATH"
+	ManifestEd25519PubPathSetting  = "WALG_MANIFEST_ED25519_PUBLIC_KEY_PATH"
+	ManifestHMACKeySetting         = "WALG_MANIFEST_HMAC_KEY"
 	DeltaMaxStepsSetting           = "WALG_DELTA_MAX_STEPS"
 	DeltaOriginSetting             = "WALG_DELTA_ORIGIN"
 	CompressionMethodSetting       = "WALG_COMPRESSION_METHOD"
+	CompressionLevelSetting        = "WALG_COMPRESSION_LEVEL"
+	ZstdWindowLogSetting           = "WALG_ZSTD_WINDOW_LOG"
+	ZstdDictionarySetting          = "WALG_ZSTD_DICTIONARY"
 	StoragePrefixSetting           = "WALG_STORAGE_PREFIX"
 	DiskRateLimitSetting           = "WALG_DISK_RATE_LIMIT"
 	NetworkRateLimitSetting        = "WALG_NETWORK_RATE_LIMIT"
@@ -95,6 +110,20 @@ const (
 	PgFailoverStoragesCheckTimeout = "WALG_FAILOVER_STORAGES_CHECK_TIMEOUT"
 	PgFailoverStorageCacheLifetime = "WALG_FAILOVER_STORAGES_CACHE_LIFETIME"
 
+	PgWalReceiveSynchronous = "WALG_WAL_RECEIVE_SYNCHRONOUS"
+	PgWalReceiveExposeHTTP  = "WALG_WAL_RECEIVE_EXPOSE_HTTP"
+	PgPartialWalInterval    = "WALG_PARTIAL_WAL_INTERVAL"
+	PgPartialWalBytes       = "WALG_PARTIAL_WAL_BYTES"
+	PgPartialWalMinInterval = "WALG_PARTIAL_WAL_MIN_INTERVAL"
+	PgPartialWalFetch       = "WALG_PARTIAL_WAL_FETCH"
+	PgUseWalSummaries       = "WALG_USE_WAL_SUMMARIES"
+	PgBackupTablesMetadata  = "WALG_BACKUP_TABLES_METADATA"
+
+	PgStandbyArchiveWaitTimeout = "WALG_STANDBY_ARCHIVE_WAIT_TIMEOUT"
+	PgStandbyPrimaryConnInfo    = "WALG_STANDBY_PRIMARY_CONNINFO"
+
+	PgRemoteBackupServerCompression = "WALG_REMOTE_BACKUP_SERVER_COMPRESSION"
+
 	ProfileSamplingRatio = "PROFILE_SAMPLING_RATIO"
 	ProfileMode          = "PROFILE_MODE"
 	ProfilePath          = "PROFILE_PATH"
@@ -140,6 +169,14 @@ const (
 	GPSegmentStatesDir     = "WALG_GP_SEG_STATES_DIR"
 	GPDeleteConcurrency    = "WALG_GP_DELETE_CONCURRENCY"
 	GPAoSegSizeThreshold   = "WALG_GP_AOSEG_SIZE_THRESHOLD"
+	GPSegmentsTransport    = "WALG_GP_SEG_TRANSPORT"
+	GPSegmentAgentPort     = "WALG_GP_SEG_AGENT_PORT"
+	GPSegmentAgentSocket   = "WALG_GP_SEG_AGENT_SOCKET"
+	GPSegmentAgentToken    = "WALG_GP_SEG_AGENT_TOKEN"
+	GPSegmentAgentTLSCert  = "WALG_GP_SEG_AGENT_TLS_CERT"
+	GPSegmentAgentTLSKey   = "WALG_GP_SEG_AGENT_TLS_KEY"
+	GPSegmentAgentTLSCA    = "WALG_GP_SEG_AGENT_TLS_CA"
+	GPSegmentAgentDataDirs = "WALG_GP_SEG_AGENT_DATA_DIRS"
 
 	GoMaxProcs = "GOMAXPROCS"
 
@@ -165,6 +202,18 @@ const (
 	YcKmsKeyIDSetting  = "YC_CSE_KMS_KEY_ID"
 	YcSaKeyFileSetting = "YC_SERVICE_ACCOUNT_KEY_FILE"
 
+	VaultAddressSetting         = "WALG_VAULT_ADDR"
+	VaultNamespaceSetting       = "WALG_VAULT_NAMESPACE"
+	VaultCACertSetting          = "WALG_VAULT_CACERT"
+	VaultTransitMountSetting    = "WALG_VAULT_TRANSIT_MOUNT"
+	VaultTransitKeySetting      = "WALG_VAULT_TRANSIT_KEY"
+	VaultAuthMountSetting       = "WALG_VAULT_AUTH_MOUNT"
+	VaultTokenFileSetting       = "WALG_VAULT_TOKEN_FILE"
+	VaultRoleIDSetting          = "WALG_VAULT_ROLE_ID"
+	VaultSecretIDFileSetting    = "WALG_VAULT_SECRET_ID_FILE"
+	VaultKubernetesRoleSetting  = "WALG_VAULT_K8S_ROLE"
+	VaultKubernetesTokenSetting = "WALG_VAULT_K8S_TOKEN_FILE"
+
 	PgBackRestStanza = "PGBACKREST_STANZA"
 
 	AzureStorageAccount   = "AZURE_STORAGE_ACCOUNT"
@@ -193,6 +242,8 @@ const (
 var (
 	CfgFile             string
 	defaultConfigValues map[string]string
+	// the database type the settings were configured for, e.g. PG or MYSQL
+	configuredDatabaseType string
 
 	commonDefaultConfigValues = map[string]string{
 		DownloadConcurrencySetting:   "10",
@@ -201,6 +252,9 @@ var (
 		UploadQueueSetting:           "2",
 		PreventWalOverwriteSetting:   "false",
 		UploadWalMetadata:            "NOMETADATA",
+		UploadObjectMetadataSetting:  "false",
+		EnvelopeEncryptionSetting:    "false",
+		EnvelopeKeyRotationSetting:   "24h",
 		DeltaMaxStepsSetting:         "0",
 		CompressionMethodSetting:     "lz4",
 		UseWalDeltaSetting:           "false",
@@ -246,6 +300,7 @@ var (
 		PgBackRestStanza:               "main",
 		PgFailoverStoragesCheckTimeout: "30s",
 		PgFailoverStorageCacheLifetime: "15m",
+		PgPartialWalMinInterval:        "1s",
 	}
 
 	GPDefaultSettings = map[string]string{
@@ -257,6 +312,8 @@ var (
 		GPSegmentStatesDir:     "/tmp",
 		GPDeleteConcurrency:    "1",
 		GPAoSegSizeThreshold:   "1048576", // (1 << 20)
+		GPSegmentsTransport:    "ssh",
+		GPSegmentAgentPort:     "7433",
 	}
 
 	AllowedSettings map[string]bool
@@ -270,9 +327,20 @@ var (
 		SentinelUserDataSetting:      true,
 		PreventWalOverwriteSetting:   true,
 		UploadWalMetadata:            true,
+		UploadObjectMetadataSetting:  true,
+		RetentionClassSetting:        true,
+		ObjectLockModeSetting:        true,
+		ObjectLockRetentionSetting:   true,
+		ObjectLockLegalHoldSetting:   true,
+		DeleteCheckObjectLockSetting: true,
+		EnvelopeEncryptionSetting:    true,
+		EnvelopeKeyRotationSetting:   true,
 		DeltaMaxStepsSetting:         true,
 		DeltaOriginSetting:           true,
 		CompressionMethodSetting:     true,
+		CompressionLevelSetting:      true,
+		ZstdWindowLogSetting:         true,
+		ZstdDictionarySetting:        true,
 		StoragePrefixSetting:         true,
 		DiskRateLimitSetting:         true,
 		NetworkRateLimitSetting:      true,
@@ -310,6 +378,10 @@ var (
 		ProfileMode:          true,
 		ProfilePath:          true,
 
+		ManifestEd25519KeyPathSetting: true,
+		ManifestEd25519PubPathSetting: true,
+		ManifestHMACKeySetting:        true,
+
 		// Swift
 		"WALG_SWIFT_PREFIX": true,
 		SwiftOsAuthURL:      true,
@@ -368,6 +440,19 @@ var (
 		YcSaKeyFileSetting: true,
 		YcKmsKeyIDSetting:  true,
 
+		// Vault
+		VaultAddressSetting:         true,
+		VaultNamespaceSetting:       true,
+		VaultCACertSetting:          true,
+		VaultTransitMountSetting:    true,
+		VaultTransitKeySetting:      true,
+		VaultAuthMountSetting:       true,
+		VaultTokenFileSetting:       true,
+		VaultRoleIDSetting:          true,
+		VaultSecretIDFileSetting:    true,
+		VaultKubernetesRoleSetting:  true,
+		VaultKubernetesTokenSetting: true,
+
 		// SH
 		"WALG_SSH_PREFIX": true,
 		SSHPort:           true,
@@ -407,6 +492,20 @@ var (
 		PgFailoverStorages:             true,
 		PgFailoverStoragesCheckTimeout: true,
 		PgFailoverStorageCacheLifetime: true,
+
+		PgWalReceiveSynchronous: true,
+		PgWalReceiveExposeHTTP:  true,
+		PgPartialWalInterval:    true,
+		PgPartialWalBytes:       true,
+		PgPartialWalMinInterval: true,
+		PgPartialWalFetch:       true,
+		PgUseWalSummaries:       true,
+		PgBackupTablesMetadata:  true,
+
+		PgStandbyArchiveWaitTimeout: true,
+		PgStandbyPrimaryConnInfo:    true,
+
+		PgRemoteBackupServerCompression: true,
 	}
 
 	MongoAllowedSettings = map[string]bool{
@@ -472,6 +571,14 @@ var (
 		GPSegmentStatesDir:     true,
 		GPDeleteConcurrency:    true,
 		GPAoSegSizeThreshold:   true,
+		GPSegmentsTransport:    true,
+		GPSegmentAgentPort:     true,
+		GPSegmentAgentSocket:   true,
+		GPSegmentAgentToken:    true,
+		GPSegmentAgentTLSCert:  true,
+		GPSegmentAgentTLSKey:   true,
+		GPSegmentAgentTLSCA:    true,
+		GPSegmentAgentDataDirs: true,
 	}
 
 	RequiredSettings       = make(map[string]bool)
@@ -479,6 +586,7 @@ var (
 		HTTPExposePprof:          webserver.EnablePprofEndpoints,
 		HTTPExposeExpVar:         webserver.EnableExpVarEndpoints,
 		OplogPushStatsExposeHTTP: nil,
+		PgWalReceiveExposeHTTP:   nil,
 	}
 	Turbo bool
 
@@ -492,6 +600,7 @@ var (
 		AzureStorageSasToken:         true,
 		GoogleApplicationCredentials: true,
 		LibsodiumKeySetting:          true,
+		ManifestHMACKeySetting:       true,
 		PgPasswordSetting:            true,
 		PgpKeyPassphraseSetting:      true,
 		PgpKeySetting:                true,
@@ -513,6 +622,7 @@ func AddTurboFlag(cmd *cobra.Command) {
 
 // nolint: gocyclo
 func ConfigureSettings(currentType string) {
+	configuredDatabaseType = currentType
 	if len(defaultConfigValues) == 0 {
 		defaultConfigValues = commonDefaultConfigValues
 		dbSpecificDefaultSettings := map[string]string{}
@@ -814,6 +924,16 @@ func FolderFromConfig(configFile string) (storage.Folder, error) {
 	return folder, err
 }
 
+// CrypterFromConfig creates the crypter of the master key from the config file
+func CrypterFromConfig(configFile string) crypto.Crypter {
+	var config = viper.New()
+	SetDefaultValues(config)
+	ReadConfigFromFile(config, configFile)
+	CheckAllowedSettings(config)
+
+	return ConfigureCrypterForSpecificConfig(config)
+}
+
 // Set the compiled config to ENV.
 // Applicable for Swift/Postgres/etc libs that waiting config paramenters only from ENV.
 func bindConfigToEnv(globalViper *viper.Viper) {
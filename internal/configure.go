@@ -11,10 +11,12 @@ import (
 	"time"
 
 	"github.com/apecloud/dataprotection-wal-g/internal/crypto/yckms"
+	"github.com/apecloud/dataprotection-wal-g/internal/integrity"
 
 	"github.com/apecloud/dataprotection-wal-g/internal/compression"
 	"github.com/apecloud/dataprotection-wal-g/internal/crypto"
 	"github.com/apecloud/dataprotection-wal-g/internal/crypto/awskms"
+	"github.com/apecloud/dataprotection-wal-g/internal/crypto/envelope"
 	"github.com/apecloud/dataprotection-wal-g/internal/crypto/openpgp"
 	"github.com/apecloud/dataprotection-wal-g/internal/fsutil"
 	"github.com/apecloud/dataprotection-wal-g/internal/limiters"
@@ -135,7 +137,9 @@ func ConfigureFolder() (storage.Folder, error) {
 		folder = NewLimitedFolder(folder, limiters.NetworkLimiter)
 	}
 
-	return ConfigureStoragePrefix(folder), nil
+	folder = ConfigureStoragePrefix(folder)
+	ConfigureDataKeyStore(folder)
+	return folder, nil
 }
 
 func ConfigureStoragePrefix(folder storage.Folder) storage.Folder {
@@ -200,10 +204,15 @@ func GetPgSlotName() (pgSlotName string) {
 
 func ConfigureCompressor() (compression.Compressor, error) {
 	compressionMethod := viper.GetString(CompressionMethodSetting)
-	if _, ok := compression.Compressors[compressionMethod]; !ok {
+	compressor, ok := compression.Compressors[compressionMethod]
+	if !ok {
 		return nil, newUnknownCompressionMethodError(compressionMethod)
 	}
-	return compression.Compressors[compressionMethod], nil
+	compressor, err := configureCompressionLevel(compressor, compressionMethod)
+	if err != nil {
+		return nil, err
+	}
+	return configureZstdWindowLog(compressor)
 }
 
 func ConfigureLogging() error {
@@ -251,9 +260,21 @@ func ConfigureUploaderToFolder(folder storage.Folder) (uploader *RegularUploader
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to configure compression")
 	}
+	compressor, err = configureZstdDictionary(compressor, folder)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to configure compression")
+	}
+	ConfigureCompressionDictionaryLoader(folder)
 
 	uploader = NewRegularUploader(compressor, folder)
-	return uploader, err
+	uploader.ObjectLock, err = ConfigureObjectLock()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to configure object lock")
+	}
+	if isBackupManifestEnabled() {
+		uploader.IntegrityRecorder = integrity.NewRecorder()
+	}
+	return uploader, nil
 }
 
 func ConfigureUploaderWithoutCompressor() (uploader Uploader, err error) {
@@ -283,34 +304,51 @@ func ConfigureSplitUploader() (Uploader, error) {
 // ConfigureCrypter uses environment variables to create and configure a crypter.
 // In case no configuration in environment variables found, return `<nil>` value.
 func ConfigureCrypter() crypto.Crypter {
+	crypter := ConfigureCrypterForSpecificConfig(viper.GetViper())
+	if crypter != nil && viper.GetBool(EnvelopeEncryptionSetting) {
+		return envelope.NewCrypter(crypter)
+	}
+	return crypter
+}
+
+// ConfigureCrypterForSpecificConfig creates the crypter of the master key from the config.
+// The envelope encryption is not applied.
+func ConfigureCrypterForSpecificConfig(config *viper.Viper) crypto.Crypter {
 	loadPassphrase := func() (string, bool) {
-		return GetSetting(PgpKeyPassphraseSetting)
+		if config.IsSet(PgpKeyPassphraseSetting) {
+			return config.GetString(PgpKeyPassphraseSetting), true
+		}
+		return "", false
 	}
 
 	// key can be either private (for download) or public (for upload)
-	if viper.IsSet(PgpKeySetting) {
-		return openpgp.CrypterFromKey(viper.GetString(PgpKeySetting), loadPassphrase)
+	if config.IsSet(PgpKeySetting) {
+		return openpgp.CrypterFromKey(config.GetString(PgpKeySetting), loadPassphrase)
 	}
 
 	// key can be either private (for download) or public (for upload)
-	if viper.IsSet(PgpKeyPathSetting) {
-		return openpgp.CrypterFromKeyPath(viper.GetString(PgpKeyPathSetting), loadPassphrase)
+	if config.IsSet(PgpKeyPathSetting) {
+		return openpgp.CrypterFromKeyPath(config.GetString(PgpKeyPathSetting), loadPassphrase)
 	}
 
-	if keyRingID, ok := getWaleCompatibleSetting(GpgKeyIDSetting); ok {
+	if keyRingID, ok := getWaleCompatibleSettingFrom(GpgKeyIDSetting, config); ok {
 		tracelog.WarningLogger.Printf(DeprecatedExternalGpgMessage)
 		return openpgp.CrypterFromKeyRingID(keyRingID, loadPassphrase)
 	}
 
-	if viper.IsSet(CseKmsIDSetting) {
-		return awskms.CrypterFromKeyID(viper.GetString(CseKmsIDSetting), viper.GetString(CseKmsRegionSetting))
+	if config.IsSet(CseKmsIDSetting) {
+		return awskms.CrypterFromKeyID(config.GetString(CseKmsIDSetting), config.GetString(CseKmsRegionSetting))
 	}
 
-	if viper.IsSet(YcKmsKeyIDSetting) {
-		return yckms.YcCrypterFromKeyIDAndCredential(viper.GetString(YcKmsKeyIDSetting), viper.GetString(YcSaKeyFileSetting))
+	if config.IsSet(YcKmsKeyIDSetting) {
+		return yckms.YcCrypterFromKeyIDAndCredential(config.GetString(YcKmsKeyIDSetting), config.GetString(YcSaKeyFileSetting))
 	}
 
-	if crypter := configureLibsodiumCrypter(); crypter != nil {
+	if crypter := configureVaultCrypter(config); crypter != nil {
+		return crypter
+	}
+
+	if crypter := configureLibsodiumCrypter(config); crypter != nil {
 		return crypter
 	}
 
@@ -393,14 +431,19 @@ func GetCommandSettingContext(ctx context.Context, variableName string) (*exec.C
 		tracelog.ErrorLogger.Print(variableName + " expected.")
 		return nil, errors.New(variableName + " not configured")
 	}
+	return newShellCommand(ctx, dataStr), nil
+}
+
+// newShellCommand runs the command line with the user's shell
+func newShellCommand(ctx context.Context, command string) *exec.Cmd {
 	shell := os.Getenv("SHELL")
 	if shell == "" {
 		shell = "/bin/sh"
 	}
-	cmd := exec.CommandContext(ctx, shell, "-c", dataStr)
+	cmd := exec.CommandContext(ctx, shell, "-c", command)
 	// do not shut up subcommands by default
 	cmd.Stderr = os.Stderr
-	return cmd, nil
+	return cmd
 }
 
 func GetCommandSetting(variableName string) (*exec.Cmd, error) {
@@ -9,13 +9,13 @@ import (
 	"github.com/spf13/viper"
 )
 
-func configureLibsodiumCrypter() crypto.Crypter {
-	if viper.IsSet(LibsodiumKeySetting) {
-		return libsodium.CrypterFromKey(viper.GetString(LibsodiumKeySetting), viper.GetString(LibsodiumKeyTransform))
+func configureLibsodiumCrypter(config *viper.Viper) crypto.Crypter {
+	if config.IsSet(LibsodiumKeySetting) {
+		return libsodium.CrypterFromKey(config.GetString(LibsodiumKeySetting), config.GetString(LibsodiumKeyTransform))
 	}
 
-	if viper.IsSet(LibsodiumKeyPathSetting) {
-		return libsodium.CrypterFromKeyPath(viper.GetString(LibsodiumKeyPathSetting), viper.GetString(LibsodiumKeyTransform))
+	if config.IsSet(LibsodiumKeyPathSetting) {
+		return libsodium.CrypterFromKeyPath(config.GetString(LibsodiumKeyPathSetting), config.GetString(LibsodiumKeyTransform))
 	}
 
 	return nil
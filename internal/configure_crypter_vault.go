@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/crypto"
+	"github.com/apecloud/dataprotection-wal-g/internal/crypto/vault"
+)
+
+func configureVaultCrypter(config *viper.Viper) crypto.Crypter {
+	if !config.IsSet(VaultTransitKeySetting) {
+		return nil
+	}
+	if !config.IsSet(VaultAddressSetting) {
+		tracelog.ErrorLogger.Fatalf("%s is required to use the Vault transit key", VaultAddressSetting)
+	}
+
+	var auth vault.Authenticator
+	authMount := config.GetString(VaultAuthMountSetting)
+	switch {
+	case config.IsSet(VaultTokenFileSetting):
+		auth = vault.TokenFileAuth{Path: config.GetString(VaultTokenFileSetting)}
+	case config.IsSet(VaultRoleIDSetting):
+		auth = vault.AppRoleAuth{
+			Mount:        authMount,
+			RoleID:       config.GetString(VaultRoleIDSetting),
+			SecretIDPath: config.GetString(VaultSecretIDFileSetting),
+		}
+	case config.IsSet(VaultKubernetesRoleSetting):
+		auth = vault.KubernetesAuth{
+			Mount:     authMount,
+			Role:      config.GetString(VaultKubernetesRoleSetting),
+			TokenPath: config.GetString(VaultKubernetesTokenSetting),
+		}
+	default:
+		tracelog.ErrorLogger.Fatalf("one of %s, %s or %s is required to authenticate in Vault",
+			VaultTokenFileSetting, VaultRoleIDSetting, VaultKubernetesRoleSetting)
+	}
+
+	crypter, err := vault.CrypterFromSettings(
+		config.GetString(VaultAddressSetting),
+		config.GetString(VaultNamespaceSetting),
+		config.GetString(VaultTransitMountSetting),
+		config.GetString(VaultTransitKeySetting),
+		config.GetString(VaultCACertSetting),
+		auth)
+	tracelog.ErrorLogger.FatalfOnError("Can't configure Vault crypter: %v", err)
+	return crypter
+}
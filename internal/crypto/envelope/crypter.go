@@ -0,0 +1,299 @@
+package envelope
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/crypto"
+	"github.com/apecloud/dataprotection-wal-g/internal/ioextensions"
+	"github.com/minio/sio"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	// headerMagic starts every object encrypted with a data key
+	headerMagic = "WGENV1"
+	keyIDLen    = 16
+	dataKeyLen  = 32
+)
+
+// ErrNoKeyStore is returned when the data keys can't be stored or loaded since the store is not configured
+var ErrNoKeyStore = errors.New("envelope encryption key store is not configured")
+
+// KeyStore keeps the data keys wrapped by the master key
+type KeyStore interface {
+	PutWrappedKey(keyID string, wrappedKey []byte) error
+	// GetWrappedKey returns the data key by ID, whether it is shared or not
+	GetWrappedKey(keyID string) ([]byte, error)
+	// PutSharedKey stores the data key shared by the log uploads of many processes
+	PutSharedKey(keyID string, wrappedKey []byte) error
+	// GetSharedKey returns the newest shared data key and its creation time, or the empty ID if there is none
+	GetSharedKey() (keyID string, wrappedKey []byte, created time.Time, err error)
+}
+
+var (
+	keyStoreMutex sync.RWMutex
+	keyStore      KeyStore
+
+	dataKeysMutex sync.Mutex
+	// currentKeys are the data keys used by this process per master crypter
+	currentKeys = make(map[string]*dataKey)
+	// unwrappedKeys caches the data keys loaded from the store by ID
+	unwrappedKeys = make(map[string][]byte)
+	// usedKeyIDs are the data keys the objects uploaded by this process depend on
+	usedKeyIDs = make(map[string]bool)
+	// sharedKeyRotation is zero unless the process encrypts with the shared data key
+	sharedKeyRotation time.Duration
+)
+
+type dataKey struct {
+	id      string
+	key     []byte
+	created time.Time
+}
+
+func SetKeyStore(store KeyStore) {
+	keyStoreMutex.Lock()
+	defer keyStoreMutex.Unlock()
+	keyStore = store
+}
+
+func getKeyStore() (KeyStore, error) {
+	keyStoreMutex.RLock()
+	defer keyStoreMutex.RUnlock()
+	if keyStore == nil {
+		return nil, ErrNoKeyStore
+	}
+	return keyStore, nil
+}
+
+// ShareDataKeys makes the process encrypt with the data key shared by the log uploads instead of
+// generating its own one. The shared key is rotated once it is older than the rotation period,
+// so the WAL range uploaded by many short processes is encrypted with a single data key.
+func ShareDataKeys(rotation time.Duration) {
+	dataKeysMutex.Lock()
+	defer dataKeysMutex.Unlock()
+	sharedKeyRotation = rotation
+}
+
+// ReferenceKeys records the data keys of the objects uploaded by the other processes
+// which the objects of this process depend on, e.g. the files reused from the previous backup
+func ReferenceKeys(keyIDs ...string) {
+	dataKeysMutex.Lock()
+	defer dataKeysMutex.Unlock()
+	for _, keyID := range keyIDs {
+		usedKeyIDs[keyID] = true
+	}
+}
+
+// UsedKeyIDs returns the sorted IDs of the data keys used and referenced by this process
+func UsedKeyIDs() []string {
+	dataKeysMutex.Lock()
+	defer dataKeysMutex.Unlock()
+	keyIDs := make([]string, 0, len(usedKeyIDs))
+	for keyID := range usedKeyIDs {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+	return keyIDs
+}
+
+// ForgetUsedKeys forgets the data keys used so far, so the next backup of the process lists only its own keys
+func ForgetUsedKeys() {
+	dataKeysMutex.Lock()
+	defer dataKeysMutex.Unlock()
+	usedKeyIDs = make(map[string]bool)
+}
+
+// Crypter encrypts the objects with the random data key, the backup process generates its own data key
+// and the log uploads reuse the shared one, see ShareDataKeys.
+// The data key is wrapped by the Master crypter and kept in the KeyStore,
+// so rotating the master key only requires re-wrapping the data keys.
+type Crypter struct {
+	Master crypto.Crypter
+}
+
+func NewCrypter(master crypto.Crypter) crypto.Crypter {
+	return &Crypter{Master: master}
+}
+
+func (crypter *Crypter) Name() string {
+	return "Envelope/" + crypter.Master.Name()
+}
+
+// CurrentKeyID returns the ID of the data key the crypter encrypts with
+func (crypter *Crypter) CurrentKeyID() (string, error) {
+	key, err := crypter.currentKey()
+	if err != nil {
+		return "", err
+	}
+	return key.id, nil
+}
+
+// Encrypt writes the header with the data key ID followed by the data encrypted with the data key
+func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	key, err := crypter.currentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	bufferedWriter := bufio.NewWriter(writer)
+	keyID, err := hex.DecodeString(key.id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = bufferedWriter.Write(append([]byte(headerMagic), keyID...)); err != nil {
+		return nil, errors.Wrap(err, "can't write envelope encryption header")
+	}
+	encryptedWriter, err := sio.EncryptWriter(bufferedWriter, sio.Config{Key: key.key})
+	if err != nil {
+		return nil, errors.Wrap(err, "can't create envelope encryption writer")
+	}
+	return ioextensions.NewOnCloseFlusher(encryptedWriter, bufferedWriter), nil
+}
+
+// Decrypt reads the data key ID from the header and loads the key from the KeyStore.
+// The objects without the header are decrypted with the Master crypter,
+// so the objects uploaded before the envelope encryption was enabled stay readable.
+func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
+	header := make([]byte, len(headerMagic)+keyIDLen)
+	n, err := io.ReadFull(reader, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	if n < len(header) || string(header[:len(headerMagic)]) != headerMagic {
+		return crypter.Master.Decrypt(io.MultiReader(bytes.NewReader(header[:n]), reader))
+	}
+
+	key, err := crypter.loadKey(hex.EncodeToString(header[len(headerMagic):]))
+	if err != nil {
+		return nil, err
+	}
+	return sio.DecryptReader(reader, sio.Config{Key: key})
+}
+
+func (crypter *Crypter) currentKey() (*dataKey, error) {
+	dataKeysMutex.Lock()
+	defer dataKeysMutex.Unlock()
+	key, ok := currentKeys[crypter.Master.Name()]
+	if ok && (sharedKeyRotation == 0 || time.Since(key.created) < sharedKeyRotation) {
+		usedKeyIDs[key.id] = true
+		return key, nil
+	}
+
+	store, err := getKeyStore()
+	if err != nil {
+		return nil, err
+	}
+	if sharedKeyRotation != 0 {
+		key, err = crypter.loadSharedKey(store)
+	} else {
+		key, err = crypter.newKey(store.PutWrappedKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	currentKeys[crypter.Master.Name()] = key
+	unwrappedKeys[key.id] = key.key
+	usedKeyIDs[key.id] = true
+	return key, nil
+}
+
+// loadSharedKey returns the newest shared data key unless it has to be rotated.
+// The new shared key is generated if the Master crypter is not able to unwrap it, e.g. has the public key only.
+func (crypter *Crypter) loadSharedKey(store KeyStore) (*dataKey, error) {
+	keyID, wrappedKey, created, err := store.GetSharedKey()
+	if err != nil {
+		return nil, errors.Wrap(err, "can't load shared data key")
+	}
+	if keyID != "" && time.Since(created) < sharedKeyRotation {
+		key, err := UnwrapKey(crypter.Master, wrappedKey)
+		if err == nil && len(key) == dataKeyLen {
+			return &dataKey{id: keyID, key: key, created: created}, nil
+		}
+		tracelog.DebugLogger.Printf("Can't unwrap shared data key %s, generating the new one: %v", keyID, err)
+	}
+	return crypter.newKey(store.PutSharedKey)
+}
+
+func (crypter *Crypter) newKey(put func(keyID string, wrappedKey []byte) error) (*dataKey, error) {
+	key, err := generateDataKey()
+	if err != nil {
+		return nil, errors.Wrap(err, "can't generate data key")
+	}
+	wrappedKey, err := WrapKey(crypter.Master, key.key)
+	if err != nil {
+		return nil, err
+	}
+	if err = put(key.id, wrappedKey); err != nil {
+		return nil, errors.Wrapf(err, "can't store data key %s", key.id)
+	}
+	return key, nil
+}
+
+func (crypter *Crypter) loadKey(keyID string) ([]byte, error) {
+	dataKeysMutex.Lock()
+	defer dataKeysMutex.Unlock()
+	if key, ok := unwrappedKeys[keyID]; ok {
+		return key, nil
+	}
+
+	store, err := getKeyStore()
+	if err != nil {
+		return nil, err
+	}
+	wrappedKey, err := store.GetWrappedKey(keyID)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't load data key %s", keyID)
+	}
+	key, err := UnwrapKey(crypter.Master, wrappedKey)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't unwrap data key %s", keyID)
+	}
+	if len(key) != dataKeyLen {
+		return nil, fmt.Errorf("data key %s has invalid length %d", keyID, len(key))
+	}
+	unwrappedKeys[keyID] = key
+	return key, nil
+}
+
+func generateDataKey() (*dataKey, error) {
+	buf := make([]byte, keyIDLen+dataKeyLen)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, err
+	}
+	return &dataKey{id: hex.EncodeToString(buf[:keyIDLen]), key: buf[keyIDLen:], created: time.Now()}, nil
+}
+
+// WrapKey encrypts the data key with the master crypter
+func WrapKey(master crypto.Crypter, key []byte) ([]byte, error) {
+	var wrapped bytes.Buffer
+	writer, err := master.Encrypt(&wrapped)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't wrap data key")
+	}
+	if _, err = writer.Write(key); err != nil {
+		return nil, errors.Wrap(err, "can't wrap data key")
+	}
+	if err = writer.Close(); err != nil {
+		return nil, errors.Wrap(err, "can't wrap data key")
+	}
+	return wrapped.Bytes(), nil
+}
+
+// UnwrapKey decrypts the data key with the master crypter
+func UnwrapKey(master crypto.Crypter, wrappedKey []byte) ([]byte, error) {
+	reader, err := master.Decrypt(bytes.NewReader(wrappedKey))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,232 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// xorCrypter is the toy master crypter, it xors the data with the key byte
+type xorCrypter struct {
+	key byte
+}
+
+func (crypter xorCrypter) Name() string {
+	return fmt.Sprintf("xor-%d", crypter.key)
+}
+
+func (crypter xorCrypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	return &xorWriter{writer: writer, key: crypter.key}, nil
+}
+
+func (crypter xorCrypter) Decrypt(reader io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(xor(data, crypter.key)), nil
+}
+
+type xorWriter struct {
+	writer io.Writer
+	key    byte
+}
+
+func (writer *xorWriter) Write(p []byte) (int, error) {
+	return writer.writer.Write(xor(p, writer.key))
+}
+
+func (writer *xorWriter) Close() error {
+	return nil
+}
+
+func xor(data []byte, key byte) []byte {
+	result := make([]byte, len(data))
+	for i := range data {
+		result[i] = data[i] ^ key
+	}
+	return result
+}
+
+type memoryKeyStore struct {
+	keys       map[string][]byte
+	sharedKeys map[string][]byte
+	newestID   string
+	created    time.Time
+}
+
+func newMemoryKeyStore() *memoryKeyStore {
+	return &memoryKeyStore{keys: make(map[string][]byte), sharedKeys: make(map[string][]byte)}
+}
+
+func (store *memoryKeyStore) PutWrappedKey(keyID string, wrappedKey []byte) error {
+	store.keys[keyID] = wrappedKey
+	return nil
+}
+
+func (store *memoryKeyStore) GetWrappedKey(keyID string) ([]byte, error) {
+	if wrappedKey, ok := store.keys[keyID]; ok {
+		return wrappedKey, nil
+	}
+	if wrappedKey, ok := store.sharedKeys[keyID]; ok {
+		return wrappedKey, nil
+	}
+	return nil, fmt.Errorf("key %s not found", keyID)
+}
+
+func (store *memoryKeyStore) PutSharedKey(keyID string, wrappedKey []byte) error {
+	store.sharedKeys[keyID] = wrappedKey
+	store.newestID, store.created = keyID, time.Now()
+	return nil
+}
+
+func (store *memoryKeyStore) GetSharedKey() (string, []byte, time.Time, error) {
+	return store.newestID, store.sharedKeys[store.newestID], store.created, nil
+}
+
+func resetKeys(store KeyStore) {
+	SetKeyStore(store)
+	currentKeys = make(map[string]*dataKey)
+	unwrappedKeys = make(map[string][]byte)
+	usedKeyIDs = make(map[string]bool)
+	sharedKeyRotation = 0
+}
+
+func keyIDOf(encrypted []byte) string {
+	return hex.EncodeToString(encrypted[len(headerMagic) : len(headerMagic)+keyIDLen])
+}
+
+func encrypt(t *testing.T, crypter *Crypter, data []byte) []byte {
+	var encrypted bytes.Buffer
+	writer, err := crypter.Encrypt(&encrypted)
+	require.NoError(t, err)
+	_, err = writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return encrypted.Bytes()
+}
+
+func decrypt(t *testing.T, crypter *Crypter, data []byte) []byte {
+	reader, err := crypter.Decrypt(bytes.NewReader(data))
+	require.NoError(t, err)
+	decrypted, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return decrypted
+}
+
+func TestCrypter_EncryptDecrypt(t *testing.T) {
+	store := newMemoryKeyStore()
+	resetKeys(store)
+	crypter := &Crypter{Master: xorCrypter{key: 42}}
+	data := bytes.Repeat([]byte("envelope "), 10000)
+
+	encrypted := encrypt(t, crypter, data)
+	assert.Equal(t, headerMagic, string(encrypted[:len(headerMagic)]))
+	assert.Len(t, store.keys, 1)
+	// the second object is encrypted with the same data key
+	encrypt(t, crypter, data)
+	assert.Len(t, store.keys, 1)
+	assert.Equal(t, []string{keyIDOf(encrypted)}, UsedKeyIDs())
+
+	// the new process loads the data key from the store
+	resetKeys(store)
+	assert.Equal(t, data, decrypt(t, crypter, encrypted))
+}
+
+func TestCrypter_DecryptWithoutHeader(t *testing.T) {
+	resetKeys(newMemoryKeyStore())
+	master := xorCrypter{key: 7}
+	crypter := &Crypter{Master: master}
+
+	for _, data := range [][]byte{[]byte("abc"), bytes.Repeat([]byte("plain "), 100)} {
+		var encrypted bytes.Buffer
+		writer, _ := master.Encrypt(&encrypted)
+		_, _ = writer.Write(data)
+		assert.Equal(t, data, decrypt(t, crypter, encrypted.Bytes()))
+	}
+}
+
+func TestCrypter_NoKeyStore(t *testing.T) {
+	resetKeys(nil)
+	crypter := &Crypter{Master: xorCrypter{key: 1}}
+	_, err := crypter.Encrypt(&bytes.Buffer{})
+	assert.ErrorIs(t, err, ErrNoKeyStore)
+}
+
+func TestRewrapKey(t *testing.T) {
+	store := newMemoryKeyStore()
+	resetKeys(store)
+	oldMaster, newMaster := xorCrypter{key: 1}, xorCrypter{key: 2}
+	data := []byte("rotated")
+	encrypted := encrypt(t, &Crypter{Master: oldMaster}, data)
+
+	for keyID, wrappedKey := range store.keys {
+		key, err := UnwrapKey(oldMaster, wrappedKey)
+		require.NoError(t, err)
+		store.keys[keyID], err = WrapKey(newMaster, key)
+		require.NoError(t, err)
+	}
+
+	resetKeys(store)
+	assert.Equal(t, data, decrypt(t, &Crypter{Master: newMaster}, encrypted))
+}
+
+func TestCrypter_SharedKey(t *testing.T) {
+	store := newMemoryKeyStore()
+	resetKeys(store)
+	ShareDataKeys(time.Hour)
+	crypter := &Crypter{Master: xorCrypter{key: 3}}
+	data := []byte("wal segment")
+
+	first := encrypt(t, crypter, data)
+	// the next process reuses the shared key
+	resetKeys(store)
+	ShareDataKeys(time.Hour)
+	second := encrypt(t, crypter, data)
+	assert.Equal(t, keyIDOf(first), keyIDOf(second))
+	assert.Empty(t, store.keys)
+	assert.Len(t, store.sharedKeys, 1)
+
+	// the outdated shared key is rotated, the objects encrypted with it stay readable
+	store.created = time.Now().Add(-2 * time.Hour)
+	currentKeys[crypter.Master.Name()].created = store.created
+	third := encrypt(t, crypter, data)
+	assert.NotEqual(t, keyIDOf(first), keyIDOf(third))
+	assert.Len(t, store.sharedKeys, 2)
+	assert.Equal(t, data, decrypt(t, crypter, first))
+}
+
+func TestCrypter_BackupKeys(t *testing.T) {
+	store := newMemoryKeyStore()
+	resetKeys(store)
+	crypter := &Crypter{Master: xorCrypter{key: 5}}
+	first := encrypt(t, crypter, []byte("backup"))
+
+	// every backup process generates its own key
+	resetKeys(store)
+	ReferenceKeys(keyIDOf(first))
+	second := encrypt(t, crypter, []byte("backup"))
+	assert.NotEqual(t, keyIDOf(first), keyIDOf(second))
+	assert.Len(t, store.keys, 2)
+	assert.ElementsMatch(t, []string{keyIDOf(first), keyIDOf(second)}, UsedKeyIDs())
+}
+
+func TestForgetUsedKeys(t *testing.T) {
+	store := newMemoryKeyStore()
+	resetKeys(store)
+	crypter := &Crypter{Master: xorCrypter{key: 5}}
+	first := encrypt(t, crypter, []byte("backup"))
+	assert.Equal(t, []string{keyIDOf(first)}, UsedKeyIDs())
+
+	ForgetUsedKeys()
+	assert.Empty(t, UsedKeyIDs())
+	// the current key is listed again once it encrypts the next backup
+	second := encrypt(t, crypter, []byte("backup"))
+	assert.Equal(t, []string{keyIDOf(second)}, UsedKeyIDs())
+}
@@ -0,0 +1,251 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultTransitMount = "transit"
+	// DefaultKubernetesTokenPath is where the service account token is mounted into the pod
+	DefaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	requestTimeout = 30 * time.Second
+)
+
+// Authenticator obtains the Vault token
+type Authenticator interface {
+	Login(client *Client) (string, error)
+}
+
+// TokenFileAuth reads the token from the file, e.g. the one written by Vault Agent.
+// The file is re-read on every login, so the rotated token is picked up.
+type TokenFileAuth struct {
+	Path string
+}
+
+func (auth TokenFileAuth) Login(*Client) (string, error) {
+	token, err := readFileValue(auth.Path)
+	if err != nil {
+		return "", errors.Wrap(err, "can't read Vault token file")
+	}
+	return token, nil
+}
+
+// AppRoleAuth logs in with the AppRole role ID and the secret ID read from the file
+type AppRoleAuth struct {
+	Mount        string
+	RoleID       string
+	SecretIDPath string
+}
+
+func (auth AppRoleAuth) Login(client *Client) (string, error) {
+	secretID, err := readFileValue(auth.SecretIDPath)
+	if err != nil {
+		return "", errors.Wrap(err, "can't read Vault AppRole secret ID file")
+	}
+	return client.login(orDefault(auth.Mount, "approle"), map[string]string{
+		"role_id":   auth.RoleID,
+		"secret_id": secretID,
+	})
+}
+
+// KubernetesAuth logs in with the JWT of the pod service account
+type KubernetesAuth struct {
+	Mount     string
+	Role      string
+	TokenPath string
+}
+
+func (auth KubernetesAuth) Login(client *Client) (string, error) {
+	jwt, err := readFileValue(orDefault(auth.TokenPath, DefaultKubernetesTokenPath))
+	if err != nil {
+		return "", errors.Wrap(err, "can't read Kubernetes service account token")
+	}
+	return client.login(orDefault(auth.Mount, "kubernetes"), map[string]string{
+		"role": auth.Role,
+		"jwt":  jwt,
+	})
+}
+
+// Client calls the transit secrets engine over the Vault HTTP API
+type Client struct {
+	Address      string
+	Namespace    string
+	TransitMount string
+	Auth         Authenticator
+
+	httpClient *http.Client
+	tokenMutex sync.Mutex
+	token      string
+}
+
+func NewClient(address, namespace, transitMount string, auth Authenticator, caCertPath string) (*Client, error) {
+	httpClient := &http.Client{Timeout: requestTimeout}
+	if caCertPath != "" {
+		caCert, err := os.ReadFile(caCertPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "can't read Vault CA certificate")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in %s", caCertPath)
+		}
+		httpClient.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+	return &Client{
+		Address:      strings.TrimSuffix(address, "/"),
+		Namespace:    namespace,
+		TransitMount: orDefault(transitMount, DefaultTransitMount),
+		Auth:         auth,
+		httpClient:   httpClient,
+	}, nil
+}
+
+// GenerateDataKey asks Vault for the new data key, returns it in plaintext and wrapped with the transit key
+func (client *Client) GenerateDataKey(keyName string, bits int) (key []byte, wrappedKey string, err error) {
+	var data struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	err = client.transitRequest("datakey/plaintext/"+keyName, map[string]interface{}{"bits": bits}, &data)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "can't generate Vault data key")
+	}
+	key, err = base64.StdEncoding.DecodeString(data.Plaintext)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "can't decode Vault data key")
+	}
+	return key, data.Ciphertext, nil
+}
+
+// Decrypt unwraps the ciphertext returned by GenerateDataKey
+func (client *Client) Decrypt(keyName, ciphertext string) ([]byte, error) {
+	var data struct {
+		Plaintext string `json:"plaintext"`
+	}
+	err := client.transitRequest("decrypt/"+keyName, map[string]interface{}{"ciphertext": ciphertext}, &data)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decrypt with Vault transit key")
+	}
+	plaintext, err := base64.StdEncoding.DecodeString(data.Plaintext)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't decode Vault plaintext")
+	}
+	return plaintext, nil
+}
+
+// transitRequest calls the transit endpoint and logs in again once if the token is expired or revoked
+func (client *Client) transitRequest(path string, body interface{}, data interface{}) error {
+	for attempt := 0; ; attempt++ {
+		token, err := client.getToken(attempt > 0)
+		if err != nil {
+			return err
+		}
+		status, err := client.do(client.TransitMount+"/"+path, token, body, data)
+		if status == http.StatusForbidden && attempt == 0 {
+			continue
+		}
+		return err
+	}
+}
+
+func (client *Client) getToken(renew bool) (string, error) {
+	client.tokenMutex.Lock()
+	defer client.tokenMutex.Unlock()
+	if client.token != "" && !renew {
+		return client.token, nil
+	}
+	token, err := client.Auth.Login(client)
+	if err != nil {
+		return "", err
+	}
+	client.token = token
+	return token, nil
+}
+
+func (client *Client) login(mount string, body map[string]string) (string, error) {
+	var response struct {
+		Auth *struct {
+			ClientToken string `json:"client_token"`
+		} `json:"auth"`
+	}
+	if _, err := client.doRaw("auth/"+mount+"/login", "", body, &response); err != nil {
+		return "", errors.Wrapf(err, "can't log in to Vault with %s auth method", mount)
+	}
+	if response.Auth == nil || response.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault %s login returned no token", mount)
+	}
+	return response.Auth.ClientToken, nil
+}
+
+// do sends the request and decodes the "data" field of the response
+func (client *Client) do(path, token string, body interface{}, data interface{}) (int, error) {
+	response := struct {
+		Data interface{} `json:"data"`
+	}{Data: data}
+	return client.doRaw(path, token, body, &response)
+}
+
+func (client *Client) doRaw(path, token string, body interface{}, response interface{}) (int, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	request, err := http.NewRequest(http.MethodPost, client.Address+"/v1/"+path, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set("X-Vault-Token", token)
+	}
+	if client.Namespace != "" {
+		request.Header.Set("X-Vault-Namespace", client.Namespace)
+	}
+
+	resp, err := client.httpClient.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.Unmarshal(respBody, &vaultErr)
+		return resp.StatusCode, fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(vaultErr.Errors, "; "))
+	}
+	return resp.StatusCode, json.Unmarshal(respBody, response)
+}
+
+func readFileValue(path string) (string, error) {
+	value, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+func orDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,153 @@
+package vault
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/crypto"
+	"github.com/apecloud/dataprotection-wal-g/internal/ioextensions"
+	"github.com/minio/sio"
+	"github.com/pkg/errors"
+)
+
+const (
+	magic              = "vault"
+	schemeVersion byte = 1
+
+	dataKeyBits = 256
+	// maxWrappedKeyLen is the sanity limit of the wrapped key length read from the header
+	maxWrappedKeyLen = 4096
+)
+
+// TransitClient is the part of the Vault transit API used by the Crypter
+type TransitClient interface {
+	GenerateDataKey(keyName string, bits int) (key []byte, wrappedKey string, err error)
+	Decrypt(keyName, ciphertext string) ([]byte, error)
+}
+
+// Crypter encrypts the files with the data key generated by the Vault transit secrets engine.
+// The data key wrapped with the transit key is written to the header of every file.
+type Crypter struct {
+	client  TransitClient
+	keyName string
+
+	mutex      sync.Mutex
+	key        []byte
+	wrappedKey string
+	// unwrappedKeys caches the data keys decrypted by Vault, keyed by the wrapped key
+	unwrappedKeys map[string][]byte
+}
+
+func NewCrypter(client TransitClient, keyName string) *Crypter {
+	return &Crypter{client: client, keyName: keyName, unwrappedKeys: make(map[string][]byte)}
+}
+
+// CrypterFromSettings creates the Vault transit crypter, the authentication is deferred until the first use
+func CrypterFromSettings(address, namespace, transitMount, keyName, caCertPath string,
+	auth Authenticator) (crypto.Crypter, error) {
+	client, err := NewClient(address, namespace, transitMount, auth, caCertPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewCrypter(client, keyName), nil
+}
+
+func (crypter *Crypter) Name() string {
+	return "Vault/Crypter"
+}
+
+// Encrypt writes the wrapped data key followed by the data encrypted with it
+func (crypter *Crypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	key, wrappedKey, err := crypter.dataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	bufferedWriter := bufio.NewWriter(writer)
+	if _, err = bufferedWriter.Write(serializeWrappedKey(wrappedKey)); err != nil {
+		return nil, errors.Wrap(err, "can't write Vault encryption header")
+	}
+	encryptedWriter, err := sio.EncryptWriter(bufferedWriter, sio.Config{Key: key})
+	if err != nil {
+		return nil, errors.Wrap(err, "Vault can't create encrypted writer")
+	}
+	return ioextensions.NewOnCloseFlusher(encryptedWriter, bufferedWriter), nil
+}
+
+// Decrypt reads the wrapped data key from the header and unwraps it with Vault
+func (crypter *Crypter) Decrypt(reader io.Reader) (io.Reader, error) {
+	wrappedKey, err := deserializeWrappedKey(reader)
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypter.unwrapKey(wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return sio.DecryptReader(reader, sio.Config{Key: key})
+}
+
+func (crypter *Crypter) dataKey() ([]byte, string, error) {
+	crypter.mutex.Lock()
+	defer crypter.mutex.Unlock()
+	if crypter.key == nil {
+		key, wrappedKey, err := crypter.client.GenerateDataKey(crypter.keyName, dataKeyBits)
+		if err != nil {
+			return nil, "", err
+		}
+		crypter.key, crypter.wrappedKey = key, wrappedKey
+		crypter.unwrappedKeys[wrappedKey] = key
+	}
+	return crypter.key, crypter.wrappedKey, nil
+}
+
+func (crypter *Crypter) unwrapKey(wrappedKey string) ([]byte, error) {
+	crypter.mutex.Lock()
+	defer crypter.mutex.Unlock()
+	if key, ok := crypter.unwrappedKeys[wrappedKey]; ok {
+		return key, nil
+	}
+	key, err := crypter.client.Decrypt(crypter.keyName, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	crypter.unwrappedKeys[wrappedKey] = key
+	return key, nil
+}
+
+func serializeWrappedKey(wrappedKey string) []byte {
+	/*
+		magic value "vault"
+		scheme version (current version is 1)
+		uint32 - wrapped key len
+		wrapped key, e.g. "vault:v1:..."
+	*/
+	header := append([]byte(magic), schemeVersion)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(wrappedKey)))
+	return append(header, wrappedKey...)
+}
+
+func deserializeWrappedKey(reader io.Reader) (string, error) {
+	header := make([]byte, len(magic)+1+4)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return "", errors.Wrap(err, "can't read Vault encryption header")
+	}
+	if string(header[:len(magic)]) != magic {
+		return "", errors.New("Vault: invalid encryption header format")
+	}
+	if header[len(magic)] != schemeVersion {
+		return "", fmt.Errorf("Vault: scheme version %d is not supported", header[len(magic)])
+	}
+	wrappedKeyLen := binary.LittleEndian.Uint32(header[len(magic)+1:])
+	if wrappedKeyLen > maxWrappedKeyLen {
+		return "", errors.New("Vault: invalid size of the wrapped key")
+	}
+	wrappedKey := make([]byte, wrappedKeyLen)
+	if _, err := io.ReadFull(reader, wrappedKey); err != nil {
+		return "", errors.Wrap(err, "can't read Vault wrapped key")
+	}
+	return string(wrappedKey), nil
+}
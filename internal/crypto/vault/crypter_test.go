@@ -0,0 +1,216 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testKeyName   = "wal-g"
+	testSecret    = "this is a very secret string used in our tests"
+	testNamespace = "dba"
+)
+
+// fakeTransit is the in-process Vault serving the transit engine and the AppRole and Kubernetes logins
+type fakeTransit struct {
+	mutex       sync.Mutex
+	validTokens map[string]bool
+	keys        map[string][]byte
+	logins      int
+	requests    map[string]int
+}
+
+func newFakeTransit(tokens ...string) *fakeTransit {
+	transit := &fakeTransit{validTokens: make(map[string]bool), keys: make(map[string][]byte), requests: make(map[string]int)}
+	for _, token := range tokens {
+		transit.validTokens[token] = true
+	}
+	return transit
+}
+
+func (transit *fakeTransit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	transit.mutex.Lock()
+	defer transit.mutex.Unlock()
+
+	if r.Header.Get("X-Vault-Namespace") != testNamespace {
+		writeVaultError(w, http.StatusNotFound, "namespace not found")
+		return
+	}
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeVaultError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	transit.requests[path]++
+	switch path {
+	case "auth/approle/login":
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			writeVaultError(w, http.StatusBadRequest, "invalid role or secret ID")
+			return
+		}
+		transit.issueToken(w)
+		return
+	case "auth/kubernetes/login":
+		if body["role"] != "wal-g" || body["jwt"] != "jwt" {
+			writeVaultError(w, http.StatusForbidden, "permission denied")
+			return
+		}
+		transit.issueToken(w)
+		return
+	}
+
+	if !transit.validTokens[r.Header.Get("X-Vault-Token")] {
+		writeVaultError(w, http.StatusForbidden, "permission denied")
+		return
+	}
+	switch path {
+	case "transit/datakey/plaintext/" + testKeyName:
+		key := make([]byte, int(body["bits"].(float64))/8)
+		_, _ = rand.Read(key)
+		ciphertext := fmt.Sprintf("vault:v1:%d", len(transit.keys))
+		transit.keys[ciphertext] = key
+		writeVaultData(w, map[string]string{
+			"plaintext":  base64.StdEncoding.EncodeToString(key),
+			"ciphertext": ciphertext,
+		})
+	case "transit/decrypt/" + testKeyName:
+		key, ok := transit.keys[body["ciphertext"].(string)]
+		if !ok {
+			writeVaultError(w, http.StatusBadRequest, "invalid ciphertext")
+			return
+		}
+		writeVaultData(w, map[string]string{"plaintext": base64.StdEncoding.EncodeToString(key)})
+	default:
+		writeVaultError(w, http.StatusNotFound, "no handler for route "+path)
+	}
+}
+
+func (transit *fakeTransit) issueToken(w http.ResponseWriter) {
+	transit.logins++
+	token := fmt.Sprintf("token-%d", transit.logins)
+	transit.validTokens[token] = true
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"auth": map[string]string{"client_token": token}})
+}
+
+func (transit *fakeTransit) revokeTokens() {
+	transit.mutex.Lock()
+	defer transit.mutex.Unlock()
+	transit.validTokens = make(map[string]bool)
+}
+
+func writeVaultData(w http.ResponseWriter, data interface{}) {
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func writeVaultError(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string][]string{"errors": {message}})
+}
+
+func writeTempFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content+"\n"), 0600))
+	return path
+}
+
+func newTestCrypter(t *testing.T, server *httptest.Server, auth Authenticator) *Crypter {
+	client, err := NewClient(server.URL+"/", testNamespace, "", auth, "")
+	require.NoError(t, err)
+	return NewCrypter(client, testKeyName)
+}
+
+func encryptDecrypt(t *testing.T, encrypter, decrypter *Crypter, data []byte) []byte {
+	buffer := new(bytes.Buffer)
+	writer, err := encrypter.Encrypt(buffer)
+	require.NoError(t, err)
+	_, err = writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	reader, err := decrypter.Decrypt(buffer)
+	require.NoError(t, err)
+	decrypted, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return decrypted
+}
+
+func TestCrypterEncryptionCycle(t *testing.T) {
+	transit := newFakeTransit()
+	server := httptest.NewServer(transit)
+	defer server.Close()
+
+	auths := map[string]Authenticator{
+		"token":      TokenFileAuth{Path: writeTempFile(t, "token", "static-token")},
+		"approle":    AppRoleAuth{RoleID: "role", SecretIDPath: writeTempFile(t, "secret-id", "secret")},
+		"kubernetes": KubernetesAuth{Role: "wal-g", TokenPath: writeTempFile(t, "jwt", "jwt")},
+	}
+	transit.validTokens["static-token"] = true
+
+	for name, auth := range auths {
+		t.Run(name, func(t *testing.T) {
+			// the files are decrypted by another process, so the data key is unwrapped by Vault
+			decrypted := encryptDecrypt(t, newTestCrypter(t, server, auth), newTestCrypter(t, server, auth), []byte(testSecret))
+			assert.Equal(t, testSecret, string(decrypted))
+		})
+	}
+}
+
+func TestCrypterReusesDataKey(t *testing.T) {
+	transit := newFakeTransit("static-token")
+	server := httptest.NewServer(transit)
+	defer server.Close()
+
+	crypter := newTestCrypter(t, server, TokenFileAuth{Path: writeTempFile(t, "token", "static-token")})
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, testSecret, string(encryptDecrypt(t, crypter, crypter, []byte(testSecret))))
+	}
+	assert.Equal(t, 1, transit.requests["transit/datakey/plaintext/"+testKeyName])
+	assert.Equal(t, 0, transit.requests["transit/decrypt/"+testKeyName])
+}
+
+func TestCrypterLogsInAgainWhenTokenIsRevoked(t *testing.T) {
+	transit := newFakeTransit()
+	server := httptest.NewServer(transit)
+	defer server.Close()
+
+	auth := AppRoleAuth{RoleID: "role", SecretIDPath: writeTempFile(t, "secret-id", "secret")}
+	encrypter := newTestCrypter(t, server, auth)
+	decrypter := newTestCrypter(t, server, auth)
+	encryptDecrypt(t, encrypter, decrypter, []byte(testSecret))
+
+	transit.revokeTokens()
+	encrypter = newTestCrypter(t, server, auth)
+	encryptDecrypt(t, encrypter, decrypter, []byte(testSecret))
+	assert.Equal(t, 4, transit.logins)
+}
+
+func TestCrypterFailedLogin(t *testing.T) {
+	server := httptest.NewServer(newFakeTransit())
+	defer server.Close()
+
+	crypter := newTestCrypter(t, server, KubernetesAuth{Role: "other", TokenPath: writeTempFile(t, "jwt", "jwt")})
+	_, err := crypter.Encrypt(new(bytes.Buffer))
+	assert.ErrorContains(t, err, "permission denied")
+}
+
+func TestCrypterInvalidHeader(t *testing.T) {
+	crypter := NewCrypter(nil, testKeyName)
+	_, err := crypter.Decrypt(strings.NewReader("not a vault header"))
+	assert.Error(t, err)
+}
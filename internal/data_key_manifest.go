@@ -0,0 +1,279 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/crypto/envelope"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+// DataKeyManifestName is the file in the backup folder listing the data keys the backup objects are encrypted with
+const DataKeyManifestName = "data_keys.json"
+
+// DataKeyManifest lists the envelope encryption data keys the backup depends on
+type DataKeyManifest struct {
+	KeyIDs []string `json:"KeyIDs"`
+}
+
+// uploadDataKeyManifest records the data keys used and referenced by the backup process, if any.
+// The keys are locked at least as long as the backup objects, so the locked backup never outlives its keys.
+func uploadDataKeyManifest(uploader Uploader, backupName string) error {
+	keyIDs := envelope.UsedKeyIDs()
+	if len(keyIDs) == 0 {
+		return nil
+	}
+	if regularUploader := getRegularUploader(uploader); regularUploader != nil && regularUploader.ObjectLock != nil {
+		if err := lockDataKeys(keyIDs, *regularUploader.ObjectLock); err != nil {
+			return err
+		}
+	}
+	tracelog.DebugLogger.Printf("Backup %s is encrypted with the data keys %v", backupName, keyIDs)
+	err := UploadBackupDto(uploader, DataKeyManifest{KeyIDs: keyIDs}, path.Join(backupName, DataKeyManifestName))
+	if err != nil {
+		return err
+	}
+	envelope.ForgetUsedKeys()
+	return nil
+}
+
+// lockDataKeys extends the lock of every data key up to the lock of the backup referencing it
+func lockDataKeys(keyIDs []string, lock storage.ObjectLock) error {
+	if dataKeyStore == nil {
+		return nil
+	}
+	for _, keyID := range keyIDs {
+		if err := dataKeyStore.extendKeyLock(keyID, lock); err != nil {
+			return errors.Wrapf(err, "failed to lock data key %s", keyID)
+		}
+	}
+	return nil
+}
+
+func readDataKeyManifest(folder storage.Folder, manifestPath string) (*DataKeyManifest, error) {
+	reader, err := folder.ReadObject(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(reader, "")
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var manifest DataKeyManifest
+	if err = json.Unmarshal(data, &manifest); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal %s", manifestPath)
+	}
+	return &manifest, nil
+}
+
+// listedDataKeys returns the data keys listed by the manifests of the backups in the folders
+func listedDataKeys(backupsFolders ...storage.Folder) (map[string]bool, error) {
+	listed := make(map[string]bool)
+	for _, backupsFolder := range backupsFolders {
+		_, backupFolders, err := backupsFolder.ListFolder()
+		if err != nil {
+			return nil, err
+		}
+		for _, backupFolder := range backupFolders {
+			manifest, err := readDataKeyManifest(backupFolder, DataKeyManifestName)
+			if _, ok := err.(storage.ObjectNotFoundError); ok {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			for _, keyID := range manifest.KeyIDs {
+				listed[keyID] = true
+			}
+		}
+	}
+	return listed, nil
+}
+
+// dataKeyCollector deletes the data keys of the deleted backups once no manifest of the remaining backups lists them.
+// The keys never listed by a manifest, e.g. generated by `st put`, are kept.
+type dataKeyCollector struct {
+	store  *FolderKeyStore
+	folder storage.Folder
+	keyIDs map[string]bool
+	// backupsFolders are the paths of the folders with the deleted manifests relative to the folder
+	backupsFolders map[string]bool
+}
+
+// newDataKeyCollector returns nil if the data key store is not configured
+func newDataKeyCollector(folder storage.Folder) *dataKeyCollector {
+	if dataKeyStore == nil {
+		return nil
+	}
+	return &dataKeyCollector{
+		store:          dataKeyStore,
+		folder:         folder,
+		keyIDs:         make(map[string]bool),
+		backupsFolders: make(map[string]bool),
+	}
+}
+
+// addBackup remembers the data keys of the backup which is about to be deleted
+func (collector *dataKeyCollector) addBackup(backupName string) {
+	collector.addManifest(path.Join(backupName, DataKeyManifestName))
+}
+
+// addManifest remembers the data keys listed by the manifest which is about to be deleted
+func (collector *dataKeyCollector) addManifest(manifestPath string) {
+	if collector == nil {
+		return
+	}
+	manifest, err := readDataKeyManifest(collector.folder, manifestPath)
+	if _, ok := err.(storage.ObjectNotFoundError); ok {
+		return
+	}
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to read %s, its data keys will be kept: %v", manifestPath, err)
+		return
+	}
+	for _, keyID := range manifest.KeyIDs {
+		collector.keyIDs[keyID] = true
+	}
+	collector.backupsFolders[path.Dir(path.Dir(manifestPath))] = true
+}
+
+// deleteUnusedKeys deletes the remembered data keys not listed by the remaining backups.
+// Nothing is deleted if any of the remaining manifests can't be read.
+func (collector *dataKeyCollector) deleteUnusedKeys() {
+	if collector == nil || len(collector.keyIDs) == 0 {
+		return
+	}
+	for backupsFolder := range collector.backupsFolders {
+		listed, err := listedDataKeys(collector.folder.GetSubFolder(backupsFolder))
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to list the data keys of the remaining backups, keeping all of them: %v", err)
+			return
+		}
+		for keyID := range listed {
+			delete(collector.keyIDs, keyID)
+		}
+	}
+	unused := make([]string, 0, len(collector.keyIDs))
+	for keyID := range collector.keyIDs {
+		unused = append(unused, keyID)
+	}
+	sort.Strings(unused)
+	unused = collector.store.unlockedKeys(collector.store.folder, dataKeyObjectNames(unused))
+	if len(unused) == 0 {
+		return
+	}
+	tracelog.InfoLogger.Printf("Deleting the data keys not used by the remaining backups: %v", unused)
+	if err := collector.store.deleteKeys(unused); err != nil {
+		tracelog.WarningLogger.Printf("Failed to delete the unused data keys: %v", err)
+	}
+}
+
+// deleteOutdatedSharedKeys deletes the shared data keys which could only be used by the deleted log objects.
+// The shared key is used for the rotation period after the creation time kept in its name, one more period is given
+// to the uploads started with it. The newest shared key and the keys listed by the backups are kept.
+// The shared keys are used by the logs of the whole storage, so only the storage root is collected,
+// and nothing is deleted if no log object is found.
+func deleteOutdatedSharedKeys(rootFolder storage.Folder) {
+	if dataKeyStore == nil || !dataKeyStore.isStorageRoot(rootFolder) {
+		return
+	}
+	rotation, err := GetDurationSetting(EnvelopeKeyRotationSetting)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to delete the outdated shared data keys: %v", err)
+		return
+	}
+	sharedKeys, err := dataKeyStore.listSharedKeys()
+	if err != nil || len(sharedKeys) < 2 {
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to list the shared data keys: %v", err)
+		}
+		return
+	}
+
+	logFolders, backupsFolders, err := listArchiveFolders(rootFolder)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to list the log folders, keeping the shared data keys: %v", err)
+		return
+	}
+	oldestLog, found, err := oldestLogObjectTime(logFolders)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to find the oldest log object, keeping the shared data keys: %v", err)
+		return
+	}
+	if !found {
+		tracelog.DebugLogger.Println("No log objects are found, keeping the shared data keys")
+		return
+	}
+	outdated := make([]sharedDataKey, 0)
+	for _, sharedKey := range sharedKeys[:len(sharedKeys)-1] {
+		if sharedKey.created.Add(2 * rotation).Before(oldestLog) {
+			outdated = append(outdated, sharedKey)
+		}
+	}
+	if len(outdated) == 0 {
+		return
+	}
+
+	listed, err := listedDataKeys(backupsFolders...)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to list the data keys of the backups, keeping the shared data keys: %v", err)
+		return
+	}
+	unused := make([]string, 0, len(outdated))
+	for _, sharedKey := range outdated {
+		if !listed[sharedKey.id] {
+			unused = append(unused, sharedKey.objectName)
+		}
+	}
+	unused = dataKeyStore.unlockedKeys(dataKeyStore.sharedFolder(), unused)
+	if len(unused) == 0 {
+		return
+	}
+	tracelog.InfoLogger.Printf("Deleting the shared data keys older than the remaining log objects: %v", unused)
+	if err = dataKeyStore.deleteSharedKeys(unused); err != nil {
+		tracelog.WarningLogger.Printf("Failed to delete the outdated shared data keys: %v", err)
+	}
+}
+
+// listArchiveFolders returns the log and the backup folders of the storage root and of the Greenplum segments
+func listArchiveFolders(rootFolder storage.Folder) (logFolders, backupsFolders []storage.Folder, err error) {
+	archiveRoots := []storage.Folder{rootFolder}
+	_, segmentFolders, err := rootFolder.GetSubFolder(utility.SegmentsPath).ListFolder()
+	if err != nil {
+		return nil, nil, err
+	}
+	archiveRoots = append(archiveRoots, segmentFolders...)
+	for _, archiveRoot := range archiveRoots {
+		for logFolderName := range logFolderNames {
+			logFolders = append(logFolders, archiveRoot.GetSubFolder(logFolderName))
+		}
+		backupsFolders = append(backupsFolders, archiveRoot.GetSubFolder(utility.BaseBackupPath))
+	}
+	return logFolders, backupsFolders, nil
+}
+
+// oldestLogObjectTime returns the modification time of the oldest object in the log folders,
+// found is false if there are none
+func oldestLogObjectTime(logFolders []storage.Folder) (oldest time.Time, found bool, err error) {
+	for _, logFolder := range logFolders {
+		err = storage.WalkFolder(context.Background(), logFolder, func(object storage.Object) error {
+			if !found || object.GetLastModified().Before(oldest) {
+				oldest = object.GetLastModified()
+				found = true
+			}
+			return nil
+		})
+		if err != nil {
+			return time.Time{}, false, err
+		}
+	}
+	return oldest, found, nil
+}
@@ -0,0 +1,293 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/crypto/envelope"
+	"github.com/apecloud/dataprotection-wal-g/internal/integrity"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/memory"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDataKeyTestFolder(t *testing.T) storage.Folder {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	ConfigureDataKeyStore(folder)
+	t.Cleanup(func() { dataKeyStore = nil })
+	return folder
+}
+
+func putTestObject(t *testing.T, folder storage.Folder, name, content string) {
+	require.NoError(t, folder.PutObject(name, strings.NewReader(content)))
+}
+
+func dataKeyExists(t *testing.T, folder storage.Folder, name string) bool {
+	exists, err := folder.GetSubFolder(utility.DataKeyPath).Exists(name + dataKeySuffix)
+	require.NoError(t, err)
+	return exists
+}
+
+func sharedDataKeyExists(t *testing.T, keyID string) bool {
+	_, err := dataKeyStore.findSharedKey(keyID)
+	if _, ok := err.(storage.ObjectNotFoundError); ok {
+		return false
+	}
+	require.NoError(t, err)
+	return true
+}
+
+func TestDeleteBackups_DeletesUnusedDataKeys(t *testing.T) {
+	folder := newDataKeyTestFolder(t)
+	for _, keyID := range []string{"aa", "bb", "cc"} {
+		require.NoError(t, dataKeyStore.PutWrappedKey(keyID, []byte("wrapped")))
+	}
+	backups := folder.GetSubFolder(utility.BaseBackupPath)
+	putTestObject(t, backups, "b1/"+DataKeyManifestName, `{"KeyIDs":["aa","bb"]}`)
+	putTestObject(t, backups, "b1/tar_partitions/part_1.tar.lz4", "data")
+	putTestObject(t, backups, "b2/"+DataKeyManifestName, `{"KeyIDs":["bb"]}`)
+	putTestObject(t, backups, "b2/tar_partitions/part_1.tar.lz4", "data")
+
+	require.NoError(t, DeleteBackups(backups, []string{"b1"}))
+	assert.False(t, dataKeyExists(t, folder, "aa"))
+	assert.True(t, dataKeyExists(t, folder, "bb"))
+
+	require.NoError(t, DeleteGarbage(backups, []string{"b2"}))
+	assert.False(t, dataKeyExists(t, folder, "bb"))
+	// the key never listed by a backup is kept
+	assert.True(t, dataKeyExists(t, folder, "cc"))
+}
+
+func TestDeleteOutdatedSharedKeys(t *testing.T) {
+	folder := newDataKeyTestFolder(t)
+	viper.Set(EnvelopeKeyRotationSetting, "1ms")
+	defer viper.Set(EnvelopeKeyRotationSetting, "24h")
+	sharedKeyExists := func(keyID string) bool {
+		return sharedDataKeyExists(t, keyID)
+	}
+
+	putTestObject(t, folder, utility.WalPath+"000000010000000000000001.lz4", "wal")
+	require.NoError(t, dataKeyStore.PutSharedKey("aa", []byte("wrapped")))
+	require.NoError(t, dataKeyStore.PutSharedKey("bb", []byte("wrapped")))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, dataKeyStore.PutSharedKey("cc", []byte("wrapped")))
+	time.Sleep(10 * time.Millisecond)
+	putTestObject(t, folder, utility.BaseBackupPath+"base_1/"+DataKeyManifestName, `{"KeyIDs":["bb"]}`)
+
+	keyID, _, _, err := dataKeyStore.GetSharedKey()
+	require.NoError(t, err)
+	assert.Equal(t, "cc", keyID)
+
+	// the old WAL segment may be encrypted with the old keys
+	deleteOutdatedSharedKeys(folder)
+	assert.True(t, sharedKeyExists("aa"))
+
+	require.NoError(t, folder.DeleteObjects([]string{utility.WalPath + "000000010000000000000001.lz4"}))
+	putTestObject(t, folder, utility.WalPath+"000000010000000000000002.lz4", "wal")
+	deleteOutdatedSharedKeys(folder)
+	assert.False(t, sharedKeyExists("aa"))
+	// listed by the backup
+	assert.True(t, sharedKeyExists("bb"))
+	// the newest shared key is kept
+	assert.True(t, sharedKeyExists("cc"))
+
+	wrappedKey, err := dataKeyStore.GetWrappedKey("cc")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("wrapped"), wrappedKey)
+}
+
+func TestDeleteOutdatedSharedKeys_KeepsKeysOfSegmentWal(t *testing.T) {
+	folder := newDataKeyTestFolder(t)
+	viper.Set(EnvelopeKeyRotationSetting, "1ms")
+	defer viper.Set(EnvelopeKeyRotationSetting, "24h")
+	sharedKeyExists := func(keyID string) bool {
+		return sharedDataKeyExists(t, keyID)
+	}
+	segmentWalPath := utility.SegmentsPath + "seg0/" + utility.WalPath
+
+	// the WAL of the Greenplum segments is kept outside the root WAL folder
+	putTestObject(t, folder, segmentWalPath+"000000010000000000000001.lz4", "wal")
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, dataKeyStore.PutSharedKey("aa", []byte("wrapped")))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, dataKeyStore.PutSharedKey("bb", []byte("wrapped")))
+	time.Sleep(10 * time.Millisecond)
+	deleteOutdatedSharedKeys(folder)
+	assert.True(t, sharedKeyExists("aa"))
+
+	// no log objects are found, so their age is unknown
+	require.NoError(t, folder.DeleteObjects([]string{segmentWalPath + "000000010000000000000001.lz4"}))
+	deleteOutdatedSharedKeys(folder)
+	assert.True(t, sharedKeyExists("aa"))
+
+	// only the storage root is collected
+	putTestObject(t, folder, segmentWalPath+"000000010000000000000002.lz4", "wal")
+	deleteOutdatedSharedKeys(folder.GetSubFolder(utility.SegmentsPath + "seg0/"))
+	assert.True(t, sharedKeyExists("aa"))
+	deleteOutdatedSharedKeys(folder)
+	assert.False(t, sharedKeyExists("aa"))
+	assert.True(t, sharedKeyExists("bb"))
+}
+
+// keyLockingFolder keeps the object locks of the in-memory folder by the full object paths,
+// the new version of the object is not locked as on the versioned storage
+type keyLockingFolder struct {
+	*memory.Folder
+	locks map[string]storage.ObjectLock
+}
+
+func (f *keyLockingFolder) PutObject(name string, content io.Reader) error {
+	delete(f.locks, storage.JoinPath(f.GetPath(), name))
+	return f.Folder.PutObject(name, content)
+}
+
+func (f *keyLockingFolder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return &keyLockingFolder{Folder: f.Folder.GetSubFolder(subFolderRelativePath).(*memory.Folder), locks: f.locks}
+}
+
+func (f *keyLockingFolder) SetObjectLock(_ context.Context, objectRelativePath string, lock storage.ObjectLock) error {
+	f.locks[storage.JoinPath(f.GetPath(), objectRelativePath)] = lock
+	return nil
+}
+
+func (f *keyLockingFolder) GetObjectLock(_ context.Context, objectRelativePath string) (storage.ObjectLock, error) {
+	return f.locks[storage.JoinPath(f.GetPath(), objectRelativePath)], nil
+}
+
+func TestLockDataKeys_KeepsKeysOfLockedBackups(t *testing.T) {
+	viper.Set(DeleteCheckObjectLockSetting, "true")
+	defer viper.Set(DeleteCheckObjectLockSetting, "false")
+	folder := &keyLockingFolder{Folder: memory.NewFolder("in_memory/", memory.NewStorage()), locks: map[string]storage.ObjectLock{}}
+	ConfigureDataKeyStore(folder)
+	t.Cleanup(func() { dataKeyStore = nil })
+	require.NoError(t, dataKeyStore.PutWrappedKey("aa", []byte("wrapped")))
+	require.NoError(t, dataKeyStore.PutSharedKey("ss", []byte("wrapped")))
+
+	lock := storage.ObjectLock{Mode: storage.ObjectLockModeCompliance, RetainUntil: time.Now().Add(time.Hour)}
+	require.NoError(t, lockDataKeys([]string{"aa", "ss"}, lock))
+	assert.Equal(t, lock, folder.locks["in_memory/"+utility.DataKeyPath+"aa.key"])
+	sharedKey, err := dataKeyStore.findSharedKey("ss")
+	require.NoError(t, err)
+	assert.Equal(t, lock, folder.locks["in_memory/"+utility.DataKeyPath+sharedDataKeyPath+sharedKey.objectName])
+
+	// the shorter lock of the other backup does not shorten the lock of the key
+	require.NoError(t, lockDataKeys([]string{"aa"},
+		storage.ObjectLock{Mode: storage.ObjectLockModeCompliance, RetainUntil: time.Now().Add(time.Minute)}))
+	assert.Equal(t, lock, folder.locks["in_memory/"+utility.DataKeyPath+"aa.key"])
+
+	backups := folder.GetSubFolder(utility.BaseBackupPath)
+	putTestObject(t, backups, "b1/"+DataKeyManifestName, `{"KeyIDs":["aa"]}`)
+	require.NoError(t, DeleteBackups(backups, []string{"b1"}))
+	assert.True(t, dataKeyExists(t, folder, "aa"))
+	assert.Empty(t, dataKeyStore.unlockedKeys(dataKeyStore.sharedFolder(), []string{sharedKey.objectName}))
+}
+
+// plainMasterCrypter wraps the data keys as is
+type plainMasterCrypter struct{}
+
+type plainWriteCloser struct {
+	io.Writer
+}
+
+func (plainWriteCloser) Close() error {
+	return nil
+}
+
+func (plainMasterCrypter) Name() string {
+	return "plain"
+}
+
+func (plainMasterCrypter) Encrypt(writer io.Writer) (io.WriteCloser, error) {
+	return plainWriteCloser{writer}, nil
+}
+
+func (plainMasterCrypter) Decrypt(reader io.Reader) (io.Reader, error) {
+	return reader, nil
+}
+
+func TestRewrapDataKeys_KeepsKeyLock(t *testing.T) {
+	folder := &keyLockingFolder{Folder: memory.NewFolder("in_memory/", memory.NewStorage()), locks: map[string]storage.ObjectLock{}}
+	store := NewFolderKeyStore(folder)
+	wrappedKey, err := envelope.WrapKey(plainMasterCrypter{}, []byte("key"))
+	require.NoError(t, err)
+	require.NoError(t, store.PutWrappedKey("aa", wrappedKey))
+	require.NoError(t, store.PutWrappedKey("bb", wrappedKey))
+	lock := storage.ObjectLock{Mode: storage.ObjectLockModeCompliance, RetainUntil: time.Now().Add(time.Hour)}
+	require.NoError(t, store.extendKeyLock("aa", lock))
+
+	rewrapped, err := RewrapDataKeys(folder, plainMasterCrypter{}, plainMasterCrypter{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, rewrapped)
+	assert.Equal(t, lock, folder.locks["in_memory/"+utility.DataKeyPath+"aa.key"])
+	assert.NotContains(t, folder.locks, "in_memory/"+utility.DataKeyPath+"bb.key")
+}
+
+func TestVerifyBackupIntegrity_EnvelopeEncryptedBackup(t *testing.T) {
+	viper.Set(ManifestHMACKeySetting, "secret")
+	defer viper.Set(ManifestHMACKeySetting, "")
+	baseBackupFolder := newDataKeyTestFolder(t).GetSubFolder(utility.BaseBackupPath)
+	uploader, err := ConfigureUploaderToFolder(baseBackupFolder)
+	require.NoError(t, err)
+	backupName := "base_000000010000000000000002"
+
+	var encrypted bytes.Buffer
+	writer, err := envelope.NewCrypter(plainMasterCrypter{}).Encrypt(&encrypted)
+	require.NoError(t, err)
+	_, err = writer.Write([]byte("backup data"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, uploader.Upload(backupName+"/tar_partitions/part_1.tar.br", &encrypted))
+	// the objects uploaded as DTOs, e.g. the Greenplum AO files metadata, are listed in the manifest too
+	require.NoError(t, UploadBackupDto(uploader, map[string]string{"a": "b"}, backupName+"/ao_files_metadata.json"))
+	require.NoError(t, UploadSentinel(uploader, map[string]string{}, backupName))
+
+	exists, err := baseBackupFolder.Exists(backupName + "/" + DataKeyManifestName)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	result, err := VerifyBackupIntegrity(baseBackupFolder, backupName, integrity.HMACSigner{Key: []byte("secret")})
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Checked)
+	assert.Empty(t, result.Problems)
+}
+
+func TestRewrapDataKeys_KeepsSharedKeyCreationTime(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	store := NewFolderKeyStore(folder)
+	wrappedKey, err := envelope.WrapKey(plainMasterCrypter{}, []byte("key"))
+	require.NoError(t, err)
+	require.NoError(t, store.PutSharedKey("ss", wrappedKey))
+	_, _, created, err := store.GetSharedKey()
+	require.NoError(t, err)
+	time.Sleep(10 * time.Millisecond)
+
+	rewrapped, err := RewrapDataKeys(folder, plainMasterCrypter{}, plainMasterCrypter{})
+	require.NoError(t, err)
+	assert.Equal(t, 1, rewrapped)
+	keyID, _, rewrappedCreated, err := store.GetSharedKey()
+	require.NoError(t, err)
+	assert.Equal(t, "ss", keyID)
+	assert.True(t, created.Equal(rewrappedCreated), "%s != %s", created, rewrappedCreated)
+}
+
+func TestGetSharedKey_KeyWithoutCreationTimeInName(t *testing.T) {
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	store := NewFolderKeyStore(folder)
+	require.NoError(t, store.sharedFolder().PutObject("aa"+dataKeySuffix, bytes.NewReader([]byte("old"))))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, store.PutSharedKey("bb", []byte("new")))
+
+	keyID, wrappedKey, _, err := store.GetSharedKey()
+	require.NoError(t, err)
+	assert.Equal(t, "bb", keyID)
+	assert.Equal(t, []byte("new"), wrappedKey)
+	wrappedKey, err = store.GetWrappedKey("aa")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("old"), wrappedKey)
+}
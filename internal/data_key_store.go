@@ -0,0 +1,315 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/crypto"
+	"github.com/apecloud/dataprotection-wal-g/internal/crypto/envelope"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	dataKeySuffix = ".key"
+	// sharedDataKeyPath keeps the data keys shared by the log uploads, the backup keys are kept in the root
+	sharedDataKeyPath = "shared/"
+	// sharedKeyTimeFormat is the creation time in the shared key name, it is sorted lexicographically
+	sharedKeyTimeFormat = "20060102T150405.000000Z"
+)
+
+// dataKeyStore is the key store of the configured storage
+var dataKeyStore *FolderKeyStore
+
+// FolderKeyStore keeps the wrapped data keys of the envelope encryption in the storage root
+type FolderKeyStore struct {
+	rootFolder storage.Folder
+	folder     storage.Folder
+}
+
+var _ envelope.KeyStore = &FolderKeyStore{}
+
+// sharedDataKey is the shared data key object named <keyID>_<created>.key. The creation time is kept
+// in the name since re-wrapping rewrites the key and resets its modification time.
+type sharedDataKey struct {
+	id         string
+	objectName string
+	created    time.Time
+}
+
+func NewFolderKeyStore(rootFolder storage.Folder) *FolderKeyStore {
+	return &FolderKeyStore{rootFolder: rootFolder, folder: rootFolder.GetSubFolder(utility.DataKeyPath)}
+}
+
+func (store *FolderKeyStore) PutWrappedKey(keyID string, wrappedKey []byte) error {
+	return store.folder.PutObject(keyID+dataKeySuffix, bytes.NewReader(wrappedKey))
+}
+
+func (store *FolderKeyStore) GetWrappedKey(keyID string) ([]byte, error) {
+	wrappedKey, err := readDataKey(store.folder, keyID+dataKeySuffix)
+	if _, ok := err.(storage.ObjectNotFoundError); ok {
+		sharedKey, err := store.findSharedKey(keyID)
+		if err != nil {
+			return nil, err
+		}
+		return readDataKey(store.sharedFolder(), sharedKey.objectName)
+	}
+	return wrappedKey, err
+}
+
+func (store *FolderKeyStore) PutSharedKey(keyID string, wrappedKey []byte) error {
+	created := utility.TimeNowCrossPlatformUTC().Format(sharedKeyTimeFormat)
+	return store.sharedFolder().PutObject(keyID+"_"+created+dataKeySuffix, bytes.NewReader(wrappedKey))
+}
+
+func (store *FolderKeyStore) GetSharedKey() (keyID string, wrappedKey []byte, created time.Time, err error) {
+	sharedKeys, err := store.listSharedKeys()
+	if err != nil || len(sharedKeys) == 0 {
+		return "", nil, time.Time{}, err
+	}
+	newest := sharedKeys[len(sharedKeys)-1]
+	wrappedKey, err = readDataKey(store.sharedFolder(), newest.objectName)
+	return newest.id, wrappedKey, newest.created, err
+}
+
+// listSharedKeys returns the shared data keys from the oldest to the newest one
+func (store *FolderKeyStore) listSharedKeys() ([]sharedDataKey, error) {
+	sharedKeys, err := store.iterateSharedKeys(storage.ListOptions{})
+	sort.Slice(sharedKeys, func(i, j int) bool {
+		return sharedKeys[i].created.Before(sharedKeys[j].created)
+	})
+	return sharedKeys, err
+}
+
+func (store *FolderKeyStore) findSharedKey(keyID string) (sharedDataKey, error) {
+	sharedKeys, err := store.iterateSharedKeys(storage.ListOptions{Prefix: keyID})
+	if err != nil {
+		return sharedDataKey{}, err
+	}
+	for _, sharedKey := range sharedKeys {
+		if sharedKey.id == keyID {
+			return sharedKey, nil
+		}
+	}
+	return sharedDataKey{}, storage.NewObjectNotFoundError(storage.JoinPath(store.sharedFolder().GetPath(), keyID+dataKeySuffix))
+}
+
+func (store *FolderKeyStore) iterateSharedKeys(options storage.ListOptions) ([]sharedDataKey, error) {
+	sharedKeys := make([]sharedDataKey, 0)
+	err := storage.IterateObjects(context.Background(), store.sharedFolder(), options,
+		func(object storage.Object) error {
+			if sharedKey, ok := parseSharedKey(object); ok {
+				sharedKeys = append(sharedKeys, sharedKey)
+			}
+			return nil
+		})
+	return sharedKeys, err
+}
+
+// parseSharedKey reads the key ID and the creation time from the shared key name,
+// the keys named without the creation time are created at their modification time
+func parseSharedKey(object storage.Object) (sharedDataKey, bool) {
+	name, ok := strings.CutSuffix(object.GetName(), dataKeySuffix)
+	if !ok {
+		return sharedDataKey{}, false
+	}
+	sharedKey := sharedDataKey{id: name, objectName: object.GetName(), created: object.GetLastModified()}
+	if keyID, createdName, found := strings.Cut(name, "_"); found {
+		if created, err := time.Parse(sharedKeyTimeFormat, createdName); err == nil {
+			sharedKey.id, sharedKey.created = keyID, created
+		}
+	}
+	return sharedKey, true
+}
+
+// deleteKeys deletes the backup data key objects, the shared keys are kept
+func (store *FolderKeyStore) deleteKeys(objectNames []string) error {
+	return store.folder.DeleteObjects(objectNames)
+}
+
+func (store *FolderKeyStore) deleteSharedKeys(objectNames []string) error {
+	return store.sharedFolder().DeleteObjects(objectNames)
+}
+
+// extendKeyLock locks the data key at least until the lock expires, the longer lock of the key is kept
+func (store *FolderKeyStore) extendKeyLock(keyID string, lock storage.ObjectLock) error {
+	ctx := context.Background()
+	keyFolder, objectName := store.folder, keyID+dataKeySuffix
+	exists, err := keyFolder.Exists(objectName)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		sharedKey, err := store.findSharedKey(keyID)
+		if err != nil {
+			return err
+		}
+		keyFolder, objectName = store.sharedFolder(), sharedKey.objectName
+	}
+	currentLock, err := storage.GetObjectLock(ctx, keyFolder, objectName)
+	if err != nil {
+		return err
+	}
+	extended := currentLock
+	extended.LegalHold = currentLock.LegalHold || lock.LegalHold
+	if extended.Mode == storage.ObjectLockModeNone {
+		extended.Mode = lock.Mode
+	}
+	if lock.RetainUntil.After(extended.RetainUntil) {
+		extended.RetainUntil = lock.RetainUntil
+	}
+	if extended.Mode == currentLock.Mode && extended.LegalHold == currentLock.LegalHold &&
+		extended.RetainUntil.Equal(currentLock.RetainUntil) {
+		return nil
+	}
+	tracelog.DebugLogger.Printf("Locking data key %s: %s", keyID, extended)
+	return storage.SetObjectLock(ctx, keyFolder, objectName, extended)
+}
+
+// unlockedKeys drops the data key objects locked by the storage from the list, they are still used by the locked backups.
+// The locks are checked only if delete should look for the locked objects.
+func (store *FolderKeyStore) unlockedKeys(keyFolder storage.Folder, objectNames []string) []string {
+	if !isObjectLockCheckEnabled() {
+		return objectNames
+	}
+	lockFilter := newLockedObjectsFilter(keyFolder)
+	unlocked := make([]string, 0, len(objectNames))
+	for _, objectName := range objectNames {
+		if !lockFilter.isLockedObject(objectName) {
+			unlocked = append(unlocked, objectName)
+		}
+	}
+	lockFilter.report()
+	return unlocked
+}
+
+// isStorageRoot checks if the folder is the storage root the keys are kept in
+func (store *FolderKeyStore) isStorageRoot(folder storage.Folder) bool {
+	return folder.GetPath() == store.rootFolder.GetPath()
+}
+
+func (store *FolderKeyStore) sharedFolder() storage.Folder {
+	return store.folder.GetSubFolder(sharedDataKeyPath)
+}
+
+func readDataKey(folder storage.Folder, objectName string) ([]byte, error) {
+	reader, err := folder.ReadObject(objectName)
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(reader, "")
+	return io.ReadAll(reader)
+}
+
+func dataKeyObjectNames(keyIDs []string) []string {
+	names := make([]string, 0, len(keyIDs))
+	for _, keyID := range keyIDs {
+		names = append(names, keyID+dataKeySuffix)
+	}
+	return names
+}
+
+// IsDataKeyObject reports whether the object path relative to the storage root is the data key,
+// the copied backups can't be decrypted without their data keys
+func IsDataKeyObject(objectPath string) bool {
+	return strings.HasPrefix(objectPath, utility.DataKeyPath)
+}
+
+// ConfigureDataKeyStore makes the envelope encryption keep the data keys in the folder
+func ConfigureDataKeyStore(folder storage.Folder) {
+	dataKeyStore = NewFolderKeyStore(folder)
+	envelope.SetKeyStore(dataKeyStore)
+}
+
+// ConfigureSharedDataKeys makes the log uploads of the process encrypt with the shared data key,
+// which is rotated every WALG_ENVELOPE_KEY_ROTATION_PERIOD
+func ConfigureSharedDataKeys() error {
+	if !viper.GetBool(EnvelopeEncryptionSetting) {
+		return nil
+	}
+	rotation, err := GetDurationSetting(EnvelopeKeyRotationSetting)
+	if err != nil {
+		return err
+	}
+	if rotation <= 0 {
+		return fmt.Errorf("%s must be positive, got %s", EnvelopeKeyRotationSetting, rotation)
+	}
+	envelope.ShareDataKeys(rotation)
+	return nil
+}
+
+// RewrapDataKeys re-encrypts every data key stored in the folder with the new master key.
+// The objects encrypted with the data keys are not touched. The keys already wrapped with the new master key
+// are skipped if it is able to decrypt them, so the interrupted run can be repeated.
+// The object lock of the key is re-applied to its new version. The locked old versions kept by the storage
+// still hold the keys wrapped with the old master key.
+func RewrapDataKeys(folder storage.Folder, oldMaster, newMaster crypto.Crypter) (rewrapped int, err error) {
+	store := NewFolderKeyStore(folder)
+	for _, keyFolder := range []storage.Folder{store.folder, store.sharedFolder()} {
+		err = storage.IterateObjects(context.Background(), keyFolder, storage.ListOptions{},
+			func(object storage.Object) error {
+				keyID, ok := strings.CutSuffix(object.GetName(), dataKeySuffix)
+				if !ok {
+					return nil
+				}
+				wrappedKey, err := readDataKey(keyFolder, object.GetName())
+				if err != nil {
+					return errors.Wrapf(err, "failed to read data key %s", keyID)
+				}
+				key, err := envelope.UnwrapKey(oldMaster, wrappedKey)
+				if err != nil {
+					// the key may be already re-wrapped by the interrupted previous run
+					if _, newErr := envelope.UnwrapKey(newMaster, wrappedKey); newErr == nil {
+						tracelog.InfoLogger.Printf("Data key %s is already wrapped with the new master key", keyID)
+						return nil
+					}
+					return errors.Wrapf(err, "failed to unwrap data key %s", keyID)
+				}
+				wrappedKey, err = envelope.WrapKey(newMaster, key)
+				if err != nil {
+					return errors.Wrapf(err, "failed to wrap data key %s", keyID)
+				}
+				err = rewriteDataKey(keyFolder, object.GetName(), wrappedKey)
+				if err != nil {
+					return err
+				}
+				tracelog.DebugLogger.Printf("Re-wrapped data key %s", keyID)
+				rewrapped++
+				return nil
+			})
+		if err != nil {
+			return rewrapped, err
+		}
+	}
+	return rewrapped, nil
+}
+
+// rewriteDataKey replaces the data key object keeping its name and object lock, the new version of the locked object
+// is not locked by the storage
+func rewriteDataKey(keyFolder storage.Folder, objectName string, wrappedKey []byte) error {
+	ctx := context.Background()
+	lock, err := storage.GetObjectLock(ctx, keyFolder, objectName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to get the lock of data key %s", objectName)
+	}
+	err = keyFolder.PutObject(objectName, bytes.NewReader(wrappedKey))
+	if err != nil {
+		return errors.Wrapf(err, "failed to store data key %s", objectName)
+	}
+	if lock == (storage.ObjectLock{}) {
+		return nil
+	}
+	err = storage.SetObjectLock(ctx, keyFolder, objectName, lock)
+	if err != nil {
+		return errors.Wrapf(err, "failed to re-apply the lock (%s) of data key %s", lock, objectName)
+	}
+	return nil
+}
@@ -21,6 +21,8 @@ type BackupAOFileDesc struct {
 	ModCount      int64          `json:"ModCount,omitempty"`
 	Compressor    string         `json:"Compressor,omitempty"`
 	FileMode      int64          `json:"FileMode"`
+	// DataKeyIDs are the envelope encryption data keys of the file and its incremental base
+	DataKeyIDs []string `json:"DataKeyIDs,omitempty"`
 }
 
 type AOFilesMetadataDTO struct {
@@ -34,7 +36,7 @@ func NewAOFilesMetadataDTO() *AOFilesMetadataDTO {
 }
 
 func (m *AOFilesMetadataDTO) addFile(key, storagePath string, mTime time.Time, aoMeta AoRelFileMetadata,
-	fileMode int64, isSkipped, isIncremented bool) {
+	fileMode int64, isSkipped, isIncremented bool, dataKeyIDs []string) {
 	m.Files[key] = BackupAOFileDesc{
 		StoragePath:   storagePath,
 		IsSkipped:     isSkipped,
@@ -44,5 +46,21 @@ func (m *AOFilesMetadataDTO) addFile(key, storagePath string, mTime time.Time, a
 		StorageType:   aoMeta.storageType,
 		FileMode:      fileMode,
 		ModCount:      aoMeta.modCount,
+		DataKeyIDs:    dataKeyIDs,
 	}
 }
+
+// dataKeyIDs returns the data keys of all the files, the skipped ones were encrypted by the previous backups
+func (m *AOFilesMetadataDTO) dataKeyIDs() []string {
+	seen := make(map[string]bool)
+	keyIDs := make([]string, 0)
+	for _, file := range m.Files {
+		for _, keyID := range file.DataKeyIDs {
+			if !seen[keyID] {
+				seen[keyID] = true
+				keyIDs = append(keyIDs, keyID)
+			}
+		}
+	}
+	return keyIDs
+}
@@ -79,7 +79,7 @@ func (u *AoStorageUploader) addFile(cfi *internal.ComposeFileInfo, aoMeta AoRelF
 			"%s: EOF (local %d, remote %d), ModCount (local %d, remote %d), will perform an incremental upload",
 			cfi.Header.Name, aoMeta.eof, remoteFile.EOF, aoMeta.modCount, remoteFile.ModCount)
 
-		err := u.incrementalAoUpload(remoteFile.StoragePath, cfi, aoMeta, remoteFile.EOF)
+		err := u.incrementalAoUpload(remoteFile.StoragePath, remoteFile.DataKeyIDs, cfi, aoMeta, remoteFile.EOF)
 		if err == nil {
 			return nil
 		}
@@ -99,22 +99,38 @@ func (u *AoStorageUploader) addFile(cfi *internal.ComposeFileInfo, aoMeta AoRelF
 	tracelog.DebugLogger.Printf(
 		"%s: ModCount %d, EOF %d matches the remote file %s, will skip this file",
 		cfi.Header.Name, remoteFile.ModCount, remoteFile.EOF, remoteFile.StoragePath)
-	return u.skipAoUpload(cfi, aoMeta, remoteFile.StoragePath)
+	return u.skipAoUpload(cfi, aoMeta, remoteFile.StoragePath, remoteFile.DataKeyIDs)
 }
 
-func (u *AoStorageUploader) addAoFileMetadata(
-	cfi *internal.ComposeFileInfo, storageKey string, aoMeta AoRelFileMetadata, isSkipped, isIncremented bool) {
+func (u *AoStorageUploader) addAoFileMetadata(cfi *internal.ComposeFileInfo, storageKey string,
+	aoMeta AoRelFileMetadata, isSkipped, isIncremented bool, dataKeyIDs []string) {
 	u.metaMutex.Lock()
-	u.meta.addFile(cfi.Header.Name, storageKey, cfi.FileInfo.ModTime(), aoMeta, cfi.Header.Mode, isSkipped, isIncremented)
+	u.meta.addFile(cfi.Header.Name, storageKey, cfi.FileInfo.ModTime(), aoMeta, cfi.Header.Mode,
+		isSkipped, isIncremented, dataKeyIDs)
 	u.metaMutex.Unlock()
 }
 
+// currentDataKeyIDs returns the data key the files are encrypted with, if the envelope encryption is used
+func (u *AoStorageUploader) currentDataKeyIDs() []string {
+	keyCrypter, ok := u.crypter.(interface{ CurrentKeyID() (string, error) })
+	if !ok {
+		return nil
+	}
+	keyID, err := keyCrypter.CurrentKeyID()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to get the current data key: %v", err)
+		return nil
+	}
+	return []string{keyID}
+}
+
 func (u *AoStorageUploader) GetFiles() *AOFilesMetadataDTO {
 	return u.meta
 }
 
-func (u *AoStorageUploader) skipAoUpload(cfi *internal.ComposeFileInfo, aoMeta AoRelFileMetadata, storageKey string) error {
-	u.addAoFileMetadata(cfi, storageKey, aoMeta, true, false)
+func (u *AoStorageUploader) skipAoUpload(cfi *internal.ComposeFileInfo, aoMeta AoRelFileMetadata, storageKey string,
+	dataKeyIDs []string) error {
+	u.addAoFileMetadata(cfi, storageKey, aoMeta, true, false, dataKeyIDs)
 	u.bundleFiles.AddSkippedFile(cfi.Header, cfi.FileInfo)
 	tracelog.DebugLogger.Printf("Skipping %s AO relfile (already exists in storage as %s)", cfi.Path, storageKey)
 	return nil
@@ -142,13 +158,13 @@ func (u *AoStorageUploader) regularAoUpload(
 		return err
 	}
 
-	u.addAoFileMetadata(cfi, storageKey, aoMeta, false, false)
+	u.addAoFileMetadata(cfi, storageKey, aoMeta, false, false, u.currentDataKeyIDs())
 	u.bundleFiles.AddFile(cfi.Header, cfi.FileInfo, false)
 	return nil
 }
 
 func (u *AoStorageUploader) incrementalAoUpload(
-	baseFileStorageKey string,
+	baseFileStorageKey string, baseFileDataKeyIDs []string,
 	cfi *internal.ComposeFileInfo, aoMeta AoRelFileMetadata, baseFileEOF int64) error {
 	storageKey := makeDeltaAoFileStorageKey(baseFileStorageKey, aoMeta.modCount)
 	tracelog.DebugLogger.Printf("Uploading %s AO relfile delta to %s", cfi.Path, storageKey)
@@ -169,7 +185,8 @@ func (u *AoStorageUploader) incrementalAoUpload(
 		return err
 	}
 
-	u.addAoFileMetadata(cfi, storageKey, aoMeta, false, true)
+	dataKeyIDs := append(append([]string{}, baseFileDataKeyIDs...), u.currentDataKeyIDs()...)
+	u.addAoFileMetadata(cfi, storageKey, aoMeta, false, true, dataKeyIDs)
 	u.bundleFiles.AddFile(cfi.Header, cfi.FileInfo, true)
 	return nil
 }
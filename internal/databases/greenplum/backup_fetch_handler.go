@@ -2,17 +2,22 @@ package greenplum
 
 import (
 	"fmt"
+	"os"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/apecloud/dataprotection-wal-g/internal"
 	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
 	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 )
 
+const SegBackupFetchCmdName = "seg-backup-fetch"
+
 type BackupFetchMode string
 
 const (
@@ -52,6 +57,10 @@ type FetchHandler struct {
 	contentIDsToFetch   map[int]bool
 	fetchMode           BackupFetchMode
 	restorePoint        string
+	// agentClient dispatches the segment commands to the segment agents, the SSH is used if it is nil
+	agentClient     *SegmentAgentClient
+	segPollInterval time.Duration
+	segPollRetries  int
 }
 
 // nolint:gocritic
@@ -84,6 +93,12 @@ func NewFetchHandler(
 	globalCluster := cluster.NewCluster(segmentConfigs)
 	tracelog.DebugLogger.Printf("cluster %v\n", globalCluster)
 
+	agentClient, err := ConfigureSegmentAgentClient()
+	tracelog.ErrorLogger.FatalOnError(err)
+	segPollInterval, err := internal.GetDurationSetting(internal.GPSegmentsPollInterval)
+	tracelog.ErrorLogger.FatalOnError(err)
+	segPollRetries := viper.GetInt(internal.GPSegmentsPollRetries)
+
 	return &FetchHandler{
 		cluster:             globalCluster,
 		backupIDByContentID: backupIDByContentID,
@@ -91,6 +106,9 @@ func NewFetchHandler(
 		contentIDsToFetch:   prepareContentIDsToFetch(fetchContentIds, segmentConfigs),
 		fetchMode:           mode,
 		restorePoint:        restorePoint,
+		agentClient:         agentClient,
+		segPollInterval:     segPollInterval,
+		segPollRetries:      segPollRetries,
 	}
 }
 
@@ -114,7 +132,13 @@ func prepareContentIDsToFetch(fetchContentIds []int, segmentConfigs []cluster.Se
 
 func (fh *FetchHandler) Fetch() error {
 	if fh.fetchMode == DefaultFetchMode || fh.fetchMode == UnpackFetchMode {
-		fh.Unpack()
+		if fh.agentClient != nil {
+			if err := fh.unpackWithAgent(); err != nil {
+				return err
+			}
+		} else {
+			fh.Unpack()
+		}
 	}
 
 	if fh.fetchMode == DefaultFetchMode || fh.fetchMode == PrepareFetchMode {
@@ -143,6 +167,61 @@ func (fh *FetchHandler) Unpack() {
 	}
 }
 
+// unpackWithAgent restores the segments in parallel via the segment agents and waits for all of them
+func (fh *FetchHandler) unpackWithAgent() error {
+	tracelog.InfoLogger.Println("[Unpack] Running wal-g on segments and master via the segment agents...")
+	segments := fh.segmentsToFetch()
+	err := fh.agentClient.RunOnSegments(segments, func(segment *cluster.SegConfig) error {
+		return fh.agentClient.StartCommand(segment, SegmentAgentCommand{
+			Name:   SegBackupFetchCmdName,
+			Args:   fh.buildFetchArgs(segment.ContentID),
+			PgPort: segment.Port,
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("unable to run wal-g: %w", err)
+	}
+
+	ticker := time.NewTicker(fh.segPollInterval)
+	defer ticker.Stop()
+	retryCount := fh.segPollRetries
+	for {
+		<-ticker.C
+		states, err := fh.agentClient.PollCommandStates(segments, SegBackupFetchCmdName)
+		if err != nil {
+			if retryCount == 0 {
+				return fmt.Errorf("gave up polling the backup-fetch states (tried %d times): %v", fh.segPollRetries, err)
+			}
+			retryCount--
+			tracelog.WarningLogger.Printf("failed to poll segment backup-fetch states, will try again %d more times: %v",
+				retryCount, err)
+			continue
+		}
+		retryCount = fh.segPollRetries
+
+		running := 0
+		for contentID, state := range states {
+			tracelog.InfoLogger.Printf("content ID: %d, status: %s, ts: %s", contentID, state.Status, state.TS)
+			switch state.Status {
+			case RunningCmdStatus:
+				running++
+			case FailedCmdStatus, InterruptedCmdStatus:
+				return fmt.Errorf("unexpected backup-fetch status: %s on segment %d at %s", state.Status, contentID, state.TS)
+			}
+		}
+		if running == 0 {
+			tracelog.InfoLogger.Println("[Unpack] All segments are restored")
+			return nil
+		}
+	}
+}
+
+func (fh *FetchHandler) segmentsToFetch() []*cluster.SegConfig {
+	return primarySegments(fh.cluster, func(contentID int) bool {
+		return fh.contentIDsToFetch[contentID]
+	})
+}
+
 func (fh *FetchHandler) Prepare() error {
 	tracelog.InfoLogger.Println("[Prepare] Updating pg_hba configs on segments...")
 	err := fh.createPgHbaOnSegments()
@@ -162,6 +241,12 @@ func (fh *FetchHandler) createPgHbaOnSegments() error {
 		return err
 	}
 
+	if fh.agentClient != nil {
+		return fh.agentClient.RunOnSegments(fh.segmentsToFetch(), func(segment *cluster.SegConfig) error {
+			return fh.agentClient.WriteConfig(segment, "pg_hba.conf", fileContents)
+		})
+	}
+
 	remoteOutput := fh.cluster.GenerateAndExecuteCommand("Updating pg_hba on segments",
 		cluster.ON_SEGMENTS|cluster.EXCLUDE_MIRRORS|cluster.INCLUDE_MASTER,
 		func(contentID int) string {
@@ -198,6 +283,12 @@ func (fh *FetchHandler) createRecoveryConfigs() error {
 	tracelog.InfoLogger.Printf("Recovery target is %s", recoveryTarget)
 	restoreCfgMaker := NewRecoveryConfigMaker("/usr/bin/wal-g", internal.CfgFile, recoveryTarget)
 
+	if fh.agentClient != nil {
+		return fh.agentClient.RunOnSegments(fh.segmentsToFetch(), func(segment *cluster.SegConfig) error {
+			return fh.agentClient.WriteConfig(segment, "recovery.conf", restoreCfgMaker.Make(segment.ContentID))
+		})
+	}
+
 	remoteOutput := fh.cluster.GenerateAndExecuteCommand("Creating recovery.conf on segments and master",
 		cluster.ON_SEGMENTS|cluster.EXCLUDE_MIRRORS|cluster.INCLUDE_MASTER,
 		func(contentID int) string {
@@ -232,16 +323,10 @@ func (fh *FetchHandler) buildFetchCommand(contentID int) string {
 	}
 
 	segment := fh.cluster.ByContent[contentID][0]
-	backupID, ok := fh.backupIDByContentID[contentID]
-	if !ok {
-		// this should never happen
-		tracelog.ErrorLogger.Fatalf("Failed to load backup id by content id %d", contentID)
-	}
-
-	segUserData := NewSegmentUserDataFromID(backupID)
+	segUserData := NewSegmentUserDataFromID(fh.getBackupID(contentID))
 	cmd := []string{
 		fmt.Sprintf("PGPORT=%d", segment.Port),
-		"wal-g seg-backup-fetch",
+		"wal-g " + SegBackupFetchCmdName,
 		fmt.Sprint(segment.DataDir),
 		fmt.Sprintf("--content-id=%d", segment.ContentID),
 		fmt.Sprintf("--target-user-data=%s", segUserData.QuotedString()),
@@ -255,6 +340,26 @@ func (fh *FetchHandler) buildFetchCommand(contentID int) string {
 	return cmdLine
 }
 
+// buildFetchArgs creates the seg-backup-fetch arguments for the segment agent. The agent passes them
+// to the command as a JSON array without a shell, so the arguments are not quoted.
+func (fh *FetchHandler) buildFetchArgs(contentID int) []string {
+	segment := fh.cluster.ByContent[contentID][0]
+	segUserData := NewSegmentUserDataFromID(fh.getBackupID(contentID))
+	return []string{
+		segment.DataDir,
+		fmt.Sprintf("--target-user-data=%s", segUserData.String()),
+	}
+}
+
+func (fh *FetchHandler) getBackupID(contentID int) string {
+	backupID, ok := fh.backupIDByContentID[contentID]
+	if !ok {
+		// this should never happen
+		tracelog.ErrorLogger.Fatalf("Failed to load backup id by content id %d", contentID)
+	}
+	return backupID
+}
+
 func NewGreenplumBackupFetcher(restoreCfgPath string, inPlaceRestore bool, logsDir string,
 	fetchContentIds []int, mode BackupFetchMode, restorePoint string,
 ) func(folder storage.Folder, backup internal.Backup) {
@@ -262,6 +367,9 @@ func NewGreenplumBackupFetcher(restoreCfgPath string, inPlaceRestore bool, logsD
 		tracelog.InfoLogger.Printf("Starting backup-fetch for %s", backup.Name)
 		if restorePoint != "" {
 			tracelog.ErrorLogger.FatalOnError(ValidateMatch(folder, backup.Name, restorePoint))
+			tracelog.InfoLogger.Printf("Checking the WAL of the segments up to the restore point %s", restorePoint)
+			err := ValidateRestorePointWals(folder, backup.Name, restorePoint, fetchContentIds, os.Stdout)
+			tracelog.ErrorLogger.FatalOnError(err)
 		}
 		var sentinel BackupSentinelDto
 		err := backup.FetchSentinel(&sentinel)
@@ -107,11 +107,12 @@ type BackupHandler struct {
 	globalCluster  *cluster.Cluster
 	currBackupInfo CurrBackupInfo
 	prevBackupInfo PrevBackupInfo
+	// agentClient dispatches the segment commands to the segment agents, the SSH is used if it is nil
+	agentClient *SegmentAgentClient
 }
 
-// TODO: unit tests
-// buildBackupPushCommand builds a command to be executed on specific segment
-func (bh *BackupHandler) buildBackupPushCommand(contentID int) string {
+// buildBackupPushArgs builds the backup-push arguments for the specific segment
+func (bh *BackupHandler) buildBackupPushArgs(contentID int) []string {
 	segment := bh.globalCluster.ByContent[contentID][0]
 	segUserData := NewSegmentUserData()
 	bh.currBackupInfo.segmentBackups[segUserData.ID] = segment
@@ -127,8 +128,14 @@ func (bh *BackupHandler) buildBackupPushCommand(contentID int) string {
 	for _, arg := range bh.arguments.segmentFwdArgs {
 		backupPushArgs = append(backupPushArgs, fmt.Sprintf("--%s=%s", arg.Name, arg.Value))
 	}
+	return backupPushArgs
+}
 
-	backupPushArgsLine := "'" + strings.Join(backupPushArgs, " ") + "'"
+// TODO: unit tests
+// buildBackupPushCommand builds a command to be executed on specific segment
+func (bh *BackupHandler) buildBackupPushCommand(contentID int) string {
+	segment := bh.globalCluster.ByContent[contentID][0]
+	backupPushArgsLine := "'" + strings.Join(bh.buildBackupPushArgs(contentID), " ") + "'"
 
 	cmd := []string{
 		// nohup to avoid the SIGHUP on SSH session disconnect
@@ -182,26 +189,10 @@ func (bh *BackupHandler) HandleBackupPush() {
 	tracelog.ErrorLogger.FatalfOnError("Failed to configure delta backup: %v\n", err)
 
 	tracelog.InfoLogger.Println("Running wal-g on segments")
-	remoteOutput := bh.globalCluster.GenerateAndExecuteCommand("Running wal-g",
-		cluster.ON_SEGMENTS|cluster.INCLUDE_MASTER,
-		func(contentID int) string {
-			return bh.buildBackupPushCommand(contentID)
-		})
-	bh.globalCluster.CheckClusterError(remoteOutput, "Unable to run wal-g", func(contentID int) string {
-		return "Unable to run wal-g"
-	}, true)
-
-	for _, command := range remoteOutput.Commands {
-		if command.Stderr != "" {
-			tracelog.ErrorLogger.Printf("stderr (segment %d):\n%s\n", command.Content, command.Stderr)
-		}
-	}
-
-	bh.currBackupInfo.backupPidByContentID, err = extractBackupPids(remoteOutput)
-	// this is a non-critical error since backup PIDs are only useful if backup is aborted
-	tracelog.ErrorLogger.PrintOnError(err)
-	if remoteOutput.NumErrors > 0 {
-		bh.abortBackup()
+	if bh.agentClient != nil {
+		bh.startSegmentBackupsWithAgent()
+	} else {
+		bh.startSegmentBackups()
 	}
 
 	// WAL-G will reconnect later
@@ -241,6 +232,51 @@ func (bh *BackupHandler) HandleBackupPush() {
 	bh.disconnect()
 }
 
+func (bh *BackupHandler) startSegmentBackups() {
+	remoteOutput := bh.globalCluster.GenerateAndExecuteCommand("Running wal-g",
+		cluster.ON_SEGMENTS|cluster.INCLUDE_MASTER,
+		func(contentID int) string {
+			return bh.buildBackupPushCommand(contentID)
+		})
+	bh.globalCluster.CheckClusterError(remoteOutput, "Unable to run wal-g", func(contentID int) string {
+		return "Unable to run wal-g"
+	}, true)
+
+	for _, command := range remoteOutput.Commands {
+		if command.Stderr != "" {
+			tracelog.ErrorLogger.Printf("stderr (segment %d):\n%s\n", command.Content, command.Stderr)
+		}
+	}
+
+	var err error
+	bh.currBackupInfo.backupPidByContentID, err = extractBackupPids(remoteOutput)
+	// this is a non-critical error since backup PIDs are only useful if backup is aborted
+	tracelog.ErrorLogger.PrintOnError(err)
+	if remoteOutput.NumErrors > 0 {
+		bh.abortBackup()
+	}
+}
+
+// startSegmentBackupsWithAgent dispatches the segment backups to the segment agents in parallel
+func (bh *BackupHandler) startSegmentBackupsWithAgent() {
+	// the segment user data is registered in the shared map, so build the arguments sequentially
+	argsByContentID := make(map[int][]string)
+	segments := primarySegments(bh.globalCluster, nil)
+	for _, segment := range segments {
+		argsByContentID[segment.ContentID] = bh.buildBackupPushArgs(segment.ContentID)
+	}
+
+	err := bh.agentClient.RunOnSegments(segments, func(segment *cluster.SegConfig) error {
+		tracelog.InfoLogger.Printf("Starting %s on segment %d via the agent", SegBackupPushCmdName, segment.ContentID)
+		return bh.agentClient.StartCommand(segment,
+			SegmentAgentCommand{Name: SegBackupPushCmdName, Args: argsByContentID[segment.ContentID]})
+	})
+	if err != nil {
+		tracelog.ErrorLogger.Printf("Unable to run wal-g: %v", err)
+		bh.abortBackup()
+	}
+}
+
 func (bh *BackupHandler) uploadRestorePointMetadata(restoreLSNs map[int]string) (err error) {
 	hostname, err := os.Hostname()
 	if err != nil {
@@ -261,7 +297,7 @@ func (bh *BackupHandler) uploadRestorePointMetadata(restoreLSNs map[int]string)
 	tracelog.InfoLogger.Printf("Uploading restore point metadata file %s", metaFileName)
 	tracelog.InfoLogger.Println(meta.String())
 
-	if err := internal.UploadDto(bh.workers.Uploader.Folder(), meta, metaFileName); err != nil {
+	if err := internal.UploadBackupDto(bh.workers.Uploader, meta, metaFileName); err != nil {
 		return fmt.Errorf("upload metadata file for restore point %s: %w", meta.Name, err)
 	}
 	return nil
@@ -347,6 +383,10 @@ func extractBackupPids(output *cluster.RemoteOutput) (map[int]int, error) {
 }
 
 func (bh *BackupHandler) pollSegmentStates() (map[int]SegCmdState, error) {
+	if bh.agentClient != nil {
+		return bh.agentClient.PollCommandStates(primarySegments(bh.globalCluster, nil), SegBackupPushCmdName)
+	}
+
 	segmentStates := make(map[int]SegCmdState)
 	remoteOutput := bh.globalCluster.GenerateAndExecuteCommand("Polling the segment backup-push statuses...",
 		cluster.ON_SEGMENTS|cluster.EXCLUDE_MIRRORS|cluster.INCLUDE_MASTER,
@@ -491,6 +531,11 @@ func NewBackupHandler(arguments BackupArguments) (bh *BackupHandler, err error)
 		return nil, err
 	}
 
+	agentClient, err := ConfigureSegmentAgentClient()
+	if err != nil {
+		return nil, err
+	}
+
 	bh = &BackupHandler{
 		arguments: arguments,
 		workers: BackupWorkers{
@@ -503,6 +548,7 @@ func NewBackupHandler(arguments BackupArguments) (bh *BackupHandler, err error)
 			gpVersion:        version,
 			systemIdentifier: systemIdentifier,
 		},
+		agentClient: agentClient,
 	}
 	return bh, nil
 }
@@ -611,6 +657,13 @@ func (bh *BackupHandler) terminateRunningBackups() error {
 }
 
 func (bh *BackupHandler) terminateWalgProcesses() error {
+	if bh.agentClient != nil {
+		tracelog.InfoLogger.Println("Terminating the segment backup-push processes via the agents...")
+		return bh.agentClient.RunOnSegments(primarySegments(bh.globalCluster, nil), func(segment *cluster.SegConfig) error {
+			return bh.agentClient.AbortCommand(segment, SegBackupPushCmdName)
+		})
+	}
+
 	knownPidsLen := len(bh.currBackupInfo.backupPidByContentID)
 	if knownPidsLen == 0 {
 		return fmt.Errorf("there are no known PIDs of WAL-G segment processess")
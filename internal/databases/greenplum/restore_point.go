@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
@@ -122,21 +124,73 @@ func NewRestorePointCreator(pointName string) (rpc *RestorePointCreator, err err
 
 // Create creates cluster-wide consistent restore point
 func (rpc *RestorePointCreator) Create() {
-	rpc.startTime = utility.TimeNowCrossPlatformUTC()
 	initGpLog(rpc.logsDir)
+	err := rpc.create()
+	tracelog.ErrorLogger.FatalOnError(err)
+}
+
+func (rpc *RestorePointCreator) create() error {
+	rpc.startTime = utility.TimeNowCrossPlatformUTC()
 
 	err := rpc.checkExists()
-	tracelog.ErrorLogger.FatalOnError(err)
+	if err != nil {
+		return err
+	}
 
 	restoreLSNs, err := createRestorePoint(rpc.Conn, rpc.pointName)
-	tracelog.ErrorLogger.FatalOnError(err)
+	if err != nil {
+		return err
+	}
 
 	err = rpc.uploadMetadata(restoreLSNs)
 	if err != nil {
-		tracelog.ErrorLogger.Printf("Failed to upload metadata file for restore point %s", rpc.pointName)
-		tracelog.ErrorLogger.FatalError(err)
+		return fmt.Errorf("failed to upload metadata file for restore point %s: %w", rpc.pointName, err)
 	}
 	tracelog.InfoLogger.Printf("Restore point %s successfully created", rpc.pointName)
+	return nil
+}
+
+// ScheduledRestorePointName returns the name of the restore point created by the schedule at the provided time
+func ScheduledRestorePointName(prefix string, createTime time.Time) string {
+	return prefix + "_" + createTime.UTC().Format(utility.BackupTimeFormat)
+}
+
+// HandleScheduledRestorePoints creates the restore points named <prefix>_<time> every interval
+// until the process is terminated. The failed attempts are logged and retried on the next tick.
+func HandleScheduledRestorePoints(prefix string, interval time.Duration) {
+	if interval <= 0 {
+		tracelog.ErrorLogger.Fatalf("The restore point creation interval must be positive, got %s", interval)
+	}
+	initGpLog(viper.GetString(internal.GPLogsDirectory))
+	tracelog.InfoLogger.Printf("Creating the restore points with prefix %s every %s", prefix, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+
+	for {
+		err := createScheduledRestorePoint(ScheduledRestorePointName(prefix, utility.TimeNowCrossPlatformUTC()))
+		if err != nil {
+			tracelog.ErrorLogger.Printf("Failed to create the scheduled restore point: %v", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case sig := <-sigCh:
+			tracelog.InfoLogger.Printf("Received %s, stopping the restore points creation", sig)
+			return
+		}
+	}
+}
+
+func createScheduledRestorePoint(pointName string) error {
+	rpc, err := NewRestorePointCreator(pointName)
+	if err != nil {
+		return err
+	}
+	defer utility.LoggedClose(rpc.Conn, "failed to close the connection")
+	return rpc.create()
 }
 
 func createRestorePoint(conn *pgx.Conn, restorePointName string) (restoreLSNs map[int]string, err error) {
@@ -183,7 +237,7 @@ func (rpc *RestorePointCreator) uploadMetadata(restoreLSNs map[int]string) (err
 	tracelog.InfoLogger.Printf("Uploading restore point metadata file %s", metaFileName)
 	tracelog.InfoLogger.Println(meta.String())
 
-	return internal.UploadDto(rpc.Uploader.Folder(), meta, metaFileName)
+	return internal.UploadBackupDto(rpc.Uploader, meta, metaFileName)
 }
 
 type RestorePointTime struct {
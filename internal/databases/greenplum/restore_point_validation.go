@@ -0,0 +1,176 @@
+package greenplum
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/postgres"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+)
+
+// SegmentWalCheckResult tells whether the WAL of a segment is archived
+// from its backup start up to the restore point
+type SegmentWalCheckResult struct {
+	ContentID       int      `json:"content_id"`
+	BackupName      string   `json:"backup_name"`
+	StartLSN        string   `json:"start_lsn"`
+	RestorePointLSN string   `json:"restore_point_lsn"`
+	FirstWalSegment string   `json:"first_wal_segment,omitempty"`
+	LastWalSegment  string   `json:"last_wal_segment,omitempty"`
+	MissingSegments []string `json:"missing_segments,omitempty"`
+	Error           string   `json:"error,omitempty"`
+}
+
+func (r SegmentWalCheckResult) IsOk() bool {
+	return r.Error == "" && len(r.MissingSegments) == 0
+}
+
+// RestorePointWalReport is the per-segment result of the restore point WAL check
+type RestorePointWalReport struct {
+	RestorePoint string                  `json:"restore_point"`
+	Backup       string                  `json:"backup"`
+	Segments     []SegmentWalCheckResult `json:"segments"`
+}
+
+func (r RestorePointWalReport) IsOk() bool {
+	for _, segment := range r.Segments {
+		if !segment.IsOk() {
+			return false
+		}
+	}
+	return true
+}
+
+// Write prints the report as JSON
+func (r RestorePointWalReport) Write(output io.Writer) error {
+	return internal.WriteAsJSON(r, output, true)
+}
+
+// CheckRestorePointWals scans the WAL folder of every primary segment of the backup and reports the WAL segments
+// missing between the segment backup start and the restore point LSN. If contentIDs is not empty,
+// only these segments are checked.
+func CheckRestorePointWals(folder storage.Folder, backupName, restorePoint string,
+	contentIDs []int) (RestorePointWalReport, error) {
+	report := RestorePointWalReport{RestorePoint: restorePoint, Backup: backupName}
+
+	backup := NewBackup(folder, backupName)
+	sentinel, err := backup.GetSentinel()
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch %s sentinel: %w", backupName, err)
+	}
+	rpMeta, err := FetchRestorePointMetadata(folder, restorePoint)
+	if err != nil {
+		return report, err
+	}
+
+	checkContentIDs := make(map[int]bool)
+	for _, contentID := range contentIDs {
+		checkContentIDs[contentID] = true
+	}
+	for _, segMeta := range sentinel.Segments {
+		if segMeta.Role != Primary || len(checkContentIDs) > 0 && !checkContentIDs[segMeta.ContentID] {
+			continue
+		}
+		report.Segments = append(report.Segments, checkSegmentWals(folder, segMeta, rpMeta))
+	}
+	sort.Slice(report.Segments, func(i, j int) bool {
+		return report.Segments[i].ContentID < report.Segments[j].ContentID
+	})
+	return report, nil
+}
+
+func checkSegmentWals(folder storage.Folder, segMeta SegmentMetadata, rpMeta RestorePointMetadata) SegmentWalCheckResult {
+	result := SegmentWalCheckResult{ContentID: segMeta.ContentID, BackupName: segMeta.BackupName}
+	fail := func(format string, args ...interface{}) SegmentWalCheckResult {
+		result.Error = fmt.Sprintf(format, args...)
+		return result
+	}
+
+	restoreLSNStr, ok := rpMeta.LsnBySegment[segMeta.ContentID]
+	if !ok {
+		return fail("restore point %s has no LSN for the segment", rpMeta.Name)
+	}
+	result.RestorePointLSN = restoreLSNStr
+	restoreLSN, err := postgres.ParseLSN(restoreLSNStr)
+	if err != nil {
+		return fail("failed to parse the restore point LSN: %v", err)
+	}
+
+	timeline, err := postgres.ParseTimelineFromBackupName(segMeta.BackupName)
+	if err != nil {
+		return fail("failed to parse the timeline of the segment backup: %v", err)
+	}
+	segBackup := postgres.NewBackup(folder.GetSubFolder(FormatSegmentBackupPath(segMeta.ContentID)), segMeta.BackupName)
+	segSentinel, err := segBackup.GetSentinel()
+	if err != nil {
+		return fail("failed to fetch the segment backup sentinel: %v", err)
+	}
+	if segSentinel.BackupStartLSN == nil {
+		return fail("the segment backup sentinel has no start LSN")
+	}
+	startLSN := *segSentinel.BackupStartLSN
+	result.StartLSN = startLSN.String()
+	if restoreLSN < startLSN {
+		return fail("the restore point LSN is before the segment backup start")
+	}
+
+	walFolder := folder.GetSubFolder(FormatSegmentWalPath(segMeta.ContentID))
+	archive, err := postgres.ListWalArchive(walFolder)
+	if err != nil {
+		return fail("failed to list the segment WAL folder: %v", err)
+	}
+	timelines, err := findSegmentTimelines(archive, walFolder, timeline, startLSN)
+	if err != nil {
+		return fail("failed to read the timeline history of the segment: %v", err)
+	}
+
+	firstSegNo := uint64(startLSN) / postgres.WalSegmentSize
+	lastSegNo := uint64(restoreLSN) / postgres.WalSegmentSize
+	result.FirstWalSegment = postgres.FormatWALFileName(timelines.Timeline(firstSegNo), firstSegNo)
+	result.LastWalSegment = postgres.FormatWALFileName(timelines.Timeline(lastSegNo), lastSegNo)
+	for segNo := firstSegNo; segNo <= lastSegNo; segNo++ {
+		segTimeline := timelines.Timeline(segNo)
+		if !archive.HasSegment(segTimeline, segNo) {
+			result.MissingSegments = append(result.MissingSegments, postgres.FormatWALFileName(segTimeline, segNo))
+		}
+	}
+	return result
+}
+
+// findSegmentTimelines picks the latest timeline the segment could be promoted to after the backup, i.e. the one
+// whose history has the backup timeline left after the backup start. Its history tells the timeline owning
+// each WAL segment number, the segments before a switch point are needed from the parent timeline.
+func findSegmentTimelines(archive *postgres.WalArchive, walFolder storage.Folder, backupTimeline uint32,
+	startLSN postgres.LSN) (*postgres.SegmentTimelines, error) {
+	for _, timeline := range archive.HistoryTimelines() {
+		if timeline <= backupTimeline {
+			break
+		}
+		timelines, err := postgres.LoadSegmentTimelines(timeline, walFolder)
+		if err != nil {
+			return nil, err
+		}
+		if timelines.Contains(backupTimeline, startLSN) {
+			return timelines, nil
+		}
+	}
+	return postgres.LoadSegmentTimelines(backupTimeline, walFolder)
+}
+
+// ValidateRestorePointWals refuses the restore if the WAL of any segment up to the restore point is missing
+func ValidateRestorePointWals(folder storage.Folder, backupName, restorePoint string,
+	contentIDs []int, output io.Writer) error {
+	report, err := CheckRestorePointWals(folder, backupName, restorePoint, contentIDs)
+	if err != nil {
+		return err
+	}
+	if err := report.Write(output); err != nil {
+		return err
+	}
+	if !report.IsOk() {
+		return fmt.Errorf("the WAL needed to reach the restore point %s is not archived on some segments", restorePoint)
+	}
+	return nil
+}
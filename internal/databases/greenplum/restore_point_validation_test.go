@@ -0,0 +1,110 @@
+package greenplum_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"path"
+	"testing"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/greenplum"
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/postgres"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/testtools"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSegBackupName = "base_000000010000000000000002"
+
+func putTestDto(t *testing.T, folder storage.Folder, name string, dto interface{}) {
+	data, err := json.Marshal(dto)
+	require.NoError(t, err)
+	require.NoError(t, folder.PutObject(name, bytes.NewReader(data)))
+}
+
+func createRestorePointWalsFolder(t *testing.T) storage.Folder {
+	folder := testtools.MakeDefaultInMemoryStorageFolder()
+	segments := make([]greenplum.SegmentMetadata, 0)
+	for _, contentID := range []int{-1, 0, 1} {
+		segments = append(segments, greenplum.SegmentMetadata{
+			ContentID: contentID, Role: greenplum.Primary, BackupName: testSegBackupName})
+		startLSN := postgres.LSN(2*postgres.WalSegmentSize + 0x28)
+		putTestDto(t, folder.GetSubFolder(greenplum.FormatSegmentBackupPath(contentID)),
+			testSegBackupName+utility.SentinelSuffix, postgres.BackupSentinelDto{BackupStartLSN: &startLSN})
+	}
+	segments = append(segments, greenplum.SegmentMetadata{ContentID: 0, Role: greenplum.Mirror})
+	putTestDto(t, folder.GetSubFolder(utility.BaseBackupPath), "backup_1"+utility.SentinelSuffix,
+		greenplum.BackupSentinelDto{Segments: segments})
+
+	putTestDto(t, folder.GetSubFolder(utility.BaseBackupPath), greenplum.RestorePointMetadataFileName("rp_1"),
+		greenplum.RestorePointMetadata{Name: "rp_1", LsnBySegment: map[int]string{
+			-1: postgres.LSN(4*postgres.WalSegmentSize + 0x100).String(),
+			0:  postgres.LSN(4*postgres.WalSegmentSize + 0x100).String(),
+			1:  postgres.LSN(3*postgres.WalSegmentSize + 0x100).String(),
+		}})
+
+	walFiles := map[int][]string{
+		// the complete WAL, the segment 4 is archived after the promotion to the timeline 2
+		-1: {"000000010000000000000002.br", "000000010000000000000003.br", "000000020000000000000004.br"},
+		// the segment 3 is missing, the partial file does not count
+		0: {"000000010000000000000002.br", "000000010000000000000003.partial.br", "000000010000000000000004.br"},
+		1: {"000000010000000000000002.br", "000000010000000000000003.br"},
+	}
+	for contentID, names := range walFiles {
+		for _, name := range names {
+			require.NoError(t, folder.PutObject(path.Join(greenplum.FormatSegmentWalPath(contentID), name),
+				&bytes.Buffer{}))
+		}
+	}
+	historyFiles := map[int]string{
+		-1: "1\t0/4000000\tno recovery target specified\n",
+		// the timeline 1 was left before the backup start, so the timeline 2 does not continue the backup
+		1: "1\t0/1000000\tno recovery target specified\n",
+	}
+	for contentID, history := range historyFiles {
+		require.NoError(t, folder.PutObject(path.Join(greenplum.FormatSegmentWalPath(contentID), "00000002.history"),
+			bytes.NewBufferString(history)))
+	}
+	return folder
+}
+
+func TestCheckRestorePointWals(t *testing.T) {
+	folder := createRestorePointWalsFolder(t)
+
+	report, err := greenplum.CheckRestorePointWals(folder, "backup_1", "rp_1", nil)
+	require.NoError(t, err)
+	assert.False(t, report.IsOk())
+	require.Len(t, report.Segments, 3)
+
+	assert.Equal(t, -1, report.Segments[0].ContentID)
+	assert.True(t, report.Segments[0].IsOk())
+	assert.Equal(t, "000000010000000000000002", report.Segments[0].FirstWalSegment)
+	assert.Equal(t, "000000020000000000000004", report.Segments[0].LastWalSegment)
+
+	assert.Equal(t, []string{"000000010000000000000003"}, report.Segments[1].MissingSegments)
+	assert.True(t, report.Segments[2].IsOk())
+
+	report, err = greenplum.CheckRestorePointWals(folder, "backup_1", "rp_1", []int{-1, 1})
+	require.NoError(t, err)
+	assert.True(t, report.IsOk())
+	assert.Len(t, report.Segments, 2)
+}
+
+func TestValidateRestorePointWals(t *testing.T) {
+	folder := createRestorePointWalsFolder(t)
+
+	var output bytes.Buffer
+	err := greenplum.ValidateRestorePointWals(folder, "backup_1", "rp_1", nil, &output)
+	assert.ErrorContains(t, err, "not archived on some segments")
+	assert.Contains(t, output.String(), "\"missing_segments\": [\n                \"000000010000000000000003\"")
+
+	err = greenplum.ValidateRestorePointWals(folder, "backup_1", "rp_2", nil, &output)
+	assert.ErrorContains(t, err, "failed to fetch metadata for restore point rp_2")
+}
+
+func TestScheduledRestorePointName(t *testing.T) {
+	createTime, err := utility.ParseUntilTS("2024-01-02T03:04:05Z")
+	require.NoError(t, err)
+	assert.Equal(t, "hourly_20240102T030405Z", greenplum.ScheduledRestorePointName("hourly", createTime))
+}
@@ -0,0 +1,475 @@
+package greenplum
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	SegmentSSHTransport   = "ssh"
+	SegmentAgentTransport = "agent"
+
+	segmentAgentUnixPrefix = "unix:"
+	segmentAgentAPIPrefix  = "/v1/segments/"
+)
+
+// The agent runs only the segment commands the coordinator needs,
+// and writes only the configs the restore prepares
+var (
+	segmentAgentAllowedCommands = map[string]bool{
+		SegBackupPushCmdName:  true,
+		SegBackupFetchCmdName: true,
+	}
+	segmentAgentAllowedConfigs = map[string]bool{
+		"pg_hba.conf":   true,
+		"recovery.conf": true,
+	}
+)
+
+// SegmentAgentCommand is a request to run the WAL-G command on a segment,
+// the first argument is the segment data directory
+type SegmentAgentCommand struct {
+	Name   string   `json:"name"`
+	Args   []string `json:"args"`
+	PgPort int      `json:"pg_port,omitempty"`
+}
+
+// SegmentAgentConfigFile is a request to write the config file into the segment data directory,
+// DataDir must be the one the agent serves the segment with
+type SegmentAgentConfigFile struct {
+	DataDir  string `json:"data_dir"`
+	Contents string `json:"contents"`
+}
+
+// SegmentAgent serves the coordinator requests on the segment host, so the segment commands are dispatched
+// over HTTP instead of the SSH fan-out. Each command is run via seg-cmd-run, so its state is tracked
+// in the same state files as in the SSH mode.
+type SegmentAgent struct {
+	token string
+	// dataDirs are the data directories of the segments on the agent host by content ID
+	dataDirs map[int]string
+
+	mu       sync.Mutex
+	commands map[string]*exec.Cmd
+	// exit errors of the finished commands
+	results map[string]error
+}
+
+func NewSegmentAgent(token string, dataDirs map[int]string) *SegmentAgent {
+	return &SegmentAgent{
+		token:    token,
+		dataDirs: dataDirs,
+		commands: make(map[string]*exec.Cmd),
+		results:  make(map[string]error),
+	}
+}
+
+// SegmentAgentListenAddress returns the unix socket if it is configured, the TCP port otherwise
+func SegmentAgentListenAddress() string {
+	if socketPath := viper.GetString(internal.GPSegmentAgentSocket); socketPath != "" {
+		return segmentAgentUnixPrefix + socketPath
+	}
+	return ":" + viper.GetString(internal.GPSegmentAgentPort)
+}
+
+// ParseSegmentAgentDataDirs parses the comma separated content_id:data_dir pairs of WALG_GP_SEG_AGENT_DATA_DIRS
+func ParseSegmentAgentDataDirs(value string) (map[int]string, error) {
+	dataDirs := make(map[int]string)
+	for _, pair := range strings.Split(value, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		contentIDStr, dataDir, ok := strings.Cut(strings.TrimSpace(pair), ":")
+		contentID, err := strconv.Atoi(contentIDStr)
+		if !ok || err != nil || !path.IsAbs(dataDir) {
+			return nil, fmt.Errorf("invalid %s entry %q, expected content_id:/absolute/data/dir",
+				internal.GPSegmentAgentDataDirs, pair)
+		}
+		dataDirs[contentID] = path.Clean(dataDir)
+	}
+	if len(dataDirs) == 0 {
+		return nil, fmt.Errorf("%s is required, it lists the segments served by the agent", internal.GPSegmentAgentDataDirs)
+	}
+	return dataDirs, nil
+}
+
+// HandleSegmentAgent serves the agent API on the address until SIGINT or SIGTERM is received.
+// The addresses prefixed with "unix:" are unix socket paths. The agent listening on TCP requires
+// the token and serves HTTPS if the certificate and the key are set.
+func HandleSegmentAgent(address string) error {
+	token := viper.GetString(internal.GPSegmentAgentToken)
+	certFile := viper.GetString(internal.GPSegmentAgentTLSCert)
+	keyFile := viper.GetString(internal.GPSegmentAgentTLSKey)
+	network := "tcp"
+	if strings.HasPrefix(address, segmentAgentUnixPrefix) {
+		network = "unix"
+		address = strings.TrimPrefix(address, segmentAgentUnixPrefix)
+	} else {
+		if token == "" {
+			return fmt.Errorf("%s is required when the segment agent listens on TCP, "+
+				"set it or listen on the unix socket", internal.GPSegmentAgentToken)
+		}
+		if (certFile == "") != (keyFile == "") {
+			return fmt.Errorf("both %s and %s must be set to serve TLS",
+				internal.GPSegmentAgentTLSCert, internal.GPSegmentAgentTLSKey)
+		}
+	}
+	dataDirs, err := ParseSegmentAgentDataDirs(viper.GetString(internal.GPSegmentAgentDataDirs))
+	if err != nil {
+		return err
+	}
+	listener, err := ListenSegmentAgent(network, address)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Handler: NewSegmentAgent(token, dataDirs)}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigCh
+		tracelog.InfoLogger.Printf("Received signal: %s, stopping the segment agent", sig)
+		if err := server.Shutdown(context.Background()); err != nil {
+			tracelog.WarningLogger.Printf("Failed to shutdown the segment agent: %v", err)
+		}
+	}()
+
+	tracelog.InfoLogger.Printf("Segment agent is listening on %s %s", network, address)
+	switch {
+	case network == "tcp" && certFile != "":
+		err = server.ServeTLS(listener, certFile, keyFile)
+	case network == "tcp":
+		tracelog.WarningLogger.Printf("Segment agent serves plain HTTP, set %s and %s to serve TLS",
+			internal.GPSegmentAgentTLSCert, internal.GPSegmentAgentTLSKey)
+		err = server.Serve(listener)
+	default:
+		err = server.Serve(listener)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// ListenSegmentAgent listens on the agent address. The unix socket may be used without the token,
+// so it is created accessible to its owner only, otherwise any local user could run the segment commands.
+func ListenSegmentAgent(network, address string) (net.Listener, error) {
+	if network != "unix" {
+		return net.Listen(network, address)
+	}
+	if err := os.Remove(address); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove the stale socket %s: %w", address, err)
+	}
+	listener, err := listenUnixSocket(address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on the socket %s: %w", address, err)
+	}
+	return listener, nil
+}
+
+// ServeHTTP routes the requests:
+//
+//	POST   /v1/segments/<content_id>/commands                run the command
+//	GET    /v1/segments/<content_id>/commands/<name>         get the command state
+//	DELETE /v1/segments/<content_id>/commands/<name>         interrupt the command
+//	GET    /v1/segments/<content_id>/commands/<name>/log     read the command log from the ?offset=
+//	PUT    /v1/segments/<content_id>/configs/<file>          write pg_hba.conf or recovery.conf
+func (a *SegmentAgent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !a.isAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, segmentAgentAPIPrefix), "/")
+	if !strings.HasPrefix(r.URL.Path, segmentAgentAPIPrefix) || len(parts) < 2 {
+		http.NotFound(w, r)
+		return
+	}
+	contentID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid content ID: %s", parts[0]), http.StatusBadRequest)
+		return
+	}
+
+	switch {
+	case len(parts) == 2 && parts[1] == "commands" && r.Method == http.MethodPost:
+		a.handleStartCommand(w, r, contentID)
+	case len(parts) == 3 && parts[1] == "commands" && r.Method == http.MethodGet:
+		a.handleCommandState(w, contentID, parts[2])
+	case len(parts) == 3 && parts[1] == "commands" && r.Method == http.MethodDelete:
+		a.handleAbortCommand(w, contentID, parts[2])
+	case len(parts) == 4 && parts[1] == "commands" && parts[3] == "log" && r.Method == http.MethodGet:
+		a.handleCommandLog(w, r, contentID, parts[2])
+	case len(parts) == 3 && parts[1] == "configs" && r.Method == http.MethodPut:
+		a.handleWriteConfig(w, r, contentID, parts[2])
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (a *SegmentAgent) isAuthorized(r *http.Request) bool {
+	if a.token == "" {
+		return true
+	}
+	expected := "Bearer " + a.token
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(expected)) == 1
+}
+
+func (a *SegmentAgent) handleStartCommand(w http.ResponseWriter, r *http.Request, contentID int) {
+	var command SegmentAgentCommand
+	if err := json.NewDecoder(r.Body).Decode(&command); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode the command: %v", err), http.StatusBadRequest)
+		return
+	}
+	if !segmentAgentAllowedCommands[command.Name] {
+		http.Error(w, fmt.Sprintf("command %q is not allowed", command.Name), http.StatusBadRequest)
+		return
+	}
+	if len(command.Args) == 0 {
+		http.Error(w, "the segment data directory argument is missing", http.StatusBadRequest)
+		return
+	}
+	if _, err := a.checkDataDir(contentID, command.Args[0]); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := segmentAgentCommandKey(contentID, command.Name)
+	if _, isKnown := a.commands[key]; isKnown {
+		if _, isFinished := a.results[key]; !isFinished {
+			http.Error(w, fmt.Sprintf("command %s is already running on segment %d", command.Name, contentID),
+				http.StatusConflict)
+			return
+		}
+	}
+
+	cmd, err := startSegmentAgentCommand(contentID, command)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.commands[key] = cmd
+	delete(a.results, key)
+	go func() {
+		waitErr := cmd.Wait()
+		a.mu.Lock()
+		a.results[key] = waitErr
+		a.mu.Unlock()
+		tracelog.InfoLogger.Printf("Command %s on segment %d finished: %v", command.Name, contentID, waitErr)
+	}()
+
+	tracelog.InfoLogger.Printf("Started command %s on segment %d, PID %d", command.Name, contentID, cmd.Process.Pid)
+	w.WriteHeader(http.StatusAccepted)
+	writeSegmentAgentJSON(w, SegCmdState{TS: time.Now(), Status: RunningCmdStatus})
+}
+
+// startSegmentAgentCommand runs the command via seg-cmd-run, writing its output to the log file of the command,
+// so the log of the run is not mixed with the output of the other commands on the segment.
+// The args are passed to seg-cmd-run as a JSON array, so they reach the command unchanged.
+func startSegmentAgentCommand(contentID int, command SegmentAgentCommand) (*exec.Cmd, error) {
+	cmdArgs, err := json.Marshal(command.Args)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal the command args: %w", err)
+	}
+	args := []string{"seg-cmd-run", command.Name, string(cmdArgs),
+		fmt.Sprintf("--content-id=%d", contentID), "--" + SegCmdRunJSONArgsFlag}
+	if internal.CfgFile != "" {
+		args = append(args, "--config", internal.CfgFile)
+	}
+
+	// the state of the previous run must not be reported for the new one
+	if err := os.Remove(FormatCmdStatePath(contentID, command.Name)); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to remove the previous command state: %w", err)
+	}
+
+	// the log of the previous run is replaced as well
+	logFile, err := os.OpenFile(formatSegmentCmdLogPath(contentID, command.Name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the command log file: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = os.Environ()
+	if command.PgPort != 0 {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("PGPORT=%d", command.PgPort))
+	}
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the command: %w", err)
+	}
+	return cmd, nil
+}
+
+func (a *SegmentAgent) handleCommandState(w http.ResponseWriter, contentID int, cmdName string) {
+	state, err := a.commandState(contentID, cmdName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeSegmentAgentJSON(w, state)
+}
+
+// commandState reads the state file written by seg-cmd-run. The file appears only after the first
+// state update, and it is not written at all if seg-cmd-run fails early, so the process state fills the gaps.
+func (a *SegmentAgent) commandState(contentID int, cmdName string) (SegCmdState, error) {
+	key := segmentAgentCommandKey(contentID, cmdName)
+	a.mu.Lock()
+	_, isKnown := a.commands[key]
+	waitErr, isFinished := a.results[key]
+	a.mu.Unlock()
+
+	stateBytes, err := os.ReadFile(FormatCmdStatePath(contentID, cmdName))
+	if err == nil {
+		var state SegCmdState
+		if err := json.Unmarshal(stateBytes, &state); err != nil {
+			return SegCmdState{}, fmt.Errorf("failed to unmarshal the state file: %w", err)
+		}
+		if state.Status != RunningCmdStatus || !isFinished {
+			return state, nil
+		}
+	}
+
+	switch {
+	case isFinished && waitErr != nil:
+		return SegCmdState{TS: time.Now(), Status: FailedCmdStatus}, nil
+	case isFinished:
+		return SegCmdState{TS: time.Now(), Status: SuccessCmdStatus}, nil
+	case isKnown:
+		return SegCmdState{TS: time.Now(), Status: RunningCmdStatus}, nil
+	default:
+		return SegCmdState{}, fmt.Errorf("command %s was not run on segment %d", cmdName, contentID)
+	}
+}
+
+func (a *SegmentAgent) handleAbortCommand(w http.ResponseWriter, contentID int, cmdName string) {
+	key := segmentAgentCommandKey(contentID, cmdName)
+	a.mu.Lock()
+	cmd, isKnown := a.commands[key]
+	_, isFinished := a.results[key]
+	a.mu.Unlock()
+
+	if !isKnown {
+		http.Error(w, fmt.Sprintf("command %s was not run on segment %d", cmdName, contentID), http.StatusNotFound)
+		return
+	}
+	if !isFinished {
+		// seg-cmd-run forwards SIGTERM to the command and marks it as interrupted
+		if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+			http.Error(w, fmt.Sprintf("failed to terminate the command: %v", err), http.StatusInternalServerError)
+			return
+		}
+		tracelog.InfoLogger.Printf("Terminated command %s on segment %d", cmdName, contentID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *SegmentAgent) handleCommandLog(w http.ResponseWriter, r *http.Request, contentID int, cmdName string) {
+	key := segmentAgentCommandKey(contentID, cmdName)
+	a.mu.Lock()
+	_, isKnown := a.commands[key]
+	a.mu.Unlock()
+	if !isKnown {
+		http.Error(w, fmt.Sprintf("command %s was not run on segment %d", cmdName, contentID), http.StatusNotFound)
+		return
+	}
+
+	var offset int64
+	if offsetStr := r.URL.Query().Get("offset"); offsetStr != "" {
+		var err error
+		offset, err = strconv.ParseInt(offsetStr, 10, 64)
+		if err != nil || offset < 0 {
+			http.Error(w, fmt.Sprintf("invalid offset: %s", offsetStr), http.StatusBadRequest)
+			return
+		}
+	}
+
+	logFile, err := os.Open(formatSegmentCmdLogPath(contentID, cmdName))
+	if os.IsNotExist(err) {
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer logFile.Close()
+
+	if _, err := logFile.Seek(offset, io.SeekStart); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	if _, err := io.Copy(w, logFile); err != nil {
+		tracelog.WarningLogger.Printf("Failed to send the segment %d log: %v", contentID, err)
+	}
+}
+
+func (a *SegmentAgent) handleWriteConfig(w http.ResponseWriter, r *http.Request, contentID int, fileName string) {
+	if !segmentAgentAllowedConfigs[fileName] {
+		http.Error(w, fmt.Sprintf("config %q is not allowed", fileName), http.StatusBadRequest)
+		return
+	}
+	var configFile SegmentAgentConfigFile
+	if err := json.NewDecoder(r.Body).Decode(&configFile); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode the config: %v", err), http.StatusBadRequest)
+		return
+	}
+	dataDir, err := a.checkDataDir(contentID, configFile.DataDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
+	filePath := path.Join(dataDir, fileName)
+	if err := os.WriteFile(filePath, []byte(configFile.Contents+"\n"), 0600); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tracelog.InfoLogger.Printf("Wrote %s on segment %d", filePath, contentID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkDataDir returns the data directory the agent serves the segment with,
+// the data directory of the request must be the same
+func (a *SegmentAgent) checkDataDir(contentID int, requestDataDir string) (string, error) {
+	dataDir, ok := a.dataDirs[contentID]
+	if !ok {
+		return "", fmt.Errorf("segment %d is not served by this agent", contentID)
+	}
+	if !path.IsAbs(requestDataDir) || path.Clean(requestDataDir) != dataDir {
+		return "", fmt.Errorf("%s is not the data directory of segment %d", requestDataDir, contentID)
+	}
+	return dataDir, nil
+}
+
+func segmentAgentCommandKey(contentID int, cmdName string) string {
+	return fmt.Sprintf("%d/%s", contentID, cmdName)
+}
+
+func writeSegmentAgentJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		tracelog.WarningLogger.Printf("Failed to write the agent response: %v", err)
+	}
+}
@@ -0,0 +1,289 @@
+package greenplum
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+)
+
+const segmentAgentRequestTimeout = time.Minute
+
+// SegmentAgentClient dispatches the segment commands to the segment agents, the agent of the segment
+// is reached at its host and WALG_GP_SEG_AGENT_PORT, or at WALG_GP_SEG_AGENT_SOCKET when it is set
+type SegmentAgentClient struct {
+	httpClient *http.Client
+	baseURL    func(segment *cluster.SegConfig) string
+	token      string
+
+	// segment log offsets already streamed to the coordinator log
+	logOffsetsMu sync.Mutex
+	logOffsets   map[string]int64
+}
+
+// ConfigureSegmentAgentClient returns the agent client if WALG_GP_SEG_TRANSPORT is "agent",
+// and nil if the segments should be reached over SSH
+func ConfigureSegmentAgentClient() (*SegmentAgentClient, error) {
+	switch transport := viper.GetString(internal.GPSegmentsTransport); transport {
+	case SegmentSSHTransport:
+		return nil, nil
+	case SegmentAgentTransport:
+		token := viper.GetString(internal.GPSegmentAgentToken)
+		if socketPath := viper.GetString(internal.GPSegmentAgentSocket); socketPath != "" {
+			return NewSegmentAgentSocketClient(socketPath, token), nil
+		}
+		if token == "" {
+			return nil, fmt.Errorf("%s is required to reach the segment agents over TCP", internal.GPSegmentAgentToken)
+		}
+		tlsConfig, err := segmentAgentTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		return NewSegmentAgentClient(viper.GetString(internal.GPSegmentAgentPort), token, tlsConfig), nil
+	default:
+		return nil, fmt.Errorf("unknown %s: %q, expected %q or %q", internal.GPSegmentsTransport,
+			transport, SegmentSSHTransport, SegmentAgentTransport)
+	}
+}
+
+// NewSegmentAgentClient makes the client reaching the agents at the segment hosts on the port,
+// over HTTPS if the TLS config is not nil
+func NewSegmentAgentClient(port, token string, tlsConfig *tls.Config) *SegmentAgentClient {
+	scheme := "http://"
+	httpClient := &http.Client{Timeout: segmentAgentRequestTimeout}
+	if tlsConfig != nil {
+		scheme = "https://"
+		httpClient.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+	}
+	return newSegmentAgentClient(httpClient,
+		func(segment *cluster.SegConfig) string {
+			return scheme + net.JoinHostPort(segment.Hostname, port)
+		}, token)
+}
+
+// segmentAgentTLSConfig returns nil if the agents serve plain HTTP. The agent certificates are verified
+// with WALG_GP_SEG_AGENT_TLS_CA, or with the system roots if only WALG_GP_SEG_AGENT_TLS_CERT is set
+// in the config shared with the agents.
+func segmentAgentTLSConfig() (*tls.Config, error) {
+	caFile := viper.GetString(internal.GPSegmentAgentTLSCA)
+	if caFile == "" {
+		if viper.GetString(internal.GPSegmentAgentTLSCert) == "" {
+			return nil, nil
+		}
+		return &tls.Config{MinVersion: tls.VersionTLS12}, nil
+	}
+	caPEM, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", internal.GPSegmentAgentTLSCA, err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+	return &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}, nil
+}
+
+// NewSegmentAgentSocketClient makes the client reaching the single agent serving all segments on the unix socket
+func NewSegmentAgentSocketClient(socketPath, token string) *SegmentAgentClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	return newSegmentAgentClient(&http.Client{Transport: transport, Timeout: segmentAgentRequestTimeout},
+		func(*cluster.SegConfig) string {
+			return "http://segment-agent"
+		}, token)
+}
+
+// NewSegmentAgentURLClient makes the client reaching the agents by the base URL, mostly useful for testing
+func NewSegmentAgentURLClient(baseURL, token string) *SegmentAgentClient {
+	return newSegmentAgentClient(&http.Client{Timeout: segmentAgentRequestTimeout},
+		func(*cluster.SegConfig) string {
+			return baseURL
+		}, token)
+}
+
+func newSegmentAgentClient(httpClient *http.Client, baseURL func(*cluster.SegConfig) string,
+	token string) *SegmentAgentClient {
+	return &SegmentAgentClient{
+		httpClient: httpClient,
+		baseURL:    baseURL,
+		token:      token,
+		logOffsets: make(map[string]int64),
+	}
+}
+
+// StartCommand runs the command on the segment without waiting for it to finish
+func (c *SegmentAgentClient) StartCommand(segment *cluster.SegConfig, command SegmentAgentCommand) error {
+	_, err := c.do(http.MethodPost, segment, "/commands", command)
+	return err
+}
+
+// GetCommandState returns the state of the last command run with the name on the segment
+func (c *SegmentAgentClient) GetCommandState(segment *cluster.SegConfig, cmdName string) (SegCmdState, error) {
+	var state SegCmdState
+	body, err := c.do(http.MethodGet, segment, "/commands/"+cmdName, nil)
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(body, &state); err != nil {
+		return state, fmt.Errorf("failed to unmarshal the segment %d state: %w", segment.ContentID, err)
+	}
+	return state, nil
+}
+
+// AbortCommand interrupts the command running on the segment
+func (c *SegmentAgentClient) AbortCommand(segment *cluster.SegConfig, cmdName string) error {
+	_, err := c.do(http.MethodDelete, segment, "/commands/"+cmdName, nil)
+	return err
+}
+
+// WriteConfig writes pg_hba.conf or recovery.conf into the segment data directory
+func (c *SegmentAgentClient) WriteConfig(segment *cluster.SegConfig, fileName, contents string) error {
+	_, err := c.do(http.MethodPut, segment, "/configs/"+fileName,
+		SegmentAgentConfigFile{DataDir: segment.DataDir, Contents: contents})
+	return err
+}
+
+// StreamCommandLog prints the segment log lines written since the previous call to the coordinator log
+func (c *SegmentAgentClient) StreamCommandLog(segment *cluster.SegConfig, cmdName string) error {
+	key := segmentAgentCommandKey(segment.ContentID, cmdName)
+	c.logOffsetsMu.Lock()
+	offset := c.logOffsets[key]
+	c.logOffsetsMu.Unlock()
+
+	body, err := c.do(http.MethodGet, segment, fmt.Sprintf("/commands/%s/log?offset=%d", cmdName, offset), nil)
+	if err != nil {
+		return err
+	}
+	// keep the incomplete last line for the next call
+	complete := bytes.LastIndexByte(body, '\n') + 1
+	scanner := bufio.NewScanner(bytes.NewReader(body[:complete]))
+	scanner.Buffer(nil, len(body)+1)
+	for scanner.Scan() {
+		tracelog.InfoLogger.Printf("[segment %d] %s", segment.ContentID, scanner.Text())
+	}
+
+	c.logOffsetsMu.Lock()
+	c.logOffsets[key] = offset + int64(complete)
+	c.logOffsetsMu.Unlock()
+	return nil
+}
+
+// RunOnSegments calls the function for every segment in parallel and joins the errors
+func (c *SegmentAgentClient) RunOnSegments(segments []*cluster.SegConfig,
+	run func(segment *cluster.SegConfig) error) error {
+	errs := make([]string, len(segments))
+	var wg sync.WaitGroup
+	for i, segment := range segments {
+		wg.Add(1)
+		go func(i int, segment *cluster.SegConfig) {
+			defer wg.Done()
+			if err := run(segment); err != nil {
+				errs[i] = fmt.Sprintf("segment %d (%s): %v", segment.ContentID, segment.Hostname, err)
+			}
+		}(i, segment)
+	}
+	wg.Wait()
+
+	failed := make([]string, 0)
+	for _, err := range errs {
+		if err != "" {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// PollCommandStates collects the command states of the segments and streams their new log lines
+func (c *SegmentAgentClient) PollCommandStates(segments []*cluster.SegConfig,
+	cmdName string) (map[int]SegCmdState, error) {
+	var mu sync.Mutex
+	states := make(map[int]SegCmdState, len(segments))
+	err := c.RunOnSegments(segments, func(segment *cluster.SegConfig) error {
+		if err := c.StreamCommandLog(segment, cmdName); err != nil {
+			tracelog.WarningLogger.Printf("Failed to read the log of segment %d: %v", segment.ContentID, err)
+		}
+		state, err := c.GetCommandState(segment, cmdName)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		states[segment.ContentID] = state
+		mu.Unlock()
+		return nil
+	})
+	return states, err
+}
+
+func (c *SegmentAgentClient) do(method string, segment *cluster.SegConfig, apiPath string,
+	request interface{}) ([]byte, error) {
+	var reqBody io.Reader
+	if request != nil {
+		reqBytes, err := json.Marshal(request)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = bytes.NewReader(reqBytes)
+	}
+
+	url := fmt.Sprintf("%s%s%d%s", c.baseURL(segment), segmentAgentAPIPrefix, segment.ContentID, apiPath)
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// primarySegments returns the primary of every content ID in the cluster, the master included
+func primarySegments(globalCluster *cluster.Cluster, contentIDFilter func(contentID int) bool) []*cluster.SegConfig {
+	segments := make([]*cluster.SegConfig, 0, len(globalCluster.ByContent))
+	seen := make(map[int]bool, len(globalCluster.ByContent))
+	for _, contentID := range globalCluster.ContentIDs {
+		if seen[contentID] || contentIDFilter != nil && !contentIDFilter(contentID) {
+			continue
+		}
+		seen[contentID] = true
+		segments = append(segments, globalCluster.ByContent[contentID][0])
+	}
+	return segments
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package greenplum
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenUnixSocket creates the socket with the 0600 mode, so there is no window when other users can connect to it
+func listenUnixSocket(address string) (net.Listener, error) {
+	oldMask := syscall.Umask(0177)
+	defer syscall.Umask(oldMask)
+	return net.Listen("unix", address)
+}
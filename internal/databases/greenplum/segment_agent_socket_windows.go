@@ -0,0 +1,22 @@
+//go:build windows
+// +build windows
+
+package greenplum
+
+import (
+	"net"
+	"os"
+)
+
+// listenUnixSocket restricts the socket mode after the creation, since there is no umask on Windows
+func listenUnixSocket(address string) (net.Listener, error) {
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(address, 0600); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
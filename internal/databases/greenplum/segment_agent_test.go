@@ -0,0 +1,160 @@
+package greenplum_test
+
+import (
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/greenplum"
+	"github.com/greenplum-db/gp-common-go-libs/cluster"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSegmentAgent_WriteConfig(t *testing.T) {
+	dataDir := t.TempDir()
+	server := httptest.NewServer(greenplum.NewSegmentAgent("secret", map[int]string{1: dataDir}))
+	defer server.Close()
+
+	segment := &cluster.SegConfig{ContentID: 1, DataDir: dataDir}
+	client := greenplum.NewSegmentAgentURLClient(server.URL, "secret")
+
+	require.NoError(t, client.WriteConfig(segment, "recovery.conf", "restore_command = 'true'"))
+	contents, err := os.ReadFile(path.Join(dataDir, "recovery.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, "restore_command = 'true'\n", string(contents))
+
+	err = client.WriteConfig(segment, "postgresql.conf", "")
+	assert.ErrorContains(t, err, "not allowed")
+
+	// the data directory of the request must be the one of the segment
+	err = client.WriteConfig(&cluster.SegConfig{ContentID: 1, DataDir: "/etc"}, "pg_hba.conf", "")
+	assert.ErrorContains(t, err, "403 Forbidden")
+	err = client.WriteConfig(&cluster.SegConfig{ContentID: 1, DataDir: "relative"}, "pg_hba.conf", "")
+	assert.ErrorContains(t, err, "is not the data directory of segment 1")
+	err = client.WriteConfig(&cluster.SegConfig{ContentID: 2, DataDir: dataDir}, "pg_hba.conf", "")
+	assert.ErrorContains(t, err, "segment 2 is not served by this agent")
+
+	err = greenplum.NewSegmentAgentURLClient(server.URL, "wrong").WriteConfig(segment, "pg_hba.conf", "")
+	assert.ErrorContains(t, err, "401 Unauthorized")
+}
+
+func TestSegmentAgent_Commands(t *testing.T) {
+	server := httptest.NewServer(greenplum.NewSegmentAgent("", map[int]string{0: "/data/gpseg0"}))
+	defer server.Close()
+
+	segment := &cluster.SegConfig{ContentID: 0}
+	client := greenplum.NewSegmentAgentURLClient(server.URL, "")
+
+	err := client.StartCommand(segment, greenplum.SegmentAgentCommand{Name: "backup-list"})
+	assert.ErrorContains(t, err, "command \"backup-list\" is not allowed")
+
+	err = client.StartCommand(segment,
+		greenplum.SegmentAgentCommand{Name: greenplum.SegBackupFetchCmdName, Args: []string{"/data/gpseg1"}})
+	assert.ErrorContains(t, err, "/data/gpseg1 is not the data directory of segment 0")
+
+	_, err = client.GetCommandState(segment, greenplum.SegBackupPushCmdName)
+	assert.ErrorContains(t, err, "404 Not Found")
+
+	err = client.StreamCommandLog(segment, greenplum.SegBackupPushCmdName)
+	assert.ErrorContains(t, err, "404 Not Found")
+
+	err = client.AbortCommand(segment, greenplum.SegBackupPushCmdName)
+	assert.ErrorContains(t, err, "404 Not Found")
+
+	resp, err := http.Get(server.URL + "/v1/segments/seg1/commands/" + greenplum.SegBackupPushCmdName)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestSegmentAgent_UnixSocket(t *testing.T) {
+	socketPath := path.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	dataDir := t.TempDir()
+	server := httptest.NewUnstartedServer(greenplum.NewSegmentAgent("", map[int]string{-1: dataDir}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	client := greenplum.NewSegmentAgentSocketClient(socketPath, "")
+	require.NoError(t, client.WriteConfig(&cluster.SegConfig{ContentID: -1, DataDir: dataDir}, "pg_hba.conf", "local all all trust"))
+
+	contents, err := os.ReadFile(path.Join(dataDir, "pg_hba.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, "local all all trust\n", string(contents))
+}
+
+func TestListenSegmentAgent_SocketPermissions(t *testing.T) {
+	socketPath := path.Join(t.TempDir(), "agent.sock")
+	// the stale socket of the previous run is replaced
+	require.NoError(t, os.WriteFile(socketPath, nil, 0666))
+
+	listener, err := greenplum.ListenSegmentAgent("unix", socketPath)
+	require.NoError(t, err)
+	defer listener.Close()
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.ModeSocket, info.Mode().Type())
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestHandleSegmentAgent_RequiresTokenOnTCP(t *testing.T) {
+	defer viper.Set(internal.GPSegmentAgentToken, viper.Get(internal.GPSegmentAgentToken))
+	viper.Set(internal.GPSegmentAgentToken, "")
+
+	err := greenplum.HandleSegmentAgent("127.0.0.1:0")
+	assert.ErrorContains(t, err, internal.GPSegmentAgentToken+" is required")
+}
+
+func TestSegmentAgentClient_TLS(t *testing.T) {
+	dataDir := t.TempDir()
+	server := httptest.NewTLSServer(greenplum.NewSegmentAgent("secret", map[int]string{1: dataDir}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	caPath := path.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caPath, caPEM, 0600))
+
+	for setting, value := range map[string]string{
+		internal.GPSegmentsTransport:  greenplum.SegmentAgentTransport,
+		internal.GPSegmentAgentPort:   serverURL.Port(),
+		internal.GPSegmentAgentToken:  "secret",
+		internal.GPSegmentAgentTLSCA:  caPath,
+		internal.GPSegmentAgentSocket: "",
+	} {
+		defer viper.Set(setting, viper.Get(setting))
+		viper.Set(setting, value)
+	}
+	client, err := greenplum.ConfigureSegmentAgentClient()
+	require.NoError(t, err)
+
+	segment := &cluster.SegConfig{ContentID: 1, Hostname: serverURL.Hostname(), DataDir: dataDir}
+	require.NoError(t, client.WriteConfig(segment, "pg_hba.conf", "local all all trust"))
+	contents, err := os.ReadFile(path.Join(dataDir, "pg_hba.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, "local all all trust\n", string(contents))
+}
+
+func TestParseSegmentAgentDataDirs(t *testing.T) {
+	dataDirs, err := greenplum.ParseSegmentAgentDataDirs("-1:/data/master/gpseg-1, 0:/data/primary/gpseg0/")
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{-1: "/data/master/gpseg-1", 0: "/data/primary/gpseg0"}, dataDirs)
+
+	_, err = greenplum.ParseSegmentAgentDataDirs("")
+	assert.ErrorContains(t, err, "is required")
+	_, err = greenplum.ParseSegmentAgentDataDirs("0:relative")
+	assert.ErrorContains(t, err, "invalid")
+	_, err = greenplum.ParseSegmentAgentDataDirs("gpseg0:/data")
+	assert.ErrorContains(t, err, "invalid")
+}
@@ -6,7 +6,6 @@ import (
 	"os"
 	"os/exec"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
@@ -15,18 +14,22 @@ import (
 	"github.com/wal-g/tracelog"
 )
 
+// SegCmdRunJSONArgsFlag makes seg-cmd-run read the command args as a JSON array, so they are passed
+// to the command as is. Otherwise the args are a single string split by the whitespace.
+const SegCmdRunJSONArgsFlag = "json-args"
+
 type SegCmdRunner struct {
 	// content ID of the segment
 	contentID int
 	// name of the command
 	cmdName string
 	// args for the command
-	cmdArgs string
+	cmdArgs []string
 	// controls the frequency of the command execution state updates
 	stateUpdateInterval time.Duration
 }
 
-func NewSegCmdRunner(contentID int, cmdName, cmdArgs string, updInterval time.Duration) *SegCmdRunner {
+func NewSegCmdRunner(contentID int, cmdName string, cmdArgs []string, updInterval time.Duration) *SegCmdRunner {
 	return &SegCmdRunner{
 		contentID:           contentID,
 		cmdName:             cmdName,
@@ -37,7 +40,7 @@ func NewSegCmdRunner(contentID int, cmdName, cmdArgs string, updInterval time.Du
 
 func (r *SegCmdRunner) Run() {
 	args := []string{r.cmdName, fmt.Sprintf("--content-id=%d", r.contentID)}
-	args = append(args, strings.Fields(r.cmdArgs)...)
+	args = append(args, r.cmdArgs...)
 
 	if internal.CfgFile != "" {
 		args = append(args, "--config", internal.CfgFile)
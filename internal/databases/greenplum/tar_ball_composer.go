@@ -15,6 +15,7 @@ import (
 
 	"github.com/apecloud/dataprotection-wal-g/internal"
 	"github.com/apecloud/dataprotection-wal-g/internal/crypto"
+	"github.com/apecloud/dataprotection-wal-g/internal/crypto/envelope"
 	"github.com/wal-g/tracelog"
 	"golang.org/x/sync/errgroup"
 )
@@ -194,7 +195,10 @@ func (c *GpTarBallComposer) FinishComposing() (internal.TarFileSets, error) {
 
 	c.addFileWaitGroup.Wait()
 
-	err = internal.UploadDto(c.uploader.Folder(), c.aoStorageUploader.GetFiles(), getAOFilesMetadataPath(c.backupName))
+	aoFiles := c.aoStorageUploader.GetFiles()
+	// the backup depends on the data keys of the AO files reused from the previous backups
+	envelope.ReferenceKeys(aoFiles.dataKeyIDs()...)
+	err = internal.UploadBackupDto(c.uploader, aoFiles, getAOFilesMetadataPath(c.backupName))
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload AO files metadata: %v", err)
 	}
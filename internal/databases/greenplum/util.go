@@ -10,7 +10,7 @@ import (
 	"github.com/apecloud/dataprotection-wal-g/utility"
 )
 
-const SegmentsFolderPath = "segments_" + utility.VersionStr + "/"
+const SegmentsFolderPath = utility.SegmentsPath
 
 func FormatSegmentStoragePrefix(contentID int) string {
 	segmentFolderName := fmt.Sprintf("seg%d", contentID)
@@ -22,6 +22,12 @@ func formatSegmentLogPath(contentID int) string {
 	return fmt.Sprintf("%s/%s-seg%d.log", logsDir, SegBackupLogPrefix, contentID)
 }
 
+// formatSegmentCmdLogPath returns the log file of the command run on the segment by the segment agent
+func formatSegmentCmdLogPath(contentID int, cmdName string) string {
+	logsDir := viper.GetString(internal.GPLogsDirectory)
+	return fmt.Sprintf("%s/%s-seg%d-%s.log", logsDir, SegBackupLogPrefix, contentID, cmdName)
+}
+
 func FormatSegmentBackupPath(contentID int) string {
 	return path.Join(FormatSegmentStoragePrefix(contentID), utility.BaseBackupPath)
 }
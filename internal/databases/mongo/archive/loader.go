@@ -321,6 +321,9 @@ func (su *StorageUploader) UploadBackup(stream io.Reader, cmd internal.ErrWaiter
 	}
 
 	backupSentinel := metaConstructor.MetaInfo()
+	if backup, ok := backupSentinel.(*models.Backup); ok {
+		backup.StreamCompression = internal.StreamCompressions(su.Uploader)
+	}
 	if err := internal.UploadSentinel(su.Uploader, backupSentinel, backupName); err != nil {
 		return fmt.Errorf("can not upload sentinel: %+v", err)
 	}
@@ -18,7 +18,8 @@ import (
 
 type BackupDetail struct {
 	models.Backup
-	ModifyTime time.Time `json:"modify_time"`
+	ModifyTime   time.Time                    `json:"modify_time"`
+	Verification *internal.BackupVerification `json:"verification,omitempty"`
 }
 
 func NewBackupDetail(backupTime internal.BackupTime, sentinel *models.Backup) *BackupDetail {
@@ -41,6 +42,10 @@ func HandleDetailedBackupList(folder storage.Folder, output io.Writer, pretty, j
 			return errors.Wrapf(err, "Unable to load sentinel of backup %v", backupTime.BackupName)
 		}
 		backupDetail := NewBackupDetail(backupTime, sentinel)
+		backupDetail.Verification, err = internal.FetchBackupVerification(folder, backupTime.BackupName)
+		if err != nil {
+			return err
+		}
 		backupDetails = append(backupDetails, backupDetail)
 	}
 
@@ -66,7 +71,7 @@ func printBackupDetailsPretty(backupDetails []*BackupDetail, output io.Writer) {
 
 	writer.AppendHeader(table.Row{
 		"#", "Name", "Type", "Version", "Last modified", "Start time", "Finish time", "Hostname", "Start Ts", "End Ts",
-		"Uncompressed size", "Compressed size", "Permanent", "User data"})
+		"Uncompressed size", "Compressed size", "Permanent", "User data", "Verification"})
 	for i, backupDetail := range backupDetails {
 		writer.AppendRow(table.Row{
 			i,
@@ -83,6 +88,7 @@ func printBackupDetailsPretty(backupDetails []*BackupDetail, output io.Writer) {
 			backupDetail.CompressedSize,
 			backupDetail.Permanent,
 			marshalUserData(backupDetail.UserData),
+			backupDetail.Verification,
 		})
 	}
 }
@@ -92,12 +98,12 @@ func printBackupDetailsWithTabFormatter(backupDetails []*BackupDetail, output io
 	defer func() { _ = writer.Flush() }()
 	_, err := fmt.Fprintln(writer,
 		"name\ttype\tversion\tlast_modified\tstart_time\tfinish_time\thostname\tstart_ts\tend_ts\tuncompressed_size"+
-			"\tcompressed_size\tpermanent\tuser_data")
+			"\tcompressed_size\tpermanent\tuser_data\tverification")
 	if err != nil {
 		return err
 	}
 	for _, backupDetail := range backupDetails {
-		_, err = fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+		_, err = fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
 			backupDetail.BackupName,
 			backupDetail.BackupType,
 			backupDetail.MongoMeta.Version,
@@ -111,6 +117,7 @@ func printBackupDetailsWithTabFormatter(backupDetails []*BackupDetail, output io
 			backupDetail.CompressedSize,
 			backupDetail.Permanent,
 			marshalUserData(backupDetail.UserData),
+			backupDetail.Verification,
 		)
 		if err != nil {
 			return err
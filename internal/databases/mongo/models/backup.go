@@ -19,6 +19,8 @@ type Backup struct {
 	Permanent        bool        `json:"Permanent"`
 	UncompressedSize int64       `json:"UncompressedSize,omitempty"`
 	CompressedSize   int64       `json:"DataSize,omitempty"`
+	// StreamCompression maps the backup streams to the algorithms chosen by the adaptive compression
+	StreamCompression map[string]string `json:"StreamCompression,omitempty"`
 }
 
 func (b *Backup) Name() string {
@@ -0,0 +1,58 @@
+package mongo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/mongo/archive"
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/mongo/models"
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/mongo/oplog"
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/mongo/stages"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+)
+
+// RestoreCheckOplogFileName is the file in the logs dir with the oplog in bson format for mongorestore --oplogFile
+const RestoreCheckOplogFileName = "oplog.bson"
+
+// FetchRestoreCheckOplog downloads the oplog of the backup into logsDir for the backup-verify restore command.
+// The oplog is fetched until the end of the backup unless the until time is set.
+func FetchRestoreCheckOplog(ctx context.Context, _ storage.Folder, backup internal.Backup,
+	logsDir string, until time.Time) error {
+	var sentinel models.Backup
+	if err := backup.FetchSentinel(&sentinel); err != nil {
+		return err
+	}
+	since, untilTS := sentinel.MongoMeta.Before.LastMajTS, sentinel.MongoMeta.After.LastMajTS
+	if !until.IsZero() {
+		untilTS = models.Timestamp{TS: uint32(until.Unix())}
+	}
+
+	downloader, err := archive.NewStorageDownloader(archive.NewDefaultStorageSettings())
+	if err != nil {
+		return err
+	}
+	archives, err := downloader.ListOplogArchives()
+	if err != nil {
+		return err
+	}
+	path, err := archive.SequenceBetweenTS(archives, since, untilTS)
+	if err != nil {
+		return err
+	}
+
+	// the applier closes the file when the replay is over
+	file, err := os.Create(filepath.Join(logsDir, RestoreCheckOplogFileName))
+	if err != nil {
+		return err
+	}
+	formatApplier, err := oplog.NewWriteApplier("bson", file)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	return HandleOplogReplay(ctx, since, untilTS, stages.NewStorageFetcher(downloader, path),
+		stages.NewGenericApplier(formatApplier))
+}
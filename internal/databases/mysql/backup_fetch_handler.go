@@ -12,7 +12,15 @@ func HandleBackupFetch(folder storage.Folder,
 	targetBackupSelector internal.BackupSelector,
 	restoreCmd *exec.Cmd,
 	prepareCmd *exec.Cmd) {
-	internal.HandleBackupFetch(folder, targetBackupSelector, internal.GetBackupToCommandFetcher(restoreCmd))
+	fetcher := internal.GetBackupToCommandFetcher(restoreCmd)
+	internal.HandleBackupFetch(folder, targetBackupSelector, func(folder storage.Folder, backup internal.Backup) {
+		var sentinel StreamSentinelDto
+		err := backup.FetchSentinel(&sentinel)
+		tracelog.ErrorLogger.FatalfOnError("Failed to fetch backup sentinel: %v", err)
+		err = internal.LoadCompressionDictionary(folder, sentinel.CompressionDictionaryID)
+		tracelog.ErrorLogger.FatalOnError(err)
+		fetcher(folder, backup)
+	})
 
 	// Prepare Backup
 	if prepareCmd != nil {
@@ -31,6 +31,8 @@ type BackupDetail struct {
 
 	IsPermanent bool        `json:"is_permanent"`
 	UserData    interface{} `json:"user_data,omitempty"`
+
+	Verification *internal.BackupVerification `json:"verification,omitempty"`
 }
 
 //nolint:gocritic
@@ -62,7 +64,10 @@ func HandleDetailedBackupList(folder storage.Folder, pretty, json bool) {
 		err = backup.FetchSentinel(&sentinel)
 		tracelog.ErrorLogger.FatalfOnError("Failed to load sentinel for backup %s", err)
 
-		backupDetails = append(backupDetails, NewBackupDetail(backupTime, sentinel))
+		backupDetail := NewBackupDetail(backupTime, sentinel)
+		backupDetail.Verification, err = internal.FetchBackupVerification(folder, backupTime.BackupName)
+		tracelog.ErrorLogger.FatalOnError(err)
+		backupDetails = append(backupDetails, backupDetail)
 	}
 
 	switch {
@@ -80,14 +85,14 @@ func HandleDetailedBackupList(folder storage.Folder, pretty, json bool) {
 func writeBackupListDetails(backupDetails []BackupDetail, output io.Writer) error {
 	writer := tabwriter.NewWriter(output, 0, 0, 1, ' ', 0)
 	defer writer.Flush()
-	_, err := fmt.Fprintln(writer, "name\tlast_modified\tstart_time\tfinish_time\thostname\tbinlog_start\tbinlog_end\tuncompressed_size\tcompressed_size\tis_permanent") //nolint:lll
+	_, err := fmt.Fprintln(writer, "name\tlast_modified\tstart_time\tfinish_time\thostname\tbinlog_start\tbinlog_end\tuncompressed_size\tcompressed_size\tis_permanent\tverification") //nolint:lll
 	if err != nil {
 		return err
 	}
 	for i := len(backupDetails) - 1; i >= 0; i-- {
 		b := backupDetails[i]
-		_, err = fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v",
-			b.BackupName, b.ModifyTime.Format(time.RFC3339), b.StartLocalTime.Format(time.RFC850), b.StopLocalTime.Format(time.RFC850), b.Hostname, b.BinLogStart, b.BinLogEnd, b.UncompressedSize, b.CompressedSize, b.IsPermanent) //nolint:lll
+		_, err = fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v",
+			b.BackupName, b.ModifyTime.Format(time.RFC3339), b.StartLocalTime.Format(time.RFC850), b.StopLocalTime.Format(time.RFC850), b.Hostname, b.BinLogStart, b.BinLogEnd, b.UncompressedSize, b.CompressedSize, b.IsPermanent, b.Verification) //nolint:lll
 		if err != nil {
 			return err
 		}
@@ -100,9 +105,9 @@ func writePrettyBackupListDetails(backupDetails []BackupDetail, output io.Writer
 	writer := table.NewWriter()
 	writer.SetOutputMirror(output)
 	defer writer.Render()
-	writer.AppendHeader(table.Row{"#", "Name", "Last modified", "Start time", "Finish time", "Hostname", "Binlog start", "Binlog end", "Uncompressed size", "Compressed size", "Permanent"}) //nolint:lll
+	writer.AppendHeader(table.Row{"#", "Name", "Last modified", "Start time", "Finish time", "Hostname", "Binlog start", "Binlog end", "Uncompressed size", "Compressed size", "Permanent", "Verification"}) //nolint:lll
 	for idx := range backupDetails {
 		b := &backupDetails[idx]
-		writer.AppendRow(table.Row{idx, b.BackupName, b.ModifyTime.Format(time.RFC850), b.StartLocalTime.Format(time.RFC850), b.StopLocalTime.Format(time.RFC850), b.Hostname, b.BinLogStart, b.BinLogEnd, b.UncompressedSize, b.CompressedSize, b.IsPermanent}) //nolint:lll
+		writer.AppendRow(table.Row{idx, b.BackupName, b.ModifyTime.Format(time.RFC850), b.StartLocalTime.Format(time.RFC850), b.StopLocalTime.Format(time.RFC850), b.Hostname, b.BinLogStart, b.BinLogEnd, b.UncompressedSize, b.CompressedSize, b.IsPermanent, b.Verification}) //nolint:lll
 	}
 }
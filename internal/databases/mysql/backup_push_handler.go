@@ -72,6 +72,9 @@ func HandleBackupPush(folder storage.Folder, uploader internal.Uploader,
 		UncompressedSize: rawSize,
 		IsPermanent:      isPermanent,
 		UserData:         userData,
+
+		CompressionDictionaryID: internal.CompressionDictionaryID(uploader.Compression()),
+		StreamCompression:       internal.StreamCompressions(uploader),
 	}
 	tracelog.InfoLogger.Printf("Backup sentinel: %s", sentinel.String())
 
@@ -63,7 +63,10 @@ func backupCopyingInfo(backupName, prefix string, from storage.Folder, to storag
 		return nil, err
 	}
 
-	var hasBackupPrefix = func(object storage.Object) bool { return strings.HasPrefix(object.GetName(), backupPrefix) }
+	// the data keys are copied as well, the backup objects may be encrypted with them
+	var hasBackupPrefix = func(object storage.Object) bool {
+		return strings.HasPrefix(object.GetName(), backupPrefix) || internal.IsDataKeyObject(object.GetName())
+	}
 	return copy.BuildCopyingInfos(from, to, objects, hasBackupPrefix, func(object storage.Object) string {
 		return strings.Replace(object.GetName(), backup.Name, prefix+backup.Name, 1)
 	}), nil
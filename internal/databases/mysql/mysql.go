@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"database/sql"
@@ -64,17 +65,21 @@ func getMySQLGTIDExecuted(db *sql.DB, flavor string) (gomysql.GTIDSet, error) {
 }
 
 func getLastUploadedBinlog(folder storage.Folder) (string, error) {
-	logFiles, _, err := folder.GetSubFolder(BinlogPath).ListFolder()
+	var lastLogFile storage.Object
+	err := storage.IterateObjects(context.Background(), folder.GetSubFolder(BinlogPath), storage.ListOptions{},
+		func(logFile storage.Object) error {
+			if lastLogFile == nil || !logFile.GetLastModified().Before(lastLogFile.GetLastModified()) {
+				lastLogFile = logFile
+			}
+			return nil
+		})
 	if err != nil {
 		return "", err
 	}
-	sort.Slice(logFiles, func(i, j int) bool {
-		return logFiles[i].GetLastModified().Before(logFiles[j].GetLastModified())
-	})
-	if len(logFiles) == 0 {
+	if lastLogFile == nil {
 		return "", nil
 	}
-	name := logFiles[len(logFiles)-1].GetName()
+	name := lastLogFile.GetName()
 	if ext := path.Ext(name); compression.FindDecompressor(ext) != nil {
 		// remove archive extension (like .br)
 		name = strings.TrimSuffix(name, ext)
@@ -84,16 +89,18 @@ func getLastUploadedBinlog(folder storage.Folder) (string, error) {
 
 func getLastUploadedBinlogBeforeGTID(folder storage.Folder, gtid gomysql.GTIDSet, flavor string) (string, error) {
 	folder = folder.GetSubFolder(BinlogPath)
-	logFiles, _, err := folder.ListFolder()
+	// only the names and the upload times are kept, the binlogs are checked from the newest one
+	var logFiles []storage.Object
+	err := storage.IterateObjects(context.Background(), folder, storage.ListOptions{}, func(logFile storage.Object) error {
+		logFiles = append(logFiles, storage.NewLocalObject(logFile.GetName(), logFile.GetLastModified(), 0))
+		return nil
+	})
 	if err != nil {
 		return "", err
 	}
 	sort.Slice(logFiles, func(i, j int) bool {
 		return logFiles[i].GetLastModified().Before(logFiles[j].GetLastModified())
 	})
-	if len(logFiles) == 0 {
-		return "", nil
-	}
 	for i := len(logFiles) - 1; i > 0; i-- {
 		prevGtid, err := GetBinlogPreviousGTIDsRemote(folder, logFiles[i].GetName(), flavor)
 		if err != nil {
@@ -190,6 +197,10 @@ type StreamSentinelDto struct {
 	IsPermanent bool        `json:"IsPermanent,omitempty"`
 	UserData    interface{} `json:"UserData,omitempty"`
 
+	CompressionDictionaryID uint32 `json:"CompressionDictionaryID,omitempty"`
+	// StreamCompression maps the backup streams to the algorithms chosen by the adaptive compression
+	StreamCompression map[string]string `json:"StreamCompression,omitempty"`
+
 	//todo: add other fields from internal.GenericMetadata
 }
 
@@ -304,51 +315,49 @@ func getBinlogSinceTS(folder storage.Folder, backup internal.Backup) (time.Time,
 	tracelog.InfoLogger.Printf("Backup sentinel: %s", streamSentinel.String())
 
 	// case when backup was uploaded before first binlog
-	sentinels, _, err := folder.GetSubFolder(utility.BaseBackupPath).ListFolder()
-	if err != nil {
-		return time.Time{}, err
-	}
-	for _, sentinel := range sentinels {
-		if strings.HasPrefix(sentinel.GetName(), backup.Name) {
+	err = storage.IterateObjects(context.Background(), folder.GetSubFolder(utility.BaseBackupPath),
+		storage.ListOptions{Prefix: backup.Name}, func(sentinel storage.Object) error {
 			tracelog.InfoLogger.Printf("Backup sentinel file: %s (%s)", sentinel.GetName(), sentinel.GetLastModified())
 			if sentinel.GetLastModified().Before(startTS) {
 				startTS = sentinel.GetLastModified()
 			}
-		}
-	}
-	// case when binlog was uploaded before backup
-	binlogs, _, err := folder.GetSubFolder(BinlogPath).ListFolder()
+			return nil
+		})
 	if err != nil {
 		return time.Time{}, err
 	}
-	for _, binlog := range binlogs {
-		if strings.HasPrefix(binlog.GetName(), streamSentinel.BinLogStart) {
+	// case when binlog was uploaded before backup
+	err = storage.IterateObjects(context.Background(), folder.GetSubFolder(BinlogPath),
+		storage.ListOptions{Prefix: streamSentinel.BinLogStart}, func(binlog storage.Object) error {
 			tracelog.InfoLogger.Printf("Backup start binlog: %s (%s)", binlog.GetName(), binlog.GetLastModified())
 			if binlog.GetLastModified().Before(startTS) {
 				startTS = binlog.GetLastModified()
 			}
-		}
+			return nil
+		})
+	if err != nil {
+		return time.Time{}, err
 	}
 	return startTS, nil
 }
 
 // getLogsCoveringInterval lists the operation logs that cover the interval
 func getLogsCoveringInterval(folder storage.Folder, start time.Time, includeStart bool, endBinlogTS time.Time) ([]storage.Object, error) {
-	logFiles, _, err := folder.ListFolder()
-	if err != nil {
-		return nil, err
-	}
-	sort.Slice(logFiles, func(i, j int) bool {
-		return logFiles[i].GetLastModified().Before(logFiles[j].GetLastModified())
-	})
 	var logsToFetch []storage.Object
-	for _, logFile := range logFiles {
+	err := storage.IterateObjects(context.Background(), folder, storage.ListOptions{}, func(logFile storage.Object) error {
 		if logFile.GetLastModified().After(endBinlogTS) {
-			continue // don't fetch binlogs from future
+			return nil // don't fetch binlogs from future
 		}
 		if start.Before(logFile.GetLastModified()) || includeStart && start.Equal(logFile.GetLastModified()) {
 			logsToFetch = append(logsToFetch, logFile)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	sort.Slice(logsToFetch, func(i, j int) bool {
+		return logsToFetch[i].GetLastModified().Before(logsToFetch[j].GetLastModified())
+	})
 	return logsToFetch, nil
 }
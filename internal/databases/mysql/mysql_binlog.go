@@ -2,6 +2,7 @@ package mysql
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -153,18 +154,24 @@ func GetBinlogStartTimestamp(filename string, flavor string) (time.Time, error)
 
 func GetBinlogTS(folder storage.Folder, binlogName string) (time.Time, error) {
 	logFolder := folder.GetSubFolder(BinlogPath)
-	logFiles, _, err := logFolder.ListFolder()
+	var binlogTS *time.Time
+	err := storage.IterateObjects(context.Background(), logFolder, storage.ListOptions{Prefix: binlogName},
+		func(logFile storage.Object) error {
+			logFileName := strings.TrimSuffix(logFile.GetName(), filepath.Ext(logFile.GetName()))
+			if logFileName == binlogName {
+				lastModified := logFile.GetLastModified()
+				binlogTS = &lastModified
+				return storage.ErrStopIteration
+			}
+			return nil
+		})
 	if err != nil {
 		return time.Time{}, err
 	}
-
-	for _, logFile := range logFiles {
-		logFileName := strings.TrimSuffix(logFile.GetName(), filepath.Ext(logFile.GetName()))
-		if logFileName == binlogName {
-			return logFile.GetLastModified(), nil
-		}
+	if binlogTS == nil {
+		return time.Time{}, fmt.Errorf("binlog %s not found", binlogName)
 	}
-	return time.Time{}, fmt.Errorf("binlog %s not found", binlogName)
+	return *binlogTS, nil
 }
 
 /*
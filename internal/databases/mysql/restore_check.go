@@ -0,0 +1,35 @@
+package mysql
+
+import (
+	"context"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/wal-g/tracelog"
+)
+
+// FetchRestoreCheckBinlogs downloads the binlogs of the backup into logsDir for the backup-verify restore command.
+// The binlogs are fetched until the end of the backup unless the until time is set.
+func FetchRestoreCheckBinlogs(_ context.Context, rootFolder storage.Folder, backup internal.Backup,
+	logsDir string, until time.Time) error {
+	startTS, err := getBinlogSinceTS(rootFolder, backup)
+	if err != nil {
+		return err
+	}
+	if until.IsZero() {
+		var sentinel StreamSentinelDto
+		if err = backup.FetchSentinel(&sentinel); err != nil {
+			return err
+		}
+		until = sentinel.StopLocalTime
+	}
+
+	handler := newIndexHandler(logsDir)
+	tracelog.InfoLogger.Printf("Fetching binlogs since %s until %s", startTS, until)
+	if err = fetchLogs(rootFolder, logsDir, startTS, until, utility.MaxTime, handler); err != nil {
+		return err
+	}
+	return handler.createIndexFile()
+}
@@ -68,6 +68,10 @@ func deltaFetchRecursionOld(backup Backup, folder storage.Folder, dbDataDirector
 	if err != nil {
 		return err
 	}
+	err = internal.LoadCompressionDictionary(folder, sentinelDto.CompressionDictionaryID)
+	if err != nil {
+		return err
+	}
 	tablespaceSpec = chooseTablespaceSpecification(sentinelDto.TablespaceSpec, tablespaceSpec)
 	sentinelDto.TablespaceSpec = tablespaceSpec
 
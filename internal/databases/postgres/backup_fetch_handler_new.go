@@ -48,6 +48,10 @@ func deltaFetchRecursionNew(cfg *FetchConfig) error {
 	if err != nil {
 		return err
 	}
+	err = internal.LoadCompressionDictionary(cfg.folder, sentinelDto.CompressionDictionaryID)
+	if err != nil {
+		return err
+	}
 	cfg.tablespaceSpec = chooseTablespaceSpecification(sentinelDto.TablespaceSpec, cfg.tablespaceSpec)
 	sentinelDto.TablespaceSpec = cfg.tablespaceSpec
 
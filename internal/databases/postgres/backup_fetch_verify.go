@@ -0,0 +1,290 @@
+package postgres
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+// RestoreVerificationReport is the result of backup-fetch --verify-only
+type RestoreVerificationReport struct {
+	// Backups are the verified backups, from the fetched one down to its base backup
+	Backups        []string                `json:"backups"`
+	CheckedFiles   int                     `json:"checked_files"`
+	CorruptBlocks  []RelationCorruptBlocks `json:"corrupt_blocks"`
+	SizeMismatches []FileSizeMismatch      `json:"size_mismatches"`
+	MissingFiles   []string                `json:"missing_files"`
+	Errors         []string                `json:"errors"`
+}
+
+// RelationCorruptBlocks lists the blocks of the relation with wrong page checksums in the backup.
+// The block numbers are counted from the start of the relation, not of its segment file.
+type RelationCorruptBlocks struct {
+	Backup   string   `json:"backup"`
+	Relation string   `json:"relation"`
+	Blocks   []uint32 `json:"blocks"`
+}
+
+// FileSizeMismatch is a file restored with a size other than recorded in the files metadata
+type FileSizeMismatch struct {
+	Backup       string `json:"backup"`
+	File         string `json:"file"`
+	ExpectedSize int64  `json:"expected_size"`
+	ActualSize   int64  `json:"actual_size"`
+}
+
+func newRestoreVerificationReport() *RestoreVerificationReport {
+	return &RestoreVerificationReport{
+		Backups:        make([]string, 0),
+		CorruptBlocks:  make([]RelationCorruptBlocks, 0),
+		SizeMismatches: make([]FileSizeMismatch, 0),
+		MissingFiles:   make([]string, 0),
+		Errors:         make([]string, 0),
+	}
+}
+
+// IsOK checks that no problems were found
+func (report *RestoreVerificationReport) IsOK() bool {
+	return len(report.CorruptBlocks) == 0 && len(report.SizeMismatches) == 0 &&
+		len(report.MissingFiles) == 0 && len(report.Errors) == 0
+}
+
+// verifiedFile is the result of the verification of a single tar entry
+type verifiedFile struct {
+	isRegular     bool
+	corruptBlocks []uint32
+	// size is the size of the file restored from the tar entry
+	size int64
+	err  error
+}
+
+// VerifyTarInterpreter reads the backup files from the tars without writing them to disk.
+// It checks the page checksums of the paged files and records the size of the restored files.
+type VerifyTarInterpreter struct {
+	Sentinel      BackupSentinelDto
+	FilesMetadata FilesMetadataDto
+	FilesToUnwrap map[string]bool
+
+	// the tars are read in parallel and the failed ones are read again, so the results are kept per file
+	mutex sync.Mutex
+	files map[string]*verifiedFile
+}
+
+func NewVerifyTarInterpreter(sentinel BackupSentinelDto, filesMetadata FilesMetadataDto,
+	filesToUnwrap map[string]bool) *VerifyTarInterpreter {
+	return &VerifyTarInterpreter{
+		Sentinel:      sentinel,
+		FilesMetadata: filesMetadata,
+		FilesToUnwrap: filesToUnwrap,
+		files:         make(map[string]*verifiedFile),
+	}
+}
+
+// Interpret verifies the tar entry, only the errors of reading the tar are returned
+func (interpreter *VerifyTarInterpreter) Interpret(fileReader io.Reader, header *tar.Header) error {
+	if interpreter.FilesToUnwrap != nil && !interpreter.FilesToUnwrap[header.Name] {
+		return nil
+	}
+	tracelog.DebugLogger.Println("Verifying: ", header.Name)
+	file := &verifiedFile{}
+	if header.Typeflag == tar.TypeReg || header.Typeflag == tar.TypeRegA {
+		file = interpreter.verifyRegularFile(fileReader, header)
+	}
+	// read the rest of the entry to check the integrity of the tar
+	if _, err := io.Copy(io.Discard, fileReader); err != nil {
+		return errors.Wrapf(err, "Interpret: failed to read '%s'", header.Name)
+	}
+
+	interpreter.mutex.Lock()
+	defer interpreter.mutex.Unlock()
+	interpreter.files[header.Name] = file
+	return nil
+}
+
+func (interpreter *VerifyTarInterpreter) verifyRegularFile(fileReader io.Reader, header *tar.Header) *verifiedFile {
+	fileDescription, haveFileDescription := interpreter.FilesMetadata.Files[header.Name]
+	if !haveFileDescription || !fileDescription.IsIncremented || !interpreter.Sentinel.IsIncremental() {
+		corruptBlocks, err := VerifyPagedFileBase(header.Name, header.FileInfo(), fileReader)
+		return &verifiedFile{isRegular: true, corruptBlocks: corruptBlocks, size: header.Size, err: err}
+	}
+
+	fileSize, diffBlockCount, diffMap, err := GetIncrementHeaderFields(fileReader)
+	if err != nil {
+		return &verifiedFile{isRegular: true, err: errors.Wrap(err, "invalid increment")}
+	}
+	// the pages are verified as a part of the file the increment is applied to
+	fileHeader := *header
+	fileHeader.Size = int64(fileSize)
+	corruptBlocks, err := verifyPageBlocks(header.Name, fileHeader.FileInfo(), fileReader,
+		getIncrementBlockNumbers(diffBlockCount, diffMap))
+	return &verifiedFile{isRegular: true, corruptBlocks: corruptBlocks, size: fileHeader.Size, err: err}
+}
+
+// addToReport adds the problems found in the backup to the report. The missing files are checked only
+// if all the tars of the backup were read.
+func (interpreter *VerifyTarInterpreter) addToReport(report *RestoreVerificationReport, backupName string,
+	checkMissingFiles bool) {
+	interpreter.mutex.Lock()
+	defer interpreter.mutex.Unlock()
+
+	fileNames := make([]string, 0, len(interpreter.files))
+	for fileName := range interpreter.files {
+		fileNames = append(fileNames, fileName)
+	}
+	sort.Strings(fileNames)
+	corruptRelations := make(map[string][]uint32)
+	for _, fileName := range fileNames {
+		file := interpreter.files[fileName]
+		if !file.isRegular {
+			continue
+		}
+		report.CheckedFiles++
+		if file.err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: failed to verify '%s': %v", backupName, fileName, file.err))
+			continue
+		}
+		fileDescription := interpreter.FilesMetadata.Files[fileName]
+		if fileDescription.Size != 0 && fileDescription.Size != file.size {
+			report.SizeMismatches = append(report.SizeMismatches, FileSizeMismatch{
+				Backup:       backupName,
+				File:         fileName,
+				ExpectedSize: fileDescription.Size,
+				ActualSize:   file.size,
+			})
+		}
+		if len(file.corruptBlocks) > 0 {
+			relation, blocks := toRelationBlocks(fileName, file.corruptBlocks)
+			corruptRelations[relation] = append(corruptRelations[relation], blocks...)
+		}
+	}
+
+	relations := make([]string, 0, len(corruptRelations))
+	for relation := range corruptRelations {
+		relations = append(relations, relation)
+	}
+	sort.Strings(relations)
+	for _, relation := range relations {
+		blocks := corruptRelations[relation]
+		slices.Sort(blocks)
+		report.CorruptBlocks = append(report.CorruptBlocks,
+			RelationCorruptBlocks{Backup: backupName, Relation: relation, Blocks: blocks})
+	}
+
+	if !checkMissingFiles {
+		return
+	}
+	missingFiles := make([]string, 0)
+	for fileName, fileDescription := range interpreter.FilesMetadata.Files {
+		if interpreter.FilesToUnwrap != nil && !interpreter.FilesToUnwrap[fileName] {
+			continue
+		}
+		// the skipped files are restored from the base backup and verified there
+		if _, ok := interpreter.files[fileName]; !ok && !fileDescription.IsSkipped {
+			missingFiles = append(missingFiles, backupName+": "+fileName)
+		}
+	}
+	sort.Strings(missingFiles)
+	report.MissingFiles = append(report.MissingFiles, missingFiles...)
+}
+
+// toRelationBlocks converts the block numbers of the segment file to the block numbers of the relation
+func toRelationBlocks(fileName string, blocks []uint32) (string, []uint32) {
+	relFileID, err := GetRelFileIDFrom(fileName)
+	if err != nil || relFileID == 0 {
+		return fileName, blocks
+	}
+	segmentBlockOffset := uint32(relFileID * BlocksInRelFile)
+	relationBlocks := make([]uint32, 0, len(blocks))
+	for _, blockNo := range blocks {
+		relationBlocks = append(relationBlocks, segmentBlockOffset+blockNo)
+	}
+	return strings.TrimSuffix(fileName, fmt.Sprintf(".%d", relFileID)), relationBlocks
+}
+
+// VerifyBackupRestore reads the backup and the backups it is based on like backup-fetch does, but
+// instead of writing the files it verifies them. The problems found are returned in the report,
+// the error is returned only if the backup can't be read at all.
+func VerifyBackupRestore(rootFolder storage.Folder, backup Backup,
+	filesToUnwrap map[string]bool) (*RestoreVerificationReport, error) {
+	report := newRestoreVerificationReport()
+	for {
+		sentinelDto, filesMetaDto, err := backup.GetSentinelAndFilesMetadata()
+		if err != nil {
+			return nil, err
+		}
+		err = internal.LoadCompressionDictionary(rootFolder, sentinelDto.CompressionDictionaryID)
+		if err != nil {
+			return nil, err
+		}
+		tracelog.InfoLogger.Printf("Verifying backup %s\n", backup.Name)
+		report.Backups = append(report.Backups, backup.Name)
+		err = verifyBackupTars(backup, sentinelDto, filesMetaDto, filesToUnwrap, report)
+		if err != nil {
+			return nil, err
+		}
+
+		if !sentinelDto.IsIncremental() {
+			return report, nil
+		}
+		filesToUnwrap, err = GetBaseFilesToUnwrap(filesMetaDto.Files, filesToUnwrap)
+		if err != nil {
+			return nil, err
+		}
+		backup = NewBackup(rootFolder.GetSubFolder(utility.BaseBackupPath), *sentinelDto.IncrementFrom)
+	}
+}
+
+func verifyBackupTars(backup Backup, sentinelDto BackupSentinelDto, filesMetaDto FilesMetadataDto,
+	filesToUnwrap map[string]bool, report *RestoreVerificationReport) error {
+	tarsToExtract, pgControlKey, err := FilesToExtractProviderImpl{}.Get(backup, filesToUnwrap, true)
+	if err != nil {
+		return err
+	}
+	if pgControlKey != "" {
+		tarsToExtract = append(tarsToExtract, internal.NewStorageReaderMaker(backup.getTarPartitionFolder(), pgControlKey))
+	} else if IsPgControlRequired(backup) {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", backup.Name, newPgControlNotFoundError()))
+	}
+	if len(tarsToExtract) == 0 {
+		return nil
+	}
+
+	interpreter := NewVerifyTarInterpreter(sentinelDto, filesMetaDto, filesToUnwrap)
+	err = internal.ExtractAll(interpreter, tarsToExtract)
+	if err != nil {
+		report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", backup.Name, err))
+	}
+	interpreter.addToReport(report, backup.Name, err == nil)
+	return nil
+}
+
+// GetPgFetcherVerifyOnly returns the fetcher of backup-fetch --verify-only, it writes the report to the output
+func GetPgFetcherVerifyOnly(fileMask string, output io.Writer) func(rootFolder storage.Folder, backup internal.Backup) {
+	return func(rootFolder storage.Folder, backup internal.Backup) {
+		pgBackup := ToPgBackup(backup)
+		filesToUnwrap, err := pgBackup.GetFilesToUnwrap(fileMask)
+		tracelog.ErrorLogger.FatalfOnError("Failed to verify backup: %v\n", err)
+
+		report, err := VerifyBackupRestore(rootFolder, pgBackup, filesToUnwrap)
+		tracelog.ErrorLogger.FatalfOnError("Failed to verify backup: %v\n", err)
+
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "    ")
+		tracelog.ErrorLogger.FatalOnError(encoder.Encode(report))
+		if !report.IsOK() {
+			tracelog.ErrorLogger.Fatalf("Backup %s is not restorable, see the report for details\n", backup.Name)
+		}
+		tracelog.InfoLogger.Printf("Backup %s is restorable, %d files verified\n", backup.Name, report.CheckedFiles)
+	}
+}
@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeTestPage builds an initialized page with a valid checksum
+func makeTestPage(blockNo uint32) []byte {
+	page := PgDatabasePage{}
+	binary.LittleEndian.PutUint32(page[4:], 1)
+	binary.LittleEndian.PutUint16(page[12:], headerSize)
+	binary.LittleEndian.PutUint16(page[14:], uint16(DatabasePageSize))
+	binary.LittleEndian.PutUint16(page[16:], uint16(DatabasePageSize))
+	binary.LittleEndian.PutUint16(page[18:], uint16(DatabasePageSize+layoutVersion))
+	checksum := pgChecksumPage(blockNo, &page)
+	binary.LittleEndian.PutUint16(page[PdChecksumOffset:], checksum)
+	return page[:]
+}
+
+func makeCorruptTestPage(blockNo uint32) []byte {
+	page := makeTestPage(blockNo)
+	page[DatabasePageSize-1] ^= 0xFF
+	return page
+}
+
+func interpretTestTar(t *testing.T, interpreter *VerifyTarInterpreter, files map[string][]byte) {
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	for name, content := range files {
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0600,
+			Size: int64(len(content))}))
+		_, err := writer.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+
+	reader := tar.NewReader(&buf)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return
+		}
+		require.NoError(t, err)
+		require.NoError(t, interpreter.Interpret(reader, header))
+	}
+}
+
+func TestVerifyTarInterpreter(t *testing.T) {
+	segmentOffset := uint32(BlocksInRelFile)
+	files := map[string][]byte{
+		"base/5/16384":   append(makeTestPage(0), makeCorruptTestPage(1)...),
+		"base/5/16384.1": append(makeCorruptTestPage(segmentOffset), makeTestPage(segmentOffset+1)...),
+		"base/5/16385":   append(makeTestPage(0), makeTestPage(1)...),
+		"PG_VERSION":     []byte("17\n"),
+	}
+	filesMetadata := FilesMetadataDto{Files: internal.BackupFileList{
+		"base/5/16384":   {Size: 2 * DatabasePageSize},
+		"base/5/16384.1": {Size: 2 * DatabasePageSize},
+		"base/5/16385":   {Size: 2 * DatabasePageSize},
+		"PG_VERSION":     {Size: 4},
+		"base/5/16386":   {Size: DatabasePageSize},
+		"base/5/16387":   {IsSkipped: true},
+	}}
+	interpreter := NewVerifyTarInterpreter(BackupSentinelDto{}, filesMetadata, nil)
+	interpretTestTar(t, interpreter, files)
+
+	report := newRestoreVerificationReport()
+	interpreter.addToReport(report, "base_000000010000000000000002", true)
+	assert.False(t, report.IsOK())
+	assert.Equal(t, 4, report.CheckedFiles)
+	assert.Equal(t, []RelationCorruptBlocks{{
+		Backup:   "base_000000010000000000000002",
+		Relation: "base/5/16384",
+		Blocks:   []uint32{1, segmentOffset},
+	}}, report.CorruptBlocks)
+	assert.Equal(t, []FileSizeMismatch{{
+		Backup:       "base_000000010000000000000002",
+		File:         "PG_VERSION",
+		ExpectedSize: 4,
+		ActualSize:   3,
+	}}, report.SizeMismatches)
+	assert.Equal(t, []string{"base_000000010000000000000002: base/5/16386"}, report.MissingFiles)
+	assert.Empty(t, report.Errors)
+}
+
+func TestVerifyTarInterpreter_FilesToUnwrap(t *testing.T) {
+	files := map[string][]byte{
+		"base/5/16384": makeCorruptTestPage(0),
+		"base/5/16385": makeTestPage(0),
+	}
+	filesMetadata := FilesMetadataDto{Files: internal.BackupFileList{
+		"base/5/16384": {Size: DatabasePageSize},
+		"base/5/16385": {Size: DatabasePageSize},
+	}}
+	interpreter := NewVerifyTarInterpreter(BackupSentinelDto{}, filesMetadata, map[string]bool{"base/5/16385": true})
+	interpretTestTar(t, interpreter, files)
+
+	report := newRestoreVerificationReport()
+	interpreter.addToReport(report, "base_000000010000000000000002", true)
+	assert.True(t, report.IsOK())
+	assert.Equal(t, 1, report.CheckedFiles)
+}
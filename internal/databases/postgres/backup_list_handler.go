@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sort"
 	"text/tabwriter"
 
 	"github.com/apecloud/dataprotection-wal-g/internal"
 	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
 	"github.com/jedib0t/go-pretty/table"
+	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
 )
 
@@ -74,3 +77,76 @@ func WritePrettyBackupListDetails(backupDetails []BackupDetail, output io.Writer
 				b.Hostname, b.DataDir, b.PgVersion, b.StartLsn, b.FinishLsn, b.IsPermanent})
 	}
 }
+
+// TypedBackupTime is a backup-list entry which tells the physical and the logical backups apart
+type TypedBackupTime struct {
+	internal.BackupTime
+	Type string `json:"backup_type"`
+}
+
+// GetTypedBackups lists both the physical and the logical backups sorted by time
+func GetTypedBackups(folder storage.Folder) ([]TypedBackupTime, error) {
+	backups := make([]TypedBackupTime, 0)
+	sources := []struct {
+		folder     storage.Folder
+		backupType string
+	}{
+		{folder.GetSubFolder(utility.BaseBackupPath), PhysicalBackupType},
+		{GetLogicalBackupFolder(folder), LogicalBackupType},
+	}
+	for _, source := range sources {
+		backupTimes, err := internal.GetBackups(source.folder)
+		if _, ok := err.(internal.NoBackupsFoundError); ok {
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list the %s backups", source.backupType)
+		}
+		for _, backupTime := range backupTimes {
+			backups = append(backups, TypedBackupTime{BackupTime: backupTime, Type: source.backupType})
+		}
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].Time.Before(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// HandleBackupList prints the physical and the logical backups with their type
+func HandleBackupList(folder storage.Folder, pretty, json bool) {
+	backups, err := GetTypedBackups(folder)
+	tracelog.ErrorLogger.FatalOnError(err)
+	if len(backups) == 0 {
+		tracelog.InfoLogger.Println("No backups found")
+		return
+	}
+
+	switch {
+	case json:
+		err = internal.WriteAsJSON(backups, os.Stdout, pretty)
+		tracelog.ErrorLogger.FatalOnError(err)
+	case pretty:
+		WritePrettyTypedBackupList(backups, os.Stdout)
+	default:
+		WriteTypedBackupList(backups, os.Stdout)
+	}
+}
+
+func WriteTypedBackupList(backups []TypedBackupTime, output io.Writer) {
+	writer := tabwriter.NewWriter(output, 0, 0, 1, ' ', 0)
+	defer writer.Flush()
+	fmt.Fprintln(writer, "name\tmodified\twal_segment_backup_start\ttype")
+	for _, b := range backups {
+		fmt.Fprintf(writer, "%v\t%v\t%v\t%v\n", b.BackupName, internal.FormatTime(b.Time), b.WalFileName, b.Type)
+	}
+}
+
+func WritePrettyTypedBackupList(backups []TypedBackupTime, output io.Writer) {
+	writer := table.NewWriter()
+	writer.SetOutputMirror(output)
+	defer writer.Render()
+	writer.AppendHeader(table.Row{"#", "Name", "Modified", "WAL segment backup start", "Type"})
+	for i, b := range backups {
+		writer.AppendRow(table.Row{i, b.BackupName, internal.PrettyFormatTime(b.Time), b.WalFileName, b.Type})
+	}
+}
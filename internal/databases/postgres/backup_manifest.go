@@ -0,0 +1,264 @@
+package postgres
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	BackupManifestFilename = "backup_manifest"
+	// BackupManifestChecksumAlgorithm is the checksum algorithm of the files in the generated manifest,
+	// the same as pg_basebackup uses by default
+	BackupManifestChecksumAlgorithm = "CRC32C"
+	// backupManifestSystemIdentifierVersion is the version the manifest has the system identifier since
+	backupManifestSystemIdentifierVersion = 170000
+	backupManifestTimeFormat              = "2006-01-02 15:04:05 GMT"
+
+	// maxReportedManifestIssues limits the manifest mismatches listed in the error
+	maxReportedManifestIssues = 10
+)
+
+// BackupManifest is the backup_manifest of PostgreSQL 13+, see
+// https://www.postgresql.org/docs/current/backup-manifest-format.html
+type BackupManifest struct {
+	Version          int                      `json:"PostgreSQL-Backup-Manifest-Version"`
+	SystemIdentifier uint64                   `json:"System-Identifier,omitempty"`
+	Files            []BackupManifestFile     `json:"Files"`
+	WalRanges        []BackupManifestWalRange `json:"WAL-Ranges"`
+	Checksum         string                   `json:"Manifest-Checksum"`
+}
+
+// BackupManifestFile is the backup_manifest entry of a file
+type BackupManifestFile struct {
+	Path string `json:"Path,omitempty"`
+	// EncodedPath is the hex encoded path, it is used instead of Path for the names which are not valid UTF-8
+	EncodedPath       string `json:"Encoded-Path,omitempty"`
+	Size              int64  `json:"Size"`
+	LastModified      string `json:"Last-Modified"`
+	ChecksumAlgorithm string `json:"Checksum-Algorithm,omitempty"`
+	Checksum          string `json:"Checksum,omitempty"`
+}
+
+// BackupManifestWalRange is the range of the WAL needed to restore the backup
+type BackupManifestWalRange struct {
+	Timeline uint32 `json:"Timeline"`
+	StartLSN string `json:"Start-LSN"`
+	EndLSN   string `json:"End-LSN"`
+}
+
+// ManifestFileChecksum is the size and the checksum of the received file
+type ManifestFileChecksum struct {
+	Size      int64
+	Algorithm string
+	Checksum  string
+}
+
+// ParseBackupManifest parses the manifest and checks its checksum
+func ParseBackupManifest(data []byte) (*BackupManifest, error) {
+	manifest := &BackupManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, errors.Wrap(err, "failed to parse the backup manifest")
+	}
+	// the checksum covers everything up to the line with the checksum itself
+	checksumLineStart := bytes.LastIndexByte(bytes.TrimRight(data, "\n"), '\n') + 1
+	sum := sha256.Sum256(data[:checksumLineStart])
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, manifest.Checksum) {
+		return nil, fmt.Errorf("backup manifest checksum mismatch: expected %s, actual %s", manifest.Checksum, actual)
+	}
+	return manifest, nil
+}
+
+func (file BackupManifestFile) path() (string, error) {
+	if file.EncodedPath == "" {
+		return file.Path, nil
+	}
+	decoded, err := hex.DecodeString(file.EncodedPath)
+	return string(decoded), err
+}
+
+// newManifestChecksumHash returns the hash of the manifest checksum algorithm, nil if it is not supported
+func newManifestChecksumHash(algorithm string) hash.Hash {
+	switch strings.ToUpper(algorithm) {
+	case "CRC32C":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case "SHA224":
+		return sha256.New224()
+	case "SHA256":
+		return sha256.New()
+	case "SHA384":
+		return sha512.New384()
+	case "SHA512":
+		return sha512.New()
+	default:
+		return nil
+	}
+}
+
+// formatManifestChecksum formats the checksum the way PostgreSQL does.
+// PostgreSQL writes CRC-32C in the byte order of the server, which is little-endian on the supported platforms.
+func formatManifestChecksum(algorithm string, checksumHash hash.Hash) string {
+	if crc, ok := checksumHash.(hash.Hash32); ok && strings.EqualFold(algorithm, "CRC32C") {
+		return hex.EncodeToString(binary.LittleEndian.AppendUint32(nil, crc.Sum32()))
+	}
+	return hex.EncodeToString(checksumHash.Sum(nil))
+}
+
+// Validate checks that the received files and the WAL range match the manifest
+func (manifest *BackupManifest) Validate(files map[string]ManifestFileChecksum, timeline uint32,
+	startLSN, endLSN LSN) error {
+	var issues []string
+	if err := manifest.validateWalRange(timeline, startLSN, endLSN); err != nil {
+		issues = append(issues, err.Error())
+	}
+
+	manifestPaths := make(map[string]bool, len(manifest.Files))
+	for _, file := range manifest.Files {
+		filePath, err := file.path()
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("invalid encoded path %s", file.EncodedPath))
+			continue
+		}
+		manifestPaths[filePath] = true
+		received, ok := files[filePath]
+		switch {
+		case !ok:
+			issues = append(issues, fmt.Sprintf("%s is in the manifest, but not in the backup", filePath))
+		case received.Size != file.Size:
+			issues = append(issues, fmt.Sprintf("%s size is %d, the manifest size is %d",
+				filePath, received.Size, file.Size))
+		case file.Checksum != "" && strings.EqualFold(received.Algorithm, file.ChecksumAlgorithm) &&
+			!strings.EqualFold(received.Checksum, file.Checksum):
+			issues = append(issues, fmt.Sprintf("%s checksum is %s, the manifest checksum is %s",
+				filePath, received.Checksum, file.Checksum))
+		}
+	}
+	for filePath := range files {
+		if !manifestPaths[filePath] {
+			issues = append(issues, fmt.Sprintf("%s is in the backup, but not in the manifest", filePath))
+		}
+	}
+
+	if len(issues) == 0 {
+		return nil
+	}
+	sort.Strings(issues)
+	return fmt.Errorf("backup does not match the manifest, %d issues found: %s", len(issues),
+		strings.Join(issues[:min(len(issues), maxReportedManifestIssues)], "; "))
+}
+
+// validateWalRange checks that the manifest WAL ranges cover the backup from its start to its end LSN
+func (manifest *BackupManifest) validateWalRange(timeline uint32, startLSN, endLSN LSN) error {
+	if len(manifest.WalRanges) == 0 {
+		return errors.New("the manifest has no WAL ranges")
+	}
+	var manifestStart, manifestEnd LSN
+	var endTimeline uint32
+	for i, walRange := range manifest.WalRanges {
+		rangeStart, err := ParseLSN(walRange.StartLSN)
+		if err != nil {
+			return errors.Wrapf(err, "invalid manifest WAL range start %s", walRange.StartLSN)
+		}
+		rangeEnd, err := ParseLSN(walRange.EndLSN)
+		if err != nil {
+			return errors.Wrapf(err, "invalid manifest WAL range end %s", walRange.EndLSN)
+		}
+		if i == 0 || rangeStart < manifestStart {
+			manifestStart = rangeStart
+		}
+		if i == 0 || rangeEnd > manifestEnd {
+			manifestEnd, endTimeline = rangeEnd, walRange.Timeline
+		}
+	}
+	if manifestStart != startLSN || manifestEnd != endLSN || endTimeline != timeline {
+		return fmt.Errorf("the manifest WAL range %s-%s on the timeline %d does not match the backup %s-%s on %d",
+			manifestStart, manifestEnd, endTimeline, startLSN, endLSN, timeline)
+	}
+	return nil
+}
+
+// BackupManifestBuilder collects the files of the backup made from the data directory
+// and writes the backup_manifest in the format of PostgreSQL
+type BackupManifestBuilder struct {
+	mutex sync.Mutex
+	files map[string]BackupManifestFile
+}
+
+func NewBackupManifestBuilder() *BackupManifestBuilder {
+	return &BackupManifestBuilder{files: make(map[string]BackupManifestFile)}
+}
+
+// NewChecksumHash returns the hash to compute the file checksum with
+func (builder *BackupManifestBuilder) NewChecksumHash() hash.Hash {
+	return newManifestChecksumHash(BackupManifestChecksumAlgorithm)
+}
+
+// AddFile adds the file to the manifest, the checksum is nil if the backup does not store the whole file
+func (builder *BackupManifestBuilder) AddFile(filePath string, size int64, modTime time.Time, checksum hash.Hash) {
+	file := BackupManifestFile{Size: size, LastModified: modTime.UTC().Format(backupManifestTimeFormat)}
+	if utf8.ValidString(filePath) {
+		file.Path = filePath
+	} else {
+		file.EncodedPath = hex.EncodeToString([]byte(filePath))
+	}
+	if checksum != nil {
+		file.ChecksumAlgorithm = BackupManifestChecksumAlgorithm
+		file.Checksum = formatManifestChecksum(BackupManifestChecksumAlgorithm, checksum)
+	}
+	builder.mutex.Lock()
+	defer builder.mutex.Unlock()
+	builder.files[filePath] = file
+}
+
+// Build writes the manifest with one file per line as PostgreSQL does. The system identifier
+// is written since PostgreSQL 17, whose pg_verifybackup checks it against pg_control.
+func (builder *BackupManifestBuilder) Build(pgVersion int, systemIdentifier *uint64, timeline uint32,
+	startLSN, endLSN LSN) ([]byte, error) {
+	builder.mutex.Lock()
+	defer builder.mutex.Unlock()
+	paths := make([]string, 0, len(builder.files))
+	for filePath := range builder.files {
+		paths = append(paths, filePath)
+	}
+	sort.Strings(paths)
+
+	var manifest bytes.Buffer
+	if pgVersion >= backupManifestSystemIdentifierVersion && systemIdentifier != nil {
+		fmt.Fprintf(&manifest, "{ \"PostgreSQL-Backup-Manifest-Version\": 2,\n\"System-Identifier\": %d,\n",
+			*systemIdentifier)
+	} else {
+		manifest.WriteString("{ \"PostgreSQL-Backup-Manifest-Version\": 1,\n")
+	}
+	manifest.WriteString("\"Files\": [")
+	for i, filePath := range paths {
+		line, err := json.Marshal(builder.files[filePath])
+		if err != nil {
+			return nil, err
+		}
+		if i > 0 {
+			manifest.WriteString(",")
+		}
+		manifest.WriteString("\n")
+		manifest.Write(line)
+	}
+	manifest.WriteString(" ],\n\"WAL-Ranges\": [\n")
+	fmt.Fprintf(&manifest, "{ \"Timeline\": %d, \"Start-LSN\": \"%s\", \"End-LSN\": \"%s\" }\n",
+		timeline, startLSN, endLSN)
+	manifest.WriteString("],\n")
+	sum := sha256.Sum256(manifest.Bytes())
+	fmt.Fprintf(&manifest, "\"Manifest-Checksum\": \"%s\"}\n", hex.EncodeToString(sum[:]))
+	return manifest.Bytes(), nil
+}
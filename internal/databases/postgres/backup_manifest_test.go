@@ -0,0 +1,96 @@
+package postgres
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestBackupManifest(files string) []byte {
+	body := "{ \"PostgreSQL-Backup-Manifest-Version\": 1,\n" +
+		"\"Files\": [\n" + files + "\n],\n" +
+		"\"WAL-Ranges\": [\n" +
+		"{ \"Timeline\": 1, \"Start-LSN\": \"0/2000028\", \"End-LSN\": \"0/2000100\" }\n" +
+		"],\n"
+	sum := sha256.Sum256([]byte(body))
+	return []byte(body + fmt.Sprintf("\"Manifest-Checksum\": \"%s\"}\n", hex.EncodeToString(sum[:])))
+}
+
+func testManifestChecksum(data string) string {
+	checksumHash := newManifestChecksumHash("CRC32C")
+	_, _ = checksumHash.Write([]byte(data))
+	return formatManifestChecksum("CRC32C", checksumHash)
+}
+
+func TestParseBackupManifest(t *testing.T) {
+	data := newTestBackupManifest(fmt.Sprintf("{ \"Path\": \"base/1/1259\", \"Size\": 4, "+
+		"\"Last-Modified\": \"2024-01-01 00:00:00 GMT\", \"Checksum-Algorithm\": \"CRC32C\", \"Checksum\": \"%s\" },\n"+
+		"{ \"Encoded-Path\": \"6261636b75705f6c6162656c\", \"Size\": 0, \"Last-Modified\": \"2024-01-01 00:00:00 GMT\" }",
+		testManifestChecksum("data")))
+	manifest, err := ParseBackupManifest(data)
+	require.NoError(t, err)
+	require.Len(t, manifest.Files, 2)
+	filePath, err := manifest.Files[1].path()
+	require.NoError(t, err)
+	assert.Equal(t, "backup_label", filePath)
+
+	files := map[string]ManifestFileChecksum{
+		"base/1/1259":  {Size: 4, Algorithm: "CRC32C", Checksum: testManifestChecksum("data")},
+		"backup_label": {Size: 0, Algorithm: "CRC32C", Checksum: testManifestChecksum("")},
+	}
+	assert.NoError(t, manifest.Validate(files, 1, 0x2000028, 0x2000100))
+
+	assert.ErrorContains(t, manifest.Validate(files, 2, 0x2000028, 0x2000100), "does not match the backup")
+	files["base/1/1259"] = ManifestFileChecksum{Size: 4, Algorithm: "CRC32C", Checksum: testManifestChecksum("date")}
+	files["base/1/1260"] = ManifestFileChecksum{Size: 1}
+	delete(files, "backup_label")
+	err = manifest.Validate(files, 1, 0x2000028, 0x2000100)
+	assert.ErrorContains(t, err, "3 issues found")
+	assert.ErrorContains(t, err, "base/1/1259 checksum is")
+	assert.ErrorContains(t, err, "base/1/1260 is in the backup, but not in the manifest")
+	assert.ErrorContains(t, err, "backup_label is in the manifest, but not in the backup")
+}
+
+func TestParseBackupManifest_ChecksumMismatch(t *testing.T) {
+	data := newTestBackupManifest("")
+	data[10] = 'p'
+	_, err := ParseBackupManifest(data)
+	assert.ErrorContains(t, err, "backup manifest checksum mismatch")
+}
+
+func TestBackupManifestBuilder(t *testing.T) {
+	builder := NewBackupManifestBuilder()
+	checksum := builder.NewChecksumHash()
+	_, _ = checksum.Write([]byte("data"))
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	builder.AddFile("base/1/1259", 4, modTime, checksum)
+	builder.AddFile("base/1/1260", 8192, modTime, nil)
+	builder.AddFile("base/1/\xff", 1, modTime, nil)
+
+	systemIdentifier := uint64(7300000000000000000)
+	data, err := builder.Build(170000, &systemIdentifier, 1, 0x2000028, 0x2000100)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("{ \"PostgreSQL-Backup-Manifest-Version\": 2,\n"+
+		"\"System-Identifier\": 7300000000000000000,\n")))
+	assert.Contains(t, string(data), "\n{\"Path\":\"base/1/1259\",\"Size\":4,"+
+		"\"Last-Modified\":\"2024-01-02 03:04:05 GMT\",\"Checksum-Algorithm\":\"CRC32C\",")
+
+	manifest, err := ParseBackupManifest(data)
+	require.NoError(t, err)
+	assert.Equal(t, systemIdentifier, manifest.SystemIdentifier)
+	assert.NoError(t, manifest.Validate(map[string]ManifestFileChecksum{
+		"base/1/1259": {Size: 4, Algorithm: "CRC32C", Checksum: testManifestChecksum("data")},
+		"base/1/1260": {Size: 8192, Algorithm: "CRC32C", Checksum: testManifestChecksum("any")},
+		"base/1/\xff": {Size: 1},
+	}, 1, 0x2000028, 0x2000100))
+
+	data, err = builder.Build(160000, &systemIdentifier, 1, 0x2000028, 0x2000100)
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(data, []byte("{ \"PostgreSQL-Backup-Manifest-Version\": 1,\n\"Files\": [")))
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/apecloud/dataprotection-wal-g/internal"
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx"
 
 	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
 	"github.com/apecloud/dataprotection-wal-g/utility"
@@ -71,6 +72,8 @@ type CurBackupInfo struct {
 	compressedSize   int64
 	dataCatalogSize  int64
 	incrementCount   int
+	tarCompressions  map[string]string
+	source           *BackupSourceInfo
 }
 
 func NewPrevBackupInfo(name string, sentinel BackupSentinelDto, filesMeta FilesMetadataDto) PrevBackupInfo {
@@ -144,11 +147,17 @@ func (bh *BackupHandler) createAndPushBackup() {
 	bh.Workers.Bundle = NewBundle(bh.PgInfo.PgDataDirectory, crypter, bh.prevBackupInfo.name,
 		bh.prevBackupInfo.sentinelDto.BackupStartLSN, bh.prevBackupInfo.filesMetadataDto.Files, arguments.forceIncremental,
 		viper.GetInt64(internal.TarSizeThresholdSetting))
+	if bh.PgInfo.pgVersion >= baseBackupManifestVersion {
+		bh.Workers.Bundle.Manifest = NewBackupManifestBuilder()
+	}
 
 	err = bh.startBackup()
 	tracelog.ErrorLogger.FatalOnError(err)
 	bh.handleDeltaBackup(folder)
 	tarFileSets := bh.uploadBackup()
+	bh.CurBackupInfo.source = bh.collectBackupSource()
+	err = bh.waitForStandbyStopSegment(folder, bh.Workers.Bundle.Replica, bh.Workers.Bundle.Timeline)
+	tracelog.ErrorLogger.FatalfOnError("Backup from standby is not finished: %v", err)
 	sentinelDto, filesMetaDto, err := bh.setupDTO(tarFileSets)
 	tracelog.ErrorLogger.FatalOnError(err)
 	bh.markBackups(folder, sentinelDto)
@@ -186,21 +195,14 @@ func (bh *BackupHandler) startBackup() (err error) {
 func (bh *BackupHandler) handleDeltaBackup(folder storage.Folder) {
 	if len(bh.prevBackupInfo.name) > 0 && bh.prevBackupInfo.sentinelDto.BackupStartLSN != nil {
 		tracelog.InfoLogger.Println("Delta backup enabled")
-		tracelog.DebugLogger.Printf("Previous backup: %s\nBackup start LSN: %d", bh.prevBackupInfo.name,
-			bh.prevBackupInfo.sentinelDto.BackupStartLSN)
-		if *bh.prevBackupInfo.sentinelDto.BackupFinishLSN > bh.CurBackupInfo.startLSN {
-			tracelog.ErrorLogger.FatalOnError(newBackupFromFuture(bh.prevBackupInfo.name))
-		}
-		if bh.prevBackupInfo.sentinelDto.SystemIdentifier != nil &&
-			bh.PgInfo.systemIdentifier != nil &&
-			*bh.PgInfo.systemIdentifier != *bh.prevBackupInfo.sentinelDto.SystemIdentifier {
-			tracelog.ErrorLogger.FatalOnError(newBackupFromOtherBD())
-		}
+		bh.checkDeltaBaseBackup()
+
+		bh.loadWalSummaryDeltaMap()
 
 		useWalDelta, _, err := configureWalDeltaUsage()
 		tracelog.ErrorLogger.FatalOnError(err)
 
-		if useWalDelta {
+		if useWalDelta && bh.Workers.Bundle.DeltaMap == nil {
 			err := bh.Workers.Bundle.DownloadDeltaMap(internal.NewFolderReader(folder.GetSubFolder(utility.WalPath)), bh.CurBackupInfo.startLSN)
 			if err == nil {
 				tracelog.InfoLogger.Println("Successfully loaded delta map, delta backup will be made with provided " +
@@ -215,6 +217,43 @@ func (bh *BackupHandler) handleDeltaBackup(folder storage.Folder) {
 	}
 }
 
+// checkDeltaBaseBackup checks that the increment base backup was taken before the current one from the same database
+func (bh *BackupHandler) checkDeltaBaseBackup() {
+	tracelog.DebugLogger.Printf("Previous backup: %s\nBackup start LSN: %d", bh.prevBackupInfo.name,
+		bh.prevBackupInfo.sentinelDto.BackupStartLSN)
+	if *bh.prevBackupInfo.sentinelDto.BackupFinishLSN > bh.CurBackupInfo.startLSN {
+		tracelog.ErrorLogger.FatalOnError(newBackupFromFuture(bh.prevBackupInfo.name))
+	}
+	if bh.prevBackupInfo.sentinelDto.SystemIdentifier != nil &&
+		bh.PgInfo.systemIdentifier != nil &&
+		*bh.PgInfo.systemIdentifier != *bh.prevBackupInfo.sentinelDto.SystemIdentifier {
+		tracelog.ErrorLogger.FatalOnError(newBackupFromOtherBD())
+	}
+}
+
+// loadWalSummaryDeltaMap builds the delta map from the WAL summaries on PostgreSQL 17+ if WAL summarization is on
+func (bh *BackupHandler) loadWalSummaryDeltaMap() {
+	useSummaries, err := useWalSummaries(bh.Workers.QueryRunner)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to check if WAL summarization is on: '%v'", err)
+		return
+	}
+	if !useSummaries {
+		return
+	}
+	prevTimeline, err := ParseTimelineFromBackupName(bh.prevBackupInfo.name)
+	if err == nil {
+		err = bh.Workers.Bundle.LoadWalSummaryDeltaMap(bh.Workers.QueryRunner, prevTimeline, bh.CurBackupInfo.startLSN)
+	}
+	if err == nil {
+		tracelog.InfoLogger.Println("Successfully loaded delta map from WAL summaries, delta backup will be made " +
+			"with provided delta map")
+	} else {
+		tracelog.WarningLogger.Printf("Error during loading delta map from WAL summaries: '%v'. "+
+			"Fallback to WAL delta or full scan delta backup\n", err)
+	}
+}
+
 func (bh *BackupHandler) setupDTO(tarFileSets internal.TarFileSets) (sentinelDto BackupSentinelDto,
 	filesMeta FilesMetadataDto, err error) {
 	var tablespaceSpec *TablespaceSpec
@@ -242,10 +281,10 @@ func (bh *BackupHandler) SetComposerInitFunc(initFunc func(handler *BackupHandle
 }
 
 func configureTarBallComposer(bh *BackupHandler, tarBallComposerType TarBallComposerType) error {
+	filePackerOptions := NewTarBallFilePackerOptions(bh.Arguments.verifyPageChecksums, bh.Arguments.storeAllCorruptBlocks)
+	filePackerOptions.manifest = bh.Workers.Bundle.Manifest
 	maker, err := NewTarBallComposerMaker(tarBallComposerType, bh.Workers.QueryRunner,
-		bh.Workers.Uploader, bh.CurBackupInfo.Name,
-		NewTarBallFilePackerOptions(bh.Arguments.verifyPageChecksums, bh.Arguments.storeAllCorruptBlocks),
-		bh.Arguments.withoutFilesMetadata)
+		bh.Workers.Uploader, bh.CurBackupInfo.Name, filePackerOptions, bh.Arguments.withoutFilesMetadata)
 	if err != nil {
 		return err
 	}
@@ -257,7 +296,8 @@ func (bh *BackupHandler) uploadBackup() internal.TarFileSets {
 	bundle := bh.Workers.Bundle
 	// Start a new tar bundle, walk the pgDataDirectory and upload everything there.
 	tracelog.InfoLogger.Println("Starting a new tar bundle")
-	err := bundle.StartQueue(internal.NewStorageTarBallMaker(bh.CurBackupInfo.Name, bh.Workers.Uploader))
+	tarBallMaker := internal.NewStorageTarBallMaker(bh.CurBackupInfo.Name, bh.Workers.Uploader)
+	err := bundle.StartQueue(tarBallMaker)
 	tracelog.ErrorLogger.FatalOnError(err)
 
 	err = bh.Arguments.composerInitFunc(bh)
@@ -284,10 +324,18 @@ func (bh *BackupHandler) uploadBackup() internal.TarFileSets {
 	labelFilesTarBallName, labelFilesList, finishLsn, err := bundle.uploadLabelFiles(bh.Workers.QueryRunner)
 	tracelog.ErrorLogger.FatalOnError(err)
 	bh.CurBackupInfo.endLSN = finishLsn
+	if bundle.Manifest != nil {
+		tracelog.DebugLogger.Println("Upload backup_manifest")
+		manifestTarBallName, err := bundle.uploadManifest(bh.PgInfo.pgVersion, bh.PgInfo.systemIdentifier,
+			bh.CurBackupInfo.startLSN, finishLsn)
+		tracelog.ErrorLogger.FatalOnError(err)
+		tarFileSets.AddFiles(manifestTarBallName, []string{BackupManifestFilename})
+	}
 	bh.CurBackupInfo.uncompressedSize = atomic.LoadInt64(bundle.TarBallQueue.AllTarballsSize)
 	bh.CurBackupInfo.compressedSize, err = bh.Workers.Uploader.UploadedDataSize()
 	bh.CurBackupInfo.dataCatalogSize = atomic.LoadInt64(bundle.DataCatalogSize)
 	tracelog.ErrorLogger.FatalOnError(err)
+	bh.CurBackupInfo.tarCompressions = tarBallMaker.TarCompressions()
 	tarFileSets.AddFiles(labelFilesTarBallName, labelFilesList)
 	timelineChanged := bundle.checkTimelineChanged(bh.Workers.QueryRunner)
 	tracelog.DebugLogger.Printf("Labelfiles tarball name: %s", labelFilesTarBallName)
@@ -321,18 +369,24 @@ func (bh *BackupHandler) HandleBackupPush() {
 }
 
 func (bh *BackupHandler) handleBackupPushRemote() {
-	if bh.Arguments.forceIncremental {
-		tracelog.ErrorLogger.Println("Delta backup not available for remote backup.")
-		tracelog.ErrorLogger.Fatal("To run delta backup, supply [db_directory].")
-	}
-	// If no arg is parsed, try to run remote backup using pglogrepl's BASE_BACKUP functionality
+	// If no arg is parsed, try to run remote backup using the BASE_BACKUP replication command
 	tracelog.InfoLogger.Println("Running remote backup through Postgres connection.")
-	tracelog.InfoLogger.Println("Features like delta backup and partial restore are disabled, there might be a performance impact.")
+	tracelog.InfoLogger.Println("Delta backups use the WAL summaries or the WAL delta files, " +
+		"a full backup is made if neither is available.")
 	tracelog.InfoLogger.Println("To run with local backup functionalities, supply [db_directory].")
 	if bh.PgInfo.pgVersion < 110000 && !bh.Arguments.verifyPageChecksums {
 		tracelog.InfoLogger.Println("VerifyPageChecksums=false is only supported for streaming backup since PG11")
 		bh.Arguments.verifyPageChecksums = true
 	}
+
+	if bh.Arguments.isFullBackup {
+		tracelog.InfoLogger.Println("Doing full backup.")
+	} else {
+		var err error
+		bh.prevBackupInfo, bh.CurBackupInfo.incrementCount, err = bh.Arguments.deltaConfigurator.Configure(
+			bh.Workers.Uploader.Folder(), bh.Arguments.isPermanent)
+		tracelog.ErrorLogger.FatalOnError(err)
+	}
 	bh.createAndPushRemoteBackup()
 }
 
@@ -368,6 +422,7 @@ func (bh *BackupHandler) handleBackupPushLocal() {
 func (bh *BackupHandler) createAndPushRemoteBackup() {
 	var err error
 	uploader := bh.Workers.Uploader
+	folder := uploader.Folder()
 	uploader.ChangeDirectory(utility.BaseBackupPath)
 	tracelog.DebugLogger.Printf("Uploading folder: %s", uploader.Folder())
 
@@ -378,10 +433,12 @@ func (bh *BackupHandler) createAndPushRemoteBackup() {
 		tarFileSets = internal.NewRegularTarFileSets()
 	}
 
-	baseBackup := bh.runRemoteBackup()
+	baseBackup := bh.runRemoteBackup(folder)
 	tracelog.InfoLogger.Println("Updating metadata")
-	bh.CurBackupInfo.startLSN = LSN(baseBackup.StartLSN)
-	bh.CurBackupInfo.endLSN = LSN(baseBackup.EndLSN)
+	bh.CurBackupInfo.startLSN = baseBackup.StartLSN
+	bh.CurBackupInfo.endLSN = baseBackup.EndLSN
+	err = bh.waitForRemoteStandbyStopSegment(folder, baseBackup.TimeLine)
+	tracelog.ErrorLogger.FatalfOnError("Backup from standby is not finished: %v", err)
 
 	bh.CurBackupInfo.uncompressedSize = baseBackup.UncompressedSize
 	bh.CurBackupInfo.compressedSize, err = bh.Workers.Uploader.UploadedDataSize()
@@ -421,9 +478,50 @@ func (bh *BackupHandler) collectDatabaseNamesMetadata() (DatabasesByNames, error
 		return nil, err
 	}
 	paths.appendDatabaseInfos(databaseInfos)
+	collectTables, err := internal.GetBoolSettingDefault(internal.PgBackupTablesMetadata, false)
+	if err != nil || !collectTables {
+		return paths, err
+	}
+	currentDatabase, err := bh.Workers.QueryRunner.getCurrentDatabase()
+	if err != nil {
+		return nil, err
+	}
+	for _, databaseInfo := range databaseInfos {
+		tables, err := collectTablesMetadata(bh.Workers.QueryRunner, currentDatabase, databaseInfo.Name)
+		if err != nil {
+			// the database can still be restored, but not its single tables
+			tracelog.WarningLogger.Printf("Failed to collect the tables of the database %s: %v\n", databaseInfo.Name, err)
+			continue
+		}
+		info := paths[databaseInfo.Name]
+		info.Tables = tables
+		paths[databaseInfo.Name] = info
+	}
 	return paths, nil
 }
 
+// collectTablesMetadata collects the relfilenodes of the tables of the database, the backup connection
+// is used for its own database, the other databases need a connection each
+func collectTablesMetadata(queryRunner *PgQueryRunner, currentDatabase, database string) (map[string][]int, error) {
+	if database == currentDatabase {
+		return queryRunner.GetTables()
+	}
+	dbConn, err := Connect(func(config *pgx.ConnConfig) error {
+		config.Database = database
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(dbConn, "")
+
+	dbQueryRunner, err := NewPgQueryRunner(dbConn)
+	if err != nil {
+		return nil, err
+	}
+	return dbQueryRunner.GetTables()
+}
+
 // NewBackupHandler returns a backup handler object, which can handle the backup
 func NewBackupHandler(arguments BackupArguments) (bh *BackupHandler, err error) {
 	// RemoteBackup is triggered by not passing PGDATA to wal-g,
@@ -450,7 +548,7 @@ func NewBackupHandler(arguments BackupArguments) (bh *BackupHandler, err error)
 	return bh, nil
 }
 
-func (bh *BackupHandler) runRemoteBackup() *StreamingBaseBackup {
+func (bh *BackupHandler) runRemoteBackup(folder storage.Folder) *StreamingBaseBackup {
 	var diskLimit int32
 	if viper.IsSet(internal.DiskRateLimitSetting) {
 		// Note that BASE_BACKUP (pg protocol) allows to limit in kb/sec
@@ -465,16 +563,33 @@ func (bh *BackupHandler) runRemoteBackup() *StreamingBaseBackup {
 	conn, err := pgconn.Connect(context.Background(), "replication=yes")
 	tracelog.ErrorLogger.FatalOnError(err)
 
-	baseBackup := NewStreamingBaseBackup(bh.PgInfo.PgDataDirectory, viper.GetInt64(internal.TarSizeThresholdSetting), conn)
+	baseBackup := NewStreamingBaseBackup(bh.PgInfo.PgDataDirectory, viper.GetInt64(internal.TarSizeThresholdSetting),
+		bh.PgInfo.pgVersion, conn)
 	var bundleFiles internal.BundleFiles
 	if bh.Arguments.withoutFilesMetadata {
 		bundleFiles = &internal.NopBundleFiles{}
 	} else {
 		bundleFiles = &internal.RegularBundleFiles{}
 	}
+	options := BaseBackupOptions{
+		// Following implementation for local backup.
+		Label:           "wal-g",
+		Fast:            true,
+		MaxRate:         diskLimit,
+		VerifyChecksums: bh.Arguments.verifyPageChecksums,
+		TablespaceMap:   true,
+		Manifest:        bh.PgInfo.pgVersion >= baseBackupManifestVersion,
+	}
+	if setting, ok := internal.GetSetting(internal.PgRemoteBackupServerCompression); ok {
+		options.Compression, options.CompressionDetail, err = ParseServerCompression(setting, bh.PgInfo.pgVersion)
+		tracelog.ErrorLogger.FatalOnError(err)
+		tracelog.InfoLogger.Printf("Using server-side compression %s", setting)
+	}
 	tracelog.InfoLogger.Println("Starting remote backup")
-	err = baseBackup.Start(bh.Arguments.verifyPageChecksums, diskLimit)
+	err = baseBackup.Start(options)
 	tracelog.ErrorLogger.FatalOnError(err)
+	bh.CurBackupInfo.startLSN = baseBackup.StartLSN
+	bh.handleRemoteDeltaBackup(folder, baseBackup)
 
 	tracelog.InfoLogger.Println("Streaming remote backup")
 	err = baseBackup.Upload(bh.Workers.Uploader, bundleFiles)
@@ -485,12 +600,83 @@ func (bh *BackupHandler) runRemoteBackup() *StreamingBaseBackup {
 	err = baseBackup.Finish()
 	tracelog.ErrorLogger.FatalOnError(err)
 
+	if options.Manifest {
+		tracelog.InfoLogger.Println("Validating the backup manifest")
+		err = baseBackup.ValidateManifest()
+		tracelog.ErrorLogger.FatalfOnError("Backup manifest validation failed: %v", err)
+		err = baseBackup.UploadManifest()
+		tracelog.ErrorLogger.FatalfOnError("Failed to upload the backup manifest: %v", err)
+	}
+
 	tracelog.DebugLogger.Println("Closing Postgres connection (replication connection)")
 	err = conn.Close(context.Background())
 	tracelog.ErrorLogger.FatalOnError(err)
 	return baseBackup
 }
 
+// handleRemoteDeltaBackup makes the remote backup a delta backup if the delta map can be built
+// from the WAL summaries or the WAL delta files. The pages are streamed from the server, so
+// there is no full scan fallback, and the full backup is made instead.
+func (bh *BackupHandler) handleRemoteDeltaBackup(folder storage.Folder, baseBackup *StreamingBaseBackup) {
+	if len(bh.prevBackupInfo.name) == 0 || bh.prevBackupInfo.sentinelDto.BackupStartLSN == nil {
+		return
+	}
+	tracelog.InfoLogger.Println("Delta backup enabled")
+	bh.checkDeltaBaseBackup()
+
+	deltaMap, err := bh.loadRemoteDeltaMap(folder, baseBackup.TimeLine)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Error during loading delta map: '%v'. Fallback to full backup\n", err)
+		bh.prevBackupInfo = PrevBackupInfo{}
+		bh.CurBackupInfo.incrementCount = 0
+		return
+	}
+	baseBackup.setIncrement(bh.prevBackupInfo.name, bh.prevBackupInfo.filesMetadataDto.Files, deltaMap,
+		bh.Arguments.forceIncremental)
+	tracelog.DebugLogger.Printf("Suffixing Backup name with Delta info: %s", baseBackup.BackupName())
+}
+
+// loadRemoteDeltaMap builds the delta map from the WAL summaries if WAL summarization is on,
+// or from the WAL delta files in storage otherwise
+func (bh *BackupHandler) loadRemoteDeltaMap(folder storage.Folder, timeline uint32) (PagedFileDeltaMap, error) {
+	incrementFromLsn := *bh.prevBackupInfo.sentinelDto.BackupStartLSN
+	prevTimeline, err := ParseTimelineFromBackupName(bh.prevBackupInfo.name)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(conn, "")
+	queryRunner, err := NewPgQueryRunner(conn)
+	if err != nil {
+		return nil, err
+	}
+	useSummaries, err := useWalSummaries(queryRunner)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to check if WAL summarization is on: '%v'", err)
+	}
+	if useSummaries && prevTimeline == timeline {
+		deltaMap, err := getWalSummaryDeltaMap(queryRunner, timeline, incrementFromLsn, bh.CurBackupInfo.startLSN)
+		if err == nil {
+			tracelog.InfoLogger.Println("Successfully loaded delta map from WAL summaries")
+			return deltaMap, nil
+		}
+		tracelog.WarningLogger.Printf("Error during loading delta map from WAL summaries: '%v'. "+
+			"Fallback to WAL delta\n", err)
+	}
+
+	deltaMap, err := getDeltaMap(internal.NewFolderReader(folder.GetSubFolder(utility.WalPath)), timeline, incrementFromLsn,
+		bh.CurBackupInfo.startLSN)
+	if err != nil {
+		return nil, err
+	}
+	tracelog.InfoLogger.Println("Successfully loaded delta map from WAL delta files")
+	return deltaMap, nil
+}
+
 func getPgServerInfo() (pgInfo BackupPgInfo, err error) {
 	// Creating a temporary connection to read slot info and wal_segment_size
 	tracelog.DebugLogger.Println("Initializing tmp connection to read Postgres info")
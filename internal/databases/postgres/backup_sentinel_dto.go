@@ -33,6 +33,12 @@ type BackupSentinelDto struct {
 	UserData interface{} `json:"UserData,omitempty"`
 
 	FilesMetadataDisabled bool `json:"FilesMetadataDisabled,omitempty"`
+
+	CompressionDictionaryID uint32 `json:"CompressionDictionaryID,omitempty"`
+	// TarCompression maps the tars to the algorithms chosen by the adaptive compression
+	TarCompression map[string]string `json:"TarCompression,omitempty"`
+	// Source is the node the backup was taken from
+	Source *BackupSourceInfo `json:"Source,omitempty"`
 }
 
 func NewBackupSentinelDto(bh *BackupHandler, tbsSpec *TablespaceSpec) BackupSentinelDto {
@@ -59,6 +65,11 @@ func NewBackupSentinelDto(bh *BackupHandler, tbsSpec *TablespaceSpec) BackupSent
 	sentinel.CompressedSize = bh.CurBackupInfo.compressedSize
 	sentinel.DataCatalogSize = bh.CurBackupInfo.dataCatalogSize
 	sentinel.FilesMetadataDisabled = bh.Arguments.withoutFilesMetadata
+	sentinel.TarCompression = bh.CurBackupInfo.tarCompressions
+	sentinel.Source = bh.CurBackupInfo.source
+	if bh.Workers.Uploader != nil {
+		sentinel.CompressionDictionaryID = internal.CompressionDictionaryID(bh.Workers.Uploader.Compression())
+	}
 	return sentinel
 }
 
@@ -0,0 +1,445 @@
+package postgres
+
+/*
+This module runs the BASE_BACKUP replication command and reads the archives it streams.
+Before PostgreSQL 15 each archive is sent in its own COPY OUT stream: the tablespaces in the order they
+were listed, then the backup manifest if requested. Since PostgreSQL 15 all the archives are sent in one
+COPY OUT stream, where the first byte of each CopyData message tells its kind: 'n' starts a new archive,
+'m' starts the manifest, 'd' is the archive data and 'p' is a progress report.
+*/
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgproto3/v2"
+	"github.com/pkg/errors"
+)
+
+const (
+	// baseBackupNewSyntaxVersion is the version the BASE_BACKUP options are in parentheses since
+	baseBackupNewSyntaxVersion = 150000
+	// baseBackupManifestVersion is the version the backup manifest is supported since
+	baseBackupManifestVersion = 130000
+)
+
+// BaseBackupOptions are the options of the BASE_BACKUP command
+type BaseBackupOptions struct {
+	Label           string
+	Fast            bool
+	MaxRate         int32
+	VerifyChecksums bool
+	TablespaceMap   bool
+	Manifest        bool
+	// Compression is the server-side compression method, e.g. lz4, supported since PostgreSQL 15
+	Compression       string
+	CompressionDetail string
+}
+
+// command builds the BASE_BACKUP command in the syntax of the PostgreSQL version
+func (options BaseBackupOptions) command(pgVersion int) string {
+	quote := func(value string) string {
+		return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+	}
+	if pgVersion >= baseBackupNewSyntaxVersion {
+		var parts []string
+		if options.Label != "" {
+			parts = append(parts, "LABEL "+quote(options.Label))
+		}
+		if options.Fast {
+			parts = append(parts, "CHECKPOINT 'fast'")
+		}
+		if options.MaxRate >= 32 {
+			parts = append(parts, fmt.Sprintf("MAX_RATE %d", options.MaxRate))
+		}
+		if options.TablespaceMap {
+			parts = append(parts, "TABLESPACE_MAP")
+		}
+		if !options.VerifyChecksums {
+			parts = append(parts, "VERIFY_CHECKSUMS false")
+		}
+		if options.Manifest {
+			parts = append(parts, "MANIFEST 'yes'")
+		}
+		if options.Compression != "" {
+			parts = append(parts, "COMPRESSION "+quote(options.Compression))
+			if options.CompressionDetail != "" {
+				parts = append(parts, "COMPRESSION_DETAIL "+quote(options.CompressionDetail))
+			}
+		}
+		if len(parts) == 0 {
+			return "BASE_BACKUP"
+		}
+		return "BASE_BACKUP (" + strings.Join(parts, ", ") + ")"
+	}
+
+	parts := []string{"BASE_BACKUP"}
+	if options.Label != "" {
+		parts = append(parts, "LABEL "+quote(options.Label))
+	}
+	if options.Fast {
+		parts = append(parts, "FAST")
+	}
+	if options.MaxRate >= 32 {
+		parts = append(parts, fmt.Sprintf("MAX_RATE %d", options.MaxRate))
+	}
+	if options.TablespaceMap {
+		parts = append(parts, "TABLESPACE_MAP")
+	}
+	if !options.VerifyChecksums {
+		parts = append(parts, "NOVERIFY_CHECKSUMS")
+	}
+	if options.Manifest && pgVersion >= baseBackupManifestVersion {
+		parts = append(parts, "MANIFEST 'yes'")
+	}
+	return strings.Join(parts, " ")
+}
+
+// BaseBackupTablespace is a tablespace listed by BASE_BACKUP, the data directory is listed with zero OID
+type BaseBackupTablespace struct {
+	OID      uint32
+	Location string
+	Size     int64
+}
+
+func (tablespace BaseBackupTablespace) isDataDirectory() bool {
+	return tablespace.OID == 0
+}
+
+// baseBackupConn is the part of the replication connection used by the BASE_BACKUP stream
+type baseBackupConn interface {
+	SendBytes(ctx context.Context, buf []byte) error
+	ReceiveMessage(ctx context.Context) (pgproto3.BackendMessage, error)
+}
+
+// baseBackupArchive is the archive streamed by BASE_BACKUP, either the tar of a tablespace or the manifest
+type baseBackupArchive struct {
+	isManifest bool
+	tablespace BaseBackupTablespace
+}
+
+// baseBackupStream reads the archives streamed by BASE_BACKUP
+type baseBackupStream struct {
+	conn        baseBackupConn
+	newProtocol bool
+	Tablespaces []BaseBackupTablespace
+	StartLSN    LSN
+	Timeline    uint32
+
+	// archiveCount is the number of the archives started
+	archiveCount int
+	inArchive    bool
+	copyDone     bool
+	// nextArchive is the archive whose start message was received while reading the previous one
+	nextArchive *baseBackupArchive
+	buffer      []byte
+	// pending is the message received while looking for the archive which belongs to the end of the backup
+	pending pgproto3.BackendMessage
+}
+
+// startBaseBackupStream sends BASE_BACKUP and reads the backup start LSN and the tablespaces
+func startBaseBackupStream(conn baseBackupConn, options BaseBackupOptions, pgVersion int) (*baseBackupStream, error) {
+	stream := &baseBackupStream{conn: conn, newProtocol: pgVersion >= baseBackupNewSyntaxVersion}
+	query := (&pgproto3.Query{String: options.command(pgVersion)}).Encode(nil)
+	if err := conn.SendBytes(context.Background(), query); err != nil {
+		return nil, errors.Wrap(err, "failed to send BASE_BACKUP")
+	}
+	rows, err := stream.receiveResultSet()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) != 1 || len(rows[0]) < 2 {
+		return nil, errors.Errorf("unexpected BASE_BACKUP start position result: %v", rows)
+	}
+	if stream.StartLSN, stream.Timeline, err = parseBaseBackupPosition(rows[0]); err != nil {
+		return nil, err
+	}
+
+	rows, err = stream.receiveResultSet()
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		tablespace, err := parseBaseBackupTablespace(row)
+		if err != nil {
+			return nil, err
+		}
+		stream.Tablespaces = append(stream.Tablespaces, tablespace)
+	}
+	return stream, nil
+}
+
+func parseBaseBackupPosition(row [][]byte) (LSN, uint32, error) {
+	lsn, err := ParseLSN(string(row[0]))
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to parse the BASE_BACKUP LSN '%s'", row[0])
+	}
+	timeline, err := strconv.ParseUint(string(row[1]), 10, 32)
+	if err != nil {
+		return 0, 0, errors.Wrapf(err, "failed to parse the BASE_BACKUP timeline '%s'", row[1])
+	}
+	return lsn, uint32(timeline), nil
+}
+
+func parseBaseBackupTablespace(row [][]byte) (BaseBackupTablespace, error) {
+	if len(row) < 3 {
+		return BaseBackupTablespace{}, errors.Errorf("unexpected BASE_BACKUP tablespace row: %v", row)
+	}
+	var tablespace BaseBackupTablespace
+	if row[0] != nil {
+		oid, err := strconv.ParseUint(string(row[0]), 10, 32)
+		if err != nil {
+			return tablespace, errors.Wrapf(err, "failed to parse the tablespace OID '%s'", row[0])
+		}
+		tablespace.OID = uint32(oid)
+		tablespace.Location = string(row[1])
+	}
+	if row[2] != nil {
+		size, err := strconv.ParseInt(string(row[2]), 10, 64)
+		if err != nil {
+			return tablespace, errors.Wrapf(err, "failed to parse the tablespace size '%s'", row[2])
+		}
+		tablespace.Size = size
+	}
+	return tablespace, nil
+}
+
+// receive returns the message received before or the next one
+func (stream *baseBackupStream) receive() (pgproto3.BackendMessage, error) {
+	if stream.pending != nil {
+		message := stream.pending
+		stream.pending = nil
+		return message, nil
+	}
+	for {
+		message, err := stream.conn.ReceiveMessage(context.Background())
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to receive the BASE_BACKUP message")
+		}
+		switch msg := message.(type) {
+		case *pgproto3.NoticeResponse:
+			continue
+		case *pgproto3.ErrorResponse:
+			return nil, pgconn.ErrorResponseToPgError(msg)
+		}
+		return message, nil
+	}
+}
+
+// receiveResultSet reads the rows of the result set up to its CommandComplete
+func (stream *baseBackupStream) receiveResultSet() ([][][]byte, error) {
+	var rows [][][]byte
+	for {
+		message, err := stream.receive()
+		if err != nil {
+			return nil, err
+		}
+		switch msg := message.(type) {
+		case *pgproto3.RowDescription:
+		case *pgproto3.DataRow:
+			row := make([][]byte, len(msg.Values))
+			for i, value := range msg.Values {
+				if value != nil {
+					row[i] = bytes.Clone(value)
+				}
+			}
+			rows = append(rows, row)
+		case *pgproto3.CommandComplete:
+			return rows, nil
+		default:
+			return nil, errors.Errorf("unexpected BASE_BACKUP message %T in the result set", msg)
+		}
+	}
+}
+
+// NextArchive moves to the next archive, it returns io.EOF when all the archives are read
+func (stream *baseBackupStream) NextArchive() (baseBackupArchive, error) {
+	if stream.inArchive {
+		// skip the rest of the current archive
+		if _, err := io.Copy(io.Discard, stream); err != nil {
+			return baseBackupArchive{}, err
+		}
+	}
+	if stream.newProtocol {
+		return stream.nextArchiveNewProtocol()
+	}
+	return stream.nextArchiveLegacy()
+}
+
+func (stream *baseBackupStream) nextArchiveLegacy() (baseBackupArchive, error) {
+	message, err := stream.receive()
+	if err != nil {
+		return baseBackupArchive{}, err
+	}
+	if _, ok := message.(*pgproto3.CopyOutResponse); !ok {
+		// the archives are over, the message belongs to the end position result set
+		stream.pending = message
+		return baseBackupArchive{}, io.EOF
+	}
+	stream.inArchive = true
+	stream.archiveCount++
+	if stream.archiveCount > len(stream.Tablespaces) {
+		return baseBackupArchive{isManifest: true}, nil
+	}
+	return baseBackupArchive{tablespace: stream.Tablespaces[stream.archiveCount-1]}, nil
+}
+
+func (stream *baseBackupStream) nextArchiveNewProtocol() (baseBackupArchive, error) {
+	if stream.archiveCount == 0 {
+		message, err := stream.receive()
+		if err != nil {
+			return baseBackupArchive{}, err
+		}
+		if _, ok := message.(*pgproto3.CopyOutResponse); !ok {
+			return baseBackupArchive{}, errors.Errorf("unexpected BASE_BACKUP message %T, expected CopyOutResponse", message)
+		}
+	}
+	for stream.nextArchive == nil {
+		if stream.copyDone {
+			return baseBackupArchive{}, io.EOF
+		}
+		data, err := stream.receiveCopyData()
+		if err != nil {
+			return baseBackupArchive{}, err
+		}
+		if data != nil {
+			return baseBackupArchive{}, errors.New("unexpected BASE_BACKUP archive data before the archive start")
+		}
+	}
+	archive := *stream.nextArchive
+	stream.nextArchive = nil
+	stream.inArchive = true
+	stream.archiveCount++
+	return archive, nil
+}
+
+// receiveCopyData receives the archive data of the new protocol, the archive starts are saved to nextArchive
+func (stream *baseBackupStream) receiveCopyData() ([]byte, error) {
+	message, err := stream.receive()
+	if err != nil {
+		return nil, err
+	}
+	switch msg := message.(type) {
+	case *pgproto3.CopyDone:
+		stream.copyDone = true
+		return nil, nil
+	case *pgproto3.CopyData:
+		if len(msg.Data) == 0 {
+			return nil, errors.New("empty BASE_BACKUP CopyData message")
+		}
+		switch msg.Data[0] {
+		case 'd':
+			return msg.Data[1:], nil
+		case 'p':
+			return nil, nil
+		case 'm':
+			stream.nextArchive = &baseBackupArchive{isManifest: true}
+			return nil, nil
+		case 'n':
+			archive, err := stream.parseNewArchive(msg.Data[1:])
+			if err != nil {
+				return nil, err
+			}
+			stream.nextArchive = &archive
+			return nil, nil
+		default:
+			return nil, errors.Errorf("unexpected BASE_BACKUP CopyData message kind '%c'", msg.Data[0])
+		}
+	default:
+		return nil, errors.Errorf("unexpected BASE_BACKUP message %T in the archive stream", msg)
+	}
+}
+
+// parseNewArchive parses the archive name and the tablespace location of the new archive message
+func (stream *baseBackupStream) parseNewArchive(data []byte) (baseBackupArchive, error) {
+	fields := bytes.Split(data, []byte{0})
+	if len(fields) < 2 {
+		return baseBackupArchive{}, errors.New("malformed BASE_BACKUP new archive message")
+	}
+	location := string(fields[1])
+	for _, tablespace := range stream.Tablespaces {
+		if tablespace.Location == location {
+			return baseBackupArchive{tablespace: tablespace}, nil
+		}
+	}
+	return baseBackupArchive{}, errors.Errorf("BASE_BACKUP archive %s of an unknown tablespace '%s'", fields[0], location)
+}
+
+// Read reads the data of the current archive, it returns io.EOF at the end of the archive
+func (stream *baseBackupStream) Read(p []byte) (int, error) {
+	for len(stream.buffer) == 0 {
+		if !stream.inArchive {
+			return 0, io.EOF
+		}
+		data, err := stream.receiveArchiveData()
+		if err != nil {
+			return 0, err
+		}
+		stream.buffer = data
+	}
+	n := copy(p, stream.buffer)
+	stream.buffer = stream.buffer[n:]
+	return n, nil
+}
+
+func (stream *baseBackupStream) receiveArchiveData() ([]byte, error) {
+	if stream.newProtocol {
+		data, err := stream.receiveCopyData()
+		if err != nil {
+			return nil, err
+		}
+		if stream.copyDone || stream.nextArchive != nil {
+			stream.inArchive = false
+		}
+		return data, nil
+	}
+
+	message, err := stream.receive()
+	if err != nil {
+		return nil, err
+	}
+	switch msg := message.(type) {
+	case *pgproto3.CopyData:
+		return msg.Data, nil
+	case *pgproto3.CopyDone:
+		stream.inArchive = false
+		return nil, nil
+	default:
+		return nil, errors.Errorf("unexpected BASE_BACKUP message %T in the archive stream", msg)
+	}
+}
+
+// Finish reads the backup end LSN and timeline after all the archives
+func (stream *baseBackupStream) Finish() (LSN, uint32, error) {
+	if _, err := stream.NextArchive(); err != io.EOF {
+		if err == nil {
+			err = errors.New("BASE_BACKUP archives are not read up to the end")
+		}
+		return 0, 0, err
+	}
+	rows, err := stream.receiveResultSet()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(rows) != 1 || len(rows[0]) < 2 {
+		return 0, 0, errors.Errorf("unexpected BASE_BACKUP end position result: %v", rows)
+	}
+	endLSN, endTimeline, err := parseBaseBackupPosition(rows[0])
+	if err != nil {
+		return 0, 0, err
+	}
+	for {
+		message, err := stream.receive()
+		if err != nil {
+			return 0, 0, err
+		}
+		if _, ok := message.(*pgproto3.ReadyForQuery); ok {
+			return endLSN, endTimeline, nil
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBaseBackupConn struct {
+	sent     []byte
+	messages []pgproto3.BackendMessage
+}
+
+func (conn *fakeBaseBackupConn) SendBytes(_ context.Context, buf []byte) error {
+	conn.sent = append(conn.sent, buf...)
+	return nil
+}
+
+func (conn *fakeBaseBackupConn) ReceiveMessage(_ context.Context) (pgproto3.BackendMessage, error) {
+	if len(conn.messages) == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+	message := conn.messages[0]
+	conn.messages = conn.messages[1:]
+	return message, nil
+}
+
+func baseBackupResultSet(rows ...[][]byte) []pgproto3.BackendMessage {
+	messages := []pgproto3.BackendMessage{&pgproto3.RowDescription{}}
+	for _, row := range rows {
+		messages = append(messages, &pgproto3.DataRow{Values: row})
+	}
+	return append(messages, &pgproto3.CommandComplete{CommandTag: []byte("SELECT")})
+}
+
+func baseBackupStartMessages() []pgproto3.BackendMessage {
+	messages := baseBackupResultSet([][]byte{[]byte("0/2000028"), []byte("1")})
+	return append(messages, baseBackupResultSet(
+		[][]byte{nil, nil, nil},
+		[][]byte{[]byte("16384"), []byte("/tblspc"), []byte("10")})...)
+}
+
+func baseBackupEndMessages() []pgproto3.BackendMessage {
+	messages := baseBackupResultSet([][]byte{[]byte("0/2000100"), []byte("1")})
+	return append(messages, &pgproto3.CommandComplete{CommandTag: []byte("BASE_BACKUP")},
+		&pgproto3.ReadyForQuery{TxStatus: 'I'})
+}
+
+func readBaseBackupArchives(t *testing.T, stream *baseBackupStream) map[string]string {
+	archives := make(map[string]string)
+	for {
+		archive, err := stream.NextArchive()
+		if err == io.EOF {
+			return archives
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(stream)
+		require.NoError(t, err)
+		name := archive.tablespace.Location
+		if archive.isManifest {
+			name = "manifest"
+		}
+		archives[name] = string(data)
+	}
+}
+
+func TestBaseBackupOptionsCommand(t *testing.T) {
+	options := BaseBackupOptions{
+		Label:             "wal-g",
+		Fast:              true,
+		MaxRate:           64,
+		TablespaceMap:     true,
+		Manifest:          true,
+		Compression:       "zstd",
+		CompressionDetail: "level=5",
+	}
+	assert.Equal(t, "BASE_BACKUP (LABEL 'wal-g', CHECKPOINT 'fast', MAX_RATE 64, TABLESPACE_MAP, "+
+		"VERIFY_CHECKSUMS false, MANIFEST 'yes', COMPRESSION 'zstd', COMPRESSION_DETAIL 'level=5')",
+		options.command(150000))
+
+	options.Compression = ""
+	assert.Equal(t, "BASE_BACKUP LABEL 'wal-g' FAST MAX_RATE 64 TABLESPACE_MAP NOVERIFY_CHECKSUMS MANIFEST 'yes'",
+		options.command(130000))
+	options.VerifyChecksums = true
+	assert.Equal(t, "BASE_BACKUP LABEL 'wal-g' FAST MAX_RATE 64 TABLESPACE_MAP", options.command(120000))
+}
+
+func TestBaseBackupStream_Legacy(t *testing.T) {
+	conn := &fakeBaseBackupConn{messages: baseBackupStartMessages()}
+	conn.messages = append(conn.messages,
+		&pgproto3.CopyOutResponse{}, &pgproto3.CopyData{Data: []byte("base")}, &pgproto3.CopyDone{},
+		&pgproto3.CopyOutResponse{}, &pgproto3.CopyData{Data: []byte("tbs")}, &pgproto3.CopyDone{},
+		&pgproto3.CopyOutResponse{}, &pgproto3.CopyData{Data: []byte("mani")},
+		&pgproto3.CopyData{Data: []byte("fest")}, &pgproto3.CopyDone{})
+	conn.messages = append(conn.messages, baseBackupEndMessages()...)
+
+	stream, err := startBaseBackupStream(conn, BaseBackupOptions{Manifest: true}, 140000)
+	require.NoError(t, err)
+	assert.Equal(t, LSN(0x2000028), stream.StartLSN)
+	assert.Equal(t, uint32(1), stream.Timeline)
+	assert.Equal(t, []BaseBackupTablespace{{}, {OID: 16384, Location: "/tblspc", Size: 10}}, stream.Tablespaces)
+
+	assert.Equal(t, map[string]string{"": "base", "/tblspc": "tbs", "manifest": "manifest"},
+		readBaseBackupArchives(t, stream))
+	endLSN, endTimeline, err := stream.Finish()
+	require.NoError(t, err)
+	assert.Equal(t, LSN(0x2000100), endLSN)
+	assert.Equal(t, uint32(1), endTimeline)
+	assert.Empty(t, conn.messages)
+}
+
+func TestBaseBackupStream_NewProtocol(t *testing.T) {
+	conn := &fakeBaseBackupConn{messages: baseBackupStartMessages()}
+	conn.messages = append(conn.messages,
+		&pgproto3.CopyOutResponse{},
+		&pgproto3.CopyData{Data: []byte("nbase.tar\x00\x00")},
+		&pgproto3.CopyData{Data: []byte("dba")},
+		&pgproto3.CopyData{Data: []byte("p12345678")},
+		&pgproto3.CopyData{Data: []byte("dse")},
+		&pgproto3.CopyData{Data: []byte("n16384.tar\x00/tblspc\x00")},
+		&pgproto3.CopyData{Data: []byte("dtbs")},
+		&pgproto3.CopyData{Data: []byte("m")},
+		&pgproto3.CopyData{Data: []byte("dmanifest")},
+		&pgproto3.CopyDone{})
+	conn.messages = append(conn.messages, baseBackupEndMessages()...)
+
+	stream, err := startBaseBackupStream(conn, BaseBackupOptions{Manifest: true}, 150000)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"": "base", "/tblspc": "tbs", "manifest": "manifest"},
+		readBaseBackupArchives(t, stream))
+	endLSN, _, err := stream.Finish()
+	require.NoError(t, err)
+	assert.Equal(t, LSN(0x2000100), endLSN)
+	assert.Empty(t, conn.messages)
+}
+
+func TestBaseBackupStream_Error(t *testing.T) {
+	conn := &fakeBaseBackupConn{messages: []pgproto3.BackendMessage{
+		&pgproto3.ErrorResponse{Severity: "ERROR", Message: "could not find any WAL files"},
+	}}
+	_, err := startBaseBackupStream(conn, BaseBackupOptions{}, 150000)
+	assert.ErrorContains(t, err, "could not find any WAL files")
+}
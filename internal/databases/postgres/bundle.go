@@ -2,7 +2,9 @@ package postgres
 
 import (
 	"archive/tar"
+	"bytes"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 	"path/filepath"
@@ -69,6 +71,8 @@ type Bundle struct {
 	DeltaMap           PagedFileDeltaMap
 	TablespaceSpec     TablespaceSpec
 	DataCatalogSize    *int64
+	// Manifest collects the files for the backup_manifest, nil if it is not generated
+	Manifest *BackupManifestBuilder
 
 	forceIncremental bool
 }
@@ -326,13 +330,22 @@ func (bundle *Bundle) UploadPgControl(compressorFileExtension string) error {
 			N: fileInfoHeader.Size,
 		}
 
-		_, err = io.Copy(tarWriter, lim)
+		var fileReader io.Reader = lim
+		var checksum hash.Hash
+		if bundle.Manifest != nil {
+			checksum = bundle.Manifest.NewChecksumHash()
+			fileReader = io.TeeReader(lim, checksum)
+		}
+		_, err = io.Copy(tarWriter, fileReader)
 		if err != nil {
 			return errors.Wrap(err, "UploadPgControl: copy failed")
 		}
 
 		tarBall.AddSize(fileInfoHeader.Size)
 		utility.LoggedClose(file, "")
+		if bundle.Manifest != nil {
+			bundle.Manifest.AddFile(fileInfoHeader.Name, fileInfoHeader.Size, info.ModTime(), checksum)
+		}
 	}
 
 	err = bundle.TarBallQueue.CloseTarball(tarBall)
@@ -390,10 +403,53 @@ func (bundle *Bundle) uploadLabelFiles(queryRunner *PgQueryRunner) (string, []st
 	if err != nil {
 		return "", nil, 0, errors.Wrap(err, "UploadLabelFiles: failed to close tarball")
 	}
+	bundle.addManifestContent(BackupLabelFilename, label)
+	bundle.addManifestContent(TablespaceMapFilename, offsetMap)
 
 	return tarBall.Name(), []string{TablespaceMapFilename, BackupLabelFilename}, lsn, nil
 }
 
+// addManifestContent adds the file written from memory to the backup_manifest
+func (bundle *Bundle) addManifestContent(name string, content string) {
+	if bundle.Manifest == nil {
+		return
+	}
+	checksum := bundle.Manifest.NewChecksumHash()
+	_, _ = io.WriteString(checksum, content)
+	bundle.Manifest.AddFile(name, int64(len(content)), utility.TimeNowCrossPlatformUTC(), checksum)
+}
+
+// uploadManifest uploads the backup_manifest in its own tarball, so backup-fetch restores it into the data directory
+func (bundle *Bundle) uploadManifest(pgVersion int, systemIdentifier *uint64, startLSN, endLSN LSN) (string, error) {
+	manifest, err := bundle.Manifest.Build(pgVersion, systemIdentifier, bundle.Timeline, startLSN, endLSN)
+	if err != nil {
+		return "", errors.Wrap(err, "uploadManifest: failed to build the backup manifest")
+	}
+
+	tarBall := bundle.NewTarBall(false)
+	tarBall.SetUp(bundle.Crypter)
+	manifestHeader := &tar.Header{
+		Name:     BackupManifestFilename,
+		Mode:     int64(0600),
+		Size:     int64(len(manifest)),
+		ModTime:  utility.TimeNowCrossPlatformUTC(),
+		Typeflag: tar.TypeReg,
+	}
+	_, err = internal.PackFileTo(tarBall, manifestHeader, bytes.NewReader(manifest))
+	if err != nil {
+		return "", errors.Wrapf(err, "uploadManifest: failed to put %s to tar", manifestHeader.Name)
+	}
+	tracelog.InfoLogger.Println(manifestHeader.Name)
+
+	err = bundle.TarBallQueue.CloseTarball(tarBall)
+	if err != nil {
+		return "", errors.Wrap(err, "uploadManifest: failed to close tarball")
+	}
+	bundle.TarBallComposer.GetFiles().AddFileDescription(BackupManifestFilename,
+		internal.BackupFileDescription{MTime: manifestHeader.ModTime, Size: manifestHeader.Size})
+	return tarBall.Name(), nil
+}
+
 func (bundle *Bundle) getDeltaBitmapFor(filePath string) (*roaring.Bitmap, error) {
 	if bundle.DeltaMap == nil {
 		return nil, nil
@@ -410,6 +466,22 @@ func (bundle *Bundle) DownloadDeltaMap(reader internal.StorageFolderReader, back
 	return nil
 }
 
+// LoadWalSummaryDeltaMap builds the delta map from the WAL summaries of PostgreSQL 17+. The summaries
+// are used only if the timeline has not changed since the increment base backup.
+func (bundle *Bundle) LoadWalSummaryDeltaMap(queryRunner *PgQueryRunner, incrementFromTimeline uint32,
+	backupStartLSN LSN) error {
+	if incrementFromTimeline != bundle.Timeline {
+		return fmt.Errorf("timeline has changed from %d to %d since the increment base backup",
+			incrementFromTimeline, bundle.Timeline)
+	}
+	deltaMap, err := getWalSummaryDeltaMap(queryRunner, bundle.Timeline, *bundle.IncrementFromLsn, backupStartLSN)
+	if err != nil {
+		return err
+	}
+	bundle.DeltaMap = deltaMap
+	return nil
+}
+
 func (bundle *Bundle) FinishTarComposer() (internal.TarFileSets, error) {
 	return bundle.TarBallComposer.FinishComposing()
 }
@@ -31,7 +31,7 @@ func (files *StatBundleFiles) AddFileWithCorruptBlocks(tarHeader *tar.Header,
 	storeAllBlocks bool) {
 	updatesCount := files.fileStats.getFileUpdateCount(tarHeader.Name)
 	fileDescription := internal.BackupFileDescription{IsSkipped: false, IsIncremented: isIncremented, MTime: fileInfo.ModTime(),
-		UpdatesCount: updatesCount}
+		UpdatesCount: updatesCount, Size: fileInfo.Size()}
 	fileDescription.SetCorruptBlocks(corruptedBlocks, storeAllBlocks)
 	files.AddFileDescription(tarHeader.Name, fileDescription)
 }
@@ -47,7 +47,7 @@ func (files *StatBundleFiles) AddFile(tarHeader *tar.Header, fileInfo os.FileInf
 	updatesCount := files.fileStats.getFileUpdateCount(tarHeader.Name)
 	files.AddFileDescription(tarHeader.Name,
 		internal.BackupFileDescription{IsSkipped: false, IsIncremented: isIncremented,
-			MTime: fileInfo.ModTime(), UpdatesCount: updatesCount})
+			MTime: fileInfo.ModTime(), UpdatesCount: updatesCount, Size: fileInfo.Size()})
 }
 
 func (files *StatBundleFiles) AddFileDescription(name string, backupFileDescription internal.BackupFileDescription) {
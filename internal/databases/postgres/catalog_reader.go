@@ -0,0 +1,338 @@
+package postgres
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// The oids of the system catalogs the relfilenodes of the tables are read from
+const (
+	pgClassOid     = 1259
+	pgIndexOid     = 2610
+	pgNamespaceOid = 2615
+)
+
+const (
+	relMapperFileName  = "pg_filenode.map"
+	relMapperFileMagic = 0x592717
+
+	// the offsets in the heap tuple header, see htup_details.h
+	tupleInfomaskOffset = 20
+	tupleHoffOffset     = 22
+	tupleHeaderMinSize  = 23
+	heapXminInvalid     = 0x0200
+	heapHasOid          = 0x0008
+
+	itemIDSize   = 4
+	itemIDNormal = 1
+	nameDataLen  = 64
+
+	// the PostgreSQL version the oid of the catalog rows became a regular column
+	oidColumnVersion = 120000
+)
+
+// catalogReader reads the relfilenodes of the user tables from the system catalog files of a database
+// restored to a directory. Both the live and the dead row versions are read, so the relfilenodes
+// the tables had over the backup are all found.
+type catalogReader struct {
+	dbDirectory string
+	pgVersion   int
+}
+
+// catalogClass is a row version of pg_class
+type catalogClass struct {
+	oid       uint32
+	name      string
+	namespace uint32
+	fileNode  uint32
+	toastOid  uint32
+	kind      byte
+}
+
+func newCatalogReader(dbDirectory string, pgVersion int) *catalogReader {
+	return &catalogReader{dbDirectory: dbDirectory, pgVersion: pgVersion}
+}
+
+// readMappedFileNode reads the relfilenode of the mapped catalog from pg_filenode.map
+func (reader *catalogReader) readMappedFileNode(oid uint32) (uint32, error) {
+	content, err := os.ReadFile(path.Join(reader.dbDirectory, relMapperFileName))
+	if err != nil {
+		return 0, err
+	}
+	if len(content) < 8 || binary.LittleEndian.Uint32(content) != relMapperFileMagic {
+		return 0, errors.Errorf("%s has an unknown format", relMapperFileName)
+	}
+	mappingCount := int(binary.LittleEndian.Uint32(content[4:]))
+	for i := 0; i < mappingCount && 16+8*i <= len(content); i++ {
+		mapping := content[8+8*i:]
+		if binary.LittleEndian.Uint32(mapping) == oid {
+			return binary.LittleEndian.Uint32(mapping[4:]), nil
+		}
+	}
+	return 0, errors.Errorf("the catalog %d is not in %s", oid, relMapperFileName)
+}
+
+// readTuples calls visit with the data and the header oid of every row version of the relation,
+// the relfilenodes not in the backup are skipped
+func (reader *catalogReader) readTuples(fileNode uint32, visit func(data []byte, headerOid uint32) error) error {
+	for segment := 0; ; segment++ {
+		fileName := strconv.FormatUint(uint64(fileNode), 10)
+		if segment > 0 {
+			fileName += "." + strconv.Itoa(segment)
+		}
+		content, err := os.ReadFile(path.Join(reader.dbDirectory, fileName))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for offset := 0; offset+int(DatabasePageSize) <= len(content); offset += int(DatabasePageSize) {
+			err = reader.readPageTuples(content[offset:offset+int(DatabasePageSize)], visit)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read the page %d of '%s'", offset/int(DatabasePageSize), fileName)
+			}
+		}
+	}
+}
+
+func (reader *catalogReader) readPageTuples(page []byte, visit func(data []byte, headerOid uint32) error) error {
+	header, err := parsePostgresPageHeader(bytes.NewReader(page))
+	if err != nil {
+		return err
+	}
+	if header.isNew() {
+		return nil
+	}
+	if header.pdLower < headerSize || int(header.pdLower) > len(page) {
+		return errors.Errorf("invalid pd_lower %d", header.pdLower)
+	}
+	for itemOffset := headerSize; itemOffset+itemIDSize <= int(header.pdLower); itemOffset += itemIDSize {
+		itemID := binary.LittleEndian.Uint32(page[itemOffset:])
+		tupleOffset, flags, length := int(itemID&0x7FFF), (itemID>>15)&3, int(itemID>>17)
+		if flags != itemIDNormal {
+			continue
+		}
+		if length < tupleHeaderMinSize || tupleOffset+length > len(page) {
+			return errors.Errorf("invalid line pointer at %d", itemOffset)
+		}
+		tuple := page[tupleOffset : tupleOffset+length]
+		infomask := binary.LittleEndian.Uint16(tuple[tupleInfomaskOffset:])
+		hoff := int(tuple[tupleHoffOffset])
+		if infomask&heapXminInvalid != 0 {
+			continue
+		}
+		if hoff > length || hoff < tupleHeaderMinSize {
+			return errors.Errorf("invalid tuple header size at %d", tupleOffset)
+		}
+		var headerOid uint32
+		if reader.pgVersion < oidColumnVersion && infomask&heapHasOid != 0 {
+			headerOid = binary.LittleEndian.Uint32(tuple[hoff-4:])
+		}
+		if err = visit(tuple[hoff:], headerOid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readRowOid returns the oid of the catalog row and the data of its other columns
+func (reader *catalogReader) readRowOid(data []byte, headerOid uint32) (uint32, []byte) {
+	if reader.pgVersion < oidColumnVersion {
+		return headerOid, data
+	}
+	if len(data) < 4 {
+		return 0, nil
+	}
+	return binary.LittleEndian.Uint32(data), data[4:]
+}
+
+func (reader *catalogReader) readClasses(fileNode uint32) ([]catalogClass, error) {
+	var classes []catalogClass
+	err := reader.readTuples(fileNode, func(data []byte, headerOid uint32) error {
+		// relname, relnamespace, reltype, reloftype, relowner, relam, relfilenode, reltablespace,
+		// relpages, reltuples, relallvisible, reltoastrelid, relhasindex, relisshared, relpersistence, relkind
+		oid, data := reader.readRowOid(data, headerOid)
+		if len(data) < nameDataLen+48 {
+			return errors.New("pg_class row is too short")
+		}
+		columns := data[nameDataLen:]
+		classes = append(classes, catalogClass{
+			oid:       oid,
+			name:      readNameData(data),
+			namespace: binary.LittleEndian.Uint32(columns),
+			fileNode:  binary.LittleEndian.Uint32(columns[20:]),
+			toastOid:  binary.LittleEndian.Uint32(columns[40:]),
+			kind:      columns[47],
+		})
+		return nil
+	})
+	if err == nil && len(classes) == 0 {
+		err = errors.New("pg_class is not in the backup")
+	}
+	return classes, err
+}
+
+func (reader *catalogReader) readNamespaces(fileNodes []uint32) (map[uint32]string, error) {
+	namespaces := make(map[uint32]string)
+	for _, fileNode := range fileNodes {
+		err := reader.readTuples(fileNode, func(data []byte, headerOid uint32) error {
+			oid, data := reader.readRowOid(data, headerOid)
+			if len(data) < nameDataLen {
+				return errors.New("pg_namespace row is too short")
+			}
+			namespaces[oid] = readNameData(data)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(namespaces) == 0 {
+		return nil, errors.New("pg_namespace is not in the backup")
+	}
+	return namespaces, nil
+}
+
+// readIndexes maps the oids of the indexed relations to the oids of their indexes
+func (reader *catalogReader) readIndexes(fileNodes []uint32) (map[uint32][]uint32, error) {
+	indexes := make(map[uint32][]uint32)
+	for _, fileNode := range fileNodes {
+		err := reader.readTuples(fileNode, func(data []byte, _ uint32) error {
+			if len(data) < 8 {
+				return errors.New("pg_index row is too short")
+			}
+			indexOid, tableOid := binary.LittleEndian.Uint32(data), binary.LittleEndian.Uint32(data[4:])
+			indexes[tableOid] = append(indexes[tableOid], indexOid)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(indexes) == 0 {
+		return nil, errors.New("pg_index is not in the backup")
+	}
+	return indexes, nil
+}
+
+// findFileNodes returns the relfilenodes of every row version of the catalog in pg_class
+func findFileNodes(classes []catalogClass, oid uint32) []uint32 {
+	var fileNodes []uint32
+	for _, class := range classes {
+		if class.oid == oid && class.fileNode != 0 {
+			fileNodes = append(fileNodes, class.fileNode)
+		}
+	}
+	return fileNodes
+}
+
+// buildCatalogTables maps the schema qualified table names to the relfilenodes of the table, its TOAST table
+// and indexes, the same way as BuildGetTablesQuery
+func buildCatalogTables(classes []catalogClass, namespaces map[uint32]string,
+	indexes map[uint32][]uint32) map[string][]int {
+	fileNodes := make(map[uint32][]uint32)
+	for _, class := range classes {
+		if class.fileNode != 0 {
+			fileNodes[class.oid] = append(fileNodes[class.oid], class.fileNode)
+		}
+	}
+	relationFileNodes := func(oid uint32, result map[int]bool) {
+		for _, fileNode := range fileNodes[oid] {
+			result[int(fileNode)] = true
+		}
+		for _, indexOid := range indexes[oid] {
+			for _, fileNode := range fileNodes[indexOid] {
+				result[int(fileNode)] = true
+			}
+		}
+	}
+
+	tableFileNodes := make(map[string]map[int]bool)
+	for _, class := range classes {
+		namespace, ok := namespaces[class.namespace]
+		if !ok || (class.kind != 'r' && class.kind != 'm') || isSystemNamespace(namespace) {
+			continue
+		}
+		name := namespace + "." + class.name
+		if tableFileNodes[name] == nil {
+			tableFileNodes[name] = make(map[int]bool)
+		}
+		relationFileNodes(class.oid, tableFileNodes[name])
+		if class.toastOid != 0 {
+			relationFileNodes(class.toastOid, tableFileNodes[name])
+		}
+	}
+
+	tables := make(map[string][]int, len(tableFileNodes))
+	for name, set := range tableFileNodes {
+		for fileNode := range set {
+			tables[name] = append(tables[name], fileNode)
+		}
+		sort.Ints(tables[name])
+	}
+	return tables
+}
+
+func isSystemNamespace(namespace string) bool {
+	return namespace == "pg_catalog" || namespace == "information_schema" || namespace == "pg_toast"
+}
+
+func readNameData(data []byte) string {
+	name := data[:nameDataLen]
+	if end := bytes.IndexByte(name, 0); end >= 0 {
+		name = name[:end]
+	}
+	return string(name)
+}
+
+// readCatalogTables reads the relfilenodes of the user tables from pg_class, pg_namespace and pg_index.
+// The extract function is called with the names of the catalog files before they are read, so only
+// the files needed are restored to the directory.
+func readCatalogTables(dbDirectory string, pgVersion int, extract func(names ...string) error) (map[string][]int, error) {
+	if pgVersion == 0 {
+		return nil, errors.New("the backup does not record the PostgreSQL version")
+	}
+	if err := extract(relMapperFileName); err != nil {
+		return nil, err
+	}
+	reader := newCatalogReader(dbDirectory, pgVersion)
+	classFileNode, err := reader.readMappedFileNode(pgClassOid)
+	if err != nil {
+		return nil, err
+	}
+	if err = extract(fileNodeNames(classFileNode)...); err != nil {
+		return nil, err
+	}
+	classes, err := reader.readClasses(classFileNode)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read pg_class")
+	}
+	namespaceFileNodes, indexFileNodes := findFileNodes(classes, pgNamespaceOid), findFileNodes(classes, pgIndexOid)
+	if err = extract(fileNodeNames(append(namespaceFileNodes, indexFileNodes...)...)...); err != nil {
+		return nil, err
+	}
+	namespaces, err := reader.readNamespaces(namespaceFileNodes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read pg_namespace")
+	}
+	indexes, err := reader.readIndexes(indexFileNodes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read pg_index")
+	}
+	return buildCatalogTables(classes, namespaces, indexes), nil
+}
+
+func fileNodeNames(fileNodes ...uint32) []string {
+	names := make([]string, 0, len(fileNodes))
+	for _, fileNode := range fileNodes {
+		names = append(names, strconv.FormatUint(uint64(fileNode), 10))
+	}
+	return names
+}
@@ -0,0 +1,208 @@
+package postgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPublicNamespace  = 2200
+	testSalesNamespace   = 16500
+	testCatalogNamespace = 11
+	testToastNamespace   = 99
+)
+
+type testCatalogRow struct {
+	oid     uint32
+	data    []byte
+	invalid bool
+	// the rows of pg_index have no oid
+	withoutOid bool
+}
+
+// makeCatalogPage lays the rows out as a heap page does, the oids are stored in the tuple headers before PostgreSQL 12
+func makeCatalogPage(pgVersion int, rows ...testCatalogRow) []byte {
+	page := make([]byte, DatabasePageSize)
+	lower, upper := headerSize, len(page)
+	for _, row := range rows {
+		hoff, infomask := 24, uint16(0)
+		data := row.data
+		switch {
+		case row.withoutOid:
+		case pgVersion < oidColumnVersion:
+			hoff, infomask = 32, heapHasOid
+		default:
+			data = append(binary.LittleEndian.AppendUint32(nil, row.oid), data...)
+		}
+		if row.invalid {
+			infomask |= heapXminInvalid
+		}
+		tuple := make([]byte, hoff+len(data))
+		binary.LittleEndian.PutUint16(tuple[tupleInfomaskOffset:], infomask)
+		tuple[tupleHoffOffset] = byte(hoff)
+		if infomask&heapHasOid != 0 {
+			binary.LittleEndian.PutUint32(tuple[hoff-4:], row.oid)
+		}
+		copy(tuple[hoff:], data)
+
+		upper -= (len(tuple) + 7) / 8 * 8
+		copy(page[upper:], tuple)
+		binary.LittleEndian.PutUint32(page[lower:], uint32(upper)|itemIDNormal<<15|uint32(len(tuple))<<17)
+		lower += itemIDSize
+	}
+	// an unused line pointer
+	lower += itemIDSize
+	binary.LittleEndian.PutUint16(page[12:], uint16(lower))
+	binary.LittleEndian.PutUint16(page[14:], uint16(upper))
+	binary.LittleEndian.PutUint16(page[16:], uint16(DatabasePageSize))
+	return page
+}
+
+func makeName(name string) []byte {
+	data := make([]byte, nameDataLen)
+	copy(data, name)
+	return data
+}
+
+func classRow(oid uint32, name string, namespace, fileNode, toastOid uint32, kind byte) testCatalogRow {
+	columns := make([]byte, 48)
+	binary.LittleEndian.PutUint32(columns, namespace)
+	binary.LittleEndian.PutUint32(columns[20:], fileNode)
+	binary.LittleEndian.PutUint32(columns[40:], toastOid)
+	columns[47] = kind
+	return testCatalogRow{oid: oid, data: append(makeName(name), columns...)}
+}
+
+func namespaceRow(oid uint32, name string) testCatalogRow {
+	return testCatalogRow{oid: oid, data: makeName(name)}
+}
+
+func indexRow(indexOid, tableOid uint32) testCatalogRow {
+	data := binary.LittleEndian.AppendUint32(nil, indexOid)
+	return testCatalogRow{data: binary.LittleEndian.AppendUint32(data, tableOid), withoutOid: true}
+}
+
+func makeFileNodeMap(mappings ...uint32) []byte {
+	content := binary.LittleEndian.AppendUint32(nil, relMapperFileMagic)
+	content = binary.LittleEndian.AppendUint32(content, uint32(len(mappings)/2))
+	for _, value := range mappings {
+		content = binary.LittleEndian.AppendUint32(content, value)
+	}
+	return append(content, make([]byte, 512-len(content))...)
+}
+
+// makeTestCatalog returns the catalog files of a database with the public.orders table having a TOAST table
+// and indexes, and the sales.customers table truncated during the backup
+func makeTestCatalog(pgVersion int, classFileNode uint32) map[string][]byte {
+	ghost := classRow(16420, "ghost", testPublicNamespace, 16420, 0, 'r')
+	ghost.invalid = true
+	return map[string][]byte{
+		relMapperFileName: makeFileNodeMap(pgClassOid, classFileNode, 1249, 1249),
+		fileNodeNames(classFileNode)[0]: makeCatalogPage(pgVersion,
+			classRow(pgClassOid, "pg_class", testCatalogNamespace, 0, 0, 'r'),
+			classRow(pgNamespaceOid, "pg_namespace", testCatalogNamespace, pgNamespaceOid, 0, 'r'),
+			classRow(pgIndexOid, "pg_index", testCatalogNamespace, pgIndexOid, 0, 'r'),
+			classRow(16400, "orders", testPublicNamespace, 16400, 16402, 'r'),
+			classRow(16402, "pg_toast_16400", testToastNamespace, 16403, 0, 't'),
+			classRow(16404, "orders_pkey", testPublicNamespace, 16405, 0, 'i'),
+			classRow(16406, "pg_toast_16400_index", testToastNamespace, 16406, 0, 'i'),
+			classRow(16407, "report", testPublicNamespace, 0, 0, 'v'),
+			classRow(16410, "customers", testSalesNamespace, 16412, 0, 'r'),
+			classRow(16410, "customers", testSalesNamespace, 16413, 0, 'r'),
+			ghost),
+		fileNodeNames(pgNamespaceOid)[0]: makeCatalogPage(pgVersion,
+			namespaceRow(testCatalogNamespace, "pg_catalog"),
+			namespaceRow(testToastNamespace, "pg_toast"),
+			namespaceRow(testPublicNamespace, "public"),
+			namespaceRow(testSalesNamespace, "sales")),
+		fileNodeNames(pgIndexOid)[0]: makeCatalogPage(pgVersion,
+			indexRow(16404, 16400),
+			indexRow(16406, 16402)),
+	}
+}
+
+func TestReadCatalogTables(t *testing.T) {
+	for _, pgVersion := range []int{110000, 150000} {
+		dir := t.TempDir()
+		for name, content := range makeTestCatalog(pgVersion, pgClassOid) {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), content, 0600))
+		}
+		var extracted []string
+		tables, err := readCatalogTables(dir, pgVersion, func(names ...string) error {
+			extracted = append(extracted, names...)
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]int{
+			"public.orders":   {16400, 16403, 16405, 16406},
+			"sales.customers": {16412, 16413},
+		}, tables, pgVersion)
+		assert.Equal(t, []string{relMapperFileName, "1259", "2615", "2610"}, extracted)
+	}
+}
+
+func TestReadCatalogTables_FailsWithoutCatalog(t *testing.T) {
+	_, err := readCatalogTables(t.TempDir(), 150000, func(names ...string) error { return nil })
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, relMapperFileName), makeFileNodeMap(pgClassOid, 16600), 0600))
+	_, err = readCatalogTables(dir, 150000, func(names ...string) error { return nil })
+	assert.Error(t, err)
+}
+
+func TestExtractProviderDBSpec_RestoreOnlyTableFromCatalog(t *testing.T) {
+	const pgVersion = 150000
+	backup := NewBackup(memory.NewFolder("in_memory/", memory.NewStorage()), "base_000000010000000000000002")
+	backup.SentinelDto = &BackupSentinelDto{PgVersion: pgVersion}
+	backup.FilesMetadataDto = &FilesMetadataDto{
+		Files: internal.BackupFileList{},
+		DatabasesByNames: DatabasesByNames{
+			"postgres": {Oid: 5},
+			"shop":     {Oid: 16390},
+		},
+	}
+
+	// pg_class was rewritten by VACUUM FULL, so its relfilenode is a user one
+	var tarContent bytes.Buffer
+	tarWriter := tar.NewWriter(&tarContent)
+	filesToUnwrap := map[string]bool{"/global/pg_control": true}
+	for name, content := range makeTestCatalog(pgVersion, 16600) {
+		file := "/base/16390/" + name
+		require.NoError(t, tarWriter.WriteHeader(&tar.Header{Name: file, Mode: 0600, Size: int64(len(content)),
+			Typeflag: tar.TypeReg}))
+		_, err := tarWriter.Write(content)
+		require.NoError(t, err)
+		filesToUnwrap[file] = true
+	}
+	require.NoError(t, tarWriter.Close())
+	require.NoError(t, backup.getTarPartitionFolder().PutObject("part_1.tar", &tarContent))
+	for _, file := range []string{"/base/16390/16400", "/base/16390/16403", "/base/16390/16405",
+		"/base/16390/16412", "/base/16390/16413", "/base/16390/16430"} {
+		filesToUnwrap[file] = true
+	}
+
+	p := NewExtractProviderDBSpec([]string{"shop/sales.customers"})
+	_, _, _, err := p.Get(backup, filesToUnwrap, true, "", false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{
+		"/global/pg_control":          true,
+		"/base/16390/pg_filenode.map": true,
+		"/base/16390/16600":           true,
+		"/base/16390/2615":            true,
+		"/base/16390/2610":            true,
+		"/base/16390/16412":           true,
+		"/base/16390/16413":           true,
+		// the relations not in the catalog are restored
+		"/base/16390/16430": true,
+	}, filesToUnwrap)
+}
@@ -14,6 +14,10 @@ import (
 // that a valid session has started; if invalid, returns AWS error
 // and `<nil>` values.
 func ConfigureWalUploader(baseUploader internal.Uploader) (uploader *WalUploader, err error) {
+	if err = internal.ConfigureSharedDataKeys(); err != nil {
+		return nil, errors.Wrap(err, "failed to configure shared data keys")
+	}
+
 	useWalDelta, deltaDataFolder, err := configureWalDeltaUsage()
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to configure WAL Delta usage")
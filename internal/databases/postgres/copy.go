@@ -34,7 +34,10 @@ func BackupCopyingInfo(backup Backup, from storage.Folder, to storage.Folder) ([
 		return nil, err
 	}
 
-	var hasBackupPrefix = func(object storage.Object) bool { return strings.HasPrefix(object.GetName(), backupPrefix) }
+	// the data keys are copied as well, the backup objects may be encrypted with them
+	var hasBackupPrefix = func(object storage.Object) bool {
+		return strings.HasPrefix(object.GetName(), backupPrefix) || internal.IsDataKeyObject(object.GetName())
+	}
 	return copy.BuildCopyingInfos(from, to, objects, hasBackupPrefix, copy.NoopRenameFunc), nil
 }
 
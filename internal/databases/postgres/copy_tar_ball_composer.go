@@ -181,6 +181,7 @@ func (c *CopyTarBallComposer) copyTar(tarName string) error {
 			file.status = processed
 			c.tarFileSets.AddFile(newTarName, fileName)
 			c.files.AddFile(file.info.Header, file.info.FileInfo, file.info.IsIncremented)
+			c.tarFilePacker.addManifestFile(fileName, file.info.FileInfo)
 		} else if header, exists := c.headerInfos[fileName]; exists {
 			header.status = processed
 			c.tarFileSets.AddFile(newTarName, fileName)
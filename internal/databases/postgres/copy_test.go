@@ -8,6 +8,7 @@ import (
 	"github.com/apecloud/dataprotection-wal-g/internal/databases/postgres"
 	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
 	"github.com/apecloud/dataprotection-wal-g/testtools"
+	"github.com/apecloud/dataprotection-wal-g/utility"
 	"github.com/stretchr/testify/assert"
 	"github.com/wal-g/tracelog"
 )
@@ -55,6 +56,21 @@ func TestGetBackupCopyingInfo_WhenFolderIsNotEmpty(t *testing.T) {
 	assert.NotEmpty(t, infos)
 }
 
+func TestGetBackupCopyingInfo_CopiesDataKeys(t *testing.T) {
+	var from = testtools.CreateMockStorageFolderWithPermanentBackups(t)
+	var to = testtools.MakeDefaultInMemoryStorageFolder()
+	assert.NoError(t, from.PutObject(utility.DataKeyPath+"0123.key", strings.NewReader("wrapped")))
+	var backup = postgres.NewBackup(from, "base_000000010000000000000002")
+	var infos, err = postgres.BackupCopyingInfo(backup, from, to)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(infos))
+	assert.NoError(t, copy.Infos(infos))
+
+	exists, err := to.Exists(utility.DataKeyPath + "0123.key")
+	assert.NoError(t, err)
+	assert.True(t, exists)
+}
+
 func TestGetHistoryCopyingInfo_WhenFolderIsEmpty(t *testing.T) {
 	var from = testtools.MakeDefaultInMemoryStorageFolder()
 	var to = testtools.MakeDefaultInMemoryStorageFolder()
@@ -0,0 +1,235 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+	"golang.org/x/sync/semaphore"
+)
+
+// archiveTask is the upload of a single WAL file by daemonArchiveQueue
+type archiveTask struct {
+	done chan struct{}
+	err  error
+	// requested is set when PostgreSQL asked to archive the file, the other tasks are read ahead
+	requested bool
+}
+
+func (task *archiveTask) isFinished() bool {
+	select {
+	case <-task.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// daemonArchiveQueue uploads the WAL files of the daemon in parallel. Besides the requested files,
+// it reads ahead the segments PostgreSQL marked .ready in archive_status. A WAL segment is acknowledged
+// only after all the preceding segments in the queue are in storage, so PostgreSQL never considers
+// a segment archived while an older one is not.
+type daemonArchiveQueue struct {
+	uploader *WalUploader
+	walDir   string
+	// readAhead is the maximal number of the read ahead files in the queue
+	readAhead int
+	workers   *semaphore.Weighted
+	// deletePartials is set when the daemon uploads the incomplete segments as .walg.partial
+	deletePartials bool
+
+	mutex sync.Mutex
+	tasks map[string]*archiveTask
+}
+
+func newDaemonArchiveQueue(uploader *WalUploader, walDir string, concurrency int) *daemonArchiveQueue {
+	return &daemonArchiveQueue{
+		uploader:  uploader,
+		walDir:    walDir,
+		readAhead: concurrency,
+		workers:   semaphore.NewWeighted(int64(concurrency)),
+		tasks:     make(map[string]*archiveTask),
+	}
+}
+
+// archive uploads the WAL files and returns when all of them and the WAL segments preceding them
+// in the queue are uploaded
+func (queue *daemonArchiveQueue) archive(walFileNames []string) error {
+	tasks := make([]*archiveTask, 0, len(walFileNames))
+	queue.mutex.Lock()
+	for _, walFileName := range walFileNames {
+		task := queue.enqueue(walFileName)
+		task.requested = true
+		tasks = append(tasks, task)
+	}
+	queue.mutex.Unlock()
+	queue.scanReady(walFileNames[len(walFileNames)-1])
+
+	for i, walFileName := range walFileNames {
+		if err := queue.wait(walFileName, tasks[i]); err != nil {
+			return err
+		}
+		tracelog.InfoLogger.Printf("Successful archiving for %s\n", walFileName)
+	}
+	if queue.uploader.getUseWalDelta() {
+		queue.uploader.FlushFiles()
+	}
+	return nil
+}
+
+// depth returns the number of the WAL files in the queue
+func (queue *daemonArchiveQueue) depth() int {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return len(queue.tasks)
+}
+
+// enqueue starts the upload of the file unless it is in the queue already, the caller holds the mutex
+func (queue *daemonArchiveQueue) enqueue(walFileName string) *archiveTask {
+	task, ok := queue.tasks[walFileName]
+	if ok {
+		return task
+	}
+	task = &archiveTask{done: make(chan struct{})}
+	queue.tasks[walFileName] = task
+	go queue.upload(walFileName, task)
+	return task
+}
+
+// scanReady enqueues the .ready WAL segments following walFileName. The uploaded read ahead segments
+// which are not .ready anymore are dropped, PostgreSQL is not going to request them.
+func (queue *daemonArchiveQueue) scanReady(walFileName string) {
+	if !isWalFilename(walFileName) {
+		return
+	}
+	files, err := os.ReadDir(filepath.Join(queue.walDir, archiveStatusDir))
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to read ahead the WAL segments to archive: %v", err)
+		return
+	}
+	readyNames := make([]string, 0)
+	isReady := make(map[string]bool)
+	for _, file := range files {
+		name, ok := strings.CutSuffix(file.Name(), readySuffix)
+		if ok && isWalFilename(name) {
+			isReady[name] = true
+			if name > walFileName {
+				readyNames = append(readyNames, name)
+			}
+		}
+	}
+	slices.Sort(readyNames)
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	readAhead := 0
+	for name, task := range queue.tasks {
+		if task.requested {
+			continue
+		}
+		if task.isFinished() && !isReady[name] {
+			delete(queue.tasks, name)
+			continue
+		}
+		readAhead++
+	}
+	for _, name := range readyNames {
+		if readAhead >= queue.readAhead {
+			break
+		}
+		if _, ok := queue.tasks[name]; !ok {
+			queue.enqueue(name)
+			readAhead++
+		}
+	}
+}
+
+func (queue *daemonArchiveQueue) upload(walFileName string, task *archiveTask) {
+	_ = queue.workers.Acquire(context.Background(), 1)
+	task.err = queue.pushWalFile(walFileName)
+	queue.workers.Release(1)
+	if task.err != nil {
+		tracelog.ErrorLogger.Printf("Failed to archive %s: %v", walFileName, task.err)
+		// the failed file is uploaded again on the next request
+		queue.mutex.Lock()
+		if queue.tasks[walFileName] == task {
+			delete(queue.tasks, walFileName)
+		}
+		queue.mutex.Unlock()
+	}
+	close(task.done)
+}
+
+func (queue *daemonArchiveQueue) pushWalFile(walFileName string) error {
+	walFilePath := filepath.Join(queue.walDir, walFileName)
+	uploader := queue.uploader.clone()
+	if uploader.ArchiveStatusManager.IsWalAlreadyUploaded(walFilePath) {
+		// uploaded by the background uploader of wal-push
+		err := uploader.ArchiveStatusManager.UnmarkWalFile(walFilePath)
+		if err != nil {
+			tracelog.ErrorLogger.Printf("unmark wal-g status for %s file failed due following error %+v", walFilePath, err)
+		}
+	} else {
+		// .history files must not be overwritten, see https://github.com/apecloud/dataprotection-wal-g/issues/420
+		preventWalOverwrite := viper.GetBool(internal.PreventWalOverwriteSetting) || strings.HasSuffix(walFileName, ".history")
+		err := uploadWALFile(uploader, walFilePath, preventWalOverwrite)
+		if err != nil {
+			return err
+		}
+	}
+	err := uploadLocalWalMetadata(walFilePath, uploader.Uploader)
+	if err != nil {
+		return err
+	}
+	if queue.deletePartials && isWalFilename(walFileName) {
+		err = deletePartialWal(uploader.Folder(), walFileName)
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to delete the partial WAL segment %s: %v", walFileName, err)
+		}
+	}
+	return nil
+}
+
+// wait waits for the upload of the file and the WAL segments preceding it, then removes them from the queue
+func (queue *daemonArchiveQueue) wait(walFileName string, task *archiveTask) error {
+	queue.mutex.Lock()
+	preceding := make(map[string]*archiveTask)
+	if isWalFilename(walFileName) {
+		for name, precedingTask := range queue.tasks {
+			if isWalFilename(name) && name < walFileName {
+				preceding[name] = precedingTask
+			}
+		}
+	}
+	queue.mutex.Unlock()
+
+	<-task.done
+	if task.err != nil {
+		return fmt.Errorf("file archiving failed for %s: %w", walFileName, task.err)
+	}
+	for name, precedingTask := range preceding {
+		<-precedingTask.done
+		if precedingTask.err != nil {
+			return fmt.Errorf("preceding WAL file %s is not archived: %w", name, precedingTask.err)
+		}
+	}
+
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for name := range preceding {
+		if queue.tasks[name] == preceding[name] {
+			delete(queue.tasks, name)
+		}
+	}
+	if queue.tasks[walFileName] == task {
+		delete(queue.tasks, walFileName)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/asm"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/lz4"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/memory"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupTestArchiveQueue creates pg_wal with the segments 1-5 of the timeline 1, the segments 2-5 are .ready
+func setupTestArchiveQueue(t *testing.T, concurrency int) (*daemonArchiveQueue, storage.Folder) {
+	viper.Set(internal.UploadWalMetadata, WalNoMetadataLevel)
+	walDir := filepath.Join(t.TempDir(), "pg_wal")
+	require.NoError(t, os.MkdirAll(filepath.Join(walDir, archiveStatusDir), 0700))
+	for segmentNo := uint64(1); segmentNo <= 5; segmentNo++ {
+		walFileName := FormatWALFileName(1, segmentNo)
+		require.NoError(t, os.WriteFile(filepath.Join(walDir, walFileName), []byte(walFileName), 0600))
+		if segmentNo > 1 {
+			readyPath := filepath.Join(walDir, archiveStatusDir, walFileName+readySuffix)
+			require.NoError(t, os.WriteFile(readyPath, nil, 0600))
+		}
+	}
+
+	folder := memory.NewFolder("in_memory/", memory.NewStorage())
+	uploader := NewWalUploader(internal.NewRegularUploader(compression.Compressors[lz4.AlgorithmName], folder), nil)
+	uploader.ArchiveStatusManager = asm.NewNopASM()
+	queue := newDaemonArchiveQueue(uploader, walDir, concurrency)
+	// the read ahead uploads must not run into the next test
+	t.Cleanup(func() { _ = queue.workers.Acquire(context.Background(), int64(concurrency)) })
+	return queue, folder
+}
+
+func TestDaemonArchiveQueue_ReadAhead(t *testing.T) {
+	queue, folder := setupTestArchiveQueue(t, 2)
+
+	require.NoError(t, queue.archive([]string{"000000010000000000000001"}))
+	exists, err := folder.Exists("000000010000000000000001." + lz4.FileExtension)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	// the next segments are read ahead up to the upload concurrency
+	assert.Equal(t, 2, queue.depth())
+
+	require.NoError(t, queue.archive([]string{"000000010000000000000002", "000000010000000000000003"}))
+	for _, walFileName := range []string{"000000010000000000000002", "000000010000000000000003"} {
+		exists, err = folder.Exists(walFileName + "." + lz4.FileExtension)
+		require.NoError(t, err)
+		assert.True(t, exists, walFileName)
+	}
+	assert.Equal(t, 2, queue.depth())
+
+	require.NoError(t, queue.archive([]string{"000000010000000000000005"}))
+	assert.Equal(t, 0, queue.depth())
+}
+
+func TestDaemonArchiveQueue_DropsStaleReadAhead(t *testing.T) {
+	queue, _ := setupTestArchiveQueue(t, 2)
+
+	require.NoError(t, queue.archive([]string{"000000010000000000000001"}))
+	readAheadNames := []string{"000000010000000000000002", "000000010000000000000003"}
+	for _, walFileName := range readAheadNames {
+		queue.mutex.Lock()
+		task := queue.tasks[walFileName]
+		queue.mutex.Unlock()
+		require.NotNil(t, task, walFileName)
+		<-task.done
+		// PostgreSQL is not going to request the segment anymore
+		require.NoError(t, os.Remove(filepath.Join(queue.walDir, archiveStatusDir, walFileName+readySuffix)))
+	}
+
+	require.NoError(t, queue.archive([]string{"000000010000000000000001"}))
+	queue.mutex.Lock()
+	queuedNames := make([]string, 0, len(queue.tasks))
+	for name := range queue.tasks {
+		queuedNames = append(queuedNames, name)
+	}
+	queue.mutex.Unlock()
+	assert.ElementsMatch(t, []string{"000000010000000000000004", "000000010000000000000005"}, queuedNames)
+}
+
+func TestDaemonArchiveQueue_DeletesPartial(t *testing.T) {
+	queue, folder := setupTestArchiveQueue(t, 1)
+	queue.deletePartials = true
+	partialName := "000000010000000000000001" + WalgPartialWalSuffix + "." + lz4.FileExtension
+	putCompressedWalFile(t, folder, "000000010000000000000001"+WalgPartialWalSuffix, []byte("partial"))
+
+	require.NoError(t, queue.archive([]string{"000000010000000000000001"}))
+	exists, err := folder.Exists(partialName)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestDaemonArchiveQueue_Failed(t *testing.T) {
+	queue, _ := setupTestArchiveQueue(t, 1)
+
+	err := queue.archive([]string{"000000010000000000000001", "000000010000000000000007"})
+	assert.ErrorContains(t, err, "file archiving failed for 000000010000000000000007")
+	// the failed file is not left in the queue to be retried
+	queue.mutex.Lock()
+	_, ok := queue.tasks["000000010000000000000007"]
+	queue.mutex.Unlock()
+	assert.False(t, ok)
+}
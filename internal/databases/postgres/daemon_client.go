@@ -0,0 +1,156 @@
+package postgres
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/wal-g/tracelog"
+)
+
+// DaemonClient sends the requests to the daemon through its socket
+type DaemonClient struct {
+	conn net.Conn
+}
+
+func NewDaemonClient(pathToSocket string) (*DaemonClient, error) {
+	conn, err := net.Dial("unix", pathToSocket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the daemon: %w", err)
+	}
+	return &DaemonClient{conn: conn}, nil
+}
+
+func (client *DaemonClient) Close() error {
+	return client.conn.Close()
+}
+
+// Check checks that the daemon is running and accepts the requests
+func (client *DaemonClient) Check() error {
+	return client.request(CheckType, []byte("CHECK"))
+}
+
+// Push archives the WAL files from $PGDATA/pg_wal of the daemon, the paths are accepted as well
+func (client *DaemonClient) Push(walFilePaths ...string) error {
+	walFileNames := make([]string, 0, len(walFilePaths))
+	for _, walFilePath := range walFilePaths {
+		walFileNames = append(walFileNames, filepath.Base(walFilePath))
+	}
+	if len(walFileNames) == 1 {
+		return client.request(FileNameType, []byte(walFileNames[0]))
+	}
+	return client.request(BatchFileNameType, []byte(strings.Join(walFileNames, MessageFieldSeparator)))
+}
+
+// Fetch fetches the WAL file to the location, it returns false if the file is not in storage
+func (client *DaemonClient) Fetch(walFileName string, location string) (bool, error) {
+	// the daemon runs in another working directory
+	location, err := filepath.Abs(location)
+	if err != nil {
+		return false, err
+	}
+	err = WriteSocketMessage(client.conn, WalFetchType, []byte(walFileName+MessageFieldSeparator+location))
+	if err != nil {
+		return false, err
+	}
+	reply, err := client.readReply()
+	if err != nil {
+		return false, err
+	}
+	switch reply {
+	case OkType:
+		return true, nil
+	case NotFoundType:
+		return false, nil
+	default:
+		return false, fmt.Errorf("daemon failed to fetch %s", walFileName)
+	}
+}
+
+// Status requests the daemon status report
+func (client *DaemonClient) Status() (DaemonReport, error) {
+	var report DaemonReport
+	err := WriteSocketMessage(client.conn, StatusType, nil)
+	if err != nil {
+		return report, err
+	}
+	messageType, messageBody, err := NewMessageReader(client.conn).Next()
+	if err != nil {
+		return report, err
+	}
+	if messageType != StatusType {
+		return report, fmt.Errorf("unexpected reply to the status request: %s", string(messageType))
+	}
+	err = json.Unmarshal(messageBody, &report)
+	return report, err
+}
+
+func (client *DaemonClient) request(messageType SocketMessageType, messageBody []byte) error {
+	err := WriteSocketMessage(client.conn, messageType, messageBody)
+	if err != nil {
+		return err
+	}
+	reply, err := client.readReply()
+	if err != nil {
+		return err
+	}
+	if reply != OkType {
+		return fmt.Errorf("daemon failed to handle the request, see the daemon log for details")
+	}
+	return nil
+}
+
+func (client *DaemonClient) readReply() (SocketMessageType, error) {
+	reply := make([]byte, 1)
+	if _, err := io.ReadFull(client.conn, reply); err != nil {
+		return ErrorType, fmt.Errorf("failed to read the daemon reply: %w", err)
+	}
+	return SocketMessageType(reply[0]), nil
+}
+
+// HandleDaemonClientCheck is invoked to perform wal-g daemon-client check
+func HandleDaemonClientCheck(pathToSocket string) {
+	client := connectDaemonClient(pathToSocket)
+	defer utility.LoggedClose(client, "")
+	tracelog.ErrorLogger.FatalOnError(client.Check())
+}
+
+// HandleDaemonClientWALPush is invoked to perform wal-g daemon-client wal-push
+func HandleDaemonClientWALPush(pathToSocket string, walFilePaths []string) {
+	client := connectDaemonClient(pathToSocket)
+	defer utility.LoggedClose(client, "")
+	tracelog.ErrorLogger.FatalOnError(client.Push(walFilePaths...))
+}
+
+// HandleDaemonClientWALFetch is invoked to perform wal-g daemon-client wal-fetch,
+// it exits with the same code as wal-fetch when the WAL file is not in storage
+func HandleDaemonClientWALFetch(pathToSocket string, walFileName string, location string) {
+	client := connectDaemonClient(pathToSocket)
+	found, err := client.Fetch(walFileName, location)
+	utility.LoggedClose(client, "")
+	tracelog.ErrorLogger.FatalOnError(err)
+	if !found {
+		tracelog.ErrorLogger.Printf("Archive '%s' does not exist.\n", walFileName)
+		os.Exit(exIoError)
+	}
+}
+
+// HandleDaemonClientStatus is invoked to perform wal-g daemon-client status
+func HandleDaemonClientStatus(pathToSocket string, output io.Writer) {
+	client := connectDaemonClient(pathToSocket)
+	defer utility.LoggedClose(client, "")
+	report, err := client.Status()
+	tracelog.ErrorLogger.FatalOnError(err)
+	tracelog.ErrorLogger.FatalOnError(json.NewEncoder(output).Encode(report))
+}
+
+func connectDaemonClient(pathToSocket string) *DaemonClient {
+	client, err := NewDaemonClient(pathToSocket)
+	tracelog.ErrorLogger.FatalOnError(err)
+	return client
+}
@@ -2,15 +2,23 @@ package postgres
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"os"
 	"path"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/fsutil"
 	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 )
 
@@ -23,6 +31,19 @@ const (
 	FileNameType SocketMessageType = 'F'
 	OkType       SocketMessageType = 'O'
 	ErrorType    SocketMessageType = 'E'
+
+	// BatchFileNameType body is the names of WAL files to archive separated by MessageFieldSeparator
+	BatchFileNameType SocketMessageType = 'B'
+	// WalFetchType body is the WAL file name and the destination path separated by MessageFieldSeparator
+	WalFetchType SocketMessageType = 'W'
+	// NotFoundType is the reply to WalFetchType when the WAL file is not in storage
+	NotFoundType SocketMessageType = 'N'
+	// StatusType is replied with a message of the same type with DaemonReport in JSON
+	StatusType SocketMessageType = 'S'
+
+	MessageFieldSeparator = "\x00"
+
+	messageHeaderLength = 3
 )
 
 func (msg SocketMessageType) ToBytes() []byte {
@@ -36,7 +57,7 @@ type SocketMessageHandler interface {
 type CheckMessageHandler struct {
 	messageType SocketMessageType
 	fd          net.Conn
-	uploader    *WalUploader
+	daemon      *Daemon
 }
 
 func (h *CheckMessageHandler) Handle(messageBody []byte) error {
@@ -51,21 +72,17 @@ func (h *CheckMessageHandler) Handle(messageBody []byte) error {
 type ArchiveMessageHandler struct {
 	messageType SocketMessageType
 	fd          net.Conn
-	uploader    *WalUploader
+	daemon      *Daemon
 }
 
 func (h *ArchiveMessageHandler) Handle(messageBody []byte) error {
-	tracelog.InfoLogger.Printf("wal file name: %s\n", string(messageBody))
-	PgDataSettingString, ok := internal.GetSetting(internal.PgDataSetting)
-	if !ok {
-		return fmt.Errorf("PGDATA is not set in the conf")
+	walFileNames := []string{string(messageBody)}
+	if h.messageType == BatchFileNameType {
+		walFileNames = strings.Split(string(messageBody), MessageFieldSeparator)
 	}
-	pathToWal := path.Join(PgDataSettingString, "pg_wal")
-	fullPath := path.Join(pathToWal, string(messageBody))
-	tracelog.InfoLogger.Printf("starting wal-push for %s\n", fullPath)
-	err := HandleWALPush(h.uploader, fullPath)
+	err := h.daemon.archive(walFileNames)
 	if err != nil {
-		return fmt.Errorf("file archiving failed: %w", err)
+		return err
 	}
 	_, err = h.fd.Write(OkType.ToBytes())
 	if err != nil {
@@ -74,17 +91,78 @@ func (h *ArchiveMessageHandler) Handle(messageBody []byte) error {
 	return nil
 }
 
-func NewMessageHandler(messageType SocketMessageType, c net.Conn, uploader *WalUploader) SocketMessageHandler {
+type WalFetchMessageHandler struct {
+	messageType SocketMessageType
+	fd          net.Conn
+	daemon      *Daemon
+}
+
+func (h *WalFetchMessageHandler) Handle(messageBody []byte) error {
+	walFileName, location, ok := strings.Cut(string(messageBody), MessageFieldSeparator)
+	if !ok || walFileName == "" || location == "" {
+		return fmt.Errorf("WAL file name and destination are expected, got '%s'", string(messageBody))
+	}
+	reply := OkType
+	err := h.daemon.fetch(walFileName, location)
+	if _, isArchNonExistErr := err.(internal.ArchiveNonExistenceError); isArchNonExistErr {
+		tracelog.WarningLogger.Print(err.Error())
+		reply = NotFoundType
+	} else if err != nil {
+		return fmt.Errorf("WAL fetch failed: %w", err)
+	}
+	_, err = h.fd.Write(reply.ToBytes())
+	if err != nil {
+		return fmt.Errorf("socket write failed: %w", err)
+	}
+	return nil
+}
+
+type StatusMessageHandler struct {
+	messageType SocketMessageType
+	fd          net.Conn
+	daemon      *Daemon
+}
+
+func (h *StatusMessageHandler) Handle(messageBody []byte) error {
+	report, err := json.Marshal(h.daemon.Report())
+	if err != nil {
+		return err
+	}
+	return WriteSocketMessage(h.fd, StatusType, report)
+}
+
+func NewMessageHandler(messageType SocketMessageType, c net.Conn, daemon *Daemon) SocketMessageHandler {
 	switch messageType {
 	case CheckType:
-		return &CheckMessageHandler{CheckType, c, uploader}
+		return &CheckMessageHandler{CheckType, c, daemon}
 	case FileNameType:
-		return &ArchiveMessageHandler{FileNameType, c, uploader}
+		return &ArchiveMessageHandler{FileNameType, c, daemon}
+	case BatchFileNameType:
+		return &ArchiveMessageHandler{BatchFileNameType, c, daemon}
+	case WalFetchType:
+		return &WalFetchMessageHandler{WalFetchType, c, daemon}
+	case StatusType:
+		return &StatusMessageHandler{StatusType, c, daemon}
 	default:
 		return nil
 	}
 }
 
+// WriteSocketMessage writes the message with the header of its type and length
+func WriteSocketMessage(w io.Writer, messageType SocketMessageType, messageBody []byte) error {
+	if len(messageBody) > math.MaxUint16-messageHeaderLength {
+		return fmt.Errorf("message body of %d bytes is too long", len(messageBody))
+	}
+	message := make([]byte, messageHeaderLength, messageHeaderLength+len(messageBody))
+	message[0] = byte(messageType)
+	binary.BigEndian.PutUint16(message[1:messageHeaderLength], uint16(messageHeaderLength+len(messageBody)))
+	message = append(message, messageBody...)
+	if _, err := w.Write(message); err != nil {
+		return fmt.Errorf("socket write failed: %w", err)
+	}
+	return nil
+}
+
 type SocketMessageReader struct {
 	c net.Conn
 }
@@ -95,7 +173,7 @@ func NewMessageReader(c net.Conn) *SocketMessageReader {
 
 // Next method reads messages sequentially from the Reader
 func (r SocketMessageReader) Next() (messageType SocketMessageType, messageBody []byte, err error) {
-	messageParameters := make([]byte, 3)
+	messageParameters := make([]byte, messageHeaderLength)
 	_, err = io.ReadFull(r.c, messageParameters)
 	if err != nil {
 		return ErrorType, nil, fmt.Errorf("failed to read params: %w", err)
@@ -107,7 +185,10 @@ func (r SocketMessageReader) Next() (messageType SocketMessageType, messageBody
 	if err != nil {
 		return ErrorType, nil, fmt.Errorf("fail to read message len: %w", err)
 	}
-	messageBody = make([]byte, messageLength-3)
+	if messageLength < messageHeaderLength {
+		return ErrorType, nil, fmt.Errorf("invalid message len: %d", messageLength)
+	}
+	messageBody = make([]byte, messageLength-messageHeaderLength)
 	_, err = io.ReadFull(r.c, messageBody)
 	if err != nil {
 		return ErrorType, nil, fmt.Errorf("failed to read msg body: %w", err)
@@ -115,8 +196,137 @@ func (r SocketMessageReader) Next() (messageType SocketMessageType, messageBody
 	return messageType, messageBody, err
 }
 
-// HandleDaemon is invoked to perform daemon mode
-func HandleDaemon(uploader *WalUploader, pathToSocket string) {
+// DaemonReport defines the daemon status reply
+type DaemonReport struct {
+	// QueueDepth is the number of WAL files in the archive queue, including the read ahead ones
+	QueueDepth       int       `json:"queue_depth"`
+	LastArchivedWal  string    `json:"last_archived_wal,omitempty"`
+	LastArchivedTime time.Time `json:"last_archived_time"`
+	LastFetchedWal   string    `json:"last_fetched_wal,omitempty"`
+	Prefetching      int       `json:"prefetching"`
+}
+
+// Daemon holds the state shared by the connections to the daemon
+type Daemon struct {
+	// archiveQueue is nil if PGDATA is not set
+	archiveQueue *daemonArchiveQueue
+	reader       internal.StorageFolderReader
+
+	sync.Mutex
+	lastArchivedWal  string
+	lastArchivedTime time.Time
+	lastFetchedWal   string
+	// prefetching are the WAL files being prefetched, so the consecutive fetches do not download them twice
+	prefetching map[string]bool
+}
+
+func newDaemon(archiveQueue *daemonArchiveQueue, reader internal.StorageFolderReader) *Daemon {
+	return &Daemon{archiveQueue: archiveQueue, reader: reader, prefetching: make(map[string]bool)}
+}
+
+// archive pushes the WAL files from $PGDATA/pg_wal through the archive queue
+func (daemon *Daemon) archive(walFileNames []string) error {
+	if daemon.archiveQueue == nil {
+		return fmt.Errorf("PGDATA is not set in the conf")
+	}
+	tracelog.InfoLogger.Printf("starting wal-push for %s\n", strings.Join(walFileNames, ", "))
+	err := daemon.archiveQueue.archive(walFileNames)
+	if err != nil {
+		return err
+	}
+	daemon.Lock()
+	daemon.lastArchivedWal = walFileNames[len(walFileNames)-1]
+	daemon.lastArchivedTime = time.Now()
+	daemon.Unlock()
+	return nil
+}
+
+// fetch does the same as wal-fetch, but the next WAL files are prefetched by the daemon itself
+func (daemon *Daemon) fetch(walFileName string, location string) error {
+	tracelog.DebugLogger.Printf("daemon fetch(%s, %s)\n", walFileName, location)
+	location = utility.ResolveSymlink(location)
+	err := fetchWalFile(daemon.reader.SubFolder(utility.WalPath), walFileName, location)
+	if err != nil {
+		return err
+	}
+	daemon.Lock()
+	daemon.lastFetchedWal = walFileName
+	daemon.Unlock()
+
+	prefetchLocation := location
+	if viper.IsSet(internal.PrefetchDir) {
+		prefetchLocation = viper.GetString(internal.PrefetchDir)
+	}
+	daemon.prefetch(walFileName, path.Dir(prefetchLocation))
+	return nil
+}
+
+// prefetch downloads the WAL files following walFileName in the background
+func (daemon *Daemon) prefetch(walFileName string, location string) {
+	concurrency, err := internal.GetMaxDownloadConcurrency()
+	if err != nil {
+		tracelog.ErrorLogger.Println("WAL-prefetch failed: ", err)
+		return
+	}
+	if !isWalFilename(walFileName) || concurrency == 1 {
+		return // There will be nothing to prefetch anyway
+	}
+
+	walFolder := daemon.reader.SubFolder(utility.WalPath)
+	waitGroup := &sync.WaitGroup{}
+	fileName := walFileName
+	for i := 0; i < concurrency; i++ {
+		fileName, err = GetNextWalFilename(fileName)
+		if err != nil {
+			tracelog.ErrorLogger.Println("WAL-prefetch failed: ", err, " file: ", fileName)
+			break
+		}
+		daemon.Lock()
+		alreadyPrefetching := daemon.prefetching[fileName]
+		daemon.prefetching[fileName] = true
+		daemon.Unlock()
+		if alreadyPrefetching {
+			continue
+		}
+
+		waitGroup.Add(1)
+		go func(fileName string) {
+			defer func() {
+				daemon.Lock()
+				delete(daemon.prefetching, fileName)
+				daemon.Unlock()
+			}()
+			prefetchFile(location, walFolder, fileName, waitGroup)
+		}(fileName)
+	}
+
+	go func() {
+		waitGroup.Wait()
+		CleanupPrefetchDirectories(walFileName, location, fsutil.FileSystemCleaner{})
+	}()
+}
+
+// Report returns the current daemon status
+func (daemon *Daemon) Report() DaemonReport {
+	queueDepth := 0
+	if daemon.archiveQueue != nil {
+		queueDepth = daemon.archiveQueue.depth()
+	}
+	daemon.Lock()
+	defer daemon.Unlock()
+	return DaemonReport{
+		QueueDepth:       queueDepth,
+		LastArchivedWal:  daemon.lastArchivedWal,
+		LastArchivedTime: daemon.lastArchivedTime,
+		LastFetchedWal:   daemon.lastFetchedWal,
+		Prefetching:      len(daemon.prefetching),
+	}
+}
+
+// HandleDaemon is invoked to perform daemon mode, the WAL segment being written
+// is uploaded as .partial if the partial WAL upload is enabled
+func HandleDaemon(uploader *WalUploader, reader internal.StorageFolderReader, pathToSocket string,
+	partialWal PartialWalConfig) {
 	if _, err := os.Stat(pathToSocket); err == nil {
 		err = os.Remove(pathToSocket)
 		if err != nil {
@@ -127,6 +337,21 @@ func HandleDaemon(uploader *WalUploader, pathToSocket string) {
 	if err != nil {
 		tracelog.ErrorLogger.Fatal("Error on listening socket:", err)
 	}
+	var archiveQueue *daemonArchiveQueue
+	pgData, ok := internal.GetSetting(internal.PgDataSetting)
+	if ok {
+		concurrency, err := internal.GetMaxUploadConcurrency()
+		tracelog.ErrorLogger.FatalOnError(err)
+		archiveQueue = newDaemonArchiveQueue(uploader, path.Join(pgData, "pg_wal"), concurrency)
+	}
+	if partialWal.Enabled() {
+		if !ok {
+			tracelog.ErrorLogger.Fatal("PGDATA is not set in the conf, it is required to upload the partial WAL segments")
+		}
+		archiveQueue.deletePartials = true
+		go newPartialWalUploader(uploader, partialWal, path.Join(pgData, "pg_wal")).run(context.Background())
+	}
+	daemon := newDaemon(archiveQueue, reader)
 	for {
 		err = SdNotify(SdNotifyWatchdog)
 		tracelog.ErrorLogger.PrintOnError(err)
@@ -134,12 +359,13 @@ func HandleDaemon(uploader *WalUploader, pathToSocket string) {
 		if err != nil {
 			tracelog.ErrorLogger.Fatal("Failed to accept, err:", err)
 		}
-		go Listen(fd, uploader)
+		go Listen(fd, daemon)
 	}
 }
 
-// Listen is used for listening connection and processing messages
-func Listen(c net.Conn, uploader *WalUploader) {
+// Listen is used for listening connection and processing messages,
+// the connection is closed after the first message other than the check
+func Listen(c net.Conn, daemon *Daemon) {
 	defer utility.LoggedClose(c, fmt.Sprintf("Failed to close connection with %s \n", c.RemoteAddr()))
 	messageReader := NewMessageReader(c)
 	for {
@@ -150,7 +376,7 @@ func Listen(c net.Conn, uploader *WalUploader) {
 			tracelog.ErrorLogger.PrintOnError(err)
 			return
 		}
-		messageHandler := NewMessageHandler(messageType, c, uploader)
+		messageHandler := NewMessageHandler(messageType, c, daemon)
 		if messageHandler == nil {
 			tracelog.ErrorLogger.Printf("Unexpected message type: %s", string(messageType))
 			_, err = c.Write(ErrorType.ToBytes())
@@ -164,8 +390,7 @@ func Listen(c net.Conn, uploader *WalUploader) {
 			tracelog.ErrorLogger.PrintOnError(err)
 			return
 		}
-		if messageType == FileNameType {
-			tracelog.InfoLogger.Printf("Successful archiving for %s\n", string(messageBody))
+		if messageType != CheckType {
 			return
 		}
 	}
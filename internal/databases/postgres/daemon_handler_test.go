@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/memory"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDaemonClient(daemon *Daemon) *DaemonClient {
+	clientConn, daemonConn := net.Pipe()
+	go Listen(daemonConn, daemon)
+	return &DaemonClient{conn: clientConn}
+}
+
+func TestDaemon_CheckAndStatus(t *testing.T) {
+	daemon := newDaemon(nil, nil)
+	daemon.lastArchivedWal = "000000010000000000000003"
+
+	client := newTestDaemonClient(daemon)
+	defer client.Close()
+	require.NoError(t, client.Check())
+	// the connection is kept open after the check
+	report, err := client.Status()
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.QueueDepth)
+	assert.Equal(t, "000000010000000000000003", report.LastArchivedWal)
+}
+
+func TestDaemon_Fetch(t *testing.T) {
+	rootFolder := memory.NewFolder("in_memory/", memory.NewStorage())
+	putCompressedWalFile(t, rootFolder.GetSubFolder(utility.WalPath), "00000002.history", []byte("history"))
+	daemon := newDaemon(nil, internal.NewFolderReader(rootFolder))
+	dir := t.TempDir()
+
+	client := newTestDaemonClient(daemon)
+	found, err := client.Fetch("00000002.history", filepath.Join(dir, "RECOVERYHISTORY"))
+	require.NoError(t, err)
+	assert.True(t, found)
+	_ = client.Close()
+	content, err := os.ReadFile(filepath.Join(dir, "RECOVERYHISTORY"))
+	require.NoError(t, err)
+	assert.Equal(t, "history", string(content))
+	assert.Equal(t, "00000002.history", daemon.Report().LastFetchedWal)
+
+	client = newTestDaemonClient(daemon)
+	found, err = client.Fetch("000000020000000000000005", filepath.Join(dir, "RECOVERYXLOG"))
+	require.NoError(t, err)
+	assert.False(t, found)
+	_ = client.Close()
+
+	client = newTestDaemonClient(daemon)
+	_, err = client.Fetch("00000002.history", filepath.Join(dir, "RECOVERYHISTORY"))
+	assert.Error(t, err, "the destination exists")
+	_ = client.Close()
+}
+
+func TestWriteSocketMessage(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteSocketMessage(&buf, FileNameType, []byte("000000010000000000000001")))
+	assert.Equal(t, append([]byte{'F', 0, 0x1B}, "000000010000000000000001"...), buf.Bytes())
+
+	assert.Error(t, WriteSocketMessage(&buf, BatchFileNameType, make([]byte, 1<<16)))
+}
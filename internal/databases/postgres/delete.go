@@ -54,7 +54,11 @@ func NewDeleteHandler(folder storage.Folder, permanentBackups, permanentWals map
 				postgresBackups,
 				lessFunc,
 				internal.IsPermanentFunc(
-					makePermanentFunc(permanentBackups, permanentWals))),
+					makePermanentFunc(permanentBackups, permanentWals)),
+				// the logical backups are deleted by their own handler, see NewLogicalDeleteHandler
+				internal.ObjectScopeFunc(func(object storage.Object) bool {
+					return !strings.HasPrefix(object.GetName(), LogicalBackupPath)
+				})),
 		}
 
 	return deleteHandler, nil
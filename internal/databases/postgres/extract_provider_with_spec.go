@@ -1,11 +1,13 @@
 package postgres
 
 import (
+	"os"
 	"path"
 	"strconv"
 	"strings"
 
 	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
 )
 
@@ -34,9 +36,14 @@ func (p ExtractProviderDBSpec) Get(
 	_, filesMeta, err := backup.GetSentinelAndFilesMetadata()
 	tracelog.ErrorLogger.FatalOnError(err)
 
-	fullRestoreDatabases, err := p.makeFullRestoreDatabaseMap(p.onlyDatabases, filesMeta.DatabasesByNames)
+	databases, tables := p.splitDatabasesAndTables()
+	fullRestoreDatabases, err := p.makeFullRestoreDatabaseMap(databases, filesMeta.DatabasesByNames)
 	tracelog.ErrorLogger.FatalOnError(err)
-	p.filterFilesToUnwrap(filesToUnwrap, fullRestoreDatabases)
+	names, err := p.resolveTablesFromCatalog(backup, tables, filesMeta.DatabasesByNames, fullRestoreDatabases, filesToUnwrap)
+	tracelog.ErrorLogger.FatalOnError(err)
+	skippedRelations, err := p.makeSkippedRelationsMap(tables, names, fullRestoreDatabases)
+	tracelog.ErrorLogger.FatalOnError(err)
+	p.filterFilesToUnwrap(filesToUnwrap, fullRestoreDatabases, skippedRelations)
 
 	return p.ExtractProviderImpl.Get(backup, filesToUnwrap, skipRedundantTars, dbDataDir, createNewIncrementalFiles)
 }
@@ -55,6 +62,127 @@ func (p ExtractProviderDBSpec) makeFullRestoreDatabaseMap(databases []string, na
 	return restoredDatabases, nil
 }
 
+// splitDatabasesAndTables separates the database/schema.table names of the tables from the database names
+func (p ExtractProviderDBSpec) splitDatabasesAndTables() (databases []string, tables []string) {
+	for _, name := range p.onlyDatabases {
+		if strings.Contains(name, "/") {
+			tables = append(tables, name)
+		} else {
+			databases = append(databases, name)
+		}
+	}
+	return databases, tables
+}
+
+// resolveTablesFromCatalog fills in the tables of the databases missing them in the backup metadata from the
+// system catalog of the database in the backup, see readCatalogTables
+func (p ExtractProviderDBSpec) resolveTablesFromCatalog(backup Backup, tables []string, names DatabasesByNames,
+	fullRestoreDatabases map[int]bool, filesToUnwrap map[string]bool) (DatabasesByNames, error) {
+	resolved := make(DatabasesByNames, len(names))
+	for name, info := range names {
+		resolved[name] = info
+	}
+	for _, table := range tables {
+		database, _, _ := strings.Cut(table, "/")
+		info, ok := resolved[database]
+		if !ok || info.Tables != nil || fullRestoreDatabases[info.Oid] {
+			continue
+		}
+		tracelog.InfoLogger.Printf("The tables of the database '%s' are not in the backup metadata, "+
+			"reading them from the system catalog of the backup\n", database)
+		catalogTables, err := p.readCatalogTables(backup, info.Oid, filesToUnwrap)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read the tables of the database '%s' from the backup, "+
+				"enable %s to record them on backup-push", database, internal.PgBackupTablesMetadata)
+		}
+		info.Tables = catalogTables
+		resolved[database] = info
+	}
+	return resolved, nil
+}
+
+// readCatalogTables restores the system catalog files of the database to a temporary directory
+// and reads the relfilenodes of the tables from them
+func (p ExtractProviderDBSpec) readCatalogTables(backup Backup, dbID int,
+	filesToUnwrap map[string]bool) (map[string][]int, error) {
+	catalogDirectory, err := os.MkdirTemp("", "walg_catalog")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(catalogDirectory)
+
+	dbPrefix := defaultTbspPrefix + strconv.Itoa(dbID) + "/"
+	extract := func(names ...string) error {
+		wanted := make(map[string]bool, len(names))
+		for _, name := range names {
+			wanted[name] = true
+		}
+		catalogFiles := make(map[string]bool)
+		for file := range filesToUnwrap {
+			// the segments of a relation are named <relfilenode>.<segment number>
+			name := strings.TrimPrefix(file, dbPrefix)
+			relation, _, _ := strings.Cut(name, ".")
+			if !strings.HasPrefix(file, dbPrefix) || !(wanted[name] || wanted[relation]) {
+				continue
+			}
+			if description := backup.FilesMetadataDto.Files[file]; description.IsIncremented || description.IsSkipped {
+				return errors.Errorf("the catalog file '%s' is an increment", file)
+			}
+			catalogFiles[file] = true
+		}
+		if len(catalogFiles) == 0 {
+			return nil
+		}
+		interpreter, tarsToExtract, _, err := p.ExtractProviderImpl.Get(backup, catalogFiles, true, catalogDirectory, false)
+		if err != nil || len(tarsToExtract) == 0 {
+			return err
+		}
+		return internal.ExtractAll(interpreter, tarsToExtract)
+	}
+	return readCatalogTables(path.Join(catalogDirectory, dbPrefix), backup.SentinelDto.PgVersion, extract)
+}
+
+// makeSkippedRelationsMap returns the relfilenodes of the tables not to restore in each database
+// where only some tables are restored. The relfilenodes not recorded in the metadata, like the ones
+// of the system catalog, are always restored.
+func (p ExtractProviderDBSpec) makeSkippedRelationsMap(tables []string, names DatabasesByNames,
+	fullRestoreDatabases map[int]bool) (map[int]map[int]bool, error) {
+	restoredRelations := make(map[int]map[int]bool)
+	for _, table := range tables {
+		database, _, _ := strings.Cut(table, "/")
+		if dbID, err := names.Resolve(database); err == nil && fullRestoreDatabases[dbID] {
+			continue
+		}
+		dbID, relFileNodes, err := names.ResolveTable(table)
+		if err != nil {
+			return nil, err
+		}
+		if restoredRelations[dbID] == nil {
+			restoredRelations[dbID] = make(map[int]bool)
+		}
+		for _, relFileNode := range relFileNodes {
+			restoredRelations[dbID][relFileNode] = true
+		}
+	}
+
+	skippedRelations := make(map[int]map[int]bool)
+	for _, info := range names {
+		restored, ok := restoredRelations[info.Oid]
+		if !ok {
+			continue
+		}
+		skippedRelations[info.Oid] = make(map[int]bool)
+		for _, relFileNodes := range info.Tables {
+			for _, relFileNode := range relFileNodes {
+				if !restored[relFileNode] {
+					skippedRelations[info.Oid][relFileNode] = true
+				}
+			}
+		}
+	}
+	return skippedRelations, nil
+}
+
 func (p ExtractProviderDBSpec) makeSystemDatabasesMap() map[int]bool {
 	restoredDatabases := make(map[int]bool)
 	for i := 0; i < systemIDLimit; i++ {
@@ -63,11 +191,16 @@ func (p ExtractProviderDBSpec) makeSystemDatabasesMap() map[int]bool {
 	return restoredDatabases
 }
 
-func (p ExtractProviderDBSpec) filterFilesToUnwrap(filesToUnwrap map[string]bool, databases map[int]bool) {
+func (p ExtractProviderDBSpec) filterFilesToUnwrap(filesToUnwrap map[string]bool, databases map[int]bool,
+	skippedRelations map[int]map[int]bool) {
 	for file := range filesToUnwrap {
-		isDB, dbID, _ := p.TryGetOidPair(file)
+		isDB, dbID, tableID := p.TryGetOidPair(file)
+		if !isDB || databases[dbID] {
+			continue
+		}
 
-		if isDB && !databases[dbID] {
+		skipped, isPartial := skippedRelations[dbID]
+		if !isPartial || skipped[tableID] {
 			delete(filesToUnwrap, file)
 		}
 	}
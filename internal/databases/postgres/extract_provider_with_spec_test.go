@@ -4,7 +4,9 @@ import (
 	"testing"
 
 	"github.com/apecloud/dataprotection-wal-g/internal/databases/postgres"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/memory"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTryGetOidPair_DefaultFile(t *testing.T) {
@@ -78,3 +80,74 @@ func TestTryGetOidPair_BaseRoot(t *testing.T) {
 	assert.Equal(t, 0, dbID)
 	assert.Equal(t, 0, tableID)
 }
+
+func TestExtractProviderDBSpec_RestoreOnlyTable(t *testing.T) {
+	backup := postgres.NewBackup(memory.NewFolder("in_memory/", memory.NewStorage()), "base_000000010000000000000002")
+	backup.SentinelDto = &postgres.BackupSentinelDto{}
+	backup.FilesMetadataDto = &postgres.FilesMetadataDto{DatabasesByNames: postgres.DatabasesByNames{
+		"postgres": {Oid: 5},
+		"shop": {Oid: 16390, Tables: map[string][]int{
+			"public.orders":    {16400, 16403, 16405, 16406},
+			"sales.customers":  {16410, 16412},
+			"public.inventory": {16420},
+		}},
+		"analytics": {Oid: 16391},
+	}}
+	filesToUnwrap := map[string]bool{
+		"/global/pg_control":             true,
+		"/base/5/16384":                  true,
+		"/base/16390/PG_VERSION":         true,
+		"/base/16390/1259":               true,
+		"/base/16390/16400":              true,
+		"/base/16390/16400.1":            true,
+		"/base/16390/16400_vm":           true,
+		"/base/16390/16403":              true,
+		"/base/16390/16410":              true,
+		"/base/16390/16412":              true,
+		"/base/16390/16420_fsm":          true,
+		"/base/16390/16430":              true,
+		"/base/16391/16384":              true,
+		"/pg_tblspc/16500/v/16390/16405": true,
+	}
+
+	p := postgres.NewExtractProviderDBSpec([]string{"shop/orders", "shop/sales.customers"})
+	_, _, _, err := p.Get(backup, filesToUnwrap, true, "", false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{
+		"/global/pg_control":             true,
+		"/base/5/16384":                  true,
+		"/base/16390/PG_VERSION":         true,
+		"/base/16390/1259":               true,
+		"/base/16390/16400":              true,
+		"/base/16390/16400.1":            true,
+		"/base/16390/16400_vm":           true,
+		"/base/16390/16403":              true,
+		"/base/16390/16410":              true,
+		"/base/16390/16412":              true,
+		"/pg_tblspc/16500/v/16390/16405": true,
+		// the relations not recorded in the metadata are restored
+		"/base/16390/16430": true,
+	}, filesToUnwrap)
+}
+
+func TestDatabasesByNames_ResolveTable(t *testing.T) {
+	names := postgres.DatabasesByNames{
+		"shop": {Oid: 16390, Tables: map[string][]int{"public.orders": {16400, 16403}}},
+		"old":  {Oid: 16391},
+	}
+	dbID, relFileNodes, err := names.ResolveTable("shop/public.orders")
+	require.NoError(t, err)
+	assert.Equal(t, 16390, dbID)
+	assert.Equal(t, []int{16400, 16403}, relFileNodes)
+
+	_, relFileNodes, err = names.ResolveTable("shop/orders")
+	require.NoError(t, err)
+	assert.Equal(t, []int{16400, 16403}, relFileNodes)
+
+	_, _, err = names.ResolveTable("shop/customers")
+	assert.Error(t, err)
+	_, _, err = names.ResolveTable("old/orders")
+	assert.Error(t, err)
+	_, _, err = names.ResolveTable("unknown/orders")
+	assert.Error(t, err)
+}
@@ -81,9 +81,7 @@ func (pageReader *IncrementalPageReader) Close() error {
 // TODO : "initialize" is rather meaningless name, maybe this func should be decomposed
 func (pageReader *IncrementalPageReader) initialize(deltaBitmap *roaring.Bitmap) (size int64, err error) {
 	var headerBuffer bytes.Buffer
-	headerBuffer.Write(IncrementFileHeader)
 	fileSize := pageReader.FileSize
-	headerBuffer.Write(utility.ToBytes(uint64(fileSize)))
 	pageReader.Blocks = make([]uint32, 0, fileSize/DatabasePageSize)
 
 	if deltaBitmap == nil {
@@ -95,13 +93,20 @@ func (pageReader *IncrementalPageReader) initialize(deltaBitmap *roaring.Bitmap)
 		pageReader.DeltaBitmapInitialize(deltaBitmap)
 	}
 
-	pageReader.WriteDiffMapToHeader(&headerBuffer)
+	pageReader.WriteIncrementHeader(&headerBuffer)
 	pageReader.Next = headerBuffer.Bytes()
 	pageDataSize := int64(len(pageReader.Blocks)) * DatabasePageSize
 	size = int64(headerBuffer.Len()) + pageDataSize
 	return
 }
 
+// WriteIncrementHeader writes the increment header with the file size and the list of the blocks
+func (pageReader *IncrementalPageReader) WriteIncrementHeader(headerWriter io.Writer) {
+	_, _ = headerWriter.Write(IncrementFileHeader)
+	_, _ = headerWriter.Write(utility.ToBytes(uint64(pageReader.FileSize)))
+	pageReader.WriteDiffMapToHeader(headerWriter)
+}
+
 func (pageReader *IncrementalPageReader) DeltaBitmapInitialize(deltaBitmap *roaring.Bitmap) {
 	it := deltaBitmap.Iterator()
 	for it.HasNext() { // TODO : do something with file truncation during reading
@@ -0,0 +1,328 @@
+package postgres
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/limiters"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	// LogicalBackupPath is the root of the logical backups, it has its own basebackups_005 folder
+	// so that the logical backups never mix with the physical ones
+	LogicalBackupPath       = "logical_" + utility.VersionStr + "/"
+	LogicalBackupNamePrefix = "logical_"
+
+	PhysicalBackupType = "physical"
+	LogicalBackupType  = "logical"
+
+	pgDumpBinary    = "pg_dump"
+	pgRestoreBinary = "pg_restore"
+)
+
+// LogicalBackupSentinelDto describes a single pg_dump archive
+type LogicalBackupSentinelDto struct {
+	Type             string      `json:"type"`
+	Database         string      `json:"database"`
+	StartTime        time.Time   `json:"start_time"`
+	FinishTime       time.Time   `json:"finish_time"`
+	Hostname         string      `json:"hostname"`
+	IsPermanent      bool        `json:"is_permanent"`
+	UncompressedSize int64       `json:"uncompressed_size"`
+	CompressedSize   int64       `json:"compressed_size"`
+	UserData         interface{} `json:"user_data,omitempty"`
+}
+
+// GetLogicalBackupFolder returns the folder holding the logical backups sentinels and streams
+func GetLogicalBackupFolder(folder storage.Folder) storage.Folder {
+	return folder.GetSubFolder(LogicalBackupPath).GetSubFolder(utility.BaseBackupPath)
+}
+
+// NewLogicalBackupName makes the name from the start time and the database, the database part
+// is only informative, so the characters conflicting with the backup names parsing are replaced
+func NewLogicalBackupName(startTime time.Time, database string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '-'
+	}, database)
+	return LogicalBackupNamePrefix + startTime.UTC().Format(utility.BackupTimeFormat) + "_" + sanitized
+}
+
+// HandleLogicalBackupPush streams pg_dump -Fc of the database through the uploader
+func HandleLogicalBackupPush(uploader internal.Uploader, database string, isPermanent bool, userData interface{}) {
+	if database == "" {
+		tracelog.ErrorLogger.Fatal("The database to dump is required for a logical backup")
+	}
+	uploader.ChangeDirectory(path.Join(LogicalBackupPath, utility.BaseBackupPath))
+
+	startTime := utility.TimeNowCrossPlatformUTC()
+	backupName := NewLogicalBackupName(startTime, database)
+	tracelog.InfoLogger.Printf("Starting logical backup %s of database %s", backupName, database)
+
+	dumpCmd := exec.Command(pgDumpBinary, "--format=custom", "--dbname="+database)
+	stdout, stderr, err := utility.StartCommandWithStdoutStderr(dumpCmd)
+	tracelog.ErrorLogger.FatalfOnError("Failed to start pg_dump: %v", err)
+
+	err = uploader.PushStreamToDestination(limiters.NewDiskLimitReader(stdout),
+		internal.GetStreamName(backupName, uploader.Compression().FileExtension()))
+	tracelog.ErrorLogger.FatalfOnError("Failed to upload the logical backup: %v", err)
+
+	err = dumpCmd.Wait()
+	if err != nil {
+		tracelog.ErrorLogger.Printf("pg_dump output:\n%s", stderr.String())
+		tracelog.ErrorLogger.Fatalf("pg_dump failed: %v", err)
+	}
+
+	sentinel := LogicalBackupSentinelDto{
+		Type:        LogicalBackupType,
+		Database:    database,
+		StartTime:   startTime,
+		FinishTime:  utility.TimeNowCrossPlatformUTC(),
+		IsPermanent: isPermanent,
+		UserData:    userData,
+	}
+	sentinel.Hostname, err = os.Hostname()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to obtain the OS hostname for the backup sentinel: %v", err)
+	}
+	sentinel.CompressedSize, err = uploader.UploadedDataSize()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to calc uploaded data size: %v", err)
+	}
+	sentinel.UncompressedSize, err = uploader.RawDataSize()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to calc raw data size: %v", err)
+	}
+
+	err = internal.UploadSentinel(uploader, &sentinel, backupName)
+	tracelog.ErrorLogger.FatalOnError(err)
+	tracelog.InfoLogger.Printf("Wrote logical backup %s", backupName)
+}
+
+// HandleLogicalBackupFetch restores the logical backup by streaming it into pg_restore.
+// LATEST is resolved among the backups of the database when it is given.
+func HandleLogicalBackupFetch(folder storage.Folder, backupName, database string) {
+	backup, sentinel, err := FindLogicalBackup(folder, backupName, database)
+	tracelog.ErrorLogger.FatalOnError(err)
+	if database == "" {
+		database = sentinel.Database
+	}
+	tracelog.InfoLogger.Printf("Restoring logical backup %s of database %s into %s",
+		backup.Name, sentinel.Database, database)
+
+	restoreCmd := exec.Command(pgRestoreBinary, "--dbname="+database)
+	restoreCmd.Stdout = os.Stdout
+	restoreCmd.Stderr = os.Stderr
+	err = internal.StreamBackupToCommandStdin(restoreCmd, backup)
+	tracelog.ErrorLogger.FatalfOnError("Failed to restore the logical backup: %v", err)
+}
+
+// FindLogicalBackup returns the logical backup by its name, or the latest one of the database for LATEST
+func FindLogicalBackup(folder storage.Folder, backupName, database string) (
+	internal.Backup, LogicalBackupSentinelDto, error) {
+	var sentinel LogicalBackupSentinelDto
+	if backupName != internal.LatestString || database == "" {
+		backup, err := internal.GetBackupByName(backupName, utility.BaseBackupPath, folder.GetSubFolder(LogicalBackupPath))
+		if err != nil {
+			return internal.Backup{}, sentinel, err
+		}
+		return backup, sentinel, backup.FetchSentinel(&sentinel)
+	}
+
+	logicalFolder := GetLogicalBackupFolder(folder)
+	backupTimes, err := internal.GetBackups(logicalFolder)
+	if err != nil {
+		return internal.Backup{}, sentinel, err
+	}
+	internal.SortBackupTimeSlices(backupTimes)
+	for i := len(backupTimes) - 1; i >= 0; i-- {
+		backup := internal.NewBackup(logicalFolder, backupTimes[i].BackupName)
+		if err := backup.FetchSentinel(&sentinel); err != nil {
+			return internal.Backup{}, sentinel, err
+		}
+		if sentinel.Database == database {
+			return backup, sentinel, nil
+		}
+	}
+	return internal.Backup{}, sentinel, internal.NewNoBackupsFoundError()
+}
+
+// NewLogicalDeleteHandler makes the retention machinery work on the logical backups of the database,
+// or of all the databases if it is empty. The backups are ordered by the start time encoded in their names.
+// The handler is not rooted at the storage root, so it never deletes the shared data keys of the physical WAL.
+func NewLogicalDeleteHandler(folder storage.Folder, database string,
+	permanentBackups map[string]bool) (*internal.DeleteHandler, error) {
+	logicalRoot := folder.GetSubFolder(LogicalBackupPath)
+	backups, err := internal.FindBackupObjects(logicalRoot)
+	if err != nil {
+		return nil, err
+	}
+	objectBackupName := func(object storage.Object) string {
+		return utility.StripLeftmostBackupName(strings.TrimPrefix(object.GetName(), utility.BaseBackupPath))
+	}
+	options := []internal.DeleteHandlerOption{
+		internal.IsPermanentFunc(func(object storage.Object) bool {
+			return permanentBackups[objectBackupName(object)]
+		}),
+	}
+
+	if database != "" {
+		databaseBackups := getLogicalBackupsOfDatabase(folder, database)
+		scopedBackups := make([]internal.BackupObject, 0, len(backups))
+		for _, backup := range backups {
+			if databaseBackups[backup.GetBackupName()] {
+				scopedBackups = append(scopedBackups, backup)
+			}
+		}
+		backups = scopedBackups
+		options = append(options, internal.ObjectScopeFunc(func(object storage.Object) bool {
+			return databaseBackups[objectBackupName(object)]
+		}))
+	}
+
+	return internal.NewDeleteHandler(logicalRoot, backups, logicalBackupLess, options...), nil
+}
+
+// HandleLogicalDeleteGarbage deletes the logical backup objects without a sentinel, left by the failed pg_dump runs.
+// Such objects are garbage once a later backup of the same database is complete, so the running dumps are kept.
+func HandleLogicalDeleteGarbage(folder storage.Folder, confirmed bool) error {
+	logicalFolder := GetLogicalBackupFolder(folder)
+	backups, leftovers, err := internal.GetBackupsAndGarbage(logicalFolder)
+	if err != nil {
+		return err
+	}
+	// the latest complete backup time by the database part of the name
+	latestComplete := make(map[string]string)
+	for _, backup := range backups {
+		backupTime, database, ok := parseLogicalBackupName(backup.BackupName)
+		if ok && backupTime > latestComplete[database] {
+			latestComplete[database] = backupTime
+		}
+	}
+	garbage := make([]string, 0)
+	for _, leftover := range leftovers {
+		backupTime, database, ok := parseLogicalBackupName(leftover)
+		if !ok || backupTime >= latestComplete[database] {
+			tracelog.InfoLogger.Printf("Skipping %s: no later complete logical backup of the database", leftover)
+			continue
+		}
+		garbage = append(garbage, leftover)
+	}
+	if len(garbage) == 0 {
+		tracelog.InfoLogger.Println("No logical backups garbage found")
+		return nil
+	}
+	for _, leftover := range garbage {
+		tracelog.InfoLogger.Println("\twill be deleted: " + leftover)
+	}
+	if !confirmed {
+		return nil
+	}
+	return internal.DeleteGarbage(logicalFolder, garbage)
+}
+
+// parseLogicalBackupName splits the logical backup name into the start time and the database part
+func parseLogicalBackupName(backupName string) (backupTime, database string, ok bool) {
+	timeAndDatabase, ok := strings.CutPrefix(backupName, LogicalBackupNamePrefix)
+	if !ok {
+		return "", "", false
+	}
+	backupTime, database, ok = strings.Cut(timeAndDatabase, "_")
+	if !ok || len(backupTime) != len(utility.BackupTimeFormat) {
+		return "", "", false
+	}
+	return backupTime, database, true
+}
+
+// GetPermanentLogicalBackups returns the names of the logical backups of the database marked as permanent,
+// the backups of all the databases are returned if the database is empty
+func GetPermanentLogicalBackups(folder storage.Folder, database string) map[string]bool {
+	permanentBackups := make(map[string]bool)
+	for backupName, sentinel := range fetchLogicalBackupSentinels(folder) {
+		if sentinel.IsPermanent && (database == "" || sentinel.Database == database) {
+			permanentBackups[backupName] = true
+		}
+	}
+	return permanentBackups
+}
+
+// getLogicalBackupsOfDatabase returns the names of the logical backups of the database
+func getLogicalBackupsOfDatabase(folder storage.Folder, database string) map[string]bool {
+	databaseBackups := make(map[string]bool)
+	for backupName, sentinel := range fetchLogicalBackupSentinels(folder) {
+		if sentinel.Database == database {
+			databaseBackups[backupName] = true
+		}
+	}
+	return databaseBackups
+}
+
+// fetchLogicalBackupSentinels maps the names of the logical backups to their sentinels,
+// the backups with unreadable sentinels are skipped
+func fetchLogicalBackupSentinels(folder storage.Folder) map[string]LogicalBackupSentinelDto {
+	folder = GetLogicalBackupFolder(folder)
+	sentinels := make(map[string]LogicalBackupSentinelDto)
+	backupTimes, err := internal.GetBackups(folder)
+	if err != nil {
+		return sentinels
+	}
+	for _, backupTime := range backupTimes {
+		var sentinel LogicalBackupSentinelDto
+		backup := internal.NewBackup(folder, backupTime.BackupName)
+		if err := backup.FetchSentinel(&sentinel); err != nil {
+			tracelog.WarningLogger.Printf("Failed to fetch the sentinel of %s: %v", backupTime.BackupName, err)
+			continue
+		}
+		sentinels[backupTime.BackupName] = sentinel
+	}
+	return sentinels
+}
+
+// LogicalMetaFetcher fetches the generic metadata of the logical backups from their sentinels
+type LogicalMetaFetcher struct{}
+
+func NewLogicalMetaFetcher() LogicalMetaFetcher {
+	return LogicalMetaFetcher{}
+}
+
+func (mf LogicalMetaFetcher) Fetch(backupName string, backupFolder storage.Folder) (internal.GenericMetadata, error) {
+	var sentinel LogicalBackupSentinelDto
+	backup := internal.NewBackup(backupFolder, backupName)
+	err := backup.FetchSentinel(&sentinel)
+	if err != nil {
+		return internal.GenericMetadata{}, err
+	}
+
+	return internal.GenericMetadata{
+		BackupName:       backupName,
+		UncompressedSize: sentinel.UncompressedSize,
+		CompressedSize:   sentinel.CompressedSize,
+		Hostname:         sentinel.Hostname,
+		StartTime:        sentinel.StartTime,
+		FinishTime:       sentinel.FinishTime,
+		IsPermanent:      sentinel.IsPermanent,
+		IncrementDetails: &internal.NopIncrementDetailsFetcher{},
+		UserData:         sentinel.UserData,
+	}, nil
+}
+
+func logicalBackupLess(object1, object2 storage.Object) bool {
+	time1, ok := utility.TryFetchTimeRFC3999(object1.GetName())
+	if !ok {
+		time1 = object1.GetLastModified().UTC().Format(utility.BackupTimeFormat)
+	}
+	time2, ok := utility.TryFetchTimeRFC3999(object2.GetName())
+	if !ok {
+		time2 = object2.GetLastModified().UTC().Format(utility.BackupTimeFormat)
+	}
+	return time1 < time2
+}
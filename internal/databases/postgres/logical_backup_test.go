@@ -0,0 +1,217 @@
+package postgres_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/postgres"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/testtools"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func putLogicalBackup(t *testing.T, folder storage.Folder, name, database string, isPermanent bool) {
+	logicalFolder := postgres.GetLogicalBackupFolder(folder)
+	sentinel, err := json.Marshal(postgres.LogicalBackupSentinelDto{
+		Type:        postgres.LogicalBackupType,
+		Database:    database,
+		IsPermanent: isPermanent,
+	})
+	require.NoError(t, err)
+	require.NoError(t, logicalFolder.PutObject(internal.GetStreamName(name, "lz4"), bytes.NewReader([]byte("dump"))))
+	require.NoError(t, logicalFolder.PutObject(name+utility.SentinelSuffix, bytes.NewReader(sentinel)))
+}
+
+func createLogicalBackupsFolder(t *testing.T) storage.Folder {
+	folder := testtools.MakeDefaultInMemoryStorageFolder()
+	require.NoError(t, folder.GetSubFolder(utility.BaseBackupPath).PutObject(
+		"base_000000010000000000000002"+utility.SentinelSuffix, bytes.NewReader([]byte("{}"))))
+	putLogicalBackup(t, folder, "logical_20240101T000000Z_tenant-1", "tenant_1", true)
+	putLogicalBackup(t, folder, "logical_20240102T000000Z_tenant-2", "tenant_2", false)
+	putLogicalBackup(t, folder, "logical_20240103T000000Z_tenant-1", "tenant_1", false)
+	putLogicalBackup(t, folder, "logical_20240104T000000Z_tenant-2", "tenant_2", false)
+	return folder
+}
+
+func TestNewLogicalBackupName(t *testing.T) {
+	startTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t, "logical_20240102T030405Z_my-db-backup", postgres.NewLogicalBackupName(startTime, "my_db_backup"))
+}
+
+func TestGetTypedBackups(t *testing.T) {
+	backups, err := postgres.GetTypedBackups(createLogicalBackupsFolder(t))
+	require.NoError(t, err)
+
+	types := make(map[string]string)
+	for _, backup := range backups {
+		types[backup.BackupName] = backup.Type
+	}
+	assert.Equal(t, map[string]string{
+		"base_000000010000000000000002":     postgres.PhysicalBackupType,
+		"logical_20240101T000000Z_tenant-1": postgres.LogicalBackupType,
+		"logical_20240102T000000Z_tenant-2": postgres.LogicalBackupType,
+		"logical_20240103T000000Z_tenant-1": postgres.LogicalBackupType,
+		"logical_20240104T000000Z_tenant-2": postgres.LogicalBackupType,
+	}, types)
+
+	buf := new(bytes.Buffer)
+	postgres.WriteTypedBackupList(backups[:1], buf)
+	assert.Regexp(t, "^name +modified +wal_segment_backup_start +type\n\\S+ +\\S+ +\\S+ +(physical|logical)\n$", buf.String())
+}
+
+func TestFindLogicalBackup(t *testing.T) {
+	folder := createLogicalBackupsFolder(t)
+
+	backup, sentinel, err := postgres.FindLogicalBackup(folder, internal.LatestString, "tenant_1")
+	require.NoError(t, err)
+	assert.Equal(t, "logical_20240103T000000Z_tenant-1", backup.Name)
+	assert.Equal(t, "tenant_1", sentinel.Database)
+
+	backup, sentinel, err = postgres.FindLogicalBackup(folder, "logical_20240102T000000Z_tenant-2", "")
+	require.NoError(t, err)
+	assert.Equal(t, "logical_20240102T000000Z_tenant-2", backup.Name)
+	assert.Equal(t, "tenant_2", sentinel.Database)
+
+	_, _, err = postgres.FindLogicalBackup(folder, internal.LatestString, "tenant_3")
+	assert.Error(t, err)
+}
+
+func listTypedBackupNames(t *testing.T, folder storage.Folder) []string {
+	backups, err := postgres.GetTypedBackups(folder)
+	require.NoError(t, err)
+	names := make([]string, 0, len(backups))
+	for _, backup := range backups {
+		names = append(names, backup.BackupName)
+	}
+	return names
+}
+
+func TestLogicalDeleteHandler_Retain(t *testing.T) {
+	folder := createLogicalBackupsFolder(t)
+	assert.Equal(t, map[string]bool{"logical_20240101T000000Z_tenant-1": true},
+		postgres.GetPermanentLogicalBackups(folder, ""))
+	assert.Empty(t, postgres.GetPermanentLogicalBackups(folder, "tenant_2"))
+
+	// the backups of the other databases are neither counted nor deleted
+	deleteHandler, err := postgres.NewLogicalDeleteHandler(folder, "tenant_2", postgres.GetPermanentLogicalBackups(folder, "tenant_2"))
+	require.NoError(t, err)
+	target, err := deleteHandler.FindTargetRetain(1, internal.NoDeleteModifier)
+	require.NoError(t, err)
+	assert.Equal(t, "logical_20240104T000000Z_tenant-2", target.GetBackupName())
+	require.NoError(t, deleteHandler.DeleteBeforeTarget(target, true))
+
+	assert.ElementsMatch(t, []string{
+		"base_000000010000000000000002",
+		"logical_20240101T000000Z_tenant-1",
+		"logical_20240103T000000Z_tenant-1",
+		"logical_20240104T000000Z_tenant-2",
+	}, listTypedBackupNames(t, folder))
+	exists, err := postgres.GetLogicalBackupFolder(folder).Exists("logical_20240102T000000Z_tenant-2/stream.lz4")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	// the permanent logical backups are kept
+	deleteHandler, err = postgres.NewLogicalDeleteHandler(folder, "tenant_1", postgres.GetPermanentLogicalBackups(folder, "tenant_1"))
+	require.NoError(t, err)
+	target, err = deleteHandler.FindTargetRetain(1, internal.NoDeleteModifier)
+	require.NoError(t, err)
+	assert.Equal(t, "logical_20240103T000000Z_tenant-1", target.GetBackupName())
+	require.NoError(t, deleteHandler.DeleteBeforeTarget(target, true))
+	assert.Len(t, listTypedBackupNames(t, folder), 4)
+}
+
+func TestLogicalDeleteHandler_RetainKeepsSharedDataKeys(t *testing.T) {
+	folder := createLogicalBackupsFolder(t)
+	internal.ConfigureDataKeyStore(folder)
+	viper.Set(internal.EnvelopeKeyRotationSetting, "1ms")
+	defer viper.Set(internal.EnvelopeKeyRotationSetting, "24h")
+	// the physical WAL is older than the shared keys, so it may be encrypted with them
+	require.NoError(t, folder.GetSubFolder(utility.WalPath).PutObject("000000010000000000000001.lz4",
+		bytes.NewReader([]byte("wal"))))
+	keyStore := internal.NewFolderKeyStore(folder)
+	require.NoError(t, keyStore.PutSharedKey("aa", []byte("wrapped")))
+	time.Sleep(10 * time.Millisecond)
+	require.NoError(t, keyStore.PutSharedKey("bb", []byte("wrapped")))
+	time.Sleep(10 * time.Millisecond)
+
+	deleteHandler, err := postgres.NewLogicalDeleteHandler(folder, "tenant_2", postgres.GetPermanentLogicalBackups(folder, "tenant_2"))
+	require.NoError(t, err)
+	target, err := deleteHandler.FindTargetRetain(1, internal.NoDeleteModifier)
+	require.NoError(t, err)
+	require.NoError(t, deleteHandler.DeleteBeforeTarget(target, true))
+	assert.Len(t, listTypedBackupNames(t, folder), 4)
+
+	for _, keyID := range []string{"aa", "bb"} {
+		_, err := keyStore.GetWrappedKey(keyID)
+		assert.NoError(t, err, keyID)
+	}
+}
+
+func TestLogicalDeleteHandler_Everything(t *testing.T) {
+	folder := createLogicalBackupsFolder(t)
+	deleteHandler, err := postgres.NewLogicalDeleteHandler(folder, "tenant_2", postgres.GetPermanentLogicalBackups(folder, "tenant_2"))
+	require.NoError(t, err)
+	deleteHandler.DeleteEverything(true)
+
+	assert.ElementsMatch(t, []string{
+		"base_000000010000000000000002",
+		"logical_20240101T000000Z_tenant-1",
+		"logical_20240103T000000Z_tenant-1",
+	}, listTypedBackupNames(t, folder))
+}
+
+func TestDeleteHandler_EverythingKeepsLogicalBackups(t *testing.T) {
+	folder := createLogicalBackupsFolder(t)
+	deleteHandler, err := postgres.NewDeleteHandler(folder, map[string]bool{}, map[string]bool{}, false)
+	require.NoError(t, err)
+	deleteHandler.DeleteEverything(true)
+
+	assert.ElementsMatch(t, []string{
+		"logical_20240101T000000Z_tenant-1",
+		"logical_20240102T000000Z_tenant-2",
+		"logical_20240103T000000Z_tenant-1",
+		"logical_20240104T000000Z_tenant-2",
+	}, listTypedBackupNames(t, folder))
+}
+
+func TestLogicalMetaFetcher(t *testing.T) {
+	folder := createLogicalBackupsFolder(t)
+	meta, err := postgres.NewLogicalMetaFetcher().Fetch("logical_20240101T000000Z_tenant-1",
+		postgres.GetLogicalBackupFolder(folder))
+	require.NoError(t, err)
+	assert.Equal(t, "logical_20240101T000000Z_tenant-1", meta.BackupName)
+	assert.True(t, meta.IsPermanent)
+}
+
+func TestHandleLogicalDeleteGarbage(t *testing.T) {
+	folder := createLogicalBackupsFolder(t)
+	logicalFolder := postgres.GetLogicalBackupFolder(folder)
+	// the failed dump of tenant_1 was followed by a complete one, the dump of tenant_2 may be still running
+	failedStream := internal.GetStreamName("logical_20240102T120000Z_tenant-1", "lz4")
+	runningStream := internal.GetStreamName("logical_20240105T000000Z_tenant-2", "lz4")
+	for _, stream := range []string{failedStream, runningStream} {
+		require.NoError(t, logicalFolder.PutObject(stream, bytes.NewReader([]byte("dump"))))
+	}
+
+	require.NoError(t, postgres.HandleLogicalDeleteGarbage(folder, false))
+	exists, err := logicalFolder.Exists(failedStream)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, postgres.HandleLogicalDeleteGarbage(folder, true))
+	exists, err = logicalFolder.Exists(failedStream)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = logicalFolder.Exists(runningStream)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = logicalFolder.Exists(internal.GetStreamName("logical_20240103T000000Z_tenant-1", "lz4"))
+	require.NoError(t, err)
+	assert.True(t, exists)
+}
@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
@@ -9,15 +10,18 @@ import (
 
 type DatabasesByNames map[string]DatabaseObjectsInfo
 
-// TODO : add tables to info
+const defaultSchema = "public"
+
 type DatabaseObjectsInfo struct {
 	Oid int `json:"oid"`
+	// Tables maps the schema qualified table names to the relfilenodes of the table, its TOAST table and indexes
+	Tables map[string][]int `json:"tables,omitempty"`
 }
 
 // TODO : make other query for this job
 func (meta DatabasesByNames) appendDatabaseInfos(infos []PgDatabaseInfo) {
 	for _, info := range infos {
-		meta[info.Name] = DatabaseObjectsInfo{int(info.Oid)}
+		meta[info.Name] = DatabaseObjectsInfo{Oid: int(info.Oid)}
 	}
 }
 
@@ -28,6 +32,27 @@ func (meta DatabasesByNames) Resolve(key string) (int, error) {
 	return 0, NewIncorrectNameError(key)
 }
 
+// ResolveTable resolves the database/schema.table key to the database oid and the relfilenodes of the table,
+// the public schema is used if the schema is omitted
+func (meta DatabasesByNames) ResolveTable(key string) (int, []int, error) {
+	database, table, _ := strings.Cut(key, "/")
+	data, ok := meta[database]
+	if !ok {
+		return 0, nil, NewIncorrectNameError(database)
+	}
+	if data.Tables == nil {
+		return 0, nil, errors.Errorf("The tables of the database '%s' are not in the backup metadata", database)
+	}
+	if !strings.Contains(table, ".") {
+		table = defaultSchema + "." + table
+	}
+	relFileNodes, ok := data.Tables[table]
+	if !ok {
+		return 0, nil, NewIncorrectTableNameError(key)
+	}
+	return data.Oid, relFileNodes, nil
+}
+
 type IncorrectNameError struct {
 	error
 }
@@ -36,6 +61,10 @@ func NewIncorrectNameError(name string) IncorrectNameError {
 	return IncorrectNameError{errors.Errorf("Can't find database in meta with name: '%s'", name)}
 }
 
+func NewIncorrectTableNameError(name string) IncorrectNameError {
+	return IncorrectNameError{errors.Errorf("Can't find table in meta with name: '%s'", name)}
+}
+
 func (err IncorrectNameError) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
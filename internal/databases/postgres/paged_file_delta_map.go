@@ -2,6 +2,7 @@ package postgres
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"path"
 	"strconv"
@@ -69,6 +70,17 @@ func (deltaMap *PagedFileDeltaMap) AddLocationsToDelta(locations []walparser.Blo
 	}
 }
 
+// AddLimitBlockToDelta marks all the blocks of the relation starting from limitBlockNo as changed,
+// the relation was truncated to limitBlockNo blocks, created or dropped
+func (deltaMap *PagedFileDeltaMap) AddLimitBlockToDelta(relFileNode walparser.RelFileNode, limitBlockNo uint32) {
+	bitmap, contains := (*deltaMap)[relFileNode]
+	if !contains {
+		bitmap = roaring.New()
+		(*deltaMap)[relFileNode] = bitmap
+	}
+	bitmap.AddRange(uint64(limitBlockNo), uint64(math.MaxUint32)+1)
+}
+
 // TODO : unit test no bitmap found
 func (deltaMap *PagedFileDeltaMap) GetDeltaBitmapFor(filePath string) (*roaring.Bitmap, error) {
 	relFileNode, err := GetRelFileNodeFrom(filePath)
@@ -81,3 +81,19 @@ func TestGetDeltaBitmapFor(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, []uint32{23, 134}, bitmap.ToArray())
 }
+
+func TestAddLimitBlockToDelta(t *testing.T) {
+	relFileNode := walparser.RelFileNode{SpcNode: postgres.DefaultSpcNode, DBNode: 1, RelNode: 2}
+	deltaMap := postgres.NewPagedFileDeltaMap()
+	deltaMap.AddLocationToDelta(walparser.BlockLocation{RelationFileNode: relFileNode, BlockNo: 3})
+	deltaMap.AddLimitBlockToDelta(relFileNode, uint32(postgres.BlocksInRelFile-2))
+
+	bitmap, err := deltaMap.GetDeltaBitmapFor("~/DemoDb/base/1/2")
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{3, uint32(postgres.BlocksInRelFile - 2), uint32(postgres.BlocksInRelFile - 1)},
+		bitmap.ToArray())
+	// all the blocks after the limit are changed
+	bitmap, err = deltaMap.GetDeltaBitmapFor("~/DemoDb/base/1/2.5")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(postgres.BlocksInRelFile), bitmap.GetCardinality())
+}
@@ -179,12 +179,17 @@ func VerifyPagedFileIncrement(path string, fileInfo os.FileInfo, increment io.Re
 	if err != nil {
 		return nil, err
 	}
+	return verifyPageBlocks(path, fileInfo, increment, getIncrementBlockNumbers(diffBlockCount, diffMap))
+}
+
+// getIncrementBlockNumbers decodes the numbers of the blocks stored in an increment
+func getIncrementBlockNumbers(diffBlockCount uint32, diffMap []byte) []uint32 {
 	blockNumbers := make([]uint32, 0, diffBlockCount)
 	for i := uint32(0); i < diffBlockCount; i++ {
 		blockNo := binary.LittleEndian.Uint32(diffMap[i*sizeofInt32 : (i+1)*sizeofInt32])
 		blockNumbers = append(blockNumbers, blockNo)
 	}
-	return verifyPageBlocks(path, fileInfo, increment, blockNumbers)
+	return blockNumbers
 }
 
 // VerifyPagedFileBase verifies pages of a standard paged file
@@ -0,0 +1,267 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression"
+	"github.com/apecloud/dataprotection-wal-g/internal/ioextensions"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	PartialWalSuffix = ".partial"
+	// WalgPartialWalSuffix marks the incomplete segments uploaded by wal-receive and daemon, unlike the .partial
+	// segments of the old timeline which PostgreSQL and pg_receivewal leave on the timeline switch
+	WalgPartialWalSuffix = ".walg" + PartialWalSuffix
+
+	// partialWalPollInterval is how often the daemon checks the WAL position of PostgreSQL
+	partialWalPollInterval = time.Second
+)
+
+// PartialWalConfig sets how often the WAL segment being written is uploaded as .partial:
+// after Interval since the oldest WAL not in storage was written, or after Bytes of such WAL.
+// The partial upload is disabled if both are zero. The partials are uploaded not more often than
+// once per MinInterval, the synchronous wal-receive included.
+type PartialWalConfig struct {
+	Interval    time.Duration
+	Bytes       uint64
+	MinInterval time.Duration
+}
+
+// ConfigurePartialWal reads the partial WAL upload settings
+func ConfigurePartialWal() (PartialWalConfig, error) {
+	var config PartialWalConfig
+	if _, ok := internal.GetSetting(internal.PgPartialWalInterval); ok {
+		interval, err := internal.GetDurationSetting(internal.PgPartialWalInterval)
+		if err != nil {
+			return config, err
+		}
+		config.Interval = interval
+	}
+	if bytesStr, ok := internal.GetSetting(internal.PgPartialWalBytes); ok {
+		partialBytes, err := strconv.ParseUint(bytesStr, 10, 64)
+		if err != nil {
+			return config, fmt.Errorf("integer expected for %s setting but given '%s': %w",
+				internal.PgPartialWalBytes, bytesStr, err)
+		}
+		config.Bytes = partialBytes
+	}
+	if _, ok := internal.GetSetting(internal.PgPartialWalMinInterval); ok {
+		minInterval, err := internal.GetDurationSetting(internal.PgPartialWalMinInterval)
+		if err != nil {
+			return config, err
+		}
+		config.MinInterval = minInterval
+	}
+	return config, nil
+}
+
+// rateAllows checks if MinInterval has passed since the previous partial upload
+func (config PartialWalConfig) rateAllows(lastUpload time.Time) bool {
+	return time.Since(lastUpload) >= config.MinInterval
+}
+
+func (config PartialWalConfig) Enabled() bool {
+	return config.Interval > 0 || config.Bytes > 0
+}
+
+// isDue checks if the WAL not in storage yet should be uploaded as .partial
+func (config PartialWalConfig) isDue(unarchivedBytes uint64, unarchivedSince, lastUpload time.Time) bool {
+	if unarchivedBytes == 0 || !config.rateAllows(lastUpload) {
+		return false
+	}
+	if config.Bytes > 0 && unarchivedBytes >= config.Bytes {
+		return true
+	}
+	return config.Interval > 0 && time.Since(unarchivedSince) >= config.Interval
+}
+
+// deletePartialWal deletes the .walg.partial of the WAL segment. It is called after the complete segment is uploaded,
+// so the segment is always available either complete or partial.
+func deletePartialWal(walFolder storage.Folder, walFileName string) error {
+	partialName := walFileName + WalgPartialWalSuffix
+	objects := []string{partialName}
+	for _, decompressor := range compression.Decompressors {
+		objects = append(objects, partialName+"."+decompressor.FileExtension())
+	}
+	return walFolder.DeleteObjects(objects)
+}
+
+// downloadWalFileWithPartialFallback downloads the WAL file, or its .walg.partial when the complete segment
+// is not in storage and WALG_PARTIAL_WAL_FETCH is set, so the recovery can use the WAL uploaded
+// before the segment was complete
+func downloadWalFileWithPartialFallback(reader internal.StorageFolderReader, walFileName, location string) error {
+	err := internal.DownloadFileTo(reader, walFileName, location)
+	if _, ok := err.(internal.ArchiveNonExistenceError); !ok || !isWalFilename(walFileName) ||
+		!viper.GetBool(internal.PgPartialWalFetch) {
+		return err
+	}
+	partialErr := internal.DownloadFileTo(reader, walFileName+WalgPartialWalSuffix, location)
+	if _, ok := partialErr.(internal.ArchiveNonExistenceError); ok {
+		return err
+	}
+	if partialErr == nil {
+		tracelog.WarningLogger.Printf("WAL file %s is not archived yet, fetched its partial segment", walFileName)
+	}
+	return partialErr
+}
+
+// partialWalUploader uploads the WAL segment being written by PostgreSQL as .partial
+type partialWalUploader struct {
+	uploader *WalUploader
+	config   PartialWalConfig
+	walDir   string
+
+	queryRunner *PgQueryRunner
+	// uploadedLSN is the end of the WAL uploaded as .partial
+	uploadedLSN     LSN
+	unarchivedSince time.Time
+	lastUpload      time.Time
+	segmentName     string
+	segmentUploaded bool
+	// pendingCleanup are the segments with .walg.partial in storage which were not archived completely yet,
+	// the daemon deletes the partial right after archiving the segment, this catches the partials uploaded meanwhile
+	pendingCleanup []string
+}
+
+func newPartialWalUploader(uploader *WalUploader, config PartialWalConfig, walDir string) *partialWalUploader {
+	return &partialWalUploader{uploader: uploader, config: config, walDir: walDir}
+}
+
+// run uploads the .partial segments until the context is done
+func (partialUploader *partialWalUploader) run(ctx context.Context) {
+	pollInterval := partialWalPollInterval
+	if partialUploader.config.Interval > 0 {
+		pollInterval = min(pollInterval, partialUploader.config.Interval)
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := partialUploader.uploadIfDue(); err != nil {
+			tracelog.WarningLogger.Printf("Failed to upload the partial WAL segment: %v", err)
+			partialUploader.closeConnection()
+		}
+		partialUploader.cleanupArchived()
+	}
+}
+
+func (partialUploader *partialWalUploader) uploadIfDue() error {
+	if partialUploader.queryRunner == nil {
+		conn, err := Connect()
+		if err != nil {
+			return err
+		}
+		partialUploader.queryRunner, err = NewPgQueryRunner(conn)
+		if err != nil {
+			_ = conn.Close()
+			return err
+		}
+	}
+	walFileName, flushLSN, err := partialUploader.queryRunner.GetCurrentWalFlushPosition()
+	if err != nil {
+		return err
+	}
+	if walFileName == "" {
+		// standby, the WAL is archived by the primary
+		return nil
+	}
+
+	// the file name is of the segment with the last flushed byte, the flushed LSN may be at its end
+	_, segmentNo, err := ParseWALFilename(walFileName)
+	if err != nil {
+		return err
+	}
+	segmentStart := LSN(segmentNo * WalSegmentSize)
+	if walFileName != partialUploader.segmentName {
+		if partialUploader.segmentUploaded {
+			partialUploader.pendingCleanup = append(partialUploader.pendingCleanup, partialUploader.segmentName)
+		}
+		partialUploader.segmentName = walFileName
+		partialUploader.segmentUploaded = false
+		partialUploader.uploadedLSN = segmentStart
+		partialUploader.unarchivedSince = time.Time{}
+	}
+	if flushLSN <= partialUploader.uploadedLSN {
+		return nil
+	}
+	if partialUploader.unarchivedSince.IsZero() {
+		partialUploader.unarchivedSince = time.Now()
+	}
+	if !partialUploader.config.isDue(uint64(flushLSN-partialUploader.uploadedLSN), partialUploader.unarchivedSince,
+		partialUploader.lastUpload) {
+		return nil
+	}
+
+	data, err := readPartialWalFile(filepath.Join(partialUploader.walDir, walFileName), uint64(flushLSN-segmentStart))
+	if err != nil {
+		return err
+	}
+	partialName := walFileName + WalgPartialWalSuffix
+	err = partialUploader.uploader.UploadWalFile(ioextensions.NewNamedReaderImpl(bytes.NewReader(data), partialName))
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Uploaded %s up to %s", partialName, flushLSN)
+	partialUploader.uploadedLSN = flushLSN
+	partialUploader.lastUpload = time.Now()
+	partialUploader.segmentUploaded = true
+	partialUploader.unarchivedSince = time.Time{}
+	return nil
+}
+
+// cleanupArchived deletes the .partial of the segments which were archived completely
+func (partialUploader *partialWalUploader) cleanupArchived() {
+	walFolder := partialUploader.uploader.Folder()
+	pending := partialUploader.pendingCleanup[:0]
+	for _, walFileName := range partialUploader.pendingCleanup {
+		archived, err := walFolder.Exists(walFileName + "." + partialUploader.uploader.Compression().FileExtension())
+		if err == nil && archived {
+			err = deletePartialWal(walFolder, walFileName)
+		}
+		if err != nil {
+			tracelog.WarningLogger.Printf("Failed to clean up the partial WAL segment %s: %v", walFileName, err)
+		}
+		if err != nil || !archived {
+			pending = append(pending, walFileName)
+		}
+	}
+	partialUploader.pendingCleanup = pending
+}
+
+func (partialUploader *partialWalUploader) closeConnection() {
+	if partialUploader.queryRunner != nil {
+		_ = partialUploader.queryRunner.Connection.Close()
+		partialUploader.queryRunner = nil
+	}
+}
+
+// readPartialWalFile reads the flushed part of the WAL file padded with zeros to the segment size,
+// the rest of the file may contain the old WAL of the recycled segment
+func readPartialWalFile(walFilePath string, flushedBytes uint64) ([]byte, error) {
+	file, err := os.Open(walFilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	data := make([]byte, WalSegmentSize)
+	if _, err = io.ReadFull(file, data[:flushedBytes]); err != nil {
+		return nil, errors.Wrapf(err, "failed to read the flushed WAL from %s", walFilePath)
+	}
+	return data, nil
+}
@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/lz4"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/memory"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func putCompressedWalFile(t *testing.T, walFolder storage.Folder, name string, content []byte) {
+	var compressed bytes.Buffer
+	writer := compression.Compressors[lz4.AlgorithmName].NewWriter(&compressed)
+	_, err := utility.FastCopy(writer, bytes.NewReader(content))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, walFolder.PutObject(name+"."+lz4.FileExtension, &compressed))
+}
+
+func TestDownloadWalFileWithPartialFallback(t *testing.T) {
+	walFolder := memory.NewFolder("in_memory/", memory.NewStorage())
+	reader := internal.NewFolderReader(walFolder)
+	putCompressedWalFile(t, walFolder, "000000010000000000000002"+WalgPartialWalSuffix, []byte("partial"))
+	// the .partial of the old timeline left on the timeline switch is never fetched instead of the segment
+	putCompressedWalFile(t, walFolder, "000000010000000000000004"+PartialWalSuffix, []byte("switch"))
+	dir := t.TempDir()
+
+	// the fallback is disabled by default
+	location := filepath.Join(dir, "RECOVERYXLOG")
+	err := downloadWalFileWithPartialFallback(reader, "000000010000000000000002", location)
+	assert.IsType(t, internal.ArchiveNonExistenceError{}, err)
+
+	viper.Set(internal.PgPartialWalFetch, true)
+	defer viper.Set(internal.PgPartialWalFetch, false)
+	require.NoError(t, downloadWalFileWithPartialFallback(reader, "000000010000000000000002", location))
+	content, err := os.ReadFile(location)
+	require.NoError(t, err)
+	assert.Equal(t, "partial", string(content))
+
+	// the complete segment replaces the partial
+	putCompressedWalFile(t, walFolder, "000000010000000000000002", []byte("complete"))
+	require.NoError(t, deletePartialWal(walFolder, "000000010000000000000002"))
+	exists, err := walFolder.Exists("000000010000000000000002" + WalgPartialWalSuffix + "." + lz4.FileExtension)
+	require.NoError(t, err)
+	assert.False(t, exists)
+	require.NoError(t, os.Remove(location))
+	require.NoError(t, downloadWalFileWithPartialFallback(reader, "000000010000000000000002", location))
+	content, err = os.ReadFile(location)
+	require.NoError(t, err)
+	assert.Equal(t, "complete", string(content))
+
+	err = downloadWalFileWithPartialFallback(reader, "000000010000000000000003", filepath.Join(dir, "missing"))
+	assert.IsType(t, internal.ArchiveNonExistenceError{}, err)
+	err = downloadWalFileWithPartialFallback(reader, "000000010000000000000004", filepath.Join(dir, "switch"))
+	assert.IsType(t, internal.ArchiveNonExistenceError{}, err)
+	// only the WAL segments have partials
+	err = downloadWalFileWithPartialFallback(reader, "00000002.history", filepath.Join(dir, "history"))
+	assert.IsType(t, internal.ArchiveNonExistenceError{}, err)
+}
+
+func TestReadPartialWalFile(t *testing.T) {
+	walFilePath := filepath.Join(t.TempDir(), "000000010000000000000002")
+	recycled := bytes.Repeat([]byte{0xff}, int(WalSegmentSize))
+	copy(recycled, "flushed")
+	require.NoError(t, os.WriteFile(walFilePath, recycled, 0600))
+
+	data, err := readPartialWalFile(walFilePath, uint64(len("flushed")))
+	require.NoError(t, err)
+	assert.Len(t, data, int(WalSegmentSize))
+	assert.Equal(t, "flushed", string(data[:len("flushed")]))
+	// the WAL of the recycled segment after the flushed position is not uploaded
+	assert.Equal(t, make([]byte, int(WalSegmentSize)-len("flushed")), data[len("flushed"):])
+}
+
+func TestPartialWalConfig(t *testing.T) {
+	assert.False(t, PartialWalConfig{}.Enabled())
+	assert.True(t, PartialWalConfig{Bytes: 1}.Enabled())
+	assert.False(t, PartialWalConfig{Bytes: 100}.isDue(0, time.Now(), time.Time{}))
+	assert.True(t, PartialWalConfig{Bytes: 100}.isDue(100, time.Now(), time.Time{}))
+	assert.False(t, PartialWalConfig{Interval: time.Minute}.isDue(1, time.Now(), time.Time{}))
+	assert.True(t, PartialWalConfig{Interval: time.Minute}.isDue(1, time.Now().Add(-time.Hour), time.Time{}))
+	// the partials are not uploaded more often than once per MinInterval
+	assert.False(t, PartialWalConfig{Bytes: 100, MinInterval: time.Minute}.isDue(100, time.Now(), time.Now()))
+	assert.True(t, PartialWalConfig{Bytes: 100, MinInterval: time.Minute}.isDue(100, time.Now(), time.Now().Add(-time.Hour)))
+}
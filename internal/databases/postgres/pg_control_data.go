@@ -9,12 +9,17 @@ import (
 	"github.com/wal-g/tracelog"
 )
 
-const pgControlSize = 8192
+const (
+	pgControlSize = 8192
+	// pgControlVersion12 is PG_CONTROL_VERSION of PostgreSQL 12 which replaced recovery.conf with recovery.signal
+	pgControlVersion12 = 1201
+)
 
 // PgControlData represents data contained in pg_control file
 type PgControlData struct {
 	systemIdentifier uint64 // systemIdentifier represents system ID of PG cluster (f.e. [0-8] bytes in pg_control)
 	currentTimeline  uint32 // currentTimeline represents current timeline of PG cluster (f.e. [48-52] bytes in pg_control v. 1100+)
+	pgControlVersion uint32 // pgControlVersion represents PG_CONTROL_VERSION of the PG release (f.e. [8-12] bytes in pg_control)
 	// Any data from pg_control
 }
 
@@ -62,6 +67,7 @@ func extractPgControlData(pgControlReader io.Reader) (*PgControlData, error) {
 	return &PgControlData{
 		systemIdentifier: systemID,
 		currentTimeline:  currentTimeline,
+		pgControlVersion: pgControlVersion,
 	}, nil
 }
 
@@ -72,3 +78,12 @@ func (data *PgControlData) GetSystemIdentifier() uint64 {
 func (data *PgControlData) GetCurrentTimeline() uint32 {
 	return data.currentTimeline
 }
+
+func (data *PgControlData) GetPgControlVersion() uint32 {
+	return data.pgControlVersion
+}
+
+// UsesRecoverySignal checks if the cluster is PostgreSQL 12+ configuring the recovery with recovery.signal
+func (data *PgControlData) UsesRecoverySignal() bool {
+	return data.pgControlVersion >= pgControlVersion12
+}
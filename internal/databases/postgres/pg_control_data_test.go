@@ -32,6 +32,7 @@ func TestExtractPgControlData_OldVersion(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, uint64(9876), pgControlData.GetSystemIdentifier())
 	assert.Equal(t, uint32(7), pgControlData.GetCurrentTimeline())
+	assert.False(t, pgControlData.UsesRecoverySignal())
 }
 
 func TestExtractPgControlData_NewVersion(t *testing.T) {
@@ -52,4 +53,6 @@ func TestExtractPgControlData_NewVersion(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, uint64(9876), pgControlData.GetSystemIdentifier())
 	assert.Equal(t, uint32(7), pgControlData.GetCurrentTimeline())
+	assert.Equal(t, uint32(1100), pgControlData.GetPgControlVersion())
+	assert.False(t, pgControlData.UsesRecoverySignal())
 }
@@ -0,0 +1,446 @@
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	TargetTimelineLatest  = "latest"
+	TargetTimelineCurrent = "current"
+
+	// maxReportedMissingSegments limits the missing WAL segments listed in the error
+	maxReportedMissingSegments = 10
+)
+
+// RecoveryTarget is the point the restored cluster recovers to, exactly one of Time, LSN and Xid is set
+type RecoveryTarget struct {
+	Time *time.Time
+	LSN  *LSN
+	Xid  string
+	// Timeline is the number of the timeline to recover along, "latest" or "current" (the backup timeline)
+	Timeline string
+	// Action is the recovery_target_action, the PostgreSQL default is used if empty
+	Action string
+}
+
+// NewRecoveryTarget parses the backup-fetch --target-* flags, targetTime is in RFC3339
+func NewRecoveryTarget(targetTime, targetLSN, targetXid, timeline, action string) (*RecoveryTarget, error) {
+	target := &RecoveryTarget{Xid: targetXid, Timeline: timeline, Action: action}
+	targetsCount := 0
+	if targetTime != "" {
+		parsedTime, err := time.Parse(time.RFC3339, targetTime)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse the target time")
+		}
+		target.Time = &parsedTime
+		targetsCount++
+	}
+	if targetLSN != "" {
+		parsedLSN, err := pgx.ParseLSN(targetLSN)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse the target LSN")
+		}
+		lsn := LSN(parsedLSN)
+		target.LSN = &lsn
+		targetsCount++
+	}
+	if targetXid != "" {
+		if _, err := strconv.ParseUint(targetXid, 10, 64); err != nil {
+			return nil, errors.Wrap(err, "failed to parse the target xid")
+		}
+		targetsCount++
+	}
+	if targetsCount != 1 {
+		return nil, errors.New("exactly one of the target time, LSN and xid should be set")
+	}
+
+	switch timeline {
+	case "":
+		target.Timeline = TargetTimelineLatest
+	case TargetTimelineLatest, TargetTimelineCurrent:
+	default:
+		if _, err := strconv.ParseUint(timeline, 10, sizeofInt32bits); err != nil {
+			return nil, fmt.Errorf("invalid target timeline '%s': a number, %s or %s expected",
+				timeline, TargetTimelineLatest, TargetTimelineCurrent)
+		}
+	}
+	switch action {
+	case "", "pause", "promote", "shutdown":
+	default:
+		return nil, fmt.Errorf("invalid target action '%s': pause, promote or shutdown expected", action)
+	}
+	return target, nil
+}
+
+func (target *RecoveryTarget) String() string {
+	switch {
+	case target.Time != nil:
+		return "time " + target.Time.Format(time.RFC3339)
+	case target.LSN != nil:
+		return "LSN " + target.LSN.String()
+	default:
+		return "xid " + target.Xid
+	}
+}
+
+// isReachedBy checks if the backup finished before the recovery target.
+// The backups do not record the transaction IDs, so the backup to recover to the xid is chosen by the user.
+func (target *RecoveryTarget) isReachedBy(backup *BackupDetail) bool {
+	switch {
+	case target.Time != nil:
+		return !backupFinishTime(backup).After(*target.Time)
+	case target.LSN != nil:
+		return backup.FinishLsn <= *target.LSN
+	default:
+		return true
+	}
+}
+
+func backupFinishTime(backup *BackupDetail) time.Time {
+	if backup.FinishTime.IsZero() {
+		return backup.Time
+	}
+	return backup.FinishTime
+}
+
+// PitrPlan is the base backup and the WAL range to recover to the target
+type PitrPlan struct {
+	Backup   BackupDetail
+	Timeline uint32
+	// StartSegment and EndSegment are the first and the last WAL segments replayed to reach the target
+	StartSegment WalSegmentDescription
+	EndSegment   WalSegmentDescription
+}
+
+// timelinePath is the target timeline with the history of its ancestors
+type timelinePath struct {
+	timeline uint32
+	// records are the timeline switches sorted by LSN
+	records []*TimelineHistoryRecord
+}
+
+func loadTimelinePath(timeline uint32, walFolder storage.Folder) (*timelinePath, error) {
+	records, err := GetTimeLineHistoryRecords(timeline, walFolder)
+	if _, ok := err.(HistoryFileNotFoundError); ok {
+		if timeline > 1 {
+			tracelog.WarningLogger.Printf("The history file of the timeline %d is not found, "+
+				"the WAL of its parents is not checked", timeline)
+		}
+		return &timelinePath{timeline: timeline}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].lsn < records[j].lsn
+	})
+	return &timelinePath{timeline: timeline, records: records}, nil
+}
+
+// switchLSN returns the LSN where the ancestor timeline switched to its child on the path
+func (history *timelinePath) switchLSN(timeline uint32) (LSN, bool) {
+	for _, record := range history.records {
+		if record.timeline == timeline {
+			return record.lsn, true
+		}
+	}
+	return 0, false
+}
+
+// contains checks if the backup is on the path, i.e. it was finished before its timeline was left
+func (history *timelinePath) contains(backupTimeline uint32, backup *BackupDetail) bool {
+	return history.containsLSN(backupTimeline, backup.FinishLsn)
+}
+
+// containsLSN checks if the timeline is on the path and was not left before the LSN
+func (history *timelinePath) containsLSN(timeline uint32, lsn LSN) bool {
+	if timeline == history.timeline {
+		return true
+	}
+	switchLSN, ok := history.switchLSN(timeline)
+	return ok && lsn <= switchLSN
+}
+
+// segmentTimeline returns the timeline of the segment on the path, the segment with the switch belongs to the child
+func (history *timelinePath) segmentTimeline(segmentNo WalSegmentNo) uint32 {
+	for _, record := range history.records {
+		if segmentNo < newWalSegmentNo(record.lsn) {
+			return record.timeline
+		}
+	}
+	return history.timeline
+}
+
+// SegmentTimelines tells which timeline owns each WAL segment on the path to the target timeline
+type SegmentTimelines struct {
+	history *timelinePath
+}
+
+// LoadSegmentTimelines reads the ancestors of the timeline from its .history file in the WAL folder
+func LoadSegmentTimelines(timeline uint32, walFolder storage.Folder) (*SegmentTimelines, error) {
+	history, err := loadTimelinePath(timeline, walFolder)
+	if err != nil {
+		return nil, err
+	}
+	return &SegmentTimelines{history: history}, nil
+}
+
+// Contains checks if the timeline is on the path and was not left before the LSN
+func (timelines *SegmentTimelines) Contains(timeline uint32, lsn LSN) bool {
+	return timelines.history.containsLSN(timeline, lsn)
+}
+
+// Timeline returns the timeline of the segment, the segment with the switch belongs to the child
+func (timelines *SegmentTimelines) Timeline(segmentNo uint64) uint32 {
+	return timelines.history.segmentTimeline(WalSegmentNo(segmentNo))
+}
+
+func (history *timelinePath) switchMap() map[WalSegmentNo]*TimelineHistoryRecord {
+	switchMap := make(map[WalSegmentNo]*TimelineHistoryRecord, len(history.records))
+	for _, record := range history.records {
+		switchMap[newWalSegmentNo(record.lsn)] = record
+	}
+	return switchMap
+}
+
+// WalArchive is the listing of the WAL folder, the partial segments are not counted as archived
+type WalArchive struct {
+	segments       map[WalSegmentDescription]time.Time
+	latestTimeline uint32
+	// historyTimelines are the timelines with the .history file
+	historyTimelines map[uint32]bool
+}
+
+// ListWalArchive lists the WAL folder page by page, only the parsed segment names are kept
+func ListWalArchive(walFolder storage.Folder) (*WalArchive, error) {
+	archive := &WalArchive{
+		segments:         make(map[WalSegmentDescription]time.Time),
+		historyTimelines: make(map[uint32]bool),
+	}
+	err := storage.IterateObjects(context.Background(), walFolder, storage.ListOptions{}, func(object storage.Object) error {
+		name := path.Base(object.GetName())
+		if historyName, _, isHistory := strings.Cut(name, ".history"); isHistory {
+			if timeline, err := ParseTimelineFromString(historyName); err == nil {
+				archive.latestTimeline = max(archive.latestTimeline, timeline)
+				archive.historyTimelines[timeline] = true
+			}
+			return nil
+		}
+		segment, err := NewWalSegmentDescription(utility.TrimFileExtension(name))
+		if err != nil {
+			return nil
+		}
+		archive.latestTimeline = max(archive.latestTimeline, segment.Timeline)
+		if modified, ok := archive.segments[segment]; !ok || object.GetLastModified().Before(modified) {
+			archive.segments[segment] = object.GetLastModified()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list the WAL archive")
+	}
+	return archive, nil
+}
+
+// HasSegment checks if the segment of the timeline is archived
+func (archive *WalArchive) HasSegment(timeline uint32, segmentNo uint64) bool {
+	_, ok := archive.segments[WalSegmentDescription{Timeline: timeline, Number: WalSegmentNo(segmentNo)}]
+	return ok
+}
+
+// HistoryTimelines returns the timelines with the .history file, the latest first
+func (archive *WalArchive) HistoryTimelines() []uint32 {
+	timelines := make([]uint32, 0, len(archive.historyTimelines))
+	for timeline := range archive.historyTimelines {
+		timelines = append(timelines, timeline)
+	}
+	sort.Slice(timelines, func(i, j int) bool {
+		return timelines[i] > timelines[j]
+	})
+	return timelines
+}
+
+func (archive *WalArchive) segmentSet() map[WalSegmentDescription]bool {
+	segments := make(map[WalSegmentDescription]bool, len(archive.segments))
+	for segment := range archive.segments {
+		segments[segment] = true
+	}
+	return segments
+}
+
+// PlanPointInTimeRestore picks the newest base backup finished before the recovery target on the target timeline
+// and checks that the WAL needed to recover from it to the target is in storage.
+// If backupName is set, only this backup is considered, it is required to recover to the xid.
+func PlanPointInTimeRestore(rootFolder storage.Folder, target *RecoveryTarget, backupName string) (*PitrPlan, error) {
+	if target.Xid != "" && (backupName == "" || backupName == internal.LatestString) {
+		return nil, errors.New("the backups do not record the transaction IDs, " +
+			"pass the name of the backup finished before the target xid")
+	}
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	baseBackupFolder := rootFolder.GetSubFolder(utility.BaseBackupPath)
+	archive, err := ListWalArchive(walFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	backupTimes, err := internal.GetBackups(baseBackupFolder)
+	if err != nil {
+		return nil, err
+	}
+	if backupName != "" && backupName != internal.LatestString {
+		var selected []internal.BackupTime
+		for _, backupTime := range backupTimes {
+			if backupTime.BackupName == backupName {
+				selected = append(selected, backupTime)
+			}
+		}
+		if len(selected) == 0 {
+			return nil, internal.NewBackupNonExistenceError(backupName)
+		}
+		backupTimes = selected
+	}
+	backups, err := GetBackupsDetails(baseBackupFolder, backupTimes)
+	if err != nil {
+		return nil, err
+	}
+
+	histories := make(map[uint32]*timelinePath)
+	getHistory := func(timeline uint32) (*timelinePath, error) {
+		if history, ok := histories[timeline]; ok {
+			return history, nil
+		}
+		history, err := loadTimelinePath(timeline, walFolder)
+		histories[timeline] = history
+		return history, err
+	}
+
+	var plan *PitrPlan
+	var planHistory *timelinePath
+	for i := range backups {
+		backup := &backups[i]
+		backupTimeline, backupSegmentNo, err := ParseWALFilename(backup.WalFileName)
+		if err != nil {
+			return nil, err
+		}
+		targetTimeline, err := resolveTargetTimeline(target.Timeline, backupTimeline, archive)
+		if err != nil {
+			return nil, err
+		}
+		history, err := getHistory(targetTimeline)
+		if err != nil {
+			return nil, err
+		}
+		if !history.contains(backupTimeline, backup) || !target.isReachedBy(backup) {
+			continue
+		}
+		if plan == nil || plan.Backup.FinishLsn < backup.FinishLsn {
+			plan = &PitrPlan{
+				Backup:       *backup,
+				Timeline:     targetTimeline,
+				StartSegment: WalSegmentDescription{Number: WalSegmentNo(backupSegmentNo), Timeline: backupTimeline},
+			}
+			planHistory = history
+		}
+	}
+	if plan == nil {
+		return nil, fmt.Errorf("no base backup finished before the recovery target %s on the timeline %s was found",
+			target, target.Timeline)
+	}
+	tracelog.InfoLogger.Printf("Selected the base backup %s finished at %s (LSN %s)",
+		plan.Backup.BackupName, backupFinishTime(&plan.Backup).Format(time.RFC3339), plan.Backup.FinishLsn)
+
+	if plan.EndSegment, err = findEndSegment(target, plan, planHistory, archive); err != nil {
+		return nil, err
+	}
+	if err = checkWalRange(plan, planHistory, archive); err != nil {
+		return nil, err
+	}
+	tracelog.InfoLogger.Printf("WAL from %s to %s is in storage", plan.StartSegment.GetFileName(), plan.EndSegment.GetFileName())
+	return plan, nil
+}
+
+func resolveTargetTimeline(timeline string, backupTimeline uint32, archive *WalArchive) (uint32, error) {
+	switch timeline {
+	case TargetTimelineLatest:
+		return max(archive.latestTimeline, backupTimeline), nil
+	case TargetTimelineCurrent:
+		return backupTimeline, nil
+	default:
+		parsed, err := strconv.ParseUint(timeline, 10, sizeofInt32bits)
+		return uint32(parsed), err
+	}
+}
+
+// findEndSegment finds the last WAL segment needed to reach the target.
+// The WAL does not record the time and xid in the segment names, so for the time target it is the first segment
+// archived after the target time, and for the xid target it is the last archived segment of the timeline.
+func findEndSegment(target *RecoveryTarget, plan *PitrPlan, history *timelinePath,
+	archive *WalArchive) (WalSegmentDescription, error) {
+	if target.LSN != nil {
+		segmentNo := newWalSegmentNo(*target.LSN)
+		return WalSegmentDescription{Number: segmentNo, Timeline: history.segmentTimeline(segmentNo)}, nil
+	}
+
+	var lastSegmentNo WalSegmentNo
+	for segment := range archive.segments {
+		if segment.Timeline == history.timeline && segment.Number > lastSegmentNo {
+			lastSegmentNo = segment.Number
+		}
+	}
+	finishSegmentNo := newWalSegmentNo(plan.Backup.FinishLsn)
+	if lastSegmentNo < finishSegmentNo {
+		lastSegmentNo = finishSegmentNo
+	}
+	if target.Time == nil {
+		return WalSegmentDescription{Number: lastSegmentNo, Timeline: history.segmentTimeline(lastSegmentNo)}, nil
+	}
+
+	for segmentNo := finishSegmentNo; segmentNo <= lastSegmentNo; segmentNo = segmentNo.next() {
+		segment := WalSegmentDescription{Number: segmentNo, Timeline: history.segmentTimeline(segmentNo)}
+		if modified, ok := archive.segments[segment]; ok && !modified.Before(*target.Time) {
+			return segment, nil
+		}
+	}
+	return WalSegmentDescription{}, fmt.Errorf("no WAL archived after the target time %s was found, "+
+		"the recovery would end before reaching the target", target.Time.Format(time.RFC3339))
+}
+
+// checkWalRange scans the WAL segments from the end segment back to the backup start segment
+func checkWalRange(plan *PitrPlan, history *timelinePath, archive *WalArchive) error {
+	startSegmentNo := plan.EndSegment.Number.next()
+	runner := NewWalSegmentRunner(
+		WalSegmentDescription{Number: startSegmentNo, Timeline: history.segmentTimeline(startSegmentNo)},
+		archive.segmentSet(),
+		plan.StartSegment.Number,
+		history.switchMap())
+	scanner := NewWalSegmentScanner(runner)
+	if err := scanner.Scan(SegmentScanConfig{UnlimitedScan: true, MissingSegmentStatus: Lost}); err != nil {
+		return err
+	}
+	missingSegments := scanner.GetMissingSegmentsDescriptions()
+	if len(missingSegments) == 0 {
+		return nil
+	}
+	sort.Slice(missingSegments, func(i, j int) bool {
+		return missingSegments[i].Number < missingSegments[j].Number
+	})
+	names := make([]string, 0, maxReportedMissingSegments)
+	for _, segment := range missingSegments[:min(len(missingSegments), maxReportedMissingSegments)] {
+		names = append(names, segment.GetFileName())
+	}
+	return fmt.Errorf("%d WAL segments needed to recover from %s are missing in storage: %s",
+		len(missingSegments), plan.Backup.BackupName, strings.Join(names, ", "))
+}
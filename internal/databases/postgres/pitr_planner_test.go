@@ -0,0 +1,192 @@
+package postgres_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/postgres"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pitrTestBaseTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func pitrTestBackup(finishSegment uint64, offset uint64, finishHours int) postgres.ExtendedMetadataDto {
+	meta := newMockExtendedMetadataDto(false)
+	meta.FinishLsn = postgres.LSN(finishSegment*postgres.WalSegmentSize + offset)
+	meta.FinishTime = pitrTestBaseTime.Add(time.Duration(finishHours) * time.Hour)
+	return meta
+}
+
+// setupPitrTestStorage stores the timeline 5 switched to the timeline 6 in the 5th segment
+func setupPitrTestStorage(t *testing.T, missingSegments ...string) storage.Folder {
+	storageSegments := make([]string, 0)
+	for _, segment := range []string{
+		"000000050000000000000001", "000000050000000000000002", "000000050000000000000003",
+		"000000050000000000000004", "000000050000000000000005", "000000050000000000000006",
+		"000000060000000000000005", "000000060000000000000006", "000000060000000000000007",
+		"000000060000000000000008", "000000060000000000000009",
+	} {
+		if !slices.Contains(missingSegments, segment) {
+			storageSegments = append(storageSegments, segment)
+		}
+	}
+
+	switchPointLsn := 5*postgres.WalSegmentSize + 100
+	historyName, historyFile, err := newTimelineHistoryFile(fmt.Sprintf("%d\t0/%X\tno recovery target specified\n", 5, switchPointLsn), 6)
+	require.NoError(t, err)
+	storageFiles := map[string]*bytes.Buffer{utility.WalPath + historyName: historyFile}
+	addMockBackupsStorageFiles(map[string]postgres.ExtendedMetadataDto{
+		"000000050000000000000002": pitrTestBackup(3, 0, 1),
+		"000000050000000000000004": pitrTestBackup(4, 50, 2),
+		// finished on the timeline 5 after the switch, so it is not on the path of the timeline 6
+		"000000050000000000000006": pitrTestBackup(6, 10, 3),
+		"000000060000000000000007": pitrTestBackup(7, 10, 4),
+	}, storageFiles)
+
+	rootFolder := setupTestStorageFolder()
+	for name, content := range storageFiles {
+		require.NoError(t, rootFolder.PutObject(name, content))
+	}
+	putWalSegments(storageSegments, rootFolder.GetSubFolder(utility.WalPath))
+	return rootFolder
+}
+
+func planPitrTest(t *testing.T, rootFolder storage.Folder, targetTime, targetLSN, targetXid, timeline string) (
+	*postgres.PitrPlan, error) {
+	target, err := postgres.NewRecoveryTarget(targetTime, targetLSN, targetXid, timeline, "")
+	require.NoError(t, err)
+	return postgres.PlanPointInTimeRestore(rootFolder, target, "")
+}
+
+func TestPlanPointInTimeRestore_TargetLSN(t *testing.T) {
+	plan, err := planPitrTest(t, setupPitrTestStorage(t), "", "0/6000500", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "base_000000050000000000000004", plan.Backup.BackupName)
+	assert.Equal(t, uint32(6), plan.Timeline)
+	assert.Equal(t, "000000050000000000000004", plan.StartSegment.GetFileName())
+	assert.Equal(t, "000000060000000000000006", plan.EndSegment.GetFileName())
+}
+
+func TestPlanPointInTimeRestore_CurrentTimeline(t *testing.T) {
+	plan, err := planPitrTest(t, setupPitrTestStorage(t), "", "0/6000500", "", postgres.TargetTimelineCurrent)
+	require.NoError(t, err)
+	assert.Equal(t, "base_000000050000000000000006", plan.Backup.BackupName)
+	assert.Equal(t, uint32(5), plan.Timeline)
+	assert.Equal(t, "000000050000000000000006", plan.EndSegment.GetFileName())
+}
+
+func TestPlanPointInTimeRestore_MissingWal(t *testing.T) {
+	_, err := planPitrTest(t, setupPitrTestStorage(t, "000000060000000000000006"), "", "0/7000000", "", "6")
+	assert.ErrorContains(t, err, "1 WAL segments needed to recover from base_000000050000000000000004 are missing")
+	assert.ErrorContains(t, err, "000000060000000000000006")
+
+	// the segment of the timeline 5 is not on the path of the timeline 6
+	_, err = planPitrTest(t, setupPitrTestStorage(t, "000000050000000000000006"), "", "0/7000000", "", "6")
+	assert.NoError(t, err)
+}
+
+func TestPlanPointInTimeRestore_TargetTime(t *testing.T) {
+	rootFolder := setupPitrTestStorage(t)
+	// all the WAL in storage was archived after the target time
+	plan, err := planPitrTest(t, rootFolder, pitrTestBaseTime.Add(150*time.Minute).Format(time.RFC3339), "", "", "")
+	require.NoError(t, err)
+	assert.Equal(t, "base_000000050000000000000004", plan.Backup.BackupName)
+	assert.Equal(t, "000000050000000000000004", plan.EndSegment.GetFileName())
+
+	_, err = planPitrTest(t, rootFolder, time.Now().Add(time.Hour).Format(time.RFC3339), "", "", "")
+	assert.ErrorContains(t, err, "no WAL archived after the target time")
+
+	_, err = planPitrTest(t, rootFolder, pitrTestBaseTime.Format(time.RFC3339), "", "", "")
+	assert.ErrorContains(t, err, "no base backup finished before the recovery target")
+}
+
+func TestPlanPointInTimeRestore_TargetXid(t *testing.T) {
+	rootFolder := setupPitrTestStorage(t)
+	// the backups do not record the xid, so the backup finished before it can't be chosen
+	_, err := planPitrTest(t, rootFolder, "", "", "1234", "")
+	assert.ErrorContains(t, err, "pass the name of the backup")
+
+	target, err := postgres.NewRecoveryTarget("", "", "1234", "", "")
+	require.NoError(t, err)
+	plan, err := postgres.PlanPointInTimeRestore(rootFolder, target, "base_000000050000000000000004")
+	require.NoError(t, err)
+	assert.Equal(t, "base_000000050000000000000004", plan.Backup.BackupName)
+	assert.Equal(t, "000000060000000000000009", plan.EndSegment.GetFileName())
+}
+
+func TestNewRecoveryTarget_Invalid(t *testing.T) {
+	for _, args := range [][]string{
+		{"", "", "", "", ""},
+		{"2024-01-01T00:00:00Z", "0/1000000", "", "", ""},
+		{"yesterday", "", "", "", ""},
+		{"", "", "abc", "", ""},
+		{"", "", "1", "newest", ""},
+		{"", "", "1", "", "stop"},
+	} {
+		_, err := postgres.NewRecoveryTarget(args[0], args[1], args[2], args[3], args[4])
+		assert.Error(t, err, args)
+	}
+}
+
+func writeTestPgControl(t *testing.T, dataDir string, pgControlVersion uint32) {
+	pgControl := make([]byte, 8192)
+	binary.LittleEndian.PutUint32(pgControl[8:12], pgControlVersion)
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDir, "global"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, postgres.PgControlPath), pgControl, 0600))
+}
+
+func TestWriteRecoveryConfig(t *testing.T) {
+	plan := &postgres.PitrPlan{Timeline: 6}
+	target, err := postgres.NewRecoveryTarget("", "0/6000500", "", "", "promote")
+	require.NoError(t, err)
+	expectedSettings := "restore_command = 'wal-g wal-fetch \"%f\" \"%p\" --config ''/etc/wal g.json'''\n" +
+		"recovery_target_lsn = '0/6000500'\n" +
+		"recovery_target_timeline = '6'\n" +
+		"recovery_target_action = 'promote'\n"
+	restoreCommand := "wal-g wal-fetch \"%f\" \"%p\" --config '/etc/wal g.json'"
+
+	dataDir := t.TempDir()
+	writeTestPgControl(t, dataDir, 1300)
+	require.NoError(t, os.WriteFile(filepath.Join(dataDir, postgres.AutoConfFileName), []byte("work_mem = '4MB'\n"), 0600))
+	require.NoError(t, postgres.WriteRecoveryConfig(dataDir, plan, target, restoreCommand))
+	autoConf, err := os.ReadFile(filepath.Join(dataDir, postgres.AutoConfFileName))
+	require.NoError(t, err)
+	assert.Contains(t, string(autoConf), "work_mem = '4MB'\n")
+	assert.Contains(t, string(autoConf), expectedSettings)
+	assert.FileExists(t, filepath.Join(dataDir, postgres.RecoverySignalFileName))
+
+	dataDir = t.TempDir()
+	writeTestPgControl(t, dataDir, 1100)
+	require.NoError(t, postgres.WriteRecoveryConfig(dataDir, plan, target, restoreCommand))
+	recoveryConf, err := os.ReadFile(filepath.Join(dataDir, postgres.RecoveryConfFileName))
+	require.NoError(t, err)
+	assert.Contains(t, string(recoveryConf), expectedSettings)
+	assert.NoFileExists(t, filepath.Join(dataDir, postgres.RecoverySignalFileName))
+}
+
+func TestDefaultRestoreCommand(t *testing.T) {
+	command, err := postgres.DefaultRestoreCommand("/usr/bin/wal-g", "")
+	require.NoError(t, err)
+	assert.Equal(t, "\"/usr/bin/wal-g\" wal-fetch \"%f\" \"%p\"", command)
+
+	command, err = postgres.DefaultRestoreCommand("/usr/bin/wal-g", "/etc/wal g.json")
+	require.NoError(t, err)
+	assert.Equal(t, "\"/usr/bin/wal-g\" wal-fetch \"%f\" \"%p\" --config \"/etc/wal g.json\"", command)
+
+	// restore_command runs in the data directory, so the relative config path is made absolute
+	workDir, err := os.Getwd()
+	require.NoError(t, err)
+	command, err = postgres.DefaultRestoreCommand("/usr/bin/wal-g", "./walg.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("\"/usr/bin/wal-g\" wal-fetch \"%%f\" \"%%p\" --config \"%s\"",
+		filepath.Join(workDir, "walg.yaml")), command)
+}
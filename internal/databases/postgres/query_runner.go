@@ -88,6 +88,16 @@ func (queryRunner *PgQueryRunner) buildGetCurrentLsn() string {
 		"END"
 }
 
+// buildGetCurrentWalFlushPosition formats a query to get the WAL file being written and the flushed LSN of the primary
+func (queryRunner *PgQueryRunner) buildGetCurrentWalFlushPosition() string {
+	if queryRunner.Version >= 100000 {
+		return "SELECT CASE WHEN pg_is_in_recovery() THEN '' ELSE pg_walfile_name(pg_current_wal_flush_lsn()) END, " +
+			"CASE WHEN pg_is_in_recovery() THEN '0/0' ELSE pg_current_wal_flush_lsn()::text END"
+	}
+	return "SELECT CASE WHEN pg_is_in_recovery() THEN '' ELSE pg_xlogfile_name(pg_current_xlog_flush_location()) END, " +
+		"CASE WHEN pg_is_in_recovery() THEN '0/0' ELSE pg_current_xlog_flush_location()::text END"
+}
+
 // BuildStartBackup formats a query that starts backup according to server features and version
 func (queryRunner *PgQueryRunner) BuildStartBackup() (string, error) {
 	// TODO: rewrite queries for older versions to remove pg_is_in_recovery()
@@ -380,6 +390,73 @@ func (queryRunner *PgQueryRunner) GetDatabaseInfos() ([]PgDatabaseInfo, error) {
 	return databases, nil
 }
 
+// getCurrentDatabase returns the name of the database the runner is connected to
+func (queryRunner *PgQueryRunner) getCurrentDatabase() (database string, err error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	err = queryRunner.Connection.QueryRow("SELECT current_database()").Scan(&database)
+	return database, errors.Wrap(err, "GetCurrentDatabase: getting the current database failed")
+}
+
+// BuildGetTablesQuery formats a query to get the relfilenodes of the user tables with their TOAST tables and indexes.
+// The tables, their TOAST tables and the indexes of both are united, each of them is found by an equi-join.
+func (queryRunner *PgQueryRunner) BuildGetTablesQuery() (string, error) {
+	switch {
+	case queryRunner.Version >= 90000:
+		return "WITH tables AS (" +
+			"SELECT n.nspname || '.' || c.relname AS name, c.oid, c.reltoastrelid " +
+			"FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace " +
+			"WHERE c.relkind IN ('r', 'm') " +
+			"AND n.nspname NOT IN ('pg_catalog', 'information_schema', 'pg_toast')), " +
+			"relations AS (" +
+			"SELECT name, oid FROM tables " +
+			"UNION ALL SELECT name, reltoastrelid FROM tables WHERE reltoastrelid <> 0) " +
+			"SELECT name, filenode FROM (" +
+			"SELECT name, pg_relation_filenode(oid) AS filenode FROM relations " +
+			"UNION ALL SELECT r.name, pg_relation_filenode(i.indexrelid) " +
+			"FROM relations r JOIN pg_index i ON i.indrelid = r.oid) filenodes " +
+			"WHERE filenode IS NOT NULL", nil
+	case queryRunner.Version == 0:
+		return "", NewNoPostgresVersionError()
+	default:
+		return "", NewUnsupportedPostgresVersionError(queryRunner.Version)
+	}
+}
+
+// GetTables fetches the relfilenodes of the user tables of the database, see BuildGetTablesQuery
+func (queryRunner *PgQueryRunner) GetTables() (map[string][]int, error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	getTablesQuery, err := queryRunner.BuildGetTablesQuery()
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryRunner GetTables: Building tables query failed")
+	}
+
+	rows, err := queryRunner.Connection.Query(getTablesQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryRunner GetTables: pg_class query failed")
+	}
+
+	defer rows.Close()
+	tables := make(map[string][]int)
+	for rows.Next() {
+		var tableName string
+		var relFileNode uint32
+		if err := rows.Scan(&tableName, &relFileNode); err != nil {
+			return nil, errors.Wrap(err, "QueryRunner GetTables: scan failed")
+		}
+		tables[tableName] = append(tables[tableName], int(relFileNode))
+	}
+
+	if rows.Err() != nil {
+		return nil, rows.Err()
+	}
+
+	return tables, nil
+}
+
 // GetParameter reads a Postgres setting
 // TODO: Unittest
 func (queryRunner *PgQueryRunner) GetParameter(parameterName string) (string, error) {
@@ -441,6 +518,172 @@ func (queryRunner *PgQueryRunner) GetPhysicalSlotInfo(slotName string) (Physical
 	return NewPhysicalSlot(slotName, true, active, restartLSN)
 }
 
+// GetCurrentWalFlushPosition returns the WAL file being written and the flushed LSN, the file name is empty on a standby
+func (queryRunner *PgQueryRunner) GetCurrentWalFlushPosition() (walFileName string, lsn LSN, err error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	var lsnStr string
+	conn := queryRunner.Connection
+	err = conn.QueryRow(queryRunner.buildGetCurrentWalFlushPosition()).Scan(&walFileName, &lsnStr)
+	if err != nil {
+		return "", 0, err
+	}
+	parsedLSN, err := pgx.ParseLSN(lsnStr)
+	return walFileName, LSN(parsedLSN), err
+}
+
+// GetWalSummarizedLSN returns the end of the WAL summarized by the WAL summarizer of PostgreSQL 17+
+func (queryRunner *PgQueryRunner) GetWalSummarizedLSN() (LSN, error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	var lsnStr string
+	conn := queryRunner.Connection
+	err := conn.QueryRow("SELECT summarized_lsn::text FROM pg_get_wal_summarizer_state()").Scan(&lsnStr)
+	if err != nil {
+		return 0, errors.Wrap(err, "QueryRunner GetWalSummarizedLSN: pg_get_wal_summarizer_state query failed")
+	}
+	lsn, err := pgx.ParseLSN(lsnStr)
+	return LSN(lsn), err
+}
+
+// GetWalSummaries lists the WAL summary files of PostgreSQL 17+
+func (queryRunner *PgQueryRunner) GetWalSummaries() ([]WalSummary, error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	conn := queryRunner.Connection
+	rows, err := conn.Query("SELECT tli, start_lsn::text, end_lsn::text FROM pg_available_wal_summaries()")
+	if err != nil {
+		return nil, errors.Wrap(err, "QueryRunner GetWalSummaries: pg_available_wal_summaries query failed")
+	}
+	defer rows.Close()
+
+	summaries := make([]WalSummary, 0)
+	for rows.Next() {
+		var timeline int64
+		var startLSNStr, endLSNStr string
+		if err := rows.Scan(&timeline, &startLSNStr, &endLSNStr); err != nil {
+			return nil, err
+		}
+		startLSN, err := pgx.ParseLSN(startLSNStr)
+		if err != nil {
+			return nil, err
+		}
+		endLSN, err := pgx.ParseLSN(endLSNStr)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, WalSummary{Timeline: uint32(timeline), StartLSN: LSN(startLSN), EndLSN: LSN(endLSN)})
+	}
+	return summaries, rows.Err()
+}
+
+// ReadWalSummaryContents adds the main fork blocks listed in the WAL summary to the delta map
+func (queryRunner *PgQueryRunner) ReadWalSummaryContents(summary WalSummary, deltaMap PagedFileDeltaMap) error {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	conn := queryRunner.Connection
+	rows, err := conn.Query("SELECT relfilenode, reltablespace, reldatabase, relblocknumber, is_limit_block "+
+		"FROM pg_wal_summary_contents($1, $2::pg_lsn, $3::pg_lsn) WHERE relforknumber = 0",
+		int64(summary.Timeline), summary.StartLSN.String(), summary.EndLSN.String())
+	if err != nil {
+		return errors.Wrap(err, "QueryRunner ReadWalSummaryContents: pg_wal_summary_contents query failed")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var relNode, spcNode, dbNode uint32
+		var blockNo int64
+		var isLimitBlock bool
+		if err := rows.Scan(&relNode, &spcNode, &dbNode, &blockNo, &isLimitBlock); err != nil {
+			return err
+		}
+		relFileNode := walparser.RelFileNode{
+			SpcNode: walparser.Oid(spcNode),
+			DBNode:  walparser.Oid(dbNode),
+			RelNode: walparser.Oid(relNode),
+		}
+		if isLimitBlock {
+			deltaMap.AddLimitBlockToDelta(relFileNode, uint32(blockNo))
+		} else {
+			deltaMap.AddLocationToDelta(walparser.BlockLocation{RelationFileNode: relFileNode, BlockNo: uint32(blockNo)})
+		}
+	}
+	return rows.Err()
+}
+
+// GetReplayLag returns the seconds since the commit time of the last transaction replayed by the standby,
+// ok is false if no transaction was replayed since the standby start
+func (queryRunner *PgQueryRunner) GetReplayLag() (lag float64, ok bool, err error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	conn := queryRunner.Connection
+	err = conn.QueryRow("SELECT pg_last_xact_replay_timestamp() IS NOT NULL, "+
+		"coalesce(extract(epoch FROM now() - pg_last_xact_replay_timestamp()), 0)::float8").Scan(&ok, &lag)
+	if err != nil {
+		return 0, false, errors.Wrap(err, "QueryRunner GetReplayLag: pg_last_xact_replay_timestamp query failed")
+	}
+	return lag, ok, nil
+}
+
+// IsInRecovery checks if the server is a standby
+func (queryRunner *PgQueryRunner) IsInRecovery() (bool, error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	var inRecovery bool
+	err := queryRunner.Connection.QueryRow("SELECT pg_is_in_recovery()").Scan(&inRecovery)
+	if err != nil {
+		return false, errors.Wrap(err, "QueryRunner IsInRecovery: pg_is_in_recovery query failed")
+	}
+	return inRecovery, nil
+}
+
+// GetServerAddr returns the address of the server, it is empty for the unix socket connection
+func (queryRunner *PgQueryRunner) GetServerAddr() (string, error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	var serverAddr string
+	err := queryRunner.Connection.QueryRow("SELECT coalesce(host(inet_server_addr()), '')").Scan(&serverAddr)
+	if err != nil {
+		return "", errors.Wrap(err, "QueryRunner GetServerAddr: inet_server_addr query failed")
+	}
+	return serverAddr, nil
+}
+
+// GetLastArchivedWal returns the name of the last file archived by the archiver of PostgreSQL 9.4+
+func (queryRunner *PgQueryRunner) GetLastArchivedWal() (string, error) {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	var lastArchivedWal string
+	conn := queryRunner.Connection
+	err := conn.QueryRow("SELECT coalesce(last_archived_wal, '') FROM pg_stat_archiver").Scan(&lastArchivedWal)
+	if err != nil {
+		return "", errors.Wrap(err, "QueryRunner GetLastArchivedWal: pg_stat_archiver query failed")
+	}
+	return lastArchivedWal, nil
+}
+
+// SwitchWal forces the switch to the next WAL segment, so the current one is archived
+func (queryRunner *PgQueryRunner) SwitchWal() error {
+	queryRunner.Mu.Lock()
+	defer queryRunner.Mu.Unlock()
+
+	query := "SELECT pg_switch_wal()::text"
+	if queryRunner.Version < 100000 {
+		query = "SELECT pg_switch_xlog()::text"
+	}
+	var lsnStr string
+	err := queryRunner.Connection.QueryRow(query).Scan(&lsnStr)
+	return errors.Wrap(err, "QueryRunner SwitchWal: switch query failed")
+}
+
 // tablespace map does not exist in < 9.6
 // TODO: Unittest
 func (queryRunner *PgQueryRunner) IsTablespaceMapExists() bool {
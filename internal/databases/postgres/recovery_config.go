@@ -0,0 +1,91 @@
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	RecoverySignalFileName = "recovery.signal"
+	RecoveryConfFileName   = "recovery.conf"
+	AutoConfFileName       = "postgresql.auto.conf"
+
+	recoveryTargetTimeFormat = "2006-01-02 15:04:05.999999Z07:00"
+)
+
+// recoverySettings are the parameters to recover the fetched backup to the target
+func recoverySettings(plan *PitrPlan, target *RecoveryTarget, restoreCommand string) []string {
+	settings := []string{
+		fmt.Sprintf("restore_command = %s", quoteConfigValue(restoreCommand)),
+	}
+	switch {
+	case target.Time != nil:
+		settings = append(settings,
+			fmt.Sprintf("recovery_target_time = %s", quoteConfigValue(target.Time.Format(recoveryTargetTimeFormat))))
+	case target.LSN != nil:
+		settings = append(settings, fmt.Sprintf("recovery_target_lsn = %s", quoteConfigValue(target.LSN.String())))
+	default:
+		settings = append(settings, fmt.Sprintf("recovery_target_xid = %s", quoteConfigValue(target.Xid)))
+	}
+	// the timeline checked by the planner, "latest" could resolve to a newer one at the startup
+	settings = append(settings, fmt.Sprintf("recovery_target_timeline = '%d'", plan.Timeline))
+	if target.Action != "" {
+		settings = append(settings, fmt.Sprintf("recovery_target_action = %s", quoteConfigValue(target.Action)))
+	}
+	return settings
+}
+
+// DefaultRestoreCommand fetches the WAL with the binary and the config. PostgreSQL runs restore_command
+// in the data directory, so the relative config path is resolved against the current directory.
+func DefaultRestoreCommand(binaryPath, configPath string) (string, error) {
+	command := fmt.Sprintf("\"%s\" wal-fetch \"%%f\" \"%%p\"", binaryPath)
+	if configPath == "" {
+		return command, nil
+	}
+	configPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve the config path for restore_command")
+	}
+	return command + fmt.Sprintf(" --config \"%s\"", configPath), nil
+}
+
+func quoteConfigValue(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// WriteRecoveryConfig configures the recovery of the fetched backup for the PostgreSQL version read from pg_control:
+// recovery.conf before PostgreSQL 12, postgresql.auto.conf and recovery.signal since PostgreSQL 12
+func WriteRecoveryConfig(dataDir string, plan *PitrPlan, target *RecoveryTarget, restoreCommand string) error {
+	pgControl, err := ExtractPgControl(dataDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read pg_control of the fetched backup")
+	}
+	header := fmt.Sprintf("# recovery to %s from %s, written by wal-g backup-fetch at %s",
+		target, plan.Backup.BackupName, time.Now().UTC().Format(time.RFC3339))
+	content := strings.Join(append([]string{header}, recoverySettings(plan, target, restoreCommand)...), "\n") + "\n"
+
+	if !pgControl.UsesRecoverySignal() {
+		tracelog.InfoLogger.Printf("Writing %s", RecoveryConfFileName)
+		return os.WriteFile(filepath.Join(dataDir, RecoveryConfFileName), []byte(content), 0600)
+	}
+
+	tracelog.InfoLogger.Printf("Writing the recovery settings to %s and creating %s", AutoConfFileName, RecoverySignalFileName)
+	autoConf, err := os.OpenFile(filepath.Join(dataDir, AutoConfFileName), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = autoConf.WriteString("\n" + content); err != nil {
+		_ = autoConf.Close()
+		return err
+	}
+	if err = autoConf.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dataDir, RecoverySignalFileName), nil, 0600)
+}
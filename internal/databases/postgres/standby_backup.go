@@ -0,0 +1,254 @@
+package postgres
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/jackc/pgx"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+const standbyArchivePollInterval = time.Second
+
+// BackupSourceInfo describes the node the backup was taken from
+type BackupSourceInfo struct {
+	Hostname  string `json:"Hostname"`
+	IsStandby bool   `json:"IsStandby"`
+	// ReplayLag is the replay lag of the standby in seconds when the backup was stopped
+	ReplayLag *float64 `json:"ReplayLag,omitempty"`
+}
+
+// walArchiveChecker checks if the WAL file is archived
+type walArchiveChecker func(walFileName string) (bool, error)
+
+// backupSourceQuerier asks the server the backup is taken from about its role
+type backupSourceQuerier interface {
+	IsInRecovery() (bool, error)
+	GetReplayLag() (lag float64, ok bool, err error)
+	GetServerAddr() (string, error)
+}
+
+// collectBackupSource describes the node the backup is taken from
+func (bh *BackupHandler) collectBackupSource() *BackupSourceInfo {
+	source := &BackupSourceInfo{IsStandby: bh.Workers.Bundle.Replica}
+	source.Hostname = localHostname()
+	if source.IsStandby {
+		source.ReplayLag = getReplayLag(bh.Workers.QueryRunner)
+	}
+	return source
+}
+
+// describeRemoteBackupSource describes the server the backup is streamed from with BASE_BACKUP,
+// the server on the same host is connected through the unix socket and has no address
+func describeRemoteBackupSource(querier backupSourceQuerier) (*BackupSourceInfo, error) {
+	isStandby, err := querier.IsInRecovery()
+	if err != nil {
+		return nil, err
+	}
+	serverAddr, err := querier.GetServerAddr()
+	if err != nil {
+		return nil, err
+	}
+	source := &BackupSourceInfo{Hostname: serverAddr, IsStandby: isStandby}
+	if source.Hostname == "" {
+		source.Hostname = localHostname()
+	}
+	if source.IsStandby {
+		source.ReplayLag = getReplayLag(querier)
+	}
+	return source, nil
+}
+
+func localHostname() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to fetch the hostname for the backup source, leaving empty: %v", err)
+	}
+	return hostname
+}
+
+func getReplayLag(querier backupSourceQuerier) *float64 {
+	lag, ok, err := querier.GetReplayLag()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to get the replay lag of the standby: %v", err)
+		return nil
+	}
+	if !ok {
+		return nil
+	}
+	return &lag
+}
+
+// waitForStandbyStopSegment waits until the WAL segment with the backup stop LSN is archived. Otherwise a backup
+// from a standby might be written before the primary archived the WAL needed to restore it.
+func (bh *BackupHandler) waitForStandbyStopSegment(rootFolder storage.Folder, isStandby bool, timeline uint32) error {
+	if _, ok := internal.GetSetting(internal.PgStandbyArchiveWaitTimeout); !ok || !isStandby {
+		return nil
+	}
+	timeout, err := internal.GetDurationSetting(internal.PgStandbyArchiveWaitTimeout)
+	if err != nil || timeout == 0 {
+		return err
+	}
+	stopSegment := newWalSegmentNo(bh.CurBackupInfo.endLSN - 1).getFilename(timeline)
+
+	checker := newStorageWalArchiveChecker(rootFolder.GetSubFolder(utility.WalPath))
+	if connInfo, ok := internal.GetSetting(internal.PgStandbyPrimaryConnInfo); ok {
+		primaryConn, err := connectPrimary(connInfo)
+		if err != nil {
+			return err
+		}
+		defer utility.LoggedClose(primaryConn, "")
+		primaryQueryRunner, err := NewPgQueryRunner(primaryConn)
+		if err != nil {
+			return err
+		}
+		checker = newPrimaryWalArchiveChecker(primaryQueryRunner, checker)
+	}
+
+	tracelog.InfoLogger.Printf("Waiting up to %s for the backup stop segment %s to be archived\n", timeout, stopSegment)
+	return waitForWalArchive(checker, stopSegment, timeout)
+}
+
+// waitForRemoteStandbyStopSegment is waitForStandbyStopSegment for the backups streamed with BASE_BACKUP,
+// the server is asked whether it is a standby. The server description is recorded as the backup source.
+// It is not required unless the wait is configured, since the user may be allowed the replication connections only.
+func (bh *BackupHandler) waitForRemoteStandbyStopSegment(rootFolder storage.Folder, timeline uint32) error {
+	source, err := collectRemoteBackupSource()
+	if err != nil {
+		if _, ok := internal.GetSetting(internal.PgStandbyArchiveWaitTimeout); ok {
+			return err
+		}
+		tracelog.WarningLogger.Printf("Failed to describe the backup source: %v", err)
+		return nil
+	}
+	bh.CurBackupInfo.source = source
+	return bh.waitForStandbyStopSegment(rootFolder, source.IsStandby, timeline)
+}
+
+func collectRemoteBackupSource() (*BackupSourceInfo, error) {
+	conn, err := Connect()
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(conn, "")
+	queryRunner, err := NewPgQueryRunner(conn)
+	if err != nil {
+		return nil, err
+	}
+	return describeRemoteBackupSource(queryRunner)
+}
+
+// connectPrimary connects to the primary with the connection string, the libpq environment variables
+// are used for the parameters missing in it
+func connectPrimary(connInfo string) (*pgx.Conn, error) {
+	config, err := pgx.ParseEnvLibpq()
+	if err != nil {
+		return nil, errors.Wrap(err, "connectPrimary: unable to read environment variables")
+	}
+	primaryConfig, err := pgx.ParseConnectionString(connInfo)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connectPrimary: invalid %s", internal.PgStandbyPrimaryConnInfo)
+	}
+	conn, err := pgx.Connect(config.Merge(primaryConfig))
+	return conn, errors.Wrap(err, "connectPrimary: primary connection failed")
+}
+
+func waitForWalArchive(checker walArchiveChecker, walFileName string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		archived, err := checker(walFileName)
+		if err != nil {
+			return err
+		}
+		if archived {
+			tracelog.InfoLogger.Printf("WAL segment %s is archived\n", walFileName)
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("WAL segment %s is not archived in %s", walFileName, timeout)
+		}
+		time.Sleep(standbyArchivePollInterval)
+	}
+}
+
+// newStorageWalArchiveChecker checks if the WAL file is in the storage
+func newStorageWalArchiveChecker(walFolder storage.Folder) walArchiveChecker {
+	return func(walFileName string) (bool, error) {
+		names := []string{walFileName}
+		for _, decompressor := range compression.Decompressors {
+			names = append(names, walFileName+"."+decompressor.FileExtension())
+		}
+		for _, name := range names {
+			exists, err := walFolder.Exists(name)
+			if err != nil || exists {
+				return exists, err
+			}
+		}
+		return false, nil
+	}
+}
+
+// newPrimaryWalArchiveChecker checks if the archiver of the primary has archived the WAL file.
+// If the primary still writes the WAL segment, it is switched once, so the segment is archived
+// without waiting for archive_timeout. When the last archived segment of the primary does not prove
+// the WAL file is archived, e.g. it is of another timeline, the storage is checked.
+func newPrimaryWalArchiveChecker(primaryQueryRunner *PgQueryRunner, storageChecker walArchiveChecker) walArchiveChecker {
+	checkedSwitch := false
+	return func(walFileName string) (bool, error) {
+		lastArchivedWal, err := primaryQueryRunner.GetLastArchivedWal()
+		if err != nil {
+			return false, err
+		}
+		if isWalArchivedBy(walFileName, lastArchivedWal) {
+			return true, nil
+		}
+		if !checkedSwitch {
+			checkedSwitch = true
+			switchPrimaryWalSegment(primaryQueryRunner, walFileName)
+		}
+		return storageChecker(walFileName)
+	}
+}
+
+func switchPrimaryWalSegment(primaryQueryRunner *PgQueryRunner, walFileName string) {
+	lsnStr, err := primaryQueryRunner.getCurrentLsn()
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to get the current LSN of the primary: %v", err)
+		return
+	}
+	currentLsn, err := ParseLSN(lsnStr)
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to parse the current LSN of the primary: %v", err)
+		return
+	}
+	segmentNo, err := newWalSegmentNoFromFilename(walFileName)
+	if err != nil || newWalSegmentNo(currentLsn) != segmentNo {
+		return
+	}
+	tracelog.InfoLogger.Printf("Switching the WAL segment %s on the primary\n", walFileName)
+	if err = primaryQueryRunner.SwitchWal(); err != nil {
+		tracelog.WarningLogger.Printf("Failed to switch the WAL segment on the primary: %v", err)
+	}
+}
+
+// isWalArchivedBy checks if the WAL segment precedes or is the last archived one. The archiver
+// archives the segments of a timeline in order, so all the segments before the last archived one
+// on the same timeline are archived too. The order says nothing about the segments of other timelines.
+func isWalArchivedBy(walFileName, lastArchivedWal string) bool {
+	timeline, segmentNo, err := ParseWALFilename(walFileName)
+	if err != nil {
+		return false
+	}
+	lastTimeline, lastSegmentNo, err := ParseWALFilename(lastArchivedWal)
+	if err != nil {
+		// the .history, .partial and .backup files do not tell the archived segment
+		return false
+	}
+	return lastTimeline == timeline && lastSegmentNo >= segmentNo
+}
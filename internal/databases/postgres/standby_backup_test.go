@@ -0,0 +1,69 @@
+package postgres
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsWalArchivedBy(t *testing.T) {
+	assert.True(t, isWalArchivedBy("000000010000000000000005", "000000010000000000000005"))
+	assert.True(t, isWalArchivedBy("000000010000000000000005", "000000010000000000000006"))
+	// the segment of the old timeline may be not archived when the primary is promoted
+	assert.False(t, isWalArchivedBy("000000010000000000000005", "000000020000000000000005"))
+	assert.False(t, isWalArchivedBy("000000010000000000000005", "000000010000000000000004"))
+	assert.False(t, isWalArchivedBy("000000020000000000000005", "000000010000000000000006"))
+	assert.False(t, isWalArchivedBy("000000010000000000000005", "00000002.history"))
+	assert.False(t, isWalArchivedBy("000000010000000000000005", ""))
+}
+
+func TestWaitForWalArchive_Storage(t *testing.T) {
+	walFolder := memory.NewFolder("in_memory/", memory.NewStorage())
+	checker := newStorageWalArchiveChecker(walFolder)
+
+	err := waitForWalArchive(checker, "000000010000000000000005", 0)
+	assert.Error(t, err)
+
+	require.NoError(t, walFolder.PutObject("000000010000000000000005.lz4", &bytes.Buffer{}))
+	assert.NoError(t, waitForWalArchive(checker, "000000010000000000000005", 0))
+}
+
+type fakeBackupSourceQuerier struct {
+	isStandby  bool
+	replayLag  float64
+	serverAddr string
+}
+
+func (querier fakeBackupSourceQuerier) IsInRecovery() (bool, error) {
+	return querier.isStandby, nil
+}
+
+func (querier fakeBackupSourceQuerier) GetReplayLag() (float64, bool, error) {
+	return querier.replayLag, querier.isStandby, nil
+}
+
+func (querier fakeBackupSourceQuerier) GetServerAddr() (string, error) {
+	return querier.serverAddr, nil
+}
+
+func TestDescribeRemoteBackupSource(t *testing.T) {
+	source, err := describeRemoteBackupSource(fakeBackupSourceQuerier{isStandby: true, replayLag: 1.5, serverAddr: "10.0.0.2"})
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.2", source.Hostname)
+	assert.True(t, source.IsStandby)
+	require.NotNil(t, source.ReplayLag)
+	assert.Equal(t, 1.5, *source.ReplayLag)
+
+	// the server connected through the unix socket runs on this host
+	source, err = describeRemoteBackupSource(fakeBackupSourceQuerier{})
+	require.NoError(t, err)
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	assert.Equal(t, hostname, source.Hostname)
+	assert.False(t, source.IsStandby)
+	assert.Nil(t, source.ReplayLag)
+}
@@ -6,154 +6,183 @@ A base backup object can connect to Postgres, issue a BASE_BACKUP command, and r
 */
 
 import (
-	"context"
+	"archive/tar"
+	"bytes"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgconn"
-	"github.com/jackc/pglogrepl"
-	"github.com/jackc/pgproto3/v2"
 	"github.com/pkg/errors"
 	"github.com/wal-g/tracelog"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/gzip"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/lz4"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/zstd"
 	"github.com/apecloud/dataprotection-wal-g/internal/ioextensions"
 	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
 )
 
-var (
-	errTbsOutOfRange     = errors.New("requesting next tablespace after all tablespaces are streamed")
-	errTbsStillStreaming = errors.New("cannot move to next table. Current tablespace is not yet fully streamed")
+const (
+	// streamingManifestChecksumAlgorithm is the default checksum algorithm of the backup manifest
+	streamingManifestChecksumAlgorithm = "CRC32C"
+	backupManifestTarName              = "backup_manifest.tar"
 )
 
+// serverCompressionExtensions maps the BASE_BACKUP server-side compression methods to the decompressor extensions
+var serverCompressionExtensions = map[string]string{
+	"gzip": gzip.FileExtension,
+	"lz4":  lz4.FileExtension,
+	"zstd": zstd.FileExtension,
+}
+
 // The StreamingBaseBackup object represents a Postgres BASE_BACKUP, connecting to Postgres, and streaming backup data.
-// For every tablespace, all files are combined in a tar format and streamed in a CopyData stream.
+// Every tablespace is streamed as a tar archive, which is split into the tar files of at most maxTarSize.
 type StreamingBaseBackup struct {
 	TimeLine         uint32
-	StartLSN         pglogrepl.LSN
-	EndLSN           pglogrepl.LSN
-	buffer           []byte
-	readIndex        int
-	tablespaces      []pglogrepl.BaseBackupTablespace
-	tbsPointer       int
-	tbsStreaming     bool
-	pgConn           *pgconn.PgConn
+	StartLSN         LSN
+	EndLSN           LSN
 	UncompressedSize int64
-	maxTarSize       int64
-	dataDir          string
 	Files            internal.BackupFileList
-	uploader         internal.Uploader
-	streamer         *TarballStreamer
-	fileNo           int
+	// Manifest is the backup_manifest sent by PostgreSQL 13+
+	Manifest []byte
+
+	maxTarSize    int64
+	dataDir       string
+	pgVersion     int
+	pgConn        *pgconn.PgConn
+	options       BaseBackupOptions
+	stream        *baseBackupStream
+	endTimeline   uint32
+	increment     *streamingIncrement
+	streamedFiles *streamedFiles
+	pgControlTar  *bytes.Buffer
+	uploader      internal.Uploader
+	fileNo        int
+}
+
+// streamingIncrement is the base of the delta backup taken with BASE_BACKUP
+type streamingIncrement struct {
+	baseName         string
+	baseFiles        internal.BackupFileList
+	deltaMap         PagedFileDeltaMap
+	forceIncremental bool
+}
+
+// streamedFiles are the details of the streamed files which the tar files do not keep
+type streamedFiles struct {
+	checksums map[string]ManifestFileChecksum
+	// incremented are the sizes of the files stored as increments
+	incremented map[string]int64
+	// skipped are the modification times of the files not changed since the increment base
+	skipped map[string]time.Time
 }
 
 // NewStreamingBaseBackup will define a new StreamingBaseBackup object
-func NewStreamingBaseBackup(pgDataDir string, maxTarSize int64, pgConn *pgconn.PgConn) (bb *StreamingBaseBackup) {
+func NewStreamingBaseBackup(pgDataDir string, maxTarSize int64, pgVersion int,
+	pgConn *pgconn.PgConn) (bb *StreamingBaseBackup) {
 	bb = &StreamingBaseBackup{
 		dataDir:    pgDataDir,
 		maxTarSize: maxTarSize,
+		pgVersion:  pgVersion,
 		pgConn:     pgConn,
+		streamedFiles: &streamedFiles{
+			checksums:   make(map[string]ManifestFileChecksum),
+			incremented: make(map[string]int64),
+			skipped:     make(map[string]time.Time),
+		},
 	}
 	return
 }
 
-// Start will start a base_backup read the backup info, and prepare for uploading tar files
-func (bb *StreamingBaseBackup) Start(verifyChecksum bool, diskLimit int32) (err error) {
-	options := pglogrepl.BaseBackupOptions{
-		// Following implementation for local backup.
-		Fast:              true,
-		TablespaceMap:     true,
-		Label:             "wal-g",
-		NoVerifyChecksums: !verifyChecksum,
-		MaxRate:           diskLimit,
-	}
-	result, err := pglogrepl.StartBaseBackup(context.Background(), bb.pgConn, options)
-	if err != nil {
-		return
+// ParseServerCompression parses the server-side compression setting, e.g. lz4 or zstd:level=5
+func ParseServerCompression(setting string, pgVersion int) (method, detail string, err error) {
+	method, detail, _ = strings.Cut(setting, ":")
+	if _, ok := serverCompressionExtensions[method]; !ok {
+		return "", "", fmt.Errorf("unsupported server-side compression '%s', expected gzip, lz4 or zstd", method)
 	}
-	bb.tablespaces = result.Tablespaces
-	bb.StartLSN = result.LSN
-	bb.TimeLine = uint32(result.TimelineID)
-	bb.Files = make(internal.BackupFileList)
-	return
-}
-
-// Finish will wrap up a backup after finalizing upload.
-func (bb *StreamingBaseBackup) Finish() (err error) {
-	result, err := pglogrepl.FinishBaseBackup(context.Background(), bb.pgConn)
-	if err != nil {
-		return
+	if pgVersion < baseBackupNewSyntaxVersion {
+		return "", "", errors.New("server-side compression is supported since PostgreSQL 15")
 	}
-	bb.EndLSN = result.LSN
-	return
+	return method, detail, nil
 }
 
-// nextTbs will internally switch to the next tablespace
-func (bb *StreamingBaseBackup) nextTbs() (err error) {
-	if bb.tbsStreaming {
-		return errTbsStillStreaming
-	}
-	if bb.tbsPointer > len(bb.tablespaces) {
-		return errTbsOutOfRange
-	}
-	err = pglogrepl.NextTableSpace(context.Background(), bb.pgConn)
+// Start will start a base_backup read the backup info, and prepare for uploading tar files
+func (bb *StreamingBaseBackup) Start(options BaseBackupOptions) (err error) {
+	bb.options = options
+	bb.stream, err = startBaseBackupStream(bb.pgConn, options, bb.pgVersion)
 	if err != nil {
 		return err
 	}
-	var tee []string
-	remaps := TarballStreamerRemaps{}
-	if bb.tbsPointer == len(bb.tablespaces) {
-		tee = append(tee, "global/pg_control")
-		tracelog.InfoLogger.Printf("Adding data directory")
-	} else {
-		curTbs := bb.tablespaces[bb.tbsPointer]
-		tsr, err := NewTarballStreamerRemap("^", fmt.Sprintf("pg_tblspc/%d/", curTbs.OID))
-		if err != nil {
-			return err
-		}
-		remaps = append(remaps, *tsr)
-		tracelog.InfoLogger.Printf("Adding tablespace %d (%s)", curTbs.OID, curTbs.Location)
+	bb.StartLSN = bb.stream.StartLSN
+	bb.TimeLine = bb.stream.Timeline
+	bb.Files = make(internal.BackupFileList)
+	return nil
+}
+
+// setIncrement makes the backup a delta backup from the base backup
+func (bb *StreamingBaseBackup) setIncrement(baseName string, baseFiles internal.BackupFileList,
+	deltaMap PagedFileDeltaMap, forceIncremental bool) {
+	bb.increment = &streamingIncrement{
+		baseName:         baseName,
+		baseFiles:        baseFiles,
+		deltaMap:         deltaMap,
+		forceIncremental: forceIncremental,
 	}
-	bb.streamer.Tee = tee
-	bb.streamer.Remaps = remaps
+}
 
-	bb.tbsStreaming = true
-	bb.tbsPointer++
-	return nil
+// Finish will wrap up a backup after finalizing upload.
+func (bb *StreamingBaseBackup) Finish() (err error) {
+	bb.EndLSN, bb.endTimeline, err = bb.stream.Finish()
+	return err
 }
 
 // Upload will read all tar files from Postgres, and use the uploader to upload to the backup location
 func (bb *StreamingBaseBackup) Upload(uploader internal.Uploader, bundleFiles internal.BundleFiles) (err error) {
-	// Upload the tar
 	bb.uploader = uploader
-	bb.streamer = NewTarballStreamer(bb, bb.maxTarSize, bundleFiles)
 	for {
-		tbsTar := ioextensions.NewNamedReaderImpl(bb.streamer, bb.FileName())
-		compressedFile := internal.CompressAndEncrypt(tbsTar, bb.uploader.Compression(), internal.ConfigureCrypter())
-		dstPath := fmt.Sprintf("%s.%s", bb.Path(), bb.uploader.Compression().FileExtension())
-		err = bb.uploader.Upload(dstPath, compressedFile)
+		archive, err := bb.stream.NextArchive()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
 			return err
 		}
-		if bb.readIndex == 0 && !bb.tbsStreaming && bb.tbsPointer > len(bb.tablespaces) {
-			// No data in buffer, not streaming anymore, and no Table spaces left. We are done here...
-			break
+		if archive.isManifest {
+			tracelog.InfoLogger.Printf("Receiving the backup manifest")
+			if bb.Manifest, err = io.ReadAll(bb.stream); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = bb.uploadArchive(archive.tablespace, bundleFiles); err != nil {
+			return err
 		}
-		bb.fileNo++
 	}
 
 	// Update file info
-	bb.streamer.Files.GetUnderlyingMap().Range(func(k, v interface{}) bool {
+	bundleFiles.GetUnderlyingMap().Range(func(k, v interface{}) bool {
 		fileName := k.(string)
 		description := v.(internal.BackupFileDescription)
+		if size, ok := bb.streamedFiles.incremented[fileName]; ok {
+			description.IsIncremented = true
+			description.Size = size
+		}
 		bb.Files[fileName] = description
 		return true
 	})
+	for fileName, mTime := range bb.streamedFiles.skipped {
+		bb.Files[fileName] = internal.BackupFileDescription{IsSkipped: true, MTime: mTime}
+	}
 
 	// Upload the extra tar
-	if len(bb.streamer.Tee) > 0 {
-		teeTar := ioextensions.NewNamedReaderImpl(bb.streamer.TeeIo, bb.FileName())
+	if bb.pgControlTar != nil {
+		teeTar := ioextensions.NewNamedReaderImpl(bb.pgControlTar, bb.FileName())
 		teeCompressedFile := internal.CompressAndEncrypt(teeTar, bb.uploader.Compression(), internal.ConfigureCrypter())
 		teeFileName := fmt.Sprintf("pg_control.tar.%s", bb.uploader.Compression().FileExtension())
 		teeFilePath := storage.JoinPath(bb.BackupName(), internal.TarPartitionFolderName, teeFileName)
@@ -166,89 +195,254 @@ func (bb *StreamingBaseBackup) Upload(uploader internal.Uploader, bundleFiles in
 	return nil
 }
 
-// BackupName returns the name of the folder where the backup should be stored.
-func (bb *StreamingBaseBackup) BackupName() string {
-	return "base_" + formatWALFileName(bb.TimeLine, uint64(bb.StartLSN)/WalSegmentSize)
-}
+// uploadArchive uploads the tar archive of the tablespace split into the tar files
+func (bb *StreamingBaseBackup) uploadArchive(tablespace BaseBackupTablespace, bundleFiles internal.BundleFiles) error {
+	pathPrefix := ""
+	if tablespace.isDataDirectory() {
+		tracelog.InfoLogger.Printf("Adding data directory")
+	} else {
+		pathPrefix = fmt.Sprintf("%s/%d/", NonDefaultTablespace, tablespace.OID)
+		tracelog.InfoLogger.Printf("Adding tablespace %d (%s)", tablespace.OID, tablespace.Location)
+	}
 
-// FileName returns the filename of a tablespace backup file.
-// This is used by the WalUploader to set the name of the destination file during upload of the backup file.
-func (bb *StreamingBaseBackup) FileName() string {
-	// Example LSN -> Name:
-	// '0/2A33FE00' -> '00000001000000000000002A'
-	return fmt.Sprintf("part_%03d.tar", bb.fileNo+1)
-}
+	archiveReader := io.Reader(bb.stream)
+	if bb.options.Compression != "" {
+		decompressor := compression.FindDecompressor(serverCompressionExtensions[bb.options.Compression])
+		decompressed, err := decompressor.Decompress(bb.stream)
+		if err != nil {
+			return errors.Wrap(err, "failed to decompress the tablespace archive")
+		}
+		defer utility.LoggedClose(decompressed, "")
+		archiveReader = decompressed
+	}
 
-// Path returns the name of the folder where the backup should be stored.
-func (bb *StreamingBaseBackup) Path() string {
-	return storage.JoinPath(bb.BackupName(), internal.TarPartitionFolderName, bb.FileName())
-}
+	pipeReader, pipeWriter := io.Pipe()
+	defer utility.LoggedClose(pipeReader, "")
+	errorGroup := new(errgroup.Group)
+	errorGroup.Go(func() error {
+		err := bb.rewriteArchive(archiveReader, pipeWriter, pathPrefix)
+		_ = pipeWriter.CloseWithError(err)
+		return err
+	})
 
-// GetTablespaceSpec returns the tablespace specifications.
-func (bb *StreamingBaseBackup) GetTablespaceSpec() *TablespaceSpec {
-	spec := NewTablespaceSpec(bb.dataDir)
-	for _, tbs := range bb.tablespaces {
-		spec.addTablespace(fmt.Sprintf("%d", tbs.OID), tbs.Location)
+	streamer := NewTarballStreamer(pipeReader, bb.maxTarSize, bundleFiles)
+	if tablespace.isDataDirectory() {
+		streamer.Tee = []string{PgControlPath}
 	}
-	return &spec
+	for !streamer.InputFinished {
+		tbsTar := ioextensions.NewNamedReaderImpl(utility.NewWithSizeReader(streamer, &bb.UncompressedSize), bb.FileName())
+		compressedFile := internal.CompressAndEncrypt(tbsTar, bb.uploader.Compression(), internal.ConfigureCrypter())
+		dstPath := fmt.Sprintf("%s.%s", bb.Path(), bb.uploader.Compression().FileExtension())
+		if err := bb.uploader.Upload(dstPath, compressedFile); err != nil {
+			_ = pipeReader.CloseWithError(err)
+			_ = errorGroup.Wait()
+			return err
+		}
+		bb.fileNo++
+	}
+	// read the end of the archive after the last file
+	if _, err := io.Copy(io.Discard, pipeReader); err != nil {
+		return err
+	}
+	if err := errorGroup.Wait(); err != nil {
+		return err
+	}
+	if tablespace.isDataDirectory() {
+		bb.pgControlTar = streamer.TeeIo
+	}
+	return nil
 }
 
-// streamFromPostgres is the lower level function to receive a portion of the backup data from Postgres.
-// The data is stored in the buffer where the Read function can retrieve it.
-func (bb *StreamingBaseBackup) streamFromPostgres() (err error) {
-	var message pgproto3.BackendMessage
-	if !bb.tbsStreaming {
-		err = bb.nextTbs()
+// rewriteArchive copies the tar archive of the tablespace adding the tablespace path prefix to the names.
+// It computes the checksums of the files for the manifest validation, and for the delta backup it replaces
+// the paged files with their increments.
+func (bb *StreamingBaseBackup) rewriteArchive(input io.Reader, output io.Writer, pathPrefix string) error {
+	reader := tar.NewReader(input)
+	writer := tar.NewWriter(output)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
+			return errors.Wrap(err, "failed to read the tablespace archive")
+		}
+		filePath := pathPrefix + strings.TrimPrefix(header.Name, "./")
+		header.Name = filePath
+		if header.Typeflag != tar.TypeReg {
+			if err = writer.WriteHeader(header); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fileSize := header.Size
+		content := io.Reader(reader)
+		checksumHash := newManifestChecksumHash(streamingManifestChecksumAlgorithm)
+		if bb.options.Manifest {
+			content = io.TeeReader(reader, checksumHash)
+		}
+		if err = bb.writeArchiveFile(writer, header, filePath, content); err != nil {
+			return errors.Wrapf(err, "failed to write %s", filePath)
+		}
+		// read the rest of the file skipped by the increment to complete the checksum
+		if _, err = io.Copy(io.Discard, content); err != nil {
 			return err
 		}
+		if bb.options.Manifest {
+			bb.streamedFiles.checksums[filePath] = ManifestFileChecksum{
+				Size:      fileSize,
+				Algorithm: streamingManifestChecksumAlgorithm,
+				Checksum:  formatManifestChecksum(streamingManifestChecksumAlgorithm, checksumHash),
+			}
+		}
 	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	// read the padding after the end of the archive
+	_, err := io.Copy(io.Discard, input)
+	return err
+}
 
-	nextStandbyMessageDeadline := time.Now().Add(StandbyMessageTimeout)
-	if bb.readIndex != 0 {
-		// Protection against overwriting a partially read buffer
+// writeArchiveFile writes the file to the tar as is, as the increment or skips it for the delta backup
+func (bb *StreamingBaseBackup) writeArchiveFile(writer *tar.Writer, header *tar.Header, filePath string,
+	content io.Reader) error {
+	blocks, skip, err := bb.increment.selectBlocks(header, filePath)
+	if err != nil {
+		return err
+	}
+	if skip {
+		bb.streamedFiles.skipped[filePath] = header.ModTime
 		return nil
 	}
-	for {
-		ctx, cancel := context.WithDeadline(context.Background(), nextStandbyMessageDeadline)
-		message, err = bb.pgConn.ReceiveMessage(ctx)
-		cancel()
-		if pgconn.Timeout(err) {
+	if blocks == nil {
+		if err = writer.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err = io.Copy(writer, content)
+		return err
+	}
+
+	pageReader := &IncrementalPageReader{FileSize: header.Size, Blocks: blocks}
+	var incrementHeader bytes.Buffer
+	pageReader.WriteIncrementHeader(&incrementHeader)
+	bb.streamedFiles.incremented[filePath] = header.Size
+	header.Size = int64(incrementHeader.Len()) + int64(len(blocks))*DatabasePageSize
+	if err = writer.WriteHeader(header); err != nil {
+		return err
+	}
+	if _, err = writer.Write(incrementHeader.Bytes()); err != nil {
+		return err
+	}
+	page := make([]byte, DatabasePageSize)
+	for blockNo := uint32(0); len(blocks) > 0; blockNo++ {
+		if _, err = io.ReadFull(content, page); err != nil {
+			return err
+		}
+		if blockNo != blocks[0] {
 			continue
 		}
-		tracelog.ErrorLogger.FatalOnError(err)
-		switch msg := message.(type) {
-		case *pgproto3.CopyData:
-			bb.buffer = msg.Data
-			return nil
-		case *pgproto3.CopyDone:
-			bb.tbsStreaming = false
-			return nil
-		default:
-			return errors.Errorf("Received unexpected message: %#v\n", msg)
+		if _, err = writer.Write(page); err != nil {
+			return err
 		}
+		blocks = blocks[1:]
 	}
+	return nil
 }
 
-// Read makes the StreamingBaseBackup an io.Reader, to be handled by WalUploader.UploadWalFile written to a file.
-func (bb *StreamingBaseBackup) Read(p []byte) (n int, err error) {
-	if bb.readIndex == 0 {
-		err = bb.streamFromPostgres()
-		if err == errTbsOutOfRange {
-			// Buffer is empty, and we have streamed all tablespaces. We are done here.
-			return 0, io.EOF
-		}
-		if err != nil {
-			return
-		}
+// selectBlocks selects the changed blocks of the paged file to store in the increment.
+// It returns nil blocks if the file is stored as is, and skip if the file is not changed since the base backup.
+func (increment *streamingIncrement) selectBlocks(header *tar.Header, filePath string) (
+	blocks []uint32, skip bool, err error) {
+	if increment == nil {
+		return nil, false, nil
+	}
+	_, wasInBase := increment.baseFiles[filePath]
+	if !(wasInBase || increment.forceIncremental) || !isPagedFile(header.FileInfo(), filePath) {
+		return nil, false, nil
+	}
+	bitmap, err := increment.deltaMap.GetDeltaBitmapFor(filePath)
+	if _, ok := err.(NoBitmapFoundError); ok {
+		return nil, true, nil
 	}
-	n = copy(p, bb.buffer[bb.readIndex:])
-	bb.UncompressedSize += int64(n)
-	bb.readIndex += n
-	if bb.readIndex == len(bb.buffer) {
-		//The entire buffer is returned. Empty buffer.
-		bb.buffer = []byte{}
-		bb.readIndex = 0
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to find the delta bitmap")
 	}
-	return n, nil
+	pageReader := &IncrementalPageReader{FileSize: header.Size, Blocks: make([]uint32, 0)}
+	pageReader.DeltaBitmapInitialize(bitmap)
+	return pageReader.Blocks, false, nil
+}
+
+// ValidateManifest checks the files and the WAL range of the backup against the manifest sent by PostgreSQL
+func (bb *StreamingBaseBackup) ValidateManifest() error {
+	manifest, err := ParseBackupManifest(bb.Manifest)
+	if err != nil {
+		return err
+	}
+	return manifest.Validate(bb.streamedFiles.checksums, bb.endTimeline, bb.StartLSN, bb.EndLSN)
+}
+
+// UploadManifest uploads the backup manifest in its own tar, so it is restored into the data directory
+func (bb *StreamingBaseBackup) UploadManifest() error {
+	var manifestTar bytes.Buffer
+	writer := tar.NewWriter(&manifestTar)
+	modTime := utility.TimeNowCrossPlatformUTC()
+	err := writer.WriteHeader(&tar.Header{
+		Name:     BackupManifestFilename,
+		Typeflag: tar.TypeReg,
+		Mode:     0600,
+		Size:     int64(len(bb.Manifest)),
+		ModTime:  modTime,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = writer.Write(bb.Manifest); err != nil {
+		return err
+	}
+	if err = writer.Close(); err != nil {
+		return err
+	}
+
+	namedTar := ioextensions.NewNamedReaderImpl(&manifestTar, backupManifestTarName)
+	compressedFile := internal.CompressAndEncrypt(namedTar, bb.uploader.Compression(), internal.ConfigureCrypter())
+	dstPath := storage.JoinPath(bb.BackupName(), internal.TarPartitionFolderName,
+		fmt.Sprintf("%s.%s", backupManifestTarName, bb.uploader.Compression().FileExtension()))
+	if err = bb.uploader.Upload(dstPath, compressedFile); err != nil {
+		return err
+	}
+	bb.Files[BackupManifestFilename] = internal.BackupFileDescription{MTime: modTime, Size: int64(len(bb.Manifest))}
+	return nil
+}
+
+// BackupName returns the name of the folder where the backup should be stored.
+func (bb *StreamingBaseBackup) BackupName() string {
+	name := "base_" + FormatWALFileName(bb.TimeLine, uint64(bb.StartLSN)/WalSegmentSize)
+	if bb.increment != nil {
+		name += "_D_" + utility.StripWalFileName(bb.increment.baseName)
+	}
+	return name
+}
+
+// FileName returns the filename of a tablespace backup file.
+// This is used by the WalUploader to set the name of the destination file during upload of the backup file.
+func (bb *StreamingBaseBackup) FileName() string {
+	return fmt.Sprintf("part_%03d.tar", bb.fileNo+1)
+}
+
+// Path returns the name of the folder where the backup should be stored.
+func (bb *StreamingBaseBackup) Path() string {
+	return storage.JoinPath(bb.BackupName(), internal.TarPartitionFolderName, bb.FileName())
+}
+
+// GetTablespaceSpec returns the tablespace specifications.
+func (bb *StreamingBaseBackup) GetTablespaceSpec() *TablespaceSpec {
+	spec := NewTablespaceSpec(bb.dataDir)
+	for _, tbs := range bb.stream.Tablespaces {
+		if !tbs.isDataDirectory() {
+			spec.addTablespace(fmt.Sprintf("%d", tbs.OID), tbs.Location)
+		}
+	}
+	return &spec
 }
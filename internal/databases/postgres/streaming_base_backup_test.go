@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/walparser"
+)
+
+type testArchiveFile struct {
+	name    string
+	content []byte
+}
+
+func newTestArchive(t *testing.T, files []testArchiveFile) []byte {
+	var archive bytes.Buffer
+	writer := tar.NewWriter(&archive)
+	require.NoError(t, writer.WriteHeader(&tar.Header{Name: "./base/", Typeflag: tar.TypeDir, Mode: 0700}))
+	for _, file := range files {
+		require.NoError(t, writer.WriteHeader(&tar.Header{Name: file.name, Typeflag: tar.TypeReg, Mode: 0600,
+			Size: int64(len(file.content)), ModTime: time.Unix(1700000000, 0)}))
+		_, err := writer.Write(file.content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return archive.Bytes()
+}
+
+func newTestPages(count int) []byte {
+	pages := make([]byte, 0, int64(count)*DatabasePageSize)
+	for i := 0; i < count; i++ {
+		pages = append(pages, bytes.Repeat([]byte{byte(i + 1)}, int(DatabasePageSize))...)
+	}
+	return pages
+}
+
+func readTestArchive(t *testing.T, data []byte) map[string][]byte {
+	files := make(map[string][]byte)
+	reader := tar.NewReader(bytes.NewReader(data))
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return files
+		}
+		require.NoError(t, err)
+		content, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		files[header.Name] = content
+	}
+}
+
+func TestStreamingBaseBackupRewriteArchive(t *testing.T) {
+	files := []testArchiveFile{
+		{name: "./PG_VERSION", content: []byte("15\n")},
+		{name: "./base/1/1000", content: newTestPages(3)},
+		{name: "./base/1/1001", content: newTestPages(2)},
+		{name: "./base/1/1002", content: newTestPages(1)},
+	}
+	deltaMap := NewPagedFileDeltaMap()
+	deltaMap.AddLocationToDelta(*walparser.NewBlockLocation(DefaultSpcNode, 1, 1000, 1))
+	deltaMap.AddLocationToDelta(*walparser.NewBlockLocation(DefaultSpcNode, 1, 1002, 0))
+
+	bb := NewStreamingBaseBackup("", 0, 150000, nil)
+	bb.options.Manifest = true
+	bb.setIncrement("base_000000010000000000000002", internal.BackupFileList{
+		"base/1/1000": {}, "base/1/1001": {},
+	}, deltaMap, false)
+
+	var output bytes.Buffer
+	require.NoError(t, bb.rewriteArchive(bytes.NewReader(newTestArchive(t, files)), &output, ""))
+	rewritten := readTestArchive(t, output.Bytes())
+
+	assert.Contains(t, rewritten, "base/")
+	assert.Equal(t, []byte("15\n"), rewritten["PG_VERSION"])
+	// the file not in the base backup is stored as is
+	assert.Equal(t, newTestPages(1), rewritten["base/1/1002"])
+	// the file without changes is skipped
+	assert.NotContains(t, rewritten, "base/1/1001")
+	assert.Equal(t, map[string]time.Time{"base/1/1001": time.Unix(1700000000, 0)}, bb.streamedFiles.skipped)
+
+	increment := bytes.NewReader(rewritten["base/1/1000"])
+	fileSize, blockCount, _, err := GetIncrementHeaderFields(increment)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3*DatabasePageSize), fileSize)
+	assert.Equal(t, uint32(1), blockCount)
+	pages, err := io.ReadAll(increment)
+	require.NoError(t, err)
+	assert.Equal(t, newTestPages(3)[DatabasePageSize:2*DatabasePageSize], pages)
+	assert.Equal(t, map[string]int64{"base/1/1000": 3 * DatabasePageSize}, bb.streamedFiles.incremented)
+
+	// the checksums are computed on the original files
+	require.Len(t, bb.streamedFiles.checksums, 4)
+	assert.Equal(t, ManifestFileChecksum{Size: 2 * DatabasePageSize, Algorithm: "CRC32C",
+		Checksum: testManifestChecksum(string(newTestPages(2)))}, bb.streamedFiles.checksums["base/1/1001"])
+}
+
+func TestStreamingBaseBackupRewriteArchive_Tablespace(t *testing.T) {
+	bb := NewStreamingBaseBackup("", 0, 150000, nil)
+	files := []testArchiveFile{{name: "PG_15_202209061/5/16385", content: newTestPages(1)}}
+
+	var output bytes.Buffer
+	require.NoError(t, bb.rewriteArchive(bytes.NewReader(newTestArchive(t, files)), &output, "pg_tblspc/16384/"))
+	rewritten := readTestArchive(t, output.Bytes())
+	assert.Equal(t, newTestPages(1), rewritten["pg_tblspc/16384/PG_15_202209061/5/16385"])
+	assert.Empty(t, bb.streamedFiles.checksums)
+}
+
+func TestParseServerCompression(t *testing.T) {
+	method, detail, err := ParseServerCompression("zstd:level=5", 150000)
+	require.NoError(t, err)
+	assert.Equal(t, "zstd", method)
+	assert.Equal(t, "level=5", detail)
+
+	_, _, err = ParseServerCompression("lz4", 140000)
+	assert.Error(t, err)
+	_, _, err = ParseServerCompression("brotli", 150000)
+	assert.Error(t, err)
+}
@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"hash"
 	"io"
 	"os"
 
@@ -31,6 +32,8 @@ func (err SkippedFileError) Error() string {
 type TarBallFilePackerOptions struct {
 	verifyPageChecksums   bool
 	storeAllCorruptBlocks bool
+	// manifest collects the packed files for the backup_manifest, nil if it is not generated
+	manifest *BackupManifestBuilder
 }
 
 func NewTarBallFilePackerOptions(verifyPageChecksums, storeAllCorruptBlocks bool) TarBallFilePackerOptions {
@@ -76,6 +79,7 @@ func (p *TarBallFilePackerImpl) PackFileIntoTar(cfi *internal.ComposeFileInfo, t
 		switch err.(type) {
 		case SkippedFileError:
 			p.files.AddSkippedFile(cfi.Header, cfi.FileInfo)
+			p.addManifestFile(cfi.Header.Name, cfi.FileInfo)
 			return nil
 		case internal.FileNotExistError:
 			// File was deleted before opening.
@@ -106,9 +110,16 @@ func (p *TarBallFilePackerImpl) PackFileIntoTar(cfi *internal.ComposeFileInfo, t
 		p.files.AddFile(cfi.Header, cfi.FileInfo, cfi.IsIncremented)
 	}
 
+	// the increment is not the file restored, so its checksum is not known
+	var checksum hash.Hash
+	fileReader := io.Reader(fileReadCloser)
+	if p.options.manifest != nil && !cfi.IsIncremented {
+		checksum = p.options.manifest.NewChecksumHash()
+		fileReader = io.TeeReader(fileReadCloser, checksum)
+	}
 	errorGroup.Go(func() error {
 		defer utility.LoggedClose(fileReadCloser, "")
-		packedFileSize, err := internal.PackFileTo(tarBall, cfi.Header, fileReadCloser)
+		packedFileSize, err := internal.PackFileTo(tarBall, cfi.Header, fileReader)
 		if err != nil {
 			return errors.Wrap(err, "PackFileIntoTar: operation failed")
 		}
@@ -118,7 +129,23 @@ func (p *TarBallFilePackerImpl) PackFileIntoTar(cfi *internal.ComposeFileInfo, t
 		return nil
 	})
 
-	return errorGroup.Wait()
+	if err := errorGroup.Wait(); err != nil {
+		return err
+	}
+	if checksum != nil {
+		p.options.manifest.AddFile(cfi.Header.Name, cfi.Header.Size, cfi.FileInfo.ModTime(), checksum)
+	} else {
+		p.addManifestFile(cfi.Header.Name, cfi.FileInfo)
+	}
+	return nil
+}
+
+// addManifestFile adds the file not read from the data directory to the backup_manifest without the checksum
+func (p *TarBallFilePackerImpl) addManifestFile(name string, fileInfo os.FileInfo) {
+	if p.options.manifest == nil || !fileInfo.Mode().IsRegular() {
+		return
+	}
+	p.options.manifest.AddFile(name, fileInfo.Size(), fileInfo.ModTime(), nil)
 }
 
 func (p *TarBallFilePackerImpl) createFileReadCloser(cfi *internal.ComposeFileInfo) (io.ReadCloser, error) {
@@ -0,0 +1,63 @@
+package postgres
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/crypto"
+)
+
+type testManifestTarBall struct {
+	tarWriter *tar.Writer
+}
+
+func (tarBall *testManifestTarBall) SetUp(_ crypto.Crypter, _ ...string) {}
+func (tarBall *testManifestTarBall) CloseTar() error                     { return tarBall.tarWriter.Close() }
+func (tarBall *testManifestTarBall) Size() int64                         { return 0 }
+func (tarBall *testManifestTarBall) AddSize(int64)                       {}
+func (tarBall *testManifestTarBall) TarWriter() *tar.Writer              { return tarBall.tarWriter }
+func (tarBall *testManifestTarBall) AwaitUploads()                       {}
+func (tarBall *testManifestTarBall) Name() string                        { return "part_001.tar" }
+
+func TestPackFileIntoTar_Manifest(t *testing.T) {
+	dataDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDir, "base", "1"), 0700))
+	filePath := filepath.Join(dataDir, "base", "1", "PG_VERSION")
+	require.NoError(t, os.WriteFile(filePath, []byte("16\n"), 0600))
+	fileInfo, err := os.Stat(filePath)
+	require.NoError(t, err)
+	header, err := tar.FileInfoHeader(fileInfo, "")
+	require.NoError(t, err)
+	header.Name = "base/1/PG_VERSION"
+
+	manifest := NewBackupManifestBuilder()
+	options := NewTarBallFilePackerOptions(false, false)
+	options.manifest = manifest
+	packer := NewTarBallFilePacker(nil, nil, &internal.RegularBundleFiles{}, options)
+	tarBall := &testManifestTarBall{tarWriter: tar.NewWriter(&bytes.Buffer{})}
+	require.NoError(t, packer.PackFileIntoTar(internal.NewComposeFileInfo(filePath, fileInfo, false, false, header),
+		tarBall))
+	packer.addManifestFile("base/1/1259", fileInfo)
+
+	data, err := manifest.Build(160000, nil, 1, 0x2000028, 0x2000100)
+	require.NoError(t, err)
+	parsed, err := ParseBackupManifest(data)
+	require.NoError(t, err)
+	require.Len(t, parsed.Files, 2)
+	assert.Equal(t, "base/1/1259", parsed.Files[0].Path)
+	assert.Empty(t, parsed.Files[0].Checksum)
+	assert.Equal(t, BackupManifestFile{
+		Path:              "base/1/PG_VERSION",
+		Size:              3,
+		LastModified:      fileInfo.ModTime().UTC().Format(backupManifestTimeFormat),
+		ChecksumAlgorithm: "CRC32C",
+		Checksum:          testManifestChecksum("16\n"),
+	}, parsed.Files[1])
+}
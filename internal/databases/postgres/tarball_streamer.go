@@ -75,6 +75,8 @@ type TarballStreamer struct {
 	Remaps TarballStreamerRemaps
 	// list of processed files
 	Files internal.BundleFiles
+	// status if all the files of the input tar are read
+	InputFinished bool
 }
 
 func NewTarballStreamer(input io.Reader, maxTarSize int64, bundleFiles internal.BundleFiles) (streamer *TarballStreamer) {
@@ -107,6 +109,9 @@ func (streamer *TarballStreamer) NextInputFile() (err error) {
 
 	tracelog.DebugLogger.Printf("Next file")
 	streamer.curHeader, err = streamer.inputTar.Next()
+	if err == io.EOF {
+		streamer.InputFinished = true
+	}
 	if err != nil {
 		return err
 	}
@@ -149,7 +154,8 @@ func (streamer *TarballStreamer) addFile() (err error) {
 	if !streamer.curHeader.FileInfo().IsDir() {
 		filePath := streamer.curHeader.Name
 		filePath = strings.TrimPrefix(filePath, "./")
-		streamer.Files.AddFileDescription(filePath, internal.BackupFileDescription{MTime: streamer.curHeader.ModTime})
+		streamer.Files.AddFileDescription(filePath, internal.BackupFileDescription{MTime: streamer.curHeader.ModTime,
+			Size: streamer.curHeader.Size})
 		streamer.tarFileReadIndex += streamer.curHeader.Size
 	}
 	return nil
@@ -96,7 +96,7 @@ func getWalFilename(lsn LSN, queryRunner *PgQueryRunner) (walFilename string, ti
 	return walSegmentNo.getFilename(timeline), timeline, nil
 }
 
-func formatWALFileName(timeline uint32, logSegNo uint64) string {
+func FormatWALFileName(timeline uint32, logSegNo uint64) string {
 	return fmt.Sprintf(walFileFormat, timeline, logSegNo/xLogSegmentsPerXLogID, logSegNo%xLogSegmentsPerXLogID)
 }
 
@@ -168,7 +168,7 @@ func GetNextWalFilename(name string) (string, error) {
 		return "", err
 	}
 	logSegNo++
-	return formatWALFileName(timelineID, logSegNo), nil
+	return FormatWALFileName(timelineID, logSegNo), nil
 }
 
 func shouldPrefault(name string) (lsn LSN, shouldPrefault bool, timelineID uint32, err error) {
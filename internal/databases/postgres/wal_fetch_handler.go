@@ -45,6 +45,17 @@ func HandleWALFetch(reader internal.StorageFolderReader, walFileName string, loc
 		defer forkPrefetch(walFileName, prefetchLocation)
 	}
 
+	err := fetchWalFile(reader, walFileName, location)
+	if _, isArchNonExistErr := err.(internal.ArchiveNonExistenceError); isArchNonExistErr {
+		tracelog.ErrorLogger.Print(err.Error())
+		os.Exit(exIoError)
+	} else {
+		tracelog.ErrorLogger.FatalOnError(err)
+	}
+}
+
+// fetchWalFile moves the prefetched WAL file to the location or downloads it from the WAL folder
+func fetchWalFile(reader internal.StorageFolderReader, walFileName string, location string) error {
 	_, _, running, prefetched := getPrefetchLocations(path.Dir(location), walFileName)
 	seenSize := int64(-1)
 
@@ -58,7 +69,9 @@ func HandleWALFetch(reader internal.StorageFolderReader, walFileName string, loc
 			}
 
 			err = os.Rename(prefetched, location)
-			tracelog.ErrorLogger.FatalOnError(err)
+			if err != nil {
+				return err
+			}
 
 			err := checkWALFileMagic(location)
 			if err != nil {
@@ -67,9 +80,9 @@ func HandleWALFetch(reader internal.StorageFolderReader, walFileName string, loc
 				break
 			}
 
-			return
+			return nil
 		} else if !os.IsNotExist(err) {
-			tracelog.ErrorLogger.FatalError(err)
+			return err
 		}
 
 		// We have race condition here, if running is renamed here, but it's OK
@@ -97,13 +110,7 @@ func HandleWALFetch(reader internal.StorageFolderReader, walFileName string, loc
 		time.Sleep(2 * time.Millisecond)
 	}
 
-	err := internal.DownloadFileTo(reader, walFileName, location)
-	if _, isArchNonExistErr := err.(internal.ArchiveNonExistenceError); isArchNonExistErr {
-		tracelog.ErrorLogger.Print(err.Error())
-		os.Exit(exIoError)
-	} else {
-		tracelog.ErrorLogger.FatalOnError(err)
-	}
+	return downloadWalFileWithPartialFallback(reader, walFileName, location)
 }
 
 // TODO : unit tests
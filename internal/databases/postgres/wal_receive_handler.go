@@ -18,6 +18,9 @@ import (
 const (
 	// Sets standbyMessageTimeout in Streaming Replication Protocol.
 	StandbyMessageTimeout = time.Second * 10
+	// synchronousFlushIdleTimeout is how long the stream is idle before the received WAL is uploaded
+	// in the synchronous mode
+	synchronousFlushIdleTimeout = time.Millisecond * 10
 )
 
 /*
@@ -48,8 +51,10 @@ func (err genericWalReceiveError) Error() string {
 	return fmt.Sprintf(tracelog.GetErrorFormatter(), err.error)
 }
 
-// HandleWALReceive is invoked to receive wal with a replication connection and push
-func HandleWALReceive(uploader *WalUploader) {
+// HandleWALReceive is invoked to receive wal with a replication connection and push.
+// The WAL is reported to PostgreSQL as flushed only after it is uploaded, and the streaming
+// follows the timeline switches of the server.
+func HandleWALReceive(uploader *WalUploader, status *WalReceiveStatus) {
 	// Connect to postgres.
 	var XLogPos pglogrepl.LSN
 	var segment *WalSegment
@@ -82,29 +87,46 @@ func HandleWALReceive(uploader *WalUploader) {
 	tracelog.ErrorLogger.FatalOnError(err)
 
 	segment = NewWalSegment(timeline, XLogPos, walSegmentBytes)
+	status.start(timeline, segment.StartLSN)
 	startReplication(conn, segment, slot.Name)
 	for {
-		streamResult, err := segment.Stream(conn, StandbyMessageTimeout)
+		streamResult, err := segment.Stream(conn, StandbyMessageTimeout, status)
 		tracelog.ErrorLogger.FatalOnError(err)
-		tracelog.DebugLogger.Printf("Successfully received wal segment %s: ", segment.Name())
 
 		switch streamResult {
 		case ProcessMessageOK:
 			// segment is a regular segemnt. Write, and create a new for this timeline.
-			err = uploader.UploadWalFile(ioextensions.NewNamedReaderImpl(segment, segment.Name()))
+			tracelog.DebugLogger.Printf("Successfully received wal segment %s: ", segment.Name())
+			err = archiveReceivedWal(conn, uploader, status, segment, segment.endLSN, true)
 			tracelog.ErrorLogger.FatalOnError(err)
-			err = uploadRemoteWalMetadata(segment.Name(), uploader.Uploader)
-			tracelog.ErrorLogger.FatalOnError(err)
-			XLogPos = segment.endLSN
+			if status.uploadsPartials() {
+				// the partial may be left by the previous run too
+				err = deletePartialWal(uploader.Folder(), segment.Name())
+				tracelog.ErrorLogger.PrintOnError(err)
+			}
 			segment, err = segment.NextWalSegment()
 			tracelog.ErrorLogger.FatalOnError(err)
+		case ProcessMessageFlushRequested:
+			// the stream is idle in the synchronous mode or the partial upload is due. Write what is received so far,
+			// unless nothing was received since the previous partial upload.
+			if !status.partialAdvanced(segment.receivedLSN()) {
+				continue
+			}
+			partial := ioextensions.NewNamedReaderImpl(segment.partialReader(), segment.partialName())
+			err = archiveReceivedWal(conn, uploader, status, partial, segment.receivedLSN(), false)
+			tracelog.ErrorLogger.FatalOnError(err)
+			status.setPartialUploaded(segment.receivedLSN())
 		case ProcessMessageCopyDone:
 			// segment is a partial. Write, and create a new for the next timeline.
-			err = uploader.UploadWalFile(ioextensions.NewNamedReaderImpl(segment, segment.Name()))
-			tracelog.ErrorLogger.FatalOnError(err)
-			err = uploadRemoteWalMetadata(segment.Name(), uploader.Uploader)
+			err = archiveReceivedWal(conn, uploader, status, segment, segment.receivedLSN(), true)
 			tracelog.ErrorLogger.FatalOnError(err)
-			timeline++
+			nextTimeline := segment.copyDoneResult
+			if nextTimeline == nil || nextTimeline.Timeline == 0 {
+				tracelog.ErrorLogger.Fatalf("The server ended the streaming of the timeline %d at %s "+
+					"without the next timeline", timeline, segment.receivedLSN())
+			}
+			timeline = uint32(nextTimeline.Timeline)
+			tracelog.InfoLogger.Printf("Switching to the timeline %d at %s", timeline, nextTimeline.LSN)
 			timelinehistfile, err := pglogrepl.TimelineHistory(context.Background(), conn, int32(timeline))
 			tracelog.ErrorLogger.FatalOnError(err)
 			tlh, err := NewTimeLineHistFile(timeline, timelinehistfile.FileName, timelinehistfile.Content)
@@ -113,7 +135,9 @@ func HandleWALReceive(uploader *WalUploader) {
 			tracelog.ErrorLogger.FatalOnError(err)
 			err = uploadRemoteWalMetadata(tlh.Name(), uploader.Uploader)
 			tracelog.ErrorLogger.FatalOnError(err)
-			segment = NewWalSegment(timeline, XLogPos, walSegmentBytes)
+			// the new timeline is streamed from the start of the segment with the switch point
+			segment = NewWalSegment(timeline, nextTimeline.LSN, walSegmentBytes)
+			status.start(timeline, segment.StartLSN)
 			startReplication(conn, segment, slot.Name)
 		default:
 			tracelog.ErrorLogger.FatalOnError(errors.Errorf("Unexpected result from WalSegment.Stream() %v", streamResult))
@@ -121,6 +145,23 @@ func HandleWALReceive(uploader *WalUploader) {
 	}
 }
 
+// archiveReceivedWal uploads the received WAL file and reports the WAL up to endLSN to PostgreSQL as flushed
+func archiveReceivedWal(conn *pgconn.PgConn, uploader *WalUploader, status *WalReceiveStatus,
+	file ioextensions.NamedReader, endLSN pglogrepl.LSN, uploadMetadata bool) error {
+	err := uploader.UploadWalFile(file)
+	if err != nil {
+		return err
+	}
+	if uploadMetadata {
+		err = uploadRemoteWalMetadata(file.Name(), uploader.Uploader)
+		if err != nil {
+			return err
+		}
+	}
+	status.setArchived(endLSN, file.Name())
+	return sendStandbyStatusUpdate(conn, status)
+}
+
 func getStartTimeline(conn *pgconn.PgConn,
 	uploader *WalUploader,
 	systemTimeline uint32,
@@ -141,7 +182,7 @@ func getStartTimeline(conn *pgconn.PgConn,
 			return systemTimeline, nil
 		}
 	}
-	return 0, nil
+	return 0, err
 }
 
 func startReplication(conn *pgconn.PgConn, segment *WalSegment, slotName string) {
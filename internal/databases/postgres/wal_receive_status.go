@@ -0,0 +1,219 @@
+package postgres
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/webserver"
+	"github.com/jackc/pglogrepl"
+)
+
+const WalReceiveStatsPath = "/stats/wal_receive"
+
+type WalReceiveState string
+
+const (
+	WalReceiveStarting  WalReceiveState = "starting"
+	WalReceiveStreaming WalReceiveState = "streaming"
+)
+
+// WalReceiveReport defines the wal-receive progress report
+type WalReceiveReport struct {
+	State       WalReceiveState `json:"state"`
+	Synchronous bool            `json:"synchronous"`
+	Timeline    uint32          `json:"timeline"`
+	ReceivedLSN string          `json:"received_lsn"`
+	// ArchivedLSN is the end of the WAL uploaded to storage, it is reported to PostgreSQL as flushed
+	ArchivedLSN          string    `json:"archived_lsn"`
+	LastArchivedFile     string    `json:"last_archived_file,omitempty"`
+	LastArchivedTime     time.Time `json:"last_archived_time"`
+	LastStatusUpdateTime time.Time `json:"last_status_update_time"`
+}
+
+// WalReceiveStatus tracks the received and the archived WAL positions of wal-receive
+type WalReceiveStatus struct {
+	sync.Mutex
+	synchronous bool
+	partial     PartialWalConfig
+	state       WalReceiveState
+	timeline    uint32
+	received    pglogrepl.LSN
+	archived    pglogrepl.LSN
+
+	// unarchivedSince is when the oldest received WAL not in storage was received
+	unarchivedSince time.Time
+	// partialLSN is the end of the WAL of the current segment uploaded as .partial
+	partialLSN        pglogrepl.LSN
+	lastPartialUpload time.Time
+
+	lastArchivedFile     string
+	lastArchivedTime     time.Time
+	lastStatusUpdateTime time.Time
+}
+
+// NewWalReceiveStatus builds WalReceiveStatus, in the synchronous mode the received WAL
+// is uploaded as soon as the stream is idle so that the waiting commits are acknowledged.
+// Otherwise the incomplete segment is uploaded as set by the partial config.
+func NewWalReceiveStatus(synchronous bool, partial PartialWalConfig) *WalReceiveStatus {
+	return &WalReceiveStatus{synchronous: synchronous, partial: partial, state: WalReceiveStarting}
+}
+
+// EnableHTTPHandler registers the status handler at the given web server
+func (status *WalReceiveStatus) EnableHTTPHandler(httpPattern string, srv webserver.WebServer) {
+	srv.HandleFunc(httpPattern, status.ServeHTTP)
+}
+
+// start sets the position the streaming starts from, the WAL before it is already archived
+func (status *WalReceiveStatus) start(timeline uint32, lsn pglogrepl.LSN) {
+	status.Lock()
+	defer status.Unlock()
+	status.state = WalReceiveStreaming
+	status.timeline = timeline
+	status.received = max(status.received, lsn)
+	status.archived = max(status.archived, lsn)
+	status.partialLSN = 0
+}
+
+func (status *WalReceiveStatus) setReceived(lsn pglogrepl.LSN) {
+	status.Lock()
+	defer status.Unlock()
+	if status.received == status.archived && lsn > status.received {
+		status.unarchivedSince = time.Now()
+	}
+	status.received = max(status.received, lsn)
+}
+
+// setArchived marks the WAL up to lsn as durable in storage
+func (status *WalReceiveStatus) setArchived(lsn pglogrepl.LSN, fileName string) {
+	status.Lock()
+	defer status.Unlock()
+	status.archived = max(status.archived, lsn)
+	if status.received > status.archived {
+		status.unarchivedSince = time.Now()
+	}
+	status.lastArchivedFile = fileName
+	status.lastArchivedTime = time.Now()
+}
+
+// hasUnarchived checks if some received WAL is not uploaded yet
+func (status *WalReceiveStatus) hasUnarchived() bool {
+	status.Lock()
+	defer status.Unlock()
+	return status.received > status.archived
+}
+
+// uploadsPartials checks if the incomplete segments are uploaded as .walg.partial
+func (status *WalReceiveStatus) uploadsPartials() bool {
+	return status.synchronous || status.partial.Enabled()
+}
+
+// setPartialUploaded marks the WAL of the current segment up to lsn as uploaded as .partial
+func (status *WalReceiveStatus) setPartialUploaded(lsn pglogrepl.LSN) {
+	status.Lock()
+	defer status.Unlock()
+	status.partialLSN = max(status.partialLSN, lsn)
+	status.lastPartialUpload = time.Now()
+}
+
+// partialAdvanced checks if the WAL up to lsn was received since the previous partial upload
+func (status *WalReceiveStatus) partialAdvanced(lsn pglogrepl.LSN) bool {
+	status.Lock()
+	defer status.Unlock()
+	return lsn > status.partialLSN
+}
+
+// partialUploadDue checks if the received WAL should be uploaded before the segment is complete
+func (status *WalReceiveStatus) partialUploadDue() bool {
+	status.Lock()
+	defer status.Unlock()
+	if status.received <= status.partialLSN {
+		return false
+	}
+	return status.partial.isDue(uint64(status.received-status.archived), status.unarchivedSince, status.lastPartialUpload)
+}
+
+// flushDue checks if the received WAL should be uploaded when the stream is idle: in the synchronous mode
+// once the rate limit allows, otherwise if the partial upload is due
+func (status *WalReceiveStatus) flushDue() bool {
+	status.Lock()
+	synchronous := status.synchronous && status.received > status.archived && status.received > status.partialLSN
+	rateAllows := status.partial.rateAllows(status.lastPartialUpload)
+	status.Unlock()
+	if synchronous {
+		return rateAllows
+	}
+	return status.partialUploadDue()
+}
+
+// partialUploadDeadline returns when the received WAL should be uploaded if nothing else is received
+func (status *WalReceiveStatus) partialUploadDeadline() (time.Time, bool) {
+	status.Lock()
+	defer status.Unlock()
+	switch {
+	case status.received <= status.archived:
+		return time.Time{}, false
+	case status.synchronous:
+		return latest(time.Now().Add(synchronousFlushIdleTimeout), status.nextPartialUploadTime()), true
+	case status.partial.Interval > 0:
+		return latest(status.unarchivedSince.Add(status.partial.Interval), status.nextPartialUploadTime()), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// nextPartialUploadTime is the earliest time the rate limit allows the next partial upload at
+func (status *WalReceiveStatus) nextPartialUploadTime() time.Time {
+	return status.lastPartialUpload.Add(status.partial.MinInterval)
+}
+
+func latest(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// standbyStatusUpdate reports the archived WAL as flushed and applied,
+// so neither the slot nor the synchronous commits advance until the WAL is in storage
+func (status *WalReceiveStatus) standbyStatusUpdate() pglogrepl.StandbyStatusUpdate {
+	status.Lock()
+	defer status.Unlock()
+	status.lastStatusUpdateTime = time.Now()
+	return pglogrepl.StandbyStatusUpdate{
+		WALWritePosition: status.received,
+		WALFlushPosition: status.archived,
+		WALApplyPosition: status.archived,
+	}
+}
+
+// Report returns the current progress
+func (status *WalReceiveStatus) Report() WalReceiveReport {
+	status.Lock()
+	defer status.Unlock()
+	return WalReceiveReport{
+		State:                status.state,
+		Synchronous:          status.synchronous,
+		Timeline:             status.timeline,
+		ReceivedLSN:          status.received.String(),
+		ArchivedLSN:          status.archived.String(),
+		LastArchivedFile:     status.lastArchivedFile,
+		LastArchivedTime:     status.lastArchivedTime,
+		LastStatusUpdateTime: status.lastStatusUpdateTime,
+	}
+}
+
+// ServeHTTP implements the status http-handler
+func (status *WalReceiveStatus) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	data, err := json.Marshal(status.Report())
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(data); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
@@ -0,0 +1,98 @@
+package postgres
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackc/pglogrepl"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalReceiveStatus_FlushedOnlyAfterArchive(t *testing.T) {
+	status := NewWalReceiveStatus(true, PartialWalConfig{})
+	segment := NewWalSegment(2, pglogrepl.LSN(0x3000100), 0x1000000)
+	status.start(2, segment.StartLSN)
+	assert.False(t, status.hasUnarchived())
+
+	status.setReceived(0x3000500)
+	assert.True(t, status.hasUnarchived())
+	update := status.standbyStatusUpdate()
+	assert.Equal(t, pglogrepl.LSN(0x3000500), update.WALWritePosition)
+	assert.Equal(t, pglogrepl.LSN(0x3000000), update.WALFlushPosition)
+	assert.Equal(t, pglogrepl.LSN(0x3000000), update.WALApplyPosition)
+
+	status.setArchived(0x3000500, "000000020000000000000003.partial")
+	assert.False(t, status.hasUnarchived())
+	update = status.standbyStatusUpdate()
+	assert.Equal(t, pglogrepl.LSN(0x3000500), update.WALFlushPosition)
+
+	// the positions do not move back when the next timeline is streamed from the segment start
+	status.start(3, segment.StartLSN)
+	update = status.standbyStatusUpdate()
+	assert.Equal(t, pglogrepl.LSN(0x3000500), update.WALFlushPosition)
+}
+
+func TestWalReceiveStatus_ServeHTTP(t *testing.T) {
+	status := NewWalReceiveStatus(false, PartialWalConfig{})
+	status.start(1, 0x1000000)
+	status.setReceived(0x1800000)
+	status.setArchived(0x1800000, "000000010000000000000001.partial")
+
+	recorder := httptest.NewRecorder()
+	status.ServeHTTP(recorder, httptest.NewRequest("GET", WalReceiveStatsPath, nil))
+	var report WalReceiveReport
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &report))
+	assert.Equal(t, WalReceiveStreaming, report.State)
+	assert.Equal(t, uint32(1), report.Timeline)
+	assert.Equal(t, "0/1800000", report.ReceivedLSN)
+	assert.Equal(t, "0/1800000", report.ArchivedLSN)
+	assert.Equal(t, "000000010000000000000001.partial", report.LastArchivedFile)
+}
+
+func TestWalReceiveStatus_PartialUploadDue(t *testing.T) {
+	status := NewWalReceiveStatus(false, PartialWalConfig{Bytes: 0x1000})
+	status.start(1, 0x1000000)
+	_, ok := status.partialUploadDeadline()
+	assert.False(t, ok)
+
+	status.setReceived(0x1000800)
+	assert.False(t, status.partialUploadDue())
+	status.setReceived(0x1001000)
+	assert.True(t, status.partialUploadDue())
+	status.setArchived(0x1001000, "000000010000000000000001.partial")
+	assert.False(t, status.partialUploadDue())
+
+	status = NewWalReceiveStatus(false, PartialWalConfig{Interval: time.Hour})
+	status.start(1, 0x1000000)
+	status.setReceived(0x1000100)
+	assert.False(t, status.partialUploadDue())
+	deadline, ok := status.partialUploadDeadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Minute)
+}
+
+func TestWalReceiveStatus_SynchronousFlushDue(t *testing.T) {
+	status := NewWalReceiveStatus(true, PartialWalConfig{MinInterval: time.Hour})
+	status.start(1, 0x1000000)
+	assert.False(t, status.flushDue())
+
+	status.setReceived(0x1000100)
+	assert.True(t, status.flushDue())
+	assert.True(t, status.partialAdvanced(0x1000100))
+	status.setArchived(0x1000100, "000000010000000000000001.partial")
+	status.setPartialUploaded(0x1000100)
+	// nothing new is received since the partial upload
+	assert.False(t, status.partialAdvanced(0x1000100))
+	assert.False(t, status.flushDue())
+
+	// the next partial upload waits for MinInterval
+	status.setReceived(0x1000200)
+	assert.True(t, status.partialAdvanced(0x1000200))
+	assert.False(t, status.flushDue())
+	deadline, ok := status.partialUploadDeadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), deadline, time.Minute)
+}
@@ -78,7 +78,7 @@ func HandleWALRestore(targetPath, sourcePath string, cloudFolder storage.Folder)
 	tracelog.InfoLogger.Printf("WAL files to restore: %v", filenamesToRestore)
 	for _, walFilename := range filenamesToRestore {
 		location := utility.ResolveSymlink(path.Join(sourceWalDir, walFilename))
-		if err = internal.DownloadFileTo(internal.NewFolderReader(cloudFolder), walFilename, location); err != nil {
+		if err = downloadWalFileWithPartialFallback(internal.NewFolderReader(cloudFolder), walFilename, location); err != nil {
 			tracelog.ErrorLogger.Printf("Failed to download WAL file %v: %v\n", walFilename, err)
 		} else {
 			tracelog.InfoLogger.Printf("Successfully download WAL file %v\n", walFilename)
@@ -7,6 +7,7 @@ and writes it out using the WalUploader before reading the next wal segment.
 */
 
 import (
+	"bytes"
 	"context"
 	"io"
 	"time"
@@ -32,6 +33,8 @@ type WalSegment struct {
 	readIndex       int
 	writeIndex      int
 	lastMsg         *pgproto3.BackendMessage
+	// copyDoneResult is the next timeline and its start position sent by the server at the end of the timeline
+	copyDoneResult *pglogrepl.CopyDoneResult
 }
 
 // The ProcessMessageResult is an enum representing possible results from the methods
@@ -46,6 +49,7 @@ const (
 	ProcessMessageReplyRequested
 	ProcessMessageSegmentGap
 	ProcessMessageMismatch
+	ProcessMessageFlushRequested
 )
 
 // NewWalSegment is a helper function to declare a new WalSegment.
@@ -97,9 +101,9 @@ func (seg *WalSegment) Name() string {
 	// '0/2A33FE00' -> '00000001000000000000002A'
 	segID := uint64(seg.StartLSN) / seg.walSegmentBytes
 	if seg.isComplete() {
-		return formatWALFileName(seg.TimeLine, segID)
+		return FormatWALFileName(seg.TimeLine, segID)
 	}
-	return formatWALFileName(seg.TimeLine, segID) + ".partial"
+	return FormatWALFileName(seg.TimeLine, segID) + PartialWalSuffix
 }
 
 // processMessage is a method that processes a message from Postgres and copies its data
@@ -159,27 +163,36 @@ func (seg *WalSegment) processMessage(message pgproto3.BackendMessage) (ProcessM
 }
 
 // Stream is a helper function to retrieve messages from Postgres and have them processed by processMessage().
-func (seg *WalSegment) Stream(conn *pgconn.PgConn, standbyMessageTimeout time.Duration) (ProcessMessageResult, error) {
+// The status positions are sent in the standby status updates. It returns ProcessMessageFlushRequested
+// when the received WAL is not archived yet and the stream is idle in the synchronous mode
+// or the partial upload is due.
+func (seg *WalSegment) Stream(conn *pgconn.PgConn, standbyMessageTimeout time.Duration,
+	status *WalReceiveStatus) (ProcessMessageResult, error) {
 	// Inspired by https://github.com/jackc/pglogrepl/blob/master/example/pglogrepl_demo/main.go
 	// And https://www.postgresql.org/docs/12/protocol-replication.html
 
 	var err error
 	var msg pgproto3.BackendMessage
+	status.setReceived(seg.receivedLSN())
 	nextStandbyMessageDeadline := time.Now()
 	for {
 		if time.Now().After(nextStandbyMessageDeadline) {
-			err = pglogrepl.SendStandbyStatusUpdate(context.Background(),
-				conn,
-				pglogrepl.StandbyStatusUpdate{WALWritePosition: seg.StartLSN})
+			err = sendStandbyStatusUpdate(conn, status)
 			tracelog.ErrorLogger.FatalOnError(err)
-			tracelog.DebugLogger.Println("Sent Standby status message")
 			nextStandbyMessageDeadline = time.Now().Add(standbyMessageTimeout)
 		}
 
-		ctx, cancel := context.WithDeadline(context.Background(), nextStandbyMessageDeadline)
+		receiveDeadline := nextStandbyMessageDeadline
+		if deadline, ok := status.partialUploadDeadline(); ok && deadline.Before(receiveDeadline) {
+			receiveDeadline = deadline
+		}
+		ctx, cancel := context.WithDeadline(context.Background(), receiveDeadline)
 		msg, err = conn.ReceiveMessage(ctx)
 		cancel()
 		if pgconn.Timeout(err) {
+			if status.flushDue() {
+				return ProcessMessageFlushRequested, nil
+			}
 			continue
 		}
 		tracelog.ErrorLogger.FatalOnError(err)
@@ -187,15 +200,20 @@ func (seg *WalSegment) Stream(conn *pgconn.PgConn, standbyMessageTimeout time.Du
 		result, err := seg.processMessage(msg)
 		switch result {
 		case ProcessMessageOK:
+			status.setReceived(seg.receivedLSN())
 			if seg.isComplete() {
 				return ProcessMessageOK, nil
 			}
+			if status.partialUploadDue() {
+				return ProcessMessageFlushRequested, nil
+			}
 		case ProcessMessageUnknown:
 			return result, err
 		case ProcessMessageCopyDone:
 			cdr, err := pglogrepl.SendStandbyCopyDone(context.Background(), conn)
 			tracelog.ErrorLogger.FatalOnError(err)
 			tracelog.DebugLogger.Printf("CopyDoneResult => %v", cdr)
+			seg.copyDoneResult = cdr
 			return result, nil
 		case ProcessMessageReplyRequested:
 			if seg.isComplete() {
@@ -213,6 +231,29 @@ func (seg *WalSegment) Stream(conn *pgconn.PgConn, standbyMessageTimeout time.Du
 	}
 }
 
+func sendStandbyStatusUpdate(conn *pgconn.PgConn, status *WalReceiveStatus) error {
+	err := pglogrepl.SendStandbyStatusUpdate(context.Background(), conn, status.standbyStatusUpdate())
+	if err == nil {
+		tracelog.DebugLogger.Println("Sent Standby status message")
+	}
+	return err
+}
+
+// receivedLSN returns the end of the WAL received into this wal segment
+func (seg *WalSegment) receivedLSN() pglogrepl.LSN {
+	return seg.StartLSN + pglogrepl.LSN(seg.writeIndex)
+}
+
+// partialName returns the name the incomplete segment is uploaded with by the partial upload
+func (seg *WalSegment) partialName() string {
+	return FormatWALFileName(seg.TimeLine, uint64(seg.StartLSN)/seg.walSegmentBytes) + WalgPartialWalSuffix
+}
+
+// partialReader reads the wal segment received so far, padded with zeros as the .partial files of pg_receivewal
+func (seg *WalSegment) partialReader() io.Reader {
+	return bytes.NewReader(seg.data)
+}
+
 // isComplete is a helper function which returns true when all data is added
 func (seg *WalSegment) isComplete() bool {
 	return seg.StartLSN+pglogrepl.LSN(seg.writeIndex) >= seg.endLSN
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
@@ -120,16 +121,33 @@ func getFolderFilenames(folder storage.Folder) ([]string, error) {
 	return filenames, nil
 }
 
+// getFolderWalSegments collects the WAL segments stored in the folder without keeping the whole listing in memory
+func getFolderWalSegments(folder storage.Folder) (map[WalSegmentDescription]bool, error) {
+	walSegments := make(map[WalSegmentDescription]bool)
+	err := storage.IterateObjects(context.Background(), folder, storage.ListOptions{}, func(object storage.Object) error {
+		addSegmentFromFile(walSegments, object.GetName())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return walSegments, nil
+}
+
 func getSegmentsFromFiles(filenames []string) map[WalSegmentDescription]bool {
 	walSegments := make(map[WalSegmentDescription]bool)
 	for _, filename := range filenames {
-		baseName := utility.TrimFileExtension(filename)
-		segment, err := NewWalSegmentDescription(baseName)
-		if _, ok := err.(NotWalFilenameError); ok {
-			// non-wal segment file, skip it
-			continue
-		}
-		walSegments[segment] = true
+		addSegmentFromFile(walSegments, filename)
 	}
 	return walSegments
 }
+
+func addSegmentFromFile(walSegments map[WalSegmentDescription]bool, filename string) {
+	baseName := utility.TrimFileExtension(filename)
+	segment, err := NewWalSegmentDescription(baseName)
+	if _, ok := err.(NotWalFilenameError); ok {
+		// non-wal segment file, skip it
+		return
+	}
+	walSegments[segment] = true
+}
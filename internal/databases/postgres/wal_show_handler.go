@@ -118,10 +118,9 @@ func (seq *WalSegmentsSequence) FindMissingSegments() ([]WalSegmentDescription,
 // groups WAL segments by the timeline and shows detailed info about each timeline stored in storage
 func HandleWalShow(rootFolder storage.Folder, showBackups bool, outputWriter WalShowOutputWriter) {
 	walFolder := rootFolder.GetSubFolder(utility.WalPath)
-	filenames, err := getFolderFilenames(walFolder)
-	tracelog.ErrorLogger.FatalfOnError("Failed to get the WAL folder filenames %v\n", err)
+	walSegments, err := getFolderWalSegments(walFolder)
+	tracelog.ErrorLogger.FatalfOnError("Failed to get the WAL folder segments %v\n", err)
 
-	walSegments := getSegmentsFromFiles(filenames)
 	segmentsByTimelines := groupSegmentsByTimelines(walSegments)
 
 	timelineInfos := make([]*TimelineInfo, 0, len(segmentsByTimelines))
@@ -2,7 +2,9 @@ package postgres
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
+	"strings"
 
 	"github.com/jedib0t/go-pretty/table"
 )
@@ -12,6 +14,7 @@ type WalShowOutputType int
 const (
 	TableOutput WalShowOutputType = iota + 1
 	JSONOutput
+	DotOutput
 )
 
 // WalShowOutputWriter writes the output of wal-show command execution result
@@ -73,3 +76,75 @@ func NewWalShowOutputWriter(outputType WalShowOutputType, output io.Writer, incl
 		return &WalShowTableOutputWriter{output: output, includeBackups: includeBackups}
 	}
 }
+
+// TimelineGraphOutputWriter writes the timeline branching tree of wal-show --timeline-graph
+type TimelineGraphOutputWriter interface {
+	Write(roots []*TimelineBranch) error
+}
+
+// TimelineGraphJSONOutputWriter writes the tree in JSON, the child timelines are nested in the parents
+type TimelineGraphJSONOutputWriter struct {
+	output io.Writer
+}
+
+func (writer *TimelineGraphJSONOutputWriter) Write(roots []*TimelineBranch) error {
+	bytes, err := json.Marshal(roots)
+	if err != nil {
+		return err
+	}
+	_, err = writer.output.Write(bytes)
+	return err
+}
+
+// TimelineGraphDotOutputWriter writes the tree in the Graphviz DOT language
+type TimelineGraphDotOutputWriter struct {
+	output io.Writer
+}
+
+func (writer *TimelineGraphDotOutputWriter) Write(roots []*TimelineBranch) error {
+	var builder strings.Builder
+	builder.WriteString("digraph timelines {\n\trankdir=LR;\n\tnode [shape=box];\n")
+	var writeBranch func(branch *TimelineBranch)
+	writeBranch = func(branch *TimelineBranch) {
+		color := "black"
+		if branch.Status != TimelineOkStatus {
+			color = "red"
+		}
+		fmt.Fprintf(&builder, "\ttl%d [label=%q, color=%s];\n", branch.ID, timelineBranchLabel(branch), color)
+		for _, child := range branch.Branches {
+			writeBranch(child)
+			fmt.Fprintf(&builder, "\ttl%d -> tl%d [label=%q];\n", branch.ID, child.ID, child.ForkLsn.String())
+		}
+	}
+	for _, root := range roots {
+		writeBranch(root)
+	}
+	builder.WriteString("}\n")
+	_, err := io.WriteString(writer.output, builder.String())
+	return err
+}
+
+func timelineBranchLabel(branch *TimelineBranch) string {
+	lines := []string{fmt.Sprintf("TLI %d (%s)", branch.ID, branch.Status)}
+	if branch.SegmentsCount > 0 {
+		lines = append(lines, fmt.Sprintf("%s - %s", branch.StartSegment, branch.EndSegment),
+			fmt.Sprintf("segments: %d", branch.SegmentsCount))
+	}
+	for _, gap := range branch.Gaps {
+		lines = append(lines, fmt.Sprintf("gap: %s - %s", gap.StartSegment, gap.EndSegment))
+	}
+	for _, backup := range branch.Backups {
+		lines = append(lines, "backup: "+backup.BackupName)
+	}
+	if len(branch.RestorableFrom) > 0 {
+		lines = append(lines, "PITR from: "+strings.Join(branch.RestorableFrom, ", "))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func NewTimelineGraphOutputWriter(outputType WalShowOutputType, output io.Writer) TimelineGraphOutputWriter {
+	if outputType == DotOutput {
+		return &TimelineGraphDotOutputWriter{output: output}
+	}
+	return &TimelineGraphJSONOutputWriter{output: output}
+}
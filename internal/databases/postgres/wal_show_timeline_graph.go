@@ -0,0 +1,237 @@
+package postgres
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/wal-g/tracelog"
+)
+
+const TimelineNoSegmentsStatus = "NO_SEGMENTS"
+
+// TimelineBranch is a timeline in the branching tree built from the .history files
+type TimelineBranch struct {
+	ID       uint32 `json:"id"`
+	ParentID uint32 `json:"parent_id"`
+	// ForkLsn is the LSN where the timeline branched off the parent
+	ForkLsn       LSN              `json:"fork_lsn"`
+	StartSegment  string           `json:"start_segment,omitempty"`
+	EndSegment    string           `json:"end_segment,omitempty"`
+	SegmentsCount int              `json:"segments_count"`
+	Gaps          []WalSegmentsGap `json:"gaps,omitempty"`
+	Backups       []*BackupDetail  `json:"backups,omitempty"`
+	// RestorableFrom are the backups the WAL is continuous from up to the end of the timeline
+	RestorableFrom []string          `json:"restorable_from,omitempty"`
+	Status         string            `json:"status"`
+	Branches       []*TimelineBranch `json:"branches,omitempty"`
+
+	path        *timelinePath
+	startNo     WalSegmentNo
+	endNo       WalSegmentNo
+	hasSegments bool
+}
+
+// MarshalJSON writes the fork LSN in the X/X form PostgreSQL shows it in
+func (branch TimelineBranch) MarshalJSON() ([]byte, error) {
+	type branchFields TimelineBranch
+	return json.Marshal(struct {
+		branchFields
+		ForkLsn string `json:"fork_lsn"`
+	}{branchFields(branch), branch.ForkLsn.String()})
+}
+
+// WalSegmentsGap is the range of the WAL segments missing in storage
+type WalSegmentsGap struct {
+	StartSegment  string `json:"start_segment"`
+	EndSegment    string `json:"end_segment"`
+	SegmentsCount uint64 `json:"segments_count"`
+}
+
+// HandleWalShowTimelineGraph shows the timeline branching tree with the WAL gaps and the backups on each timeline
+func HandleWalShowTimelineGraph(rootFolder storage.Folder, showBackups bool, outputWriter TimelineGraphOutputWriter) {
+	roots, err := BuildTimelineGraph(rootFolder, showBackups)
+	tracelog.ErrorLogger.FatalfOnError("Failed to build the timeline graph: %v\n", err)
+
+	err = outputWriter.Write(roots)
+	tracelog.ErrorLogger.FatalfOnError("Error writing output: %v\n", err)
+}
+
+// BuildTimelineGraph builds the timeline branching tree and returns its root timelines
+func BuildTimelineGraph(rootFolder storage.Folder, showBackups bool) ([]*TimelineBranch, error) {
+	walFolder := rootFolder.GetSubFolder(utility.WalPath)
+	archive, err := ListWalArchive(walFolder)
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make(map[uint32]*TimelineBranch)
+	getBranch := func(timeline uint32) *TimelineBranch {
+		if branch, ok := branches[timeline]; ok {
+			return branch
+		}
+		branch := &TimelineBranch{ID: timeline, path: &timelinePath{timeline: timeline}}
+		branches[timeline] = branch
+		return branch
+	}
+	for segment := range archive.segments {
+		branch := getBranch(segment.Timeline)
+		if !branch.hasSegments || segment.Number < branch.startNo {
+			branch.startNo = segment.Number
+		}
+		if !branch.hasSegments || segment.Number > branch.endNo {
+			branch.endNo = segment.Number
+		}
+		branch.hasSegments = true
+		branch.SegmentsCount++
+	}
+	for timeline := range archive.historyTimelines {
+		path, err := loadTimelinePath(timeline, walFolder)
+		if err != nil {
+			return nil, err
+		}
+		branch := getBranch(timeline)
+		branch.path = path
+		// the records are sorted by LSN, so the last one is the switch from the parent
+		if len(path.records) > 0 {
+			parentRecord := path.records[len(path.records)-1]
+			branch.ParentID = parentRecord.timeline
+			branch.ForkLsn = parentRecord.lsn
+		}
+		for _, record := range path.records {
+			getBranch(record.timeline)
+		}
+	}
+
+	for _, branch := range branches {
+		branch.setSegments(archive)
+	}
+	if showBackups {
+		if err = addTimelineBackups(branches, archive, rootFolder); err != nil {
+			return nil, err
+		}
+	}
+	return linkTimelineBranches(branches), nil
+}
+
+// setSegments sets the segment range and the gaps of the timeline. The timeline continues the parent
+// from the segment with the fork LSN, so the segments from it to the first archived one are missing too.
+func (branch *TimelineBranch) setSegments(archive *WalArchive) {
+	if !branch.hasSegments {
+		branch.Status = TimelineNoSegmentsStatus
+		return
+	}
+	if branch.ParentID != 0 {
+		branch.startNo = min(branch.startNo, newWalSegmentNo(branch.ForkLsn))
+	}
+	branch.StartSegment = branch.startNo.getFilename(branch.ID)
+	branch.EndSegment = branch.endNo.getFilename(branch.ID)
+
+	var gap *WalSegmentsGap
+	for segmentNo := branch.startNo; segmentNo <= branch.endNo; segmentNo = segmentNo.next() {
+		if _, ok := archive.segments[WalSegmentDescription{Number: segmentNo, Timeline: branch.ID}]; ok {
+			gap = nil
+			continue
+		}
+		if gap == nil {
+			branch.Gaps = append(branch.Gaps, WalSegmentsGap{StartSegment: segmentNo.getFilename(branch.ID)})
+			gap = &branch.Gaps[len(branch.Gaps)-1]
+		}
+		gap.EndSegment = segmentNo.getFilename(branch.ID)
+		gap.SegmentsCount++
+	}
+
+	branch.Status = TimelineOkStatus
+	if len(branch.Gaps) > 0 {
+		branch.Status = TimelineLostSegmentStatus
+	}
+}
+
+// addTimelineBackups adds the backups taken on each timeline and finds the backups
+// each timeline can be restored from up to its last archived segment
+func addTimelineBackups(branches map[uint32]*TimelineBranch, archive *WalArchive, rootFolder storage.Folder) error {
+	baseBackupFolder := rootFolder.GetSubFolder(utility.BaseBackupPath)
+	backupTimes, err := internal.GetBackups(baseBackupFolder)
+	if err != nil {
+		if _, ok := err.(internal.NoBackupsFoundError); ok {
+			tracelog.InfoLogger.Println("No backups found in storage.")
+			return nil
+		}
+		return err
+	}
+	backups, err := GetBackupsDetails(baseBackupFolder, backupTimes)
+	if err != nil {
+		return err
+	}
+
+	backupTimelines := make([]uint32, len(backups))
+	backupStartNos := make([]WalSegmentNo, len(backups))
+	for i := range backups {
+		var startNo uint64
+		backupTimelines[i], startNo, err = ParseWALFilename(backups[i].WalFileName)
+		if err != nil {
+			return err
+		}
+		backupStartNos[i] = WalSegmentNo(startNo)
+		if branch, ok := branches[backupTimelines[i]]; ok {
+			branch.Backups = append(branch.Backups, &backups[i])
+		}
+	}
+
+	for _, branch := range branches {
+		if !branch.hasSegments {
+			continue
+		}
+		continuousFrom := findContinuousWalStart(branch, archive)
+		for i := range backups {
+			backup := &backups[i]
+			if !branch.path.contains(backupTimelines[i], backup) {
+				continue
+			}
+			if backupStartNos[i] >= continuousFrom && newWalSegmentNo(backup.FinishLsn) <= branch.endNo {
+				branch.RestorableFrom = append(branch.RestorableFrom, backup.BackupName)
+			}
+		}
+	}
+	return nil
+}
+
+// findContinuousWalStart finds the first segment of the range ending at the last segment of the timeline
+// with no missing segments on the timeline and its ancestors
+func findContinuousWalStart(branch *TimelineBranch, archive *WalArchive) WalSegmentNo {
+	segmentNo := branch.endNo
+	for segmentNo > 0 {
+		previousNo := segmentNo - 1
+		previous := WalSegmentDescription{Number: previousNo, Timeline: branch.path.segmentTimeline(previousNo)}
+		if _, ok := archive.segments[previous]; !ok {
+			break
+		}
+		segmentNo = previousNo
+	}
+	return segmentNo
+}
+
+// linkTimelineBranches links the timelines to their parents and returns the roots ordered by ID
+func linkTimelineBranches(branches map[uint32]*TimelineBranch) []*TimelineBranch {
+	ids := make([]uint32, 0, len(branches))
+	for id := range branches {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+
+	roots := make([]*TimelineBranch, 0)
+	for _, id := range ids {
+		branch := branches[id]
+		parent, ok := branches[branch.ParentID]
+		if branch.ParentID == 0 || !ok {
+			roots = append(roots, branch)
+			continue
+		}
+		parent.Branches = append(parent.Branches, branch)
+	}
+	return roots
+}
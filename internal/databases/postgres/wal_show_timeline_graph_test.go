@@ -0,0 +1,110 @@
+package postgres_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/databases/postgres"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildTimelineGraph(t *testing.T) {
+	roots, err := postgres.BuildTimelineGraph(setupPitrTestStorage(t), true)
+	require.NoError(t, err)
+
+	require.Len(t, roots, 1)
+	parent := roots[0]
+	assert.Equal(t, uint32(5), parent.ID)
+	assert.Equal(t, "000000050000000000000001", parent.StartSegment)
+	assert.Equal(t, "000000050000000000000006", parent.EndSegment)
+	assert.Equal(t, postgres.TimelineOkStatus, parent.Status)
+	assert.Empty(t, parent.Gaps)
+	assert.Len(t, parent.Backups, 3)
+	assert.ElementsMatch(t, []string{"base_000000050000000000000002", "base_000000050000000000000004",
+		"base_000000050000000000000006"}, parent.RestorableFrom)
+
+	require.Len(t, parent.Branches, 1)
+	child := parent.Branches[0]
+	assert.Equal(t, uint32(6), child.ID)
+	assert.Equal(t, uint32(5), child.ParentID)
+	assert.Equal(t, postgres.LSN(5*postgres.WalSegmentSize+100), child.ForkLsn)
+	assert.Equal(t, "000000060000000000000005", child.StartSegment)
+	assert.Equal(t, "000000060000000000000009", child.EndSegment)
+	assert.Equal(t, 5, child.SegmentsCount)
+	assert.Len(t, child.Backups, 1)
+	// the backup finished on the timeline 5 after the switch can not be used for the timeline 6
+	assert.ElementsMatch(t, []string{"base_000000050000000000000002", "base_000000050000000000000004",
+		"base_000000060000000000000007"}, child.RestorableFrom)
+}
+
+func TestBuildTimelineGraph_Gaps(t *testing.T) {
+	rootFolder := setupPitrTestStorage(t, "000000050000000000000003",
+		"000000060000000000000005", "000000060000000000000006")
+	roots, err := postgres.BuildTimelineGraph(rootFolder, true)
+	require.NoError(t, err)
+
+	require.Len(t, roots, 1)
+	parent := roots[0]
+	assert.Equal(t, postgres.TimelineLostSegmentStatus, parent.Status)
+	assert.Equal(t, []postgres.WalSegmentsGap{{
+		StartSegment:  "000000050000000000000003",
+		EndSegment:    "000000050000000000000003",
+		SegmentsCount: 1,
+	}}, parent.Gaps)
+	assert.ElementsMatch(t, []string{"base_000000050000000000000004", "base_000000050000000000000006"},
+		parent.RestorableFrom)
+
+	require.Len(t, parent.Branches, 1)
+	child := parent.Branches[0]
+	// the timeline starts at the fork segment, even though the first archived segment is later
+	assert.Equal(t, "000000060000000000000005", child.StartSegment)
+	assert.Equal(t, postgres.TimelineLostSegmentStatus, child.Status)
+	assert.Equal(t, []postgres.WalSegmentsGap{{
+		StartSegment:  "000000060000000000000005",
+		EndSegment:    "000000060000000000000006",
+		SegmentsCount: 2,
+	}}, child.Gaps)
+	assert.Equal(t, []string{"base_000000060000000000000007"}, child.RestorableFrom)
+}
+
+func TestBuildTimelineGraph_TimelineWithoutSegments(t *testing.T) {
+	rootFolder := setupPitrTestStorage(t)
+	historyName, historyFile, err := newTimelineHistoryFile(
+		fmt.Sprintf("5\t0/%X\tno recovery target specified\n", 3*postgres.WalSegmentSize), 7)
+	require.NoError(t, err)
+	require.NoError(t, rootFolder.PutObject(utility.WalPath+historyName, historyFile))
+
+	roots, err := postgres.BuildTimelineGraph(rootFolder, false)
+	require.NoError(t, err)
+
+	require.Len(t, roots, 1)
+	require.Len(t, roots[0].Branches, 2)
+	branch := roots[0].Branches[1]
+	assert.Equal(t, uint32(7), branch.ID)
+	assert.Equal(t, postgres.TimelineNoSegmentsStatus, branch.Status)
+	assert.Empty(t, branch.Backups)
+	assert.Empty(t, branch.RestorableFrom)
+}
+
+func TestTimelineGraphOutputWriters(t *testing.T) {
+	roots, err := postgres.BuildTimelineGraph(setupPitrTestStorage(t), false)
+	require.NoError(t, err)
+
+	var jsonOutput bytes.Buffer
+	require.NoError(t, postgres.NewTimelineGraphOutputWriter(postgres.JSONOutput, &jsonOutput).Write(roots))
+	var decoded []map[string]interface{}
+	require.NoError(t, json.Unmarshal(jsonOutput.Bytes(), &decoded))
+	require.Len(t, decoded, 1)
+	require.Len(t, decoded[0]["branches"], 1)
+	assert.Equal(t, "0/5000064", decoded[0]["branches"].([]interface{})[0].(map[string]interface{})["fork_lsn"])
+
+	var dotOutput bytes.Buffer
+	require.NoError(t, postgres.NewTimelineGraphOutputWriter(postgres.DotOutput, &dotOutput).Write(roots))
+	assert.Contains(t, dotOutput.String(), "digraph timelines {")
+	assert.Contains(t, dotOutput.String(), "tl5 -> tl6 [label=\"0/5000064\"];")
+	assert.Contains(t, dotOutput.String(), "tl6 [label=\"TLI 6 (OK)\\n000000060000000000000005 - 000000060000000000000009")
+}
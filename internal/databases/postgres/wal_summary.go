@@ -0,0 +1,118 @@
+package postgres
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/pkg/errors"
+	"github.com/wal-g/tracelog"
+)
+
+const (
+	// walSummaryWaitTimeout is how long the backup waits for the WAL summarizer to reach the backup start
+	walSummaryWaitTimeout  = time.Minute
+	walSummaryPollInterval = 100 * time.Millisecond
+)
+
+// WalSummary is a WAL summary file of PostgreSQL 17+, it lists the blocks modified by the WAL in [StartLSN, EndLSN)
+type WalSummary struct {
+	Timeline uint32
+	StartLSN LSN
+	EndLSN   LSN
+}
+
+// useWalSummaries checks if the delta map may be built from the WAL summaries
+func useWalSummaries(queryRunner *PgQueryRunner) (bool, error) {
+	useSummaries, err := internal.GetBoolSettingDefault(internal.PgUseWalSummaries, true)
+	if err != nil || !useSummaries || queryRunner.Version < 170000 {
+		return false, err
+	}
+	summarizeWal, err := queryRunner.GetParameter("summarize_wal")
+	if err != nil {
+		return false, err
+	}
+	return summarizeWal == "on", nil
+}
+
+// getWalSummaryDeltaMap builds the delta map of the changes in [firstUsedLSN, firstNotUsedLSN) from the WAL summaries
+func getWalSummaryDeltaMap(queryRunner *PgQueryRunner,
+	timeline uint32,
+	firstUsedLSN,
+	firstNotUsedLSN LSN) (PagedFileDeltaMap, error) {
+	tracelog.InfoLogger.Printf("Building delta map from WAL summaries, Timeline: %d, FirstUsedLsn: %s, FirstNotUsedLsn: %s\n",
+		timeline, firstUsedLSN, firstNotUsedLSN)
+	err := waitForWalSummarization(queryRunner, firstNotUsedLSN)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := queryRunner.GetWalSummaries()
+	if err != nil {
+		return nil, err
+	}
+	summaries, err = selectWalSummaries(summaries, timeline, firstUsedLSN, firstNotUsedLSN)
+	if err != nil {
+		return nil, err
+	}
+
+	deltaMap := NewPagedFileDeltaMap()
+	for _, summary := range summaries {
+		err = queryRunner.ReadWalSummaryContents(summary, deltaMap)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Error during reading WAL summary %d %s-%s",
+				summary.Timeline, summary.StartLSN, summary.EndLSN)
+		}
+	}
+	tracelog.InfoLogger.Printf("Successfully read %d WAL summaries\n", len(summaries))
+	return deltaMap, nil
+}
+
+// waitForWalSummarization waits until the WAL summarizer reaches lsn
+func waitForWalSummarization(queryRunner *PgQueryRunner, lsn LSN) error {
+	deadline := time.Now().Add(walSummaryWaitTimeout)
+	for {
+		summarizedLSN, err := queryRunner.GetWalSummarizedLSN()
+		if err != nil {
+			return err
+		}
+		if summarizedLSN >= lsn {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("WAL is summarized up to %s only, %s is not reached in %s",
+				summarizedLSN, lsn, walSummaryWaitTimeout)
+		}
+		time.Sleep(walSummaryPollInterval)
+	}
+}
+
+// selectWalSummaries selects the summaries of the timeline which cover [firstUsedLSN, firstNotUsedLSN) without gaps
+func selectWalSummaries(summaries []WalSummary,
+	timeline uint32,
+	firstUsedLSN,
+	firstNotUsedLSN LSN) ([]WalSummary, error) {
+	selected := make([]WalSummary, 0)
+	for _, summary := range summaries {
+		if summary.Timeline == timeline && summary.EndLSN > firstUsedLSN && summary.StartLSN < firstNotUsedLSN {
+			selected = append(selected, summary)
+		}
+	}
+	slices.SortFunc(selected, func(a, b WalSummary) int {
+		return cmp.Compare(a.StartLSN, b.StartLSN)
+	})
+
+	covered := firstUsedLSN
+	for _, summary := range selected {
+		if summary.StartLSN > covered {
+			break
+		}
+		covered = max(covered, summary.EndLSN)
+	}
+	if covered < firstNotUsedLSN {
+		return nil, fmt.Errorf("WAL summaries of the timeline %d do not cover the WAL from %s to %s",
+			timeline, covered, firstNotUsedLSN)
+	}
+	return selected, nil
+}
@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectWalSummaries(t *testing.T) {
+	summaries := []WalSummary{
+		{Timeline: 1, StartLSN: 0x3000000, EndLSN: 0x4000028},
+		{Timeline: 1, StartLSN: 0x1000028, EndLSN: 0x2000100},
+		{Timeline: 1, StartLSN: 0x2000100, EndLSN: 0x3000000},
+		{Timeline: 2, StartLSN: 0x2000100, EndLSN: 0x5000000},
+		{Timeline: 1, StartLSN: 0x4000028, EndLSN: 0x5000000},
+	}
+
+	selected, err := selectWalSummaries(summaries, 1, 0x2000000, 0x4000028)
+	require.NoError(t, err)
+	assert.Equal(t, []WalSummary{summaries[1], summaries[2], summaries[0]}, selected)
+
+	// the summaries of the other timelines are not used
+	_, err = selectWalSummaries(summaries, 2, 0x2000000, 0x4000028)
+	assert.ErrorContains(t, err, "WAL summaries of the timeline 2 do not cover the WAL from 0/2000000 to 0/4000028")
+
+	// the summary 0/3000000-0/4000028 is removed
+	_, err = selectWalSummaries(append(summaries[1:3:3], summaries[4]), 1, 0x2000000, 0x4000100)
+	assert.ErrorContains(t, err, "do not cover the WAL from 0/3000000 to 0/4000100")
+}
@@ -20,6 +20,8 @@ type Backup struct {
 	Permanent       bool        `json:"Permanent"`
 	DataSize        int64       `json:"DataSize,omitempty"`
 	BackupSize      int64       `json:"BackupSize,omitempty"`
+	// StreamCompression maps the backup streams to the algorithms chosen by the adaptive compression
+	StreamCompression map[string]string `json:"StreamCompression,omitempty"`
 }
 
 func (b Backup) Name() string {
@@ -149,6 +151,7 @@ func (su *StorageUploader) UploadBackup(stream io.Reader, cmd internal.ErrWaiter
 	backup.BackupSize = uploadedSize
 	backup.BackupName = dstPath
 	backup.DataSize = rawSize
+	backup.StreamCompression = internal.StreamCompressions(su.Uploader)
 	if err := internal.UploadSentinel(su, backupSentinelInfo, dstPath); err != nil {
 		return fmt.Errorf("can not upload sentinel: %+v", err)
 	}
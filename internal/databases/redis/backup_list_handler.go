@@ -14,6 +14,12 @@ import (
 	"github.com/wal-g/tracelog"
 )
 
+// BackupDetail is the backup sentinel with the result of the last backup-verify restore test
+type BackupDetail struct {
+	archive.Backup
+	Verification *internal.BackupVerification `json:"Verification,omitempty"`
+}
+
 // TODO : unit tests
 func HandleDetailedBackupList(folder storage.Folder, pretty bool, json bool) {
 	backups, err := internal.GetBackups(folder)
@@ -38,8 +44,8 @@ func HandleDetailedBackupList(folder storage.Folder, pretty bool, json bool) {
 	tracelog.ErrorLogger.FatalOnError(err)
 }
 
-func GetBackupsDetails(folder storage.Folder, backups []internal.BackupTime) ([]archive.Backup, error) {
-	backupsDetails := make([]archive.Backup, 0, len(backups))
+func GetBackupsDetails(folder storage.Folder, backups []internal.BackupTime) ([]BackupDetail, error) {
+	backupsDetails := make([]BackupDetail, 0, len(backups))
 	for i := len(backups) - 1; i >= 0; i-- {
 		details, err := GetBackupDetails(folder, backups[i])
 		if err != nil {
@@ -50,28 +56,32 @@ func GetBackupsDetails(folder storage.Folder, backups []internal.BackupTime) ([]
 	return backupsDetails, nil
 }
 
-func GetBackupDetails(folder storage.Folder, backupTime internal.BackupTime) (archive.Backup, error) {
+func GetBackupDetails(folder storage.Folder, backupTime internal.BackupTime) (BackupDetail, error) {
 	backup := internal.NewBackup(folder, backupTime.BackupName)
 
 	metaData := archive.Backup{}
 	err := backup.FetchSentinel(&metaData)
 	if err != nil {
-		return archive.Backup{}, err
+		return BackupDetail{}, err
+	}
+	verification, err := internal.FetchBackupVerification(folder, backupTime.BackupName)
+	if err != nil {
+		return BackupDetail{}, err
 	}
-	return metaData, nil
+	return BackupDetail{Backup: metaData, Verification: verification}, nil
 }
 
-func writeBackupListDetails(backupDetails []archive.Backup, output io.Writer) error {
+func writeBackupListDetails(backupDetails []BackupDetail, output io.Writer) error {
 	writer := tabwriter.NewWriter(output, 0, 0, 1, ' ', 0)
 	defer func() { _ = writer.Flush() }()
-	_, err := fmt.Fprintln(writer, "name\tstart_time\tfinish_time\tuser_data\tdata_size\tbackup_size\tpermanent") //nolint:lll
+	_, err := fmt.Fprintln(writer, "name\tstart_time\tfinish_time\tuser_data\tdata_size\tbackup_size\tpermanent\tverification") //nolint:lll
 	if err != nil {
 		return err
 	}
 	for i, count := 0, len(backupDetails); i < count; i++ {
 		b := backupDetails[i]
-		_, err = fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
-			b.BackupName, b.StartLocalTime.Format(time.RFC3339), b.FinishLocalTime.Format(time.RFC3339), b.UserData, b.DataSize, b.BackupSize, b.Permanent) //nolint:lll
+		_, err = fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n",
+			b.BackupName, b.StartLocalTime.Format(time.RFC3339), b.FinishLocalTime.Format(time.RFC3339), b.UserData, b.DataSize, b.BackupSize, b.Permanent, b.Verification) //nolint:lll
 		if err != nil {
 			return err
 		}
@@ -80,13 +90,13 @@ func writeBackupListDetails(backupDetails []archive.Backup, output io.Writer) er
 }
 
 // TODO : unit tests
-func writePrettyBackupListDetails(backupDetails []archive.Backup, output io.Writer) {
+func writePrettyBackupListDetails(backupDetails []BackupDetail, output io.Writer) {
 	writer := table.NewWriter()
 	writer.SetOutputMirror(output)
 	defer writer.Render()
-	writer.AppendHeader(table.Row{"#", "Name", "Start time", "Finish time", "UserData", "Data size", "Backup size", "Permanent"}) //nolint:lll
+	writer.AppendHeader(table.Row{"#", "Name", "Start time", "Finish time", "UserData", "Data size", "Backup size", "Permanent", "Verification"}) //nolint:lll
 	for idx := range backupDetails {
 		b := &backupDetails[idx]
-		writer.AppendRow(table.Row{idx + 1, b.BackupName, b.StartLocalTime.Format(time.RFC850), b.FinishLocalTime.Format(time.RFC850), b.UserData, b.DataSize, b.BackupSize, b.Permanent}) //nolint:lll
+		writer.AppendRow(table.Row{idx + 1, b.BackupName, b.StartLocalTime.Format(time.RFC850), b.FinishLocalTime.Format(time.RFC850), b.UserData, b.DataSize, b.BackupSize, b.Permanent, b.Verification}) //nolint:lll
 	}
 }
@@ -3,72 +3,101 @@ package redis
 import (
 	"bytes"
 	"testing"
+	"time"
 
+	"github.com/apecloud/dataprotection-wal-g/internal"
 	"github.com/apecloud/dataprotection-wal-g/internal/databases/redis/archive"
 	"github.com/stretchr/testify/assert"
 )
 
-var emptyColumnsBackups = []archive.Backup{
+var emptyColumnsBackups = []BackupDetail{
 	{
-		BackupName: "backupName",
+		Backup: archive.Backup{
+			BackupName: "backupName",
+		},
 	},
 	{
-		UserData: "userData",
+		Backup: archive.Backup{
+			UserData: "userData",
+		},
 	},
 }
 
-var shortValuesBackups = []archive.Backup{
+var shortValuesBackups = []BackupDetail{
 	{
-		BackupName: "b1",
-		UserData:   "u1",
+		Backup: archive.Backup{
+			BackupName: "b1",
+			UserData:   "u1",
+		},
 	},
 	{
-		BackupName: "b2",
-		UserData:   "u2",
+		Backup: archive.Backup{
+			BackupName: "b2",
+			UserData:   "u2",
+		},
 	},
 }
 
-var longValuesBackups = []archive.Backup{
+var longValuesBackups = []BackupDetail{
 	{
-		BackupName: "veryLongBackupName1",
-		UserData:   "someLongUsefulUserData1",
+		Backup: archive.Backup{
+			BackupName: "veryLongBackupName1",
+			UserData:   "someLongUsefulUserData1",
+		},
 	},
 	{
-		BackupName: "veryLongBackupName2",
-		UserData:   "someLongUsefulUserData2",
+		Backup: archive.Backup{
+			BackupName: "veryLongBackupName2",
+			UserData:   "someLongUsefulUserData2",
+		},
 	},
 }
 
 func TestWriteBackupListDetails_NoBackups(t *testing.T) {
-	expectedOutput := "name start_time finish_time user_data data_size backup_size permanent\n"
+	expectedOutput := "name start_time finish_time user_data data_size backup_size permanent verification\n"
 	buffer := bytes.Buffer{}
-	writeBackupListDetails(make([]archive.Backup, 0), &buffer)
+	writeBackupListDetails(make([]BackupDetail, 0), &buffer)
 	assert.Equal(t, expectedOutput, buffer.String())
 }
 
 func TestWriteBackupListDetails_EmptyColumnsValues(t *testing.T) {
-	expectedOutput := "name       start_time           finish_time          user_data data_size backup_size permanent\n" +
-		"backupName 0001-01-01T00:00:00Z 0001-01-01T00:00:00Z <nil>     0         0           false\n" +
-		"           0001-01-01T00:00:00Z 0001-01-01T00:00:00Z userData  0         0           false\n"
+	expectedOutput := "name       start_time           finish_time          user_data data_size backup_size permanent verification\n" +
+		"backupName 0001-01-01T00:00:00Z 0001-01-01T00:00:00Z <nil>     0         0           false     -\n" +
+		"           0001-01-01T00:00:00Z 0001-01-01T00:00:00Z userData  0         0           false     -\n"
 	buffer := bytes.Buffer{}
 	writeBackupListDetails(emptyColumnsBackups, &buffer)
 	assert.Equal(t, expectedOutput, buffer.String())
 }
 
 func TestWriteBackupListDetails_ShortColumnsValues(t *testing.T) {
-	expectedOutput := "name start_time           finish_time          user_data data_size backup_size permanent\n" +
-		"b1   0001-01-01T00:00:00Z 0001-01-01T00:00:00Z u1        0         0           false\n" +
-		"b2   0001-01-01T00:00:00Z 0001-01-01T00:00:00Z u2        0         0           false\n"
+	expectedOutput := "name start_time           finish_time          user_data data_size backup_size permanent verification\n" +
+		"b1   0001-01-01T00:00:00Z 0001-01-01T00:00:00Z u1        0         0           false     -\n" +
+		"b2   0001-01-01T00:00:00Z 0001-01-01T00:00:00Z u2        0         0           false     -\n"
 	buffer := bytes.Buffer{}
 	writeBackupListDetails(shortValuesBackups, &buffer)
 	assert.Equal(t, expectedOutput, buffer.String())
 }
 
 func TestWriteBackupListDetails_LongColumnsValues(t *testing.T) {
-	expectedOutput := "name                start_time           finish_time          user_data               data_size backup_size permanent\n" +
-		"veryLongBackupName1 0001-01-01T00:00:00Z 0001-01-01T00:00:00Z someLongUsefulUserData1 0         0           false\n" +
-		"veryLongBackupName2 0001-01-01T00:00:00Z 0001-01-01T00:00:00Z someLongUsefulUserData2 0         0           false\n"
+	expectedOutput := "name                start_time           finish_time          user_data               data_size backup_size permanent verification\n" +
+		"veryLongBackupName1 0001-01-01T00:00:00Z 0001-01-01T00:00:00Z someLongUsefulUserData1 0         0           false     -\n" +
+		"veryLongBackupName2 0001-01-01T00:00:00Z 0001-01-01T00:00:00Z someLongUsefulUserData2 0         0           false     -\n"
 	buffer := bytes.Buffer{}
 	writeBackupListDetails(longValuesBackups, &buffer)
 	assert.Equal(t, expectedOutput, buffer.String())
 }
+
+func TestWriteBackupListDetails_Verification(t *testing.T) {
+	expectedOutput := "name start_time           finish_time          user_data data_size backup_size permanent verification\n" +
+		"b1   0001-01-01T00:00:00Z 0001-01-01T00:00:00Z <nil>     0         0           false     success 2023-01-02T03:04:05Z (1m30s)\n"
+	buffer := bytes.Buffer{}
+	writeBackupListDetails([]BackupDetail{{
+		Backup: archive.Backup{BackupName: "b1"},
+		Verification: &internal.BackupVerification{
+			Result:          internal.VerificationResultSuccess,
+			FinishTime:      time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+			DurationSeconds: 90,
+		},
+	}}, &buffer)
+	assert.Equal(t, expectedOutput, buffer.String())
+}
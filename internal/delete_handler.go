@@ -3,6 +3,7 @@ package internal
 import (
 	"fmt"
 	"os"
+	"path"
 	"sort"
 	"strconv"
 	"strings"
@@ -69,6 +70,14 @@ type BackupObject interface {
 
 type DeleteHandlerOption func(h *DeleteHandler)
 
+// ObjectScopeFunc limits the deletion to the objects accepted by inScope,
+// e.g. to the backups of a single database stored next to the others
+func ObjectScopeFunc(inScope func(storage.Object) bool) DeleteHandlerOption {
+	return func(h *DeleteHandler) {
+		h.inScope = inScope
+	}
+}
+
 func IsPermanentFunc(isPermanent func(storage.Object) bool) DeleteHandlerOption {
 	return func(h *DeleteHandler) {
 		h.isPermanent = isPermanent
@@ -89,7 +98,9 @@ func NewDeleteHandler(
 			return less(object2, object1)
 		},
 		// by default, all storage objects are impermanent
-		isPermanent: func(storage.Object) bool { return false },
+		isPermanent:      func(storage.Object) bool { return false },
+		inScope:          func(storage.Object) bool { return true },
+		checkObjectLocks: isObjectLockCheckEnabled(),
 	}
 
 	for _, option := range options {
@@ -107,6 +118,9 @@ type DeleteHandler struct {
 	greater func(object1, object2 storage.Object) bool
 
 	isPermanent func(object storage.Object) bool
+	inScope     func(object storage.Object) bool
+	// checkObjectLocks makes delete skip the objects protected by the storage object lock
+	checkObjectLocks bool
 }
 
 func (h *DeleteHandler) HandleDeleteBefore(args []string, confirmed bool) {
@@ -346,7 +360,7 @@ func (h *DeleteHandler) FindTargetRetainAfterTime(retentionCount int, timeLine t
 func (h *DeleteHandler) DeleteEverything(confirmed bool) {
 	filter := func(object storage.Object) bool { return true }
 	folderFilter := func(path string) bool { return true }
-	err := storage.DeleteObjectsWhere(h.Folder, confirmed, filter, folderFilter)
+	err := h.deleteObjectsWhere(h.Folder, confirmed, filter, folderFilter)
 	tracelog.ErrorLogger.FatalOnError(err)
 }
 
@@ -365,11 +379,46 @@ func (h *DeleteHandler) DeleteBeforeTargetWhere(target BackupObject, confirmed b
 	}
 	tracelog.InfoLogger.Println("Start delete")
 
-	return storage.DeleteObjectsWhere(h.Folder, confirmed, func(object storage.Object) bool {
-		return objSelector(object) && h.less(object, target) && !h.isPermanent(object)
+	lockedBackup := h.findOldestLockedBackup(target)
+	return h.deleteObjectsWhere(h.Folder, confirmed, func(object storage.Object) bool {
+		return objSelector(object) && h.less(object, target) && !h.isPermanent(object) && !isSharedObject(object) &&
+			(lockedBackup == nil || !isLogObject(object.GetName()) || h.less(object, lockedBackup))
 	}, folderFilter)
 }
 
+// findOldestLockedBackup returns the oldest backup before the target locked by the storage, or nil.
+// The logs it needs to be restored are kept along with it.
+func (h *DeleteHandler) findOldestLockedBackup(target BackupObject) BackupObject {
+	if !h.checkObjectLocks {
+		return nil
+	}
+	backups := make([]BackupObject, 0, len(h.backups))
+	for _, backup := range h.backups {
+		if h.less(backup, target) {
+			backups = append(backups, backup)
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		return h.less(backups[i], backups[j])
+	})
+	lockFilter := newLockedObjectsFilter(h.Folder)
+	for _, backup := range backups {
+		if lockFilter.isBackupLocked(path.Join(utility.BaseBackupPath, SentinelNameFromBackup(backup.GetBackupName()))) {
+			tracelog.InfoLogger.Printf("Backup %s is locked by the storage, the logs since its start are kept",
+				backup.GetBackupName())
+			return backup
+		}
+	}
+	return nil
+}
+
+// isSharedObject reports whether the object is used by the backups of any age,
+// e.g. the envelope encryption data key or the compression dictionary
+func isSharedObject(object storage.Object) bool {
+	name := object.GetName()
+	return IsDataKeyObject(name) || strings.HasPrefix(name, utility.CompressionDictionaryPath)
+}
+
 func (h *DeleteHandler) DeleteTarget(target BackupObject, confirmed, findFull bool,
 	folderFilter func(name string) bool) error {
 	var backupsToDelete []BackupObject
@@ -390,12 +439,46 @@ func (h *DeleteHandler) DeleteTarget(target BackupObject, confirmed, findFull bo
 		backupNamesToDelete[bTarget.GetBackupName()] = true
 	}
 
-	return storage.DeleteObjectsWhere(h.Folder.GetSubFolder(utility.BaseBackupPath),
+	return h.deleteObjectsWhere(h.Folder.GetSubFolder(utility.BaseBackupPath),
 		confirmed, func(object storage.Object) bool {
 			return backupNamesToDelete[utility.StripLeftmostBackupName(object.GetName())] && !h.isPermanent(object)
 		}, folderFilter)
 }
 
+// deleteObjectsWhere is storage.DeleteObjectsWhere which skips the locked objects
+// and reports them at the end instead of failing on them. The data keys no longer used
+// by the remaining backups and log objects are deleted afterwards.
+func (h *DeleteHandler) deleteObjectsWhere(folder storage.Folder, confirmed bool,
+	objFilter func(object storage.Object) bool, folderFilter func(name string) bool) error {
+	var lockFilter *lockedObjectsFilter
+	if h.checkObjectLocks {
+		lockFilter = newLockedObjectsFilter(folder)
+	}
+	var keyCollector *dataKeyCollector
+	if confirmed {
+		keyCollector = newDataKeyCollector(folder)
+	}
+
+	err := storage.DeleteObjectsWhere(folder, confirmed, func(object storage.Object) bool {
+		if !h.inScope(object) || !objFilter(object) || lockFilter.isLocked(object.GetName()) {
+			return false
+		}
+		if path.Base(object.GetName()) == DataKeyManifestName {
+			keyCollector.addManifest(object.GetName())
+		}
+		return true
+	}, folderFilter)
+	lockFilter.report()
+	if err != nil {
+		return err
+	}
+	keyCollector.deleteUnusedKeys()
+	if confirmed && folder == h.Folder {
+		deleteOutdatedSharedKeys(folder)
+	}
+	return nil
+}
+
 // TODO: unit tests
 // Find all backups related to the target.
 // All delta backups with the same base backup are considered as related.
@@ -0,0 +1,76 @@
+package integrity
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ManifestFileName is the name of the manifest object in the backup folder
+const ManifestFileName = "integrity_manifest.json"
+
+// ObjectEntry describes the stored object as it was uploaded, i.e. compressed and encrypted
+type ObjectEntry struct {
+	// Path is relative to the folder with the backup sentinels
+	Path   string `json:"Path"`
+	Size   int64  `json:"Size"`
+	SHA256 string `json:"SHA256"`
+}
+
+// Manifest lists the objects of the backup and is signed to detect the tampering
+type Manifest struct {
+	BackupName         string        `json:"BackupName"`
+	CreatedAt          time.Time     `json:"CreatedAt"`
+	Objects            []ObjectEntry `json:"Objects"`
+	SignatureAlgorithm string        `json:"SignatureAlgorithm"`
+	Signature          string        `json:"Signature"`
+}
+
+func NewManifest(backupName string, objects []ObjectEntry, createdAt time.Time) *Manifest {
+	sorted := append([]ObjectEntry(nil), objects...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+	return &Manifest{BackupName: backupName, CreatedAt: createdAt.UTC(), Objects: sorted}
+}
+
+// payload is the signed content: every field except the signature itself
+func (manifest *Manifest) payload() ([]byte, error) {
+	return json.Marshal(struct {
+		BackupName         string
+		CreatedAt          time.Time
+		Objects            []ObjectEntry
+		SignatureAlgorithm string
+	}{manifest.BackupName, manifest.CreatedAt, manifest.Objects, manifest.SignatureAlgorithm})
+}
+
+func (manifest *Manifest) Sign(signer Signer) error {
+	manifest.SignatureAlgorithm = signer.Algorithm()
+	payload, err := manifest.payload()
+	if err != nil {
+		return err
+	}
+	signature, err := signer.Sign(payload)
+	if err != nil {
+		return fmt.Errorf("failed to sign the manifest of %s: %w", manifest.BackupName, err)
+	}
+	manifest.Signature = base64.StdEncoding.EncodeToString(signature)
+	return nil
+}
+
+// Verify checks that the manifest was signed with the verifier key and was not modified since
+func (manifest *Manifest) Verify(verifier Verifier) error {
+	if manifest.SignatureAlgorithm != verifier.Algorithm() {
+		return fmt.Errorf("the manifest is signed with %q, but the %q key is configured",
+			manifest.SignatureAlgorithm, verifier.Algorithm())
+	}
+	signature, err := base64.StdEncoding.DecodeString(manifest.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid manifest signature: %w", err)
+	}
+	payload, err := manifest.payload()
+	if err != nil {
+		return err
+	}
+	return verifier.Verify(payload, signature)
+}
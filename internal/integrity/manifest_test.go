@@ -0,0 +1,87 @@
+package integrity_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/integrity"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManifest() *integrity.Manifest {
+	return integrity.NewManifest("base_000000010000000000000002", []integrity.ObjectEntry{
+		{Path: "tar_partitions/part_2.tar.lz4", Size: 20, SHA256: "bb"},
+		{Path: "tar_partitions/part_1.tar.lz4", Size: 10, SHA256: "aa"},
+	}, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+}
+
+func TestManifest_SortsObjects(t *testing.T) {
+	manifest := newTestManifest()
+	assert.Equal(t, "tar_partitions/part_1.tar.lz4", manifest.Objects[0].Path)
+}
+
+func TestManifest_Ed25519(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	privateDer, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	parsedPrivate, err := integrity.ParseEd25519PrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDer}))
+	require.NoError(t, err)
+	publicDer, err := x509.MarshalPKIXPublicKey(publicKey)
+	require.NoError(t, err)
+	parsedPublic, err := integrity.ParseEd25519PublicKey(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDer}))
+	require.NoError(t, err)
+
+	manifest := newTestManifest()
+	require.NoError(t, manifest.Sign(integrity.Ed25519Signer{PrivateKey: parsedPrivate}))
+	assert.Equal(t, integrity.AlgorithmEd25519, manifest.SignatureAlgorithm)
+	assert.NoError(t, manifest.Verify(integrity.Ed25519Verifier{PublicKey: parsedPublic}))
+
+	manifest.Objects[1].Size++
+	assert.ErrorIs(t, manifest.Verify(integrity.Ed25519Verifier{PublicKey: parsedPublic}), integrity.ErrInvalidSignature)
+}
+
+func TestManifest_HMAC(t *testing.T) {
+	manifest := newTestManifest()
+	require.NoError(t, manifest.Sign(integrity.HMACSigner{Key: []byte("secret")}))
+	assert.NoError(t, manifest.Verify(integrity.HMACSigner{Key: []byte("secret")}))
+	assert.ErrorIs(t, manifest.Verify(integrity.HMACSigner{Key: []byte("other")}), integrity.ErrInvalidSignature)
+
+	manifest.BackupName = "base_000000010000000000000004"
+	assert.ErrorIs(t, manifest.Verify(integrity.HMACSigner{Key: []byte("secret")}), integrity.ErrInvalidSignature)
+}
+
+func TestManifest_AlgorithmMismatch(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	manifest := newTestManifest()
+	require.NoError(t, manifest.Sign(integrity.HMACSigner{Key: []byte("secret")}))
+	assert.Error(t, manifest.Verify(integrity.Ed25519Verifier{PublicKey: publicKey}))
+}
+
+func TestRecorder_Take(t *testing.T) {
+	recorder := integrity.NewRecorder()
+	for _, objectPath := range []string{"basebackups_005/a/part_1", "basebackups_005/b/part_1", "basebackups_005/a/part_2"} {
+		reader, record := recorder.Track(objectPath, strings.NewReader("data"))
+		_, err := reader.Read(make([]byte, 10))
+		require.NoError(t, err)
+		record()
+	}
+	// the object which failed to upload is not recorded
+	_, _ = recorder.Track("basebackups_005/a/part_3", strings.NewReader("data"))
+
+	entries := recorder.Take("basebackups_005/a/")
+	assert.Equal(t, []integrity.ObjectEntry{
+		{Path: "part_1", Size: 4, SHA256: "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7"},
+		{Path: "part_2", Size: 4, SHA256: "3a6eb0790f39ac87c94f3856b2dd2c5d110e6811602261a9a923d3bb23adc8b7"},
+	}, entries)
+	assert.Empty(t, recorder.Take("basebackups_005/a/"))
+	assert.Len(t, recorder.Take("basebackups_005/b/"), 1)
+}
@@ -0,0 +1,51 @@
+package integrity
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/checksum"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+)
+
+// Recorder collects the sizes and checksums of the uploaded objects to build the manifest
+type Recorder struct {
+	mutex   sync.Mutex
+	objects map[string]ObjectEntry
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{objects: make(map[string]ObjectEntry)}
+}
+
+// Track wraps the content of the object uploaded to the storage path.
+// The returned function records the object and should be called once it is uploaded successfully.
+func (recorder *Recorder) Track(objectPath string, content io.Reader) (io.Reader, func()) {
+	calculator := checksum.CreateCalculator()
+	size := new(int64)
+	reader := checksum.CreateReaderWithChecksum(utility.NewWithSizeReader(content, size), calculator)
+	return reader, func() {
+		recorder.mutex.Lock()
+		defer recorder.mutex.Unlock()
+		recorder.objects[objectPath] = ObjectEntry{Path: objectPath, Size: *size, SHA256: calculator.Checksum()}
+	}
+}
+
+// Take returns the objects recorded under the prefix with the paths relative to it and forgets them
+func (recorder *Recorder) Take(prefix string) []ObjectEntry {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	entries := make([]ObjectEntry, 0)
+	for objectPath, entry := range recorder.objects {
+		if !strings.HasPrefix(objectPath, prefix) {
+			continue
+		}
+		entry.Path = strings.TrimPrefix(objectPath, prefix)
+		entries = append(entries, entry)
+		delete(recorder.objects, objectPath)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return entries
+}
@@ -0,0 +1,118 @@
+package integrity
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+const (
+	AlgorithmEd25519    = "ed25519"
+	AlgorithmHMACSHA256 = "hmac-sha256"
+)
+
+// ErrInvalidSignature is returned when the manifest doesn't match its signature
+var ErrInvalidSignature = errors.New("manifest signature is invalid")
+
+type Signer interface {
+	Algorithm() string
+	Sign(payload []byte) ([]byte, error)
+}
+
+type Verifier interface {
+	Algorithm() string
+	Verify(payload, signature []byte) error
+}
+
+// Ed25519Signer signs with the private key, the manifest is verified with the public one
+type Ed25519Signer struct {
+	PrivateKey ed25519.PrivateKey
+}
+
+func (signer Ed25519Signer) Algorithm() string {
+	return AlgorithmEd25519
+}
+
+func (signer Ed25519Signer) Sign(payload []byte) ([]byte, error) {
+	return ed25519.Sign(signer.PrivateKey, payload), nil
+}
+
+func (signer Ed25519Signer) Verify(payload, signature []byte) error {
+	return Ed25519Verifier{PublicKey: signer.PrivateKey.Public().(ed25519.PublicKey)}.Verify(payload, signature)
+}
+
+type Ed25519Verifier struct {
+	PublicKey ed25519.PublicKey
+}
+
+func (verifier Ed25519Verifier) Algorithm() string {
+	return AlgorithmEd25519
+}
+
+func (verifier Ed25519Verifier) Verify(payload, signature []byte) error {
+	if !ed25519.Verify(verifier.PublicKey, payload, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// HMACSigner both signs and verifies with the shared secret key
+type HMACSigner struct {
+	Key []byte
+}
+
+func (signer HMACSigner) Algorithm() string {
+	return AlgorithmHMACSHA256
+}
+
+func (signer HMACSigner) Sign(payload []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, signer.Key)
+	mac.Write(payload)
+	return mac.Sum(nil), nil
+}
+
+func (signer HMACSigner) Verify(payload, signature []byte) error {
+	expected, _ := signer.Sign(payload)
+	if !hmac.Equal(expected, signature) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+// ParseEd25519PrivateKey reads the PKCS #8 PEM key, e.g. generated by `openssl genpkey -algorithm ed25519`
+func ParseEd25519PrivateKey(pemBytes []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found in the ed25519 private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the ed25519 private key: %w", err)
+	}
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("the private key is %T, not ed25519", key)
+	}
+	return privateKey, nil
+}
+
+// ParseEd25519PublicKey reads the PKIX PEM key, e.g. generated by `openssl pkey -pubout`
+func ParseEd25519PublicKey(pemBytes []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM block found in the ed25519 public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the ed25519 public key: %w", err)
+	}
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("the public key is %T, not ed25519", key)
+	}
+	return publicKey, nil
+}
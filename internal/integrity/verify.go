@@ -0,0 +1,65 @@
+package integrity
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/checksum"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+)
+
+type ProblemKind string
+
+const (
+	ProblemMissing    ProblemKind = "missing"
+	ProblemTruncated  ProblemKind = "truncated"
+	ProblemTampered   ProblemKind = "tampered"
+	ProblemUnexpected ProblemKind = "unexpected"
+)
+
+// Problem is the object which doesn't match the manifest
+type Problem struct {
+	Path    string
+	Kind    ProblemKind
+	Details string
+}
+
+func (problem Problem) String() string {
+	if problem.Details == "" {
+		return fmt.Sprintf("%s: %s", problem.Kind, problem.Path)
+	}
+	return fmt.Sprintf("%s: %s (%s)", problem.Kind, problem.Path, problem.Details)
+}
+
+// VerifyObject streams the object and compares its size and checksum with the manifest entry.
+// It returns nil if the object matches.
+func VerifyObject(ctx context.Context, folder storage.Folder, entry ObjectEntry) (*Problem, error) {
+	reader, err := storage.ReadObjectWithContext(ctx, folder, entry.Path)
+	if err != nil {
+		var notFound storage.ObjectNotFoundError
+		if errors.As(err, &notFound) {
+			return &Problem{Path: entry.Path, Kind: ProblemMissing}, nil
+		}
+		return nil, err
+	}
+	defer reader.Close()
+
+	calculator := checksum.CreateCalculator()
+	size, err := io.Copy(io.Discard, checksum.CreateReaderWithChecksum(reader, calculator))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", entry.Path, err)
+	}
+	switch {
+	case size < entry.Size:
+		return &Problem{Path: entry.Path, Kind: ProblemTruncated,
+			Details: fmt.Sprintf("%d of %d bytes", size, entry.Size)}, nil
+	case size > entry.Size:
+		return &Problem{Path: entry.Path, Kind: ProblemTampered,
+			Details: fmt.Sprintf("%d bytes instead of %d", size, entry.Size)}, nil
+	case calculator.Checksum() != entry.SHA256:
+		return &Problem{Path: entry.Path, Kind: ProblemTampered, Details: "sha256 mismatch"}, nil
+	}
+	return nil, nil
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"io"
 
 	"github.com/apecloud/dataprotection-wal-g/internal/ioextensions"
@@ -14,6 +15,11 @@ type LimitedFolder struct {
 	limiter *rate.Limiter
 }
 
+var _ storage.ContextFolder = &LimitedFolder{}
+var _ storage.PagedFolder = &LimitedFolder{}
+var _ storage.MetadataFolder = &LimitedFolder{}
+var _ storage.LockingFolder = &LimitedFolder{}
+
 func NewLimitedFolder(folder storage.Folder, limiter *rate.Limiter) *LimitedFolder {
 	return &LimitedFolder{Folder: folder, limiter: limiter}
 }
@@ -28,13 +34,77 @@ func (lf *LimitedFolder) ReadObject(objectRelativePath string) (io.ReadCloser, e
 	if err != nil {
 		return nil, err
 	}
-	return ioextensions.ReadCascadeCloser{
-		Reader: limiters.NewReader(readCloser, lf.limiter),
-		Closer: readCloser,
-	}, nil
+	return lf.limitReadCloser(readCloser), nil
 }
 
 func (lf *LimitedFolder) PutObject(name string, content io.Reader) error {
 	limitedReader := limiters.NewReader(content, lf.limiter)
 	return lf.Folder.PutObject(name, limitedReader)
 }
+
+func (lf *LimitedFolder) ListFolderWithContext(ctx context.Context) (objects []storage.Object, subFolders []storage.Folder, err error) {
+	return storage.ListFolderWithContext(ctx, lf.Folder)
+}
+
+func (lf *LimitedFolder) ListFolderPages(ctx context.Context, opts storage.ListOptions,
+	pageFn func(objects []storage.Object, subFolders []storage.Folder) error) error {
+	return storage.ListFolderPages(ctx, lf.Folder, opts, pageFn)
+}
+
+func (lf *LimitedFolder) DeleteObjectsWithContext(ctx context.Context, objectRelativePaths []string) error {
+	return storage.DeleteObjectsWithContext(ctx, lf.Folder, objectRelativePaths)
+}
+
+func (lf *LimitedFolder) ExistsWithContext(ctx context.Context, objectRelativePath string) (bool, error) {
+	return storage.ExistsWithContext(ctx, lf.Folder, objectRelativePath)
+}
+
+func (lf *LimitedFolder) ReadObjectWithContext(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	readCloser, err := storage.ReadObjectWithContext(ctx, lf.Folder, objectRelativePath)
+	if err != nil {
+		return nil, err
+	}
+	return lf.limitReadCloser(readCloser), nil
+}
+
+func (lf *LimitedFolder) ReadObjectRange(ctx context.Context, objectRelativePath string, offset, length int64) (io.ReadCloser, error) {
+	readCloser, err := storage.ReadObjectRange(ctx, lf.Folder, objectRelativePath, offset, length)
+	if err != nil {
+		return nil, err
+	}
+	return lf.limitReadCloser(readCloser), nil
+}
+
+func (lf *LimitedFolder) StatObject(ctx context.Context, objectRelativePath string) (*storage.ObjectInfo, error) {
+	return storage.StatObject(ctx, lf.Folder, objectRelativePath)
+}
+
+func (lf *LimitedFolder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	limitedReader := limiters.NewReader(content, lf.limiter)
+	return storage.PutObjectWithContext(ctx, lf.Folder, name, limitedReader)
+}
+
+func (lf *LimitedFolder) PutObjectWithMetadata(ctx context.Context, name string, content io.Reader,
+	metadata map[string]string) error {
+	limitedReader := limiters.NewReader(content, lf.limiter)
+	return storage.PutObjectWithMetadata(ctx, lf.Folder, name, limitedReader, metadata)
+}
+
+func (lf *LimitedFolder) SetObjectLock(ctx context.Context, objectRelativePath string, lock storage.ObjectLock) error {
+	return storage.SetObjectLock(ctx, lf.Folder, objectRelativePath, lock)
+}
+
+func (lf *LimitedFolder) GetObjectLock(ctx context.Context, objectRelativePath string) (storage.ObjectLock, error) {
+	return storage.GetObjectLock(ctx, lf.Folder, objectRelativePath)
+}
+
+func (lf *LimitedFolder) CopyObjectWithContext(ctx context.Context, srcPath string, dstPath string) error {
+	return storage.CopyObjectWithContext(ctx, lf.Folder, srcPath, dstPath)
+}
+
+func (lf *LimitedFolder) limitReadCloser(readCloser io.ReadCloser) io.ReadCloser {
+	return ioextensions.ReadCascadeCloser{
+		Reader: limiters.NewReader(readCloser, lf.limiter),
+		Closer: readCloser,
+	}
+}
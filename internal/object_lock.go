@@ -0,0 +1,216 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+)
+
+// ConfigureObjectLock returns the lock to set on the uploaded backup objects or nil if object locking is not configured.
+// The retention is counted from the moment of the configuration, so all the objects of a backup expire together.
+func ConfigureObjectLock() (*storage.ObjectLock, error) {
+	modeStr, _ := GetSetting(ObjectLockModeSetting)
+	mode, err := storage.ParseObjectLockMode(modeStr)
+	if err != nil {
+		return nil, err
+	}
+	legalHold := viper.GetBool(ObjectLockLegalHoldSetting)
+
+	var retention time.Duration
+	if retentionStr, ok := GetSetting(ObjectLockRetentionSetting); ok && retentionStr != "" {
+		retention, err = GetDurationSetting(ObjectLockRetentionSetting)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if (mode == storage.ObjectLockModeNone) != (retention <= 0) {
+		return nil, fmt.Errorf("both %s and %s should be set to lock the objects",
+			ObjectLockModeSetting, ObjectLockRetentionSetting)
+	}
+	if mode == storage.ObjectLockModeNone && !legalHold {
+		return nil, nil
+	}
+
+	lock := &storage.ObjectLock{Mode: mode, LegalHold: legalHold}
+	if mode != storage.ObjectLockModeNone {
+		lock.RetainUntil = utility.TimeNowCrossPlatformUTC().Add(retention)
+	}
+	return lock, nil
+}
+
+// isObjectLockCheckEnabled checks if delete should look for the locked objects.
+// It is enabled by default when the uploaded objects are locked.
+func isObjectLockCheckEnabled() bool {
+	if viper.IsSet(DeleteCheckObjectLockSetting) {
+		return viper.GetBool(DeleteCheckObjectLockSetting)
+	}
+	mode, _ := GetSetting(ObjectLockModeSetting)
+	return mode != "" || viper.GetBool(ObjectLockLegalHoldSetting)
+}
+
+// isLockedBackupObject checks if the object at the storage path should be locked on upload
+func isLockedBackupObject(objectPath string) bool {
+	objectKind, _ := classifyObjectPath(objectPath)
+	return objectKind == ObjectKindBackup || objectKind == ObjectKindSentinel
+}
+
+// lockedObjectsFilter tells the objects protected by the storage object lock, so delete can skip them
+// instead of failing halfway through
+type lockedObjectsFilter struct {
+	folder        storage.Folder
+	now           time.Time
+	lockedObjects []string
+	// backupLocks caches whether the backup is locked by the path of its sentinel
+	backupLocks map[string]bool
+}
+
+func newLockedObjectsFilter(folder storage.Folder) *lockedObjectsFilter {
+	return &lockedObjectsFilter{folder: folder, now: utility.TimeNowCrossPlatformUTC(), backupLocks: make(map[string]bool)}
+}
+
+// isLocked checks the lock of the object at the path relative to the filter folder.
+// Only the objects locked on upload are checked, the others are never locked by wal-g.
+// The objects of a backup are locked together, so the lock of the backup sentinel is checked once for all of them.
+// The objects whose lock can't be checked are considered locked. The nil filter locks nothing.
+func (f *lockedObjectsFilter) isLocked(objectPath string) bool {
+	if f == nil {
+		return false
+	}
+	fullPath := storage.JoinPath(f.folder.GetPath(), objectPath)
+	objectKind, backupName := classifyObjectPath(fullPath)
+	if objectKind != ObjectKindBackup && objectKind != ObjectKindSentinel {
+		return false
+	}
+	if backupName == "" {
+		return f.isLockedObject(objectPath)
+	}
+	backupPath := utility.BaseBackupPath + backupName
+	sentinelPath := strings.TrimPrefix(fullPath[:strings.LastIndex(fullPath, backupPath)]+backupPath+utility.SentinelSuffix,
+		f.folder.GetPath())
+	locked, known := f.backupLocks[sentinelPath]
+	if !known {
+		var exists bool
+		locked, exists = f.checkLock(sentinelPath)
+		if !exists {
+			// the backup without the sentinel, e.g. the garbage of the failed backup
+			return f.isLockedObject(objectPath)
+		}
+		f.backupLocks[sentinelPath] = locked
+	}
+	if locked {
+		f.lockedObjects = append(f.lockedObjects, objectPath)
+	}
+	return locked
+}
+
+// isBackupLocked checks the lock of the backup sentinel at the path relative to the filter folder
+func (f *lockedObjectsFilter) isBackupLocked(sentinelPath string) bool {
+	locked, known := f.backupLocks[sentinelPath]
+	if !known {
+		locked, _ = f.checkLock(sentinelPath)
+		f.backupLocks[sentinelPath] = locked
+	}
+	return locked
+}
+
+// isLockedObject checks the lock of any object at the path relative to the filter folder
+func (f *lockedObjectsFilter) isLockedObject(objectPath string) bool {
+	locked, _ := f.checkLock(objectPath)
+	if locked {
+		f.lockedObjects = append(f.lockedObjects, objectPath)
+	}
+	return locked
+}
+
+// checkLock returns if the object is locked and if it exists
+func (f *lockedObjectsFilter) checkLock(objectPath string) (locked, exists bool) {
+	lock, err := storage.GetObjectLock(context.Background(), f.folder, objectPath)
+	if _, ok := err.(storage.ObjectNotFoundError); ok {
+		return false, false
+	}
+	if err != nil {
+		tracelog.WarningLogger.Printf("Failed to check the lock of %s, keeping it: %v", objectPath, err)
+		return true, true
+	}
+	if lock.IsActive(f.now) {
+		tracelog.DebugLogger.Printf("\tlocked (%s): %s", lock, objectPath)
+		return true, true
+	}
+	return false, true
+}
+
+// report logs the locked objects met by the filter
+func (f *lockedObjectsFilter) report() {
+	if f == nil || len(f.lockedObjects) == 0 {
+		return
+	}
+	tracelog.WarningLogger.Printf("%d objects are locked by the storage and were not deleted:", len(f.lockedObjects))
+	for _, objectName := range f.lockedObjects {
+		tracelog.WarningLogger.Println("\t" + objectName)
+	}
+}
+
+// ExtendBackupLock extends the retention of every object of the backup up to retainUntil.
+// The objects already locked for longer are left as is, the legal hold is kept.
+func ExtendBackupLock(baseBackupFolder storage.Folder, backupName string, retainUntil time.Time) error {
+	configuredLock, err := ConfigureObjectLock()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	extendLock := func(objectPath string) error {
+		currentLock, err := storage.GetObjectLock(ctx, baseBackupFolder, objectPath)
+		if err != nil {
+			return err
+		}
+		if !currentLock.RetainUntil.Before(retainUntil) {
+			tracelog.DebugLogger.Printf("%s is already locked until %s", objectPath, currentLock.RetainUntil)
+			return nil
+		}
+		lock := storage.ObjectLock{Mode: currentLock.Mode, RetainUntil: retainUntil, LegalHold: currentLock.LegalHold}
+		if configuredLock != nil && configuredLock.Mode != storage.ObjectLockModeNone {
+			lock.Mode = configuredLock.Mode
+		}
+		if lock.Mode == storage.ObjectLockModeNone {
+			return fmt.Errorf("%s is not locked, set %s to lock it", objectPath, ObjectLockModeSetting)
+		}
+		tracelog.DebugLogger.Printf("Locking %s: %s", objectPath, lock)
+		return storage.SetObjectLock(ctx, baseBackupFolder, objectPath, lock)
+	}
+
+	tracelog.InfoLogger.Printf("Extending the lock of backup %s until %s", backupName, retainUntil.Format(time.RFC3339))
+	err = storage.WalkFolder(ctx, baseBackupFolder.GetSubFolder(backupName), func(object storage.Object) error {
+		return extendLock(path.Join(backupName, object.GetName()))
+	})
+	if err != nil {
+		return err
+	}
+	sentinelName := SentinelNameFromBackup(backupName)
+	if err = extendLock(sentinelName); err != nil {
+		return err
+	}
+	return extendDataKeysLock(baseBackupFolder, backupName, sentinelName)
+}
+
+// extendDataKeysLock locks the data keys listed by the backup as long as its sentinel
+func extendDataKeysLock(baseBackupFolder storage.Folder, backupName, sentinelName string) error {
+	manifest, err := readDataKeyManifest(baseBackupFolder, path.Join(backupName, DataKeyManifestName))
+	if _, ok := err.(storage.ObjectNotFoundError); ok {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	sentinelLock, err := storage.GetObjectLock(context.Background(), baseBackupFolder, sentinelName)
+	if err != nil {
+		return err
+	}
+	return lockDataKeys(manifest.KeyIDs, sentinelLock)
+}
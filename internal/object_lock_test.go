@@ -0,0 +1,229 @@
+package internal_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/memory"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/testtools"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// lockingFolder keeps the object locks of the in-memory folder by the full object paths,
+// so the locks set through a subfolder are seen from the parent folder
+type lockingFolder struct {
+	*memory.Folder
+	locks     map[string]storage.ObjectLock
+	failLocks bool
+	checked   *[]string
+}
+
+func newLockingFolder() *lockingFolder {
+	return &lockingFolder{
+		Folder:  testtools.MakeDefaultInMemoryStorageFolder(),
+		locks:   map[string]storage.ObjectLock{},
+		checked: &[]string{},
+	}
+}
+
+func (f *lockingFolder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return &lockingFolder{
+		Folder:    f.Folder.GetSubFolder(subFolderRelativePath).(*memory.Folder),
+		locks:     f.locks,
+		failLocks: f.failLocks,
+		checked:   f.checked,
+	}
+}
+
+func (f *lockingFolder) SetObjectLock(_ context.Context, objectRelativePath string, lock storage.ObjectLock) error {
+	if f.failLocks {
+		return errors.New("lock failed")
+	}
+	f.locks[storage.JoinPath(f.GetPath(), objectRelativePath)] = lock
+	return nil
+}
+
+func (f *lockingFolder) GetObjectLock(_ context.Context, objectRelativePath string) (storage.ObjectLock, error) {
+	objectPath := storage.JoinPath(f.GetPath(), objectRelativePath)
+	*f.checked = append(*f.checked, objectPath)
+	if exists, err := f.Exists(objectRelativePath); err != nil || !exists {
+		return storage.ObjectLock{}, storage.NewObjectNotFoundError(objectPath)
+	}
+	return f.locks[objectPath], nil
+}
+
+func (f *lockingFolder) lock(objectPath string, lock storage.ObjectLock) {
+	f.locks[storage.JoinPath(f.GetPath(), objectPath)] = lock
+}
+
+func listObjectNames(t *testing.T, folder storage.Folder) []string {
+	names := make([]string, 0)
+	err := storage.WalkFolder(context.Background(), folder, func(object storage.Object) error {
+		names = append(names, object.GetName())
+		return nil
+	})
+	assert.NoError(t, err)
+	return names
+}
+
+func TestDeleteEverything_SkipsLockedObjects(t *testing.T) {
+	viper.Set(internal.DeleteCheckObjectLockSetting, "true")
+	defer viper.Set(internal.DeleteCheckObjectLockSetting, "false")
+
+	folder := newLockingFolder()
+	for _, name := range []string{"locked", "held", "expired", "free"} {
+		assert.NoError(t, folder.PutObject("basebackups_005/"+name, &bytes.Buffer{}))
+	}
+	assert.NoError(t, folder.PutObject("wal_005/000000010000000000000001.br", &bytes.Buffer{}))
+	now := time.Now()
+	folder.lock("basebackups_005/locked", storage.ObjectLock{Mode: storage.ObjectLockModeCompliance, RetainUntil: now.Add(time.Hour)})
+	folder.lock("basebackups_005/held", storage.ObjectLock{LegalHold: true})
+	folder.lock("basebackups_005/expired", storage.ObjectLock{Mode: storage.ObjectLockModeGovernance, RetainUntil: now.Add(-time.Hour)})
+
+	deleteHandler := internal.NewDeleteHandler(folder, nil, func(object1, object2 storage.Object) bool { return false })
+	deleteHandler.DeleteEverything(true)
+
+	names := listObjectNames(t, folder)
+	assert.ElementsMatch(t, []string{"basebackups_005/locked", "basebackups_005/held"}, names)
+	// WAL is never locked on upload, so delete does not check its lock
+	assert.NotContains(t, *folder.checked, "in_memory/wal_005/000000010000000000000001.br")
+	assert.Contains(t, *folder.checked, "in_memory/basebackups_005/free")
+}
+
+func TestConfigureObjectLock(t *testing.T) {
+	defer func() {
+		viper.Set(internal.ObjectLockModeSetting, "")
+		viper.Set(internal.ObjectLockRetentionSetting, "")
+	}()
+
+	lock, err := internal.ConfigureObjectLock()
+	assert.NoError(t, err)
+	assert.Nil(t, lock)
+
+	viper.Set(internal.ObjectLockModeSetting, "governance")
+	_, err = internal.ConfigureObjectLock()
+	assert.Error(t, err)
+
+	viper.Set(internal.ObjectLockRetentionSetting, "24h")
+	lock, err = internal.ConfigureObjectLock()
+	assert.NoError(t, err)
+	assert.Equal(t, storage.ObjectLockModeGovernance, lock.Mode)
+	assert.WithinDuration(t, time.Now().Add(24*time.Hour), lock.RetainUntil, time.Minute)
+}
+
+func TestUploadSentinel_LocksSentinel(t *testing.T) {
+	folder := newLockingFolder()
+	lock := storage.ObjectLock{Mode: storage.ObjectLockModeCompliance, RetainUntil: time.Now().Add(time.Hour)}
+	uploader := internal.NewRegularUploader(nil, folder.GetSubFolder(utility.BaseBackupPath))
+	uploader.ObjectLock = &lock
+
+	backupName := "base_000000010000000000000002"
+	assert.NoError(t, uploader.Upload(backupName+"/tar_partitions/part_1.tar", &bytes.Buffer{}))
+	assert.NoError(t, internal.UploadSentinel(uploader, map[string]string{}, backupName))
+
+	assert.Equal(t, lock, folder.locks["in_memory/basebackups_005/"+backupName+utility.SentinelSuffix])
+	assert.Equal(t, lock, folder.locks["in_memory/basebackups_005/"+backupName+"/tar_partitions/part_1.tar"])
+}
+
+func TestUpload_RemovesObjectIfLockFails(t *testing.T) {
+	folder := newLockingFolder()
+	folder.failLocks = true
+	uploader := internal.NewRegularUploader(nil, folder.GetSubFolder(utility.BaseBackupPath))
+	uploader.ObjectLock = &storage.ObjectLock{LegalHold: true}
+
+	err := internal.UploadSentinel(uploader, map[string]string{}, "base_000000010000000000000002")
+	assert.ErrorContains(t, err, "lock failed")
+	assert.Empty(t, listObjectNames(t, folder))
+}
+
+func TestDeleteBackups_SkipsLockedObjects(t *testing.T) {
+	viper.Set(internal.DeleteCheckObjectLockSetting, "true")
+	defer viper.Set(internal.DeleteCheckObjectLockSetting, "false")
+
+	folder := newLockingFolder().GetSubFolder(utility.BaseBackupPath).(*lockingFolder)
+	// the objects of the backup without the sentinel are checked one by one
+	for _, name := range []string{
+		"locked_backup_stop_sentinel.json", "locked/stream.br",
+		"partial/stream.br", "partial/metadata.json",
+	} {
+		assert.NoError(t, folder.PutObject(name, &bytes.Buffer{}))
+	}
+	folder.lock("locked_backup_stop_sentinel.json", storage.ObjectLock{LegalHold: true})
+	folder.lock("partial/stream.br", storage.ObjectLock{LegalHold: true})
+
+	assert.NoError(t, internal.DeleteBackups(folder, []string{"locked", "partial"}))
+	assert.ElementsMatch(t, []string{"locked_backup_stop_sentinel.json", "locked/stream.br", "partial/stream.br"},
+		listObjectNames(t, folder))
+
+	// the objects of the backup are locked along with its sentinel
+	assert.NoError(t, internal.DeleteGarbage(folder, []string{"locked", "partial"}))
+	assert.ElementsMatch(t, []string{"locked_backup_stop_sentinel.json", "locked/stream.br", "partial/stream.br"},
+		listObjectNames(t, folder))
+}
+
+var testObjectNumber = regexp.MustCompile(`(?:base_|wal_005/)(\d+)`)
+
+func TestDeleteBeforeTarget_KeepsLogsOfLockedBackup(t *testing.T) {
+	viper.Set(internal.DeleteCheckObjectLockSetting, "true")
+	defer viper.Set(internal.DeleteCheckObjectLockSetting, "false")
+
+	folder := newLockingFolder()
+	for _, name := range []string{"1", "2", "3"} {
+		assert.NoError(t, folder.PutObject("basebackups_005/base_"+name+utility.SentinelSuffix, &bytes.Buffer{}))
+		assert.NoError(t, folder.PutObject("basebackups_005/base_"+name+"/part_1.tar", &bytes.Buffer{}))
+		assert.NoError(t, folder.PutObject("basebackups_005/base_"+name+"/part_2.tar", &bytes.Buffer{}))
+		assert.NoError(t, folder.PutObject("wal_005/"+name, &bytes.Buffer{}))
+	}
+	lock := storage.ObjectLock{Mode: storage.ObjectLockModeCompliance, RetainUntil: time.Now().Add(time.Hour)}
+	for _, name := range []string{"base_2" + utility.SentinelSuffix, "base_2/part_1.tar", "base_2/part_2.tar"} {
+		folder.lock("basebackups_005/"+name, lock)
+	}
+
+	// the backups and the WAL are ordered by the number in their names
+	less := func(object1, object2 storage.Object) bool {
+		return testObjectNumber.FindStringSubmatch(object1.GetName())[1] < testObjectNumber.FindStringSubmatch(object2.GetName())[1]
+	}
+	backups, err := internal.FindBackupObjects(folder)
+	assert.NoError(t, err)
+	deleteHandler := internal.NewDeleteHandler(folder, backups, less)
+	var target internal.BackupObject
+	for _, backup := range backups {
+		if backup.GetBackupName() == "base_3" {
+			target = backup
+		}
+	}
+	assert.NoError(t, deleteHandler.DeleteBeforeTarget(target, true))
+
+	// the WAL since the start of the locked backup is needed to restore it
+	assert.ElementsMatch(t, []string{
+		"basebackups_005/base_2" + utility.SentinelSuffix, "basebackups_005/base_2/part_1.tar", "basebackups_005/base_2/part_2.tar",
+		"basebackups_005/base_3" + utility.SentinelSuffix, "basebackups_005/base_3/part_1.tar", "basebackups_005/base_3/part_2.tar",
+		"wal_005/2", "wal_005/3",
+	}, listObjectNames(t, folder))
+	// the lock of the backup is checked once for all its objects
+	assert.NotContains(t, *folder.checked, "in_memory/basebackups_005/base_2/part_1.tar")
+	assert.NotContains(t, *folder.checked, "in_memory/basebackups_005/base_1/part_1.tar")
+}
+
+func TestExtendBackupLock_KeepsLegalHold(t *testing.T) {
+	folder := newLockingFolder().GetSubFolder(utility.BaseBackupPath).(*lockingFolder)
+	assert.NoError(t, folder.PutObject("base_1"+utility.SentinelSuffix, &bytes.Buffer{}))
+	assert.NoError(t, folder.PutObject("base_1/part_1.tar", &bytes.Buffer{}))
+	lock := storage.ObjectLock{Mode: storage.ObjectLockModeGovernance, RetainUntil: time.Now().Add(time.Hour), LegalHold: true}
+	folder.lock("base_1"+utility.SentinelSuffix, lock)
+	folder.lock("base_1/part_1.tar", lock)
+
+	retainUntil := time.Now().Add(24 * time.Hour)
+	assert.NoError(t, internal.ExtendBackupLock(folder, "base_1", retainUntil))
+	extended := storage.ObjectLock{Mode: storage.ObjectLockModeGovernance, RetainUntil: retainUntil, LegalHold: true}
+	assert.Equal(t, extended, folder.locks["in_memory/basebackups_005/base_1"+utility.SentinelSuffix])
+	assert.Equal(t, extended, folder.locks["in_memory/basebackups_005/base_1/part_1.tar"])
+}
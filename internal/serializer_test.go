@@ -36,6 +36,7 @@ func TestDtoSerializer_NewDtoSerializer(t *testing.T) {
 		},
 	}
 
+	defer viper.Set(internal.SerializerTypeSetting, viper.Get(internal.SerializerTypeSetting))
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			viper.Set(internal.SerializerTypeSetting, tt.serializerTypeSettingValue)
@@ -6,6 +6,7 @@ import (
 	"io"
 	"sync/atomic"
 
+	"github.com/apecloud/dataprotection-wal-g/internal/compression"
 	"github.com/apecloud/dataprotection-wal-g/internal/crypto"
 	"github.com/apecloud/dataprotection-wal-g/utility"
 	"github.com/pkg/errors"
@@ -24,6 +25,9 @@ type StorageTarBall struct {
 	tarWriter   *tar.Writer
 	uploader    Uploader
 	name        string
+
+	compressingWriter io.WriteCloser
+	tarCompressions   *AdaptiveCompressions
 }
 
 func (tarBall *StorageTarBall) Name() string {
@@ -60,6 +64,10 @@ func (tarBall *StorageTarBall) CloseTar() error {
 	if err != nil {
 		return errors.Wrap(err, "CloseTar: failed to close underlying writer")
 	}
+	if adaptiveWriter, ok := tarBall.compressingWriter.(compression.AdaptiveWriter); ok && tarBall.tarCompressions != nil {
+		tracelog.DebugLogger.Printf("Part %d is compressed with %s", tarBall.partNumber, adaptiveWriter.Algorithm())
+		tarBall.tarCompressions.Add(tarBall.name, adaptiveWriter.Algorithm())
+	}
 	tracelog.InfoLogger.Printf("Finished writing part %d.\n", tarBall.partNumber)
 	return nil
 }
@@ -110,8 +118,8 @@ func (tarBall *StorageTarBall) startUpload(name string, crypter crypto.Crypter)
 		writerToCompress = &utility.CascadeWriteCloser{WriteCloser: encryptedWriter, Underlying: pipeWriter}
 	}
 
-	return &utility.CascadeWriteCloser{WriteCloser: uploader.Compression().NewWriter(writerToCompress),
-		Underlying: writerToCompress}
+	tarBall.compressingWriter = uploader.Compression().NewWriter(writerToCompress)
+	return &utility.CascadeWriteCloser{WriteCloser: tarBall.compressingWriter, Underlying: writerToCompress}
 }
 
 // Size accumulated in this tarball
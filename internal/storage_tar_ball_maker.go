@@ -2,13 +2,19 @@ package internal
 
 // StorageTarBallMaker creates tarballs that are uploaded to storage.
 type StorageTarBallMaker struct {
-	partCount  int
-	backupName string
-	uploader   Uploader
+	partCount       int
+	backupName      string
+	uploader        Uploader
+	tarCompressions *AdaptiveCompressions
 }
 
 func NewStorageTarBallMaker(backupName string, uploader Uploader) *StorageTarBallMaker {
-	return &StorageTarBallMaker{0, backupName, uploader}
+	return &StorageTarBallMaker{0, backupName, uploader, NewAdaptiveCompressions()}
+}
+
+// TarCompressions returns the algorithms chosen by the adaptive compression for the tarballs made so far
+func (tarBallMaker *StorageTarBallMaker) TarCompressions() map[string]string {
+	return tarBallMaker.tarCompressions.Get()
 }
 
 // Make returns a tarball with required storage fields.
@@ -20,9 +26,10 @@ func (tarBallMaker *StorageTarBallMaker) Make(dedicatedUploader bool) TarBall {
 	}
 	size := int64(0)
 	return &StorageTarBall{
-		partNumber: tarBallMaker.partCount,
-		backupName: tarBallMaker.backupName,
-		uploader:   uploader,
-		partSize:   &size,
+		partNumber:      tarBallMaker.partCount,
+		backupName:      tarBallMaker.backupName,
+		uploader:        uploader,
+		partSize:        &size,
+		tarCompressions: tarBallMaker.tarCompressions,
 	}
 }
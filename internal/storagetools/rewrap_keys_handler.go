@@ -0,0 +1,29 @@
+package storagetools
+
+import (
+	"fmt"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/spf13/viper"
+	"github.com/wal-g/tracelog"
+)
+
+// HandleRewrapKeys re-wraps the envelope encryption data keys with the master key configured in newConfigPath
+func HandleRewrapKeys(folder storage.Folder, newConfigPath string) error {
+	oldMaster := internal.ConfigureCrypterForSpecificConfig(viper.GetViper())
+	if oldMaster == nil {
+		return fmt.Errorf("the current master key is not configured")
+	}
+	newMaster := internal.CrypterFromConfig(newConfigPath)
+	if newMaster == nil {
+		return fmt.Errorf("the new master key is not configured in %s", newConfigPath)
+	}
+
+	rewrapped, err := internal.RewrapDataKeys(folder, oldMaster, newMaster)
+	if err != nil {
+		return fmt.Errorf("rewrap data keys: %v", err)
+	}
+	tracelog.InfoLogger.Printf("Re-wrapped %d data keys", rewrapped)
+	return nil
+}
@@ -0,0 +1,69 @@
+package storagetools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/zstd"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/wal-g/tracelog"
+)
+
+// HandleTrainDictionary trains the zstd dictionary on the decompressed beginnings of the objects in samplesPath
+// and uploads it to the storage root
+func HandleTrainDictionary(samplesPath string, uploader internal.Uploader,
+	maxSamples, sampleSize, dictionarySize int) error {
+	samplesFolder := uploader.Folder().GetSubFolder(samplesPath)
+	samples := make([][]byte, 0, maxSamples)
+	err := storage.IterateObjects(context.Background(), samplesFolder, storage.ListOptions{},
+		func(object storage.Object) error {
+			if len(samples) >= maxSamples {
+				return storage.ErrStopIteration
+			}
+			sample, err := readSample(samplesFolder, object.GetName(), sampleSize)
+			if err != nil {
+				return fmt.Errorf("read sample %s: %v", object.GetName(), err)
+			}
+			if len(sample) > 0 {
+				samples = append(samples, sample)
+			}
+			return nil
+		})
+	if err != nil {
+		return fmt.Errorf("collect samples: %v", err)
+	}
+	tracelog.InfoLogger.Printf("Training zstd dictionary on %d samples from %s", len(samples), samplesPath)
+
+	dictionary, err := zstd.TrainDictionary(samples, dictionarySize)
+	if err != nil {
+		return fmt.Errorf("train dictionary: %v", err)
+	}
+	id, err := internal.UploadCompressionDictionary(uploader, dictionary)
+	if err != nil {
+		return err
+	}
+	tracelog.InfoLogger.Printf("Uploaded zstd dictionary %d of %d bytes, set %s=%d to compress with it",
+		id, len(dictionary), internal.ZstdDictionarySetting, id)
+	return nil
+}
+
+func readSample(folder storage.Folder, objectName string, sampleSize int) ([]byte, error) {
+	readCloser, err := folder.ReadObject(objectName)
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(readCloser, "")
+
+	decompressor := compression.FindDecompressor(path.Ext(objectName))
+	reader, err := internal.DecompressDecryptBytes(readCloser, decompressor)
+	if err != nil {
+		return nil, err
+	}
+	defer utility.LoggedClose(reader, "")
+	return io.ReadAll(io.LimitReader(reader, int64(sampleSize)))
+}
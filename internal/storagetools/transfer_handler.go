@@ -208,9 +208,9 @@ func (h *TransferHandler) transferFilesWorker(
 
 		switch job.jobType {
 		case transferJobTypeCopy:
-			newJob, err = h.copyFile(job)
+			newJob, err = h.copyFile(ctx, job)
 		case transferJobTypeDelete:
-			newJob, err = h.deleteFile(job)
+			newJob, err = h.deleteFile(ctx, job)
 		}
 
 		if err != nil {
@@ -228,14 +228,14 @@ func (h *TransferHandler) transferFilesWorker(
 	}
 }
 
-func (h *TransferHandler) copyFile(job transferJob) (newJob *transferJob, err error) {
-	content, err := h.source.ReadObject(job.filePath)
+func (h *TransferHandler) copyFile(ctx context.Context, job transferJob) (newJob *transferJob, err error) {
+	content, err := storage.ReadObjectWithContext(ctx, h.source, job.filePath)
 	if err != nil {
 		return nil, fmt.Errorf("can't read file from the source storage: %w", err)
 	}
 	defer utility.LoggedClose(content, "can't close object content read from the source storage")
 
-	err = h.target.PutObject(job.filePath, content)
+	err = storage.PutObjectWithContext(ctx, h.target, job.filePath, content)
 	if err != nil {
 		return nil, fmt.Errorf("can't write file to the target storage: %w", err)
 	}
@@ -248,21 +248,21 @@ func (h *TransferHandler) copyFile(job transferJob) (newJob *transferJob, err er
 	return newJob, nil
 }
 
-func (h *TransferHandler) deleteFile(job transferJob) (newJob *transferJob, err error) {
+func (h *TransferHandler) deleteFile(ctx context.Context, job transferJob) (newJob *transferJob, err error) {
 	var appeared bool
 
 	skipCheck := h.cfg.AppearanceChecks == 0
 	if skipCheck {
 		appeared = true
 	} else {
-		appeared, err = h.checkForAppearance(job.prevCheck, job.filePath)
+		appeared, err = h.checkForAppearance(ctx, job.prevCheck, job.filePath)
 		if err != nil {
 			return nil, err
 		}
 	}
 
 	if appeared {
-		err = h.source.DeleteObjects([]string{job.filePath})
+		err = storage.DeleteObjectsWithContext(ctx, h.source, []string{job.filePath})
 		if err != nil {
 			return nil, fmt.Errorf("can't delete file from the source storage: %w", err)
 		}
@@ -293,14 +293,18 @@ func (h *TransferHandler) deleteFile(job transferJob) (newJob *transferJob, err
 	return newJob, nil
 }
 
-func (h *TransferHandler) checkForAppearance(prevCheck time.Time, filePath string) (appeared bool, err error) {
+func (h *TransferHandler) checkForAppearance(ctx context.Context, prevCheck time.Time, filePath string) (appeared bool, err error) {
 	nextCheck := prevCheck.Add(h.cfg.AppearanceChecksInterval)
 	waitTime := time.Until(nextCheck)
 	if waitTime > 0 {
-		time.Sleep(waitTime)
+		select {
+		case <-time.After(waitTime):
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
 	}
 
-	appeared, err = h.target.Exists(filePath)
+	appeared, err = storage.ExistsWithContext(ctx, h.target, filePath)
 	if err != nil {
 		return false, fmt.Errorf("can't check if file exists in the target storage: %w", err)
 	}
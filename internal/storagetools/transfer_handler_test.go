@@ -2,6 +2,7 @@ package storagetools
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"strconv"
@@ -246,7 +247,7 @@ func TestTransferHandler_copyFile(t *testing.T) {
 			filePath: "1",
 		}
 
-		_, err := h.copyFile(job)
+		_, err := h.copyFile(context.Background(), job)
 		require.NoError(t, err)
 
 		file, err := h.target.ReadObject("1")
@@ -266,7 +267,7 @@ func TestTransferHandler_copyFile(t *testing.T) {
 			filePath: "1",
 		}
 
-		_, err := h.copyFile(job)
+		_, err := h.copyFile(context.Background(), job)
 		require.NoError(t, err)
 
 		file, err := h.target.ReadObject("1")
@@ -285,7 +286,7 @@ func TestTransferHandler_copyFile(t *testing.T) {
 			filePath: "1",
 		}
 
-		newJob, err := h.copyFile(job)
+		newJob, err := h.copyFile(context.Background(), job)
 		require.NoError(t, err)
 
 		wantJob := &transferJob{
@@ -303,7 +304,7 @@ func TestTransferHandler_copyFile(t *testing.T) {
 			filePath: "1",
 		}
 
-		_, err := h.copyFile(job)
+		_, err := h.copyFile(context.Background(), job)
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "can't read file")
 	})
@@ -335,7 +336,7 @@ func TestTransferHandler_deleteFile(t *testing.T) {
 			performedChecks: 0,
 		}
 
-		newJob, err := h.deleteFile(job)
+		newJob, err := h.deleteFile(context.Background(), job)
 		assert.NoError(t, err)
 		assert.NotNil(t, newJob)
 		assert.Equal(t, "1", newJob.filePath)
@@ -360,7 +361,7 @@ func TestTransferHandler_deleteFile(t *testing.T) {
 			performedChecks: 0,
 		}
 
-		newJob, err := h.deleteFile(job)
+		newJob, err := h.deleteFile(context.Background(), job)
 		assert.NoError(t, err)
 		assert.Nil(t, newJob)
 
@@ -382,7 +383,7 @@ func TestTransferHandler_deleteFile(t *testing.T) {
 			performedChecks: 0,
 		}
 
-		newJob, err := h.deleteFile(job)
+		newJob, err := h.deleteFile(context.Background(), job)
 		assert.NoError(t, err)
 		assert.Nil(t, newJob)
 
@@ -404,13 +405,13 @@ func TestTransferHandler_deleteFile(t *testing.T) {
 		}
 
 		for i := 0; i < 2; i++ {
-			newJob, err := h.deleteFile(job)
+			newJob, err := h.deleteFile(context.Background(), job)
 			assert.NoError(t, err)
 			require.NotNil(t, newJob)
 			assert.Equal(t, uint(i+1), newJob.performedChecks)
 			job = *newJob
 		}
-		_, err := h.deleteFile(job)
+		_, err := h.deleteFile(context.Background(), job)
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "couldn't wait for the file to appear")
 
@@ -433,7 +434,7 @@ func TestTransferHandler_checkForAppearance(t *testing.T) {
 		thisCheckTime := time.Now()
 		prevCheckTime := thisCheckTime.Add(-50 * time.Millisecond)
 
-		appeared, err := h.checkForAppearance(prevCheckTime, "1")
+		appeared, err := h.checkForAppearance(context.Background(), prevCheckTime, "1")
 		assert.GreaterOrEqual(t, time.Now(), thisCheckTime.Add(50*time.Millisecond))
 		assert.NoError(t, err)
 		assert.True(t, appeared)
@@ -451,7 +452,7 @@ func TestTransferHandler_checkForAppearance(t *testing.T) {
 
 		prevCheckTime := time.Now().Add(-time.Hour)
 
-		appeared, err := h.checkForAppearance(prevCheckTime, "1")
+		appeared, err := h.checkForAppearance(context.Background(), prevCheckTime, "1")
 		assert.NoError(t, err)
 		assert.True(t, appeared)
 	})
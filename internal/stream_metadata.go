@@ -49,5 +49,5 @@ func GetBackupStreamFetcher(backup Backup) (StreamFetcher, error) {
 
 func UploadBackupStreamMetadata(uploader Uploader, metadata interface{}, backupName string) error {
 	sentinelName := StreamMetadataNameFromBackup(backupName)
-	return UploadDto(uploader.Folder(), metadata, sentinelName)
+	return UploadBackupDto(uploader, metadata, sentinelName)
 }
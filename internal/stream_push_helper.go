@@ -7,6 +7,7 @@ import (
 
 	"golang.org/x/sync/errgroup"
 
+	"github.com/apecloud/dataprotection-wal-g/internal/compression"
 	"github.com/apecloud/dataprotection-wal-g/internal/splitmerge"
 
 	"github.com/apecloud/dataprotection-wal-g/utility"
@@ -96,13 +97,35 @@ func (uploader *RegularUploader) PushStreamToDestination(stream io.Reader, dstPa
 	if uploader.dataSize != nil {
 		stream = utility.NewWithSizeReader(stream, uploader.dataSize)
 	}
-	compressed := CompressAndEncrypt(stream, uploader.Compressor, ConfigureCrypter())
+	compressor := uploader.Compressor
+	var adaptiveCompressor *adaptiveCompressorRecorder
+	if compressor != nil && uploader.streamCompressions != nil {
+		adaptiveCompressor = &adaptiveCompressorRecorder{Compressor: compressor}
+		compressor = adaptiveCompressor
+	}
+	compressed := CompressAndEncrypt(stream, compressor, ConfigureCrypter())
 	err := uploader.Upload(dstPath, compressed)
 	tracelog.InfoLogger.Println("FILE PATH:", dstPath)
+	if err == nil && adaptiveCompressor != nil && adaptiveCompressor.writer != nil {
+		tracelog.DebugLogger.Printf("%s is compressed with %s", dstPath, adaptiveCompressor.writer.Algorithm())
+		uploader.streamCompressions.Add(dstPath, adaptiveCompressor.writer.Algorithm())
+	}
 
 	return err
 }
 
+// adaptiveCompressorRecorder keeps the writer of the adaptive compressor to get the algorithm it has chosen
+type adaptiveCompressorRecorder struct {
+	compression.Compressor
+	writer compression.AdaptiveWriter
+}
+
+func (recorder *adaptiveCompressorRecorder) NewWriter(writer io.Writer) io.WriteCloser {
+	compressingWriter := recorder.Compressor.NewWriter(writer)
+	recorder.writer, _ = compressingWriter.(compression.AdaptiveWriter)
+	return compressingWriter
+}
+
 func GetStreamName(backupName string, extension string) string {
 	return utility.SanitizePath(path.Join(backupName, "stream.")) + extension
 }
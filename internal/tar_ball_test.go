@@ -3,11 +3,15 @@ package internal_test
 import (
 	"archive/tar"
 	"bytes"
+	"crypto/rand"
 	"io"
 	"strings"
 	"testing"
 
 	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/auto"
+	"github.com/apecloud/dataprotection-wal-g/internal/compression/lz4"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/memory"
 	"github.com/apecloud/dataprotection-wal-g/testtools"
 	"github.com/stretchr/testify/assert"
 )
@@ -109,3 +113,32 @@ func TestPackFileTo(t *testing.T) {
 	}
 	assert.Equal(t, []byte(mockData), interpreter.Out)
 }
+
+func TestStorageTarBall_RecordsAdaptiveCompression(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	tarBallMaker := internal.NewStorageTarBallMaker("mockBackup", internal.NewRegularUploader(auto.Compressor{}, folder))
+
+	tarBall := tarBallMaker.Make(false)
+	tarBall.SetUp(nil)
+	mockData := make([]byte, 1<<16)
+	_, err := rand.Read(mockData)
+	assert.NoError(t, err)
+	err = tarBall.TarWriter().WriteHeader(&tar.Header{Name: "mock", Size: int64(len(mockData))})
+	assert.NoError(t, err)
+	_, err = tarBall.TarWriter().Write(mockData)
+	assert.NoError(t, err)
+	assert.NoError(t, tarBall.CloseTar())
+	tarBall.AwaitUploads()
+
+	assert.Equal(t, map[string]string{"part_001.tar.auto": auto.AlgorithmNone}, tarBallMaker.TarCompressions())
+}
+
+func TestPushStream_RecordsAdaptiveCompression(t *testing.T) {
+	folder := memory.NewFolder("", memory.NewStorage())
+	uploader := internal.NewRegularUploader(auto.Compressor{}, folder)
+
+	err := uploader.PushStreamToDestination(bytes.NewReader(make([]byte, 1<<16)), "stream.auto")
+	assert.NoError(t, err)
+
+	assert.Equal(t, map[string]string{"stream.auto": lz4.AlgorithmName}, internal.StreamCompressions(uploader))
+}
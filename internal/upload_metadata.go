@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"strings"
+
+	"github.com/apecloud/dataprotection-wal-g/internal/compression"
+	"github.com/apecloud/dataprotection-wal-g/internal/crypto"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+)
+
+// Keys of the metadata written along with the uploaded objects.
+// Underscores are used since Azure requires the metadata keys to be valid C# identifiers.
+const (
+	ObjectMetadataBackupName     = "walg_backup_name"
+	ObjectMetadataDatabaseType   = "walg_db_type"
+	ObjectMetadataCompression    = "walg_compression"
+	ObjectMetadataCrypter        = "walg_crypter"
+	ObjectMetadataRetentionClass = "walg_retention_class"
+	ObjectMetadataObjectKind     = "walg_object_kind"
+)
+
+// Values of the ObjectMetadataObjectKind
+const (
+	ObjectKindWal      = "wal"
+	ObjectKindBackup   = "backup"
+	ObjectKindSentinel = "sentinel"
+	ObjectKindOther    = "other"
+)
+
+// logFolderNames are the folders of the continuous archives: PostgreSQL WAL, MySQL binlogs and MongoDB oplog
+var logFolderNames = map[string]bool{
+	strings.TrimSuffix(utility.WalPath, "/"): true,
+	"binlog_" + utility.VersionStr:           true,
+	"oplog_" + utility.VersionStr:            true,
+}
+
+// UploadMetadata describes the metadata the Uploader attaches to every uploaded object
+type UploadMetadata struct {
+	DatabaseType   string
+	Compression    string
+	Crypter        string
+	RetentionClass string
+}
+
+// NewUploadMetadata fills in the metadata of the configured database, compressor and crypter
+func NewUploadMetadata(compressor compression.Compressor, crypter crypto.Crypter) *UploadMetadata {
+	metadata := &UploadMetadata{DatabaseType: strings.ToLower(configuredDatabaseType)}
+	if compressor != nil {
+		metadata.Compression = compressor.FileExtension()
+	}
+	if crypter != nil {
+		metadata.Crypter = crypter.Name()
+	}
+	metadata.RetentionClass, _ = GetSetting(RetentionClassSetting)
+	return metadata
+}
+
+// ForObject returns the metadata of the object at the storage path.
+// The object kind and the backup name are derived from the storage layout.
+func (metadata *UploadMetadata) ForObject(objectPath string) map[string]string {
+	objectKind, backupName := classifyObjectPath(objectPath)
+	result := map[string]string{
+		ObjectMetadataObjectKind: objectKind,
+	}
+	addIfNotEmpty := func(key, value string) {
+		if value != "" {
+			result[key] = value
+		}
+	}
+	addIfNotEmpty(ObjectMetadataBackupName, backupName)
+	addIfNotEmpty(ObjectMetadataDatabaseType, metadata.DatabaseType)
+	addIfNotEmpty(ObjectMetadataCompression, metadata.Compression)
+	addIfNotEmpty(ObjectMetadataCrypter, metadata.Crypter)
+	addIfNotEmpty(ObjectMetadataRetentionClass, metadata.RetentionClass)
+	return result
+}
+
+func classifyObjectPath(objectPath string) (objectKind string, backupName string) {
+	parts := strings.Split(strings.Trim(objectPath, "/"), "/")
+	for i, part := range parts {
+		if logFolderNames[part] {
+			return ObjectKindWal, ""
+		}
+		if part != strings.TrimSuffix(utility.BaseBackupPath, "/") || i+1 >= len(parts) {
+			continue
+		}
+		name := parts[i+1]
+		if i+2 == len(parts) && strings.HasSuffix(name, utility.SentinelSuffix) {
+			return ObjectKindSentinel, strings.TrimSuffix(name, utility.SentinelSuffix)
+		}
+		if i+2 == len(parts) {
+			// other files in the root of the backups folder, e.g. the backup metadata
+			return ObjectKindBackup, ""
+		}
+		return ObjectKindBackup, name
+	}
+	return ObjectKindOther, ""
+}
+
+// isLogObject checks if the object at the path is in a log folder, e.g. the WAL segment
+func isLogObject(objectPath string) bool {
+	objectKind, _ := classifyObjectPath(objectPath)
+	return objectKind == ObjectKindWal
+}
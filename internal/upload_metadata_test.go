@@ -0,0 +1,97 @@
+package internal_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/apecloud/dataprotection-wal-g/internal"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/memory"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/apecloud/dataprotection-wal-g/testtools"
+	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// metadataFolder keeps the metadata of the objects uploaded to the in-memory folder by their full paths
+type metadataFolder struct {
+	*memory.Folder
+	metadata map[string]map[string]string
+}
+
+func (f *metadataFolder) GetSubFolder(subFolderRelativePath string) storage.Folder {
+	return &metadataFolder{Folder: f.Folder.GetSubFolder(subFolderRelativePath).(*memory.Folder), metadata: f.metadata}
+}
+
+func (f *metadataFolder) PutObjectWithMetadata(_ context.Context, name string, content io.Reader,
+	metadata map[string]string) error {
+	f.metadata[storage.JoinPath(f.GetPath(), name)] = metadata
+	return f.PutObject(name, content)
+}
+
+func TestUploadMetadata_ForObject(t *testing.T) {
+	metadata := &internal.UploadMetadata{
+		DatabaseType:   "pg",
+		Compression:    "lz4",
+		RetentionClass: "weekly",
+	}
+
+	testCases := []struct {
+		path       string
+		kind       string
+		backupName string
+	}{
+		{"prefix/wal_005/000000010000000000000001.lz4", internal.ObjectKindWal, ""},
+		{"prefix/binlog_005/mysql-bin.000001.lz4", internal.ObjectKindWal, ""},
+		{"prefix/basebackups_005/base_000000010000000000000002_backup_stop_sentinel.json",
+			internal.ObjectKindSentinel, "base_000000010000000000000002"},
+		{"prefix/basebackups_005/base_000000010000000000000002/tar_partitions/part_1.tar.lz4",
+			internal.ObjectKindBackup, "base_000000010000000000000002"},
+		{"prefix/some_other_file", internal.ObjectKindOther, ""},
+	}
+	for _, tc := range testCases {
+		objectMetadata := metadata.ForObject(tc.path)
+		assert.Equal(t, tc.kind, objectMetadata[internal.ObjectMetadataObjectKind], tc.path)
+		assert.Equal(t, tc.backupName, objectMetadata[internal.ObjectMetadataBackupName], tc.path)
+		assert.Equal(t, "pg", objectMetadata[internal.ObjectMetadataDatabaseType], tc.path)
+		assert.Equal(t, "lz4", objectMetadata[internal.ObjectMetadataCompression], tc.path)
+		assert.Equal(t, "weekly", objectMetadata[internal.ObjectMetadataRetentionClass], tc.path)
+		_, hasCrypter := objectMetadata[internal.ObjectMetadataCrypter]
+		assert.False(t, hasCrypter, tc.path)
+	}
+}
+
+func TestUploadSentinel_AttachesMetadata(t *testing.T) {
+	folder := &metadataFolder{Folder: testtools.MakeDefaultInMemoryStorageFolder(), metadata: map[string]map[string]string{}}
+	uploader := internal.NewRegularUploader(nil, folder.GetSubFolder(utility.BaseBackupPath))
+	uploader.Metadata = &internal.UploadMetadata{DatabaseType: "pg"}
+
+	backupName := "base_000000010000000000000002"
+	require.NoError(t, internal.UploadSentinel(uploader, map[string]string{}, backupName))
+	require.NoError(t, internal.UploadBackupStreamMetadata(uploader, map[string]string{}, backupName))
+
+	sentinelMetadata := folder.metadata["in_memory/basebackups_005/"+backupName+utility.SentinelSuffix]
+	assert.Equal(t, internal.ObjectKindSentinel, sentinelMetadata[internal.ObjectMetadataObjectKind])
+	assert.Equal(t, backupName, sentinelMetadata[internal.ObjectMetadataBackupName])
+	assert.Equal(t, "pg", sentinelMetadata[internal.ObjectMetadataDatabaseType])
+
+	streamMetadata := folder.metadata["in_memory/basebackups_005/"+internal.StreamMetadataNameFromBackup(backupName)]
+	assert.Equal(t, internal.ObjectKindBackup, streamMetadata[internal.ObjectMetadataObjectKind])
+	assert.Equal(t, backupName, streamMetadata[internal.ObjectMetadataBackupName])
+}
+
+func TestNewRegularUploader_SetsMetadata(t *testing.T) {
+	defer viper.Set(internal.UploadObjectMetadataSetting, viper.Get(internal.UploadObjectMetadataSetting))
+	folder := testtools.MakeDefaultInMemoryStorageFolder()
+
+	viper.Set(internal.UploadObjectMetadataSetting, false)
+	assert.Nil(t, internal.NewRegularUploader(nil, folder).Metadata)
+
+	// the uploaders made without the compressor, e.g. by SQL Server, get the metadata too
+	viper.Set(internal.UploadObjectMetadataSetting, true)
+	uploader := internal.NewRegularUploader(nil, folder)
+	require.NotNil(t, uploader.Metadata)
+	assert.Empty(t, uploader.Metadata.Compression)
+}
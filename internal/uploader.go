@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"path/filepath"
@@ -10,9 +11,11 @@ import (
 	"github.com/apecloud/dataprotection-wal-g/internal/abool"
 
 	"github.com/apecloud/dataprotection-wal-g/internal/compression"
+	"github.com/apecloud/dataprotection-wal-g/internal/integrity"
 	"github.com/apecloud/dataprotection-wal-g/internal/ioextensions"
 	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
 	"github.com/apecloud/dataprotection-wal-g/utility"
+	"github.com/spf13/viper"
 	"github.com/wal-g/tracelog"
 )
 
@@ -43,6 +46,14 @@ type RegularUploader struct {
 	failed          *abool.AtomicBool
 	tarSize         *int64
 	dataSize        *int64
+	// Metadata is attached to every uploaded object when set
+	Metadata *UploadMetadata
+	// ObjectLock is set on every uploaded backup object when set
+	ObjectLock *storage.ObjectLock
+	// IntegrityRecorder collects the checksums of the backup objects for the integrity manifest when set
+	IntegrityRecorder *integrity.Recorder
+	// streamCompressions collects the algorithms chosen by the adaptive compression for the pushed streams
+	streamCompressions *AdaptiveCompressions
 }
 
 var _ Uploader = &RegularUploader{}
@@ -65,6 +76,8 @@ type UploadObject struct {
 	Content io.Reader
 }
 
+// NewRegularUploader creates the uploader to the folder. It attaches the object metadata to the uploads
+// if WALG_UPLOAD_OBJECT_METADATA is set, so every uploader gets it regardless of how it is configured.
 func NewRegularUploader(
 	compressor compression.Compressor,
 	uploadingLocation storage.Folder,
@@ -76,6 +89,11 @@ func NewRegularUploader(
 		tarSize:         new(int64),
 		dataSize:        new(int64),
 		failed:          abool.New(),
+
+		streamCompressions: NewAdaptiveCompressions(),
+	}
+	if viper.GetBool(UploadObjectMetadataSetting) {
+		uploader.Metadata = NewUploadMetadata(compressor, ConfigureCrypter())
 	}
 	return uploader
 }
@@ -127,12 +145,17 @@ func (uploader *RegularUploader) Finish() {
 // Clone creates similar Uploader with new WaitGroup
 func (uploader *RegularUploader) Clone() Uploader {
 	return &RegularUploader{
-		UploadingFolder: uploader.UploadingFolder,
-		Compressor:      uploader.Compressor,
-		waitGroup:       &sync.WaitGroup{},
-		failed:          abool.NewBool(uploader.Failed()),
-		tarSize:         uploader.tarSize,
-		dataSize:        uploader.dataSize,
+		UploadingFolder:   uploader.UploadingFolder,
+		Compressor:        uploader.Compressor,
+		waitGroup:         &sync.WaitGroup{},
+		failed:            abool.NewBool(uploader.Failed()),
+		tarSize:           uploader.tarSize,
+		dataSize:          uploader.dataSize,
+		Metadata:          uploader.Metadata,
+		ObjectLock:        uploader.ObjectLock,
+		IntegrityRecorder: uploader.IntegrityRecorder,
+
+		streamCompressions: uploader.streamCompressions,
 	}
 }
 
@@ -173,8 +196,11 @@ func (uploader *RegularUploader) Upload(path string, content io.Reader) error {
 	if uploader.tarSize != nil {
 		content = utility.NewWithSizeReader(content, uploader.tarSize)
 	}
-	err := uploader.UploadingFolder.PutObject(path, content)
-	if err != nil {
+	content, recordObject := uploader.trackObject(path, content)
+	err := uploader.putObject(path, content)
+	if err == nil {
+		recordObject()
+	} else {
 		WalgMetrics.uploadedFilesFailedTotal.Inc()
 		uploader.failed.Set()
 		tracelog.ErrorLogger.Printf(tracelog.GetErrorFormatter()+"\n", err)
@@ -183,6 +209,69 @@ func (uploader *RegularUploader) Upload(path string, content io.Reader) error {
 	return nil
 }
 
+// getRegularUploader returns the RegularUploader doing the actual uploads, or nil if there is none
+func getRegularUploader(uploader Uploader) *RegularUploader {
+	switch typed := uploader.(type) {
+	case *RegularUploader:
+		return typed
+	case *SplitStreamUploader:
+		return getRegularUploader(typed.Uploader)
+	default:
+		return nil
+	}
+}
+
+// StreamCompressions returns the algorithms chosen by the adaptive compression for the streams pushed so far,
+// keyed by the stream path. It returns nil if no algorithm was chosen adaptively.
+func StreamCompressions(uploader Uploader) map[string]string {
+	regularUploader := getRegularUploader(uploader)
+	if regularUploader == nil || regularUploader.streamCompressions == nil {
+		return nil
+	}
+	return regularUploader.streamCompressions.Get()
+}
+
+// trackObject makes the integrity recorder, if set, record the object listed in the backup manifest.
+// The returned function should be called once the object is uploaded successfully.
+func (uploader *RegularUploader) trackObject(path string, content io.Reader) (io.Reader, func()) {
+	if uploader.IntegrityRecorder == nil {
+		return content, func() {}
+	}
+	objectPath := storage.JoinPath(uploader.UploadingFolder.GetPath(), path)
+	if !isManifestObject(objectPath) {
+		return content, func() {}
+	}
+	return uploader.IntegrityRecorder.Track(objectPath, content)
+}
+
+func (uploader *RegularUploader) putObject(path string, content io.Reader) error {
+	if uploader.Metadata == nil && uploader.ObjectLock == nil {
+		return uploader.UploadingFolder.PutObject(path, content)
+	}
+
+	ctx := context.Background()
+	objectPath := storage.JoinPath(uploader.UploadingFolder.GetPath(), path)
+	var err error
+	if uploader.Metadata == nil {
+		err = storage.PutObjectWithContext(ctx, uploader.UploadingFolder, path, content)
+	} else {
+		metadata := uploader.Metadata.ForObject(objectPath)
+		err = storage.PutObjectWithMetadata(ctx, uploader.UploadingFolder, path, content, metadata)
+	}
+	if err != nil || uploader.ObjectLock == nil || !isLockedBackupObject(objectPath) {
+		return err
+	}
+	err = storage.SetObjectLock(ctx, uploader.UploadingFolder, path, *uploader.ObjectLock)
+	if err != nil {
+		// the backup must not be left with an unprotected object, so the object is removed and the upload fails
+		if deleteErr := uploader.UploadingFolder.DeleteObjects([]string{path}); deleteErr != nil {
+			tracelog.WarningLogger.Printf("Failed to remove the unlocked object %s: %v", objectPath, deleteErr)
+		}
+		return fmt.Errorf("failed to lock %s: %w", objectPath, err)
+	}
+	return nil
+}
+
 // UploadMultiple uploads multiple objects from the start of the slice,
 // returning the first error if any. Note that this operation is not atomic
 // TODO : unit tests
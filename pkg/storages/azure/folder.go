@@ -18,6 +18,7 @@ import (
 	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
@@ -242,13 +243,20 @@ type Folder struct {
 	path                string
 }
 
+var _ storage.ContextFolder = &Folder{}
+var _ storage.PagedFolder = &Folder{}
+var _ storage.MetadataFolder = &Folder{}
+
 func (folder *Folder) GetPath() string {
 	return folder.path
 }
 
 func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
+	return folder.ExistsWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ExistsWithContext(ctx context.Context, objectRelativePath string) (bool, error) {
 	path := storage.JoinPath(folder.path, objectRelativePath)
-	ctx := context.Background()
 	blobClient := folder.containerClient.NewBlockBlobClient(path)
 	_, err := blobClient.GetProperties(ctx, nil)
 	var stgErr *azcore.ResponseError
@@ -261,15 +269,69 @@ func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
 	return true, nil
 }
 
+func (folder *Folder) StatObject(ctx context.Context, objectRelativePath string) (*storage.ObjectInfo, error) {
+	path := storage.JoinPath(folder.path, objectRelativePath)
+	blobClient := folder.containerClient.NewBlockBlobClient(path)
+	properties, err := blobClient.GetProperties(ctx, nil)
+	var stgErr *azcore.ResponseError
+	if err != nil && errors.As(err, &stgErr) && stgErr.ErrorCode == string(bloberror.BlobNotFound) {
+		return nil, storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return nil, NewFolderError(err, "Unable to stat object %v", path)
+	}
+
+	info := &storage.ObjectInfo{
+		Name:         objectRelativePath,
+		UserMetadata: make(map[string]string, len(properties.Metadata)),
+	}
+	if properties.ContentLength != nil {
+		info.Size = *properties.ContentLength
+	}
+	if properties.LastModified != nil {
+		info.LastModified = *properties.LastModified
+	}
+	if properties.ETag != nil {
+		info.ETag = string(*properties.ETag)
+	}
+	for key, value := range properties.Metadata {
+		if value != nil {
+			info.UserMetadata[key] = *value
+		}
+	}
+	return info, nil
+}
+
 func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
-	blobPager := folder.containerClient.NewListBlobsHierarchyPager("/", &container.ListBlobsHierarchyOptions{Prefix: &folder.path})
+	return folder.ListFolderWithContext(context.Background())
+}
+
+func (folder *Folder) ListFolderWithContext(ctx context.Context) (objects []storage.Object, subFolders []storage.Folder, err error) {
+	err = folder.ListFolderPages(ctx, storage.ListOptions{}, func(pageObjects []storage.Object, pageSubFolders []storage.Folder) error {
+		objects = append(objects, pageObjects...)
+		subFolders = append(subFolders, pageSubFolders...)
+		return nil
+	})
+	return objects, subFolders, err
+}
+
+// ListFolderPages lists the folder by the blob service pages. Azure has no "start after" listing,
+// so the pages before opts.StartAfter are fetched and skipped.
+func (folder *Folder) ListFolderPages(ctx context.Context, opts storage.ListOptions,
+	pageFn func(objects []storage.Object, subFolders []storage.Folder) error) error {
+	prefix := folder.path + opts.Prefix
+	blobPager := folder.containerClient.NewListBlobsHierarchyPager("/", &container.ListBlobsHierarchyOptions{Prefix: &prefix})
 	for blobPager.More() {
-		page, err := blobPager.NextPage(context.Background())
+		page, err := blobPager.NextPage(ctx)
 		if err != nil {
-			return objects, subFolders, err
+			return err
 		}
+		objects := make([]storage.Object, 0, len(page.Segment.BlobItems))
 		for _, blob := range page.Segment.BlobItems {
 			objName := strings.TrimPrefix(*blob.Name, folder.path)
+			if !storage.MatchesListOptions(objName, opts) {
+				continue
+			}
 			updated := *blob.Properties.LastModified
 
 			objects = append(objects, storage.NewLocalObject(objName, updated, *blob.Properties.ContentLength))
@@ -277,9 +339,13 @@ func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []stora
 
 		//Get subFolder names
 		blobPrefixes := page.Segment.BlobPrefixes
+		subFolders := make([]storage.Folder, 0, len(blobPrefixes))
 		//add subFolders to the list of storage folders
 		for _, blobPrefix := range blobPrefixes {
 			subFolderPath := *blobPrefix.Name
+			if !storage.MatchesListOptions(strings.TrimPrefix(subFolderPath, folder.path), opts) {
+				continue
+			}
 
 			subFolders = append(subFolders, NewFolder(
 				folder.uploadStreamOptions,
@@ -288,8 +354,11 @@ func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []stora
 				folder.timeout,
 				subFolderPath))
 		}
+		if err := pageFn(objects, subFolders); err != nil {
+			return err
+		}
 	}
-	return objects, subFolders, err
+	return nil
 }
 
 func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder {
@@ -302,10 +371,30 @@ func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder
 }
 
 func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ReadObjectWithContext(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectRange(ctx, objectRelativePath, 0, -1)
+}
+
+func (folder *Folder) ReadObjectRange(ctx context.Context, objectRelativePath string, offset, length int64) (io.ReadCloser, error) {
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
 	path := storage.JoinPath(folder.path, objectRelativePath)
 	blobClient := folder.containerClient.NewBlockBlobClient(path)
 
-	get, err := blobClient.DownloadStream(context.Background(), nil)
+	var options *blob.DownloadStreamOptions
+	if offset > 0 || length > 0 {
+		// Count equal to zero means "up to the end of the blob"
+		count := length
+		if count < 0 {
+			count = 0
+		}
+		options = &blob.DownloadStreamOptions{Range: blob.HTTPRange{Offset: offset, Count: count}}
+	}
+	get, err := blobClient.DownloadStream(ctx, options)
 	if err != nil {
 		var storageError *azcore.ResponseError
 		errors.As(err, &storageError)
@@ -319,12 +408,27 @@ func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, erro
 }
 
 func (folder *Folder) PutObject(name string, content io.Reader) error {
+	return folder.PutObjectWithContext(context.Background(), name, content)
+}
+
+func (folder *Folder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	return folder.PutObjectWithMetadata(ctx, name, content, nil)
+}
+
+func (folder *Folder) PutObjectWithMetadata(ctx context.Context, name string, content io.Reader, metadata map[string]string) error {
 	tracelog.DebugLogger.Printf("Put %v into %v\n", name, folder.path)
 	//Upload content to a block blob using full path
 	path := storage.JoinPath(folder.path, name)
 	blobClient := folder.containerClient.NewBlockBlobClient(path)
 
-	_, err := blobClient.UploadStream(context.Background(), content, &folder.uploadStreamOptions)
+	uploadStreamOptions := folder.uploadStreamOptions
+	if len(metadata) > 0 {
+		uploadStreamOptions.Metadata = make(map[string]*string, len(metadata))
+		for key, value := range metadata {
+			uploadStreamOptions.Metadata[key] = to.Ptr(value)
+		}
+	}
+	_, err := blobClient.UploadStream(ctx, content, &uploadStreamOptions)
 	if err != nil {
 		return NewFolderError(err, "Unable to upload blob %v", name)
 	}
@@ -334,9 +438,13 @@ func (folder *Folder) PutObject(name string, content io.Reader) error {
 }
 
 func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
+	return folder.CopyObjectWithContext(context.Background(), srcPath, dstPath)
+}
+
+func (folder *Folder) CopyObjectWithContext(ctx context.Context, srcPath string, dstPath string) error {
 	var exists bool
 	var err error
-	if exists, err = folder.Exists(srcPath); !exists {
+	if exists, err = folder.ExistsWithContext(ctx, srcPath); !exists {
 		if err == nil {
 			return errors.New("object do not exists")
 		}
@@ -352,19 +460,23 @@ func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
 		return NewFolderError(err, "Unable to init Azure Blob client for copy destination %s", dstPath)
 	}
 	tireAccess := blob.AccessTierHot
-	_, err = dstClient.StartCopyFromURL(context.Background(), srcClient.URL(),
+	_, err = dstClient.StartCopyFromURL(ctx, srcClient.URL(),
 		&blob.StartCopyFromURLOptions{Tier: &tireAccess})
 	return err
 }
 
 func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
+	return folder.DeleteObjectsWithContext(context.Background(), objectRelativePaths)
+}
+
+func (folder *Folder) DeleteObjectsWithContext(ctx context.Context, objectRelativePaths []string) error {
 	for _, objectRelativePath := range objectRelativePaths {
 		//Delete blob using blobClient obtained from full path to blob
 		path := storage.JoinPath(folder.path, objectRelativePath)
 		blobClient := folder.containerClient.NewBlockBlobClient(path)
 		tracelog.DebugLogger.Printf("Delete %v\n", path)
 		deleteType := azblob.DeleteSnapshotsOptionTypeInclude
-		_, err := blobClient.Delete(context.Background(),
+		_, err := blobClient.Delete(ctx,
 			&azblob.DeleteBlobOptions{DeleteSnapshots: &deleteType})
 		var stgErr *azcore.ResponseError
 		if err != nil && errors.As(err, &stgErr) && stgErr.ErrorCode == string(bloberror.BlobNotFound) {
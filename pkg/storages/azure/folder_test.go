@@ -18,6 +18,17 @@ func TestAzureFolder(t *testing.T) {
 	storage.RunFolderTest(storageFolder, t)
 }
 
+func TestAzureContextFolder(t *testing.T) {
+	t.Skip("Credentials needed to run Azure Storage tests")
+
+	storageFolder, err := ConfigureFolder("azure://test-container/test-folder/Sub0",
+		make(map[string]string))
+
+	assert.NoError(t, err)
+
+	storage.RunContextFolderTest(storageFolder.(storage.ContextFolder), t)
+}
+
 var ConfigureAuthType = configureAuthType
 
 func TestConfigureAccessKeyAuthType(t *testing.T) {
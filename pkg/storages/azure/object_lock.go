@@ -0,0 +1,68 @@
+package azure
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+)
+
+var _ storage.LockingFolder = &Folder{}
+
+// SetObjectLock sets the blob immutability policy and turns the legal hold on. The container must have
+// the version-level immutability support enabled. The compliance mode is mapped to the locked policy,
+// the governance mode is mapped to the unlocked one. The legal hold is never released here.
+func (folder *Folder) SetObjectLock(ctx context.Context, objectRelativePath string, lock storage.ObjectLock) error {
+	path := storage.JoinPath(folder.path, objectRelativePath)
+	blobClient := folder.containerClient.NewBlockBlobClient(path)
+	if lock.Mode != storage.ObjectLockModeNone {
+		policyMode := blob.ImmutabilityPolicySettingUnlocked
+		if lock.Mode == storage.ObjectLockModeCompliance {
+			policyMode = blob.ImmutabilityPolicySettingLocked
+		}
+		_, err := blobClient.SetImmutabilityPolicy(ctx, lock.RetainUntil,
+			&blob.SetImmutabilityPolicyOptions{Mode: to.Ptr(policyMode)})
+		if err != nil {
+			return NewFolderError(err, "Unable to set immutability policy of blob %v", path)
+		}
+	}
+	if lock.LegalHold {
+		if _, err := blobClient.SetLegalHold(ctx, true, nil); err != nil {
+			return NewFolderError(err, "Unable to set legal hold of blob %v", path)
+		}
+	}
+	return nil
+}
+
+func (folder *Folder) GetObjectLock(ctx context.Context, objectRelativePath string) (storage.ObjectLock, error) {
+	path := storage.JoinPath(folder.path, objectRelativePath)
+	blobClient := folder.containerClient.NewBlockBlobClient(path)
+	properties, err := blobClient.GetProperties(ctx, nil)
+	var stgErr *azcore.ResponseError
+	if err != nil && errors.As(err, &stgErr) && stgErr.ErrorCode == string(bloberror.BlobNotFound) {
+		return storage.ObjectLock{}, storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return storage.ObjectLock{}, NewFolderError(err, "Unable to get lock of blob %v", path)
+	}
+
+	var lock storage.ObjectLock
+	if properties.ImmutabilityPolicyMode != nil && properties.ImmutabilityPolicyExpiresOn != nil {
+		switch *properties.ImmutabilityPolicyMode {
+		case blob.ImmutabilityPolicyModeLocked:
+			lock.Mode = storage.ObjectLockModeCompliance
+		case blob.ImmutabilityPolicyModeUnlocked:
+			lock.Mode = storage.ObjectLockModeGovernance
+		}
+		lock.RetainUntil = *properties.ImmutabilityPolicyExpiresOn
+	}
+	if properties.LegalHold != nil {
+		lock.LegalHold = *properties.LegalHold
+	}
+	return lock, nil
+}
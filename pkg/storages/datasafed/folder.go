@@ -33,6 +33,8 @@ type Folder struct {
 	ctx     context.Context
 }
 
+var _ storage.ContextFolder = &Folder{}
+
 func ConfigureFolder(configPath string, settings map[string]string) (storage.Folder, error) {
 	return NewFolder(configPath, "")
 }
@@ -67,7 +69,11 @@ func (folder *Folder) GetPath() string {
 }
 
 func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
-	err = folder.storage.List(folder.ctx, folder.subPath, &ds.ListOptions{}, func(entry ds.DirEntry) error {
+	return folder.ListFolderWithContext(folder.ctx)
+}
+
+func (folder *Folder) ListFolderWithContext(ctx context.Context) (objects []storage.Object, subFolders []storage.Folder, err error) {
+	err = folder.storage.List(ctx, folder.subPath, &ds.ListOptions{}, func(entry ds.DirEntry) error {
 		if entry.IsDir() {
 			// not using GetSubFolder() by intention
 			subPath := path.Join(folder.subPath, entry.Name()) + "/"
@@ -85,14 +91,18 @@ func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []stora
 }
 
 func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
+	return folder.DeleteObjectsWithContext(folder.ctx, objectRelativePaths)
+}
+
+func (folder *Folder) DeleteObjectsWithContext(ctx context.Context, objectRelativePaths []string) error {
 	for _, fileName := range objectRelativePaths {
 		filePath := folder.GetFilePath(fileName)
-		err := folder.storage.Remove(folder.ctx, filePath, false)
+		err := folder.storage.Remove(ctx, filePath, false)
 		if err == nil || folder.isNotFoundError(err) {
 			continue
 		}
 		// remove all for the dir
-		err = folder.storage.Remove(folder.ctx, filePath, true)
+		err = folder.storage.Remove(ctx, filePath, true)
 		if folder.isNotFoundError(err) {
 			continue
 		}
@@ -104,14 +114,19 @@ func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
 }
 
 func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
-	_, err := folder.storage.Stat(folder.ctx, folder.GetFilePath(objectRelativePath))
+	return folder.ExistsWithContext(folder.ctx, objectRelativePath)
+}
+
+func (folder *Folder) ExistsWithContext(ctx context.Context, objectRelativePath string) (bool, error) {
+	result, err := folder.storage.Stat(ctx, folder.GetFilePath(objectRelativePath))
 	if folder.isNotFoundError(err) {
 		return false, nil
 	}
 	if err != nil {
 		return false, NewError(err, "Unable to stat object %v", objectRelativePath)
 	}
-	return true, nil
+	// some backends stat a missing path as an empty directory
+	return result.Entries > 0, nil
 }
 
 func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder {
@@ -132,8 +147,19 @@ func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder
 }
 
 func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectWithContext(folder.ctx, objectRelativePath)
+}
+
+func (folder *Folder) ReadObjectWithContext(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectRange(ctx, objectRelativePath, 0, -1)
+}
+
+func (folder *Folder) ReadObjectRange(ctx context.Context, objectRelativePath string, offset, length int64) (io.ReadCloser, error) {
+	if length < 0 {
+		length = -1
+	}
 	filePath := folder.GetFilePath(objectRelativePath)
-	reader, err := folder.storage.OpenFile(folder.ctx, filePath, 0, -1)
+	reader, err := folder.storage.OpenFile(ctx, filePath, offset, length)
 	if err != nil {
 		if errors.Is(err, ds.ErrObjectNotFound) {
 			return reader, storage.NewObjectNotFoundError(objectRelativePath)
@@ -143,10 +169,36 @@ func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, erro
 	return reader, nil
 }
 
+func (folder *Folder) StatObject(ctx context.Context, objectRelativePath string) (*storage.ObjectInfo, error) {
+	filePath := folder.GetFilePath(objectRelativePath)
+	var info *storage.ObjectInfo
+	err := folder.storage.List(ctx, filePath, &ds.ListOptions{PathIsFile: true}, func(entry ds.DirEntry) error {
+		if !entry.IsDir() {
+			info = &storage.ObjectInfo{
+				Name:         objectRelativePath,
+				Size:         entry.Size(),
+				LastModified: entry.MTime(),
+			}
+		}
+		return nil
+	})
+	if (err == nil && info == nil) || folder.isNotFoundError(err) {
+		return nil, storage.NewObjectNotFoundError(objectRelativePath)
+	}
+	if err != nil {
+		return nil, NewError(err, "Unable to stat object %v", objectRelativePath)
+	}
+	return info, nil
+}
+
 func (folder *Folder) PutObject(name string, content io.Reader) error {
+	return folder.PutObjectWithContext(folder.ctx, name, content)
+}
+
+func (folder *Folder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
 	tracelog.DebugLogger.Printf("Put %v into %v\n", name, folder.subPath)
 	filePath := folder.GetFilePath(name)
-	err := folder.storage.Push(folder.ctx, content, filePath)
+	err := folder.storage.Push(ctx, content, filePath)
 	if err != nil {
 		return NewError(err, "Unable to open file %v", filePath)
 	}
@@ -154,11 +206,15 @@ func (folder *Folder) PutObject(name string, content io.Reader) error {
 }
 
 func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
-	readerCloser, err := folder.storage.OpenFile(folder.ctx, srcPath, 0, -1)
+	return folder.CopyObjectWithContext(folder.ctx, srcPath, dstPath)
+}
+
+func (folder *Folder) CopyObjectWithContext(ctx context.Context, srcPath string, dstPath string) error {
+	readerCloser, err := folder.storage.OpenFile(ctx, srcPath, 0, -1)
 	if err != nil {
 		return err
 	}
-	return folder.PutObject(dstPath, readerCloser)
+	return folder.PutObjectWithContext(ctx, dstPath, readerCloser)
 }
 
 func (folder *Folder) isNotFoundError(err error) bool {
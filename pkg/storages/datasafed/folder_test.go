@@ -0,0 +1,19 @@
+package datasafed
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apecloud/datasafed/pkg/storage/rclone"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+)
+
+func TestDatasafedContextFolder(t *testing.T) {
+	ctx := context.Background()
+	memoryStorage, err := rclone.New(ctx, map[string]string{"type": "memory"}, "")
+	assert.NoError(t, err)
+
+	storage.RunContextFolderTest(&Folder{storage: memoryStorage, ctx: ctx}, t)
+}
@@ -1,6 +1,7 @@
 package fs
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -24,6 +25,8 @@ type Folder struct {
 	subpath  string
 }
 
+var _ storage.ContextFolder = &Folder{}
+
 func NewFolder(rootPath string, subPath string) *Folder {
 	return &Folder{rootPath, subPath}
 }
@@ -44,6 +47,13 @@ func (folder *Folder) GetPath() string {
 }
 
 func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
+	return folder.ListFolderWithContext(context.Background())
+}
+
+func (folder *Folder) ListFolderWithContext(ctx context.Context) (objects []storage.Object, subFolders []storage.Folder, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 	files, err := ioutil.ReadDir(path.Join(folder.rootPath, folder.subpath))
 	if err != nil {
 		return nil, nil, NewError(err, "Unable to read folder")
@@ -61,7 +71,14 @@ func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []stora
 }
 
 func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
+	return folder.DeleteObjectsWithContext(context.Background(), objectRelativePaths)
+}
+
+func (folder *Folder) DeleteObjectsWithContext(ctx context.Context, objectRelativePaths []string) error {
 	for _, fileName := range objectRelativePaths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		err := os.RemoveAll(folder.GetFilePath(fileName))
 		if os.IsNotExist(err) {
 			continue
@@ -74,6 +91,13 @@ func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
 }
 
 func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
+	return folder.ExistsWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ExistsWithContext(ctx context.Context, objectRelativePath string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 	_, err := os.Stat(folder.GetFilePath(objectRelativePath))
 	if os.IsNotExist(err) {
 		return false, nil
@@ -83,6 +107,7 @@ func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
 	}
 	return true, nil
 }
+
 func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder {
 	sf := Folder{folder.rootPath, path.Join(folder.subpath, subFolderRelativePath)}
 	_ = sf.EnsureExists()
@@ -94,6 +119,17 @@ func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder
 }
 
 func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ReadObjectWithContext(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectRange(ctx, objectRelativePath, 0, -1)
+}
+
+func (folder *Folder) ReadObjectRange(ctx context.Context, objectRelativePath string, offset, length int64) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	filePath := folder.GetFilePath(objectRelativePath)
 	file, err := os.Open(filePath)
 	if os.IsNotExist(err) {
@@ -102,17 +138,49 @@ func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, erro
 	if err != nil {
 		return nil, NewError(err, "Unable to read object %v", filePath)
 	}
-	return file, nil
+	if offset > 0 {
+		if _, err = file.Seek(offset, io.SeekStart); err != nil {
+			_ = file.Close()
+			return nil, NewError(err, "Unable to seek object %v", filePath)
+		}
+	}
+	return storage.NewContextReadCloser(ctx, storage.LimitReadCloser(file, length)), nil
+}
+
+func (folder *Folder) StatObject(ctx context.Context, objectRelativePath string) (*storage.ObjectInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	filePath := folder.GetFilePath(objectRelativePath)
+	fileInfo, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return nil, storage.NewObjectNotFoundError(filePath)
+	}
+	if err != nil {
+		return nil, NewError(err, "Unable to stat object %v", filePath)
+	}
+	return &storage.ObjectInfo{
+		Name:         objectRelativePath,
+		Size:         fileInfo.Size(),
+		LastModified: fileInfo.ModTime(),
+	}, nil
 }
 
 func (folder *Folder) PutObject(name string, content io.Reader) error {
+	return folder.PutObjectWithContext(context.Background(), name, content)
+}
+
+func (folder *Folder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	tracelog.DebugLogger.Printf("Put %v into %v\n", name, folder.subpath)
 	filePath := folder.GetFilePath(name)
 	file, err := OpenFileWithDir(filePath)
 	if err != nil {
 		return NewError(err, "Unable to open file %v", filePath)
 	}
-	_, err = io.Copy(file, content)
+	_, err = io.Copy(file, storage.NewContextReader(ctx, content))
 	if err != nil {
 		closerErr := file.Close()
 		if closerErr != nil {
@@ -128,6 +196,10 @@ func (folder *Folder) PutObject(name string, content io.Reader) error {
 }
 
 func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
+	return folder.CopyObjectWithContext(context.Background(), srcPath, dstPath)
+}
+
+func (folder *Folder) CopyObjectWithContext(ctx context.Context, srcPath string, dstPath string) error {
 	src := path.Join(folder.rootPath, srcPath)
 	srcStat, err := os.Stat(src)
 	if err != nil {
@@ -140,7 +212,7 @@ func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
 	if err != nil {
 		return err
 	}
-	err = folder.PutObject(dstPath, file)
+	err = folder.PutObjectWithContext(ctx, dstPath, file)
 	return err
 }
 
@@ -22,6 +22,14 @@ func TestFSFolder(t *testing.T) {
 	storage.RunFolderTest(storageFolder, t)
 }
 
+func TestFSContextFolder(t *testing.T) {
+	tmpDir := setupTmpDir(t)
+
+	defer os.RemoveAll(tmpDir)
+
+	storage.RunContextFolderTest(NewFolder(tmpDir, ""), t)
+}
+
 func setupTmpDir(t *testing.T) string {
 	cwd, err := filepath.Abs("./")
 	if err != nil {
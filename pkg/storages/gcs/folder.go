@@ -29,6 +29,7 @@ const (
 	defaultContextTimeout = 60 * 60 // 1 hour
 	maxRetryDelay         = 5 * time.Minute
 	composeChunkLimit     = 32
+	listPageSize          = 1000
 
 	encryptionKeySize = 32
 )
@@ -169,6 +170,10 @@ type Folder struct {
 	uploaderOptions []UploaderOption
 }
 
+var _ storage.ContextFolder = &Folder{}
+var _ storage.PagedFolder = &Folder{}
+var _ storage.MetadataFolder = &Folder{}
+
 func (folder *Folder) GetPath() string {
 	return folder.path
 }
@@ -185,20 +190,45 @@ func (folder *Folder) BuildObjectHandle(path string) *gcs.ObjectHandle {
 }
 
 func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
+	return folder.ListFolderWithContext(context.Background())
+}
+
+func (folder *Folder) ListFolderWithContext(ctx context.Context) (objects []storage.Object, subFolders []storage.Folder, err error) {
+	err = folder.ListFolderPages(ctx, storage.ListOptions{}, func(pageObjects []storage.Object, pageSubFolders []storage.Folder) error {
+		objects = append(objects, pageObjects...)
+		subFolders = append(subFolders, pageSubFolders...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return objects, subFolders, nil
+}
+
+func (folder *Folder) ListFolderPages(ctx context.Context, opts storage.ListOptions,
+	pageFn func(objects []storage.Object, subFolders []storage.Folder) error) error {
 	prefix := storage.AddDelimiterToPath(folder.path)
-	ctx, cancel := folder.createTimeoutContext()
+	ctx, cancel := folder.createTimeoutContext(ctx)
 	defer cancel()
-	it := folder.bucket.Objects(ctx, &gcs.Query{Delimiter: "/", Prefix: prefix})
+	query := &gcs.Query{Delimiter: "/", Prefix: prefix + opts.Prefix}
+	if opts.StartAfter != "" {
+		// StartOffset is inclusive, the exact match is skipped below
+		query.StartOffset = prefix + opts.StartAfter
+	}
+	it := folder.bucket.Objects(ctx, query)
+
+	objects := make([]storage.Object, 0, listPageSize)
+	subFolders := make([]storage.Folder, 0)
 	for {
 		objAttrs, err := it.Next()
 		if err == iterator.Done {
 			break
 		}
 		if err != nil {
-			return nil, nil, NewError(err, "Unable to iterate %v", folder.path)
+			return NewError(err, "Unable to iterate %v", folder.path)
 		}
 		if objAttrs.Prefix != "" {
-			if objAttrs.Prefix != prefix+"/" {
+			if objAttrs.Prefix != prefix+"/" && storage.MatchesListOptions(strings.TrimPrefix(objAttrs.Prefix, prefix), opts) {
 				// Sometimes GCS returns "//" folder - skip it
 				subFolders = append(subFolders,
 					NewFolder(
@@ -212,27 +242,38 @@ func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []stora
 			}
 		} else {
 			objName := strings.TrimPrefix(objAttrs.Name, prefix)
-			if objName != "" {
+			if objName != "" && storage.MatchesListOptions(objName, opts) {
 				// GCS returns the current directory - skip it.
 				objects = append(objects, storage.NewLocalObject(objName, objAttrs.Updated, objAttrs.Size))
 			}
 		}
+		if len(objects) >= listPageSize {
+			if err := pageFn(objects, subFolders); err != nil {
+				return err
+			}
+			objects = make([]storage.Object, 0, listPageSize)
+			subFolders = make([]storage.Folder, 0)
+		}
 	}
-	return objects, subFolders, err
+	return pageFn(objects, subFolders)
 }
 
-func (folder *Folder) createTimeoutContext() (context.Context, context.CancelFunc) {
-	return context.WithTimeout(context.Background(), time.Second*time.Duration(folder.contextTimeout))
+func (folder *Folder) createTimeoutContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, time.Second*time.Duration(folder.contextTimeout))
 }
 
 func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
+	return folder.DeleteObjectsWithContext(context.Background(), objectRelativePaths)
+}
+
+func (folder *Folder) DeleteObjectsWithContext(ctx context.Context, objectRelativePaths []string) error {
 	for _, objectRelativePath := range objectRelativePaths {
 		path := folder.joinPath(folder.path, objectRelativePath)
 		object := folder.BuildObjectHandle(path)
 		tracelog.DebugLogger.Printf("Delete %v\n", path)
-		ctx, cancel := folder.createTimeoutContext()
+		deleteCtx, cancel := folder.createTimeoutContext(ctx)
 		defer cancel()
-		err := object.Delete(ctx)
+		err := object.Delete(deleteCtx)
 		if err != nil && err != gcs.ErrObjectNotExist {
 			return NewError(err, "Unable to delete object %v", path)
 		}
@@ -241,9 +282,13 @@ func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
 }
 
 func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
+	return folder.ExistsWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ExistsWithContext(ctx context.Context, objectRelativePath string) (bool, error) {
 	path := folder.joinPath(folder.path, objectRelativePath)
 	object := folder.BuildObjectHandle(path)
-	ctx, cancel := folder.createTimeoutContext()
+	ctx, cancel := folder.createTimeoutContext(ctx)
 	defer cancel()
 	_, err := object.Attrs(ctx)
 	if err == gcs.ErrObjectNotExist {
@@ -267,20 +312,60 @@ func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder
 }
 
 func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ReadObjectWithContext(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectRange(ctx, objectRelativePath, 0, -1)
+}
+
+func (folder *Folder) ReadObjectRange(ctx context.Context, objectRelativePath string, offset, length int64) (io.ReadCloser, error) {
 	path := folder.joinPath(folder.path, objectRelativePath)
 	object := folder.BuildObjectHandle(path)
-	reader, err := object.NewReader(context.Background())
+	reader, err := object.NewRangeReader(ctx, offset, length)
 	if err == gcs.ErrObjectNotExist {
 		return nil, storage.NewObjectNotFoundError(path)
 	}
-	return io.NopCloser(reader), err
+	if err != nil {
+		return nil, NewError(err, "Unable to read object %v", path)
+	}
+	return io.NopCloser(reader), nil
+}
+
+func (folder *Folder) StatObject(ctx context.Context, objectRelativePath string) (*storage.ObjectInfo, error) {
+	path := folder.joinPath(folder.path, objectRelativePath)
+	object := folder.BuildObjectHandle(path)
+	ctx, cancel := folder.createTimeoutContext(ctx)
+	defer cancel()
+	attrs, err := object.Attrs(ctx)
+	if err == gcs.ErrObjectNotExist {
+		return nil, storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return nil, NewError(err, "Unable to stat object %v", path)
+	}
+	return &storage.ObjectInfo{
+		Name:         objectRelativePath,
+		Size:         attrs.Size,
+		LastModified: attrs.Updated,
+		ETag:         attrs.Etag,
+		UserMetadata: attrs.Metadata,
+	}, nil
 }
 
 func (folder *Folder) PutObject(name string, content io.Reader) error {
+	return folder.PutObjectWithContext(context.Background(), name, content)
+}
+
+func (folder *Folder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	return folder.PutObjectWithMetadata(ctx, name, content, nil)
+}
+
+func (folder *Folder) PutObjectWithMetadata(ctx context.Context, name string, content io.Reader, metadata map[string]string) error {
 	tracelog.DebugLogger.Printf("Put %v into %v\n", name, folder.path)
 	object := folder.BuildObjectHandle(folder.joinPath(folder.path, name))
 
-	ctx, cancel := folder.createTimeoutContext()
+	ctx, cancel := folder.createTimeoutContext(ctx)
 	defer cancel()
 
 	chunkNum := 0
@@ -338,7 +423,10 @@ func (folder *Folder) PutObject(name string, content io.Reader) error {
 
 	tracelog.DebugLogger.Printf("Compose file %v from chunks\n", object.ObjectName())
 
-	if err := composeChunks(ctx, NewUploader(object, folder.uploaderOptions...), tmpChunks); err != nil {
+	objectUploaderOptions := make([]UploaderOption, 0, len(folder.uploaderOptions)+1)
+	objectUploaderOptions = append(objectUploaderOptions, folder.uploaderOptions...)
+	objectUploaderOptions = append(objectUploaderOptions, func(uploader *Uploader) { uploader.metadata = metadata })
+	if err := composeChunks(ctx, NewUploader(object, objectUploaderOptions...), tmpChunks); err != nil {
 		return NewError(err, "Failed to compose temporary chunks into an object")
 	}
 
@@ -348,7 +436,11 @@ func (folder *Folder) PutObject(name string, content io.Reader) error {
 }
 
 func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
-	if exists, err := folder.Exists(srcPath); !exists {
+	return folder.CopyObjectWithContext(context.Background(), srcPath, dstPath)
+}
+
+func (folder *Folder) CopyObjectWithContext(ctx context.Context, srcPath string, dstPath string) error {
+	if exists, err := folder.ExistsWithContext(ctx, srcPath); !exists {
 		if err == nil {
 			return errors.New("object does not exist")
 		}
@@ -357,7 +449,6 @@ func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
 	source := path.Join(folder.path, srcPath)
 	dst := path.Join(folder.path, dstPath)
 
-	ctx := context.Background()
 	_, err := folder.bucket.Object(dst).CopierFrom(folder.bucket.Object(source)).Run(ctx)
 	return err
 }
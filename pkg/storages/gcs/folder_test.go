@@ -54,6 +54,17 @@ func TestGSFolder(t *testing.T) {
 	storage.RunFolderTest(storageFolder, t)
 }
 
+func TestGSContextFolder(t *testing.T) {
+	t.Skip("Credentials needed to run GCP tests")
+
+	storageFolder, err := ConfigureFolder("gs://x4m-test/walg-bucket",
+		nil)
+
+	assert.NoError(t, err)
+
+	storage.RunContextFolderTest(storageFolder.(storage.ContextFolder), t)
+}
+
 func TestGSExactFolder(t *testing.T) {
 	t.Skip("Credentials needed to run GCP tests")
 
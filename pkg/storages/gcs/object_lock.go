@@ -0,0 +1,65 @@
+package gcs
+
+import (
+	"context"
+	"fmt"
+
+	gcs "cloud.google.com/go/storage"
+
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+)
+
+var _ storage.LockingFolder = &Folder{}
+
+// SetObjectLock turns the temporary hold on for the legal hold. GCS has no per-object retention,
+// the retention is provided by the bucket retention policy. So the retention of the object is only checked
+// to cover lock.RetainUntil. The temporary hold is never released here.
+func (folder *Folder) SetObjectLock(ctx context.Context, objectRelativePath string, lock storage.ObjectLock) error {
+	path := folder.joinPath(folder.path, objectRelativePath)
+	object := folder.BuildObjectHandle(path)
+	ctx, cancel := folder.createTimeoutContext(ctx)
+	defer cancel()
+
+	if lock.LegalHold {
+		if _, err := object.Update(ctx, gcs.ObjectAttrsToUpdate{TemporaryHold: true}); err != nil {
+			return NewError(err, "Unable to set temporary hold of object %v", path)
+		}
+	}
+	if lock.Mode == storage.ObjectLockModeNone {
+		return nil
+	}
+	attrs, err := object.Attrs(ctx)
+	if err != nil {
+		return NewError(err, "Unable to get retention of object %v", path)
+	}
+	if attrs.RetentionExpirationTime.Before(lock.RetainUntil) {
+		return fmt.Errorf("retention of the object %v expires at %v before %v, "+
+			"GCS can't extend the retention of a single object, configure the bucket retention policy instead",
+			path, attrs.RetentionExpirationTime, lock.RetainUntil)
+	}
+	return nil
+}
+
+// GetObjectLock reports the bucket retention policy as the compliance mode retention,
+// the temporary and event-based holds are reported as the legal hold.
+func (folder *Folder) GetObjectLock(ctx context.Context, objectRelativePath string) (storage.ObjectLock, error) {
+	path := folder.joinPath(folder.path, objectRelativePath)
+	object := folder.BuildObjectHandle(path)
+	ctx, cancel := folder.createTimeoutContext(ctx)
+	defer cancel()
+	attrs, err := object.Attrs(ctx)
+	if err == gcs.ErrObjectNotExist {
+		return storage.ObjectLock{}, storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return storage.ObjectLock{}, NewError(err, "Unable to get lock of object %v", path)
+	}
+
+	var lock storage.ObjectLock
+	if !attrs.RetentionExpirationTime.IsZero() {
+		lock.Mode = storage.ObjectLockModeCompliance
+		lock.RetainUntil = attrs.RetentionExpirationTime
+	}
+	lock.LegalHold = attrs.TemporaryHold || attrs.EventBasedHold
+	return lock, nil
+}
@@ -27,6 +27,7 @@ type Uploader struct {
 	baseRetryDelay   time.Duration
 	maxRetryDelay    time.Duration
 	maxUploadRetries int
+	metadata         map[string]string
 }
 
 type UploaderOption func(*Uploader)
@@ -103,7 +104,9 @@ func (u *Uploader) ComposeObject(ctx context.Context, tmpChunks []*storage.Objec
 
 func (u *Uploader) getComposeFunc(tmpChunks []*storage.ObjectHandle) func(context.Context) error {
 	return func(ctx context.Context) error {
-		_, err := u.objHandle.ComposerFrom(tmpChunks...).Run(ctx)
+		composer := u.objHandle.ComposerFrom(tmpChunks...)
+		composer.Metadata = u.metadata
+		_, err := composer.Run(ctx)
 		// Since compose sources must not have an encryption key, clean up it.
 		if err == nil {
 			*u.objHandle = *u.objHandle.Key(nil)
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"path"
 	"path/filepath"
@@ -17,6 +18,8 @@ type Folder struct {
 	Storage *Storage
 }
 
+var _ storage.ContextFolder = &Folder{}
+
 func NewFolder(path string, storage *Storage) *Folder {
 	return &Folder{path, storage}
 }
@@ -26,6 +29,13 @@ func NewError(err error, format string, args ...interface{}) storage.Error {
 }
 
 func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
+	return folder.ExistsWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ExistsWithContext(ctx context.Context, objectRelativePath string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 	_, exists := folder.Storage.Load(path.Join(folder.path, objectRelativePath))
 	return exists, nil
 }
@@ -35,6 +45,13 @@ func (folder *Folder) GetPath() string {
 }
 
 func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
+	return folder.ListFolderWithContext(context.Background())
+}
+
+func (folder *Folder) ListFolderWithContext(ctx context.Context) (objects []storage.Object, subFolders []storage.Folder, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 	subFolderNames := sync.Map{}
 	folder.Storage.Range(func(key string, value TimeStampedData) bool {
 		if !strings.HasPrefix(key, folder.path) {
@@ -58,6 +75,13 @@ func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []stora
 }
 
 func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
+	return folder.DeleteObjectsWithContext(context.Background(), objectRelativePaths)
+}
+
+func (folder *Folder) DeleteObjectsWithContext(ctx context.Context, objectRelativePaths []string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	for _, objectName := range objectRelativePaths {
 		folder.Storage.Delete(storage.JoinPath(folder.path, objectName))
 	}
@@ -69,16 +93,55 @@ func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder
 }
 
 func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ReadObjectWithContext(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectRange(ctx, objectRelativePath, 0, -1)
+}
+
+func (folder *Folder) ReadObjectRange(ctx context.Context, objectRelativePath string, offset, length int64) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	objectAbsPath := path.Join(folder.path, objectRelativePath)
 	object, exists := folder.Storage.Load(objectAbsPath)
 	if !exists {
 		return nil, storage.NewObjectNotFoundError(objectAbsPath)
 	}
-	return io.NopCloser(&object.Data), nil
+	data := object.Data.Bytes()
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	data = data[offset:]
+	if length >= 0 && length < int64(len(data)) {
+		data = data[:length]
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (folder *Folder) StatObject(ctx context.Context, objectRelativePath string) (*storage.ObjectInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	objectAbsPath := path.Join(folder.path, objectRelativePath)
+	object, exists := folder.Storage.Load(objectAbsPath)
+	if !exists {
+		return nil, storage.NewObjectNotFoundError(objectAbsPath)
+	}
+	return &storage.ObjectInfo{
+		Name:         objectRelativePath,
+		Size:         int64(object.Size),
+		LastModified: object.Timestamp,
+	}, nil
 }
 
 func (folder *Folder) PutObject(name string, content io.Reader) error {
-	data, err := io.ReadAll(content)
+	return folder.PutObjectWithContext(context.Background(), name, content)
+}
+
+func (folder *Folder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	data, err := io.ReadAll(storage.NewContextReader(ctx, content))
 	objectPath := path.Join(folder.path, name)
 	if err != nil {
 		return errors.Wrapf(err, "failed to put '%s' in memory storage", objectPath)
@@ -88,17 +151,21 @@ func (folder *Folder) PutObject(name string, content io.Reader) error {
 }
 
 func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
-	if exists, err := folder.Exists(srcPath); !exists {
+	return folder.CopyObjectWithContext(context.Background(), srcPath, dstPath)
+}
+
+func (folder *Folder) CopyObjectWithContext(ctx context.Context, srcPath string, dstPath string) error {
+	if exists, err := folder.ExistsWithContext(ctx, srcPath); !exists {
 		if err == nil {
 			return errors.New("object does not exist")
 		}
 		return err
 	}
-	file, err := folder.ReadObject(srcPath)
+	file, err := folder.ReadObjectWithContext(ctx, srcPath)
 	if err != nil {
 		return err
 	}
-	err = folder.PutObject(dstPath, file)
+	err = folder.PutObjectWithContext(ctx, dstPath, file)
 	if err != nil {
 		return err
 	}
@@ -9,3 +9,7 @@ import (
 func TestS3Folder(t *testing.T) {
 	storage.RunFolderTest(NewFolder("in_memory/", NewStorage()), t)
 }
+
+func TestContextFolder(t *testing.T) {
+	storage.RunContextFolderTest(NewFolder("in_memory/", NewStorage()), t)
+}
@@ -1,6 +1,8 @@
 package s3
 
 import (
+	"context"
+	"fmt"
 	"io"
 	"path"
 	"strconv"
@@ -104,6 +106,10 @@ type Folder struct {
 	useListObjectsV1 bool
 }
 
+var _ storage.ContextFolder = &Folder{}
+var _ storage.PagedFolder = &Folder{}
+var _ storage.MetadataFolder = &Folder{}
+
 func NewFolder(uploader Uploader, s3API s3iface.S3API, settings map[string]string, bucket, path string, useListObjectsV1 bool) *Folder {
 	return &Folder{
 		uploader:         uploader,
@@ -143,13 +149,17 @@ func ConfigureFolder(prefix string, settings map[string]string) (storage.Folder,
 }
 
 func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
+	return folder.ExistsWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ExistsWithContext(ctx context.Context, objectRelativePath string) (bool, error) {
 	objectPath := folder.Path + objectRelativePath
 	stopSentinelObjectInput := &s3.HeadObjectInput{
 		Bucket: folder.Bucket,
 		Key:    aws.String(objectPath),
 	}
 
-	_, err := folder.S3API.HeadObject(stopSentinelObjectInput)
+	_, err := folder.S3API.HeadObjectWithContext(ctx, stopSentinelObjectInput)
 	if err != nil {
 		if isAwsNotExist(err) {
 			return false, nil
@@ -159,12 +169,53 @@ func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
 	return true, nil
 }
 
+func (folder *Folder) StatObject(ctx context.Context, objectRelativePath string) (*storage.ObjectInfo, error) {
+	objectPath := folder.Path + objectRelativePath
+	input := &s3.HeadObjectInput{
+		Bucket: folder.Bucket,
+		Key:    aws.String(objectPath),
+	}
+
+	output, err := folder.S3API.HeadObjectWithContext(ctx, input)
+	if err != nil {
+		if isAwsNotExist(err) {
+			return nil, storage.NewObjectNotFoundError(objectPath)
+		}
+		return nil, errors.Wrapf(err, "failed to stat s3 object '%s'", objectPath)
+	}
+
+	userMetadata := make(map[string]string, len(output.Metadata))
+	for key, value := range output.Metadata {
+		userMetadata[key] = aws.StringValue(value)
+	}
+	return &storage.ObjectInfo{
+		Name:         objectRelativePath,
+		Size:         aws.Int64Value(output.ContentLength),
+		LastModified: aws.TimeValue(output.LastModified),
+		ETag:         strings.Trim(aws.StringValue(output.ETag), "\""),
+		UserMetadata: userMetadata,
+	}, nil
+}
+
 func (folder *Folder) PutObject(name string, content io.Reader) error {
-	return folder.uploader.upload(*folder.Bucket, folder.Path+name, content)
+	return folder.PutObjectWithContext(context.Background(), name, content)
+}
+
+func (folder *Folder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	return folder.uploader.upload(ctx, *folder.Bucket, folder.Path+name, content, nil)
+}
+
+// PutObjectWithMetadata uploads the object with the metadata written as the object tags
+func (folder *Folder) PutObjectWithMetadata(ctx context.Context, name string, content io.Reader, metadata map[string]string) error {
+	return folder.uploader.upload(ctx, *folder.Bucket, folder.Path+name, content, metadata)
 }
 
 func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
-	if exists, err := folder.Exists(srcPath); !exists {
+	return folder.CopyObjectWithContext(context.Background(), srcPath, dstPath)
+}
+
+func (folder *Folder) CopyObjectWithContext(ctx context.Context, srcPath string, dstPath string) error {
+	if exists, err := folder.ExistsWithContext(ctx, srcPath); !exists {
 		if err == nil {
 			return errors.New("object does not exist")
 		}
@@ -173,7 +224,7 @@ func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
 	source := path.Join(*folder.Bucket, folder.Path, srcPath)
 	dst := path.Join(folder.Path, dstPath)
 	input := &s3.CopyObjectInput{CopySource: &source, Bucket: folder.Bucket, Key: &dst}
-	_, err := folder.S3API.CopyObject(input)
+	_, err := folder.S3API.CopyObjectWithContext(ctx, input)
 	if err != nil {
 		return err
 	}
@@ -181,13 +232,17 @@ func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
 }
 
 func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ReadObjectWithContext(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
 	objectPath := folder.Path + objectRelativePath
 	input := &s3.GetObjectInput{
 		Bucket: folder.Bucket,
 		Key:    aws.String(objectPath),
 	}
 
-	object, err := folder.S3API.GetObject(input)
+	object, err := folder.S3API.GetObjectWithContext(ctx, input)
 	if err != nil {
 		if isAwsNotExist(err) {
 			return nil, storage.NewObjectNotFoundError(objectPath)
@@ -204,6 +259,38 @@ func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, erro
 	return reader, nil
 }
 
+func (folder *Folder) ReadObjectRange(ctx context.Context, objectRelativePath string, offset, length int64) (io.ReadCloser, error) {
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+	objectPath := folder.Path + objectRelativePath
+	bytesRange := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		bytesRange += strconv.FormatInt(offset+length-1, 10)
+	}
+	input := &s3.GetObjectInput{
+		Bucket: folder.Bucket,
+		Key:    aws.String(objectPath),
+		Range:  aws.String(bytesRange),
+	}
+
+	object, err := folder.S3API.GetObjectWithContext(ctx, input)
+	if err != nil {
+		if isAwsNotExist(err) {
+			return nil, storage.NewObjectNotFoundError(objectPath)
+		}
+		return nil, errors.Wrapf(err, "failed to read range %s of object: '%s' from S3", bytesRange, objectPath)
+	}
+
+	rangeEnabled, maxRetries, minRetryDelay, maxRetryDelay := folder.getReaderSettings()
+
+	reader := object.Body
+	if rangeEnabled {
+		reader = NewS3RangeReader(object.Body, objectPath, offset, length, maxRetries, folder, minRetryDelay, maxRetryDelay)
+	}
+	return reader, nil
+}
+
 func (folder *Folder) getReaderSettings() (rangeEnabled bool, retriesCount int, minRetryDelay, maxRetryDelay time.Duration) {
 	rangeEnabled = RangeBatchEnabledDefault
 	if rangeBatch, ok := folder.settings[RangeBatchEnabled]; ok {
@@ -242,12 +329,32 @@ func (folder *Folder) GetPath() string {
 }
 
 func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
-	listFunc := func(commonPrefixes []*s3.CommonPrefix, contents []*s3.Object) {
+	return folder.ListFolderWithContext(context.Background())
+}
+
+func (folder *Folder) ListFolderWithContext(ctx context.Context) (objects []storage.Object, subFolders []storage.Folder, err error) {
+	err = folder.ListFolderPages(ctx, storage.ListOptions{}, func(pageObjects []storage.Object, pageSubFolders []storage.Folder) error {
+		objects = append(objects, pageObjects...)
+		subFolders = append(subFolders, pageSubFolders...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return objects, subFolders, nil
+}
+
+func (folder *Folder) ListFolderPages(ctx context.Context, opts storage.ListOptions,
+	pageFn func(objects []storage.Object, subFolders []storage.Folder) error) error {
+	var pageErr error
+	listFunc := func(commonPrefixes []*s3.CommonPrefix, contents []*s3.Object) bool {
+		subFolders := make([]storage.Folder, 0, len(commonPrefixes))
 		for _, prefix := range commonPrefixes {
 			subFolder := NewFolder(folder.uploader, folder.S3API, folder.settings, *folder.Bucket,
 				*prefix.Prefix, folder.useListObjectsV1)
 			subFolders = append(subFolders, subFolder)
 		}
+		objects := make([]storage.Object, 0, len(contents))
 		for _, object := range contents {
 			// Some storages return root tar_partitions folder as a Key.
 			// We do not want to fail restoration due to this fact.
@@ -256,57 +363,72 @@ func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []stora
 				continue
 			}
 			objectRelativePath := strings.TrimPrefix(*object.Key, folder.Path)
+			// some S3-compatible storages ignore StartAfter and Marker, so double-check the options
+			if !storage.MatchesListOptions(objectRelativePath, opts) {
+				continue
+			}
 			objects = append(objects, storage.NewLocalObject(objectRelativePath, *object.LastModified, *object.Size))
 		}
+		pageErr = pageFn(objects, subFolders)
+		return pageErr == nil
 	}
 
-	prefix := aws.String(folder.Path)
+	prefix := aws.String(folder.Path + opts.Prefix)
 	delimiter := aws.String("/")
+	var startAfter *string
+	if opts.StartAfter != "" {
+		startAfter = aws.String(folder.Path + opts.StartAfter)
+	}
+	var err error
 	if folder.useListObjectsV1 {
-		err = folder.listObjectsPagesV1(prefix, delimiter, listFunc)
+		err = folder.listObjectsPagesV1(ctx, prefix, delimiter, startAfter, listFunc)
 	} else {
-		err = folder.listObjectsPagesV2(prefix, delimiter, listFunc)
+		err = folder.listObjectsPagesV2(ctx, prefix, delimiter, startAfter, listFunc)
 	}
 
 	if err != nil {
-		return nil, nil, errors.Wrapf(err, "failed to list s3 folder: '%s'", folder.Path)
+		return errors.Wrapf(err, "failed to list s3 folder: '%s'", folder.Path)
 	}
-	return objects, subFolders, nil
+	return pageErr
 }
 
-func (folder *Folder) listObjectsPagesV1(prefix *string, delimiter *string,
-	listFunc func(commonPrefixes []*s3.CommonPrefix, contents []*s3.Object)) error {
+func (folder *Folder) listObjectsPagesV1(ctx context.Context, prefix *string, delimiter *string, marker *string,
+	listFunc func(commonPrefixes []*s3.CommonPrefix, contents []*s3.Object) bool) error {
 	s3Objects := &s3.ListObjectsInput{
 		Bucket:    folder.Bucket,
 		Prefix:    prefix,
 		Delimiter: delimiter,
+		Marker:    marker,
 	}
-	return folder.S3API.ListObjectsPages(s3Objects, func(files *s3.ListObjectsOutput, lastPage bool) bool {
-		listFunc(files.CommonPrefixes, files.Contents)
-		return true
+	return folder.S3API.ListObjectsPagesWithContext(ctx, s3Objects, func(files *s3.ListObjectsOutput, lastPage bool) bool {
+		return listFunc(files.CommonPrefixes, files.Contents)
 	})
 }
 
-func (folder *Folder) listObjectsPagesV2(prefix *string, delimiter *string,
-	listFunc func(commonPrefixes []*s3.CommonPrefix, contents []*s3.Object)) error {
+func (folder *Folder) listObjectsPagesV2(ctx context.Context, prefix *string, delimiter *string, startAfter *string,
+	listFunc func(commonPrefixes []*s3.CommonPrefix, contents []*s3.Object) bool) error {
 	s3Objects := &s3.ListObjectsV2Input{
-		Bucket:    folder.Bucket,
-		Prefix:    prefix,
-		Delimiter: delimiter,
+		Bucket:     folder.Bucket,
+		Prefix:     prefix,
+		Delimiter:  delimiter,
+		StartAfter: startAfter,
 	}
-	return folder.S3API.ListObjectsV2Pages(s3Objects, func(files *s3.ListObjectsV2Output, lastPage bool) bool {
-		listFunc(files.CommonPrefixes, files.Contents)
-		return true
+	return folder.S3API.ListObjectsV2PagesWithContext(ctx, s3Objects, func(files *s3.ListObjectsV2Output, lastPage bool) bool {
+		return listFunc(files.CommonPrefixes, files.Contents)
 	})
 }
 
 func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
+	return folder.DeleteObjectsWithContext(context.Background(), objectRelativePaths)
+}
+
+func (folder *Folder) DeleteObjectsWithContext(ctx context.Context, objectRelativePaths []string) error {
 	parts := partitionStrings(objectRelativePaths, 1000)
 	for _, part := range parts {
 		input := &s3.DeleteObjectsInput{Bucket: folder.Bucket, Delete: &s3.Delete{
 			Objects: folder.partitionToObjects(part),
 		}}
-		_, err := folder.S3API.DeleteObjects(input)
+		_, err := folder.S3API.DeleteObjectsWithContext(ctx, input)
 		if err != nil {
 			return errors.Wrapf(err, "failed to delete s3 object: '%s'", part)
 		}
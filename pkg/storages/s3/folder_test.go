@@ -1,9 +1,18 @@
 package s3
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
 
 	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -38,3 +47,69 @@ func TestS3FolderEndpointSource(t *testing.T) {
 
 	storage.RunFolderTest(storageFolder, t)
 }
+
+func TestS3ContextFolder(t *testing.T) {
+	t.Skip("Credentials needed to run S3 tests")
+
+	storageFolder, err := ConfigureFolder("s3://test-bucket/wal-g-test-folder/Sub0",
+		map[string]string{
+			EndpointSetting: "HTTP://s3.kek.lol.net/",
+		})
+
+	assert.NoError(t, err)
+
+	storage.RunContextFolderTest(storageFolder.(storage.ContextFolder), t)
+}
+
+// rangeS3API serves the ranges of data, the first body breaks after a few bytes to force a reconnect
+type rangeS3API struct {
+	s3iface.S3API
+	data   string
+	ranges []string
+}
+
+type brokenBody struct {
+	io.Reader
+}
+
+func (body *brokenBody) Read(p []byte) (int, error) {
+	n, err := body.Reader.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func (body *brokenBody) Close() error {
+	return nil
+}
+
+func (api *rangeS3API) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	api.ranges = append(api.ranges, *input.Range)
+	var from, to int
+	_, err := fmt.Sscanf(*input.Range, "bytes=%d-%d", &from, &to)
+	if err != nil {
+		return nil, err
+	}
+	if len(api.ranges) == 1 {
+		return &s3.GetObjectOutput{Body: &brokenBody{strings.NewReader(api.data[from : from+2])}}, nil
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(api.data[from : to+1]))}, nil
+}
+
+func (api *rangeS3API) GetObjectWithContext(_ aws.Context, input *s3.GetObjectInput,
+	_ ...request.Option) (*s3.GetObjectOutput, error) {
+	return api.GetObject(input)
+}
+
+func TestReadObjectRangeReconnectsWithinRange(t *testing.T) {
+	api := &rangeS3API{data: "0123456789"}
+	folder := NewFolder(Uploader{}, api, map[string]string{RangeBatchEnabled: "true"}, "bucket", "path", false)
+
+	reader, err := folder.ReadObjectRange(context.Background(), "file", 2, 5)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "23456", string(data))
+	assert.Equal(t, []string{"bytes=2-6", "bytes=4-6"}, api.ranges)
+}
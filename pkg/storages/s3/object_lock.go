@@ -0,0 +1,63 @@
+package s3
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
+)
+
+var _ storage.LockingFolder = &Folder{}
+
+// SetObjectLock sets the Object Lock retention and turns the legal hold on. The bucket must have Object Lock enabled.
+// The legal hold is never released here.
+func (folder *Folder) SetObjectLock(ctx context.Context, objectRelativePath string, lock storage.ObjectLock) error {
+	objectPath := folder.Path + objectRelativePath
+	if lock.Mode != storage.ObjectLockModeNone {
+		input := &s3.PutObjectRetentionInput{
+			Bucket: folder.Bucket,
+			Key:    aws.String(objectPath),
+			Retention: &s3.ObjectLockRetention{
+				Mode:            aws.String(string(lock.Mode)),
+				RetainUntilDate: aws.Time(lock.RetainUntil),
+			},
+		}
+		if _, err := folder.S3API.PutObjectRetentionWithContext(ctx, input); err != nil {
+			return errors.Wrapf(err, "failed to set retention of s3 object '%s'", objectPath)
+		}
+	}
+	if lock.LegalHold {
+		input := &s3.PutObjectLegalHoldInput{
+			Bucket:    folder.Bucket,
+			Key:       aws.String(objectPath),
+			LegalHold: &s3.ObjectLockLegalHold{Status: aws.String(s3.ObjectLockLegalHoldStatusOn)},
+		}
+		if _, err := folder.S3API.PutObjectLegalHoldWithContext(ctx, input); err != nil {
+			return errors.Wrapf(err, "failed to set legal hold of s3 object '%s'", objectPath)
+		}
+	}
+	return nil
+}
+
+func (folder *Folder) GetObjectLock(ctx context.Context, objectRelativePath string) (storage.ObjectLock, error) {
+	objectPath := folder.Path + objectRelativePath
+	input := &s3.HeadObjectInput{
+		Bucket: folder.Bucket,
+		Key:    aws.String(objectPath),
+	}
+	output, err := folder.S3API.HeadObjectWithContext(ctx, input)
+	if err != nil {
+		if isAwsNotExist(err) {
+			return storage.ObjectLock{}, storage.NewObjectNotFoundError(objectPath)
+		}
+		return storage.ObjectLock{}, errors.Wrapf(err, "failed to get lock of s3 object '%s'", objectPath)
+	}
+	return storage.ObjectLock{
+		Mode:        storage.ObjectLockMode(aws.StringValue(output.ObjectLockMode)),
+		RetainUntil: aws.TimeValue(output.ObjectLockRetainUntilDate),
+		LegalHold:   aws.StringValue(output.ObjectLockLegalHoldStatus) == s3.ObjectLockLegalHoldStatusOn,
+	}, nil
+}
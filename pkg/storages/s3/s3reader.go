@@ -27,6 +27,7 @@ type s3Reader struct {
 	retryNum      int
 	objectPath    string
 	storageCursor int64
+	storageEnd    int64 // inclusive end of the read range, -1 reads until the end of the object
 	maxRetryDelay time.Duration
 	minRetryDelay time.Duration
 	reconnectID   int
@@ -35,7 +36,7 @@ type s3Reader struct {
 
 func (reader *s3Reader) getObjectRange(from, to int64) (*s3.GetObjectOutput, error) {
 	bytesRange := fmt.Sprintf("bytes=%d-", from)
-	if to != 0 {
+	if to >= 0 {
 		bytesRange += strconv.Itoa(int(to))
 	}
 	input := &s3.GetObjectInput{
@@ -54,6 +55,9 @@ func (reader *s3Reader) Read(p []byte) (n int, err error) {
 	}
 	for {
 		if reconnect {
+			if reader.storageEnd >= 0 && reader.storageCursor > reader.storageEnd {
+				return 0, io.EOF
+			}
 			connErr := reader.reconnect()
 			if connErr != nil {
 				reader.debugLog("reconnect failed %s", connErr)
@@ -86,7 +90,7 @@ func (reader *s3Reader) reconnect() error {
 
 	for {
 		reader.reconnectID++
-		object, err := reader.getObjectRange(reader.storageCursor, 0)
+		object, err := reader.getObjectRange(reader.storageCursor, reader.storageEnd)
 		if err != nil {
 			failed++
 			reader.debugLog("reconnect failed [%d/%d]: %s", failed, reader.maxRetries, err)
@@ -141,13 +145,25 @@ func (reader *s3Reader) Close() (err error) {
 func NewS3Reader(body io.ReadCloser, objectPath string, retriesCount int, folder *Folder, minRetryDelay, maxRetryDelay time.Duration) *s3Reader {
 	DebugLogBufferCounter++
 	reader := &s3Reader{lastBody: body, objectPath: objectPath, maxRetries: retriesCount,
-		logDebugID: getHash(objectPath, DebugLogBufferCounter),
-		folder:     folder, minRetryDelay: minRetryDelay, maxRetryDelay: maxRetryDelay}
+		logDebugID: getHash(objectPath, DebugLogBufferCounter), storageEnd: -1,
+		folder: folder, minRetryDelay: minRetryDelay, maxRetryDelay: maxRetryDelay}
 
 	reader.debugLog("Init s3reader path %s", objectPath)
 	return reader
 }
 
+// NewS3RangeReader creates the reader of the object range starting at offset, reconnecting within the same range.
+// Negative length reads until the end of the object.
+// nolint: revive, lll
+func NewS3RangeReader(body io.ReadCloser, objectPath string, offset, length int64, retriesCount int, folder *Folder, minRetryDelay, maxRetryDelay time.Duration) *s3Reader {
+	reader := NewS3Reader(body, objectPath, retriesCount, folder, minRetryDelay, maxRetryDelay)
+	reader.storageCursor = offset
+	if length > 0 {
+		reader.storageEnd = offset + length - 1
+	}
+	return reader
+}
+
 func getHash(objectPath string, id int) string {
 	hash := fnv.New32a()
 	_, err := hash.Write([]byte(objectPath))
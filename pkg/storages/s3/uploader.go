@@ -1,10 +1,12 @@
 package s3
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"net/url"
 	"strconv"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -45,7 +47,7 @@ func NewUploader(uploaderAPI s3manageriface.UploaderAPI, serverSideEncryption, s
 }
 
 // TODO : unit tests
-func (uploader *Uploader) createUploadInput(bucket, path string, content io.Reader) *s3manager.UploadInput {
+func (uploader *Uploader) createUploadInput(bucket, path string, content io.Reader, tags map[string]string) *s3manager.UploadInput {
 	uploadInput := &s3manager.UploadInput{
 		Bucket:       aws.String(bucket),
 		Key:          aws.String(path),
@@ -53,6 +55,14 @@ func (uploader *Uploader) createUploadInput(bucket, path string, content io.Read
 		StorageClass: aws.String(uploader.StorageClass),
 	}
 
+	if len(tags) > 0 {
+		tagging := url.Values{}
+		for key, value := range tags {
+			tagging.Set(key, value)
+		}
+		uploadInput.Tagging = aws.String(tagging.Encode())
+	}
+
 	if uploader.serverSideEncryption != "" {
 		if uploader.SSECustomerKey != "" {
 			uploadInput.SSECustomerAlgorithm = aws.String(uploader.serverSideEncryption)
@@ -73,9 +83,9 @@ func (uploader *Uploader) createUploadInput(bucket, path string, content io.Read
 	return uploadInput
 }
 
-func (uploader *Uploader) upload(bucket, path string, content io.Reader) error {
-	input := uploader.createUploadInput(bucket, path, content)
-	_, err := uploader.uploaderAPI.Upload(input)
+func (uploader *Uploader) upload(ctx context.Context, bucket, path string, content io.Reader, tags map[string]string) error {
+	input := uploader.createUploadInput(bucket, path, content, tags)
+	_, err := uploader.uploaderAPI.UploadWithContext(ctx, input)
 	return errors.Wrapf(err, "failed to upload '%s' to bucket '%s'", path, bucket)
 }
 
@@ -2,6 +2,7 @@ package sh
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -19,6 +20,8 @@ type Folder struct {
 	path   string
 }
 
+var _ storage.ContextFolder = &Folder{}
+
 const (
 	Port              = "SSH_PORT"
 	Password          = "SSH_PASSWORD"
@@ -111,6 +114,13 @@ func (folder *Folder) GetPath() string {
 }
 
 func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
+	return folder.ListFolderWithContext(context.Background())
+}
+
+func (folder *Folder) ListFolderWithContext(ctx context.Context) (objects []storage.Object, subFolders []storage.Folder, err error) {
+	if err = ctx.Err(); err != nil {
+		return nil, nil, err
+	}
 	client := folder.client
 	path := folder.path
 
@@ -151,9 +161,16 @@ func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []stora
 }
 
 func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
+	return folder.DeleteObjectsWithContext(context.Background(), objectRelativePaths)
+}
+
+func (folder *Folder) DeleteObjectsWithContext(ctx context.Context, objectRelativePaths []string) error {
 	client := folder.client
 
 	for _, relativePath := range objectRelativePaths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		path := client.Join(folder.path, relativePath)
 
 		stat, err := client.Stat(path)
@@ -176,6 +193,13 @@ func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
 }
 
 func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
+	return folder.ExistsWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ExistsWithContext(ctx context.Context, objectRelativePath string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 	path := filepath.Join(folder.path, objectRelativePath)
 	_, err := folder.client.Stat(path)
 
@@ -200,6 +224,17 @@ func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder
 }
 
 func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ReadObjectWithContext(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectRange(ctx, objectRelativePath, 0, -1)
+}
+
+func (folder *Folder) ReadObjectRange(ctx context.Context, objectRelativePath string, offset, length int64) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	path := folder.client.Join(folder.path, objectRelativePath)
 	file, err := folder.client.OpenFile(path)
 
@@ -207,13 +242,58 @@ func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, erro
 		return nil, storage.NewObjectNotFoundError(path)
 	}
 
+	var reader io.Reader = file
+	if offset > 0 {
+		if seeker, ok := file.(io.Seeker); ok {
+			_, err = seeker.Seek(offset, io.SeekStart)
+		} else {
+			_, err = io.CopyN(io.Discard, file, offset)
+		}
+		if err != nil && err != io.EOF {
+			_ = file.Close()
+			return nil, NewFolderError(err, "Fail to seek file '%s'", path)
+		}
+	}
+	if length >= 0 {
+		reader = io.LimitReader(reader, length)
+	}
+
 	return struct {
 		io.Reader
 		io.Closer
-	}{bufio.NewReaderSize(file, defaultBufferSize), file}, nil
+	}{storage.NewContextReader(ctx, bufio.NewReaderSize(reader, defaultBufferSize)), file}, nil
+}
+
+func (folder *Folder) StatObject(ctx context.Context, objectRelativePath string) (*storage.ObjectInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path := folder.client.Join(folder.path, objectRelativePath)
+	fileInfo, err := folder.client.Stat(path)
+
+	if os.IsNotExist(err) {
+		return nil, storage.NewObjectNotFoundError(path)
+	}
+
+	if err != nil {
+		return nil, NewFolderError(err, "Fail to get object stat '%s'", path)
+	}
+
+	return &storage.ObjectInfo{
+		Name:         objectRelativePath,
+		Size:         fileInfo.Size(),
+		LastModified: fileInfo.ModTime(),
+	}, nil
 }
 
 func (folder *Folder) PutObject(name string, content io.Reader) error {
+	return folder.PutObjectWithContext(context.Background(), name, content)
+}
+
+func (folder *Folder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	client := folder.client
 	absolutePath := filepath.Join(folder.path, name)
 
@@ -234,7 +314,7 @@ func (folder *Folder) PutObject(name string, content io.Reader) error {
 		)
 	}
 
-	_, err = io.Copy(file, content)
+	_, err = io.Copy(file, storage.NewContextReader(ctx, content))
 	if err != nil {
 		closerErr := file.Close()
 		if closerErr != nil {
@@ -256,17 +336,21 @@ func (folder *Folder) PutObject(name string, content io.Reader) error {
 }
 
 func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
-	if exists, err := folder.Exists(srcPath); !exists {
+	return folder.CopyObjectWithContext(context.Background(), srcPath, dstPath)
+}
+
+func (folder *Folder) CopyObjectWithContext(ctx context.Context, srcPath string, dstPath string) error {
+	if exists, err := folder.ExistsWithContext(ctx, srcPath); !exists {
 		if err == nil {
 			return errors.New("object does not exist")
 		}
 		return err
 	}
-	file, err := folder.ReadObject(srcPath)
+	file, err := folder.ReadObjectWithContext(ctx, srcPath)
 	if err != nil {
 		return err
 	}
-	err = folder.PutObject(dstPath, file)
+	err = folder.PutObjectWithContext(ctx, dstPath, file)
 	if err != nil {
 		return err
 	}
@@ -21,3 +21,16 @@ func TestSHFolder(t *testing.T) {
 
 	storage.RunFolderTest(storageFolder, t)
 }
+
+func TestSHContextFolder(t *testing.T) {
+	t.Skip("Credentials needed to run SSH tests")
+
+	storageFolder, err := ConfigureFolder("ssh://some.host/tmp/x",
+		map[string]string{
+			Username:       "x4mmm",
+			PrivateKeyPath: "/Users/x4mmm/.ssh/id_rsa_pg_tester"})
+
+	assert.NoError(t, err)
+
+	storage.RunContextFolderTest(storageFolder.(storage.ContextFolder), t)
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"path"
+	"time"
+)
+
+// ObjectInfo is the result of StatObject
+type ObjectInfo struct {
+	Name         string
+	Size         int64
+	LastModified time.Time
+	ETag         string
+	UserMetadata map[string]string
+}
+
+// ContextFolder is an optional extension of Folder implemented by storages which are able
+// to interrupt in-flight calls, read a part of an object and stat a single object.
+type ContextFolder interface {
+	Folder
+
+	ListFolderWithContext(ctx context.Context) (objects []Object, subFolders []Folder, err error)
+
+	DeleteObjectsWithContext(ctx context.Context, objectRelativePaths []string) error
+
+	ExistsWithContext(ctx context.Context, objectRelativePath string) (bool, error)
+
+	// Should return ObjectNotFoundError in case, there is no such object
+	ReadObjectWithContext(ctx context.Context, objectRelativePath string) (io.ReadCloser, error)
+
+	// Reads at most length bytes starting from offset. Negative length means "up to the end of the object".
+	// Should return ObjectNotFoundError in case, there is no such object
+	ReadObjectRange(ctx context.Context, objectRelativePath string, offset, length int64) (io.ReadCloser, error)
+
+	// Should return ObjectNotFoundError in case, there is no such object
+	StatObject(ctx context.Context, objectRelativePath string) (*ObjectInfo, error)
+
+	PutObjectWithContext(ctx context.Context, name string, content io.Reader) error
+
+	CopyObjectWithContext(ctx context.Context, srcPath string, dstPath string) error
+}
+
+// ListFolderWithContext lists the folder and interrupts the listing on ctx cancellation if the folder supports it
+func ListFolderWithContext(ctx context.Context, folder Folder) (objects []Object, subFolders []Folder, err error) {
+	if ctxFolder, ok := folder.(ContextFolder); ok {
+		return ctxFolder.ListFolderWithContext(ctx)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	return folder.ListFolder()
+}
+
+func DeleteObjectsWithContext(ctx context.Context, folder Folder, objectRelativePaths []string) error {
+	if ctxFolder, ok := folder.(ContextFolder); ok {
+		return ctxFolder.DeleteObjectsWithContext(ctx, objectRelativePaths)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return folder.DeleteObjects(objectRelativePaths)
+}
+
+func ExistsWithContext(ctx context.Context, folder Folder, objectRelativePath string) (bool, error) {
+	if ctxFolder, ok := folder.(ContextFolder); ok {
+		return ctxFolder.ExistsWithContext(ctx, objectRelativePath)
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return folder.Exists(objectRelativePath)
+}
+
+func ReadObjectWithContext(ctx context.Context, folder Folder, objectRelativePath string) (io.ReadCloser, error) {
+	if ctxFolder, ok := folder.(ContextFolder); ok {
+		return ctxFolder.ReadObjectWithContext(ctx, objectRelativePath)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	readCloser, err := folder.ReadObject(objectRelativePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewContextReadCloser(ctx, readCloser), nil
+}
+
+// ReadObjectRange reads a part of the object. Folders which can't do ranged reads natively
+// read the object from the beginning and discard everything before the offset.
+func ReadObjectRange(ctx context.Context, folder Folder, objectRelativePath string, offset, length int64) (io.ReadCloser, error) {
+	if ctxFolder, ok := folder.(ContextFolder); ok {
+		return ctxFolder.ReadObjectRange(ctx, objectRelativePath, offset, length)
+	}
+	readCloser, err := ReadObjectWithContext(ctx, folder, objectRelativePath)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := io.CopyN(io.Discard, readCloser, offset); err != nil && err != io.EOF {
+			_ = readCloser.Close()
+			return nil, err
+		}
+	}
+	return LimitReadCloser(readCloser, length), nil
+}
+
+// StatObject returns the object info. Folders which can't stat a single object
+// list the parent folder instead, so user metadata and ETag are left empty.
+func StatObject(ctx context.Context, folder Folder, objectRelativePath string) (*ObjectInfo, error) {
+	if ctxFolder, ok := folder.(ContextFolder); ok {
+		return ctxFolder.StatObject(ctx, objectRelativePath)
+	}
+	dirName, fileName := path.Split(objectRelativePath)
+	objects, _, err := ListFolderWithContext(ctx, folder.GetSubFolder(dirName))
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range objects {
+		if object.GetName() == fileName {
+			return &ObjectInfo{
+				Name:         objectRelativePath,
+				Size:         object.GetSize(),
+				LastModified: object.GetLastModified(),
+			}, nil
+		}
+	}
+	return nil, NewObjectNotFoundError(objectRelativePath)
+}
+
+func PutObjectWithContext(ctx context.Context, folder Folder, name string, content io.Reader) error {
+	if ctxFolder, ok := folder.(ContextFolder); ok {
+		return ctxFolder.PutObjectWithContext(ctx, name, content)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return folder.PutObject(name, NewContextReader(ctx, content))
+}
+
+func CopyObjectWithContext(ctx context.Context, folder Folder, srcPath string, dstPath string) error {
+	if ctxFolder, ok := folder.(ContextFolder); ok {
+		return ctxFolder.CopyObjectWithContext(ctx, srcPath, dstPath)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return folder.CopyObject(srcPath, dstPath)
+}
+
+type contextReader struct {
+	ctx context.Context
+	io.Reader
+}
+
+// NewContextReader returns a reader which fails with the ctx error as soon as ctx is done
+func NewContextReader(ctx context.Context, reader io.Reader) io.Reader {
+	return &contextReader{ctx, reader}
+}
+
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.Reader.Read(p)
+}
+
+// NewContextReadCloser is the same as NewContextReader, but keeps the Closer of the underlying reader
+func NewContextReadCloser(ctx context.Context, readCloser io.ReadCloser) io.ReadCloser {
+	return struct {
+		io.Reader
+		io.Closer
+	}{NewContextReader(ctx, readCloser), readCloser}
+}
+
+// LimitReadCloser limits the reader by length bytes. Negative length means no limit.
+func LimitReadCloser(readCloser io.ReadCloser, length int64) io.ReadCloser {
+	if length < 0 {
+		return readCloser
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(readCloser, length), readCloser}
+}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"path"
@@ -32,26 +33,47 @@ type Folder interface {
 	CopyObject(srcPath string, dstPath string) error
 }
 
+// DeleteObjectsBatchSize is the number of objects DeleteObjectsWhere deletes at once while walking the folder
+const DeleteObjectsBatchSize = 1000
+
 func DeleteObjectsWhere(folder Folder, confirm bool, objFilter func(object1 Object) bool, folderFilter func(name string) bool) error {
-	relativePathObjects, err := ListFolderRecursivelyWithFilter(folder, folderFilter)
-	if err != nil {
+	ctx := context.Background()
+	filteredRelativePaths := make([]string, 0)
+	matchedCount := 0
+	flush := func() error {
+		if !confirm || len(filteredRelativePaths) == 0 {
+			return nil
+		}
+		err := DeleteObjectsWithContext(ctx, folder, filteredRelativePaths)
+		filteredRelativePaths = filteredRelativePaths[:0]
 		return err
 	}
-	filteredRelativePaths := make([]string, 0)
+
 	tracelog.InfoLogger.Println("Objects in folder:")
-	for _, object := range relativePathObjects {
-		if objFilter(object) {
-			tracelog.InfoLogger.Println("\twill be deleted: " + object.GetName())
-			filteredRelativePaths = append(filteredRelativePaths, object.GetName())
-		} else {
+	err := WalkFolderWithFilter(ctx, folder, folderFilter, func(object Object) error {
+		if !objFilter(object) {
 			tracelog.DebugLogger.Println("\tskipped: " + object.GetName())
+			return nil
 		}
+		tracelog.InfoLogger.Println("\twill be deleted: " + object.GetName())
+		matchedCount++
+		if !confirm {
+			return nil
+		}
+		filteredRelativePaths = append(filteredRelativePaths, object.GetName())
+		if len(filteredRelativePaths) >= DeleteObjectsBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	if len(filteredRelativePaths) == 0 {
+	if matchedCount == 0 {
 		return nil
 	}
 	if confirm {
-		return folder.DeleteObjects(filteredRelativePaths)
+		return flush()
 	}
 	tracelog.InfoLogger.Println("Dry run, nothing were deleted")
 	return nil
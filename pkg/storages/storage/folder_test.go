@@ -2,11 +2,14 @@ package storage_test
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"sort"
 	"strings"
 	"testing"
 
 	"github.com/apecloud/dataprotection-wal-g/pkg/storages/memory"
+	"github.com/apecloud/dataprotection-wal-g/pkg/storages/memory/mock"
 	"github.com/apecloud/dataprotection-wal-g/pkg/storages/storage"
 	"github.com/stretchr/testify/assert"
 )
@@ -206,3 +209,106 @@ func TestListFolderRecursivelyWithPrefix(t *testing.T) {
 		assertFiles(t, files, []string{"a/111", "a/b/222"})
 	})
 }
+
+func TestReadObjectRange_FallbackForPlainFolder(t *testing.T) {
+	// mock.Folder does not implement storage.ContextFolder
+	folder := mock.NewFolder(memory.NewFolder("in_memory/", memory.NewStorage()))
+	err := folder.PutObject("sub/file", strings.NewReader("0123456789"))
+	assert.NoError(t, err)
+
+	readCloser, err := storage.ReadObjectRange(context.Background(), folder, "sub/file", 3, 4)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(readCloser)
+	assert.NoError(t, err)
+	assert.Equal(t, "3456", string(data))
+
+	info, err := storage.StatObject(context.Background(), folder, "sub/file")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), info.Size)
+	assert.Equal(t, "sub/file", info.Name)
+
+	_, err = storage.StatObject(context.Background(), folder, "sub/missing")
+	assert.Error(t, err.(storage.ObjectNotFoundError))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = storage.ReadObjectWithContext(ctx, folder, "sub/file")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestIterateObjects_PrefixAndStartAfter(t *testing.T) {
+	var folder = memory.NewFolder("in_memory/", memory.NewStorage())
+	for _, relativePath := range []string{"log.003", "log.001", "log.002", "other.001", "log.001.d/e"} {
+		err := folder.PutObject(relativePath, &bytes.Buffer{})
+		assert.NoError(t, err)
+	}
+
+	var names []string
+	err := storage.IterateObjects(context.Background(), folder,
+		storage.ListOptions{Prefix: "log.", StartAfter: "log.001"}, func(object storage.Object) error {
+			names = append(names, object.GetName())
+			return nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"log.002", "log.003"}, names)
+}
+
+func TestIterateObjects_StopIteration(t *testing.T) {
+	var folder = memory.NewFolder("in_memory/", memory.NewStorage())
+	for _, relativePath := range []string{"a", "b", "c"} {
+		err := folder.PutObject(relativePath, &bytes.Buffer{})
+		assert.NoError(t, err)
+	}
+
+	var names []string
+	err := storage.IterateObjects(context.Background(), folder, storage.ListOptions{}, func(object storage.Object) error {
+		names = append(names, object.GetName())
+		if object.GetName() == "b" {
+			return storage.ErrStopIteration
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, names)
+}
+
+func TestWalkFolderWithFilter(t *testing.T) {
+	var folder = memory.NewFolder("in_memory/", memory.NewStorage())
+	paths := []string{
+		"a",
+		"subfolder1/b",
+		"subfolder1/subfolder11/c",
+		"subfolder2/d",
+		"subfolder2/subfolder21/e",
+	}
+	for _, relativePath := range paths {
+		err := folder.PutObject(relativePath, &bytes.Buffer{})
+		assert.NoError(t, err)
+	}
+
+	var names []string
+	err := storage.WalkFolderWithFilter(context.Background(), folder, func(path string) bool {
+		return !strings.HasPrefix(path, "subfolder2/subfolder21")
+	}, func(object storage.Object) error {
+		names = append(names, object.GetName())
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "subfolder1/b", "subfolder1/subfolder11/c", "subfolder2/d"}, names)
+}
+
+func TestWalkFolder_StopIteration(t *testing.T) {
+	var folder = memory.NewFolder("in_memory/", memory.NewStorage())
+	for _, relativePath := range []string{"subfolder1/a", "subfolder2/b"} {
+		err := folder.PutObject(relativePath, &bytes.Buffer{})
+		assert.NoError(t, err)
+	}
+
+	var names []string
+	err := storage.WalkFolder(context.Background(), folder, func(object storage.Object) error {
+		names = append(names, object.GetName())
+		return storage.ErrStopIteration
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"subfolder1/a"}, names)
+}
@@ -0,0 +1,26 @@
+package storage
+
+import (
+	"context"
+	"io"
+)
+
+// MetadataFolder is an optional extension of Folder implemented by storages which are able
+// to attach key-value metadata to the objects on upload: S3 writes it as object tags,
+// GCS and Azure write it as the object metadata.
+type MetadataFolder interface {
+	Folder
+
+	// Keys should consist of letters, digits and underscores to fit the restrictions of all storages
+	PutObjectWithMetadata(ctx context.Context, name string, content io.Reader, metadata map[string]string) error
+}
+
+// PutObjectWithMetadata uploads the object with the metadata. Folders which can't store
+// the metadata upload the object without it.
+func PutObjectWithMetadata(ctx context.Context, folder Folder, name string, content io.Reader,
+	metadata map[string]string) error {
+	if metadataFolder, ok := folder.(MetadataFolder); ok && len(metadata) > 0 {
+		return metadataFolder.PutObjectWithMetadata(ctx, name, content, metadata)
+	}
+	return PutObjectWithContext(ctx, folder, name, content)
+}
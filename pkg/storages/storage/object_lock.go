@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type ObjectLockMode string
+
+const (
+	ObjectLockModeNone ObjectLockMode = ""
+	// ObjectLockModeGovernance retention can be shortened or removed by the privileged users
+	ObjectLockModeGovernance ObjectLockMode = "GOVERNANCE"
+	// ObjectLockModeCompliance retention can't be shortened or removed by anyone until it expires
+	ObjectLockModeCompliance ObjectLockMode = "COMPLIANCE"
+)
+
+// ErrObjectLockNotSupported is returned when the storage can't lock objects
+var ErrObjectLockNotSupported = errors.New("object lock is not supported by the storage")
+
+// ObjectLock describes the retention and the legal hold of an object
+type ObjectLock struct {
+	Mode        ObjectLockMode
+	RetainUntil time.Time
+	LegalHold   bool
+}
+
+// IsActive checks if the lock prevents the object from being deleted at the moment
+func (lock ObjectLock) IsActive(now time.Time) bool {
+	return lock.LegalHold || (lock.Mode != ObjectLockModeNone && lock.RetainUntil.After(now))
+}
+
+func (lock ObjectLock) String() string {
+	return fmt.Sprintf("mode: %q, retain until: %s, legal hold: %t",
+		lock.Mode, lock.RetainUntil.Format(time.RFC3339), lock.LegalHold)
+}
+
+// ParseObjectLockMode parses the case-insensitive lock mode name
+func ParseObjectLockMode(mode string) (ObjectLockMode, error) {
+	switch ObjectLockMode(strings.ToUpper(mode)) {
+	case ObjectLockModeNone:
+		return ObjectLockModeNone, nil
+	case ObjectLockModeGovernance:
+		return ObjectLockModeGovernance, nil
+	case ObjectLockModeCompliance:
+		return ObjectLockModeCompliance, nil
+	default:
+		return ObjectLockModeNone, fmt.Errorf("unknown object lock mode %q, expected %q or %q",
+			mode, ObjectLockModeGovernance, ObjectLockModeCompliance)
+	}
+}
+
+// LockingFolder is an optional extension of Folder implemented by storages which are able
+// to protect the objects from deletion: S3 Object Lock, Azure immutability policies and GCS holds.
+type LockingFolder interface {
+	Folder
+
+	// SetObjectLock sets or extends the lock of the existing object
+	SetObjectLock(ctx context.Context, objectRelativePath string, lock ObjectLock) error
+
+	// GetObjectLock returns the zero ObjectLock if the object is not locked.
+	// Should return ObjectNotFoundError in case, there is no such object
+	GetObjectLock(ctx context.Context, objectRelativePath string) (ObjectLock, error)
+}
+
+// SetObjectLock sets or extends the lock of the object. Returns ErrObjectLockNotSupported
+// if the folder can't lock objects.
+func SetObjectLock(ctx context.Context, folder Folder, objectRelativePath string, lock ObjectLock) error {
+	if lockingFolder, ok := folder.(LockingFolder); ok {
+		return lockingFolder.SetObjectLock(ctx, objectRelativePath, lock)
+	}
+	return ErrObjectLockNotSupported
+}
+
+// GetObjectLock returns the lock of the object. Objects of the folders which can't lock objects are never locked.
+func GetObjectLock(ctx context.Context, folder Folder, objectRelativePath string) (ObjectLock, error) {
+	if lockingFolder, ok := folder.(LockingFolder); ok {
+		return lockingFolder.GetObjectLock(ctx, objectRelativePath)
+	}
+	return ObjectLock{}, nil
+}
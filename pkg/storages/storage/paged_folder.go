@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strings"
+)
+
+// ErrStopIteration can be returned from the IterateObjects and WalkFolder callbacks to stop the listing without an error
+var ErrStopIteration = errors.New("stop iteration")
+
+// ListOptions narrows the listing of a single folder level
+type ListOptions struct {
+	// Prefix of the object and subfolder names relative to the listed folder
+	Prefix string
+	// StartAfter skips the names which are lexicographically less than or equal to it
+	StartAfter string
+}
+
+// PagedFolder is an optional extension of Folder implemented by storages which are able
+// to list huge folders page by page without keeping the whole listing in memory.
+type PagedFolder interface {
+	Folder
+
+	// ListFolderPages calls pageFn for each page of the listing. Objects have names relative to the folder.
+	// Pages are provided in the lexicographical order of the object names.
+	// The listing stops on the first error returned by pageFn.
+	ListFolderPages(ctx context.Context, opts ListOptions,
+		pageFn func(objects []Object, subFolders []Folder) error) error
+}
+
+// ListFolderPages lists the folder level page by page. Folders which can't list by pages
+// are listed with a single ListFolder call, filtered and provided as a single page.
+// The error returned by pageFn is passed through as is.
+func ListFolderPages(ctx context.Context, folder Folder, opts ListOptions,
+	pageFn func(objects []Object, subFolders []Folder) error) error {
+	if pagedFolder, ok := folder.(PagedFolder); ok {
+		return pagedFolder.ListFolderPages(ctx, opts, pageFn)
+	}
+	return listFolderAsSinglePage(ctx, folder, opts, pageFn)
+}
+
+func listFolderAsSinglePage(ctx context.Context, folder Folder, opts ListOptions,
+	pageFn func(objects []Object, subFolders []Folder) error) error {
+	objects, subFolders, err := ListFolderWithContext(ctx, folder)
+	if err != nil {
+		return err
+	}
+	filteredObjects := make([]Object, 0, len(objects))
+	for _, object := range objects {
+		if MatchesListOptions(object.GetName(), opts) {
+			filteredObjects = append(filteredObjects, object)
+		}
+	}
+	sort.Slice(filteredObjects, func(i, j int) bool {
+		return filteredObjects[i].GetName() < filteredObjects[j].GetName()
+	})
+	filteredSubFolders := make([]Folder, 0, len(subFolders))
+	for _, subFolder := range subFolders {
+		subFolderName := strings.TrimPrefix(subFolder.GetPath(), folder.GetPath())
+		if MatchesListOptions(subFolderName, opts) {
+			filteredSubFolders = append(filteredSubFolders, subFolder)
+		}
+	}
+	sort.Slice(filteredSubFolders, func(i, j int) bool {
+		return filteredSubFolders[i].GetPath() < filteredSubFolders[j].GetPath()
+	})
+	return pageFn(filteredObjects, filteredSubFolders)
+}
+
+// MatchesListOptions checks if the relative object or subfolder name passes the listing options
+func MatchesListOptions(name string, opts ListOptions) bool {
+	return strings.HasPrefix(name, opts.Prefix) && (opts.StartAfter == "" || name > opts.StartAfter)
+}
+
+// IterateObjects calls objectFn for every object of the folder level matching the options
+func IterateObjects(ctx context.Context, folder Folder, opts ListOptions, objectFn func(object Object) error) error {
+	err := ListFolderPages(ctx, folder, opts, func(objects []Object, _ []Folder) error {
+		for _, object := range objects {
+			if err := objectFn(object); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, ErrStopIteration) {
+		return nil
+	}
+	return err
+}
+
+// WalkFolder calls objectFn for every object in the folder and its subfolders
+func WalkFolder(ctx context.Context, folder Folder, objectFn func(object Object) error) error {
+	return WalkFolderWithFilter(ctx, folder, func(string) bool { return true }, objectFn)
+}
+
+// WalkFolderWithFilter calls objectFn for every object in the folder and the subfolders matching the folderSelector.
+// Objects have names relative to the folder. Only the pending subfolders are kept in memory, not the objects.
+func WalkFolderWithFilter(ctx context.Context, folder Folder, folderSelector func(path string) bool,
+	objectFn func(object Object) error) error {
+	err := walkFolderWithFilter(ctx, folder, folderSelector, objectFn)
+	if errors.Is(err, ErrStopIteration) {
+		return nil
+	}
+	return err
+}
+
+func walkFolderWithFilter(ctx context.Context, folder Folder, folderSelector func(path string) bool,
+	objectFn func(object Object) error) error {
+	stack := []Folder{folder}
+	for len(stack) > 0 {
+		subFolder := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		folderPrefix := strings.TrimPrefix(subFolder.GetPath(), folder.GetPath())
+
+		var nextSubFolders []Folder
+		err := ListFolderPages(ctx, subFolder, ListOptions{}, func(objects []Object, subFolders []Folder) error {
+			for _, object := range objects {
+				relativePath := path.Join(folderPrefix, object.GetName())
+				err := objectFn(NewLocalObject(relativePath, object.GetLastModified(), object.GetSize()))
+				if err != nil {
+					return err
+				}
+			}
+			nextSubFolders = append(nextSubFolders, filterSubfolders(folder.GetPath(), subFolders, folderSelector)...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// keep the lexicographical order of the walk
+		for i := len(nextSubFolders) - 1; i >= 0; i-- {
+			stack = append(stack, nextSubFolders[i])
+		}
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"math/rand"
 	"strings"
@@ -98,3 +99,42 @@ func RunFolderTest(storageFolder Folder, t *testing.T) {
 	_, err = sub1.ReadObject("Tumba Yumba")
 	assert.Error(t, err.(ObjectNotFoundError))
 }
+
+func RunContextFolderTest(storageFolder ContextFolder, t *testing.T) {
+	ctx := context.Background()
+
+	err := storageFolder.PutObjectWithContext(ctx, "file0", strings.NewReader("0123456789"))
+	assert.NoError(t, err)
+
+	info, err := storageFolder.StatObject(ctx, "file0")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10), info.Size)
+
+	readCloser, err := storageFolder.ReadObjectRange(ctx, "file0", 2, 5)
+	assert.NoError(t, err)
+	data, err := io.ReadAll(readCloser)
+	assert.NoError(t, err)
+	assert.Equal(t, "23456", string(data))
+	assert.NoError(t, readCloser.Close())
+
+	readCloser, err = storageFolder.ReadObjectRange(ctx, "file0", 7, -1)
+	assert.NoError(t, err)
+	data, err = io.ReadAll(readCloser)
+	assert.NoError(t, err)
+	assert.Equal(t, "789", string(data))
+	assert.NoError(t, readCloser.Close())
+
+	_, err = storageFolder.StatObject(ctx, "Tumba Yumba")
+	assert.Error(t, err.(ObjectNotFoundError))
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	err = storageFolder.PutObjectWithContext(canceledCtx, "file1", strings.NewReader("data1"))
+	assert.Error(t, err)
+
+	err = storageFolder.DeleteObjectsWithContext(ctx, []string{"file0"})
+	assert.NoError(t, err)
+	exists, err := storageFolder.ExistsWithContext(ctx, "file0")
+	assert.NoError(t, err)
+	assert.False(t, exists)
+}
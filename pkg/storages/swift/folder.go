@@ -1,7 +1,9 @@
 package swift
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"os"
 	"strings"
@@ -67,11 +69,20 @@ type Folder struct {
 	path       string
 }
 
+var _ storage.ContextFolder = &Folder{}
+
 func (folder *Folder) GetPath() string {
 	return folder.path
 }
 
 func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
+	return folder.ExistsWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ExistsWithContext(ctx context.Context, objectRelativePath string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
 	path := storage.JoinPath(folder.path, objectRelativePath)
 	_, _, err := folder.connection.Object(folder.container.Name, path)
 	if err == swift.ObjectNotFound {
@@ -83,10 +94,38 @@ func (folder *Folder) Exists(objectRelativePath string) (bool, error) {
 	return true, nil
 }
 
+func (folder *Folder) StatObject(ctx context.Context, objectRelativePath string) (*storage.ObjectInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	path := storage.JoinPath(folder.path, objectRelativePath)
+	obj, headers, err := folder.connection.Object(folder.container.Name, path)
+	if err == swift.ObjectNotFound {
+		return nil, storage.NewObjectNotFoundError(path)
+	}
+	if err != nil {
+		return nil, NewError(err, "Unable to stat object %v", path)
+	}
+	return &storage.ObjectInfo{
+		Name:         objectRelativePath,
+		Size:         obj.Bytes,
+		LastModified: obj.LastModified,
+		ETag:         obj.Hash,
+		UserMetadata: headers.ObjectMetadata(),
+	}, nil
+}
+
 func (folder *Folder) ListFolder() (objects []storage.Object, subFolders []storage.Folder, err error) {
+	return folder.ListFolderWithContext(context.Background())
+}
+
+func (folder *Folder) ListFolderWithContext(ctx context.Context) (objects []storage.Object, subFolders []storage.Folder, err error) {
 	//Iterate
 	err = folder.connection.ObjectsWalk(folder.container.Name, &swift.ObjectsOpts{Delimiter: int32('/'), Prefix: folder.path},
 		func(opts *swift.ObjectsOpts) (interface{}, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			objectNames, err := folder.connection.ObjectNames(folder.container.Name, opts)
 			if err != nil {
 				return nil, err
@@ -126,9 +165,34 @@ func (folder *Folder) GetSubFolder(subFolderRelativePath string) storage.Folder
 }
 
 func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectWithContext(context.Background(), objectRelativePath)
+}
+
+func (folder *Folder) ReadObjectWithContext(ctx context.Context, objectRelativePath string) (io.ReadCloser, error) {
+	return folder.ReadObjectRange(ctx, objectRelativePath, 0, -1)
+}
+
+func (folder *Folder) ReadObjectRange(ctx context.Context, objectRelativePath string, offset, length int64) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
 	path := storage.JoinPath(folder.path, objectRelativePath)
+	// hash can be checked only if the whole object is read
+	checkHash := true
+	var headers swift.Headers
+	if offset > 0 || length > 0 {
+		checkHash = false
+		bytesRange := fmt.Sprintf("bytes=%d-", offset)
+		if length > 0 {
+			bytesRange += fmt.Sprint(offset + length - 1)
+		}
+		headers = swift.Headers{"Range": bytesRange}
+	}
 	//get the object from the cloud using full path
-	readContents, _, err := folder.connection.ObjectOpen(folder.container.Name, path, true, nil)
+	readContents, _, err := folder.connection.ObjectOpen(folder.container.Name, path, checkHash, headers)
 	if err == swift.ObjectNotFound {
 		return nil, storage.NewObjectNotFoundError(path)
 	}
@@ -136,14 +200,21 @@ func (folder *Folder) ReadObject(objectRelativePath string) (io.ReadCloser, erro
 		return nil, NewError(err, "Unable to OPEN Object %v", path)
 	}
 	//retrieved object from  the cloud
-	return io.NopCloser(readContents), nil
+	return storage.NewContextReadCloser(ctx, io.NopCloser(readContents)), nil
 }
 
 func (folder *Folder) PutObject(name string, content io.Reader) error {
+	return folder.PutObjectWithContext(context.Background(), name, content)
+}
+
+func (folder *Folder) PutObjectWithContext(ctx context.Context, name string, content io.Reader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	tracelog.DebugLogger.Printf("Put %v into %v\n", name, folder.path)
 	path := storage.JoinPath(folder.path, name)
 	//put the object in the cloud using full path
-	_, err := folder.connection.ObjectPut(folder.container.Name, path, content, false, "", "", nil)
+	_, err := folder.connection.ObjectPut(folder.container.Name, path, storage.NewContextReader(ctx, content), false, "", "", nil)
 	if err != nil {
 		return NewError(err, "Unable to write content.")
 	}
@@ -152,7 +223,11 @@ func (folder *Folder) PutObject(name string, content io.Reader) error {
 }
 
 func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
-	if exists, err := folder.Exists(srcPath); !exists {
+	return folder.CopyObjectWithContext(context.Background(), srcPath, dstPath)
+}
+
+func (folder *Folder) CopyObjectWithContext(ctx context.Context, srcPath string, dstPath string) error {
+	if exists, err := folder.ExistsWithContext(ctx, srcPath); !exists {
 		if err == nil {
 			return errors.New("object does not exist")
 		}
@@ -163,7 +238,14 @@ func (folder *Folder) CopyObject(srcPath string, dstPath string) error {
 }
 
 func (folder *Folder) DeleteObjects(objectRelativePaths []string) error {
+	return folder.DeleteObjectsWithContext(context.Background(), objectRelativePaths)
+}
+
+func (folder *Folder) DeleteObjectsWithContext(ctx context.Context, objectRelativePaths []string) error {
 	for _, objectRelativePath := range objectRelativePaths {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		path := storage.JoinPath(folder.path, objectRelativePath)
 		tracelog.DebugLogger.Printf("Delete object %v\n", path)
 		err := folder.connection.ObjectDelete(folder.container.Name, path)
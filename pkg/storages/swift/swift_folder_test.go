@@ -24,6 +24,14 @@ func TestSwiftFolderUsingConfigFile(t *testing.T) {
 	storage.RunFolderTest(storageFolderUsingConfigFile, t)
 }
 
+func TestSwiftContextFolder(t *testing.T) {
+	t.Skip("Credentials needed to run Swift Storage tests")
+
+	storageFolder, err := ConfigureFolder("swift://test-container/test-folder/sub0", settings)
+	assert.NoError(t, err)
+	storage.RunContextFolderTest(storageFolder.(storage.ContextFolder), t)
+}
+
 func TestSwiftFolderUsingEnvVariables(t *testing.T) {
 	t.Skip("Credentials needed to run Swift Storage tests")
 
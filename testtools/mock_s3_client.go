@@ -8,6 +8,7 @@ import (
 	walgs3 "github.com/apecloud/dataprotection-wal-g/pkg/storages/s3"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3iface"
 )
@@ -16,6 +17,7 @@ import (
 // ListObjects(*ListObjectsV2Input)
 // GetObject(*GetObjectInput)
 // HeadObject(*HeadObjectInput)
+// and their WithContext versions
 type MockS3Client struct {
 	s3iface.S3API
 	err      bool
@@ -64,6 +66,21 @@ func (client *MockS3Client) HeadObject(input *s3.HeadObjectInput) (*s3.HeadObjec
 	return &s3.HeadObjectOutput{}, nil
 }
 
+func (client *MockS3Client) ListObjectsV2PagesWithContext(_ aws.Context, input *s3.ListObjectsV2Input,
+	callback func(*s3.ListObjectsV2Output, bool) bool, _ ...request.Option) error {
+	return client.ListObjectsV2Pages(input, callback)
+}
+
+func (client *MockS3Client) GetObjectWithContext(_ aws.Context, input *s3.GetObjectInput,
+	_ ...request.Option) (*s3.GetObjectOutput, error) {
+	return client.GetObject(input)
+}
+
+func (client *MockS3Client) HeadObjectWithContext(_ aws.Context, input *s3.HeadObjectInput,
+	_ ...request.Option) (*s3.HeadObjectOutput, error) {
+	return client.HeadObject(input)
+}
+
 // Creates 5 fake S3 objects with Key and LastModified field.
 func fakeContents() []*s3.Object {
 	c := make([]*s3.Object, 5)
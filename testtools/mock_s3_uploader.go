@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"io"
 
+	"github.com/aws/aws-sdk-go/aws"
+
 	"github.com/apecloud/dataprotection-wal-g/pkg/storages/memory"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
@@ -25,6 +27,7 @@ func (err mockMultiFailureError) Error() string {
 
 // Mock out uploader client for S3. Includes these methods:
 // Upload(*UploadInput, ...func(*s3manager.Uploader))
+// UploadWithContext(aws.Context, *UploadInput, ...func(*s3manager.Uploader))
 type MockS3Uploader struct {
 	s3manageriface.UploaderAPI
 	multiErr bool
@@ -69,3 +72,8 @@ func (uploader *MockS3Uploader) Upload(input *s3manager.UploadInput,
 
 	return output, nil
 }
+
+func (uploader *MockS3Uploader) UploadWithContext(_ aws.Context, input *s3manager.UploadInput,
+	f ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	return uploader.Upload(input, f...)
+}
@@ -58,6 +58,13 @@ const (
 	StreamMetadataFileName = "stream_metadata.json"
 	PathSeparator          = string(os.PathSeparator)
 	Mebibyte               = 1024 * 1024
+
+	// CompressionDictionaryPath is the folder of the trained compression dictionaries in the storage root
+	CompressionDictionaryPath = "compression_dicts_" + VersionStr + "/"
+	// DataKeyPath is the folder of the wrapped data keys of the envelope encryption in the storage root
+	DataKeyPath = "data_keys_" + VersionStr + "/"
+	// SegmentsPath is the folder of the Greenplum segment backups and WAL in the storage root
+	SegmentsPath = "segments_" + VersionStr + "/"
 )
 
 // MaxTime not really the maximal value, but high enough.